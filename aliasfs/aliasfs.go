@@ -0,0 +1,461 @@
+// Package aliasfs serves some directories of an afero.Fs from other
+// filesystems.
+package aliasfs
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Alias serves the directory at Path, and everything under it, from the
+// root of Fs. ReadOnly aliases can't be written.
+type Alias struct {
+	Path     string
+	Fs       afero.Fs
+	ReadOnly bool
+}
+
+// Fs is an afero.Fs that serves the paths of its aliases from their own
+// filesystems and the others from its source. The aliases show up in the
+// directories above them as normal directories, replacing the entries
+// of the source with the same name.
+//
+// The aliases can't be moved or removed, nor the directories above them,
+// and files can't be moved between them and the rest of the Fs.
+type Fs struct {
+	source  afero.Fs
+	aliases []Alias
+}
+
+// New creates a new Fs on top of source with the given aliases. Aliases
+// at the root or inside other aliases are ignored.
+func New(source afero.Fs, aliases []Alias) *Fs {
+	fs := &Fs{source: source}
+
+	sorted := append([]Alias(nil), aliases...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(clean(sorted[i].Path)) < len(clean(sorted[j].Path))
+	})
+
+	for _, alias := range sorted {
+		alias.Path = clean(alias.Path)
+		if alias.Path == "/" || fs.alias(alias.Path) != nil {
+			continue
+		}
+
+		fs.aliases = append(fs.aliases, alias)
+	}
+
+	return fs
+}
+
+// Source returns the underlying Fs.
+func (fs *Fs) Source() afero.Fs {
+	return fs.source
+}
+
+// Resolve returns the Fs that serves name and the path of name on it.
+func (fs *Fs) Resolve(name string) (afero.Fs, string) {
+	if alias := fs.alias(name); alias != nil {
+		return alias.Fs, rel(alias, name)
+	}
+
+	return fs.source, name
+}
+
+// WithContext binds the underlying filesystems to ctx if they support it.
+func (fs *Fs) WithContext(ctx context.Context) afero.Fs {
+	bound := &Fs{source: withContext(fs.source, ctx), aliases: make([]Alias, len(fs.aliases))}
+	for i, alias := range fs.aliases {
+		alias.Fs = withContext(alias.Fs, ctx)
+		bound.aliases[i] = alias
+	}
+
+	return bound
+}
+
+func withContext(fs afero.Fs, ctx context.Context) afero.Fs {
+	if cfs, ok := fs.(interface {
+		WithContext(ctx context.Context) afero.Fs
+	}); ok {
+		return cfs.WithContext(ctx)
+	}
+
+	return fs
+}
+
+func clean(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+// alias returns the alias that serves name, or nil if the source does.
+func (fs *Fs) alias(name string) *Alias {
+	name = clean(name)
+	for i, alias := range fs.aliases {
+		if name == alias.Path || strings.HasPrefix(name, alias.Path+"/") {
+			return &fs.aliases[i]
+		}
+	}
+
+	return nil
+}
+
+// rel returns the path of name on the Fs of alias.
+func rel(alias *Alias, name string) string {
+	return "/" + strings.TrimPrefix(strings.TrimPrefix(clean(name), alias.Path), "/")
+}
+
+// isRoot checks if name is the root of one of the aliases.
+func (fs *Fs) isRoot(name string) bool {
+	alias := fs.alias(name)
+	return alias != nil && alias.Path == clean(name)
+}
+
+// contains checks if an alias is under name, which can't be moved or
+// removed without it.
+func (fs *Fs) contains(name string) bool {
+	name = clean(name)
+	for _, alias := range fs.aliases {
+		if name == "/" || strings.HasPrefix(alias.Path, name+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// children returns the aliases right under dir.
+func (fs *Fs) children(dir string) []*Alias {
+	dir = clean(dir)
+
+	var children []*Alias
+	for i, alias := range fs.aliases {
+		if path.Dir(alias.Path) == dir {
+			children = append(children, &fs.aliases[i])
+		}
+	}
+
+	return children
+}
+
+// writable returns the Fs that serves name and the path of name on it,
+// or an error if name can't be written.
+func (fs *Fs) writable(op, name string) (afero.Fs, string, error) {
+	alias := fs.alias(name)
+	if alias == nil {
+		return fs.source, name, nil
+	}
+
+	if alias.ReadOnly {
+		return nil, "", denied(op, name)
+	}
+
+	return alias.Fs, rel(alias, name), nil
+}
+
+func denied(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+// rename wraps the errors of the aliases so they have the path of the
+// Fs instead of the one on the Fs of the alias.
+func rename(err error, name string) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return &os.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+
+	return err
+}
+
+// Name implements afero.Fs.
+func (fs *Fs) Name() string {
+	return "aliasfs"
+}
+
+// Remote reports if the source is expensive to read.
+func (fs *Fs) Remote() bool {
+	r, ok := fs.source.(interface{ Remote() bool })
+	return ok && r.Remote()
+}
+
+// Create implements afero.Fs.
+func (fs *Fs) Create(name string) (afero.File, error) {
+	if fs.isRoot(name) {
+		return nil, denied("create", name)
+	}
+
+	target, p, err := fs.writable("create", name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := target.Create(p)
+	return f, rename(err, name)
+}
+
+// Mkdir implements afero.Fs.
+func (fs *Fs) Mkdir(name string, perm os.FileMode) error {
+	if fs.isRoot(name) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	target, p, err := fs.writable("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return rename(target.Mkdir(p, perm), name)
+}
+
+// MkdirAll implements afero.Fs.
+func (fs *Fs) MkdirAll(name string, perm os.FileMode) error {
+	if fs.isRoot(name) {
+		return nil
+	}
+
+	target, p, err := fs.writable("mkdir", name)
+	if err != nil {
+		return err
+	}
+
+	return rename(target.MkdirAll(p, perm), name)
+}
+
+// Open implements afero.Fs.
+func (fs *Fs) Open(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile implements afero.Fs.
+func (fs *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	target, p := fs.Resolve(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		var err error
+		if target, p, err = fs.writable("open", name); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := target.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, rename(err, name)
+	}
+
+	if fs.isRoot(name) {
+		return &file{File: f, name: name}, nil
+	}
+
+	if children := fs.children(name); len(children) > 0 {
+		return &file{File: f, name: name, children: children}, nil
+	}
+
+	return f, nil
+}
+
+// Remove implements afero.Fs.
+func (fs *Fs) Remove(name string) error {
+	if fs.isRoot(name) || fs.contains(name) {
+		return denied("remove", name)
+	}
+
+	target, p, err := fs.writable("remove", name)
+	if err != nil {
+		return err
+	}
+
+	return rename(target.Remove(p), name)
+}
+
+// RemoveAll implements afero.Fs.
+func (fs *Fs) RemoveAll(name string) error {
+	if fs.isRoot(name) || fs.contains(name) {
+		return denied("removeall", name)
+	}
+
+	target, p, err := fs.writable("removeall", name)
+	if err != nil {
+		return err
+	}
+
+	return rename(target.RemoveAll(p), name)
+}
+
+// Rename implements afero.Fs.
+func (fs *Fs) Rename(oldname, newname string) error {
+	if fs.isRoot(oldname) || fs.contains(oldname) || fs.isRoot(newname) {
+		return denied("rename", oldname)
+	}
+
+	if fs.alias(oldname) != fs.alias(newname) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrPermission}
+	}
+
+	target, oldpath, err := fs.writable("rename", oldname)
+	if err != nil {
+		return err
+	}
+
+	_, newpath := fs.Resolve(newname)
+	return rename(target.Rename(oldpath, newpath), oldname)
+}
+
+// Stat implements afero.Fs.
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	target, p := fs.Resolve(name)
+	info, err := target.Stat(p)
+	if err != nil {
+		return nil, rename(err, name)
+	}
+
+	if fs.isRoot(name) {
+		return renamedInfo{FileInfo: info, name: path.Base(clean(name))}, nil
+	}
+
+	return info, nil
+}
+
+// LstatIfPossible implements afero.Lstater.
+func (fs *Fs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	target, p := fs.Resolve(name)
+	lstater, ok := target.(afero.Lstater)
+	if !ok || fs.isRoot(name) {
+		// The root of an alias is always the directory it serves.
+		info, err := fs.Stat(name)
+		return info, false, err
+	}
+
+	info, lstated, err := lstater.LstatIfPossible(p)
+	return info, lstated, rename(err, name)
+}
+
+// Chmod implements afero.Fs.
+func (fs *Fs) Chmod(name string, mode os.FileMode) error {
+	target, p, err := fs.writable("chmod", name)
+	if err != nil {
+		return err
+	}
+
+	return rename(target.Chmod(p, mode), name)
+}
+
+// Chtimes implements afero.Fs.
+func (fs *Fs) Chtimes(name string, atime, mtime time.Time) error {
+	target, p, err := fs.writable("chtimes", name)
+	if err != nil {
+		return err
+	}
+
+	return rename(target.Chtimes(p, atime, mtime), name)
+}
+
+// renamedInfo gives the root of an alias the name of its path.
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string {
+	return i.name
+}
+
+// file has the path of the Fs as its name and adds the aliases under
+// it to its entries.
+type file struct {
+	afero.File
+	name     string
+	children []*Alias
+	// pending are the aliases not returned by Readdir yet.
+	pending []os.FileInfo
+	started bool
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil || clean(f.name) == "/" {
+		return info, err
+	}
+
+	return renamedInfo{FileInfo: info, name: path.Base(clean(f.name))}, nil
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.started {
+		f.started = true
+		for _, alias := range f.children {
+			info, err := alias.Fs.Stat("/")
+			if err != nil || !info.IsDir() {
+				continue
+			}
+
+			f.pending = append(f.pending, renamedInfo{FileInfo: info, name: path.Base(alias.Path)})
+		}
+	}
+
+	for {
+		infos, err := f.File.Readdir(count)
+
+		kept := infos[:0]
+		for _, info := range infos {
+			if !f.shadowed(info.Name()) {
+				kept = append(kept, info)
+			}
+		}
+
+		if err != nil && err != io.EOF {
+			return kept, err
+		}
+
+		done := err == io.EOF || len(infos) == 0 || count <= 0
+		if done {
+			n := len(f.pending)
+			if count > 0 && n > count-len(kept) {
+				n = count - len(kept)
+			}
+
+			kept = append(kept, f.pending[:n]...)
+			f.pending = f.pending[n:]
+		}
+
+		switch {
+		case count <= 0:
+			return kept, nil
+		case len(kept) > 0:
+			return kept, nil
+		case done:
+			return nil, io.EOF
+		}
+	}
+}
+
+// shadowed checks if an entry of the source is replaced by an alias.
+func (f *file) shadowed(name string) bool {
+	for _, alias := range f.children {
+		if path.Base(alias.Path) == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *file) Readdirnames(count int) ([]string, error) {
+	infos, err := f.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+
+	return names, err
+}
@@ -5,6 +5,7 @@ import (
 	nerrors "errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
@@ -31,6 +32,38 @@ func addConfigFlags(flags *pflag.FlagSet) {
 	addUserFlags(flags)
 	flags.BoolP("signup", "s", false, "allow users to signup")
 	flags.String("shell", "", "shell command to which other commands should be appended")
+	flags.Bool("normalizeNames", false, "find files whose names use another Unicode normalization form")
+	flags.Bool("caseInsensitive", settings.DefaultCaseInsensitive, "find files whose names only differ in case")
+	flags.Bool("plainTextCLI", false, "list directories as plain text to command line clients such as curl")
+	flags.StringSlice("noIndex", nil, "paths search engines shouldn't index, such as /share")
+	flags.Bool("trackChanges", false, "keep the last listing polled for changes to report the deleted files")
+	flags.Bool("dirTemplates", false, "render the HTML listings of directories with their .template.html file")
+	flags.Bool("gitStatus", false, "annotate the listings of the directories in git work trees with the status of their files (needs git)")
+	flags.Bool("docMeta", false, "annotate the PDFs and the OOXML documents of the listings with their titles, authors and numbers of pages")
+	flags.String("dirOptions", "", "name of the options files of the directories setting how their listings are sorted, limited and titled, such as .filemanager.json (empty for none)")
+	flags.String("trashDir", "", "directory of the scopes where the deleted files are moved to, such as /.trash (removed if empty)")
+	flags.Bool("showHidden", false, "list the files whose names start with a dot")
+	flags.Bool("dirsFirst", false, "list the directories before the files, whatever the sorting")
+	flags.Bool("dirSizes", false, "list the directories with the sizes of their contents, walking them")
+	flags.String("dirMode", "", "octal mode of the new directories (defaults to 0755)")
+	flags.Bool("allowSpecialBits", false, "let the modes the files are changed to have the setuid, setgid and sticky bits")
+	flags.Int64("maxEditSize", 0, "maximum size in bytes of the files replaced with PUT or read with content=true (defaults to 10 MiB)")
+	flags.Bool("requireConditionalWrites", false, "refuse to replace the existing files with PUT without an If-Match or an If-Unmodified-Since")
+	flags.Int64("maxChecksumSize", 0, "maximum size in bytes of the files checksummed in the listings (defaults to 64 MiB)")
+	flags.Int("searchLimit", 0, "maximum number of results of the searches of the listings (defaults to 500)")
+	flags.Int("feedLimit", 0, "maximum number of entries of the RSS feeds of the listings (defaults to 50)")
+	flags.String("listingIndex", "", "show the index file of directories above their HTML listings (show, or hide to also leave it out of the listing)")
+	flags.StringSlice("readmes", settings.DefaultReadmes, "names of the README files shown above the HTML listings of their directories, by priority (off if empty)")
+	flags.String("dateFormat", "", "Go layout of the dates of the listings, such as 02/01/2006 15:04")
+	flags.String("timezone", "", "IANA time zone of the dates of the listings, such as Asia/Tokyo (defaults to the server's)")
+	flags.String("sortLocale", "", "BCP 47 tag of the language whose collation sorts the names of the listings, such as de (case-folded if empty)")
+	flags.Int("defaultLimit", 0, "number of items listed when the requests don't set a limit (0 for all)")
+	flags.Int("maxLimit", 0, "maximum number of items the requests can list (0 for no limit)")
+	flags.String("symlinks", "", "how the symbolic links are listed (follow, show to mark them with their targets, or hide)")
+	flags.StringToString("categories", nil, "custom file categories by extension, such as .blend=document")
+	flags.StringToString("mimeTypes", nil, "custom MIME types by file name or extension, such as README=text/markdown")
+	flags.Int("hookTimeout", settings.DefaultHookTimeout, "seconds the commands of the hooks can run before they are killed")
+	flags.StringArray("webhooks", nil, `URLs notified of the operations on the files, each optionally followed by "events=upload,delete,rename,copy,mkdir" and "secret=..." separated by spaces; repeat for several`)
 
 	flags.String("auth.method", string(auth.MethodJSONAuth), "authentication type")
 	flags.String("auth.header", "", "HTTP header for auth.method=proxy")
@@ -42,6 +75,39 @@ func addConfigFlags(flags *pflag.FlagSet) {
 	flags.String("branding.name", "", "replace 'File Browser' by this name")
 	flags.String("branding.files", "", "path to directory with images and custom styles")
 	flags.Bool("branding.disableExternal", false, "disable external links such as GitHub links")
+	flags.String("branding.theme", "", "default theme of the HTML listings (light or dark); defaults to the browser preference")
+	flags.String("branding.title", "", "prefix of the titles of the HTML listings; defaults to the name")
+	flags.String("branding.favicon", "", "icon of the HTML listings: folder, cloud, star or the path of an image")
+	flags.StringSlice("branding.styles", nil, "stylesheets added to the HTML listings: URLs or paths inside the branding directory")
+	flags.StringSlice("branding.scripts", nil, "scripts added to the HTML listings: URLs or paths inside the branding directory")
+	flags.String("branding.listing", "", "path of the template of the HTML listings, reloaded when it changes (embedded one if empty)")
+
+	flags.Int("tree.maxDepth", settings.DefaultTreeMaxDepth, "maximum depth of the directory trees")
+	flags.Int("tree.maxNodes", settings.DefaultTreeMaxNodes, "maximum number of entries of the directory trees")
+	flags.Int("listingCache.ttl", 0, "seconds the listings of the directories are cached for, until they change (off if 0)")
+	flags.Int("listingCache.maxEntries", settings.DefaultListingCacheEntries, "maximum number of listings cached")
+	flags.Int("listingCache.maxItems", settings.DefaultListingCacheItems, "maximum number of items of all the cached listings")
+	flags.Bool("live.disabled", false, "disable the live updates of the listings")
+	flags.Int("live.connections", settings.DefaultLiveConnections, "maximum number of live updates at once")
+	flags.Int("live.perUser", 0, "maximum number of live updates a user can have at once (0 for no limit)")
+	flags.Int("live.delay", settings.DefaultLiveDelay, "milliseconds the changes of the live updates are gathered before they're sent")
+	flags.Int("slow.listing", 0, "milliseconds over which the listings are logged as slow (off if 0)")
+	flags.Int("slow.render", 0, "milliseconds over which the rendering of the listings is logged as slow (off if 0)")
+	flags.Int("slow.write", 0, "milliseconds over which the changes to the files are logged as slow (off if 0)")
+	flags.IntSlice("images.sizes", nil, "widths and heights the images can be resized to, such as 320,1200 (off if empty)")
+	flags.StringSlice("images.formats", []string{settings.ImageFormatJPEG, settings.ImageFormatPNG}, "formats the images can be resized to (jpeg, png)")
+	flags.IntSlice("images.thumbs", []int{settings.DefaultThumbSize}, "sizes of the boxes the thumbnails of the images fit in, the first of which the listings link to (off if empty)")
+	flags.Int64("uploads.maxSize", 0, "size in bytes of the biggest file uploaded (0 for no limit)")
+	flags.StringSlice("uploads.allowedExtensions", nil, "extensions of the only files that can be uploaded, such as .jpg, whatever the blocked ones")
+	flags.StringSlice("uploads.blockedExtensions", nil, "extensions of the files that can't be uploaded")
+	flags.Int("uploads.chunkTTL", settings.DefaultChunkTTL, "minutes the incomplete chunked uploads are kept after their last chunk")
+	flags.Bool("fetch.enabled", false, "let the users upload files by URL, which the server fetches")
+	flags.Int64("fetch.maxSize", 0, "size in bytes of the biggest file fetched (0 for no limit)")
+	flags.StringSlice("fetch.allowNetworks", nil, "IPs or CIDRs of the private networks the server fetches from anyway")
+	flags.Int("archives.jobs", 1, "number of archives built in the background at once")
+	flags.Int("archives.perUser", 0, "number of archives a user can have waiting or being built (0 for no limit)")
+	flags.Int("archives.ttl", settings.DefaultArchiveTTL, "minutes the archives built in the background are kept")
+	flags.Int64("archives.minFree", 0, "bytes kept free on the disk of the archive spool")
 }
 
 func getAuthentication(flags *pflag.FlagSet, defaults ...interface{}) (settings.AuthMethod, auth.Auther) {
@@ -117,6 +183,69 @@ func getAuthentication(flags *pflag.FlagSet, defaults ...interface{}) (settings.
 	return method, auther
 }
 
+func formatCategories(categories map[string]string) string {
+	pairs := make([]string, 0, len(categories))
+	for ext, category := range categories {
+		pairs = append(pairs, ext+"="+category)
+	}
+
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// parseWebhooks parses the webhooks of the flag, which are URLs followed
+// by their options separated by spaces.
+func parseWebhooks(values []string) ([]settings.Webhook, error) {
+	hooks := make([]settings.Webhook, 0, len(values))
+	for _, value := range values {
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		hook := settings.Webhook{URL: fields[0]}
+		for _, field := range fields[1:] {
+			switch {
+			case strings.HasPrefix(field, "events="):
+				hook.Events = strings.Split(strings.TrimPrefix(field, "events="), ",")
+			case strings.HasPrefix(field, "secret="):
+				hook.Secret = strings.TrimPrefix(field, "secret=")
+			default:
+				return nil, fmt.Errorf("unknown option %q of the webhook %s", field, hook.URL)
+			}
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+func mustGetWebhooks(flags *pflag.FlagSet) []settings.Webhook {
+	values, err := flags.GetStringArray("webhooks")
+	checkErr(err)
+	hooks, err := parseWebhooks(values)
+	checkErr(err)
+	return hooks
+}
+
+// formatWebhooks describes the webhooks without their secrets.
+func formatWebhooks(hooks []settings.Webhook) string {
+	list := make([]string, 0, len(hooks))
+	for _, hook := range hooks {
+		s := hook.URL
+		if len(hook.Events) > 0 {
+			s += " events=" + strings.Join(hook.Events, ",")
+		}
+		if hook.Secret != "" {
+			s += " (signed)"
+		}
+		list = append(list, s)
+	}
+
+	return strings.Join(list, "; ")
+}
+
 func printSettings(ser *settings.Server, set *settings.Settings, auther auth.Auther) {
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 
@@ -124,10 +253,82 @@ func printSettings(ser *settings.Server, set *settings.Settings, auther auth.Aut
 	fmt.Fprintf(w, "Create User Dir:\t%t\n", set.CreateUserDir)
 	fmt.Fprintf(w, "Auth method:\t%s\n", set.AuthMethod)
 	fmt.Fprintf(w, "Shell:\t%s\t\n", strings.Join(set.Shell, " "))
+	fmt.Fprintf(w, "Normalize names:\t%t\n", set.NormalizeNames)
+	fmt.Fprintf(w, "Case insensitive:\t%t\n", set.CaseInsensitive)
+	fmt.Fprintf(w, "Plain text for CLI:\t%t\n", set.PlainTextCLI)
+	fmt.Fprintf(w, "No index:\t%s\n", strings.Join(set.NoIndex, " "))
+	fmt.Fprintf(w, "Track changes:\t%t\n", set.TrackChanges)
+	fmt.Fprintf(w, "Directory templates:\t%t\n", set.DirTemplates)
+	fmt.Fprintf(w, "Git status:\t%t\n", set.GitStatus)
+	fmt.Fprintf(w, "Document metadata:\t%t\n", set.DocMeta)
+	fmt.Fprintf(w, "Directory options:\t%s\n", set.DirOptions)
+	fmt.Fprintf(w, "Trash directory:\t%s\n", set.TrashDir)
+	fmt.Fprintf(w, "Show hidden files:\t%t\n", set.ShowHidden)
+	fmt.Fprintf(w, "Directories first:\t%t\n", set.DirsFirst)
+	fmt.Fprintf(w, "Directory sizes:\t%t\n", set.DirSizes)
+	fmt.Fprintf(w, "Directory mode:\t%04o\n", set.NewDirMode())
+	fmt.Fprintf(w, "Allow special bits:\t%t\n", set.AllowSpecialBits)
+	fmt.Fprintf(w, "Maximum edit size:\t%d\n", set.EditLimit())
+	fmt.Fprintf(w, "Require conditional writes:\t%t\n", set.RequireConditionalWrites)
+	fmt.Fprintf(w, "Maximum checksum size:\t%d\n", set.ChecksumLimit())
+	fmt.Fprintf(w, "Search limit:\t%d\n", set.ListingSearchLimit())
+	fmt.Fprintf(w, "Feed limit:\t%d\n", set.ListingFeedLimit())
+	fmt.Fprintf(w, "Listing index:\t%s\n", set.ListingIndex)
+	fmt.Fprintf(w, "READMEs:\t%s\n", strings.Join(set.Readmes, " "))
+	fmt.Fprintf(w, "Date format:\t%s\n", set.DateFormat)
+	fmt.Fprintf(w, "Time zone:\t%s\n", set.Timezone)
+	fmt.Fprintf(w, "Sort locale:\t%s\n", set.SortLocale)
+	fmt.Fprintf(w, "Default item limit:\t%d\n", set.DefaultLimit)
+	fmt.Fprintf(w, "Maximum item limit:\t%d\n", set.MaxLimit)
+	fmt.Fprintf(w, "Symbolic links:\t%s\n", set.Symlinks)
+	fmt.Fprintf(w, "Categories:\t%s\n", formatCategories(set.Categories))
+	fmt.Fprintf(w, "MIME types:\t%s\n", formatCategories(set.MimeTypes))
+	fmt.Fprintf(w, "Webhooks:\t%s\n", formatWebhooks(set.Webhooks))
+	fmt.Fprintf(w, "Hook timeout:\t%ds\n", set.HookTimeout)
 	fmt.Fprintln(w, "\nBranding:")
 	fmt.Fprintf(w, "\tName:\t%s\n", set.Branding.Name)
 	fmt.Fprintf(w, "\tFiles override:\t%s\n", set.Branding.Files)
 	fmt.Fprintf(w, "\tDisable external links:\t%t\n", set.Branding.DisableExternal)
+	fmt.Fprintf(w, "\tTheme:\t%s\n", set.Branding.Theme)
+	fmt.Fprintf(w, "\tTitle:\t%s\n", set.Branding.Title)
+	fmt.Fprintf(w, "\tFavicon:\t%s\n", set.Branding.Favicon)
+	fmt.Fprintf(w, "\tStyles:\t%s\n", strings.Join(set.Branding.Styles, " "))
+	fmt.Fprintf(w, "\tScripts:\t%s\n", strings.Join(set.Branding.Scripts, " "))
+	fmt.Fprintf(w, "\tListing template:\t%s\n", set.Branding.Listing)
+	fmt.Fprintln(w, "\nTree:")
+	fmt.Fprintf(w, "\tMax depth:\t%d\n", set.Tree.MaxDepth)
+	fmt.Fprintf(w, "\tMax nodes:\t%d\n", set.Tree.MaxNodes)
+	fmt.Fprintln(w, "\nListing cache:")
+	fmt.Fprintf(w, "\tTTL:\t%ds\n", set.ListingCache.TTL)
+	fmt.Fprintf(w, "\tMax entries:\t%d\n", set.ListingCache.MaxEntries)
+	fmt.Fprintf(w, "\tMax items:\t%d\n", set.ListingCache.MaxItems)
+	fmt.Fprintln(w, "\nLive updates:")
+	fmt.Fprintf(w, "\tDisabled:\t%t\n", set.Live.Disabled)
+	fmt.Fprintf(w, "\tConnections:\t%d\n", set.Live.MaxConnections())
+	fmt.Fprintf(w, "\tPer user:\t%d\n", set.Live.PerUser)
+	fmt.Fprintf(w, "\tDelay:\t%s\n", set.Live.Batching())
+	fmt.Fprintln(w, "\nSlow operations:")
+	fmt.Fprintf(w, "\tListing:\t%dms\n", set.Slow.Listing)
+	fmt.Fprintf(w, "\tRender:\t%dms\n", set.Slow.Render)
+	fmt.Fprintf(w, "\tWrite:\t%dms\n", set.Slow.Write)
+	fmt.Fprintln(w, "\nImages:")
+	fmt.Fprintf(w, "\tSizes:\t%s\n", strings.Trim(fmt.Sprint(set.Images.Sizes), "[]"))
+	fmt.Fprintf(w, "\tFormats:\t%s\n", strings.Join(set.Images.Formats, " "))
+	fmt.Fprintf(w, "\tThumbnails:\t%s\n", strings.Trim(fmt.Sprint(set.Images.Thumbs), "[]"))
+	fmt.Fprintln(w, "\nUploads:")
+	fmt.Fprintf(w, "\tMax size:\t%d\n", set.Uploads.MaxSize)
+	fmt.Fprintf(w, "\tAllowed extensions:\t%s\n", strings.Join(set.Uploads.AllowedExtensions, " "))
+	fmt.Fprintf(w, "\tBlocked extensions:\t%s\n", strings.Join(set.Uploads.BlockedExtensions, " "))
+	fmt.Fprintf(w, "\tChunk TTL:\t%s\n", set.Uploads.ChunkLifetime())
+	fmt.Fprintln(w, "\nFetch:")
+	fmt.Fprintf(w, "\tEnabled:\t%t\n", set.Fetch.Enabled)
+	fmt.Fprintf(w, "\tMax size:\t%d\n", set.Fetch.MaxSize)
+	fmt.Fprintf(w, "\tAllowed networks:\t%s\n", strings.Join(set.Fetch.AllowNetworks, " "))
+	fmt.Fprintln(w, "\nArchives:")
+	fmt.Fprintf(w, "\tJobs:\t%d\n", set.Archives.RunningJobs())
+	fmt.Fprintf(w, "\tPer user:\t%d\n", set.Archives.PerUser)
+	fmt.Fprintf(w, "\tTTL:\t%dm\n", set.Archives.Lifetime())
+	fmt.Fprintf(w, "\tMin free:\t%d\n", set.Archives.MinFree)
 	fmt.Fprintln(w, "\nServer:")
 	fmt.Fprintf(w, "\tLog:\t%s\n", ser.Log)
 	fmt.Fprintf(w, "\tPort:\t%s\n", ser.Port)
@@ -137,6 +338,20 @@ func printSettings(ser *settings.Server, set *settings.Settings, auther auth.Aut
 	fmt.Fprintf(w, "\tAddress:\t%s\n", ser.Address)
 	fmt.Fprintf(w, "\tTLS Cert:\t%s\n", ser.TLSCert)
 	fmt.Fprintf(w, "\tTLS Key:\t%s\n", ser.TLSKey)
+	fmt.Fprintf(w, "\tRedirect status:\t%d\n", ser.RedirectStatus)
+	fmt.Fprintf(w, "\tTrusted proxies:\t%s\n", strings.Join(ser.TrustedProxies, " "))
+	fmt.Fprintf(w, "\tLanding path:\t%s\n", ser.LandingPath)
+	fmt.Fprintf(w, "\tStatic path:\t%s\n", ser.StaticPath)
+	fmt.Fprintf(w, "\tMetrics path:\t%s\n", ser.MetricsPath)
+	fmt.Fprintf(w, "\tMetrics clients:\t%s\n", strings.Join(ser.MetricsAllow, " "))
+	fmt.Fprintf(w, "\tStatus path:\t%s\n", ser.StatusPath)
+	fmt.Fprintf(w, "\tAccess log:\t%s\n", ser.AccessLog)
+	fmt.Fprintf(w, "\tImage cache:\t%s\n", ser.ImageCache)
+	fmt.Fprintf(w, "\tArchive spool:\t%s\n", ser.ArchiveSpool)
+	fmt.Fprintf(w, "\tUpload spool:\t%s\n", ser.UploadSpool)
+	fmt.Fprintf(w, "\tRead only:\t%t\n", ser.ReadOnly)
+	fmt.Fprintf(w, "\tCORS origins:\t%s\n", strings.Join(ser.CORSOrigins, " "))
+	fmt.Fprintf(w, "\tAlias roots:\t%s\n", strings.Join(ser.AliasRoots, " "))
 	fmt.Fprintln(w, "\nDefaults:")
 	fmt.Fprintf(w, "\tScope:\t%s\n", set.Defaults.Scope)
 	fmt.Fprintf(w, "\tLocale:\t%s\n", set.Defaults.Locale)
@@ -29,27 +29,121 @@ override the options.`,
 		authMethod, auther := getAuthentication(flags)
 
 		s := &settings.Settings{
-			Key:        generateKey(),
-			Signup:     mustGetBool(flags, "signup"),
-			Shell:      strings.Split(strings.TrimSpace(mustGetString(flags, "shell")), " "),
-			AuthMethod: authMethod,
-			Defaults:   defaults,
+			Key:                      generateKey(),
+			Signup:                   mustGetBool(flags, "signup"),
+			Shell:                    strings.Split(strings.TrimSpace(mustGetString(flags, "shell")), " "),
+			AuthMethod:               authMethod,
+			NormalizeNames:           mustGetBool(flags, "normalizeNames"),
+			CaseInsensitive:          mustGetBool(flags, "caseInsensitive"),
+			PlainTextCLI:             mustGetBool(flags, "plainTextCLI"),
+			NoIndex:                  mustGetStringSlice(flags, "noIndex"),
+			TrackChanges:             mustGetBool(flags, "trackChanges"),
+			DirTemplates:             mustGetBool(flags, "dirTemplates"),
+			GitStatus:                mustGetBool(flags, "gitStatus"),
+			DocMeta:                  mustGetBool(flags, "docMeta"),
+			DirOptions:               mustGetString(flags, "dirOptions"),
+			TrashDir:                 mustGetString(flags, "trashDir"),
+			ShowHidden:               mustGetBool(flags, "showHidden"),
+			DirsFirst:                mustGetBool(flags, "dirsFirst"),
+			DirSizes:                 mustGetBool(flags, "dirSizes"),
+			DirMode:                  mustGetString(flags, "dirMode"),
+			AllowSpecialBits:         mustGetBool(flags, "allowSpecialBits"),
+			MaxEditSize:              mustGetInt64(flags, "maxEditSize"),
+			RequireConditionalWrites: mustGetBool(flags, "requireConditionalWrites"),
+			MaxChecksumSize:          mustGetInt64(flags, "maxChecksumSize"),
+			SearchLimit:              mustGetInt(flags, "searchLimit"),
+			FeedLimit:                mustGetInt(flags, "feedLimit"),
+			ListingIndex:             mustGetString(flags, "listingIndex"),
+			Readmes:                  mustGetStringSlice(flags, "readmes"),
+			DateFormat:               mustGetString(flags, "dateFormat"),
+			Timezone:                 mustGetString(flags, "timezone"),
+			SortLocale:               mustGetString(flags, "sortLocale"),
+			DefaultLimit:             mustGetInt(flags, "defaultLimit"),
+			MaxLimit:                 mustGetInt(flags, "maxLimit"),
+			Symlinks:                 mustGetString(flags, "symlinks"),
+			Categories:               mustGetStringToString(flags, "categories"),
+			MimeTypes:                mustGetStringToString(flags, "mimeTypes"),
+			Webhooks:                 mustGetWebhooks(flags),
+			HookTimeout:              mustGetInt(flags, "hookTimeout"),
+			Defaults:                 defaults,
 			Branding: settings.Branding{
 				Name:            mustGetString(flags, "branding.name"),
 				DisableExternal: mustGetBool(flags, "branding.disableExternal"),
 				Files:           mustGetString(flags, "branding.files"),
+				Theme:           mustGetString(flags, "branding.theme"),
+				Title:           mustGetString(flags, "branding.title"),
+				Favicon:         mustGetString(flags, "branding.favicon"),
+				Styles:          mustGetStringSlice(flags, "branding.styles"),
+				Scripts:         mustGetStringSlice(flags, "branding.scripts"),
+				Listing:         mustGetString(flags, "branding.listing"),
+			},
+			Tree: settings.Tree{
+				MaxDepth: mustGetInt(flags, "tree.maxDepth"),
+				MaxNodes: mustGetInt(flags, "tree.maxNodes"),
+			},
+			ListingCache: settings.ListingCache{
+				TTL:        mustGetInt(flags, "listingCache.ttl"),
+				MaxEntries: mustGetInt(flags, "listingCache.maxEntries"),
+				MaxItems:   mustGetInt(flags, "listingCache.maxItems"),
+			},
+			Live: settings.Live{
+				Disabled:    mustGetBool(flags, "live.disabled"),
+				Connections: mustGetInt(flags, "live.connections"),
+				PerUser:     mustGetInt(flags, "live.perUser"),
+				Delay:       mustGetInt(flags, "live.delay"),
+			},
+			Slow: settings.Slow{
+				Listing: mustGetInt(flags, "slow.listing"),
+				Render:  mustGetInt(flags, "slow.render"),
+				Write:   mustGetInt(flags, "slow.write"),
+			},
+			Images: settings.Images{
+				Sizes:   mustGetIntSlice(flags, "images.sizes"),
+				Formats: mustGetStringSlice(flags, "images.formats"),
+				Thumbs:  mustGetIntSlice(flags, "images.thumbs"),
+			},
+			Uploads: settings.Uploads{
+				MaxSize:           mustGetInt64(flags, "uploads.maxSize"),
+				AllowedExtensions: mustGetStringSlice(flags, "uploads.allowedExtensions"),
+				BlockedExtensions: mustGetStringSlice(flags, "uploads.blockedExtensions"),
+				ChunkTTL:          mustGetInt(flags, "uploads.chunkTTL"),
+			},
+			Fetch: settings.Fetch{
+				Enabled:       mustGetBool(flags, "fetch.enabled"),
+				MaxSize:       mustGetInt64(flags, "fetch.maxSize"),
+				AllowNetworks: mustGetStringSlice(flags, "fetch.allowNetworks"),
+			},
+			Archives: settings.Archives{
+				Jobs:    mustGetInt(flags, "archives.jobs"),
+				PerUser: mustGetInt(flags, "archives.perUser"),
+				TTL:     mustGetInt(flags, "archives.ttl"),
+				MinFree: mustGetInt64(flags, "archives.minFree"),
 			},
 		}
 
 		ser := &settings.Server{
-			Address: mustGetString(flags, "address"),
-			Socket:  mustGetString(flags, "socket"),
-			Root:    mustGetString(flags, "root"),
-			BaseURL: mustGetString(flags, "baseurl"),
-			TLSKey:  mustGetString(flags, "key"),
-			TLSCert: mustGetString(flags, "cert"),
-			Port:    mustGetString(flags, "port"),
-			Log:     mustGetString(flags, "log"),
+			Address:        mustGetString(flags, "address"),
+			Socket:         mustGetString(flags, "socket"),
+			Root:           mustGetString(flags, "root"),
+			BaseURL:        mustGetString(flags, "baseurl"),
+			TLSKey:         mustGetString(flags, "key"),
+			TLSCert:        mustGetString(flags, "cert"),
+			Port:           mustGetString(flags, "port"),
+			Log:            mustGetString(flags, "log"),
+			RedirectStatus: mustGetInt(flags, "redirect"),
+			TrustedProxies: mustGetStringSlice(flags, "trustedProxies"),
+			LandingPath:    mustGetString(flags, "landingPath"),
+			StaticPath:     mustGetString(flags, "staticPath"),
+			MetricsPath:    mustGetString(flags, "metricsPath"),
+			MetricsAllow:   mustGetStringSlice(flags, "metricsAllow"),
+			StatusPath:     mustGetString(flags, "statusPath"),
+			AccessLog:      mustGetString(flags, "accessLog"),
+			ImageCache:     mustGetString(flags, "imageCache"),
+			ArchiveSpool:   mustGetString(flags, "archiveSpool"),
+			UploadSpool:    mustGetString(flags, "uploadSpool"),
+			ReadOnly:       mustGetBool(flags, "readOnly"),
+			CORSOrigins:    mustGetStringSlice(flags, "corsOrigins"),
+			AliasRoots:     mustGetStringSlice(flags, "aliasRoots"),
 		}
 
 		err := d.store.Settings.Save(s)
@@ -45,8 +45,100 @@ you want to change. Other options will remain unchanged.`,
 				ser.Port = mustGetString(flags, flag.Name)
 			case "log":
 				ser.Log = mustGetString(flags, flag.Name)
+			case "redirect":
+				ser.RedirectStatus = mustGetInt(flags, flag.Name)
+			case "trustedProxies":
+				ser.TrustedProxies = mustGetStringSlice(flags, flag.Name)
+			case "landingPath":
+				ser.LandingPath = mustGetString(flags, flag.Name)
+			case "staticPath":
+				ser.StaticPath = mustGetString(flags, flag.Name)
+			case "metricsPath":
+				ser.MetricsPath = mustGetString(flags, flag.Name)
+			case "metricsAllow":
+				ser.MetricsAllow = mustGetStringSlice(flags, flag.Name)
+			case "statusPath":
+				ser.StatusPath = mustGetString(flags, flag.Name)
+			case "accessLog":
+				ser.AccessLog = mustGetString(flags, flag.Name)
+			case "imageCache":
+				ser.ImageCache = mustGetString(flags, flag.Name)
+			case "archiveSpool":
+				ser.ArchiveSpool = mustGetString(flags, flag.Name)
+			case "uploadSpool":
+				ser.UploadSpool = mustGetString(flags, flag.Name)
+			case "readOnly":
+				ser.ReadOnly = mustGetBool(flags, flag.Name)
+			case "corsOrigins":
+				ser.CORSOrigins = mustGetStringSlice(flags, flag.Name)
+			case "aliasRoots":
+				ser.AliasRoots = mustGetStringSlice(flags, flag.Name)
 			case "signup":
 				set.Signup = mustGetBool(flags, flag.Name)
+			case "normalizeNames":
+				set.NormalizeNames = mustGetBool(flags, flag.Name)
+			case "caseInsensitive":
+				set.CaseInsensitive = mustGetBool(flags, flag.Name)
+			case "plainTextCLI":
+				set.PlainTextCLI = mustGetBool(flags, flag.Name)
+			case "noIndex":
+				set.NoIndex = mustGetStringSlice(flags, flag.Name)
+			case "trackChanges":
+				set.TrackChanges = mustGetBool(flags, flag.Name)
+			case "dirTemplates":
+				set.DirTemplates = mustGetBool(flags, flag.Name)
+			case "gitStatus":
+				set.GitStatus = mustGetBool(flags, flag.Name)
+			case "docMeta":
+				set.DocMeta = mustGetBool(flags, flag.Name)
+			case "dirOptions":
+				set.DirOptions = mustGetString(flags, flag.Name)
+			case "trashDir":
+				set.TrashDir = mustGetString(flags, flag.Name)
+			case "showHidden":
+				set.ShowHidden = mustGetBool(flags, flag.Name)
+			case "dirsFirst":
+				set.DirsFirst = mustGetBool(flags, flag.Name)
+			case "dirSizes":
+				set.DirSizes = mustGetBool(flags, flag.Name)
+			case "dirMode":
+				set.DirMode = mustGetString(flags, flag.Name)
+			case "allowSpecialBits":
+				set.AllowSpecialBits = mustGetBool(flags, flag.Name)
+			case "maxEditSize":
+				set.MaxEditSize = mustGetInt64(flags, flag.Name)
+			case "requireConditionalWrites":
+				set.RequireConditionalWrites = mustGetBool(flags, flag.Name)
+			case "maxChecksumSize":
+				set.MaxChecksumSize = mustGetInt64(flags, flag.Name)
+			case "searchLimit":
+				set.SearchLimit = mustGetInt(flags, flag.Name)
+			case "feedLimit":
+				set.FeedLimit = mustGetInt(flags, flag.Name)
+			case "listingIndex":
+				set.ListingIndex = mustGetString(flags, flag.Name)
+			case "readmes":
+				set.Readmes = mustGetStringSlice(flags, flag.Name)
+			case "dateFormat":
+				set.DateFormat = mustGetString(flags, flag.Name)
+			case "timezone":
+				set.Timezone = mustGetString(flags, flag.Name)
+			case "sortLocale":
+				set.SortLocale = mustGetString(flags, flag.Name)
+			case "defaultLimit":
+				set.DefaultLimit = mustGetInt(flags, flag.Name)
+			case "maxLimit":
+				set.MaxLimit = mustGetInt(flags, flag.Name)
+			case "symlinks":
+				set.Symlinks = mustGetString(flags, flag.Name)
+			case "categories":
+				set.Categories = mustGetStringToString(flags, flag.Name)
+			case "mimeTypes":
+				set.MimeTypes = mustGetStringToString(flags, flag.Name)
+			case "webhooks":
+				set.Webhooks = mustGetWebhooks(flags)
+			case "hookTimeout":
+				set.HookTimeout = mustGetInt(flags, flag.Name)
 			case "auth.method":
 				hasAuth = true
 			case "shell":
@@ -57,6 +149,70 @@ you want to change. Other options will remain unchanged.`,
 				set.Branding.DisableExternal = mustGetBool(flags, flag.Name)
 			case "branding.files":
 				set.Branding.Files = mustGetString(flags, flag.Name)
+			case "branding.theme":
+				set.Branding.Theme = mustGetString(flags, flag.Name)
+			case "branding.title":
+				set.Branding.Title = mustGetString(flags, flag.Name)
+			case "branding.favicon":
+				set.Branding.Favicon = mustGetString(flags, flag.Name)
+			case "branding.styles":
+				set.Branding.Styles = mustGetStringSlice(flags, flag.Name)
+			case "branding.scripts":
+				set.Branding.Scripts = mustGetStringSlice(flags, flag.Name)
+			case "branding.listing":
+				set.Branding.Listing = mustGetString(flags, flag.Name)
+			case "tree.maxDepth":
+				set.Tree.MaxDepth = mustGetInt(flags, flag.Name)
+			case "tree.maxNodes":
+				set.Tree.MaxNodes = mustGetInt(flags, flag.Name)
+			case "listingCache.ttl":
+				set.ListingCache.TTL = mustGetInt(flags, flag.Name)
+			case "listingCache.maxEntries":
+				set.ListingCache.MaxEntries = mustGetInt(flags, flag.Name)
+			case "listingCache.maxItems":
+				set.ListingCache.MaxItems = mustGetInt(flags, flag.Name)
+			case "live.disabled":
+				set.Live.Disabled = mustGetBool(flags, flag.Name)
+			case "live.connections":
+				set.Live.Connections = mustGetInt(flags, flag.Name)
+			case "live.perUser":
+				set.Live.PerUser = mustGetInt(flags, flag.Name)
+			case "live.delay":
+				set.Live.Delay = mustGetInt(flags, flag.Name)
+			case "slow.listing":
+				set.Slow.Listing = mustGetInt(flags, flag.Name)
+			case "slow.render":
+				set.Slow.Render = mustGetInt(flags, flag.Name)
+			case "slow.write":
+				set.Slow.Write = mustGetInt(flags, flag.Name)
+			case "uploads.maxSize":
+				set.Uploads.MaxSize = mustGetInt64(flags, flag.Name)
+			case "uploads.allowedExtensions":
+				set.Uploads.AllowedExtensions = mustGetStringSlice(flags, flag.Name)
+			case "uploads.blockedExtensions":
+				set.Uploads.BlockedExtensions = mustGetStringSlice(flags, flag.Name)
+			case "uploads.chunkTTL":
+				set.Uploads.ChunkTTL = mustGetInt(flags, flag.Name)
+			case "fetch.enabled":
+				set.Fetch.Enabled = mustGetBool(flags, flag.Name)
+			case "fetch.maxSize":
+				set.Fetch.MaxSize = mustGetInt64(flags, flag.Name)
+			case "fetch.allowNetworks":
+				set.Fetch.AllowNetworks = mustGetStringSlice(flags, flag.Name)
+			case "images.sizes":
+				set.Images.Sizes = mustGetIntSlice(flags, flag.Name)
+			case "images.formats":
+				set.Images.Formats = mustGetStringSlice(flags, flag.Name)
+			case "images.thumbs":
+				set.Images.Thumbs = mustGetIntSlice(flags, flag.Name)
+			case "archives.jobs":
+				set.Archives.Jobs = mustGetInt(flags, flag.Name)
+			case "archives.perUser":
+				set.Archives.PerUser = mustGetInt(flags, flag.Name)
+			case "archives.ttl":
+				set.Archives.TTL = mustGetInt(flags, flag.Name)
+			case "archives.minFree":
+				set.Archives.MinFree = mustGetInt64(flags, flag.Name)
 			}
 		})
 
@@ -69,6 +225,8 @@ you want to change. Other options will remain unchanged.`,
 		// check if there are new flags for existing auth method
 		set.AuthMethod, auther = getAuthentication(flags, hasAuth, set, auther)
 
+		checkErr(set.Validate())
+
 		err = d.store.Auth.Save(auther)
 		checkErr(err)
 		err = d.store.Settings.Save(set)
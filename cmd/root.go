@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"crypto/tls"
-	"errors"
+	nerrors "errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
@@ -10,11 +13,17 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/errors"
 	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/logging"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
 	"github.com/filebrowser/filebrowser/v2/users"
@@ -42,6 +51,8 @@ func init() {
 	flags.Bool("noauth", false, "use the noauth auther when using quick setup")
 	flags.String("username", "admin", "username for the first user when using quick config")
 	flags.String("password", "", "hashed password for the first user when using quick config (default \"admin\")")
+	flags.String("logLevel", "info", "least severe messages logged: debug, info, warn or error")
+	flags.String("logFormat", "text", "format of the log messages: text or json")
 
 	addServerFlags(flags)
 }
@@ -55,6 +66,20 @@ func addServerFlags(flags *pflag.FlagSet) {
 	flags.StringP("root", "r", ".", "root to prepend to relative paths")
 	flags.String("socket", "", "socket to listen to (cannot be used with address, port, cert nor key flags)")
 	flags.StringP("baseurl", "b", "", "base url")
+	flags.Int("redirect", http.StatusMovedPermanently, "status code of the redirects to add a trailing slash to directories (301, 307 or 308)")
+	flags.StringSlice("trustedProxies", nil, "IPs or CIDRs of the proxies whose X-Forwarded-* and X-Real-IP headers are trusted")
+	flags.String("landingPath", "", "path of the page that lists the roots the user can browse, such as / (off if empty)")
+	flags.String("staticPath", settings.DefaultStaticPath, "path of the static files, such as the scripts and styles of the frontend")
+	flags.String("metricsPath", "", "path of the metrics in the Prometheus text format, such as /metrics (off if empty)")
+	flags.StringSlice("metricsAllow", nil, "IPs or CIDRs of the clients allowed to get the metrics and the status (loopback only if empty)")
+	flags.String("statusPath", "", "path of the status of the handler in JSON, such as /status (off if empty)")
+	flags.String("accessLog", "", "access log output, such as stdout or a file (off if empty)")
+	flags.String("imageCache", "", "directory where the resized images are kept (not kept if empty)")
+	flags.String("archiveSpool", "", "directory where the archives are built in the background (streamed only if empty)")
+	flags.String("uploadSpool", "", "directory where the chunked uploads are kept until they're complete (off if empty)")
+	flags.Bool("readOnly", false, "refuse every request that would change the files, whatever the permissions")
+	flags.StringSlice("corsOrigins", nil, "origins of the sites whose pages can use the API, or * for any (none if empty)")
+	flags.StringSlice("aliasRoots", nil, "directories, along with the root, the targets of the aliases set through the API can be in")
 }
 
 var rootCmd = &cobra.Command{
@@ -80,6 +105,9 @@ If you don't set "config", it will look for a configuration file called
 - $HOME/
 - /etc/filebrowser/
 
+The options of the configuration file are the flags of this command,
+and File Browser refuses to start if it has other ones.
+
 The precedence of the configuration values are as follows:
 
 - flags
@@ -95,6 +123,11 @@ set FB_DATABASE.
 Also, if the database path doesn't exist, File Browser will enter into
 the quick setup mode and a new database will be bootstraped and a new
 user created with the credentials from options "username" and "password".`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		// Before the database is opened, so a bad configuration file
+		// doesn't leave an empty one behind.
+		checkErr(checkConfigFile(cmd.Flags()))
+	},
 	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
 		log.Println(cfgFile)
 
@@ -102,13 +135,22 @@ user created with the credentials from options "username" and "password".`,
 			quickSetup(cmd.Flags(), d)
 		}
 
-		server := getRunParams(cmd.Flags(), d.store)
+		server, err := getRunParams(cmd.Flags(), d.store)
+		checkErr(err)
 		setupLog(server.Log)
+		logger, err := getLogger(cmd.Flags())
+		checkErr(err)
 
 		root, err := filepath.Abs(server.Root)
 		checkErr(err)
 		server.Root = root
 
+		set, err := d.store.Settings.Get()
+		checkErr(err)
+		checkErr(set.Validate())
+		checkErr(server.Validate())
+		checkUserAliases(d.store, server.Root)
+
 		adr := server.Address + ":" + server.Port
 
 		var listener net.Listener
@@ -126,32 +168,144 @@ user created with the credentials from options "username" and "password".`,
 			checkErr(err)
 		}
 
-		sigc := make(chan os.Signal, 1)
-		signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
-		go cleanupHandler(listener, sigc)
+		opts := []fbhttp.Option{fbhttp.WithLogger(logger)}
+		if server.AccessLog != "" {
+			opts = append(opts, fbhttp.WithAccessLog(logWriter(server.AccessLog)))
+		}
 
-		handler, err := fbhttp.NewHandler(d.store, server)
+		handler, err := fbhttp.NewHandler(d.store, server, opts...)
 		checkErr(err)
 
-		defer listener.Close()
+		srv := &http.Server{Handler: handler}
+		done := make(chan struct{})
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+		go cleanupHandler(srv, handler, sigc, done)
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go reloadHandler(cmd.Flags(), d.store, handler, hup)
 
 		log.Println("Listening on", listener.Addr().String())
-		if err := http.Serve(listener, handler); err != nil {
+		if err := srv.Serve(listener); err != http.ErrServerClosed {
 			log.Fatal(err)
 		}
+		<-done
 	}, pythonConfig{allowNoDB: true}),
 }
 
-func cleanupHandler(listener net.Listener, c chan os.Signal) {
+// checkUserAliases warns about the aliases whose targets went missing
+// since they were set, which are not listed until they are back.
+func checkUserAliases(st *storage.Storage, root string) {
+	all, err := st.Users.Gets(root)
+	if err == errors.ErrNotExist {
+		return
+	}
+	checkErr(err)
+
+	for _, user := range all {
+		if err := users.CheckAliases(user.Aliases); err != nil {
+			log.Printf("User %s: %v", user.Username, err)
+		}
+	}
+}
+
+// shutdownTimeout is how long the requests being served and the queued
+// notifications of the webhooks are waited for when shutting down.
+const shutdownTimeout = 30 * time.Second
+
+// cleanupHandler stops the server on the first signal received on c. It
+// waits for the requests being served, then for the notifications of the
+// webhooks to be sent and the access log to be flushed, and closes done.
+func cleanupHandler(srv *http.Server, handler *fbhttp.Handler, c chan os.Signal, done chan struct{}) {
 	sig := <-c
 	log.Printf("Caught signal %s: shutting down.", sig)
-	listener.Close()
-	os.Exit(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Couldn't wait for the requests being served: %v", err)
+	}
+
+	if err := handler.Close(ctx); err != nil {
+		log.Printf("Couldn't send all the notifications of the webhooks or flush the access log: %v", err)
+	}
+
+	close(done)
 }
 
-func getRunParams(flags *pflag.FlagSet, st *storage.Storage) *settings.Server {
+// reloadHandler reloads the server settings from the configuration file,
+// the flags and the database on every signal received on c. The settings
+// that can't be reloaded are kept as they were.
+func reloadHandler(flags *pflag.FlagSet, st *storage.Storage, handler reloader, c chan os.Signal) {
+	for sig := range c {
+		log.Printf("Caught signal %s: reloading the server settings.", sig)
+
+		if err := reloadServer(flags, st, handler); err != nil {
+			log.Printf("Couldn't reload the server settings: %v", err)
+			continue
+		}
+
+		log.Println("Reloaded the server settings.")
+	}
+}
+
+// reloader is a handler whose server settings can be reloaded, such as
+// fbhttp.Handler.
+type reloader interface {
+	Server() settings.Server
+	Reload(server *settings.Server) error
+}
+
+// config guards viper, whose configuration file is read again by the
+// reloads while the server runs.
+var config sync.RWMutex
+
+// reloadServer reads the configuration file again and reloads the server
+// settings of handler from it, the flags and the database. On errors,
+// the handler keeps the settings it has.
+func reloadServer(flags *pflag.FlagSet, st *storage.Storage, handler reloader) error {
+	config.Lock()
+	err := v.ReadInConfig()
+	if _, ok := err.(v.ConfigParseError); !ok {
+		err = checkConfigFile(flags)
+	}
+	config.Unlock()
+	if err != nil {
+		return err
+	}
+
+	server, err := getRunParams(flags, st)
+	if err != nil {
+		return err
+	}
+
+	if server.Root, err = filepath.Abs(server.Root); err != nil {
+		return err
+	}
+
+	previous := handler.Server()
+	if server.Address != previous.Address || server.Port != previous.Port ||
+		server.Socket != previous.Socket || server.TLSKey != previous.TLSKey ||
+		server.TLSCert != previous.TLSCert || server.Log != previous.Log ||
+		server.AccessLog != previous.AccessLog {
+		log.Println("The address, port, socket, TLS, log and access log settings only change on restart.")
+	}
+
+	return handler.Reload(server)
+}
+
+// getRunParams returns the server settings of the database, overridden
+// by the ones of the flags, the environment and the configuration file.
+func getRunParams(flags *pflag.FlagSet, st *storage.Storage) (*settings.Server, error) {
+	config.RLock()
+	defer config.RUnlock()
+
 	server, err := st.Settings.GetServer()
-	checkErr(err)
+	if err != nil {
+		return nil, err
+	}
 
 	if val, set := getParamB(flags, "root"); set {
 		server.Root = val
@@ -165,6 +319,86 @@ func getRunParams(flags *pflag.FlagSet, st *storage.Storage) *settings.Server {
 		server.Log = val
 	}
 
+	if flags.Changed("redirect") {
+		if server.RedirectStatus, err = flags.GetInt("redirect"); err != nil {
+			return nil, err
+		}
+	} else if v.IsSet("redirect") {
+		server.RedirectStatus = v.GetInt("redirect")
+	}
+
+	if flags.Changed("trustedProxies") {
+		if server.TrustedProxies, err = flags.GetStringSlice("trustedProxies"); err != nil {
+			return nil, err
+		}
+	} else if v.IsSet("trustedProxies") {
+		server.TrustedProxies = v.GetStringSlice("trustedProxies")
+	}
+
+	if val, set := getParamB(flags, "landingPath"); set {
+		server.LandingPath = val
+	}
+
+	if val, set := getParamB(flags, "staticPath"); set {
+		server.StaticPath = val
+	}
+
+	if val, set := getParamB(flags, "metricsPath"); set {
+		server.MetricsPath = val
+	}
+
+	if flags.Changed("metricsAllow") {
+		if server.MetricsAllow, err = flags.GetStringSlice("metricsAllow"); err != nil {
+			return nil, err
+		}
+	} else if v.IsSet("metricsAllow") {
+		server.MetricsAllow = v.GetStringSlice("metricsAllow")
+	}
+
+	if val, set := getParamB(flags, "statusPath"); set {
+		server.StatusPath = val
+	}
+
+	if val, set := getParamB(flags, "accessLog"); set {
+		server.AccessLog = val
+	}
+
+	if val, set := getParamB(flags, "imageCache"); set {
+		server.ImageCache = val
+	}
+
+	if val, set := getParamB(flags, "archiveSpool"); set {
+		server.ArchiveSpool = val
+	}
+
+	if val, set := getParamB(flags, "uploadSpool"); set {
+		server.UploadSpool = val
+	}
+
+	if flags.Changed("readOnly") {
+		if server.ReadOnly, err = flags.GetBool("readOnly"); err != nil {
+			return nil, err
+		}
+	} else if v.IsSet("readOnly") {
+		server.ReadOnly = v.GetBool("readOnly")
+	}
+
+	if flags.Changed("corsOrigins") {
+		if server.CORSOrigins, err = flags.GetStringSlice("corsOrigins"); err != nil {
+			return nil, err
+		}
+	} else if v.IsSet("corsOrigins") {
+		server.CORSOrigins = v.GetStringSlice("corsOrigins")
+	}
+
+	if flags.Changed("aliasRoots") {
+		if server.AliasRoots, err = flags.GetStringSlice("aliasRoots"); err != nil {
+			return nil, err
+		}
+	} else if v.IsSet("aliasRoots") {
+		server.AliasRoots = v.GetStringSlice("aliasRoots")
+	}
+
 	isSocketSet := false
 	isAddrSet := false
 
@@ -194,7 +428,7 @@ func getRunParams(flags *pflag.FlagSet, st *storage.Storage) *settings.Server {
 	}
 
 	if isAddrSet && isSocketSet {
-		checkErr(errors.New("--socket flag cannot be used with --address, --port, --key nor --cert"))
+		return nil, nerrors.New("--socket flag cannot be used with --address, --port, --key nor --cert")
 	}
 
 	// Do not use saved Socket if address was manually set.
@@ -202,7 +436,7 @@ func getRunParams(flags *pflag.FlagSet, st *storage.Storage) *settings.Server {
 		server.Socket = ""
 	}
 
-	return server
+	return server, nil
 }
 
 // getParamB returns a parameter as a string and a boolean to tell if it is different from the default
@@ -235,45 +469,51 @@ func getParam(flags *pflag.FlagSet, key string) string {
 }
 
 func setupLog(logMethod string) {
+	log.SetOutput(logWriter(logMethod))
+}
+
+// logWriter returns the output of a log: stdout, stderr, nothing if it is
+// empty, or else a file rotated by size.
+func logWriter(logMethod string) io.Writer {
 	switch logMethod {
 	case "stdout":
-		log.SetOutput(os.Stdout)
+		return os.Stdout
 	case "stderr":
-		log.SetOutput(os.Stderr)
+		return os.Stderr
 	case "":
-		log.SetOutput(ioutil.Discard)
+		return ioutil.Discard
 	default:
-		log.SetOutput(&lumberjack.Logger{
+		return &lumberjack.Logger{
 			Filename:   logMethod,
 			MaxSize:    100,
 			MaxAge:     14,
 			MaxBackups: 10,
-		})
+		}
+	}
+}
+
+// getLogger returns the logger of the handler, which writes to the log
+// output in the format and from the level of the flags.
+func getLogger(flags *pflag.FlagSet) (logging.Logger, error) {
+	level, err := logging.ParseLevel(getParam(flags, "logLevel"))
+	if err != nil {
+		return nil, err
+	}
+
+	switch format := getParam(flags, "logFormat"); format {
+	case "text":
+		return logging.NewStd(nil, level), nil
+	case "json":
+		return logging.NewJSON(log.Writer(), level), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q: it must be text or json", format)
 	}
 }
 
 func quickSetup(flags *pflag.FlagSet, d pythonData) {
-	set := &settings.Settings{
-		Key:           generateKey(),
-		Signup:        false,
-		CreateUserDir: false,
-		Defaults: settings.UserDefaults{
-			Scope:  ".",
-			Locale: "en",
-			Perm: users.Permissions{
-				Admin:    false,
-				Execute:  true,
-				Create:   true,
-				Rename:   true,
-				Modify:   true,
-				Delete:   true,
-				Share:    true,
-				Download: true,
-			},
-		},
-	}
-
-	var err error
+	set, err := settings.New()
+	checkErr(err)
+
 	if _, noauth := getParamB(flags, "noauth"); noauth {
 		set.AuthMethod = auth.MethodNoAuth
 		err = d.store.Auth.Save(&auth.NoAuth{})
@@ -287,13 +527,27 @@ func quickSetup(flags *pflag.FlagSet, d pythonData) {
 	checkErr(err)
 
 	ser := &settings.Server{
-		BaseURL: getParam(flags, "baseurl"),
-		Port:    getParam(flags, "port"),
-		Log:     getParam(flags, "log"),
-		TLSKey:  getParam(flags, "key"),
-		TLSCert: getParam(flags, "cert"),
-		Address: getParam(flags, "address"),
-		Root:    getParam(flags, "root"),
+		BaseURL:        getParam(flags, "baseurl"),
+		Port:           getParam(flags, "port"),
+		Log:            getParam(flags, "log"),
+		TLSKey:         getParam(flags, "key"),
+		TLSCert:        getParam(flags, "cert"),
+		Address:        getParam(flags, "address"),
+		Root:           getParam(flags, "root"),
+		RedirectStatus: mustGetInt(flags, "redirect"),
+		TrustedProxies: mustGetStringSlice(flags, "trustedProxies"),
+		LandingPath:    getParam(flags, "landingPath"),
+		StaticPath:     getParam(flags, "staticPath"),
+		MetricsPath:    getParam(flags, "metricsPath"),
+		MetricsAllow:   mustGetStringSlice(flags, "metricsAllow"),
+		StatusPath:     getParam(flags, "statusPath"),
+		AccessLog:      getParam(flags, "accessLog"),
+		ImageCache:     getParam(flags, "imageCache"),
+		ArchiveSpool:   getParam(flags, "archiveSpool"),
+		UploadSpool:    getParam(flags, "uploadSpool"),
+		ReadOnly:       mustGetBool(flags, "readOnly"),
+		CORSOrigins:    mustGetStringSlice(flags, "corsOrigins"),
+		AliasRoots:     mustGetStringSlice(flags, "aliasRoots"),
 	}
 
 	err = d.store.Settings.SaveServer(ser)
@@ -350,3 +604,63 @@ func initConfig() {
 	}
 
 }
+
+// checkConfigFile checks that the options of the configuration file are
+// flags of the command, so misspelled ones don't go unnoticed, and that
+// their values have the type of the flag. The values themselves are
+// validated with the settings.
+func checkConfigFile(flags *pflag.FlagSet) error {
+	known := map[string]*pflag.Flag{}
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Name != "config" {
+			known[strings.ToLower(flag.Name)] = flag
+		}
+	})
+
+	keys := v.AllKeys()
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		flag, ok := known[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown option %q", key))
+			continue
+		}
+
+		if err := checkConfigValue(flag, key); err != nil {
+			problems = append(problems, fmt.Sprintf("option %q: %v", key, err))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid config file %s:\n  - %s", v.ConfigFileUsed(), strings.Join(problems, "\n  - "))
+}
+
+func checkConfigValue(flag *pflag.Flag, key string) error {
+	switch value := v.Get(key); value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}:
+		return nerrors.New("it must not be a table")
+	case []interface{}:
+		if flag.Value.Type() != "stringSlice" {
+			return nerrors.New("it must not be a list")
+		}
+		return nil
+	}
+
+	switch flag.Value.Type() {
+	case "int":
+		if _, err := strconv.Atoi(v.GetString(key)); err != nil {
+			return fmt.Errorf("%q is not an integer", v.GetString(key))
+		}
+	case "bool":
+		if _, err := strconv.ParseBool(v.GetString(key)); err != nil {
+			return fmt.Errorf("%q is not a boolean", v.GetString(key))
+		}
+	}
+
+	return nil
+}
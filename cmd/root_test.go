@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	v "github.com/spf13/viper"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// fakeHandler is a reloader that keeps the settings it's given.
+type fakeHandler struct {
+	mu      sync.Mutex
+	server  settings.Server
+	reloads int
+}
+
+func (h *fakeHandler) Server() settings.Server {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.server
+}
+
+func (h *fakeHandler) Reload(server *settings.Server) error {
+	if err := server.Validate(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.server = *server
+	h.reloads++
+	return nil
+}
+
+// withConfigFile makes viper read the configuration file at the returned
+// path, with content.
+func withConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "config.json")
+	writeConfig(t, file, content)
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(v.Reset)
+
+	return file
+}
+
+func writeConfig(t *testing.T, file, content string) {
+	t.Helper()
+
+	// The file is replaced, so the reloads never read half of it. Each
+	// write has its own temporary file, since the writes can be concurrent,
+	// and the errors don't stop the goroutines of the writes.
+	tmp, err := os.CreateTemp(filepath.Dir(file), "config-*.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = tmp.WriteString(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReloadServer(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string // the base URL after the reload, or "" if it fails
+	}{
+		{"reloaded", `{"baseurl": "/files"}`, "/files"},
+		{"invalid JSON", `{"baseurl": `, ""},
+		{"unknown option", `{"baseurl": "/files", "nope": true}`, ""},
+		{"socket and port", `{"socket": "/tmp/fb.sock", "port": "8081"}`, ""},
+		{"invalid settings", `{"baseurl": "files"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := withConfigFile(t, `{"baseurl": "/old"}`)
+			st := filebrowsertest.NewStorage()
+			if err := st.Settings.SaveServer(&settings.Server{Root: "/", Port: "8080"}); err != nil {
+				t.Fatal(err)
+			}
+
+			handler := &fakeHandler{server: settings.Server{Root: "/", Port: "8080", BaseURL: "/old"}}
+			writeConfig(t, file, tt.config)
+
+			err := reloadServer(rootCmd.Flags(), st, handler)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatal("reloadServer() succeeded")
+				}
+
+				if got := handler.Server(); got.BaseURL != "/old" || handler.reloads != 0 {
+					t.Errorf("the settings changed to %+v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := handler.Server().BaseURL; got != tt.want {
+				t.Errorf("BaseURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadServerConcurrent(t *testing.T) {
+	file := withConfigFile(t, `{"baseurl": "/a"}`)
+	st := filebrowsertest.NewStorage()
+	if err := st.Settings.SaveServer(&settings.Server{Root: "/", Port: "8080"}); err != nil {
+		t.Fatal(err)
+	}
+	handler := &fakeHandler{server: settings.Server{Root: "/", Port: "8080"}}
+
+	// The configuration file changes while it's reloaded and read.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				switch i % 3 {
+				case 0:
+					if j%2 == 0 {
+						writeConfig(t, file, `{"baseurl": "/a"}`)
+					} else {
+						writeConfig(t, file, `{"baseurl": "/b"}`)
+					}
+				case 1:
+					if err := reloadServer(rootCmd.Flags(), st, handler); err != nil {
+						t.Error(err)
+					}
+				default:
+					if _, err := getRunParams(rootCmd.Flags(), st); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := handler.Server().BaseURL; got != "/a" && got != "/b" {
+		t.Errorf("BaseURL = %q, want /a or /b", got)
+	}
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string // the problems reported, none if it's valid
+	}{
+		{"empty", `{}`, nil},
+		{"valid", `{"baseurl": "/files", "port": "8081", "readOnly": true, "redirect": 308}`, nil},
+		{"any case", `{"BaseURL": "/files", "READONLY": "true"}`, nil},
+		{"list", `{"trustedProxies": ["10.0.0.0/8", "192.0.2.1"]}`, nil},
+		{"comma separated list", `{"trustedProxies": "10.0.0.0/8,192.0.2.1"}`, nil},
+		{"unknown", `{"baseurl": "/files", "nope": true}`, []string{`unknown option "nope"`}},
+		{"misspelled", `{"basurl": "/files"}`, []string{`unknown option "basurl"`}},
+		{"config", `{"config": "other.json"}`, []string{`unknown option "config"`}},
+		{"table", `{"baseurl": {"path": "/files"}}`, []string{`unknown option "baseurl.path"`}},
+		{"integer", `{"redirect": "soon"}`, []string{`option "redirect": "soon" is not an integer`}},
+		{"boolean", `{"readOnly": "maybe"}`, []string{`option "readonly": "maybe" is not a boolean`}},
+		{"not a list", `{"port": ["8080", "8081"]}`, []string{`option "port": it must not be a list`}},
+		{"every problem", `{"nope": 1, "redirect": "soon", "readOnly": 2}`, []string{
+			`unknown option "nope"`,
+			`option "readonly": "2" is not a boolean`,
+			`option "redirect": "soon" is not an integer`,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfigFile(t, tt.config)
+
+			err := checkConfigFile(rootCmd.Flags())
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("checkConfigFile() = %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("checkConfigFile() succeeded")
+			}
+
+			if got := strings.Count(err.Error(), "\n  - "); got != len(tt.want) {
+				t.Errorf("checkConfigFile() reported %d problems, want %d: %v", got, len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), "\n  - "+want) {
+					t.Errorf("checkConfigFile() = %v, want %s", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFileServer(t *testing.T) {
+	withConfigFile(t, `{
+		"baseurl": "/files",
+		"port": "8081",
+		"readOnly": true,
+		"redirect": 308,
+		"trustedProxies": ["10.0.0.0/8", "192.0.2.1"]
+	}`)
+
+	st := filebrowsertest.NewStorage()
+	if err := st.Settings.SaveServer(&settings.Server{Root: "/", Port: "8080"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkConfigFile(rootCmd.Flags()); err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := getRunParams(rootCmd.Flags(), st)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server settings are the same as the ones set through the API.
+	want := &settings.Server{
+		Root:           "/",
+		BaseURL:        "/files",
+		Port:           "8081",
+		ReadOnly:       true,
+		RedirectStatus: 308,
+		TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1"},
+	}
+	if server.BaseURL != want.BaseURL || server.Port != want.Port || server.ReadOnly != want.ReadOnly ||
+		server.RedirectStatus != want.RedirectStatus || !reflect.DeepEqual(server.TrustedProxies, want.TrustedProxies) {
+		t.Errorf("getRunParams() = %+v, want %+v", server, want)
+	}
+
+	if err := server.Validate(); err != nil {
+		t.Errorf("the server settings are invalid: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/filebrowser/filebrowser/v2/rules"
 	"github.com/filebrowser/filebrowser/v2/settings"
@@ -24,7 +25,14 @@ var rulesCmd = &cobra.Command{
 "username" and "id". You must either set only one of them
 or none. If you set one of them, the command will apply to
 an user, otherwise it will be applied to the global set or
-rules.`,
+rules.
+
+When several rules match a path, the most specific one applies:
+the first regex or glob rule that matches and then the rule with
+the longest path. User rules always take precedence over the
+global ones. The rules with methods only apply to the requests
+with those methods, and the ones that apply to GET tell what can
+be seen: the paths they disallow aren't found nor listed.`,
 	Args: cobra.NoArgs,
 }
 
@@ -74,18 +82,24 @@ func printRules(rules []rules.Rule, id interface{}) {
 
 	for id, rule := range rules {
 		fmt.Printf("(%d) ", id)
-		if rule.Regex {
-			if rule.Allow {
-				fmt.Printf("Allow Regex: \t%s\n", rule.Regexp.Raw)
-			} else {
-				fmt.Printf("Disallow Regex: \t%s\n", rule.Regexp.Raw)
-			}
-		} else {
-			if rule.Allow {
-				fmt.Printf("Allow Path: \t%s\n", rule.Path)
-			} else {
-				fmt.Printf("Disallow Path: \t%s\n", rule.Path)
-			}
+
+		verb := "Disallow"
+		if rule.Allow {
+			verb = "Allow"
+		}
+
+		switch {
+		case rule.Regex:
+			fmt.Printf("%s Regex: \t%s", verb, rule.Regexp.Raw)
+		case rule.Glob:
+			fmt.Printf("%s Glob: \t%s", verb, rule.Path)
+		default:
+			fmt.Printf("%s Path: \t%s", verb, rule.Path)
+		}
+
+		if len(rule.Methods) > 0 {
+			fmt.Printf(" \t(%s)", strings.Join(rule.Methods, ", "))
 		}
+		fmt.Println()
 	}
 }
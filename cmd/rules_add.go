@@ -1,7 +1,8 @@
 package cmd
 
 import (
-	"regexp"
+	"errors"
+	"strings"
 
 	"github.com/filebrowser/filebrowser/v2/rules"
 	"github.com/filebrowser/filebrowser/v2/settings"
@@ -13,6 +14,8 @@ func init() {
 	rulesCmd.AddCommand(rulesAddCmd)
 	rulesAddCmd.Flags().BoolP("allow", "a", false, "indicates this is an allow rule")
 	rulesAddCmd.Flags().BoolP("regex", "r", false, "indicates this is a regex rule")
+	rulesAddCmd.Flags().BoolP("glob", "g", false, "indicates this is a glob rule, where ** matches any number of directories")
+	rulesAddCmd.Flags().StringSlice("methods", nil, "methods of the requests the rule applies to, such as PUT,DELETE (all if empty)")
 }
 
 var rulesAddCmd = &cobra.Command{
@@ -23,15 +26,21 @@ var rulesAddCmd = &cobra.Command{
 	Run: python(func(cmd *cobra.Command, args []string, d pythonData) {
 		allow := mustGetBool(cmd.Flags(), "allow")
 		regex := mustGetBool(cmd.Flags(), "regex")
+		glob := mustGetBool(cmd.Flags(), "glob")
 		exp := args[0]
 
-		if regex {
-			regexp.MustCompile(exp)
+		if regex && glob {
+			checkErr(errors.New("a rule can't be both a regex and a glob"))
 		}
 
 		rule := rules.Rule{
 			Allow: allow,
 			Regex: regex,
+			Glob:  glob,
+		}
+
+		for _, method := range mustGetStringSlice(cmd.Flags(), "methods") {
+			rule.Methods = append(rule.Methods, strings.ToUpper(method))
 		}
 
 		if regex {
@@ -40,6 +49,8 @@ var rulesAddCmd = &cobra.Command{
 			rule.Path = exp
 		}
 
+		checkErr(rules.Check([]rules.Rule{rule}))
+
 		user := func(u *users.User) {
 			u.Rules = append(u.Rules, rule)
 			err := d.store.Users.Save(u)
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/filebrowser/filebrowser/v2/settings"
@@ -67,13 +69,49 @@ func addUserFlags(flags *pflag.FlagSet) {
 	flags.Bool("perm.delete", true, "delete perm for users")
 	flags.Bool("perm.share", true, "share perm for users")
 	flags.Bool("perm.download", true, "download perm for users")
-	flags.String("sorting.by", "name", "sorting mode (name, size or modified)")
+	flags.String("sorting.by", "name", "sorting mode (name, size, modified or type)")
 	flags.Bool("sorting.asc", false, "sorting by ascending order")
 	flags.Bool("lockPassword", false, "lock password")
 	flags.StringSlice("commands", nil, "a list of the commands a user can execute")
-	flags.String("scope", ".", "scope for users")
+	flags.String("scope", ".", "scope for users (a path or an sftp://user@host/path URL)")
 	flags.String("locale", "en", "locale for users")
 	flags.String("viewMode", string(users.ListViewMode), "view mode for users")
+	flags.String("title", "", "prefix of the titles of the HTML listings of the scope of the user")
+	flags.String("favicon", "", "icon of the HTML listings of the scope of the user")
+	flags.String("scopeBranding.name", "", "name heading the HTML listings of the scope of the user")
+	flags.String("scopeBranding.logo", "", "URL of the logo heading the HTML listings of the scope of the user")
+	flags.String("scopeBranding.color", "", "color of the links of the HTML listings of the scope of the user")
+	flags.String("scopeBranding.cssFile", "", "stylesheet written in the HTML listings of the scope of the user (a path of the scope, or of the disk if absolute)")
+	flags.String("scopeBranding.jsFile", "", "script written in the HTML listings of the scope of the user (a path of the scope, or of the disk if absolute)")
+	flags.Bool("scopeBranding.hideCredits", false, "hide the credits of the HTML listings of the scope of the user")
+	flags.StringToString("variables", nil, "variables of the HTML listing templates of the scope of the user, such as team=docs")
+	flags.StringSlice("exclude", nil, "paths of the scope hidden from the user, with everything under them")
+	flags.StringSlice("ignore", nil, "glob patterns of the names, or of the paths from the root of the scope with a leading slash, hidden from the user (a trailing slash only matches directories)")
+	flags.StringToString("aliases", nil, "directories of the scope of the user served from other roots, such as /shared=/mnt/nas/shared")
+	flags.StringSlice("readOnlyAliases", nil, "paths of the aliases that can't be written")
+	flags.StringSlice("privateAliases", nil, "paths of the aliases left out of the landing page")
+	flags.StringSlice("transfers", nil, `areas of the scope the user can move files between, such as /incoming:/archive, where "/" is the rest of the scope`)
+	flags.String("machineFormats", "", "deny the listings in any format but HTML, WebDAV and the API-only routes to the user, serving the HTML listings instead (html) or failing (reject)")
+}
+
+// getBranding updates branding with the branding flags that were set.
+func getBranding(flags *pflag.FlagSet, branding *users.Branding) {
+	flags.Visit(func(flag *pflag.Flag) {
+		switch flag.Name {
+		case "scopeBranding.name":
+			branding.Name = mustGetString(flags, flag.Name)
+		case "scopeBranding.logo":
+			branding.Logo = mustGetString(flags, flag.Name)
+		case "scopeBranding.color":
+			branding.Color = mustGetString(flags, flag.Name)
+		case "scopeBranding.cssFile":
+			branding.CSSFile = mustGetString(flags, flag.Name)
+		case "scopeBranding.jsFile":
+			branding.JSFile = mustGetString(flags, flag.Name)
+		case "scopeBranding.hideCredits":
+			branding.HideCredits = mustGetBool(flags, flag.Name)
+		}
+	})
 }
 
 func getViewMode(flags *pflag.FlagSet) users.ViewMode {
@@ -84,6 +122,72 @@ func getViewMode(flags *pflag.FlagSet) users.ViewMode {
 	return viewMode
 }
 
+func getMachineFormats(flags *pflag.FlagSet) string {
+	mode := mustGetString(flags, "machineFormats")
+	if mode != "" && mode != users.MachineFormatsHTML && mode != users.MachineFormatsReject {
+		checkErr(errors.New("machine formats must be empty, \"" + users.MachineFormatsHTML + "\" or \"" + users.MachineFormatsReject + "\""))
+	}
+	return mode
+}
+
+func getAliases(flags *pflag.FlagSet) []users.Alias {
+	targets := mustGetStringToString(flags, "aliases")
+
+	paths := make([]string, 0, len(targets))
+	for p := range targets {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	aliases := make([]users.Alias, 0, len(paths))
+	for _, p := range paths {
+		aliases = append(aliases, users.Alias{Path: p, Target: targets[p]})
+	}
+
+	markAliases(flags, aliases)
+	return aliases
+}
+
+// getTransfers returns the transfers of the transfers flag, which are
+// pairs of areas separated by a colon.
+func getTransfers(flags *pflag.FlagSet) []users.Transfer {
+	pairs := mustGetStringSlice(flags, "transfers")
+	transfers := make([]users.Transfer, 0, len(pairs))
+	for _, pair := range pairs {
+		areas := strings.SplitN(pair, ":", 2)
+		if len(areas) != 2 {
+			checkErr(fmt.Errorf("invalid transfer %q: it must be from:to", pair))
+		}
+
+		transfers = append(transfers, users.Transfer{From: areas[0], To: areas[1]})
+	}
+
+	return transfers
+}
+
+// markAliases marks the aliases listed by the readOnlyAliases and
+// privateAliases flags, when they are set.
+func markAliases(flags *pflag.FlagSet, aliases []users.Alias) {
+	mark := func(name string, set func(alias *users.Alias, marked bool)) {
+		if !flags.Changed(name) {
+			return
+		}
+
+		paths := mustGetStringSlice(flags, name)
+		for i := range aliases {
+			marked := false
+			for _, p := range paths {
+				marked = marked || p == aliases[i].Path
+			}
+
+			set(&aliases[i], marked)
+		}
+	}
+
+	mark("readOnlyAliases", func(alias *users.Alias, marked bool) { alias.ReadOnly = marked })
+	mark("privateAliases", func(alias *users.Alias, marked bool) { alias.Private = marked })
+}
+
 func getUserDefaults(flags *pflag.FlagSet, defaults *settings.UserDefaults, all bool) {
 	visit := func(flag *pflag.Flag) {
 		switch flag.Name {
@@ -24,11 +24,20 @@ var usersAddCmd = &cobra.Command{
 		checkErr(err)
 
 		user := &users.User{
-			Username:     args[0],
-			Password:     password,
-			LockPassword: mustGetBool(cmd.Flags(), "lockPassword"),
+			Username:       args[0],
+			Password:       password,
+			LockPassword:   mustGetBool(cmd.Flags(), "lockPassword"),
+			Title:          mustGetString(cmd.Flags(), "title"),
+			Favicon:        mustGetString(cmd.Flags(), "favicon"),
+			MachineFormats: getMachineFormats(cmd.Flags()),
+			Exclude:        mustGetStringSlice(cmd.Flags(), "exclude"),
+			Ignore:         mustGetStringSlice(cmd.Flags(), "ignore"),
+			Variables:      mustGetStringToString(cmd.Flags(), "variables"),
+			Aliases:        getAliases(cmd.Flags()),
+			Transfers:      getTransfers(cmd.Flags()),
 		}
 
+		getBranding(cmd.Flags(), &user.Branding)
 		s.Defaults.Apply(user)
 
 		servSettings, err := d.store.Settings.GetServer()
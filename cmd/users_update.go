@@ -56,6 +56,42 @@ options you want to change.`,
 		user.Sorting = defaults.Sorting
 		user.LockPassword = mustGetBool(flags, "lockPassword")
 
+		if flags.Changed("title") {
+			user.Title = mustGetString(flags, "title")
+		}
+
+		if flags.Changed("favicon") {
+			user.Favicon = mustGetString(flags, "favicon")
+		}
+
+		getBranding(flags, &user.Branding)
+
+		if flags.Changed("machineFormats") {
+			user.MachineFormats = getMachineFormats(flags)
+		}
+
+		if flags.Changed("exclude") {
+			user.Exclude = mustGetStringSlice(flags, "exclude")
+		}
+
+		if flags.Changed("ignore") {
+			user.Ignore = mustGetStringSlice(flags, "ignore")
+		}
+
+		if flags.Changed("aliases") {
+			user.Aliases = getAliases(flags)
+		} else {
+			markAliases(flags, user.Aliases)
+		}
+
+		if flags.Changed("transfers") {
+			user.Transfers = getTransfers(flags)
+		}
+
+		if flags.Changed("variables") {
+			user.Variables = mustGetStringToString(flags, "variables")
+		}
+
 		if newUsername != "" {
 			user.Username = newUsername
 		}
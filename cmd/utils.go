@@ -35,6 +35,36 @@ func mustGetBool(flags *pflag.FlagSet, flag string) bool {
 	return b
 }
 
+func mustGetInt(flags *pflag.FlagSet, flag string) int {
+	b, err := flags.GetInt(flag)
+	checkErr(err)
+	return b
+}
+
+func mustGetInt64(flags *pflag.FlagSet, flag string) int64 {
+	i, err := flags.GetInt64(flag)
+	checkErr(err)
+	return i
+}
+
+func mustGetIntSlice(flags *pflag.FlagSet, flag string) []int {
+	s, err := flags.GetIntSlice(flag)
+	checkErr(err)
+	return s
+}
+
+func mustGetStringSlice(flags *pflag.FlagSet, flag string) []string {
+	s, err := flags.GetStringSlice(flag)
+	checkErr(err)
+	return s
+}
+
+func mustGetStringToString(flags *pflag.FlagSet, flag string) map[string]string {
+	m, err := flags.GetStringToString(flag)
+	checkErr(err)
+	return m
+}
+
 func mustGetUint(flags *pflag.FlagSet, flag string) uint {
 	b, err := flags.GetUint(flag)
 	checkErr(err)
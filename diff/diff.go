@@ -0,0 +1,397 @@
+// Package diff compares texts line by line with the algorithm of Myers,
+// in linear space, and writes the differences as unified diffs.
+package diff
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The operations of the lines of the hunks.
+const (
+	OpEqual  = " "
+	OpDelete = "-"
+	OpInsert = "+"
+)
+
+// Line is a line of a hunk. Text has the line ending of the line, if it
+// has one, so the lines only differing by it, such as with CRLF and LF,
+// are told apart, and so is the last line without a newline.
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Hunk is a group of changed lines with the unchanged lines around them.
+// The starts are the numbers, from one, of the first lines of the hunk in
+// the old and the new texts, or the numbers of the lines before the hunk
+// when it has no lines of a text.
+type Hunk struct {
+	OldStart int    `json:"oldStart"`
+	OldLines int    `json:"oldLines"`
+	NewStart int    `json:"newStart"`
+	NewLines int    `json:"newLines"`
+	Lines    []Line `json:"lines"`
+}
+
+// Split splits a text in lines, keeping their line endings.
+func Split(s string) []string {
+	lines := []string{}
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+
+	return lines
+}
+
+// Diff returns the hunks of the differences between the old and the new
+// texts, with up to around unchanged lines around the changes. The
+// comparison gives up on the parts left when ctx is done, which are then
+// reported as completely replaced. Identical texts have no hunks.
+func Diff(ctx context.Context, oldText, newText string, around int) []Hunk {
+	a, b := Split(oldText), Split(newText)
+
+	// The lines are compared as numbers.
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+
+	d := &differ{ctx: ctx}
+	d.compare(intern(a), intern(b))
+	return hunks(deletesFirst(d.ops), a, b, around)
+}
+
+// deletesFirst puts the deleted lines of each change before the inserted
+// ones, as the diffs usually show them.
+func deletesFirst(ops []string) []string {
+	for i := 0; i < len(ops); {
+		if ops[i] == OpEqual {
+			i++
+			continue
+		}
+
+		end, deletes := i, 0
+		for end < len(ops) && ops[end] != OpEqual {
+			if ops[end] == OpDelete {
+				deletes++
+			}
+			end++
+		}
+
+		for j := i; j < end; j++ {
+			if j < i+deletes {
+				ops[j] = OpDelete
+			} else {
+				ops[j] = OpInsert
+			}
+		}
+		i = end
+	}
+
+	return ops
+}
+
+// differ computes the operations turning a text into another.
+type differ struct {
+	ctx context.Context
+	ops []string
+}
+
+func (d *differ) add(op string, n int) {
+	for i := 0; i < n; i++ {
+		d.ops = append(d.ops, op)
+	}
+}
+
+// compare adds the operations turning a into b.
+func (d *differ) compare(a, b []int) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	d.add(OpEqual, prefix)
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-suffix-1] == b[len(b)-suffix-1] {
+		suffix++
+	}
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	switch {
+	case len(a) == 0:
+		d.add(OpInsert, len(b))
+	case len(b) == 0:
+		d.add(OpDelete, len(a))
+	case disjoint(a, b):
+		// The texts with nothing in common are the slowest to bisect.
+		d.add(OpDelete, len(a))
+		d.add(OpInsert, len(b))
+	default:
+		d.bisect(a, b)
+	}
+
+	d.add(OpEqual, suffix)
+}
+
+// disjoint checks if a and b have no lines in common.
+func disjoint(a, b []int) bool {
+	lines := make(map[int]struct{}, len(a))
+	for _, line := range a {
+		lines[line] = struct{}{}
+	}
+
+	for _, line := range b {
+		if _, ok := lines[line]; ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bisect finds the middle snake of the shortest edit script turning a
+// into b, walking it from both ends, and compares the parts before and
+// after it. a and b have neither a common prefix nor a common suffix.
+func (d *differ) bisect(a, b []int) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	size := 2*maxD + 1
+	v1 := make([]int, size)
+	v2 := make([]int, size)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[offset+1] = 0
+	v2[offset+1] = 0
+
+	delta := n - m
+	// The paths can only meet going forward when delta is odd.
+	front := delta%2 != 0
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+
+	for step := 0; step < maxD; step++ {
+		if d.ctx != nil && d.ctx.Err() != nil {
+			break
+		}
+
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < size && v2[k2Offset] != -1 && x1 >= n-v2[k2Offset] {
+					d.split(a, b, x1, y1)
+					return
+				}
+			}
+		}
+
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < size && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := offset + x1 - k1Offset
+					if x1 >= n-x2 {
+						d.split(a, b, x1, y1)
+						return
+					}
+				}
+			}
+		}
+	}
+
+	// Given up: everything is replaced.
+	d.add(OpDelete, n)
+	d.add(OpInsert, m)
+}
+
+func (d *differ) split(a, b []int, x, y int) {
+	d.compare(a[:x], b[:y])
+	d.compare(a[x:], b[y:])
+}
+
+// hunks groups the operations turning a into b in hunks.
+func hunks(ops []string, a, b []string, around int) []Hunk {
+	if around < 0 {
+		around = 0
+	}
+
+	// The positions in a and b before each operation.
+	type pos struct{ a, b int }
+	positions := make([]pos, len(ops)+1)
+	for i, op := range ops {
+		p := positions[i]
+		switch op {
+		case OpEqual:
+			p.a++
+			p.b++
+		case OpDelete:
+			p.a++
+		case OpInsert:
+			p.b++
+		}
+		positions[i+1] = p
+	}
+
+	result := []Hunk{}
+	for i := 0; i < len(ops); {
+		for i < len(ops) && ops[i] == OpEqual {
+			i++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		start := i - around
+		if start < 0 {
+			start = 0
+		}
+
+		// The hunk goes on while the next change is close enough to
+		// share the lines around them.
+		end := i
+		for end < len(ops) {
+			if ops[end] != OpEqual {
+				end++
+				continue
+			}
+
+			next := end
+			for next < len(ops) && ops[next] == OpEqual {
+				next++
+			}
+			if next == len(ops) || next-end > 2*around {
+				break
+			}
+			end = next
+		}
+
+		stop := end + around
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		hunk := Hunk{
+			OldStart: positions[start].a,
+			NewStart: positions[start].b,
+			OldLines: positions[stop].a - positions[start].a,
+			NewLines: positions[stop].b - positions[start].b,
+		}
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+
+		for j := start; j < stop; j++ {
+			switch ops[j] {
+			case OpInsert:
+				hunk.Lines = append(hunk.Lines, Line{Op: OpInsert, Text: b[positions[j].b]})
+			default:
+				hunk.Lines = append(hunk.Lines, Line{Op: ops[j], Text: a[positions[j].a]})
+			}
+		}
+
+		result = append(result, hunk)
+		i = stop
+	}
+
+	return result
+}
+
+// WriteUnified writes the hunks as a unified diff of the files with the
+// old and the new names.
+func WriteUnified(w io.Writer, oldName, newName string, hunks []Hunk) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "--- %s\n+++ %s\n", oldName, newName)
+
+	for _, hunk := range hunks {
+		fmt.Fprintln(bw, hunk.Header())
+		for _, line := range hunk.Lines {
+			bw.WriteString(line.Op)
+			bw.WriteString(line.Text)
+			if !strings.HasSuffix(line.Text, "\n") {
+				bw.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Header returns the header line of the hunk in the unified diffs, such
+// as "@@ -1,3 +1,4 @@".
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, lines)
+}
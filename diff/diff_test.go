@@ -0,0 +1,223 @@
+package diff
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{}},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a\n"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"a\r\nb\n", []string{"a\r\n", "b\n"}},
+		{"\n\n", []string{"\n", "\n"}},
+	}
+
+	for _, tt := range tests {
+		if got := Split(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWriteUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"empty", "", "", ""},
+		{"added", "", "a\nb\n", "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"removed", "a\nb\n", "", "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"changed", "a\nb\nc\n", "a\nx\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"inserted first", "b\nc\n", "a\nb\nc\n", "@@ -1 +1,2 @@\n+a\n b\n"},
+		{"appended", "a\n", "a\nb\n", "@@ -1 +1,2 @@\n a\n+b\n"},
+		{
+			"no newline in the old text",
+			"a\nb", "a\nb\n",
+			"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			"no newline in the new text",
+			"a\nb\n", "a\nb",
+			"@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+		},
+		{
+			"no newline in both",
+			"a\nb", "a\nc",
+			"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+		{"no newline and identical", "a\nb", "a\nb", ""},
+		{
+			"CRLF and LF",
+			"a\r\nb\r\n", "a\r\nb\n",
+			"@@ -1,2 +1,2 @@\n a\r\n-b\r\n+b\n",
+		},
+		{
+			"LF to CRLF",
+			"a\nb\n", "a\r\nb\r\n",
+			"@@ -1,2 +1,2 @@\n-a\n-b\n+a\r\n+b\r\n",
+		},
+		{
+			"changes far apart",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			"@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -9,2 +9,2 @@\n 9\n-10\n+y\n",
+		},
+		{
+			"changes close together",
+			"1\n2\n3\n4\n", "x\n2\n3\ny\n",
+			"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n-4\n+y\n",
+		},
+		{
+			"moved line",
+			"a\nb\nc\nd\n", "b\nc\nd\na\n",
+			"@@ -1,2 +1 @@\n-a\n b\n@@ -4 +3,2 @@\n d\n+a\n",
+		},
+		{
+			"repeated lines",
+			"a\na\na\n", "a\na\n",
+			"@@ -2,2 +2 @@\n a\n-a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteUnified(&b, "old", "new", Diff(context.Background(), tt.old, tt.new, 1)); err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := b.String(), "--- old\n+++ new\n"+tt.want; got != want {
+				t.Errorf("the diff is\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// lcs returns the length of the longest common subsequence of a and b.
+func lcs(a, b []string) int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] > lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	return lengths[0][0]
+}
+
+// apply returns the old and the new texts of the hunks of a diff of the
+// whole texts.
+func apply(hunks []Hunk) (oldText, newText string) {
+	var o, n strings.Builder
+	for _, hunk := range hunks {
+		for _, line := range hunk.Lines {
+			if line.Op != OpInsert {
+				o.WriteString(line.Text)
+			}
+			if line.Op != OpDelete {
+				n.WriteString(line.Text)
+			}
+		}
+	}
+
+	return o.String(), n.String()
+}
+
+func randomText(r *rand.Rand) string {
+	lines := []string{"a\n", "b\n", "c\n", "d\r\n", "e"}
+	var b strings.Builder
+	for i := r.Intn(40); i > 0; i-- {
+		b.WriteString(lines[r.Intn(len(lines)-1)])
+	}
+	if r.Intn(2) == 0 {
+		b.WriteString(lines[len(lines)-1])
+	}
+
+	return b.String()
+}
+
+func TestDiffIsMinimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		oldText, newText := randomText(r), randomText(r)
+		// All the lines are around the changes, so there is one hunk with
+		// both texts, or none if they are identical.
+		hunks := Diff(context.Background(), oldText, newText, 100)
+
+		if oldText == newText {
+			if len(hunks) != 0 {
+				t.Fatalf("the identical texts %q have %d hunks", oldText, len(hunks))
+			}
+			continue
+		}
+		if len(hunks) != 1 {
+			t.Fatalf("the diff of %q and %q has %d hunks, want 1", oldText, newText, len(hunks))
+		}
+
+		if gotOld, gotNew := apply(hunks); gotOld != oldText || gotNew != newText {
+			t.Fatalf("the diff of %q and %q gives %q and %q", oldText, newText, gotOld, gotNew)
+		}
+
+		a, b := Split(oldText), Split(newText)
+		changes := 0
+		for _, line := range hunks[0].Lines {
+			if line.Op != OpEqual {
+				changes++
+			}
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); changes != want {
+			t.Fatalf("the diff of %q and %q has %d changed lines, want %d", oldText, newText, changes, want)
+		}
+	}
+}
+
+func TestDiffCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// What is left when the comparison gives up is replaced, so the diff
+	// still turns a text into the other.
+	oldText, newText := "a\nb\nc\nd\ne\n", "a\nc\nb\ne\nf\n"
+	hunks := Diff(ctx, oldText, newText, 100)
+	if gotOld, gotNew := apply(hunks); gotOld != oldText || gotNew != newText {
+		t.Errorf("the diff gives %q and %q", gotOld, gotNew)
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tests := []struct {
+		hunk Hunk
+		want string
+	}{
+		{Hunk{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 4}, "@@ -1,3 +1,4 @@"},
+		{Hunk{OldStart: 5, OldLines: 1, NewStart: 6, NewLines: 1}, "@@ -5 +6 @@"},
+		{Hunk{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 2}, "@@ -0,0 +1,2 @@"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.hunk.Header(); got != tt.want {
+			t.Errorf("Header() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package disk
+
+import (
+	"sync"
+	"time"
+)
+
+// cacheTTL is how long the usage of a path is cached. Probing network
+// mounts can be slow so it isn't done on every request.
+const cacheTTL = 5 * time.Second
+
+// Usage describes the space of the filesystem that hosts a path.
+type Usage struct {
+	Free  uint64
+	Used  uint64
+	Total uint64
+}
+
+type cached struct {
+	usage   Usage
+	expires time.Time
+}
+
+var cache = struct {
+	sync.Mutex
+	m map[string]cached
+}{m: map[string]cached{}}
+
+// UsageOf returns the usage of the filesystem that hosts path. The
+// results are cached for a few seconds.
+func UsageOf(path string) (Usage, error) {
+	now := time.Now()
+
+	cache.Lock()
+	c, ok := cache.m[path]
+	cache.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.usage, nil
+	}
+
+	usage, err := usageOf(path)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	cache.Lock()
+	for p, c := range cache.m {
+		if now.After(c.expires) {
+			delete(cache.m, p)
+		}
+	}
+	cache.m[path] = cached{usage: usage, expires: now.Add(cacheTTL)}
+	cache.Unlock()
+
+	return usage, nil
+}
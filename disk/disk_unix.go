@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package disk
+
+import "syscall"
+
+func usageOf(path string) (Usage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return Usage{}, err
+	}
+
+	bsize := uint64(stat.Bsize)
+	total := uint64(stat.Blocks) * bsize
+	free := uint64(stat.Bavail) * bsize
+
+	return Usage{
+		Free:  free,
+		Used:  total - uint64(stat.Bfree)*bsize,
+		Total: total,
+	}, nil
+}
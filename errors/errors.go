@@ -14,4 +14,9 @@ var (
 	ErrIsDirectory       = errors.New("file is directory")
 	ErrInvalidOption     = errors.New("invalid option")
 	ErrInvalidAuthMethod = errors.New("invalid auth method")
+	ErrTooLarge          = errors.New("file is too large")
+	ErrSpecialFile       = errors.New("file is a pipe, a socket or a device")
+	ErrSymlink           = errors.New("file is a symbolic link")
+	ErrStale             = errors.New("the file changed")
+	ErrUnconditional     = errors.New("the file can only be replaced with an If-Match or an If-Unmodified-Since")
 )
@@ -0,0 +1,152 @@
+// Package events publishes the operations on the files to the programs
+// that embed File Browser and to its webhooks. The publishers never wait
+// for the subscribers: each of them has a buffer, and when it is full the
+// oldest event in it is dropped for the new one.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event is an operation on the files that succeeded. Its Type is one of
+// the events of the settings, such as settings.EventUpload. It is also
+// the payload sent to the webhooks.
+type Event struct {
+	Type string `json:"event"`
+	Path string `json:"path"`
+	// Destination is the new path of the renamed and copied files.
+	Destination string `json:"destination,omitempty"`
+	// Size is the size of the uploaded, renamed and copied files.
+	Size  int64     `json:"size"`
+	User  string    `json:"user"`
+	Scope string    `json:"scope"`
+	Time  time.Time `json:"timestamp"`
+}
+
+// Buffer is the number of events a subscriber can fall behind by before
+// its oldest events are dropped.
+const Buffer = 256
+
+// Bus delivers the events published on it to its subscribers. The zero
+// value is ready to use.
+type Bus struct {
+	// dropped comes first to be aligned for the atomic operations.
+	dropped uint64
+
+	mu     sync.RWMutex
+	subs   map[*subscriber]bool
+	closed bool
+}
+
+type subscriber struct {
+	types   map[string]bool
+	ch      chan Event
+	dropped *uint64
+	// mu makes dropping the oldest event and sending the new one atomic
+	// when the events are published concurrently.
+	mu sync.Mutex
+}
+
+func (s *subscriber) wants(event string) bool {
+	return len(s.types) == 0 || s.types[event]
+}
+
+// send delivers e without blocking, dropping the oldest event if the
+// buffer is full.
+func (s *subscriber) send(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		select {
+		case s.ch <- e:
+			return
+		default:
+		}
+
+		select {
+		case <-s.ch:
+			atomic.AddUint64(s.dropped, 1)
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel with the events of the given types, or of
+// all of them if there are none, and the function that unsubscribes from
+// them and closes the channel. The channel is also closed when the bus
+// is. If the events aren't read fast enough, the oldest ones are dropped.
+func (b *Bus) Subscribe(types ...string) (<-chan Event, func()) {
+	s := &subscriber{ch: make(chan Event, Buffer), dropped: &b.dropped}
+	if len(types) > 0 {
+		s.types = map[string]bool{}
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(s.ch)
+		return s.ch, func() {}
+	}
+
+	if b.subs == nil {
+		b.subs = map[*subscriber]bool{}
+	}
+	b.subs[s] = true
+
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() { b.unsubscribe(s) })
+	}
+}
+
+// unsubscribe removes s and closes its channel. The publishers hold the
+// read lock while they send, so none of them sends on it once it is
+// closed.
+func (b *Bus) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs[s] {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
+
+// Publish delivers e to the subscribers that want it. It never blocks on
+// them.
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.subs {
+		if s.wants(e.Type) {
+			s.send(e)
+		}
+	}
+}
+
+// Dropped returns the number of events dropped because the subscribers
+// fell behind, since the bus was created.
+func (b *Bus) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Close unsubscribes all the subscribers and closes their channels. The
+// events published after it are dropped.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
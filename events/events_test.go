@@ -0,0 +1,193 @@
+package events
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// drain returns the paths of the events left in ch, which must be closed
+// or hold no more than them.
+func drain(ch <-chan Event) []string {
+	var paths []string
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return paths
+			}
+			paths = append(paths, e.Path)
+		default:
+			return paths
+		}
+	}
+}
+
+func TestBusTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []string
+		want  string // the paths of the events received
+	}{
+		{"all", nil, "/a /b /c"},
+		{"one type", []string{"upload"}, "/a /c"},
+		{"two types", []string{"upload", "delete"}, "/a /b /c"},
+		{"other type", []string{"rename"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Bus
+			ch, unsubscribe := b.Subscribe(tt.types...)
+			defer unsubscribe()
+
+			b.Publish(Event{Type: "upload", Path: "/a"})
+			b.Publish(Event{Type: "delete", Path: "/b"})
+			b.Publish(Event{Type: "upload", Path: "/c"})
+
+			if got := strings.Join(drain(ch), " "); got != tt.want {
+				t.Errorf("received %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusDropsOldest(t *testing.T) {
+	tests := []struct {
+		name      string
+		published int
+		dropped   int
+	}{
+		{"under the buffer", Buffer - 1, 0},
+		{"the buffer", Buffer, 0},
+		{"one over", Buffer + 1, 1},
+		{"many over", 3*Buffer + 7, 2*Buffer + 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Bus
+			ch, unsubscribe := b.Subscribe()
+			defer unsubscribe()
+
+			for i := 0; i < tt.published; i++ {
+				b.Publish(Event{Path: strconv.Itoa(i)})
+			}
+
+			// The newest events are kept, in the order they were
+			// published.
+			paths := drain(ch)
+			if len(paths) != tt.published-tt.dropped {
+				t.Fatalf("received %d events, want %d", len(paths), tt.published-tt.dropped)
+			}
+			for i, p := range paths {
+				if want := strconv.Itoa(tt.dropped + i); p != want {
+					t.Fatalf("the event %d is %s, want %s", i, p, want)
+				}
+			}
+
+			if got := b.Dropped(); got != uint64(tt.dropped) {
+				t.Errorf("Dropped() = %d, want %d", got, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestBusClose(t *testing.T) {
+	var b Bus
+	ch, unsubscribe := b.Subscribe()
+	b.Publish(Event{Path: "/a"})
+	b.Close()
+
+	// The events sent before are still read, then the channel is closed.
+	if e, ok := <-ch; !ok || e.Path != "/a" {
+		t.Errorf("received %+v, %v, want /a", e, ok)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("the channel isn't closed")
+	}
+
+	// Nothing is sent on the closed channels, and they aren't closed
+	// again.
+	b.Publish(Event{Path: "/b"})
+	unsubscribe()
+	unsubscribe()
+
+	late, unsubscribeLate := b.Subscribe()
+	defer unsubscribeLate()
+	if _, ok := <-late; ok {
+		t.Error("the channel of a subscription to a closed bus isn't closed")
+	}
+}
+
+func TestBusUnsubscribe(t *testing.T) {
+	var b Bus
+	ch, unsubscribe := b.Subscribe()
+	other, unsubscribeOther := b.Subscribe()
+	defer unsubscribeOther()
+
+	unsubscribe()
+	unsubscribe()
+	b.Publish(Event{Path: "/a"})
+
+	if _, ok := <-ch; ok {
+		t.Error("the channel isn't closed")
+	}
+	if got := strings.Join(drain(other), " "); got != "/a" {
+		t.Errorf("the other subscriber received %q, want /a", got)
+	}
+}
+
+// TestBusConcurrent subscribes and unsubscribes while the events are
+// published, and closes the bus while they still are, which must neither
+// race nor send on a closed channel. It is meant to be run with -race.
+func TestBusConcurrent(t *testing.T) {
+	const (
+		publishers  = 4
+		events      = 2000
+		subscribers = 4
+		rounds      = 50
+	)
+
+	var b Bus
+	var wg sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := 0; n < events; n++ {
+				b.Publish(Event{Type: "upload", Path: strconv.Itoa(i*events + n)})
+			}
+		}(i)
+	}
+
+	var subs sync.WaitGroup
+	for i := 0; i < subscribers; i++ {
+		subs.Add(1)
+		go func(i int) {
+			defer subs.Done()
+			for r := 0; r < rounds; r++ {
+				ch, unsubscribe := b.Subscribe("upload")
+				// Some subscribers read, the others fall behind.
+				if i%2 == 0 {
+					drain(ch)
+				}
+				unsubscribe()
+
+				for range ch {
+				}
+			}
+		}(i)
+	}
+
+	// One subscriber stays until the bus is closed, which may be while
+	// the events are still published.
+	last, _ := b.Subscribe()
+	subs.Wait()
+	b.Close()
+	wg.Wait()
+
+	for range last {
+	}
+}
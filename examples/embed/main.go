@@ -0,0 +1,103 @@
+// Command embed serves a directory with the File Browser handler mounted
+// in a plain net/http server, without authentication. It is a starting
+// point for embedding File Browser in other Go services:
+//
+//	go get github.com/filebrowser/filebrowser/v2/examples/embed
+//	embed -root /srv/files -address 127.0.0.1:8080
+//
+// The handler serves the frontend from the rice boxes, so it must be
+// built with them like the filebrowser command.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/asdine/storm"
+
+	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/errors"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/logging"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/storage/bolt"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func main() {
+	root := flag.String("root", ".", "directory to serve")
+	database := flag.String("database", "embed.db", "database path")
+	address := flag.String("address", "127.0.0.1:8080", "address to listen on")
+	baseURL := flag.String("baseurl", "/files", "path the files are served under")
+	readOnly := flag.Bool("readonly", false, "only let the files be downloaded and shared")
+	trace := flag.Bool("trace", false, "log the spans of the requests with a traceparent header")
+	flag.Parse()
+
+	db, err := storm.Open(*database)
+	checkErr(err)
+	defer db.Close()
+
+	store, err := bolt.NewStorage(db)
+	checkErr(err)
+
+	if _, err := store.Settings.Get(); err == errors.ErrNotExist {
+		setup(store, *readOnly)
+	} else {
+		checkErr(err)
+	}
+
+	dir, err := filepath.Abs(*root)
+	checkErr(err)
+
+	opts := []fbhttp.Option{fbhttp.WithLogger(logging.NewStd(nil, logging.LevelInfo))}
+	if *trace {
+		opts = append(opts, fbhttp.WithTracer(logTracer{}))
+	}
+
+	handler, err := fbhttp.NewHandler(store, &settings.Server{
+		Root:    dir,
+		BaseURL: *baseURL,
+	}, opts...)
+	checkErr(err)
+
+	mux := http.NewServeMux()
+	mux.Handle(*baseURL+"/", handler)
+	mux.Handle("/", http.RedirectHandler(*baseURL+"/", http.StatusFound))
+
+	log.Printf("Serving %s on http://%s%s/", dir, *address, *baseURL)
+	log.Fatal(http.ListenAndServe(*address, mux))
+}
+
+// setup saves the settings of a new database and the user all the
+// requests are made as, since there is no authentication. The read only
+// setting only applies to new databases.
+func setup(store *storage.Storage, readOnly bool) {
+	var opts []settings.Option
+	if readOnly {
+		opts = append(opts, settings.WithReadOnly())
+	}
+
+	set, err := settings.New(opts...)
+	checkErr(err)
+	set.AuthMethod = auth.MethodNoAuth
+
+	checkErr(store.Auth.Save(&auth.NoAuth{}))
+	checkErr(store.Settings.Save(set))
+
+	user := &users.User{Username: "admin"}
+	user.Password, err = users.HashPwd("admin")
+	checkErr(err)
+
+	set.Defaults.Apply(user)
+	user.Perm.Admin = !readOnly
+	checkErr(store.Users.Save(user))
+}
+
+func checkErr(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
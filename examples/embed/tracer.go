@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/tracing"
+)
+
+// logTracer logs the spans of the requests with a W3C traceparent header
+// when they end. It shows how a tracer is given to the handler: an
+// OpenTelemetry one is wired the same way, with an adapter described in
+// the documentation of the tracing package.
+type logTracer struct{}
+
+type (
+	traceKey struct{}
+	spanKey  struct{}
+)
+
+// Extract keeps the trace ID of the traceparent header, which is made of
+// a version, a trace ID, the ID of the parent span and flags.
+func (logTracer) Extract(ctx context.Context, header http.Header) (context.Context, bool) {
+	parts := strings.Split(header.Get("traceparent"), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx, false
+	}
+
+	return context.WithValue(ctx, traceKey{}, parts[1]), true
+}
+
+func (logTracer) Start(ctx context.Context, name string) (context.Context, tracing.Span) {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	span := &logSpan{
+		trace: fmt.Sprint(ctx.Value(traceKey{})),
+		id:    hex.EncodeToString(id),
+		name:  name,
+		start: time.Now(),
+	}
+
+	if parent, ok := ctx.Value(spanKey{}).(*logSpan); ok {
+		span.parent = parent.id
+	}
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type logSpan struct {
+	trace, id, parent string
+	name              string
+	start             time.Time
+	attrs             []string
+}
+
+func (s *logSpan) SetString(key, value string) {
+	s.attrs = append(s.attrs, fmt.Sprintf("%s=%q", key, value))
+}
+
+func (s *logSpan) SetInt(key string, value int64) {
+	s.attrs = append(s.attrs, fmt.Sprintf("%s=%d", key, value))
+}
+
+func (s *logSpan) SetBool(key string, value bool) {
+	s.attrs = append(s.attrs, fmt.Sprintf("%s=%t", key, value))
+}
+
+func (s *logSpan) End() {
+	log.Printf("span %s trace=%s id=%s parent=%s duration=%s %s",
+		s.name, s.trace, s.id, s.parent, time.Since(s.start), strings.Join(s.attrs, " "))
+}
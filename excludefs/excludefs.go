@@ -0,0 +1,293 @@
+// Package excludefs hides some paths of an afero.Fs, and the entries
+// that match some patterns, as if they didn't exist.
+package excludefs
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Fs is an afero.Fs that hides the excluded paths and everything under
+// them. They are left out of the directories and reading them fails as
+// if they didn't exist, while writing them or moving or removing the
+// directories above them is not permitted.
+//
+// The paths match whole segments, so excluding /files/int doesn't hide
+// /files/internal, and they are compared in NFC. On Windows and macOS,
+// whose filesystems find the files regardless of case, they are also
+// compared regardless of case, so /Files/Int is hidden too.
+//
+// The entries that match the ignore patterns are hidden the same way,
+// with everything under them. Moving or removing a directory walks it to
+// find the ignored entries under it.
+type Fs struct {
+	source   afero.Fs
+	excluded []string
+	patterns []pattern
+}
+
+// New creates a new Fs on top of source that hides the excluded paths
+// and the entries that match the ignore patterns. The invalid patterns,
+// which CheckPatterns reports, are left out.
+func New(source afero.Fs, excluded, ignored []string) *Fs {
+	fs := &Fs{source: source}
+	for _, p := range excluded {
+		if p = clean(p); p != "/" {
+			fs.excluded = append(fs.excluded, p)
+		}
+	}
+
+	for _, raw := range ignored {
+		if p, err := parsePattern(raw); err == nil {
+			fs.patterns = append(fs.patterns, p)
+		}
+	}
+
+	return fs
+}
+
+// Source returns the underlying Fs.
+func (fs *Fs) Source() afero.Fs {
+	return fs.source
+}
+
+// WithContext binds the underlying Fs to ctx if it supports it.
+func (fs *Fs) WithContext(ctx context.Context) afero.Fs {
+	if cfs, ok := fs.source.(interface {
+		WithContext(ctx context.Context) afero.Fs
+	}); ok {
+		return &Fs{source: cfs.WithContext(ctx), excluded: fs.excluded, patterns: fs.patterns}
+	}
+
+	return fs
+}
+
+// foldCase is set on the systems whose filesystems find the files
+// regardless of case by default.
+const foldCase = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+func clean(name string) string {
+	name = norm.NFC.String(path.Clean("/" + filepath.ToSlash(name)))
+	if foldCase {
+		name = strings.ToLower(name)
+	}
+
+	return name
+}
+
+// Excluded checks if name is one of the excluded paths or is under one,
+// or if it or a directory above it is ignored.
+func (fs *Fs) Excluded(name string) bool {
+	cleaned := clean(name)
+	for _, p := range fs.excluded {
+		if cleaned == p || strings.HasPrefix(cleaned, p+"/") {
+			return true
+		}
+	}
+
+	return fs.ignored(name)
+}
+
+// contains checks if an excluded path or an ignored entry is under name,
+// which can't be moved or removed without it.
+func (fs *Fs) contains(name string) bool {
+	cleaned := clean(name)
+	for _, p := range fs.excluded {
+		if cleaned == "/" || strings.HasPrefix(p, cleaned+"/") {
+			return true
+		}
+	}
+
+	return fs.ignoresBelow(name)
+}
+
+func notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func denied(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+// Name implements afero.Fs.
+func (fs *Fs) Name() string {
+	return "excludefs"
+}
+
+// Create implements afero.Fs.
+func (fs *Fs) Create(name string) (afero.File, error) {
+	if fs.Excluded(name) {
+		return nil, denied("create", name)
+	}
+
+	return fs.source.Create(name)
+}
+
+// Mkdir implements afero.Fs.
+func (fs *Fs) Mkdir(name string, perm os.FileMode) error {
+	if fs.Excluded(name) {
+		return denied("mkdir", name)
+	}
+
+	return fs.source.Mkdir(name, perm)
+}
+
+// MkdirAll implements afero.Fs.
+func (fs *Fs) MkdirAll(name string, perm os.FileMode) error {
+	if fs.Excluded(name) {
+		return denied("mkdir", name)
+	}
+
+	return fs.source.MkdirAll(name, perm)
+}
+
+// Open implements afero.Fs.
+func (fs *Fs) Open(name string) (afero.File, error) {
+	if fs.Excluded(name) {
+		return nil, notExist("open", name)
+	}
+
+	f, err := fs.source.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs, dir: name}, nil
+}
+
+// OpenFile implements afero.Fs.
+func (fs *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if fs.Excluded(name) {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+			return nil, denied("open", name)
+		}
+		return nil, notExist("open", name)
+	}
+
+	f, err := fs.source.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f, fs: fs, dir: name}, nil
+}
+
+// Remove implements afero.Fs.
+func (fs *Fs) Remove(name string) error {
+	if fs.Excluded(name) {
+		return notExist("remove", name)
+	}
+
+	return fs.source.Remove(name)
+}
+
+// RemoveAll implements afero.Fs.
+func (fs *Fs) RemoveAll(name string) error {
+	if fs.Excluded(name) {
+		return notExist("removeall", name)
+	}
+
+	if fs.contains(name) {
+		return denied("removeall", name)
+	}
+
+	return fs.source.RemoveAll(name)
+}
+
+// Rename implements afero.Fs.
+func (fs *Fs) Rename(oldname, newname string) error {
+	if fs.Excluded(oldname) {
+		return notExist("rename", oldname)
+	}
+
+	if fs.Excluded(newname) || fs.contains(oldname) {
+		return denied("rename", oldname)
+	}
+
+	return fs.source.Rename(oldname, newname)
+}
+
+// Stat implements afero.Fs.
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	if fs.Excluded(name) {
+		return nil, notExist("stat", name)
+	}
+
+	return fs.source.Stat(name)
+}
+
+// LstatIfPossible implements afero.Lstater.
+func (fs *Fs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if fs.Excluded(name) {
+		return nil, false, notExist("lstat", name)
+	}
+
+	if lstater, ok := fs.source.(afero.Lstater); ok {
+		return lstater.LstatIfPossible(name)
+	}
+
+	info, err := fs.source.Stat(name)
+	return info, false, err
+}
+
+// Chmod implements afero.Fs.
+func (fs *Fs) Chmod(name string, mode os.FileMode) error {
+	if fs.Excluded(name) {
+		return notExist("chmod", name)
+	}
+
+	return fs.source.Chmod(name, mode)
+}
+
+// Chtimes implements afero.Fs.
+func (fs *Fs) Chtimes(name string, atime, mtime time.Time) error {
+	if fs.Excluded(name) {
+		return notExist("chtimes", name)
+	}
+
+	return fs.source.Chtimes(name, atime, mtime)
+}
+
+// file leaves the excluded entries out of the directories.
+type file struct {
+	afero.File
+	fs  *Fs
+	dir string
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	for {
+		infos, err := f.File.Readdir(count)
+
+		kept := infos[:0]
+		for _, info := range infos {
+			if !f.fs.Excluded(path.Join(f.dir, info.Name())) {
+				kept = append(kept, info)
+			}
+		}
+
+		// Reading a few entries only returns none at the end, so the
+		// excluded ones are replaced by the next entries.
+		if len(kept) > 0 || len(infos) == 0 || count <= 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+func (f *file) Readdirnames(count int) ([]string, error) {
+	infos, err := f.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+
+	return names, err
+}
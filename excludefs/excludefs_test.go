@@ -0,0 +1,255 @@
+package excludefs
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// newSource returns a filesystem with files, by path. The paths ending
+// with a slash are directories.
+func newSource(t *testing.T, names ...string) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	for _, name := range names {
+		var err error
+		if strings.HasSuffix(name, "/") {
+			err = fs.MkdirAll(name, 0755)
+		} else {
+			err = afero.WriteFile(fs, name, []byte(name), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fs
+}
+
+func readDir(t *testing.T, fs afero.Fs, dir string) string {
+	t.Helper()
+
+	infos, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, " ")
+}
+
+func TestExcluded(t *testing.T) {
+	fs := New(afero.NewMemMapFs(), []string{"/files/int", "secret/", "/caf\u00e9"}, nil)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"/files/int", true},
+		{"/files/int/", true},
+		{"/files/int/a.txt", true},
+		{"files/int/deep/a.txt", true},
+		{"/files/./int", true},
+		{"/files/x/../int", true},
+		{"/files/internal2", false},
+		{"/files/in", false},
+		{"/files", false},
+		{"/", false},
+		{"/secret", true},
+		{"/secret/a", true},
+		{"/secrets", false},
+		{"/other/secret", false},
+		{"/cafe\u0301", true}, // the same name in NFD
+		{"/cafe\u0301/menu", true},
+		{"/cafe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fs.Excluded(tt.name); got != tt.want {
+				t.Errorf("Excluded(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludedRoot(t *testing.T) {
+	fs := New(newSource(t, "/a.txt"), []string{"/", ""}, nil)
+	if fs.Excluded("/a.txt") {
+		t.Error("excluding the root hides the scope")
+	}
+}
+
+func TestReaddir(t *testing.T) {
+	source := newSource(t, "/files/int/a.txt", "/files/internal2/b.txt", "/files/c.txt", "/files/d.txt")
+	fs := New(source, []string{"/files/int"}, nil)
+
+	if got, want := readDir(t, fs, "/files"), "c.txt d.txt internal2"; got != want {
+		t.Errorf("the listing is %q, want %q", got, want)
+	}
+
+	// The entries are read one at a time, so the excluded one is replaced
+	// by the next one.
+	dir, err := fs.Open("/files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dir.Close()
+
+	var names []string
+	for {
+		infos, err := dir.Readdir(1)
+		if len(infos) == 0 || err != nil {
+			break
+		}
+		names = append(names, infos[0].Name())
+	}
+	sort.Strings(names)
+
+	if got, want := strings.Join(names, " "), "c.txt d.txt internal2"; got != want {
+		t.Errorf("the entries read one by one are %q, want %q", got, want)
+	}
+}
+
+func TestOperations(t *testing.T) {
+	tests := []struct {
+		name string
+		op   func(fs afero.Fs) error
+		want func(err error) bool
+	}{
+		{"stat", func(fs afero.Fs) error {
+			_, err := fs.Stat("/files/int/a.txt")
+			return err
+		}, os.IsNotExist},
+		{"open", func(fs afero.Fs) error {
+			_, err := fs.Open("/files/int")
+			return err
+		}, os.IsNotExist},
+		{"create", func(fs afero.Fs) error {
+			_, err := fs.Create("/files/int/new.txt")
+			return err
+		}, os.IsPermission},
+		{"mkdir", func(fs afero.Fs) error {
+			return fs.Mkdir("/files/int", 0755)
+		}, os.IsPermission},
+		{"rename onto", func(fs afero.Fs) error {
+			return fs.Rename("/files/c.txt", "/files/int/c.txt")
+		}, os.IsPermission},
+		{"rename above", func(fs afero.Fs) error {
+			return fs.Rename("/files", "/moved")
+		}, os.IsPermission},
+		{"remove above", func(fs afero.Fs) error {
+			return fs.RemoveAll("/files")
+		}, os.IsPermission},
+		{"remove", func(fs afero.Fs) error {
+			return fs.RemoveAll("/files/int")
+		}, os.IsNotExist},
+		{"remove next to it", func(fs afero.Fs) error {
+			return fs.RemoveAll("/files/internal2")
+		}, func(err error) bool { return err == nil }},
+		{"chmod", func(fs afero.Fs) error {
+			return fs.Chmod("/files/int/a.txt", 0600)
+		}, os.IsNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newSource(t, "/files/int/a.txt", "/files/internal2/b.txt", "/files/c.txt")
+			if err := tt.op(New(source, []string{"/files/int"}, nil)); !tt.want(err) {
+				t.Errorf("the operation returned %v", err)
+			}
+
+			if _, err := source.Stat("/files/int/a.txt"); err != nil {
+				t.Errorf("the excluded file was changed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckPatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string // the reason it's invalid, if it is
+	}{
+		{"*.bak", ""},
+		{"secret-*.pem", ""},
+		{"node_modules/", ""},
+		{"/build/*.log", ""},
+		{"/build/", ""},
+		{"[ab]*.tmp", ""},
+		{"", "it matches nothing"},
+		{"/", "it matches nothing"},
+		{"build/*.log", "the patterns of paths must start with a slash"},
+		{"[", "syntax error in pattern"},
+		{"/build/[a-", "syntax error in pattern"},
+	}
+
+	for _, tt := range tests {
+		err := CheckPatterns([]string{"*.tmp", tt.pattern})
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("CheckPatterns(%q) = %v", tt.pattern, err)
+			}
+			continue
+		}
+
+		perr, ok := err.(*PatternError)
+		if !ok || perr.Pattern != tt.pattern || perr.Reason != tt.want {
+			t.Errorf("CheckPatterns(%q) = %v, want %q", tt.pattern, err, tt.want)
+		}
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	source := newSource(t,
+		"/a.bak", "/A.BAK", "/secret-1.pem", "/secret.pem",
+		"/node_modules/", "/node_modules/x.js", "/src/node_modules/",
+		"/web/node_modules",
+		"/build/a.log", "/build/sub/b.log", "/src/build/c.log",
+	)
+	fs := New(source, nil, []string{"*.bak", "secret-*.pem", "node_modules/", "/build/*.log", "[invalid"})
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"/a.bak", true},
+		{"/A.BAK", foldCase},
+		{"/dir/deep/a.bak", true},
+		{"/a.bak.txt", false},
+		{"/secret-1.pem", true},
+		{"/secret.pem", false},
+		{"/node_modules", true},
+		{"/node_modules/x.js", true},
+		{"/src/node_modules", true},
+		// Only the directories match the patterns with a trailing slash.
+		{"/web/node_modules", false},
+		{"/build/a.log", true},
+		{"/build/sub/b.log", false},
+		{"/src/build/c.log", false},
+		{"/", false},
+	}
+
+	for _, tt := range tests {
+		if got := fs.Excluded(tt.name); got != tt.want {
+			t.Errorf("Excluded(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	want := "A.BAK build secret.pem src web"
+	if foldCase {
+		want = "build secret.pem src web"
+	}
+	if got := readDir(t, fs, "/"); got != want {
+		t.Errorf("the root has %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package excludefs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/unicode/norm"
+)
+
+// pattern is a glob of the ignored entries, with the syntax of
+// path.Match. It matches the names of the entries, or their paths from
+// the root when it starts with a slash, and only the directories when it
+// ends with one.
+type pattern struct {
+	glob    string
+	rooted  bool
+	dirOnly bool
+}
+
+// PatternError describes why an ignore pattern is invalid.
+type PatternError struct {
+	Pattern string
+	Reason  string
+}
+
+func (e *PatternError) Error() string {
+	return fmt.Sprintf("invalid ignore pattern %q: %s", e.Pattern, e.Reason)
+}
+
+func parsePattern(raw string) (pattern, error) {
+	p := pattern{glob: norm.NFC.String(filepath.ToSlash(raw))}
+	if strings.HasSuffix(p.glob, "/") {
+		p.dirOnly = true
+		p.glob = strings.TrimSuffix(p.glob, "/")
+	}
+
+	if strings.HasPrefix(p.glob, "/") {
+		p.rooted = true
+		p.glob = path.Clean(p.glob)
+	}
+
+	switch {
+	case p.glob == "" || p.glob == "/":
+		return p, &PatternError{Pattern: raw, Reason: "it matches nothing"}
+	case !p.rooted && strings.Contains(p.glob, "/"):
+		return p, &PatternError{Pattern: raw, Reason: "the patterns of paths must start with a slash"}
+	}
+
+	if _, err := path.Match(p.glob, ""); err != nil {
+		return p, &PatternError{Pattern: raw, Reason: err.Error()}
+	}
+
+	if foldCase {
+		p.glob = strings.ToLower(p.glob)
+	}
+
+	return p, nil
+}
+
+// CheckPatterns checks the ignore patterns, so the invalid ones are
+// reported when they are set rather than left out when the Fs is made.
+func CheckPatterns(patterns []string) error {
+	for _, raw := range patterns {
+		if _, err := parsePattern(raw); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ignored checks if an ignore pattern matches name or a directory above
+// it. The entries above name are directories, since they have name under
+// them, so only name itself is stated for the patterns of directories.
+func (fs *Fs) ignored(name string) bool {
+	cleaned := clean(name)
+	if len(fs.patterns) == 0 || cleaned == "/" {
+		return false
+	}
+
+	elems := strings.Split(cleaned[1:], "/")
+	for i, elem := range elems {
+		last := i == len(elems)-1
+		rel := "/" + strings.Join(elems[:i+1], "/")
+		for _, p := range fs.patterns {
+			target := elem
+			if p.rooted {
+				target = rel
+			}
+
+			if ok, _ := path.Match(p.glob, target); !ok {
+				continue
+			}
+
+			if !p.dirOnly || !last {
+				return true
+			}
+
+			info, err := fs.source.Stat(path.Clean("/" + filepath.ToSlash(name)))
+			if err == nil && info.IsDir() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+var errIgnoredBelow = errors.New("an ignored entry is below")
+
+// ignoresBelow checks if an ignored entry is under the directory name,
+// which can't be moved or removed without it.
+func (fs *Fs) ignoresBelow(name string) bool {
+	if len(fs.patterns) == 0 {
+		return false
+	}
+
+	root := path.Clean("/" + filepath.ToSlash(name))
+	err := afero.Walk(fs.source, root, func(p string, _ os.FileInfo, err error) error {
+		if err == nil && p != root && fs.ignored(p) {
+			return errIgnoredBelow
+		}
+
+		return nil
+	})
+
+	return err == errIgnoredBelow
+}
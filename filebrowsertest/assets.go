@@ -0,0 +1,40 @@
+package filebrowsertest
+
+import (
+	"sync"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/GeertJohan/go.rice/embedded"
+)
+
+// assetsBox is the name of the box of the frontend of the servers.
+const assetsBox = "filebrowsertest-frontend"
+
+var registerAssets sync.Once
+
+// Assets returns the frontend of the servers, to be given to the other
+// handlers with http.WithAssets: an index.html with the data of the page,
+// in place of the one of frontend/dist, so the handler can be tested
+// without building the frontend.
+func Assets() *rice.Box {
+	registerAssets.Do(func() {
+		box := &embedded.EmbeddedBox{
+			Name: assetsBox,
+			Time: DefaultModTime,
+			Dirs: map[string]*embedded.EmbeddedDir{
+				"": {Filename: "", DirModTime: DefaultModTime},
+			},
+			Files: map[string]*embedded.EmbeddedFile{
+				"index.html": {
+					Filename:    "index.html",
+					FileModTime: DefaultModTime,
+					Content:     `<!DOCTYPE html><html><head><title>[{[ .Name ]}]</title></head><body data-base-url="[{[ .BaseURL ]}]" data-static-url="[{[ .StaticURL ]}]"></body></html>`,
+				},
+			},
+		}
+		box.Link()
+		embedded.RegisterEmbeddedBox(assetsBox, box)
+	})
+
+	return rice.MustFindBox(assetsBox)
+}
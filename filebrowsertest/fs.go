@@ -0,0 +1,771 @@
+// Package filebrowsertest provides utilities to test File Browser and the
+// programs that embed its handler without touching the disk: an in-memory
+// filesystem that can have symbolic links, modes, modification times and
+// failing paths, and a Server that runs requests through the handler.
+//
+// A test of a listing looks like:
+//
+//	srv, err := filebrowsertest.New(filebrowsertest.NewFS(map[string]filebrowsertest.File{
+//		"/docs/a.txt":  {Content: "hello"},
+//		"/docs/latest": {Link: "a.txt"},
+//		"/private":     {Mode: os.ModeDir, Err: os.ErrPermission},
+//	}), settings.WithDefaultSort("name", true))
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//
+//	listing, err := srv.Listing("/docs")
+//
+// The handler serves a stand-in index.html in place of the frontend, so
+// it doesn't have to be built.
+package filebrowsertest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultModTime is the modification time of the files of NewFS that
+// don't have one, so the listings don't change between runs.
+var DefaultModTime = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// maxLinks is how many links are followed before giving up on a path.
+const maxLinks = 40
+
+// File describes a file of an FS.
+type File struct {
+	// Content is the content of regular files.
+	Content string
+	// Mode has the permissions of the file and os.ModeDir for
+	// directories. Files default to 0644 and directories to 0755.
+	Mode os.FileMode
+	// ModTime defaults to DefaultModTime.
+	ModTime time.Time
+	// Link makes the file a symbolic link to Link, which is relative to
+	// the directory of the file unless it is absolute.
+	Link string
+	// Err makes every access to the file, and to what is under it, fail
+	// with Err. Its directory lists it as an entry that can't be read, as
+	// when the information of a file on disk can't be read.
+	Err error
+}
+
+type node struct {
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+	link    string
+	err     error
+}
+
+// FS is an in-memory afero.Fs. Unlike afero.MemMapFs, it has symbolic
+// links, which it follows like the disk does, and paths that fail. It
+// implements afero.Lstater, and Readlink for the symbolic links.
+type FS struct {
+	mu    sync.RWMutex
+	nodes map[string]*node
+}
+
+// NewFS creates an FS with files, by path. The directories above them are
+// created as needed. It panics if a file is under another one which is
+// not a directory.
+func NewFS(files map[string]File) *FS {
+	fs := &FS{nodes: map[string]*node{
+		"/": {mode: os.ModeDir | 0755, modTime: DefaultModTime},
+	}}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file := files[name]
+		p := clean(name)
+
+		for dir := path.Dir(p); ; dir = path.Dir(dir) {
+			if n, ok := fs.nodes[dir]; !ok {
+				fs.nodes[dir] = &node{mode: os.ModeDir | 0755, modTime: DefaultModTime}
+			} else if !n.mode.IsDir() {
+				panic(fmt.Sprintf("filebrowsertest: %s is under %s, which is not a directory", name, dir))
+			}
+
+			if dir == "/" {
+				break
+			}
+		}
+
+		n := &node{modTime: file.ModTime, err: file.Err}
+		if n.modTime.IsZero() {
+			n.modTime = DefaultModTime
+		}
+
+		switch {
+		case file.Link != "":
+			n.mode = os.ModeSymlink | 0777
+			n.link = file.Link
+		case file.Mode.IsDir() || strings.HasSuffix(name, "/"):
+			n.mode = os.ModeDir | file.Mode.Perm()
+			if n.mode.Perm() == 0 {
+				n.mode |= 0755
+			}
+		default:
+			n.mode = file.Mode.Perm()
+			if n.mode == 0 {
+				n.mode = 0644
+			}
+			n.data = []byte(file.Content)
+		}
+
+		fs.nodes[p] = n
+	}
+
+	return fs
+}
+
+func clean(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+func pathError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// resolve returns the path of name with the links above it replaced by
+// their targets, and name itself too if follow is set. It fails if some
+// of those paths have an error.
+func (fs *FS) resolve(op, name string, follow bool) (string, error) {
+	p := clean(name)
+	for hops := 0; ; {
+		resolved := "/"
+		rest := strings.Split(strings.TrimPrefix(p, "/"), "/")
+		restarted := false
+
+		for i, part := range rest {
+			if part == "" {
+				continue
+			}
+
+			current := path.Join(resolved, part)
+			n, ok := fs.nodes[current]
+			if !ok {
+				return path.Join(append([]string{current}, rest[i+1:]...)...), nil
+			}
+
+			if n.err != nil {
+				return "", pathError(op, name, n.err)
+			}
+
+			last := i == len(rest)-1
+			if n.link != "" && (!last || follow) {
+				hops++
+				if hops > maxLinks {
+					return "", pathError(op, name, syscall.ELOOP)
+				}
+
+				target := n.link
+				if !path.IsAbs(target) {
+					target = path.Join(resolved, target)
+				}
+
+				p = clean(path.Join(append([]string{target}, rest[i+1:]...)...))
+				restarted = true
+				break
+			}
+
+			resolved = current
+		}
+
+		if !restarted {
+			return resolved, nil
+		}
+	}
+}
+
+// children returns the paths of the files in the directory dir, sorted.
+func (fs *FS) children(dir string) []string {
+	var children []string
+	for p := range fs.nodes {
+		if p != "/" && path.Dir(p) == dir {
+			children = append(children, p)
+		}
+	}
+
+	sort.Strings(children)
+	return children
+}
+
+// mkdir creates the directory p, whose parent must exist.
+func (fs *FS) mkdir(op, name, p string, perm os.FileMode) error {
+	if _, ok := fs.nodes[p]; ok {
+		return pathError(op, name, os.ErrExist)
+	}
+
+	parent, ok := fs.nodes[path.Dir(p)]
+	if !ok {
+		return pathError(op, name, os.ErrNotExist)
+	}
+
+	if !parent.mode.IsDir() {
+		return pathError(op, name, syscall.ENOTDIR)
+	}
+
+	fs.nodes[p] = &node{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// move moves p and everything under it to newpath.
+func (fs *FS) move(p, newpath string) {
+	for old, n := range fs.nodes {
+		if old == p || strings.HasPrefix(old, p+"/") {
+			delete(fs.nodes, old)
+			fs.nodes[newpath+strings.TrimPrefix(old, p)] = n
+		}
+	}
+}
+
+// Name implements afero.Fs.
+func (fs *FS) Name() string {
+	return "filebrowsertest"
+}
+
+// Create implements afero.Fs.
+func (fs *FS) Create(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir implements afero.Fs.
+func (fs *FS) Mkdir(name string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p, err := fs.resolve("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+
+	return fs.mkdir("mkdir", name, p, perm)
+}
+
+// MkdirAll implements afero.Fs.
+func (fs *FS) MkdirAll(name string, perm os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p, err := fs.resolve("mkdir", name, true)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for dir := p; dir != "/"; dir = path.Dir(dir) {
+		n, ok := fs.nodes[dir]
+		if ok && !n.mode.IsDir() {
+			return pathError("mkdir", name, syscall.ENOTDIR)
+		}
+
+		if ok {
+			break
+		}
+
+		missing = append(missing, dir)
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := fs.mkdir("mkdir", name, missing[i], perm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Open implements afero.Fs.
+func (fs *FS) Open(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile implements afero.Fs.
+func (fs *FS) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p, err := fs.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	write := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	n, ok := fs.nodes[p]
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, pathError("open", name, os.ErrNotExist)
+	case !ok:
+		parent, ok := fs.nodes[path.Dir(p)]
+		if !ok {
+			return nil, pathError("open", name, os.ErrNotExist)
+		}
+
+		if !parent.mode.IsDir() {
+			return nil, pathError("open", name, syscall.ENOTDIR)
+		}
+
+		n = &node{mode: perm.Perm(), modTime: time.Now()}
+		fs.nodes[p] = n
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, pathError("open", name, os.ErrExist)
+	case n.mode.IsDir() && write:
+		return nil, pathError("open", name, syscall.EISDIR)
+	case write && flag&os.O_TRUNC != 0:
+		n.data = nil
+		n.modTime = time.Now()
+	}
+
+	return &file{fs: fs, name: name, path: p, node: n, flag: flag}, nil
+}
+
+// Remove implements afero.Fs.
+func (fs *FS) Remove(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p, err := fs.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := fs.nodes[p]; !ok || p == "/" {
+		return pathError("remove", name, os.ErrNotExist)
+	}
+
+	if len(fs.children(p)) > 0 {
+		return pathError("remove", name, syscall.ENOTEMPTY)
+	}
+
+	delete(fs.nodes, p)
+	return nil
+}
+
+// RemoveAll implements afero.Fs.
+func (fs *FS) RemoveAll(name string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p, err := fs.resolve("removeall", name, false)
+	if err != nil {
+		return err
+	}
+
+	for q := range fs.nodes {
+		if (q == p && p != "/") || strings.HasPrefix(q, strings.TrimSuffix(p, "/")+"/") {
+			delete(fs.nodes, q)
+		}
+	}
+
+	return nil
+}
+
+// Rename implements afero.Fs.
+func (fs *FS) Rename(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	oldpath, err := fs.resolve("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+
+	newpath, err := fs.resolve("rename", newname, false)
+	if err != nil {
+		return err
+	}
+
+	n, ok := fs.nodes[oldpath]
+	if !ok || oldpath == "/" {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+
+	if oldpath == newpath {
+		return nil
+	}
+
+	if strings.HasPrefix(newpath, oldpath+"/") {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EINVAL}
+	}
+
+	if parent, ok := fs.nodes[path.Dir(newpath)]; !ok || !parent.mode.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+
+	if existing, ok := fs.nodes[newpath]; ok {
+		switch {
+		case existing.mode.IsDir() && !n.mode.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EISDIR}
+		case existing.mode.IsDir() && len(fs.children(newpath)) > 0:
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.ENOTEMPTY}
+		case !existing.mode.IsDir() && n.mode.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.ENOTDIR}
+		}
+
+		delete(fs.nodes, newpath)
+	}
+
+	fs.move(oldpath, newpath)
+	return nil
+}
+
+// Stat implements afero.Fs.
+func (fs *FS) Stat(name string) (os.FileInfo, error) {
+	return fs.stat("stat", name, true)
+}
+
+// LstatIfPossible implements afero.Lstater.
+func (fs *FS) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	info, err := fs.stat("lstat", name, false)
+	return info, true, err
+}
+
+func (fs *FS) stat(op, name string, follow bool) (os.FileInfo, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	p, err := fs.resolve(op, name, follow)
+	if err != nil {
+		return nil, err
+	}
+
+	n, ok := fs.nodes[p]
+	if !ok {
+		return nil, pathError(op, name, os.ErrNotExist)
+	}
+
+	return n.info(path.Base(clean(name))), nil
+}
+
+// Readlink returns the target of the symbolic link name.
+func (fs *FS) Readlink(name string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	p, err := fs.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+
+	n, ok := fs.nodes[p]
+	if !ok {
+		return "", pathError("readlink", name, os.ErrNotExist)
+	}
+
+	if n.link == "" {
+		return "", pathError("readlink", name, syscall.EINVAL)
+	}
+
+	return n.link, nil
+}
+
+// Chmod implements afero.Fs.
+func (fs *FS) Chmod(name string, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p, err := fs.resolve("chmod", name, true)
+	if err != nil {
+		return err
+	}
+
+	n, ok := fs.nodes[p]
+	if !ok {
+		return pathError("chmod", name, os.ErrNotExist)
+	}
+
+	n.mode = n.mode&^os.ModePerm | mode.Perm()
+	return nil
+}
+
+// Chtimes implements afero.Fs.
+func (fs *FS) Chtimes(name string, atime, mtime time.Time) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p, err := fs.resolve("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+
+	n, ok := fs.nodes[p]
+	if !ok {
+		return pathError("chtimes", name, os.ErrNotExist)
+	}
+
+	n.modTime = mtime
+	return nil
+}
+
+func (n *node) info(name string) os.FileInfo {
+	size := int64(len(n.data))
+	if n.link != "" {
+		size = int64(len(n.link))
+	}
+
+	return &fileInfo{name: name, size: size, mode: n.mode, modTime: n.modTime}
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) Mode() os.FileMode  { return i.mode }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *fileInfo) Sys() interface{}   { return nil }
+
+// file is an open file of an FS.
+type file struct {
+	fs     *FS
+	name   string
+	path   string
+	node   *node
+	flag   int
+	offset int64
+	closed bool
+	// read is how many entries of the directory were read.
+	read int
+}
+
+func (f *file) check(op string, write bool) error {
+	switch {
+	case f.closed:
+		return pathError(op, f.name, os.ErrClosed)
+	case write && f.flag&(os.O_WRONLY|os.O_RDWR) == 0:
+		return pathError(op, f.name, os.ErrPermission)
+	case !write && f.flag&os.O_WRONLY != 0:
+		return pathError(op, f.name, os.ErrPermission)
+	case f.node.mode.IsDir() && op != "readdir":
+		return pathError(op, f.name, syscall.EISDIR)
+	}
+
+	return nil
+}
+
+func (f *file) Name() string {
+	return f.name
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return pathError("close", f.name, os.ErrClosed)
+	}
+
+	f.closed = true
+	return nil
+}
+
+func (f *file) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) ReadAt(b []byte, off int64) (int, error) {
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(b, f.node.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, pathError("seek", f.name, os.ErrClosed)
+	}
+
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += size
+	}
+
+	if offset < 0 {
+		return 0, pathError("seek", f.name, syscall.EINVAL)
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *file) Write(b []byte) (int, error) {
+	if f.flag&os.O_APPEND != 0 {
+		f.fs.mu.RLock()
+		f.offset = int64(len(f.node.data))
+		f.fs.mu.RUnlock()
+	}
+
+	n, err := f.WriteAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *file) WriteAt(b []byte, off int64) (int, error) {
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if end := off + int64(len(b)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+
+	copy(f.node.data[off:], b)
+	f.node.modTime = time.Now()
+	return len(b), nil
+}
+
+func (f *file) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *file) Truncate(size int64) error {
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if size < int64(len(f.node.data)) {
+		f.node.data = f.node.data[:size]
+	} else {
+		f.node.data = append(f.node.data, make([]byte, size-int64(len(f.node.data)))...)
+	}
+
+	f.node.modTime = time.Now()
+	return nil
+}
+
+func (f *file) Sync() error {
+	return nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	if f.closed {
+		return nil, pathError("stat", f.name, os.ErrClosed)
+	}
+
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	return f.node.info(path.Base(clean(f.name))), nil
+}
+
+// entries returns the next count paths of the directory, or all of them
+// if count is not positive.
+func (f *file) entries(count int) ([]string, error) {
+	if f.closed {
+		return nil, pathError("readdir", f.name, os.ErrClosed)
+	}
+
+	if !f.node.mode.IsDir() {
+		return nil, pathError("readdir", f.name, syscall.ENOTDIR)
+	}
+
+	children := f.fs.children(f.path)
+	if f.read > len(children) {
+		f.read = len(children)
+	}
+
+	children = children[f.read:]
+	if count > 0 {
+		if len(children) == 0 {
+			return nil, io.EOF
+		}
+
+		if len(children) > count {
+			children = children[:count]
+		}
+	}
+
+	f.read += len(children)
+	return children, nil
+}
+
+// Readdir returns the information of the entries like Lstat does. The
+// entries with an error are left out, and the error is returned with
+// the others.
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	children, err := f.entries(count)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(children))
+	var failed error
+	for _, p := range children {
+		n := f.fs.nodes[p]
+		if n.err != nil {
+			if failed == nil {
+				failed = pathError("lstat", path.Join(f.name, path.Base(p)), n.err)
+			}
+			continue
+		}
+
+		infos = append(infos, n.info(path.Base(p)))
+	}
+
+	return infos, failed
+}
+
+func (f *file) Readdirnames(count int) ([]string, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	children, err := f.entries(count)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(children))
+	for i, p := range children {
+		names[i] = path.Base(p)
+	}
+
+	return names, nil
+}
@@ -0,0 +1,132 @@
+package filebrowsertest
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestFS(t *testing.T) {
+	modTime := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	fs := NewFS(map[string]File{
+		"/docs/a.txt":     {Content: "hello", Mode: 0600, ModTime: modTime},
+		"/docs/empty/":    {},
+		"/docs/latest":    {Link: "a.txt"},
+		"/docs/up":        {Link: "/docs"},
+		"/docs/broken":    {Link: "missing.txt"},
+		"/loop/a":         {Link: "b"},
+		"/loop/b":         {Link: "a"},
+		"/private":        {Mode: os.ModeDir, Err: os.ErrPermission},
+		"/private/secret": {Content: "secret"},
+	})
+
+	tests := []struct {
+		name  string
+		stat  func(string) (os.FileInfo, error)
+		path  string
+		mode  os.FileMode
+		size  int64
+		error error
+	}{
+		{"file", fs.Stat, "/docs/a.txt", 0600, 5, nil},
+		{"directory", fs.Stat, "/docs/empty", os.ModeDir | 0755, 0, nil},
+		{"link", fs.Stat, "/docs/latest", 0600, 5, nil},
+		{"link above", fs.Stat, "/docs/up/up/a.txt", 0600, 5, nil},
+		{"link lstat", lstat(fs), "/docs/latest", os.ModeSymlink | 0777, 5, nil},
+		{"broken link", fs.Stat, "/docs/broken", 0, 0, os.ErrNotExist},
+		{"broken link lstat", lstat(fs), "/docs/broken", os.ModeSymlink | 0777, 11, nil},
+		{"loop", fs.Stat, "/loop/a", 0, 0, syscall.ELOOP},
+		{"error", fs.Stat, "/private", 0, 0, os.ErrPermission},
+		{"under an error", fs.Stat, "/private/secret", 0, 0, os.ErrPermission},
+		{"missing", fs.Stat, "/nope", 0, 0, os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := tt.stat(tt.path)
+			if tt.error != nil {
+				if !errors.Is(err, tt.error) {
+					t.Fatalf("stat = %v, want %v", err, tt.error)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Mode() != tt.mode || info.Size() != tt.size {
+				t.Errorf("stat = %v, %d bytes, want %v, %d bytes", info.Mode(), info.Size(), tt.mode, tt.size)
+			}
+		})
+	}
+
+	if info, err := fs.Stat("/docs/a.txt"); err != nil || !info.ModTime().Equal(modTime) {
+		t.Errorf("the modification time is %v, %v, want %v", info.ModTime(), err, modTime)
+	}
+
+	if target, err := fs.Readlink("/docs/up"); err != nil || target != "/docs" {
+		t.Errorf("Readlink() = %q, %v, want /docs", target, err)
+	}
+
+	if _, err := fs.Readlink("/docs/a.txt"); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("Readlink() of a file = %v, want EINVAL", err)
+	}
+}
+
+func lstat(fs *FS) func(string) (os.FileInfo, error) {
+	return func(name string) (os.FileInfo, error) {
+		info, _, err := fs.LstatIfPossible(name)
+		return info, err
+	}
+}
+
+func TestFSChanges(t *testing.T) {
+	fs := NewFS(map[string]File{
+		"/docs/a.txt":   {Content: "hello"},
+		"/docs/b/c.txt": {Content: "c"},
+	})
+
+	if err := afero.WriteFile(fs, "/docs/new.txt", []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Rename("/docs/b", "/moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.MkdirAll("/x/y", 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.Remove("/docs"); err == nil {
+		t.Error("Remove() of a directory that isn't empty succeeded")
+	}
+
+	if err := fs.Mkdir("/docs/a.txt/d", 0755); !errors.Is(err, syscall.ENOTDIR) {
+		t.Errorf("Mkdir() under a file = %v, want ENOTDIR", err)
+	}
+
+	var names []string
+	err := afero.Walk(fs, "/", func(p string, info os.FileInfo, err error) error {
+		names = append(names, p)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/ /docs /docs/a.txt /docs/new.txt /moved /moved/c.txt /x /x/y"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("the files are %s, want %s", got, want)
+	}
+
+	if content, err := afero.ReadFile(fs, "/moved/c.txt"); err != nil || string(content) != "c" {
+		t.Errorf("the moved file has %q, %v", content, err)
+	}
+}
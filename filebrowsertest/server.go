@@ -0,0 +1,134 @@
+package filebrowsertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/files"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// Server runs requests through the File Browser handler, authenticated
+// as an admin whose scope is an afero.Fs, with the storage in memory.
+type Server struct {
+	Storage *storage.Storage
+	Handler http.Handler
+
+	token string
+}
+
+// New creates a Server whose user has fs as its scope. The settings are
+// the ones of settings.New with opts, without authentication. They and
+// the user can be changed through the Storage.
+func New(fs afero.Fs, opts ...settings.Option) (*Server, error) {
+	set, err := settings.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	set.AuthMethod = auth.MethodNoAuth
+
+	store := NewStorage()
+	if err := store.Auth.Save(&auth.NoAuth{}); err != nil {
+		return nil, err
+	}
+
+	if err := store.Settings.Save(set); err != nil {
+		return nil, err
+	}
+
+	server := &settings.Server{Root: "/"}
+	if err := store.Settings.SaveServer(server); err != nil {
+		return nil, err
+	}
+
+	user := &users.User{Username: "admin", Fs: fs}
+	user.Password, err = users.HashPwd("admin")
+	if err != nil {
+		return nil, err
+	}
+
+	set.Defaults.Apply(user)
+	user.Scope = "/"
+	user.Perm.Admin = true
+	if err := store.Users.Save(user); err != nil {
+		return nil, err
+	}
+
+	handler, err := fbhttp.NewHandler(store, server, fbhttp.WithAssets(Assets()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{Storage: store, Handler: handler}, nil
+}
+
+// Do runs r through the handler, with the token of the user unless it
+// has one, and returns the response.
+func (s *Server) Do(r *http.Request) (*httptest.ResponseRecorder, error) {
+	if r.Header.Get("X-Auth") == "" && r.URL.Path != "/api/login" {
+		if s.token == "" {
+			w := s.serve(httptest.NewRequest(http.MethodPost, "/api/login", nil))
+			if w.Code != http.StatusOK {
+				return nil, fmt.Errorf("couldn't log in: %d %s", w.Code, w.Body)
+			}
+
+			s.token = w.Body.String()
+		}
+
+		r.Header.Set("X-Auth", s.token)
+	}
+
+	return s.serve(r), nil
+}
+
+func (s *Server) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Handler.ServeHTTP(w, r)
+	return w
+}
+
+// Get requests target, which is a path and query, with the given
+// Accept header, if any.
+func (s *Server) Get(target, accept string) (*httptest.ResponseRecorder, error) {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+
+	return s.Do(r)
+}
+
+// Listing requests the JSON listing of the directory dir and decodes it.
+// It fails unless the response is a 200 OK.
+func (s *Server) Listing(dir string) (*files.FileInfo, error) {
+	target := "/api/resources" + strings.TrimSuffix("/"+strings.TrimPrefix(dir, "/"), "/") + "/"
+	w, err := s.Get((&url.URL{Path: target}).String(), "application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Code != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %d %s", target, w.Code, strings.TrimSpace(w.Body.String()))
+	}
+
+	file := &files.FileInfo{}
+	if err := json.Unmarshal(w.Body.Bytes(), file); err != nil {
+		return nil, fmt.Errorf("GET %s: %v", target, err)
+	}
+
+	if file.Listing == nil {
+		return nil, fmt.Errorf("GET %s: %s is not a directory", target, dir)
+	}
+
+	return file, nil
+}
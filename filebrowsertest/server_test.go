@@ -0,0 +1,54 @@
+package filebrowsertest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServerIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		status int
+		want   string // in the body
+	}{
+		{"index", "/", http.StatusOK, `data-static-url="static"`},
+		{"page of the frontend", "/files/docs/", http.StatusOK, "<title>Docs</title>"},
+		{"missing static file", "/static/js/app.js", http.StatusNotFound, ""},
+	}
+
+	// Two servers share the frontend.
+	if _, err := New(NewFS(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := New(NewFS(map[string]File{"/docs/a.txt": {Content: "a"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := srv.Storage.Settings.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.Branding.Name = "Docs"
+	if err := srv.Storage.Settings.Save(set); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := srv.Get(tt.target, "text/html")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if w.Code != tt.status {
+				t.Fatalf("GET %s = %d, want %d: %s", tt.target, w.Code, tt.status, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("GET %s = %s, want %q in it", tt.target, w.Body, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package filebrowsertest
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/share"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// NewStorage creates a storage.Storage that keeps everything in memory.
+func NewStorage() *storage.Storage {
+	users := users.NewStorage(&usersBackend{})
+	return &storage.Storage{
+		Users:    users,
+		Share:    share.NewStorage(&shareBackend{}),
+		Settings: settings.NewStorage(&settingsBackend{}),
+		Auth:     auth.NewStorage(&authBackend{authers: map[settings.AuthMethod]auth.Auther{}}, users),
+	}
+}
+
+// usersBackend returns copies of the users it has, which keep their Fs.
+// The users saved with the API get the one of their scope on the disk,
+// as they can't say otherwise.
+type usersBackend struct {
+	mu    sync.Mutex
+	users []*users.User
+}
+
+func (b *usersBackend) find(i interface{}) (*users.User, error) {
+	for _, user := range b.users {
+		switch id := i.(type) {
+		case uint:
+			if user.ID == id {
+				return user, nil
+			}
+		case string:
+			if user.Username == id {
+				return user, nil
+			}
+		default:
+			return nil, errors.ErrInvalidDataType
+		}
+	}
+
+	return nil, errors.ErrNotExist
+}
+
+func (b *usersBackend) GetBy(i interface{}) (*users.User, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	user, err := b.find(i)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *user
+	return &u, nil
+}
+
+func (b *usersBackend) Gets() ([]*users.User, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	list := make([]*users.User, len(b.users))
+	for i, user := range b.users {
+		u := *user
+		list[i] = &u
+	}
+
+	return list, nil
+}
+
+func (b *usersBackend) Save(user *users.User) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if user.ID == 0 {
+		for _, u := range b.users {
+			if u.ID > user.ID {
+				user.ID = u.ID
+			}
+		}
+		user.ID++
+	}
+
+	for i, u := range b.users {
+		if u.ID != user.ID && u.Username == user.Username {
+			return errors.ErrExist
+		}
+
+		if u.ID == user.ID {
+			saved := *user
+			saved.Fs = u.Fs
+			b.users[i] = &saved
+			return nil
+		}
+	}
+
+	saved := *user
+	b.users = append(b.users, &saved)
+	return nil
+}
+
+func (b *usersBackend) Update(user *users.User, fields ...string) error {
+	if len(fields) == 0 {
+		return b.Save(user)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	saved, err := b.find(user.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		val := reflect.ValueOf(user).Elem().FieldByName(field)
+		reflect.ValueOf(saved).Elem().FieldByName(field).Set(val)
+	}
+
+	return nil
+}
+
+func (b *usersBackend) delete(keep func(*users.User) bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	kept := b.users[:0]
+	for _, user := range b.users {
+		if keep(user) {
+			kept = append(kept, user)
+		}
+	}
+	b.users = kept
+}
+
+func (b *usersBackend) DeleteByID(id uint) error {
+	b.delete(func(u *users.User) bool { return u.ID != id })
+	return nil
+}
+
+func (b *usersBackend) DeleteByUsername(username string) error {
+	b.delete(func(u *users.User) bool { return u.Username != username })
+	return nil
+}
+
+type shareBackend struct {
+	mu    sync.Mutex
+	links []*share.Link
+}
+
+func (b *shareBackend) GetByHash(hash string) (*share.Link, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, link := range b.links {
+		if link.Hash == hash {
+			l := *link
+			return &l, nil
+		}
+	}
+
+	return nil, errors.ErrNotExist
+}
+
+func (b *shareBackend) GetPermanent(path string, id uint) (*share.Link, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, link := range b.links {
+		if link.Path == path && link.UserID == id && link.Expire == 0 {
+			l := *link
+			return &l, nil
+		}
+	}
+
+	return nil, errors.ErrNotExist
+}
+
+func (b *shareBackend) Gets(path string, id uint) ([]*share.Link, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var links []*share.Link
+	for _, link := range b.links {
+		if link.Path == path && link.UserID == id {
+			l := *link
+			links = append(links, &l)
+		}
+	}
+
+	if len(links) == 0 {
+		return links, errors.ErrNotExist
+	}
+
+	return links, nil
+}
+
+func (b *shareBackend) Save(l *share.Link) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	saved := *l
+	for i, link := range b.links {
+		if link.Hash == l.Hash {
+			b.links[i] = &saved
+			return nil
+		}
+	}
+
+	b.links = append(b.links, &saved)
+	return nil
+}
+
+func (b *shareBackend) Delete(hash string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, link := range b.links {
+		if link.Hash == hash {
+			b.links = append(b.links[:i], b.links[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// settingsBackend returns copies of the settings, so the handlers can't
+// change the ones it has.
+type settingsBackend struct {
+	mu       sync.Mutex
+	settings *settings.Settings
+	server   *settings.Server
+}
+
+func (b *settingsBackend) Get() (*settings.Settings, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.settings == nil {
+		return nil, errors.ErrNotExist
+	}
+
+	s := *b.settings
+	return &s, nil
+}
+
+func (b *settingsBackend) Save(s *settings.Settings) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	saved := *s
+	b.settings = &saved
+	return nil
+}
+
+func (b *settingsBackend) GetServer() (*settings.Server, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.server == nil {
+		return nil, errors.ErrNotExist
+	}
+
+	s := *b.server
+	return &s, nil
+}
+
+func (b *settingsBackend) SaveServer(s *settings.Server) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	saved := *s
+	b.server = &saved
+	return nil
+}
+
+type authBackend struct {
+	mu      sync.Mutex
+	authers map[settings.AuthMethod]auth.Auther
+}
+
+func (b *authBackend) Get(t settings.AuthMethod) (auth.Auther, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	auther, ok := b.authers[t]
+	if !ok {
+		return nil, errors.ErrNotExist
+	}
+
+	return auther, nil
+}
+
+func (b *authBackend) Save(a auth.Auther) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var method settings.AuthMethod
+	switch a.(type) {
+	case *auth.JSONAuth:
+		method = auth.MethodJSONAuth
+	case *auth.ProxyAuth:
+		method = auth.MethodProxyAuth
+	case *auth.NoAuth:
+		method = auth.MethodNoAuth
+	default:
+		return errors.ErrInvalidAuthMethod
+	}
+
+	b.authers[method] = a
+	return nil
+}
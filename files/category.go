@@ -0,0 +1,139 @@
+package files
+
+import (
+	"strings"
+)
+
+// Categories of the files.
+const (
+	CategoryFolder   = "folder"
+	CategoryImage    = "image"
+	CategoryVideo    = "video"
+	CategoryAudio    = "audio"
+	CategoryArchive  = "archive"
+	CategoryDocument = "document"
+	CategoryCode     = "code"
+	CategoryText     = "text"
+	CategoryBinary   = "binary"
+)
+
+// categories are the categories of the files by their extension. The
+// files whose extension is not here are classified by their type.
+var categories = map[string]string{
+	".7z":      CategoryArchive,
+	".br":      CategoryArchive,
+	".bz2":     CategoryArchive,
+	".gz":      CategoryArchive,
+	".lz4":     CategoryArchive,
+	".rar":     CategoryArchive,
+	".tar":     CategoryArchive,
+	".tar.bz2": CategoryArchive,
+	".tar.gz":  CategoryArchive,
+	".tar.lz4": CategoryArchive,
+	".tar.xz":  CategoryArchive,
+	".tar.zst": CategoryArchive,
+	".tbz2":    CategoryArchive,
+	".tgz":     CategoryArchive,
+	".txz":     CategoryArchive,
+	".xz":      CategoryArchive,
+	".zip":     CategoryArchive,
+	".zst":     CategoryArchive,
+
+	".doc":  CategoryDocument,
+	".docx": CategoryDocument,
+	".epub": CategoryDocument,
+	".odp":  CategoryDocument,
+	".ods":  CategoryDocument,
+	".odt":  CategoryDocument,
+	".pdf":  CategoryDocument,
+	".ppt":  CategoryDocument,
+	".pptx": CategoryDocument,
+	".rtf":  CategoryDocument,
+	".xls":  CategoryDocument,
+	".xlsx": CategoryDocument,
+
+	".bash":  CategoryCode,
+	".c":     CategoryCode,
+	".cc":    CategoryCode,
+	".cpp":   CategoryCode,
+	".cs":    CategoryCode,
+	".css":   CategoryCode,
+	".go":    CategoryCode,
+	".h":     CategoryCode,
+	".hpp":   CategoryCode,
+	".htm":   CategoryCode,
+	".html":  CategoryCode,
+	".java":  CategoryCode,
+	".js":    CategoryCode,
+	".json":  CategoryCode,
+	".jsx":   CategoryCode,
+	".kt":    CategoryCode,
+	".less":  CategoryCode,
+	".lua":   CategoryCode,
+	".php":   CategoryCode,
+	".pl":    CategoryCode,
+	".ps1":   CategoryCode,
+	".py":    CategoryCode,
+	".rb":    CategoryCode,
+	".rs":    CategoryCode,
+	".scala": CategoryCode,
+	".scss":  CategoryCode,
+	".sh":    CategoryCode,
+	".sql":   CategoryCode,
+	".swift": CategoryCode,
+	".toml":  CategoryCode,
+	".ts":    CategoryCode,
+	".tsx":   CategoryCode,
+	".vue":   CategoryCode,
+	".xml":   CategoryCode,
+	".yaml":  CategoryCode,
+	".yml":   CategoryCode,
+	".zsh":   CategoryCode,
+}
+
+// lookupCategory returns the category of the extension ext from the
+// custom categories or the built in ones.
+func lookupCategory(ext string, custom map[string]string) (string, bool) {
+	for key, category := range custom {
+		if strings.EqualFold(strings.TrimPrefix(key, "."), ext[1:]) {
+			return category, true
+		}
+	}
+
+	category, ok := categories[ext]
+	return category, ok
+}
+
+// detectCategory classifies the file. The longest extension wins, so
+// "a.tar.gz" is checked as ".tar.gz" before ".gz". Files with unknown
+// extensions are classified by their type.
+func (i *FileInfo) detectCategory(custom map[string]string) {
+	if i.IsDir {
+		i.Category = CategoryFolder
+		return
+	}
+
+	name := strings.ToLower(strings.TrimLeft(i.Name, "."))
+	for j := strings.IndexByte(name, '.'); j != -1; {
+		if category, ok := lookupCategory(name[j:], custom); ok {
+			i.Category = category
+			return
+		}
+
+		next := strings.IndexByte(name[j+1:], '.')
+		if next == -1 {
+			break
+		}
+
+		j += next + 1
+	}
+
+	switch i.Type {
+	case "image", "video", "audio":
+		i.Category = i.Type
+	case "text", "textImmutable":
+		i.Category = CategoryText
+	default:
+		i.Category = CategoryBinary
+	}
+}
@@ -0,0 +1,45 @@
+package files
+
+import "testing"
+
+func TestDetectCategory(t *testing.T) {
+	tests := []struct {
+		name   string
+		isDir  bool
+		typ    string
+		custom map[string]string
+		want   string
+	}{
+		{"docs", true, "", nil, CategoryFolder},
+		{"docs.zip", true, "", nil, CategoryFolder},
+		{"a.tar.gz", false, "blob", nil, CategoryArchive},
+		{"A.TAR.GZ", false, "blob", nil, CategoryArchive},
+		{"backup.2024.tar.Zst", false, "blob", nil, CategoryArchive},
+		{"report.final.PDF", false, "pdf", nil, CategoryDocument},
+		{"main.go", false, "text", nil, CategoryCode},
+		{"Main.GO", false, "text", nil, CategoryCode},
+		{".bashrc", false, "text", nil, CategoryText},
+		{".zshrc.zsh", false, "text", nil, CategoryCode},
+		{"notes", false, "text", nil, CategoryText},
+		{"photo.jpeg", false, "image", nil, CategoryImage},
+		{"clip.mkv", false, "video", nil, CategoryVideo},
+		{"song.flac", false, "audio", nil, CategoryAudio},
+		{"data.bin", false, "blob", nil, CategoryBinary},
+		{"trailing.", false, "blob", nil, CategoryBinary},
+		{"scene.blend", false, "blob", map[string]string{".blend": CategoryDocument}, CategoryDocument},
+		{"scene.BLEND", false, "blob", map[string]string{"blend": CategoryDocument}, CategoryDocument},
+		{"a.gz", false, "blob", map[string]string{".gz": CategoryBinary}, CategoryBinary},
+		{"a.tar.gz", false, "blob", map[string]string{".gz": CategoryBinary}, CategoryArchive},
+		{"model.tar.gz", false, "blob", map[string]string{".tar.gz": "model"}, "model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &FileInfo{Name: tt.name, IsDir: tt.isDir, Type: tt.typ}
+			file.detectCategory(tt.custom)
+			if file.Category != tt.want {
+				t.Errorf("the category is %q, want %q", file.Category, tt.want)
+			}
+		})
+	}
+}
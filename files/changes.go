@@ -0,0 +1,76 @@
+package files
+
+import (
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/spf13/afero"
+)
+
+// ChangesOptions are the options when looking for changes.
+type ChangesOptions struct {
+	Fs      afero.Fs
+	Path    string
+	Since   time.Time
+	Depth   int
+	Checker rules.Checker
+}
+
+// Changes walks a directory up to Depth levels below it and returns the
+// entries modified after Since, as well as the paths of all the entries
+// it went through so the callers can find out which ones were deleted.
+func Changes(opts ChangesOptions) (changed []*FileInfo, seen []string, err error) {
+	since := opts.Since.UTC()
+	changed = []*FileInfo{}
+
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		infos, err := afero.ReadDir(opts.Fs, dir)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			p := path.Join(dir, info.Name())
+			if !opts.Checker.Check(p) {
+				continue
+			}
+
+			seen = append(seen, p)
+
+			if info.ModTime().UTC().After(since) {
+				file := &FileInfo{
+					Fs:        opts.Fs,
+					Path:      p,
+					Name:      info.Name(),
+					Size:      info.Size(),
+					ModTime:   info.ModTime(),
+					ModUnix:   info.ModTime().Unix(),
+					Mode:      info.Mode(),
+					OctalMode: OctalMode(info.Mode()),
+					IsDir:     info.IsDir(),
+					Extension: filepath.Ext(info.Name()),
+					Hidden:    IsHidden(info),
+				}
+				file.Owner, file.Group = Ownership(info)
+				changed = append(changed, file)
+			}
+
+			if info.IsDir() && depth < opts.Depth {
+				if err := walk(p, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(opts.Path, 0); err != nil {
+		return nil, nil, err
+	}
+
+	return changed, seen, nil
+}
@@ -0,0 +1,622 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// docMetaScan bounds the bytes of a PDF that are scanned for its
+	// metadata. The PDFs are read docMetaWindow bytes at a time, and the
+	// windows overlap by docMetaOverlap so the objects split between two
+	// of them are whole in one.
+	docMetaScan    = 32 << 20
+	docMetaWindow  = 1 << 20
+	docMetaOverlap = 64 << 10
+	// docMetaPart bounds the bytes read of the parts of the OOXML
+	// documents and of the object streams of the PDFs, once
+	// decompressed.
+	docMetaPart = 1 << 20
+	// docMetaObjects bounds the number of objects of a PDF kept as the
+	// candidates of its information dictionary.
+	docMetaObjects = 256
+	// docMetaString bounds the length of the strings of the metadata.
+	docMetaString = 1024
+)
+
+// ErrNoDocMeta is returned for the files whose metadata can't be read,
+// such as the ones that aren't PDFs nor OOXML documents, and
+// ErrCorruptDoc for the documents that can't be parsed.
+var (
+	ErrNoDocMeta  = errors.New("the file isn't a PDF nor an OOXML document")
+	ErrCorruptDoc = errors.New("corrupt document")
+)
+
+// docMetaExtensions are the extensions of the files whose metadata is
+// read, and whether they are PDFs.
+var docMetaExtensions = map[string]bool{
+	".pdf":  true,
+	".docx": false,
+	".docm": false,
+	".xlsx": false,
+	".xlsm": false,
+	".pptx": false,
+	".pptm": false,
+}
+
+// DocMeta is the metadata of a document, read from the document itself:
+// the information dictionary or the XMP metadata of a PDF, or the core
+// and the application properties of an OOXML document. Pages are the
+// slides of the presentations.
+type DocMeta struct {
+	Title   string `json:"title,omitempty"`
+	Author  string `json:"author,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Pages   int    `json:"pages,omitempty"`
+}
+
+// HasDocMeta checks if the metadata of the file named name can be read.
+func HasDocMeta(name string) bool {
+	_, ok := docMetaExtensions[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// ReadDocMeta reads the metadata of the document at p. Only parts of the
+// file are read, and the corrupt documents are an error.
+func ReadDocMeta(fs afero.Fs, p string) (meta *DocMeta, err error) {
+	pdf, ok := docMetaExtensions[strings.ToLower(path.Ext(p))]
+	if !ok {
+		return nil, ErrNoDocMeta
+	}
+
+	fd, err := fs.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, ErrNoDocMeta
+	}
+
+	// The parsers don't trust the files, but a corrupt one mustn't take
+	// the server down if they miss something.
+	defer func() {
+		if rec := recover(); rec != nil {
+			meta, err = nil, fmt.Errorf("%w: %v", ErrCorruptDoc, rec)
+		}
+	}()
+
+	if pdf {
+		return readPDFMeta(fd, info.Size())
+	}
+
+	return readOOXMLMeta(fd, info.Size())
+}
+
+// ooxmlCore are the core properties of an OOXML document, in
+// docProps/core.xml.
+type ooxmlCore struct {
+	Title   string `xml:"title"`
+	Creator string `xml:"creator"`
+	Subject string `xml:"subject"`
+}
+
+// ooxmlApp are the application properties of an OOXML document, in
+// docProps/app.xml.
+type ooxmlApp struct {
+	Pages  int `xml:"Pages"`
+	Slides int `xml:"Slides"`
+}
+
+func readOOXMLMeta(r io.ReaderAt, size int64) (*DocMeta, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptDoc, err)
+	}
+
+	meta := &DocMeta{}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "docProps/core.xml":
+			var core ooxmlCore
+			if err := readZipXML(f, &core); err != nil {
+				return nil, err
+			}
+			meta.Title = clipString(strings.TrimSpace(core.Title))
+			meta.Author = clipString(strings.TrimSpace(core.Creator))
+			meta.Subject = clipString(strings.TrimSpace(core.Subject))
+		case "docProps/app.xml":
+			var app ooxmlApp
+			if err := readZipXML(f, &app); err != nil {
+				return nil, err
+			}
+			// The presentations have slides instead of pages.
+			if app.Pages > meta.Pages {
+				meta.Pages = app.Pages
+			}
+			if app.Slides > meta.Pages {
+				meta.Pages = app.Slides
+			}
+		}
+	}
+
+	return meta, nil
+}
+
+func readZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptDoc, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, docMetaPart))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptDoc, err)
+	}
+
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrCorruptDoc, f.Name, err)
+	}
+
+	return nil
+}
+
+var (
+	pdfPagesRe   = regexp.MustCompile(`/Type\s*/Pages\b`)
+	pdfCountRe   = regexp.MustCompile(`/Count\s+(\d+)`)
+	pdfObjStmRe  = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	pdfObjRe     = regexp.MustCompile(`(\d+)\s+\d+\s+obj\s*<<`)
+	pdfInfoRe    = regexp.MustCompile(`/Info\s+(\d+)\s+\d+\s+R`)
+	pdfEncryptRe = regexp.MustCompile(`/Encrypt\b`)
+	pdfKeyRe     = regexp.MustCompile(`/(Title|Author|Subject|Producer|Creator|CreationDate|ModDate)\b\s*`)
+	pdfIntRe     = regexp.MustCompile(`/(N|First)\s+(\d+)`)
+	pdfStreamRe  = regexp.MustCompile(`^\s*stream\r?\n`)
+	xmpRe        = regexp.MustCompile(`(?s)<dc:(title|creator|description)\b.*?<rdf:li[^>]*>([^<]*)</rdf:li>`)
+)
+
+// pdfScan is the state of the scan of a PDF.
+type pdfScan struct {
+	r         io.ReaderAt
+	pages     int
+	info      int
+	encrypted bool
+	// objects are the dictionaries that look like information ones, by
+	// their number, and xmp is the metadata of the XMP stream.
+	objects map[int]*DocMeta
+	xmp     DocMeta
+	// streams are the offsets of the object streams already read.
+	streams map[int64]bool
+}
+
+// readPDFMeta scans the start of a PDF for the root of its pages, the
+// number of its information dictionary and the dictionaries that can be
+// it, both as they are and in the object streams.
+func readPDFMeta(r io.ReaderAt, size int64) (*DocMeta, error) {
+	head := make([]byte, 5)
+	if _, err := r.ReadAt(head, 0); err != nil || string(head) != "%PDF-" {
+		return nil, fmt.Errorf("%w: not a PDF", ErrCorruptDoc)
+	}
+
+	scan := &pdfScan{r: r, objects: map[int]*DocMeta{}, streams: map[int64]bool{}}
+	buf := make([]byte, docMetaWindow)
+	limit := size
+	if limit > docMetaScan {
+		limit = docMetaScan
+	}
+	for offset := int64(0); offset < limit; offset += docMetaWindow - docMetaOverlap {
+		n, err := r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		scan.window(buf[:n], offset)
+		if offset+int64(n) >= limit {
+			break
+		}
+	}
+
+	// The trailer is at the end, past the scan of the big files.
+	if size > limit {
+		tail := make([]byte, docMetaOverlap)
+		n, err := r.ReadAt(tail, size-int64(len(tail)))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		scan.window(tail[:n], size-int64(len(tail)))
+	}
+
+	meta := &DocMeta{Pages: scan.pages}
+	if scan.encrypted {
+		// The strings of the encrypted PDFs can't be read without their
+		// key, but the XMP metadata is often left in the clear.
+		scan.objects = nil
+	}
+
+	if info, ok := scan.objects[scan.info]; ok {
+		meta.Title, meta.Author, meta.Subject = info.Title, info.Author, info.Subject
+	}
+
+	if meta.Title == "" {
+		meta.Title = scan.xmp.Title
+	}
+	if meta.Author == "" {
+		meta.Author = scan.xmp.Author
+	}
+	if meta.Subject == "" {
+		meta.Subject = scan.xmp.Subject
+	}
+
+	return meta, nil
+}
+
+// window scans data, which starts at offset in the file.
+func (s *pdfScan) window(data []byte, offset int64) {
+	s.objectsIn(data, true)
+
+	if pdfEncryptRe.Match(data) {
+		s.encrypted = true
+	}
+
+	for _, m := range pdfInfoRe.FindAllSubmatch(data, -1) {
+		if n, err := strconv.Atoi(string(m[1])); err == nil {
+			s.info = n
+		}
+	}
+
+	for _, m := range xmpRe.FindAllSubmatch(data, -1) {
+		value := clipString(strings.TrimSpace(xmlText(m[2])))
+		switch string(m[1]) {
+		case "title":
+			s.xmp.Title = value
+		case "creator":
+			s.xmp.Author = value
+		case "description":
+			s.xmp.Subject = value
+		}
+	}
+
+	for _, loc := range pdfObjStmRe.FindAllIndex(data, -1) {
+		start, end := pdfDict(data, loc[0])
+		if start < 0 {
+			continue
+		}
+
+		stream := pdfStreamRe.FindIndex(data[end:])
+		if stream == nil || s.streams[offset+int64(end+stream[1])] {
+			continue
+		}
+		at := offset + int64(end+stream[1])
+		s.streams[at] = true
+
+		s.objectStream(data[start:end], at)
+	}
+}
+
+// objectsIn looks for the roots of the pages and the candidates of the
+// information dictionary in data. The objects of the object streams have
+// no obj keyword, so they are found by their dictionaries.
+func (s *pdfScan) objectsIn(data []byte, numbered bool) {
+	for _, loc := range pdfPagesRe.FindAllIndex(data, -1) {
+		start, end := pdfDict(data, loc[0])
+		if start < 0 {
+			continue
+		}
+
+		if m := pdfCountRe.FindSubmatch(data[start:end]); m != nil {
+			if n, err := strconv.Atoi(string(m[1])); err == nil && n > s.pages {
+				s.pages = n
+			}
+		}
+	}
+
+	if !numbered {
+		return
+	}
+
+	for _, m := range pdfObjRe.FindAllSubmatchIndex(data, -1) {
+		n, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+
+		_, end := pdfDict(data, m[1]-1)
+		if end < 0 {
+			continue
+		}
+
+		s.candidate(n, data[m[1]-2:end])
+	}
+}
+
+// candidate keeps the dictionary of the object n if it can be the
+// information dictionary: it has some of its keys, and it isn't an item
+// of the outline, which have titles too.
+func (s *pdfScan) candidate(n int, dict []byte) {
+	if bytes.Contains(dict, []byte("/Parent")) || bytes.Contains(dict, []byte("/Type")) {
+		return
+	}
+
+	keys := pdfKeyRe.FindAllSubmatchIndex(dict, -1)
+	if len(keys) == 0 {
+		return
+	}
+
+	if _, ok := s.objects[n]; !ok && len(s.objects) >= docMetaObjects {
+		return
+	}
+
+	meta := &DocMeta{}
+	for _, key := range keys {
+		value := clipString(strings.TrimSpace(pdfString(dict[key[1]:])))
+		switch string(dict[key[2]:key[3]]) {
+		case "Title":
+			meta.Title = value
+		case "Author":
+			meta.Author = value
+		case "Subject":
+			meta.Subject = value
+		}
+	}
+	s.objects[n] = meta
+}
+
+// objectStream reads the objects of the object stream whose dictionary
+// is dict and whose data starts at offset in the file. Only the streams
+// compressed with Flate are read.
+func (s *pdfScan) objectStream(dict []byte, offset int64) {
+	if bytes.Contains(dict, []byte("/Filter")) && !bytes.Contains(dict, []byte("/FlateDecode")) {
+		return
+	}
+
+	count, first := -1, -1
+	for _, m := range pdfIntRe.FindAllSubmatch(dict, -1) {
+		n, err := strconv.Atoi(string(m[2]))
+		if err != nil {
+			return
+		}
+
+		if string(m[1]) == "N" {
+			count = n
+		} else {
+			first = n
+		}
+	}
+
+	if count < 0 || first < 0 {
+		return
+	}
+
+	var src io.Reader = io.NewSectionReader(s.r, offset, docMetaPart)
+	if bytes.Contains(dict, []byte("/FlateDecode")) {
+		zr, err := zlib.NewReader(src)
+		if err != nil {
+			return
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	// The streams are read as far as they can be, so a truncated one
+	// still has its first objects.
+	data, _ := io.ReadAll(io.LimitReader(src, docMetaPart))
+	if first > len(data) {
+		return
+	}
+
+	s.objectsIn(data, false)
+
+	header := strings.Fields(string(data[:first]))
+	for i := 0; i+1 < len(header) && i < 2*count; i += 2 {
+		n, err1 := strconv.Atoi(header[i])
+		at, err2 := strconv.Atoi(header[i+1])
+		if err1 != nil || err2 != nil || at < 0 || first+at >= len(data) {
+			continue
+		}
+
+		obj := data[first+at:]
+		start := bytes.Index(obj, []byte("<<"))
+		if start < 0 || len(bytes.TrimSpace(obj[:start])) > 0 {
+			continue
+		}
+
+		if _, end := pdfDict(obj, start+1); end > 0 {
+			s.candidate(n, obj[start:end])
+		}
+	}
+}
+
+// pdfDict returns the bounds of the dictionary of data around i, from
+// its << to its >>, or -1 if they can't be found nearby. The dictionary
+// can start at i-1.
+func pdfDict(data []byte, i int) (int, int) {
+	// The dictionaries of the pages have the references to all of them,
+	// so they can be long.
+	const reach = docMetaOverlap / 2
+
+	start, depth := -1, 0
+	for j := i; j >= 1 && i-j < reach; j-- {
+		switch {
+		case data[j-1] == '>' && data[j] == '>':
+			depth++
+			j--
+		case data[j-1] == '<' && data[j] == '<':
+			if depth == 0 {
+				start = j - 1
+			} else {
+				depth--
+			}
+			j--
+		}
+		if start >= 0 {
+			break
+		}
+	}
+
+	if start < 0 {
+		return -1, -1
+	}
+
+	depth = 0
+	for j := start; j+1 < len(data) && j-start < 2*reach; j++ {
+		switch {
+		case data[j] == '<' && data[j+1] == '<':
+			depth++
+			j++
+		case data[j] == '>' && data[j+1] == '>':
+			depth--
+			j++
+			if depth == 0 {
+				return start, j + 1
+			}
+		}
+	}
+
+	return -1, -1
+}
+
+// pdfString decodes the string at the start of data, either a literal
+// one in parentheses or a hexadecimal one in angle brackets.
+func pdfString(data []byte) string {
+	var raw []byte
+	switch {
+	case len(data) > 0 && data[0] == '(':
+		raw = pdfLiteral(data[1:])
+	case len(data) > 1 && data[0] == '<' && data[1] != '<':
+		raw = pdfHex(data[1:])
+	default:
+		return ""
+	}
+
+	// The strings are either in UTF-16 with its byte order mark, in
+	// UTF-8 with its own, or in PDFDocEncoding, which is about Latin-1.
+	switch {
+	case len(raw) >= 2 && raw[0] == 0xfe && raw[1] == 0xff:
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case bytes.HasPrefix(raw, []byte("\xef\xbb\xbf")):
+		return strings.ToValidUTF8(string(raw[3:]), "")
+	}
+
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+func pdfLiteral(data []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(data) && len(out) < 4*docMetaString; i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch c = data[i]; c {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r':
+				if i+1 < len(data) && data[i+1] == '\n' {
+					i++
+				}
+			case '\n':
+			default:
+				if c >= '0' && c <= '7' {
+					v := 0
+					for j := 0; j < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; j++ {
+						v = v*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					out = append(out, byte(v))
+				} else {
+					out = append(out, c)
+				}
+			}
+		case c == '(':
+			depth++
+			out = append(out, c)
+		case c == ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+func pdfHex(data []byte) []byte {
+	var out []byte
+	var digits []byte
+	for i := 0; i < len(data) && data[i] != '>' && len(out) < 4*docMetaString; i++ {
+		if v, err := strconv.ParseUint(string(data[i]), 16, 8); err == nil {
+			digits = append(digits, byte(v))
+		}
+
+		if len(digits) == 2 {
+			out = append(out, digits[0]<<4|digits[1])
+			digits = digits[:0]
+		}
+	}
+
+	if len(digits) == 1 {
+		out = append(out, digits[0]<<4)
+	}
+
+	return out
+}
+
+// xmlText unescapes the text of an XML element.
+func xmlText(data []byte) string {
+	var s string
+	if err := xml.Unmarshal(append(append([]byte("<x>"), data...), "</x>"...), &s); err != nil {
+		return string(data)
+	}
+
+	return s
+}
+
+// clipString cuts s to docMetaString runes.
+func clipString(s string) string {
+	s = strings.ToValidUTF8(s, "")
+	if runes := []rune(s); len(runes) > docMetaString {
+		return string(runes[:docMetaString])
+	}
+
+	return s
+}
@@ -0,0 +1,230 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// samplePDF returns a PDF with an information dictionary and the root of
+// its pages, and the metadata read from it.
+func samplePDF() ([]byte, DocMeta) {
+	pdf := strings.Join([]string{
+		"%PDF-1.4",
+		"1 0 obj << /Title (Quarterly \\(draft\\) report) /Author <FEFF0041006C006900630065> /Subject (Sales) >> endobj",
+		"2 0 obj << /Type /Pages /Count 12 /Kids [3 0 R] >> endobj",
+		"3 0 obj << /Type /Page /Parent 2 0 R /Title (Not the info) >> endobj",
+		"trailer << /Root 4 0 R /Info 1 0 R >>",
+		"%%EOF",
+	}, "\n")
+
+	return []byte(pdf), DocMeta{Title: "Quarterly (draft) report", Author: "Alice", Subject: "Sales", Pages: 12}
+}
+
+// objectStreamPDF returns a PDF whose information dictionary is in a
+// compressed object stream, and the metadata read from it.
+func objectStreamPDF() ([]byte, DocMeta) {
+	objects := "<< /Title (Streamed) /Author (Bob) >> << /Type /Pages /Count 4 >>"
+	header := "5 0 6 38 "
+
+	var stream bytes.Buffer
+	zw := zlib.NewWriter(&stream)
+	zw.Write([]byte(header + objects))
+	zw.Close()
+
+	pdf := fmt.Sprintf("%%PDF-1.5\n7 0 obj << /Type /ObjStm /N 2 /First %d /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream endobj\ntrailer << /Info 5 0 R >>\n%%%%EOF",
+		len(header), stream.Len(), stream.Bytes())
+
+	return []byte(pdf), DocMeta{Title: "Streamed", Author: "Bob", Pages: 4}
+}
+
+// sampleOOXML returns an OOXML document with the parts.
+func sampleOOXML(parts map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	return buf.Bytes()
+}
+
+const (
+	sampleCore = `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Plan</dc:title><dc:creator>Carol</dc:creator><dc:subject>Roadmap</dc:subject></cp:coreProperties>`
+	sampleApp  = `<Properties><Pages>7</Pages></Properties>`
+)
+
+// sampleDocx returns a document with the core and application properties,
+// and the metadata read from it.
+func sampleDocx() ([]byte, DocMeta) {
+	return sampleOOXML(map[string]string{
+		"[Content_Types].xml": "<Types/>",
+		"docProps/core.xml":   sampleCore,
+		"docProps/app.xml":    sampleApp,
+	}), DocMeta{Title: "Plan", Author: "Carol", Subject: "Roadmap", Pages: 7}
+}
+
+// readMeta reads the metadata of data with the parser of the PDFs or of
+// the OOXML documents. Unlike ReadDocMeta, it doesn't recover from the
+// panics, so the tests see them.
+func readMeta(pdf bool, data []byte) (*DocMeta, error) {
+	if pdf {
+		return readPDFMeta(bytes.NewReader(data), int64(len(data)))
+	}
+
+	return readOOXMLMeta(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestReadDocMeta(t *testing.T) {
+	pdf, pdfMeta := samplePDF()
+	stream, streamMeta := objectStreamPDF()
+	docx, docxMeta := sampleDocx()
+
+	tests := []struct {
+		name string
+		pdf  bool
+		data []byte
+		want DocMeta
+	}{
+		{"PDF", true, pdf, pdfMeta},
+		{"object stream", true, stream, streamMeta},
+		{"docx", false, docx, docxMeta},
+		{"pptx", false, sampleOOXML(map[string]string{"docProps/app.xml": `<Properties><Slides>9</Slides></Properties>`}), DocMeta{Pages: 9}},
+		{"OOXML without properties", false, sampleOOXML(map[string]string{"word/document.xml": "<w:document/>"}), DocMeta{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := readMeta(tt.pdf, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *meta != tt.want {
+				t.Errorf("the metadata is %+v, want %+v", *meta, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadDocMetaCorrupt(t *testing.T) {
+	random := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	tests := []struct {
+		name string
+		pdf  bool
+		data []byte
+		want *DocMeta // or nil for an ErrCorruptDoc
+	}{
+		{"empty PDF", true, nil, nil},
+		{"short PDF", true, []byte("%PDF"), nil},
+		{"not a PDF", true, []byte("<html>"), nil},
+		{"random bytes", true, random, nil},
+		{"random bytes after the header", true, append([]byte("%PDF-1.7\n"), random...), &DocMeta{}},
+		{"unfinished dictionary", true, []byte("%PDF-1.4\n1 0 obj << /Title (Cut"), &DocMeta{}},
+		{"unfinished string", true, []byte("%PDF-1.4\n1 0 obj << /Title (Cut >> endobj trailer << /Info 1 0 R >>"), &DocMeta{Title: "Cut >>"}},
+		{"unbalanced dictionaries", true, []byte("%PDF-1.4\n" + strings.Repeat("<<", 10000) + " /Type /Pages /Count 3 " + strings.Repeat(">>", 3)), &DocMeta{Pages: 3}},
+		{"closing dictionaries only", true, []byte("%PDF-1.4\n" + strings.Repeat(">>", 10000) + "/Type /Pages"), &DocMeta{}},
+		{"huge count", true, []byte("%PDF-1.4\n1 0 obj << /Type /Pages /Count 99999999999999999999999 >> endobj"), &DocMeta{}},
+		{"huge object number", true, []byte("%PDF-1.4\n99999999999999999999999 0 obj << /Title (Big) >> endobj"), &DocMeta{}},
+		{"huge info number", true, []byte("%PDF-1.4\n1 0 obj << /Title (A) >> endobj trailer << /Info 99999999999999999999999 0 R >>"), &DocMeta{}},
+		{"odd hexadecimal string", true, []byte("%PDF-1.4\n1 0 obj << /Title <FEFF004> >> endobj trailer << /Info 1 0 R >>"), &DocMeta{Title: "@"}},
+		{"odd UTF-16 string", true, []byte("%PDF-1.4\n1 0 obj << /Title <FEFF00410> >> endobj trailer << /Info 1 0 R >>"), &DocMeta{Title: "A"}},
+		{"invalid UTF-8 string", true, []byte("%PDF-1.4\n1 0 obj << /Title <EFBBBFFF41> >> endobj trailer << /Info 1 0 R >>"), &DocMeta{Title: "A"}},
+		{"escape at the end", true, []byte("%PDF-1.4\n1 0 obj << /Title (A\\"), &DocMeta{}},
+		{"octal escape at the end", true, []byte("%PDF-1.4\n1 0 obj << /Title (A\\7>> endobj trailer << /Info 1 0 R >>"), &DocMeta{Title: "A\a>>"}},
+		{"object stream without its counts", true, []byte("%PDF-1.5\n<< /Type /ObjStm >>\nstream\n<< /Title (A) >>"), &DocMeta{}},
+		{"object stream past its data", true, []byte("%PDF-1.5\n<< /Type /ObjStm /N 1 /First 100000 >>\nstream\n1 0 << /Title (A) >>"), &DocMeta{}},
+		{"object stream with huge counts", true, []byte("%PDF-1.5\n<< /Type /ObjStm /N 99999999999999999999999 /First 0 >>\nstream\n1 0 << /Title (A) >>"), &DocMeta{}},
+		{"object stream with bad offsets", true, []byte("%PDF-1.5\n<< /Type /ObjStm /N 3 /First 16 >>\nstream\n1 -5 2 999 3 x  << /Title (A) >>"), &DocMeta{}},
+		{"object stream with bad compression", true, append([]byte("%PDF-1.5\n<< /Type /ObjStm /N 1 /First 4 /Filter /FlateDecode >>\nstream\n"), random[:256]...), &DocMeta{}},
+		{"object stream with another compression", true, []byte("%PDF-1.5\n<< /Type /ObjStm /N 1 /First 4 /Filter /LZWDecode >>\nstream\n1 0 << /Title (A) >>"), &DocMeta{}},
+		{"empty OOXML", false, nil, nil},
+		{"not a zip", false, []byte("PK\x03\x04 not a zip"), nil},
+		{"random OOXML", false, random, nil},
+		{"garbage core properties", false, sampleOOXML(map[string]string{"docProps/core.xml": "<cp:coreProperties><dc:title>"}), nil},
+		{"binary core properties", false, sampleOOXML(map[string]string{"docProps/core.xml": string(random[:512])}), nil},
+		{"garbage application properties", false, sampleOOXML(map[string]string{"docProps/app.xml": "<Properties><Pages>many</Pages></Properties>"}), nil},
+		{"huge page count", false, sampleOOXML(map[string]string{"docProps/app.xml": "<Properties><Pages>99999999999999999999999</Pages></Properties>"}), nil},
+		{"negative page count", false, sampleOOXML(map[string]string{"docProps/app.xml": "<Properties><Pages>-3</Pages></Properties>"}), &DocMeta{}},
+		{"empty core properties", false, sampleOOXML(map[string]string{"docProps/core.xml": ""}), nil},
+		{"invalid UTF-8 title", false, sampleOOXML(map[string]string{"docProps/core.xml": "<c><title>A\xffB</title></c>"}), nil},
+		{"long title", false, sampleOOXML(map[string]string{"docProps/core.xml": "<c><title>" + strings.Repeat("é", 2*docMetaString) + "</title></c>"}), &DocMeta{Title: strings.Repeat("é", docMetaString)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := readMeta(tt.pdf, tt.data)
+			if tt.want == nil {
+				if !errors.Is(err, ErrCorruptDoc) {
+					t.Fatalf("the metadata is %+v, %v, want an ErrCorruptDoc", meta, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *meta != *tt.want {
+				t.Errorf("the metadata is %+v, want %+v", *meta, *tt.want)
+			}
+		})
+	}
+}
+
+// TestReadDocMetaTruncated reads every truncation of the samples: each
+// fails, or has the metadata of the whole document or none of it.
+func TestReadDocMetaTruncated(t *testing.T) {
+	pdf, pdfMeta := samplePDF()
+	stream, streamMeta := objectStreamPDF()
+	docx, docxMeta := sampleDocx()
+
+	tests := []struct {
+		name string
+		pdf  bool
+		data []byte
+		full DocMeta
+	}{
+		{"PDF", true, pdf, pdfMeta},
+		{"object stream", true, stream, streamMeta},
+		{"docx", false, docx, docxMeta},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for n := 0; n < len(tt.data); n++ {
+				meta, err := readMeta(tt.pdf, tt.data[:n])
+				if err != nil {
+					if !errors.Is(err, ErrCorruptDoc) {
+						t.Errorf("cut at %d: %v isn't an ErrCorruptDoc", n, err)
+					}
+					continue
+				}
+
+				for _, field := range []struct{ got, full string }{
+					{meta.Title, tt.full.Title},
+					{meta.Author, tt.full.Author},
+					{meta.Subject, tt.full.Subject},
+				} {
+					if field.got != "" && field.got != field.full || !utf8.ValidString(field.got) {
+						t.Errorf("cut at %d: the metadata is %+v, want the one of the whole document %+v or none", n, *meta, tt.full)
+					}
+				}
+				if meta.Pages != 0 && meta.Pages != tt.full.Pages {
+					t.Errorf("cut at %d: the document has %d pages, want %d or none", n, meta.Pages, tt.full.Pages)
+				}
+			}
+		})
+	}
+}
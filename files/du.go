@@ -0,0 +1,127 @@
+package files
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/spf13/afero"
+)
+
+// DiskUsageOptions are the options when measuring the directories under
+// a directory.
+type DiskUsageOptions struct {
+	// Context stops the walk, such as when the request is canceled.
+	Context context.Context
+	Fs      afero.Fs
+	Path    string
+	// Depth is the depth of the deepest directories reported. Their sizes
+	// still count everything below them.
+	Depth int
+	// MaxEntries is the number of entries walked through. The directories
+	// that weren't walked completely are approximate.
+	MaxEntries int
+	Checker    rules.Checker
+	// Partial returns what was measured until Context is done, as
+	// approximate, rather than its error.
+	Partial bool
+}
+
+// DirUsage is the space taken by a directory and everything below it.
+// Name is its path relative to the measured directory.
+type DirUsage struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	Depth int    `json:"depth"`
+	Size  int64  `json:"size"`
+	Dirs  int    `json:"dirs"`
+	Files int    `json:"files"`
+	// Approximate is set when some of the entries below the directory
+	// couldn't be read or weren't walked.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// DiskUsage walks a directory once and returns its own usage and the
+// ones of the directories under it up to Depth levels below, largest
+// first.
+func DiskUsage(opts DiskUsageOptions) (*DirUsage, []*DirUsage, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	entries := 0
+	dirs := []*DirUsage{}
+	var rootErr error
+
+	var walk func(dir string, depth int) *DirUsage
+	walk = func(dir string, depth int) *DirUsage {
+		u := &DirUsage{
+			Path:  dir,
+			Name:  strings.TrimPrefix(strings.TrimPrefix(dir, opts.Path), "/"),
+			Depth: depth,
+		}
+
+		if depth > 0 && depth <= opts.Depth {
+			dirs = append(dirs, u)
+		}
+
+		if ctx.Err() != nil {
+			u.Approximate = true
+			return u
+		}
+
+		infos, err := afero.ReadDir(opts.Fs, dir)
+		if err != nil {
+			if depth == 0 {
+				rootErr = err
+			}
+			u.Approximate = true
+			return u
+		}
+
+		for _, info := range infos {
+			p := path.Join(dir, info.Name())
+			if !opts.Checker.Check(p) {
+				continue
+			}
+
+			if opts.MaxEntries > 0 && entries >= opts.MaxEntries {
+				u.Approximate = true
+				break
+			}
+			entries++
+
+			if !info.IsDir() {
+				u.Files++
+				u.Size += info.Size()
+				continue
+			}
+
+			child := walk(p, depth+1)
+			u.Dirs += child.Dirs + 1
+			u.Files += child.Files
+			u.Size += child.Size
+			u.Approximate = u.Approximate || child.Approximate
+		}
+
+		return u
+	}
+
+	root := walk(opts.Path, 0)
+	if rootErr != nil {
+		return nil, nil, rootErr
+	}
+
+	if err := ctx.Err(); err != nil && !opts.Partial {
+		return nil, nil, err
+	}
+
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return dirs[i].Size > dirs[j].Size
+	})
+
+	return root, dirs, nil
+}
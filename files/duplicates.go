@@ -0,0 +1,245 @@
+package files
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"path"
+	"sort"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/spf13/afero"
+)
+
+// duplicateWorkers is the number of files hashed at the same time.
+const duplicateWorkers = 4
+
+// DuplicatesOptions are the options when looking for duplicates.
+type DuplicatesOptions struct {
+	// Context cancels the search, such as when the request is.
+	Context context.Context
+	Fs      afero.Fs
+	Path    string
+	Depth   int
+	// MaxFiles is the number of files compared. The search is truncated
+	// once it is reached.
+	MaxFiles int
+	// MaxSize is the size of the biggest file hashed. Zero is no limit.
+	MaxSize int64
+	Checker rules.Checker
+}
+
+// DuplicateGroup is a set of files with the same contents.
+type DuplicateGroup struct {
+	Size  int64    `json:"size"`
+	Hash  string   `json:"sha256"`
+	Paths []string `json:"paths"`
+}
+
+// Duplicates are the files with the same contents under a directory.
+// Wasted is the size of all the copies but one of each group. Skipped
+// counts the files that couldn't be compared because they are too big or
+// couldn't be read.
+type Duplicates struct {
+	Groups    []*DuplicateGroup `json:"groups"`
+	Files     int               `json:"files"`
+	Skipped   int               `json:"skipped"`
+	Wasted    int64             `json:"wasted"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+// FindDuplicates walks a directory up to Depth levels below it and groups
+// its files that are byte-identical. The files are grouped by size first,
+// so only the ones that share their size are hashed.
+func FindDuplicates(opts DuplicatesOptions) (*Duplicates, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	res := &Duplicates{Groups: []*DuplicateGroup{}}
+	bySize := map[int64][]string{}
+
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		if err := ctx.Err(); err != nil || res.Truncated {
+			return err
+		}
+
+		infos, err := afero.ReadDir(opts.Fs, dir)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			p := path.Join(dir, info.Name())
+			if !opts.Checker.Check(p) {
+				continue
+			}
+
+			if info.IsDir() {
+				// The directories below that can't be read are left out.
+				if depth < opts.Depth {
+					if err := walk(p, depth+1); err != nil && ctx.Err() != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			// Empty files are all the same, and waste nothing.
+			if !info.Mode().IsRegular() || info.Size() == 0 {
+				continue
+			}
+
+			if opts.MaxFiles > 0 && res.Files >= opts.MaxFiles {
+				res.Truncated = true
+				return nil
+			}
+
+			res.Files++
+			if tooBig(opts, info.Size()) {
+				res.Skipped++
+				continue
+			}
+
+			bySize[info.Size()] = append(bySize[info.Size()], p)
+		}
+
+		return nil
+	}
+
+	if err := walk(opts.Path, 0); err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, paths := range bySize {
+		if len(paths) > 1 {
+			candidates = append(candidates, paths...)
+		}
+	}
+
+	hashes, failed := hashFiles(ctx, opts.Fs, candidates)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	res.Skipped += failed
+
+	for size, paths := range bySize {
+		byHash := map[string][]string{}
+		for _, p := range paths {
+			if hash, ok := hashes[p]; ok {
+				byHash[hash] = append(byHash[hash], p)
+			}
+		}
+
+		for hash, same := range byHash {
+			if len(same) < 2 {
+				continue
+			}
+
+			sort.Strings(same)
+			res.Groups = append(res.Groups, &DuplicateGroup{Size: size, Hash: hash, Paths: same})
+			res.Wasted += size * int64(len(same)-1)
+		}
+	}
+
+	// The groups that waste the most come first.
+	sort.Slice(res.Groups, func(i, j int) bool {
+		a, b := res.Groups[i], res.Groups[j]
+		wa, wb := a.Size*int64(len(a.Paths)-1), b.Size*int64(len(b.Paths)-1)
+		if wa != wb {
+			return wa > wb
+		}
+		return a.Paths[0] < b.Paths[0]
+	})
+
+	return res, nil
+}
+
+// tooBig checks if a file of size is skipped.
+func tooBig(opts DuplicatesOptions, size int64) bool {
+	if opts.MaxSize > 0 && size > opts.MaxSize {
+		return true
+	}
+
+	return isRemote(opts.Fs) && size > remoteChecksumLimit
+}
+
+// hashFiles returns the SHA-256 of the files at paths, and the number of
+// them that couldn't be read. It stops early if ctx is canceled.
+func hashFiles(ctx context.Context, fs afero.Fs, paths []string) (map[string]string, int) {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		hashes = map[string]string{}
+		failed int
+	)
+
+	queue := make(chan string)
+	for i := 0; i < duplicateWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				hash, err := hashFile(ctx, fs, p, "sha256")
+
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					hashes[p] = hash
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, p := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- p
+	}
+	close(queue)
+	wg.Wait()
+
+	return hashes, failed
+}
+
+// hashFile returns the hex digest of the file name with the algorithm
+// of the checksums algo.
+func hashFile(ctx context.Context, fs afero.Fs, name, algo string) (string, error) {
+	h, err := NewHash(algo)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, contextReader{ctx, f}); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contextReader stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
@@ -1,6 +1,7 @@
 package files
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -14,29 +15,101 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/logging"
 	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/tracing"
 	"github.com/spf13/afero"
 )
 
-// FileInfo describes a file.
+// remoteChecksumLimit is the biggest file that is checksummed when
+// the file lives on a remote filesystem.
+const remoteChecksumLimit = 64 * 1024 * 1024 // 64 MB
+
+// remoteFs is implemented by the filesystems on which reading the
+// contents of the files is expensive, such as network backed ones.
+type remoteFs interface {
+	Remote() bool
+}
+
+func isRemote(fs afero.Fs) bool {
+	r, ok := fs.(remoteFs)
+	return ok && r.Remote()
+}
+
+// FileInfo describes a file. ModUnix is its modification time in seconds
+// since the Unix epoch, for the clients that don't parse the RFC 3339
+// date of ModTime, and OctalMode its permission bits the way chmod takes
+// them, such as 0644.
 type FileInfo struct {
 	*Listing
-	Fs        afero.Fs          `json:"-"`
-	Path      string            `json:"path"`
-	Name      string            `json:"name"`
-	Size      int64             `json:"size"`
-	Extension string            `json:"extension"`
-	ModTime   time.Time         `json:"modified"`
-	Mode      os.FileMode       `json:"mode"`
-	IsDir     bool              `json:"isDir"`
-	Type      string            `json:"type"`
-	Subtitles []string          `json:"subtitles,omitempty"`
-	Content   string            `json:"content,omitempty"`
+	Fs        afero.Fs    `json:"-"`
+	Path      string      `json:"path"`
+	Name      string      `json:"name"`
+	Size      int64       `json:"size"`
+	Extension string      `json:"extension"`
+	ModTime   time.Time   `json:"modified"`
+	ModUnix   int64       `json:"modifiedUnix"`
+	Mode      os.FileMode `json:"mode"`
+	OctalMode string      `json:"octalMode"`
+	IsDir     bool        `json:"isDir"`
+	Type      string      `json:"type"`
+	Category  string      `json:"category,omitempty"`
+	Subtitles []string    `json:"subtitles,omitempty"`
+	Content   string      `json:"content,omitempty"`
+	// Encoding is base64 when Content is the base64 of a binary file.
+	Encoding  string            `json:"encoding,omitempty"`
 	Checksums map[string]string `json:"checksums,omitempty"`
+	// Error is set on the items of the listings whose information
+	// couldn't be read, which only have a name and a path.
+	Error bool `json:"error,omitempty"`
+	// IsSymlink and LinkTarget are only set when the options have a
+	// Readlink function. The other information is the one of the target,
+	// unless the link is broken.
+	IsSymlink  bool   `json:"isSymlink,omitempty"`
+	LinkTarget string `json:"linkTarget,omitempty"`
+	// SizeIsEstimate is set on the directories of the listings with the
+	// sizes of their contents whose walks didn't complete.
+	SizeIsEstimate bool `json:"sizeIsEstimate,omitempty"`
+	// BrokenLink is set on the symbolic links of the listings whose
+	// targets can't be reached, which are listed as empty files.
+	BrokenLink bool `json:"brokenLink,omitempty"`
+	// GitStatus is the status in git of the items of the listings of
+	// the directories in work trees, such as "modified", when it is on.
+	GitStatus string `json:"gitStatus,omitempty"`
+	// DocMeta is the metadata read from the PDFs and the OOXML documents,
+	// when the settings or the request ask for it.
+	DocMeta *DocMeta `json:"docMeta,omitempty"`
+	// Special is the kind of the special files, such as "pipe", whose
+	// contents are never read.
+	Special string `json:"special,omitempty"`
+	// Hidden is set on the files whose names start with a dot and, on
+	// Windows, on the ones with the hidden or the system attribute, so
+	// the clients can leave them out.
+	Hidden bool `json:"hidden,omitempty"`
+	// Owner and Group are the names of the owner and the group of the
+	// file, or their ids if they don't resolve. They are empty on the
+	// systems and the filesystems that have none, such as Windows.
+	Owner string `json:"owner,omitempty"`
+	Group string `json:"group,omitempty"`
+	// URL is the link to the results of the searches of the listings,
+	// relative to the searched directory.
+	URL string `json:"url,omitempty"`
+	// Thumbnail is the URL of the thumbnail of the images of the
+	// listings that have one.
+	Thumbnail string `json:"thumbnail,omitempty"`
+	// Tags and MimeType are only set when the request asks for them.
+	Tags     []string `json:"tags,omitempty"`
+	MimeType string   `json:"mimeType,omitempty"`
+	// Children are the items of the directories of the recursive
+	// listings, and ChildrenError is set on the directories that couldn't
+	// be read.
+	Children      []*FileInfo `json:"children,omitempty"`
+	ChildrenError bool        `json:"childrenError,omitempty"`
 }
 
 // FileOptions are the options when getting a file info.
@@ -46,14 +119,37 @@ type FileOptions struct {
 	Modify  bool
 	Expand  bool
 	Checker rules.Checker
+
+	// Categories are custom categories by extension, such as
+	// ".blend": "document", which take precedence over the built in ones.
+	Categories map[string]string
+
+	// Readlink, when set, marks the symbolic links as such, with the
+	// target it returns for them.
+	Readlink func(name string) (string, error)
+
+	// Context, when set, is the context of the request the listings are
+	// traced in.
+	Context context.Context
+
+	// ReadLimit, when positive, stops reading the directory once that
+	// many entries are read, which are then listed in the order of the
+	// filesystem rather than by name. The listing is marked as truncated
+	// if there are more.
+	ReadLimit int
+
+	// Logger, when set, logs the links that can't be read and the
+	// directories whose entries have to be read one by one.
+	Logger logging.Logger
 }
 
 // NewFileInfo creates a File object from a path and a given user. This File
 // object will be automatically filled depending on if it is a directory
 // or a file. If it's a video file, it will also detect any subtitles.
+// The files the checker refuses aren't found, so they don't leak.
 func NewFileInfo(opts FileOptions) (*FileInfo, error) {
 	if !opts.Checker.Check(opts.Path) {
-		return nil, os.ErrPermission
+		return nil, os.ErrNotExist
 	}
 
 	info, err := opts.Fs.Stat(opts.Path)
@@ -66,21 +162,39 @@ func NewFileInfo(opts FileOptions) (*FileInfo, error) {
 		Path:      opts.Path,
 		Name:      info.Name(),
 		ModTime:   info.ModTime(),
+		ModUnix:   info.ModTime().Unix(),
 		Mode:      info.Mode(),
+		OctalMode: OctalMode(info.Mode()),
 		IsDir:     info.IsDir(),
 		Size:      info.Size(),
 		Extension: filepath.Ext(info.Name()),
+		Hidden:    IsHidden(info),
+	}
+	file.Owner, file.Group = Ownership(info)
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = logging.Nop
+	}
+
+	if opts.Readlink != nil {
+		if info, err := lstat(opts.Fs, opts.Path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			file.markLink(opts.Readlink, logger)
+		}
 	}
 
 	if opts.Expand {
 		if file.IsDir {
-			return file, file.readListing(opts.Checker)
+			file.detectCategory(opts.Categories)
+			return file, file.readListing(opts.Context, opts.Checker, opts.Categories, opts.Readlink, opts.ReadLimit, logger)
 		}
 
 		err = file.detectType(opts.Modify, true)
 		if err != nil {
 			return nil, err
 		}
+
+		file.detectCategory(opts.Categories)
 	}
 
 	return file, err
@@ -93,6 +207,14 @@ func (i *FileInfo) Checksum(algo string) error {
 		return errors.ErrIsDirectory
 	}
 
+	if isRemote(i.Fs) && i.Size > remoteChecksumLimit {
+		return errors.ErrTooLarge
+	}
+
+	if SpecialKind(i.Mode) != "" {
+		return errors.ErrSpecialFile
+	}
+
 	if i.Checksums == nil {
 		i.Checksums = map[string]string{}
 	}
@@ -103,31 +225,91 @@ func (i *FileInfo) Checksum(algo string) error {
 	}
 	defer reader.Close()
 
-	var h hash.Hash
+	h, err := NewHash(algo)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(h, reader)
+	if err != nil {
+		return err
+	}
+
+	i.Checksums[algo] = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
 
+// NewHash returns a new hash of the algorithm of the checksums, which is
+// md5, sha1, sha256 or sha512.
+func NewHash(algo string) (hash.Hash, error) {
 	switch algo {
 	case "md5":
-		h = md5.New()
+		return md5.New(), nil
 	case "sha1":
-		h = sha1.New()
+		return sha1.New(), nil
 	case "sha256":
-		h = sha256.New()
+		return sha256.New(), nil
 	case "sha512":
-		h = sha512.New()
-	default:
-		return errors.ErrInvalidOption
+		return sha512.New(), nil
 	}
 
-	_, err = io.Copy(h, reader)
-	if err != nil {
-		return err
+	return nil, errors.ErrInvalidOption
+}
+
+// Classify detects the type and the category of the file like it is
+// done for the items of the listings, without reading its contents.
+func (i *FileInfo) Classify(categories map[string]string) error {
+	if !i.IsDir {
+		if err := i.detectType(true, false); err != nil {
+			return err
+		}
 	}
 
-	i.Checksums[algo] = hex.EncodeToString(h.Sum(nil))
+	i.detectCategory(categories)
 	return nil
 }
 
+// IsText checks if the file is text, which can be previewed as it is,
+// whether it can be edited or not.
+func (i *FileInfo) IsText() bool {
+	return !i.IsDir && (i.Type == "text" || i.Type == "textImmutable")
+}
+
+// Clone returns a copy of the file and of the items of its listing, which
+// can be changed without changing them, and are read with fs.
+func (i *FileInfo) Clone(fs afero.Fs) *FileInfo {
+	c := *i
+	c.Fs = fs
+	if i.Listing == nil {
+		return &c
+	}
+
+	listing := *i.Listing
+	listing.Items = make([]*FileInfo, len(i.Items))
+	for n, item := range i.Items {
+		clone := *item
+		clone.Fs = fs
+		clone.Subtitles = append([]string(nil), item.Subtitles...)
+		listing.Items[n] = &clone
+	}
+
+	c.Listing = &listing
+	return &c
+}
+
 func (i *FileInfo) detectType(modify, saveContent bool) error {
+	if i.Special = SpecialKind(i.Mode); i.Special != "" {
+		i.Type = "blob"
+		return nil
+	}
+
+	if !saveContent && isRemote(i.Fs) {
+		// opening every file of a listing would take ages on a
+		// remote filesystem so we only rely on the extension.
+		i.detectTypeByExtension()
+		return nil
+	}
+
 	// failing to detect the type should not return error.
 	// imagine the situation where a file in a dir with thousands
 	// of files couldn't be opened: we'd have immediately
@@ -188,6 +370,23 @@ func (i *FileInfo) detectType(modify, saveContent bool) error {
 	return nil
 }
 
+func (i *FileInfo) detectTypeByExtension() {
+	mimetype := mime.TypeByExtension(i.Extension)
+
+	switch {
+	case strings.HasPrefix(mimetype, "video"):
+		i.Type = "video"
+	case strings.HasPrefix(mimetype, "audio"):
+		i.Type = "audio"
+	case strings.HasPrefix(mimetype, "image"):
+		i.Type = "image"
+	case strings.HasPrefix(mimetype, "text") && i.Size <= 10*1024*1024: // 10 MB
+		i.Type = "text"
+	default:
+		i.Type = "blob"
+	}
+}
+
 func (i *FileInfo) detectSubtitles() {
 	if i.Type != "video" {
 		return
@@ -204,17 +403,40 @@ func (i *FileInfo) detectSubtitles() {
 	}
 }
 
-func (i *FileInfo) readListing(checker rules.Checker) error {
-	afs := &afero.Afero{Fs: i.Fs}
-	dir, err := afs.ReadDir(i.Path)
+// markLink marks the file as a symbolic link with the target returned
+// by readlink, which is left empty if it fails.
+func (i *FileInfo) markLink(readlink func(name string) (string, error), logger logging.Logger) {
+	i.IsSymlink = true
+
+	target, err := readlink(i.Path)
+	if err != nil {
+		logger.Warn("couldn't read the link", "path", i.Path, "error", err)
+		return
+	}
+
+	i.LinkTarget = target
+}
+
+func (i *FileInfo) readListing(ctx context.Context, checker rules.Checker, categories map[string]string, readlink func(string) (string, error), limit int, logger logging.Logger) error {
+	_, span := tracing.Start(ctx, "filebrowser.readdir")
+	dir, unreadable, truncated, err := readDir(i.Fs, i.Path, limit, logger)
+	span.SetInt("items", int64(len(dir)))
+	span.SetInt("unreadable", int64(len(unreadable)))
+	span.End()
 	if err != nil {
 		return err
 	}
 
+	// The enrichment checks the rules and follows the links of the items,
+	// and detects their types.
+	_, span = tracing.Start(ctx, "filebrowser.enrich")
+	defer span.End()
+
 	listing := &Listing{
-		Items:    []*FileInfo{},
-		NumDirs:  0,
-		NumFiles: 0,
+		Items:     make([]*FileInfo, 0, len(dir)+len(unreadable)),
+		NumDirs:   0,
+		NumFiles:  0,
+		Truncated: truncated,
 	}
 
 	for _, f := range dir {
@@ -225,12 +447,16 @@ func (i *FileInfo) readListing(checker rules.Checker) error {
 			continue
 		}
 
-		if strings.HasPrefix(f.Mode().String(), "L") {
+		isLink := strings.HasPrefix(f.Mode().String(), "L")
+		broken := false
+		if isLink {
 			// It's a symbolic link. We try to follow it. If it doesn't work,
 			// we stay with the link information instead if the target's.
 			info, err := i.Fs.Stat(path)
 			if err == nil {
 				f = info
+			} else {
+				broken = true
 			}
 		}
 
@@ -239,26 +465,143 @@ func (i *FileInfo) readListing(checker rules.Checker) error {
 			Name:      name,
 			Size:      f.Size(),
 			ModTime:   f.ModTime(),
+			ModUnix:   f.ModTime().Unix(),
 			Mode:      f.Mode(),
+			OctalMode: OctalMode(f.Mode()),
 			IsDir:     f.IsDir(),
 			Extension: filepath.Ext(name),
 			Path:      path,
+			Hidden:    IsHidden(f),
+		}
+		file.Owner, file.Group = Ownership(f)
+
+		if broken {
+			file.Size = 0
+			file.BrokenLink = true
+		}
+
+		if isLink && readlink != nil {
+			file.markLink(readlink, logger)
 		}
 
 		if file.IsDir {
 			listing.NumDirs++
 		} else {
 			listing.NumFiles++
+		}
 
-			err := file.detectType(true, false)
-			if err != nil {
-				return err
-			}
+		if err := file.Classify(categories); err != nil {
+			return err
 		}
 
 		listing.Items = append(listing.Items, file)
 	}
 
+	for _, name := range unreadable {
+		path := path.Join(i.Path, name)
+		if !checker.Check(path) {
+			continue
+		}
+
+		listing.Items = append(listing.Items, &FileInfo{
+			Fs:        i.Fs,
+			Name:      name,
+			Extension: filepath.Ext(name),
+			Path:      path,
+			Type:      "blob",
+			Error:     true,
+		})
+		listing.NumUnreadable++
+	}
+
+	span.SetInt("items", int64(len(listing.Items)))
 	i.Listing = listing
 	return nil
 }
+
+// readDirBatch is the number of entries of the directories read at a
+// time, so a large directory is never asked for all of them at once.
+const readDirBatch = 4096
+
+// readDir reads the entries of a directory, sorted by name. When limit is
+// positive, it stops once it has read limit of them, which are returned
+// in the order of the filesystem, and truncated tells if there are more.
+// When some entries can't be read, it reads the others one by one and
+// returns the names of the ones that failed, so that one bad entry
+// doesn't make the whole directory unreadable. It only fails if the
+// directory can't be read at all.
+func readDir(fs afero.Fs, name string, limit int, logger logging.Logger) (infos []os.FileInfo, unreadable []string, truncated bool, err error) {
+	dir, err := fs.Open(name)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer dir.Close()
+
+	for {
+		batch, err := dir.Readdir(readDirBatch)
+		infos = append(infos, batch...)
+		switch {
+		case err == io.EOF || (err == nil && len(batch) == 0):
+			if limit <= 0 {
+				sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+			}
+			return infos, nil, false, nil
+		case err != nil:
+			logger.Warn("couldn't read some entries, reading them one by one", "path", name, "error", err)
+			return readDirNames(fs, name, limit)
+		case limit > 0 && len(infos) > limit:
+			// One more entry than the limit tells there are more.
+			return infos[:limit], nil, true, nil
+		}
+	}
+}
+
+// readDirNames reads the entries of a directory one by one, like readDir
+// does when some of them can't be read.
+func readDirNames(fs afero.Fs, name string, limit int) ([]os.FileInfo, []string, bool, error) {
+	// The offset of the directory is past the entries read so far.
+	again, err := fs.Open(name)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer again.Close()
+
+	names, err := again.Readdirnames(-1)
+	if err != nil && len(names) == 0 {
+		return nil, nil, false, err
+	}
+
+	truncated := false
+	if limit > 0 && len(names) > limit {
+		names, truncated = names[:limit], true
+	}
+
+	if limit <= 0 {
+		sort.Strings(names)
+	}
+
+	infos := make([]os.FileInfo, 0, len(names))
+	var unreadable []string
+	for _, n := range names {
+		info, err := lstat(fs, path.Join(name, n))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				unreadable = append(unreadable, n)
+			}
+			continue
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, unreadable, truncated, nil
+}
+
+func lstat(fs afero.Fs, name string) (os.FileInfo, error) {
+	if lstater, ok := fs.(afero.Lstater); ok {
+		info, _, err := lstater.LstatIfPossible(name)
+		return info, err
+	}
+
+	return fs.Stat(name)
+}
@@ -0,0 +1,145 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/logging"
+)
+
+type allowAll struct{}
+
+func (allowAll) Check(string) bool { return true }
+
+func TestNewFileInfoLogs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(dir, "link")); err != nil {
+		t.Skip("symbolic links aren't supported:", err)
+	}
+
+	var buf bytes.Buffer
+	tests := []struct {
+		name   string
+		logger logging.Logger
+		want   string
+	}{
+		{"without a logger", nil, ""},
+		{"with a logger", logging.NewStd(log.New(&buf, "", 0), logging.LevelDebug), "WARN couldn't read the link"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			file, err := NewFileInfo(FileOptions{
+				Fs:      afero.NewBasePathFs(afero.NewOsFs(), dir),
+				Path:    "/",
+				Expand:  true,
+				Checker: allowAll{},
+				Readlink: func(string) (string, error) {
+					return "", errors.New("unreadable")
+				},
+				Logger: tt.logger,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(file.Items) != 2 || !file.Items[1].IsSymlink {
+				t.Fatalf("the listing has %d items, want the file and the link", len(file.Items))
+			}
+
+			if got := buf.String(); !strings.HasPrefix(got, tt.want) || tt.want == "" && got != "" {
+				t.Errorf("the log is %q, want it to start with %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// bigDir is a directory of fake entries, read without a filesystem, so
+// the benchmarks only measure readDir.
+type bigDir struct {
+	afero.File
+	b       *testing.B
+	entries []os.FileInfo
+	read    int
+}
+
+func (d *bigDir) Readdir(count int) ([]os.FileInfo, error) {
+	if count <= 0 {
+		d.b.Fatalf("the directory was read whole with Readdir(%d)", count)
+	}
+
+	if d.read == len(d.entries) {
+		return nil, io.EOF
+	}
+
+	end := d.read + count
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+	batch := d.entries[d.read:end]
+	d.read = end
+	return batch, nil
+}
+
+func (d *bigDir) Close() error { return nil }
+
+// bigDirFs opens every name as a bigDir of the entries.
+type bigDirFs struct {
+	afero.Fs
+	b       *testing.B
+	entries []os.FileInfo
+}
+
+func (fs *bigDirFs) Open(string) (afero.File, error) {
+	return &bigDir{b: fs.b, entries: fs.entries}, nil
+}
+
+func BenchmarkReadDir(b *testing.B) {
+	const entries = 500000
+
+	// The names are in a shuffled order, as the ones of the filesystems.
+	infos := make([]os.FileInfo, entries)
+	for i := range infos {
+		infos[i] = fakeInfo(fmt.Sprintf("file-%06d", (i*7919)%entries))
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+	}{
+		{"whole", 0},
+		{"first page", 100},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			fs := &bigDirFs{b: b, entries: infos}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				read, _, truncated, err := readDir(fs, "/big", tt.limit, logging.Nop)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if want := tt.limit > 0; truncated != want {
+					b.Fatalf("truncated = %v, want %v", truncated, want)
+				}
+				if tt.limit == 0 && len(read) != entries {
+					b.Fatalf("read %d entries, want %d", len(read), entries)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package files
+
+import (
+	"os"
+	"strings"
+)
+
+// IsHidden checks if the file of info is hidden: if its name starts with
+// a dot or, on Windows, if it has the hidden or the system attribute.
+func IsHidden(info os.FileInfo) bool {
+	return strings.HasPrefix(info.Name(), ".") || hiddenAttribute(info)
+}
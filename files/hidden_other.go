@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package files
+
+import "os"
+
+func hiddenAttribute(info os.FileInfo) bool {
+	return false
+}
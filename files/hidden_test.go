@@ -0,0 +1,38 @@
+package files
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// fakeInfo is the information of a file named name.
+type fakeInfo string
+
+func (i fakeInfo) Name() string       { return string(i) }
+func (i fakeInfo) Size() int64        { return 0 }
+func (i fakeInfo) Mode() os.FileMode  { return 0644 }
+func (i fakeInfo) ModTime() time.Time { return time.Time{} }
+func (i fakeInfo) IsDir() bool        { return false }
+func (i fakeInfo) Sys() interface{}   { return nil }
+
+func TestIsHidden(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{".hidden", true},
+		{".git", true},
+		{"..", true},
+		{"visible", false},
+		{"a.txt", false},
+		{"a.", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsHidden(fakeInfo(tt.name)); got != tt.want {
+			t.Errorf("IsHidden(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
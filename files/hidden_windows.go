@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package files
+
+import (
+	"os"
+	"syscall"
+)
+
+func hiddenAttribute(info os.FileInfo) bool {
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return false
+	}
+
+	return data.FileAttributes&(syscall.FILE_ATTRIBUTE_HIDDEN|syscall.FILE_ATTRIBUTE_SYSTEM) != 0
+}
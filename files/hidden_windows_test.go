@@ -0,0 +1,51 @@
+//go:build windows
+// +build windows
+
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestHiddenAttribute(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes uint32
+		want       bool
+	}{
+		{"normal", syscall.FILE_ATTRIBUTE_NORMAL, false},
+		{"read-only", syscall.FILE_ATTRIBUTE_READONLY, false},
+		{"hidden", syscall.FILE_ATTRIBUTE_HIDDEN, true},
+		{"system", syscall.FILE_ATTRIBUTE_SYSTEM, true},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(name, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := syscall.UTF16PtrFromString(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := syscall.SetFileAttributes(p, tt.attributes); err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := IsHidden(info); got != tt.want {
+				t.Errorf("IsHidden() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
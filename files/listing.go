@@ -1,22 +1,111 @@
 package files
 
 import (
+	"bytes"
 	"sort"
 	"strings"
 
 	"github.com/maruel/natural"
+	"golang.org/x/text/collate"
 )
 
-// Listing is a collection of files.
+// Listing is a collection of files. When ItemsLimitedTo is set, Items only
+// has that many of them, while NumDirs and NumFiles still count them all.
+// Offset is the position of the first of Items among the TotalItems of
+// the sorted listing, when it's a page of it.
+// NumUnreadable counts the items whose information couldn't be read,
+// which are neither directories nor files, so API clients know the
+// listing is partial. Usage, Free and Quota are the used, free and total
+// bytes of the disk of the scope, which are only known for local disks.
+// Branch is the git branch of the directories in work trees, when the
+// git status is on. Truncated is set on the listings made by walking a
+// directory, such as the recent files, that stopped before the end.
+// Capabilities are what the user can do in the directory.
+// HiddenSuppressed is set when the files whose names start with a dot are
+// left out of Items, and NumHidden counts them; NumDirs and NumFiles
+// don't. DirSizes is set when the sizes of the directories are the ones
+// of their contents. Readme is the source of the README file of the
+// directory, when the request asks for it. Collator, when set, sorts the
+// names with the collation of its language rather than case-folded.
 type Listing struct {
-	Items    []*FileInfo `json:"items"`
-	NumDirs  int         `json:"numDirs"`
-	NumFiles int         `json:"numFiles"`
-	Sorting  Sorting     `json:"sorting"`
+	Items            []*FileInfo   `json:"items"`
+	NumDirs          int           `json:"numDirs"`
+	NumFiles         int           `json:"numFiles"`
+	NumUnreadable    int           `json:"numUnreadable,omitempty"`
+	Sorting          Sorting       `json:"sorting"`
+	Usage            uint64        `json:"usage,omitempty"`
+	Free             uint64        `json:"free,omitempty"`
+	Quota            uint64        `json:"quota,omitempty"`
+	ItemsLimitedTo   int           `json:"itemsLimitedTo,omitempty"`
+	Offset           int           `json:"offset,omitempty"`
+	TotalItems       int           `json:"totalItems,omitempty"`
+	Branch           string        `json:"branch,omitempty"`
+	Truncated        bool          `json:"truncated,omitempty"`
+	HiddenSuppressed bool          `json:"hiddenSuppressed,omitempty"`
+	DirSizes         bool          `json:"dirSizes,omitempty"`
+	NumHidden        int           `json:"numHidden,omitempty"`
+	Favorites        []Favorite    `json:"favorites,omitempty"`
+	Capabilities     *Capabilities `json:"capabilities,omitempty"`
+	Readme           string        `json:"readme,omitempty"`
+
+	Collator *collate.Collator `json:"-"`
+	keys     map[*FileInfo][]byte
+}
+
+// Capabilities are what a user can do in a directory: upload files to it,
+// make directories in it, delete, rename and edit its files, download
+// them as an archive and share them. They take the permissions of the
+// user, the rules and the read-only aliases into account.
+type Capabilities struct {
+	CanUpload          bool `json:"canUpload"`
+	CanMkdir           bool `json:"canMkdir"`
+	CanDelete          bool `json:"canDelete"`
+	CanRename          bool `json:"canRename"`
+	CanEdit            bool `json:"canEdit"`
+	CanDownloadArchive bool `json:"canDownloadArchive"`
+	CanShare           bool `json:"canShare"`
+}
+
+// Favorite is a path pinned by a user. Missing is set when there is
+// nothing at the path anymore, so the pin can be removed.
+type Favorite struct {
+	Path    string `json:"path"`
+	Name    string `json:"name"`
+	IsDir   bool   `json:"isDir"`
+	Missing bool   `json:"missing,omitempty"`
+}
+
+// Page keeps the n items of the listing from offset, or all of them from
+// offset if n is not positive. It must be called once the listing is
+// sorted, so the pages are stable. An offset past the end leaves no
+// items.
+func (l *Listing) Page(offset, n int) {
+	l.TotalItems = len(l.Items)
+	if offset > len(l.Items) {
+		offset = len(l.Items)
+	}
+
+	l.Items = l.Items[offset:]
+	l.Offset = offset
+	if n <= 0 || len(l.Items) <= n {
+		return
+	}
+
+	l.Items = l.Items[:n]
+	l.ItemsLimitedTo = n
 }
 
-// ApplySort applies the sort order using .Order and .Sort
+// ApplySort applies the sort order using .Order and .Sort. The listings
+// sorted by "none" are left in the order of the filesystem.
 func (l Listing) ApplySort() {
+	if l.Sorting.By == "none" {
+		return
+	}
+
+	if l.Collator != nil && l.Sorting.By != "size" && l.Sorting.By != "modified" {
+		l.keys = l.collationKeys()
+	}
+
 	// Check '.Order' to know how to sort
 	if !l.Sorting.Asc {
 		switch l.Sorting.By {
@@ -26,6 +115,8 @@ func (l Listing) ApplySort() {
 			sort.Sort(sort.Reverse(bySize(l)))
 		case "modified":
 			sort.Sort(sort.Reverse(byModified(l)))
+		case "type":
+			sort.Sort(sort.Reverse(byType(l)))
 		default:
 			// If not one of the above, do nothing
 			return
@@ -38,6 +129,8 @@ func (l Listing) ApplySort() {
 			sort.Sort(bySize(l))
 		case "modified":
 			sort.Sort(byModified(l))
+		case "type":
+			sort.Sort(byType(l))
 		default:
 			sort.Sort(byName(l))
 			return
@@ -45,10 +138,50 @@ func (l Listing) ApplySort() {
 	}
 }
 
+// GroupDirs moves the directories of the listing before its files,
+// keeping the order of each, so they are grouped whatever the sorting.
+func (l Listing) GroupDirs() {
+	sort.SliceStable(l.Items, func(i, j int) bool {
+		return l.Items[i].IsDir && !l.Items[j].IsDir
+	})
+}
+
+// collationKeys returns the collation keys of the names of the items, so
+// they are computed once per sort rather than once per comparison.
+func (l Listing) collationKeys() map[*FileInfo][]byte {
+	var buf collate.Buffer
+	keys := make(map[*FileInfo][]byte, len(l.Items))
+	for _, item := range l.Items {
+		keys[item] = l.Collator.KeyFromString(&buf, item.Name)
+	}
+
+	return keys
+}
+
+// lessName checks if the name of a sorts before the one of b.
+func (l Listing) lessName(a, b *FileInfo) bool {
+	if l.keys != nil {
+		return bytes.Compare(l.keys[a], l.keys[b]) < 0
+	}
+
+	return natural.Less(strings.ToLower(a.Name), strings.ToLower(b.Name))
+}
+
+// IsSortKey checks if the listings can be sorted by key.
+func IsSortKey(key string) bool {
+	switch key {
+	case "name", "size", "modified", "type":
+		return true
+	default:
+		return false
+	}
+}
+
 // Implement sorting for Listing
 type byName Listing
 type bySize Listing
 type byModified Listing
+type byType Listing
 
 // By Name
 func (l byName) Len() int {
@@ -69,7 +202,7 @@ func (l byName) Less(i, j int) bool {
 		return false
 	}
 
-	return natural.Less(strings.ToLower(l.Items[j].Name), strings.ToLower(l.Items[i].Name))
+	return Listing(l).lessName(l.Items[j], l.Items[i])
 }
 
 // By Size
@@ -106,3 +239,28 @@ func (l byModified) Less(i, j int) bool {
 	iModified, jModified := l.Items[i].ModTime, l.Items[j].ModTime
 	return iModified.Sub(jModified) < 0
 }
+
+// By Type, which groups the files by extension, regardless of case, and
+// sorts them by name in each group like byName.
+func (l byType) Len() int {
+	return len(l.Items)
+}
+
+func (l byType) Swap(i, j int) {
+	l.Items[i], l.Items[j] = l.Items[j], l.Items[i]
+}
+
+func (l byType) Less(i, j int) bool {
+	if l.Items[i].IsDir != l.Items[j].IsDir {
+		return l.Items[i].IsDir
+	}
+
+	if !l.Items[i].IsDir {
+		iExt, jExt := strings.ToLower(l.Items[i].Extension), strings.ToLower(l.Items[j].Extension)
+		if iExt != jExt {
+			return natural.Less(jExt, iExt)
+		}
+	}
+
+	return Listing(l).lessName(l.Items[j], l.Items[i])
+}
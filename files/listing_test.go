@@ -0,0 +1,205 @@
+package files
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+func TestGroupDirs(t *testing.T) {
+	base := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	items := func() []*FileInfo {
+		return []*FileInfo{
+			{Name: "big.iso", Size: 300, ModTime: base.Add(1 * time.Hour)},
+			{Name: "zebra", Size: 50, IsDir: true, ModTime: base.Add(5 * time.Hour)},
+			{Name: "small.txt", Size: 10, ModTime: base.Add(3 * time.Hour)},
+			{Name: "apple", Size: 500, IsDir: true, ModTime: base.Add(2 * time.Hour)},
+			{Name: "mid.txt", Size: 100, ModTime: base.Add(4 * time.Hour)},
+		}
+	}
+
+	// The Asc of the sortings is reversed for the names and the times.
+	tests := []struct {
+		name    string
+		sorting Sorting
+		want    string
+	}{
+		{"size descending", Sorting{By: "size", Asc: false}, "apple zebra big.iso mid.txt small.txt"},
+		{"size ascending", Sorting{By: "size", Asc: true}, "zebra apple small.txt mid.txt big.iso"},
+		{"name", Sorting{By: "name", Asc: false}, "apple zebra big.iso mid.txt small.txt"},
+		{"name reversed", Sorting{By: "name", Asc: true}, "zebra apple small.txt mid.txt big.iso"},
+		{"modified", Sorting{By: "modified", Asc: false}, "zebra apple mid.txt small.txt big.iso"},
+		{"modified reversed", Sorting{By: "modified", Asc: true}, "apple zebra big.iso small.txt mid.txt"},
+		{"none", Sorting{By: "none"}, "zebra apple big.iso small.txt mid.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := Listing{Items: items(), Sorting: tt.sorting}
+			l.ApplySort()
+
+			// Each group keeps the order of the sorting.
+			var dirs, files []string
+			for _, item := range l.Items {
+				if item.IsDir {
+					dirs = append(dirs, item.Name)
+				} else {
+					files = append(files, item.Name)
+				}
+			}
+
+			l.GroupDirs()
+			names := make([]string, len(l.Items))
+			for i, item := range l.Items {
+				names[i] = item.Name
+			}
+
+			got := strings.Join(names, " ")
+			if got != tt.want {
+				t.Errorf("the listing is %s, want %s", got, tt.want)
+			}
+			if sorted := strings.Join(append(dirs, files...), " "); got != sorted {
+				t.Errorf("the listing is %s, want the groups as sorted: %s", got, sorted)
+			}
+		})
+	}
+}
+
+func TestGroupDirsStable(t *testing.T) {
+	l := Listing{Items: []*FileInfo{
+		{Name: "c"}, {Name: "b", IsDir: true}, {Name: "a"}, {Name: "e", IsDir: true}, {Name: "d"}, {Name: "a", IsDir: true},
+	}}
+	l.GroupDirs()
+
+	names := make([]string, len(l.Items))
+	for i, item := range l.Items {
+		names[i] = item.Name
+	}
+
+	if got, want := strings.Join(names, " "), "b e a c a d"; got != want {
+		t.Errorf("the listing is %s, want %s", got, want)
+	}
+}
+
+func BenchmarkApplySortNames(b *testing.B) {
+	const entries = 50000
+
+	// Names with accents, cases and numbers, in a shuffled order.
+	words := []string{"Änderungen", "apple", "Apple", "Öl", "zebra", "Ärger", "résumé", "Resume", "über", "Umbau"}
+	original := make([]*FileInfo, entries)
+	for i := range original {
+		n := (i * 7919) % entries
+		original[i] = &FileInfo{Name: fmt.Sprintf("%s %d.txt", words[n%len(words)], n)}
+	}
+
+	tests := []struct {
+		name     string
+		collator *collate.Collator
+	}{
+		{"case-folded", nil},
+		{"collated", collate.New(language.German, collate.IgnoreCase, collate.Numeric)},
+	}
+
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			items := make([]*FileInfo, entries)
+			l := Listing{Items: items, Sorting: Sorting{By: "name", Asc: true}, Collator: tt.collator}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				copy(items, original)
+				b.StartTimer()
+
+				l.ApplySort()
+			}
+		})
+	}
+}
+
+func TestLessNameUnicode(t *testing.T) {
+	collated := Listing{Collator: collate.New(language.French, collate.IgnoreCase, collate.Numeric)}
+
+	// The names are compared both ways, with and without a collator:
+	// whatever their bytes, at most one is before the other.
+	tests := []struct {
+		name string
+		a, b string
+		less bool // a is before b in both comparisons
+	}{
+		{"numbers", "file2", "file10", true},
+		{"case", "apple", "Banana", true},
+		{"invalid UTF-8", "a\xff", "a\xfe", false},
+		{"invalid UTF-8 against valid", "\xff", "z", false},
+		{"truncated rune", "caf\xc3", "café", false},
+		{"lone continuation bytes", "\x80\x80", "\x80", false},
+		{"combining mark", "e\u0301", "\u00e9", false},
+		{"combining marks only", "\u0301\u0308", "\u0308", false},
+		{"combining mark on a digit", "1\u0301", "2", true},
+		{"long number", "f99999999999999999999999999", "f1", false},
+		{"leading zeros", "f007", "f7", false},
+		{"empty", "", "a", true},
+		{"NUL", "a\x00b", "a", false},
+		{"surrogate", "\xed\xa0\x80", "a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := &FileInfo{Name: tt.a}, &FileInfo{Name: tt.b}
+			for _, l := range []Listing{{}, collated} {
+				if l.Collator != nil {
+					l.Items = []*FileInfo{a, b}
+					l.keys = l.collationKeys()
+				}
+
+				ab, ba := l.lessName(a, b), l.lessName(b, a)
+				if ab && ba {
+					t.Errorf("%q and %q are both before the other (collated: %v)", tt.a, tt.b, l.Collator != nil)
+				}
+				if tt.less && !ab {
+					t.Errorf("%q isn't before %q (collated: %v)", tt.a, tt.b, l.Collator != nil)
+				}
+			}
+		})
+	}
+}
+
+// TestApplySortRandomNames sorts names made of random pieces of invalid
+// UTF-8, combining marks and digits by every key, in both orders: the
+// sorts don't panic and keep every item.
+func TestApplySortRandomNames(t *testing.T) {
+	pieces := []string{"a", "B", "\u00e9", "e\u0301", "\u0301", "\u0308", "1", "10", "0", "99999999999999999999", "\xff", "\xc3", "\x80", "\xed\xa0\x80", ".", ".TXT", ".txt", " ", "\x00", "日本"}
+	random := rand.New(rand.NewSource(1))
+
+	items := make([]*FileInfo, 500)
+	for i := range items {
+		var name strings.Builder
+		for n := random.Intn(6); n >= 0; n-- {
+			name.WriteString(pieces[random.Intn(len(pieces))])
+		}
+		items[i] = &FileInfo{Name: name.String(), Extension: pieces[random.Intn(len(pieces))], IsDir: random.Intn(4) == 0}
+	}
+
+	for _, by := range []string{"name", "type", "size", "modified"} {
+		for _, asc := range []bool{true, false} {
+			for _, collator := range []*collate.Collator{nil, collate.New(language.German, collate.IgnoreCase, collate.Numeric)} {
+				t.Run(fmt.Sprintf("%s %v %v", by, asc, collator != nil), func(t *testing.T) {
+					l := Listing{Items: append([]*FileInfo(nil), items...), Sorting: Sorting{By: by, Asc: asc}, Collator: collator}
+					l.ApplySort()
+
+					seen := map[*FileInfo]bool{}
+					for _, item := range l.Items {
+						seen[item] = true
+					}
+					if len(l.Items) != len(items) || len(seen) != len(items) {
+						t.Errorf("the sort kept %d of the %d items", len(seen), len(items))
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// DirMimeType is the MIME type of the directories.
+const DirMimeType = "inode/directory"
+
+// MimeTypeByName returns the MIME type of a file from its name: the one
+// of overrides for its base name or its extension, such as "Makefile" or
+// ".log", or else the one of its extension. It returns an empty string
+// if neither of them is known.
+func MimeTypeByName(name string, overrides map[string]string) string {
+	base := path.Base(name)
+	ext := strings.ToLower(path.Ext(base))
+
+	if t, ok := overrides[base]; ok {
+		return t
+	}
+
+	if t, ok := overrides[ext]; ok && ext != "" {
+		return t
+	}
+
+	return mime.TypeByExtension(ext)
+}
+
+// SniffMimeType returns the MIME type of the file at name of fs detected
+// from its first 512 bytes.
+func SniffMimeType(fs afero.Fs, name string) (string, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buffer := make([]byte, 512)
+	n, err := io.ReadFull(f, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	return http.DetectContentType(buffer[:n]), nil
+}
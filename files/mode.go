@@ -0,0 +1,93 @@
+package files
+
+import (
+	"fmt"
+	"os"
+)
+
+// SpecialBits are the setuid, setgid and sticky bits of the modes.
+const SpecialBits = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// UnixMode returns the permission bits of mode, with the special bits
+// where chmod has them, such as 04755 for a setuid executable.
+func UnixMode(mode os.FileMode) uint32 {
+	bits := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+
+	return bits
+}
+
+// FileMode returns the mode of the Unix permission bits, the inverse of
+// UnixMode.
+func FileMode(bits uint32) os.FileMode {
+	mode := os.FileMode(bits) & os.ModePerm
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+
+	return mode
+}
+
+// OctalMode returns the octal permission bits of mode, such as 0644.
+func OctalMode(mode os.FileMode) string {
+	return fmt.Sprintf("%04o", UnixMode(mode))
+}
+
+// HumanMode returns the mode of the file the way ls shows it, such as
+// drwxr-xr-x, with the special bits in the places of the execute ones.
+func (i *FileInfo) HumanMode() string {
+	mode := i.Mode
+	buf := []byte("----------")
+
+	switch {
+	case mode.IsDir():
+		buf[0] = 'd'
+	case mode&os.ModeSymlink != 0:
+		buf[0] = 'l'
+	case mode&os.ModeNamedPipe != 0:
+		buf[0] = 'p'
+	case mode&os.ModeSocket != 0:
+		buf[0] = 's'
+	case mode&os.ModeCharDevice != 0:
+		buf[0] = 'c'
+	case mode&os.ModeDevice != 0:
+		buf[0] = 'b'
+	}
+
+	const rwx = "rwxrwxrwx"
+	for n := 0; n < 9; n++ {
+		if mode&(1<<uint(8-n)) != 0 {
+			buf[n+1] = rwx[n]
+		}
+	}
+
+	special := func(pos int, set bool, c byte) {
+		if !set {
+			return
+		}
+		if buf[pos] == 'x' {
+			buf[pos] = c
+		} else {
+			buf[pos] = c - 'a' + 'A'
+		}
+	}
+	special(3, mode&os.ModeSetuid != 0, 's')
+	special(6, mode&os.ModeSetgid != 0, 's')
+	special(9, mode&os.ModeSticky != 0, 't')
+
+	return string(buf)
+}
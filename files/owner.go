@@ -0,0 +1,96 @@
+package files
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// accountLookup resolves the ids of the users and the groups to their
+// names.
+type accountLookup interface {
+	userName(id string) (string, error)
+	groupName(id string) (string, error)
+}
+
+// systemAccounts looks the ids up in the accounts of the system.
+type systemAccounts struct{}
+
+func (systemAccounts) userName(id string) (string, error) {
+	u, err := user.LookupId(id)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Username, nil
+}
+
+func (systemAccounts) groupName(id string) (string, error) {
+	g, err := user.LookupGroupId(id)
+	if err != nil {
+		return "", err
+	}
+
+	return g.Name, nil
+}
+
+// accountCache keeps the names of the ids it has looked up, so the
+// listings of the large directories don't look the same ids up for each
+// of their files. The ids that can't be resolved are kept as they are.
+type accountCache struct {
+	lookup accountLookup
+	mu     sync.Mutex
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+func newAccountCache(lookup accountLookup) *accountCache {
+	return &accountCache{
+		lookup: lookup,
+		users:  map[uint32]string{},
+		groups: map[uint32]string{},
+	}
+}
+
+// accounts resolves the owners and the groups of the files.
+var accounts = newAccountCache(systemAccounts{})
+
+func (c *accountCache) name(names map[uint32]string, id uint32, lookup func(string) (string, error)) string {
+	c.mu.Lock()
+	name, ok := names[id]
+	c.mu.Unlock()
+	if ok {
+		return name
+	}
+
+	name = strconv.FormatUint(uint64(id), 10)
+	if resolved, err := lookup(name); err == nil && resolved != "" {
+		name = resolved
+	}
+
+	c.mu.Lock()
+	names[id] = name
+	c.mu.Unlock()
+	return name
+}
+
+func (c *accountCache) userName(id uint32) string {
+	return c.name(c.users, id, c.lookup.userName)
+}
+
+func (c *accountCache) groupName(id uint32) string {
+	return c.name(c.groups, id, c.lookup.groupName)
+}
+
+// Ownership returns the names of the owner and the group of the file of
+// info, or their ids if they don't resolve. They are empty where the
+// files have none, such as on Windows.
+func Ownership(info os.FileInfo) (owner, group string) {
+	uid, gid, ok := fileIDs(info)
+	if !ok {
+		return "", ""
+	}
+
+	return accounts.userName(uid), accounts.groupName(gid)
+}
@@ -0,0 +1,127 @@
+package files
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAccounts resolves the ids of its maps and counts the lookups.
+type fakeAccounts struct {
+	mu      sync.Mutex
+	users   map[string]string
+	groups  map[string]string
+	lookups int
+}
+
+func (a *fakeAccounts) find(names map[string]string, id string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.lookups++
+	name, ok := names[id]
+	if !ok {
+		return "", errors.New("unknown id " + id)
+	}
+
+	return name, nil
+}
+
+func (a *fakeAccounts) userName(id string) (string, error) {
+	return a.find(a.users, id)
+}
+
+func (a *fakeAccounts) groupName(id string) (string, error) {
+	return a.find(a.groups, id)
+}
+
+func newFakeAccounts() *fakeAccounts {
+	return &fakeAccounts{
+		users:  map[string]string{"0": "root", "1000": "alice", "7": ""},
+		groups: map[string]string{"0": "root", "100": "users"},
+	}
+}
+
+func TestAccountCache(t *testing.T) {
+	tests := []struct {
+		name        string
+		uid, gid    uint32
+		user, group string
+	}{
+		{"resolved", 1000, 100, "alice", "users"},
+		{"root", 0, 0, "root", "root"},
+		{"unknown", 4242, 4343, "4242", "4343"},
+		{"empty name", 7, 100, "7", "users"},
+		// The ids of the users aren't the ones of the groups.
+		{"user id as group", 1000, 1000, "alice", "1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := newFakeAccounts()
+			cache := newAccountCache(lookup)
+
+			for i := 0; i < 3; i++ {
+				if got := cache.userName(tt.uid); got != tt.user {
+					t.Errorf("userName(%d) = %q, want %q", tt.uid, got, tt.user)
+				}
+				if got := cache.groupName(tt.gid); got != tt.group {
+					t.Errorf("groupName(%d) = %q, want %q", tt.gid, got, tt.group)
+				}
+			}
+
+			// The ids are looked up once, resolved or not.
+			if lookup.lookups != 2 {
+				t.Errorf("the ids were looked up %d times, want 2", lookup.lookups)
+			}
+		})
+	}
+}
+
+func TestAccountCacheConcurrent(t *testing.T) {
+	lookup := newFakeAccounts()
+	cache := newAccountCache(lookup)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := uint32(i % 5)
+			cache.userName(id)
+			cache.groupName(id)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := cache.userName(0); got != "root" {
+		t.Errorf("userName(0) = %q, want root", got)
+	}
+}
+
+func TestOwnershipWithoutIDs(t *testing.T) {
+	// The files whose Sys isn't a Stat_t, as on Windows, have no owner,
+	// and the fields are left out of the JSON.
+	owner, group := Ownership(fakeInfo("a.txt"))
+	if owner != "" || group != "" {
+		t.Errorf("Ownership() = %q, %q, want none", owner, group)
+	}
+
+	out, err := json.Marshal(&FileInfo{Name: "a.txt", Owner: owner, Group: group})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), `"owner"`) || strings.Contains(string(out), `"group"`) {
+		t.Errorf("the JSON has the owner or the group: %s", out)
+	}
+
+	out, err = json.Marshal(&FileInfo{Name: "a.txt", Owner: "alice", Group: "users"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"owner":"alice","group":"users"`) {
+		t.Errorf("the JSON doesn't have the owner and the group: %s", out)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestOwnership(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(name, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uid, gid := strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())
+	tests := []struct {
+		name         string
+		users        map[string]string
+		groups       map[string]string
+		owner, group string
+	}{
+		{"resolved", map[string]string{uid: "alice"}, map[string]string{gid: "users"}, "alice", "users"},
+		{"unresolved", nil, nil, uid, gid},
+	}
+
+	saved := accounts
+	defer func() { accounts = saved }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts = newAccountCache(&fakeAccounts{users: tt.users, groups: tt.groups})
+
+			owner, group := Ownership(info)
+			if owner != tt.owner || group != tt.group {
+				t.Errorf("Ownership() = %q, %q, want %q, %q", owner, group, tt.owner, tt.group)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package files
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/spf13/afero"
+)
+
+// RecentOptions are the options when looking for the recent files.
+type RecentOptions struct {
+	// Context stops the walk, such as when the request is canceled.
+	Context context.Context
+	Fs      afero.Fs
+	Path    string
+	Since   time.Time
+	Depth   int
+	// MaxEntries is the number of entries walked through. The listing
+	// is truncated once it is reached.
+	MaxEntries int
+	Checker    rules.Checker
+	Categories map[string]string
+}
+
+// Recent walks a directory up to Depth levels below it and returns the
+// listing of the files modified after Since, newest first. Their names
+// are their paths relative to the directory.
+func Recent(opts RecentOptions) (*Listing, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	since := opts.Since.UTC()
+	listing := &Listing{
+		Items:   []*FileInfo{},
+		Sorting: Sorting{By: "modified", Asc: false},
+	}
+	entries := 0
+
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		if err := ctx.Err(); err != nil || listing.Truncated {
+			return err
+		}
+
+		infos, err := afero.ReadDir(opts.Fs, dir)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			p := path.Join(dir, info.Name())
+			if !opts.Checker.Check(p) {
+				continue
+			}
+
+			if opts.MaxEntries > 0 && entries >= opts.MaxEntries {
+				listing.Truncated = true
+				return nil
+			}
+			entries++
+
+			if info.IsDir() {
+				// The directories below that can't be read are left out.
+				if depth < opts.Depth {
+					if err := walk(p, depth+1); err != nil && ctx.Err() != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			if !info.ModTime().UTC().After(since) {
+				continue
+			}
+
+			item := &FileInfo{
+				Fs:        opts.Fs,
+				Path:      p,
+				Name:      strings.TrimPrefix(strings.TrimPrefix(p, opts.Path), "/"),
+				Size:      info.Size(),
+				Extension: path.Ext(p),
+				ModTime:   info.ModTime(),
+				ModUnix:   info.ModTime().Unix(),
+				Mode:      info.Mode(),
+				OctalMode: OctalMode(info.Mode()),
+			}
+			item.Owner, item.Group = Ownership(info)
+
+			if err := item.Classify(opts.Categories); err == nil {
+				listing.Items = append(listing.Items, item)
+				listing.NumFiles++
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(opts.Path, 0); err != nil {
+		return nil, err
+	}
+
+	listing.ApplySort()
+	return listing, nil
+}
@@ -0,0 +1,30 @@
+package files
+
+import "os"
+
+// The kinds of the special files, which File Browser never reads: opening
+// a named pipe blocks until something writes to it, and reading a device
+// may never end.
+const (
+	SpecialPipe   = "pipe"
+	SpecialSocket = "socket"
+	SpecialDevice = "device"
+	SpecialOther  = "other"
+)
+
+// SpecialKind returns the kind of special file of mode, or an empty
+// string for the regular files, the directories and the symbolic links.
+func SpecialKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return SpecialPipe
+	case mode&os.ModeSocket != 0:
+		return SpecialSocket
+	case mode&os.ModeDevice != 0:
+		return SpecialDevice
+	case mode&os.ModeIrregular != 0:
+		return SpecialOther
+	default:
+		return ""
+	}
+}
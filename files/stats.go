@@ -0,0 +1,222 @@
+package files
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/spf13/afero"
+)
+
+// statsBuckets are the upper bounds of the buckets of the histograms of
+// the sizes, each ten times the previous one from 1 MiB. The last bucket
+// has the files of 10 GiB and more.
+var statsBuckets = []int64{1 << 20, 10 << 20, 100 << 20, 1 << 30, 10 << 30}
+
+// StatsOptions are the options of the statistics of a directory.
+type StatsOptions struct {
+	// Context stops the walk, such as when the request is canceled.
+	Context context.Context
+	Fs      afero.Fs
+	Path    string
+	// Depth is the number of levels below the directory that are walked
+	// too, none by default.
+	Depth int
+	// MaxEntries is the number of entries walked through. The statistics
+	// of the directories that weren't walked completely are approximate.
+	MaxEntries int
+	// Largest is the number of the largest files that are kept.
+	Largest    int
+	Checker    rules.Checker
+	Categories map[string]string
+}
+
+// CategoryStats are the number and the size of the files of a category.
+type CategoryStats struct {
+	Category string `json:"category"`
+	Files    int    `json:"files"`
+	Size     int64  `json:"size"`
+}
+
+// SizeBucket is a bucket of the histogram of the sizes: the files of at
+// least Min bytes and less than Max, which is zero for the last bucket.
+type SizeBucket struct {
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max,omitempty"`
+	Files int   `json:"files"`
+	Size  int64 `json:"size"`
+}
+
+// StatsFile is one of the largest files of the statistics. Path is
+// relative to the directory.
+type StatsFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Category string `json:"category"`
+}
+
+// DirStats is the profile of the files under a directory: their number
+// and size by category, the histogram of their sizes and the largest of
+// them. The categories are sorted by size, largest first.
+type DirStats struct {
+	Path       string          `json:"path"`
+	Dirs       int             `json:"dirs"`
+	Files      int             `json:"files"`
+	Size       int64           `json:"size"`
+	Categories []CategoryStats `json:"categories"`
+	Histogram  []SizeBucket    `json:"histogram"`
+	Largest    []StatsFile     `json:"largest"`
+	// Approximate is set when some of the entries under the directory
+	// couldn't be read or weren't walked.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// Stats walks a directory once, and the directories below it down to
+// Depth, and returns the statistics of their files. The files are
+// classified by their names only, so none of them is opened.
+func Stats(opts StatsOptions) (*DirStats, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	stats := &DirStats{
+		Path:       opts.Path,
+		Categories: []CategoryStats{},
+		Histogram:  make([]SizeBucket, len(statsBuckets)+1),
+		Largest:    []StatsFile{},
+	}
+
+	var min int64
+	for i, max := range statsBuckets {
+		stats.Histogram[i] = SizeBucket{Min: min, Max: max}
+		min = max
+	}
+	stats.Histogram[len(statsBuckets)] = SizeBucket{Min: min}
+
+	categories := map[string]*CategoryStats{}
+	entries := 0
+
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		infos, err := afero.ReadDir(opts.Fs, dir)
+		if err != nil {
+			if depth == 0 {
+				return err
+			}
+			stats.Approximate = true
+			return nil
+		}
+
+		for _, info := range infos {
+			p := path.Join(dir, info.Name())
+			if !opts.Checker.Check(p) {
+				continue
+			}
+
+			if opts.MaxEntries > 0 && entries >= opts.MaxEntries {
+				stats.Approximate = true
+				return nil
+			}
+			entries++
+
+			if info.IsDir() {
+				stats.Dirs++
+				if depth < opts.Depth {
+					if err := walk(p, depth+1); err != nil {
+						return err
+					}
+				} else if opts.Depth > 0 {
+					stats.Approximate = true
+				}
+				continue
+			}
+
+			file := &FileInfo{
+				Name:      info.Name(),
+				Size:      info.Size(),
+				Mode:      info.Mode(),
+				Extension: filepath.Ext(info.Name()),
+			}
+			if file.Special = SpecialKind(file.Mode); file.Special != "" {
+				file.Type = "blob"
+			} else {
+				file.detectTypeByExtension()
+			}
+			file.detectCategory(opts.Categories)
+
+			stats.add(categories, file, strings.TrimPrefix(strings.TrimPrefix(p, opts.Path), "/"), opts.Largest)
+		}
+
+		return nil
+	}
+
+	if err := walk(opts.Path, 0); err != nil {
+		return nil, err
+	}
+
+	for _, category := range categories {
+		stats.Categories = append(stats.Categories, *category)
+	}
+
+	sort.Slice(stats.Categories, func(i, j int) bool {
+		a, b := stats.Categories[i], stats.Categories[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Category < b.Category
+	})
+
+	return stats, nil
+}
+
+// add counts a file in the statistics, and keeps it if it's one of the
+// largest.
+func (s *DirStats) add(categories map[string]*CategoryStats, file *FileInfo, rel string, largest int) {
+	s.Files++
+	s.Size += file.Size
+
+	category, ok := categories[file.Category]
+	if !ok {
+		category = &CategoryStats{Category: file.Category}
+		categories[file.Category] = category
+	}
+	category.Files++
+	category.Size += file.Size
+
+	bucket := &s.Histogram[len(s.Histogram)-1]
+	for i := range s.Histogram {
+		if s.Histogram[i].Max > file.Size {
+			bucket = &s.Histogram[i]
+			break
+		}
+	}
+	bucket.Files++
+	bucket.Size += file.Size
+
+	if largest <= 0 {
+		return
+	}
+
+	// The largest files are kept sorted, so a file smaller than all of
+	// them is dropped once there are enough.
+	i := sort.Search(len(s.Largest), func(i int) bool {
+		return s.Largest[i].Size < file.Size
+	})
+	if i == largest {
+		return
+	}
+
+	if len(s.Largest) < largest {
+		s.Largest = append(s.Largest, StatsFile{})
+	}
+	copy(s.Largest[i+1:], s.Largest[i:])
+	s.Largest[i] = StatsFile{Path: rel, Size: file.Size, Category: file.Category}
+}
@@ -0,0 +1,104 @@
+package files
+
+import (
+	"path"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/spf13/afero"
+)
+
+// TreeNode is an entry of a directory tree.
+type TreeNode struct {
+	Name        string      `json:"name"`
+	Path        string      `json:"path"`
+	IsDir       bool        `json:"isDir"`
+	ChildCount  int         `json:"childCount"`
+	HasChildren bool        `json:"hasChildren"`
+	Truncated   bool        `json:"truncated,omitempty"`
+	Children    []*TreeNode `json:"children,omitempty"`
+}
+
+// TreeOptions are the options when building a directory tree.
+type TreeOptions struct {
+	Fs       afero.Fs
+	Path     string
+	Depth    int
+	MaxNodes int
+	Files    bool
+	Checker  rules.Checker
+}
+
+// NewTree builds the tree of the directories under a path. The tree is
+// walked breadth first so, once MaxNodes is reached, the deepest
+// branches are the ones left out. Branches that are not fully shown
+// are marked as truncated.
+func NewTree(opts TreeOptions) (*TreeNode, error) {
+	type queued struct {
+		node  *TreeNode
+		depth int
+	}
+
+	root := &TreeNode{
+		Name:  path.Base(opts.Path),
+		Path:  opts.Path,
+		IsDir: true,
+	}
+
+	nodes := 1
+	queue := []queued{{root, 0}}
+
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+
+		infos, err := afero.ReadDir(opts.Fs, item.node.Path)
+		if err != nil {
+			if item.node == root {
+				return nil, err
+			}
+
+			continue
+		}
+
+		for _, info := range infos {
+			if !info.IsDir() && !opts.Files {
+				continue
+			}
+
+			p := path.Join(item.node.Path, info.Name())
+			if !opts.Checker.Check(p) {
+				continue
+			}
+
+			item.node.ChildCount++
+			if item.depth >= opts.Depth {
+				continue
+			}
+
+			if nodes >= opts.MaxNodes {
+				item.node.Truncated = true
+				continue
+			}
+
+			child := &TreeNode{
+				Name:  info.Name(),
+				Path:  p,
+				IsDir: info.IsDir(),
+			}
+
+			nodes++
+			item.node.Children = append(item.node.Children, child)
+
+			if info.IsDir() {
+				queue = append(queue, queued{child, item.depth + 1})
+			}
+		}
+
+		item.node.HasChildren = item.node.ChildCount > 0
+		if item.depth >= opts.Depth && item.node.HasChildren {
+			item.node.Truncated = true
+		}
+	}
+
+	return root, nil
+}
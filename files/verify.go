@@ -0,0 +1,313 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/spf13/afero"
+)
+
+// verifyWorkers is the number of files hashed at the same time when
+// verifying sums.
+const verifyWorkers = 4
+
+// The states of the files of a verification.
+const (
+	VerifyOK         = "ok"
+	VerifyMismatch   = "mismatch"
+	VerifyMissing    = "missing"
+	VerifyUnreadable = "unreadable"
+)
+
+// digestAlgorithms are the algorithms of the digests by their length in
+// hex.
+var digestAlgorithms = map[int]string{32: "md5", 40: "sha1", 64: "sha256", 128: "sha512"}
+
+// Sum is a line of a sums file, such as SHA256SUMS: the digest, in hex,
+// of the file at Path, relative to the directory of the file.
+type Sum struct {
+	Path   string
+	Algo   string
+	Digest string
+}
+
+// ParseSums parses a sums file in the format of sha256sum and the like,
+// with the files in text or binary mode, or in the BSD format of their
+// --tag option. The algorithm of each line comes from the length of its
+// digest. The blank lines and the comments are skipped.
+func ParseSums(r io.Reader) ([]Sum, error) {
+	var sums []Sum
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sum, err := parseSum(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		sums = append(sums, sum)
+	}
+
+	return sums, scanner.Err()
+}
+
+func parseSum(line string) (Sum, error) {
+	// The names with a backslash or a newline are escaped, and their
+	// lines start with a backslash.
+	escaped := strings.HasPrefix(line, "\\")
+	if escaped {
+		line = line[1:]
+	}
+
+	var sum Sum
+	if open := strings.Index(line, " ("); open > 0 && strings.Contains(line, ") = ") {
+		end := strings.LastIndex(line, ") = ")
+		sum.Algo = strings.ToLower(strings.ReplaceAll(line[:open], "-", ""))
+		sum.Path = line[open+2 : end]
+		sum.Digest = line[end+4:]
+	} else {
+		space := strings.Index(line, " ")
+		if space < 0 {
+			return sum, fmt.Errorf("a digest and a name are expected")
+		}
+		digest, name := line[:space], line[space+1:]
+		if strings.HasPrefix(name, " ") || strings.HasPrefix(name, "*") {
+			name = name[1:]
+		}
+		sum.Path, sum.Digest = name, digest
+	}
+
+	sum.Digest = strings.ToLower(sum.Digest)
+	if _, err := hex.DecodeString(sum.Digest); err != nil {
+		return sum, fmt.Errorf("invalid digest %q", sum.Digest)
+	}
+
+	algo, ok := digestAlgorithms[len(sum.Digest)]
+	if !ok || (sum.Algo != "" && sum.Algo != algo) {
+		return sum, fmt.Errorf("the digest %q isn't a md5, sha1, sha256 or sha512 one", sum.Digest)
+	}
+	sum.Algo = algo
+
+	if escaped {
+		sum.Path = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(sum.Path)
+	}
+
+	if sum.Path == "" {
+		return sum, fmt.Errorf("the name is missing")
+	}
+
+	return sum, nil
+}
+
+// VerifyOptions are the options of a verification.
+type VerifyOptions struct {
+	// Context cancels the verification, such as when the request is.
+	Context context.Context
+	Fs      afero.Fs
+	Path    string
+	Sums    []Sum
+	// Depth and MaxFiles bound the walk of the directory for the files
+	// that aren't in the sums.
+	Depth    int
+	MaxFiles int
+	Checker  rules.Checker
+	// Done, if set, is called once each sum is verified.
+	Done func()
+}
+
+// VerifyResult is the state of a file of a sums file. Actual is only set
+// for the mismatches.
+type VerifyResult struct {
+	Path     string `json:"path"`
+	Status   string `json:"status"`
+	Algo     string `json:"algo"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Verification is the report of a verification of the files of a
+// directory against a sums file. Extra are the files of the directory
+// that aren't in the sums, which are truncated at MaxFiles.
+type Verification struct {
+	Results    []VerifyResult `json:"results"`
+	Extra      []string       `json:"extra"`
+	OK         int            `json:"ok"`
+	Mismatched int            `json:"mismatched"`
+	Missing    int            `json:"missing"`
+	Unreadable int            `json:"unreadable"`
+	Truncated  bool           `json:"truncated,omitempty"`
+}
+
+// Verify checks the files of a directory against their sums. The files
+// are hashed a few at a time.
+func Verify(opts VerifyOptions) (*Verification, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	done := opts.Done
+	if done == nil {
+		done = func() {}
+	}
+
+	res := &Verification{Results: make([]VerifyResult, len(opts.Sums)), Extra: []string{}}
+	listed := map[string]bool{}
+	var hashed []int
+
+	for i, sum := range opts.Sums {
+		result := &res.Results[i]
+		*result = VerifyResult{Path: sum.Path, Algo: sum.Algo, Expected: sum.Digest}
+
+		name := path.Clean(sum.Path)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			result.Status, result.Error = VerifyUnreadable, "the file is outside the directory"
+			done()
+			continue
+		}
+
+		name = path.Join(opts.Path, name)
+		listed[name] = true
+
+		// The files the user can't see are missing for them.
+		if !opts.Checker.Check(name) {
+			result.Status = VerifyMissing
+			done()
+			continue
+		}
+
+		info, err := opts.Fs.Stat(name)
+		switch {
+		case os.IsNotExist(err):
+			result.Status = VerifyMissing
+		case err != nil:
+			result.Status, result.Error = VerifyUnreadable, err.Error()
+		case !info.Mode().IsRegular():
+			result.Status, result.Error = VerifyUnreadable, "not a regular file"
+		case isRemote(opts.Fs) && info.Size() > remoteChecksumLimit:
+			result.Status, result.Error = VerifyUnreadable, errors.ErrTooLarge.Error()
+		default:
+			hashed = append(hashed, i)
+			continue
+		}
+		done()
+	}
+
+	var wg sync.WaitGroup
+	queue := make(chan int)
+	for i := 0; i < verifyWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				// Each worker has its own results, so they aren't locked.
+				result := &res.Results[i]
+				digest, err := hashFile(ctx, opts.Fs, path.Join(opts.Path, path.Clean(result.Path)), result.Algo)
+				switch {
+				case err != nil:
+					result.Status, result.Error = VerifyUnreadable, err.Error()
+				case digest != result.Expected:
+					result.Status, result.Actual = VerifyMismatch, digest
+				default:
+					result.Status = VerifyOK
+				}
+				done()
+			}
+		}()
+	}
+
+	for _, i := range hashed {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := walkExtra(ctx, opts, opts.Path, 0, listed, res); err != nil {
+		return nil, err
+	}
+	sort.Strings(res.Extra)
+
+	for _, result := range res.Results {
+		switch result.Status {
+		case VerifyOK:
+			res.OK++
+		case VerifyMismatch:
+			res.Mismatched++
+		case VerifyMissing:
+			res.Missing++
+		case VerifyUnreadable:
+			res.Unreadable++
+		}
+	}
+
+	return res, nil
+}
+
+// walkExtra adds the files under dir that aren't listed to the extra
+// files of res.
+func walkExtra(ctx context.Context, opts VerifyOptions, dir string, depth int, listed map[string]bool, res *Verification) error {
+	if err := ctx.Err(); err != nil || res.Truncated {
+		return err
+	}
+
+	infos, err := afero.ReadDir(opts.Fs, dir)
+	if err != nil {
+		// The directories below that can't be read are left out.
+		if depth > 0 {
+			return nil
+		}
+		return err
+	}
+
+	for _, info := range infos {
+		p := path.Join(dir, info.Name())
+		if !opts.Checker.Check(p) {
+			continue
+		}
+
+		if info.IsDir() {
+			if depth < opts.Depth {
+				if err := walkExtra(ctx, opts, p, depth+1, listed, res); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if !info.Mode().IsRegular() || listed[p] {
+			continue
+		}
+
+		if opts.MaxFiles > 0 && len(res.Extra) >= opts.MaxFiles {
+			res.Truncated = true
+			return nil
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, opts.Path), "/")
+		res.Extra = append(res.Extra, rel)
+	}
+
+	return nil
+}
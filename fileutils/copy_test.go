@@ -0,0 +1,65 @@
+package fileutils
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestCopyKeepsModesAndTimes(t *testing.T) {
+	old := time.Date(2019, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		path string
+		mode os.FileMode
+		time time.Time
+	}{
+		{"file", "/src/a.txt", 0600, old},
+		{"executable", "/src/sub/b.sh", 0755, old.Add(time.Hour)},
+		{"hidden file", "/src/.hidden", 0644, old.Add(2 * time.Hour)},
+		{"directory", "/src/sub", os.ModeDir | 0750, old.Add(3 * time.Hour)},
+		{"root", "/src", os.ModeDir | 0700, old.Add(4 * time.Hour)},
+	}
+
+	fs := afero.NewMemMapFs()
+	for _, name := range []string{"/src/a.txt", "/src/sub/b.sh", "/src/.hidden"} {
+		if err := afero.WriteFile(fs, name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The deepest first, since the writes change the times of the
+	// directories.
+	for i := len(tests) - 1; i >= 0; i-- {
+		tt := tests[i]
+		if err := fs.Chmod(tt.path, tt.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Chtimes(tt.path, tt.time, tt.time); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Copy(fs, "/src", "/dst"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := "/dst" + tt.path[len("/src"):]
+			info, err := fs.Stat(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.Mode() != tt.mode {
+				t.Errorf("%s has mode %v, want %v", p, info.Mode(), tt.mode)
+			}
+			if !info.ModTime().Equal(tt.time) {
+				t.Errorf("%s was modified at %v, want %v", p, info.ModTime(), tt.time)
+			}
+		})
+	}
+}
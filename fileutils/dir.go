@@ -7,8 +7,9 @@ import (
 )
 
 // CopyDir copies a directory from source to dest and all
-// of its sub-directories. It doesn't stop if it finds an error
-// during the copy. Returns an error if any.
+// of its sub-directories, with their modification times. It
+// doesn't stop if it finds an error during the copy. Returns
+// an error if any.
 func CopyDir(fs afero.Fs, source string, dest string) error {
 	// Get properties of source.
 	srcinfo, err := fs.Stat(source)
@@ -58,5 +59,6 @@ func CopyDir(fs afero.Fs, source string, dest string) error {
 		return errors.New(errString)
 	}
 
-	return nil
+	// The copies of the contents changed the time of the directory.
+	return fs.Chtimes(dest, srcinfo.ModTime(), srcinfo.ModTime())
 }
@@ -2,14 +2,27 @@ package fileutils
 
 import (
 	"io"
+	"path"
 	"path/filepath"
 
 	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
 )
 
-// CopyFile copies a file from source to dest and returns
-// an error if any.
+// CopyFile copies a file from source to dest, with its mode and its
+// modification time, and returns an error if any.
 func CopyFile(fs afero.Fs, source string, dest string) error {
+	// The special files, such as the named pipes, can't be copied.
+	info, err := fs.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return errors.ErrSpecialFile
+	}
+
 	// Open the source file.
 	src, err := fs.Open(source)
 	if err != nil {
@@ -19,7 +32,7 @@ func CopyFile(fs afero.Fs, source string, dest string) error {
 
 	// Makes the directory needed to create the dst
 	// file.
-	err = fs.MkdirAll(filepath.Dir(dest), 0666)
+	err = fs.MkdirAll(path.Dir(filepath.ToSlash(dest)), 0666)
 	if err != nil {
 		return err
 	}
@@ -29,23 +42,21 @@ func CopyFile(fs afero.Fs, source string, dest string) error {
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
 
 	// Copy the contents of the file.
 	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return err
 	}
 
-	// Copy the mode if the user can't
-	// open the file.
-	info, err := fs.Stat(source)
-	if err != nil {
-		err = fs.Chmod(dest, info.Mode())
-		if err != nil {
-			return err
-		}
+	// The times are set once the file is closed, since the writes change
+	// them.
+	if err := fs.Chmod(dest, info.Mode()); err != nil {
+		return err
 	}
 
-	return nil
+	return fs.Chtimes(dest, info.ModTime(), info.ModTime())
 }
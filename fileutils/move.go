@@ -0,0 +1,39 @@
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// Move renames src to dst or, when they are on different devices, moves
+// it with MoveByCopy.
+func Move(fs afero.Fs, src, dst string) error {
+	err := fs.Rename(src, dst)
+	if errors.Is(err, syscall.EXDEV) {
+		return MoveByCopy(fs, src, dst)
+	}
+
+	return err
+}
+
+// MoveByCopy copies src to dst, checks the copy against the original and
+// only then removes src. When the copy fails, the partial copy is removed
+// and the original is kept.
+func MoveByCopy(fs afero.Fs, src, dst string) error {
+	err := Copy(fs, src, dst)
+	if err == nil {
+		err = Verify(fs, src, dst)
+	}
+
+	if err != nil {
+		if removeErr := fs.RemoveAll(dst); removeErr != nil {
+			return fmt.Errorf("%w, and the partial copy couldn't be removed: %v", err, removeErr)
+		}
+		return err
+	}
+
+	return fs.RemoveAll(src)
+}
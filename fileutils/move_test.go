@@ -0,0 +1,135 @@
+package fileutils
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// devicesFs is an afero.Fs whose renames fail as they do between devices,
+// and where no file can be created under /full/y.
+type devicesFs struct {
+	afero.Fs
+	renames int
+}
+
+func (fs *devicesFs) Rename(oldname, newname string) error {
+	fs.renames++
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: syscall.EXDEV}
+}
+
+func (fs *devicesFs) Create(name string) (afero.File, error) {
+	if strings.HasPrefix(name, "/full/y/") {
+		return nil, &os.PathError{Op: "create", Path: name, Err: syscall.ENOSPC}
+	}
+
+	return fs.Fs.Create(name)
+}
+
+// tree returns the paths and contents of the files of fs.
+func tree(t *testing.T, fs afero.Fs) string {
+	t.Helper()
+
+	var lines []string
+	err := afero.Walk(fs, "/", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		line := p
+		if !info.IsDir() {
+			content, err := afero.ReadFile(fs, p)
+			if err != nil {
+				return err
+			}
+			line += "=" + string(content)
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, " ")
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		devices  bool
+		src, dst string
+		want     string
+		err      bool
+	}{
+		{"file", false, "/a/x.txt", "/b/x.txt", "/ /a /a/y /a/y/z.txt=z /b /b/x.txt=x", false},
+		{"file across devices", true, "/a/x.txt", "/b/x.txt", "/ /a /a/y /a/y/z.txt=z /b /b/x.txt=x", false},
+		{"directory across devices", true, "/a", "/b/a", "/ /b /b/a /b/a/x.txt=x /b/a/y /b/a/y/z.txt=z", false},
+		// The copy of z.txt can't be created, so the original is kept and
+		// the partial copy, with x.txt, is removed.
+		{"failed copy across devices", true, "/a", "/full", "/ /a /a/x.txt=x /a/y /a/y/z.txt=z /b", true},
+		{"missing", true, "/missing", "/b/missing", "/ /a /a/x.txt=x /a/y /a/y/z.txt=z /b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := afero.NewMemMapFs()
+			for name, content := range map[string]string{"/a/x.txt": "x", "/a/y/z.txt": "z"} {
+				if err := afero.WriteFile(mem, name, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := mem.Mkdir("/b", 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			var fs afero.Fs = mem
+			devices := &devicesFs{Fs: mem}
+			if tt.devices {
+				fs = devices
+			}
+
+			err := Move(fs, tt.src, tt.dst)
+			if (err != nil) != tt.err {
+				t.Fatalf("Move() = %v, want an error: %v", err, tt.err)
+			}
+			if tt.devices && devices.renames != 1 {
+				t.Errorf("Move() renamed %d times, want once", devices.renames)
+			}
+			if got := tree(t, mem); got != tt.want {
+				t.Errorf("the files are %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveOtherErrors(t *testing.T) {
+	// Only the renames between devices fall back to copies.
+	fs := &errorFs{Fs: afero.NewMemMapFs(), err: syscall.EACCES}
+	if err := afero.WriteFile(fs, "/a.txt", []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Move(fs, "/a.txt", "/b.txt"); !errors.Is(err, syscall.EACCES) {
+		t.Errorf("Move() = %v, want EACCES", err)
+	}
+	if ok, _ := afero.Exists(fs, "/b.txt"); ok {
+		t.Error("the file was copied")
+	}
+}
+
+// errorFs is an afero.Fs whose renames fail with err.
+type errorFs struct {
+	afero.Fs
+	err error
+}
+
+func (fs *errorFs) Rename(oldname, newname string) error {
+	return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.err}
+}
@@ -0,0 +1,99 @@
+package fileutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+
+	"github.com/spf13/afero"
+)
+
+// ErrMismatch is returned by Verify when a copy differs from its source.
+var ErrMismatch = errors.New("the copy differs from the original")
+
+// verifyChunk is how much of the files is compared at once.
+const verifyChunk = 64 << 10
+
+// Verify checks that dst is a copy of src: the same file or the same
+// folder, with the same files and contents. The files are compared as
+// they are read, so they are never loaded whole.
+func Verify(fs afero.Fs, src, dst string) error {
+	srcInfo, err := fs.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	dstInfo, err := fs.Stat(dst)
+	if err != nil {
+		return err
+	}
+
+	if srcInfo.IsDir() != dstInfo.IsDir() {
+		return ErrMismatch
+	}
+
+	if !srcInfo.IsDir() {
+		if srcInfo.Size() != dstInfo.Size() {
+			return ErrMismatch
+		}
+		return compareFiles(fs, src, dst)
+	}
+
+	dir, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := Verify(fs, path.Join(src, name), path.Join(dst, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compareFiles(fs afero.Fs, src, dst string) error {
+	a, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	b, err := fs.Open(dst)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	bufA := make([]byte, verifyChunk)
+	bufB := make([]byte, verifyChunk)
+	for {
+		n, errA := io.ReadFull(a, bufA)
+		m, errB := io.ReadFull(b, bufB)
+		if n != m || !bytes.Equal(bufA[:n], bufB[:m]) {
+			return ErrMismatch
+		}
+
+		switch {
+		case errA == io.EOF || errA == io.ErrUnexpectedEOF:
+			if errB != io.EOF && errB != io.ErrUnexpectedEOF {
+				return ErrMismatch
+			}
+			return nil
+		case errA != nil:
+			return errA
+		case errB != nil:
+			if errB == io.EOF || errB == io.ErrUnexpectedEOF {
+				return ErrMismatch
+			}
+			return errB
+		}
+	}
+}
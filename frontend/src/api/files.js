@@ -10,6 +10,8 @@ export async function fetch (url) {
   if (res.status === 200) {
     let data = await res.json()
     data.url = `/files${url}`
+    // The edits send it back, so they can't replace newer versions.
+    data.etag = res.headers.get('ETag')
 
     if (data.isDir) {
       if (!data.url.endsWith('/')) data.url += '/'
@@ -31,10 +33,10 @@ export async function fetch (url) {
   }
 }
 
-async function resourceAction (url, method, content) {
+async function resourceAction (url, method, content, headers = {}) {
   url = removePrefix(url)
 
-  let opts = { method }
+  let opts = { method, headers }
 
   if (content) {
     opts.body = content
@@ -42,8 +44,10 @@ async function resourceAction (url, method, content) {
 
   const res = await fetchURL(`/api/resources${url}`, opts)
 
-  if (res.status !== 200) {
-    throw new Error(res.responseText)
+  if (res.status !== 200 && res.status !== 201 && res.status !== 204) {
+    let err = new Error(res.responseText)
+    err.status = res.status
+    throw err
   } else {
     return res
   }
@@ -53,8 +57,8 @@ export async function remove (url) {
   return resourceAction(url, 'DELETE')
 }
 
-export async function put (url, content = '') {
-  return resourceAction(url, 'PUT', content)
+export async function put (url, content = '', etag = null) {
+  return resourceAction(url, 'PUT', content, etag ? { 'If-Match': etag } : {})
 }
 
 export function download (format, ...files) {
@@ -139,5 +143,5 @@ export function copy (items) {
 
 export async function checksum (url, algo) {
   const data = await resourceAction(`${url}?checksum=${algo}`, 'GET')
-  return (await data.json()).checksums[algo]
+  return (await data.json()).checksum
 }
@@ -23,6 +23,12 @@ import zhTW from './zh-tw.json'
 Vue.use(VueI18n)
 
 export function detectLocale () {
+  // The server picks the locale from the Accept-Language header
+  // or the lang query parameter.
+  if (window.FileBrowser.Locale) {
+    return window.FileBrowser.Locale
+  }
+
   let locale = (navigator.language || navigator.browserLangugae).toLowerCase()
   switch (true) {
     case /^ar.*/i.test(locale):
@@ -7,6 +7,22 @@ function removeLastDir (url) {
   return arr.join('/')
 }
 
+// encodePath escapes every segment of a path, keeping the slashes
+// so names with '%', '#' or '?' can be used in links.
+function encodePath (str) {
+  return str.split('/').map(v => encodeURIComponent(v)).join('/')
+}
+
+// decodeSegment decodes a segment of a route path. The router
+// might have decoded it already, so a stray '%' is left as is.
+function decodeSegment (str) {
+  try {
+    return decodeURIComponent(str)
+  } catch (e) {
+    return str
+  }
+}
+
 // this code borrow from mozilla
 // https://developer.mozilla.org/en-US/docs/Web/JavaScript/Reference/Global_Objects/encodeURIComponent#Examples
 function encodeRFC5987ValueChars(str) {
@@ -22,5 +38,7 @@ function encodeRFC5987ValueChars(str) {
 
 export default {
   encodeRFC5987ValueChars: encodeRFC5987ValueChars,
+  encodePath: encodePath,
+  decodeSegment: decodeSegment,
   removeLastDir: removeLastDir
 }
@@ -0,0 +1,279 @@
+// Package gitstatus reads the status of the files of the directories
+// that are in git work trees with the git command. It fails when git
+// isn't installed or the directory isn't in a work tree, which the
+// callers ignore.
+//
+// The users can write the files of the work trees, so git is only run
+// on the repositories in their scopes, and without the settings of the
+// repositories that run commands: the file system monitor and the
+// hooks.
+package gitstatus
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The statuses of the files. A directory has the status of its files,
+// or StatusModified if they have different ones.
+const (
+	StatusModified   = "modified"
+	StatusAdded      = "added"
+	StatusDeleted    = "deleted"
+	StatusRenamed    = "renamed"
+	StatusUntracked  = "untracked"
+	StatusConflicted = "conflicted"
+)
+
+const (
+	// timeout bounds the time git can take for a directory.
+	timeout = 2 * time.Second
+	// maxOutput bounds the output of git read for a directory. The
+	// directories with more changes aren't annotated.
+	maxOutput = 1 << 20
+	// cacheTTL is how long the status of a directory is cached.
+	cacheTTL = 3 * time.Second
+	// maxCached bounds the number of directories cached.
+	maxCached = 256
+)
+
+// ErrTooLarge is returned for the directories whose status is longer
+// than git is allowed to write.
+var ErrTooLarge = errors.New("the git status is too large")
+
+// ErrNoRepository is returned for the directories that aren't in a
+// repository under the root they're read in.
+var ErrNoRepository = errors.New("not in a git repository")
+
+// Status is the status of the entries of a directory.
+type Status struct {
+	// Branch is the current branch, or HEAD if it is detached.
+	Branch string
+	// Entries are the statuses of the entries of the directory with
+	// changes, by name.
+	Entries map[string]string
+}
+
+type cached struct {
+	status  *Status
+	err     error
+	expires time.Time
+}
+
+var cache = struct {
+	sync.Mutex
+	m map[string]cached
+}{m: map[string]cached{}}
+
+// Of returns the status of the entries of dir, which is a local path
+// under root. The repository must be under root too: the ones above it
+// are never read. The results are cached for a few seconds.
+func Of(root, dir string) (*Status, error) {
+	now := time.Now()
+	key := root + "\x00" + dir
+
+	cache.Lock()
+	c, ok := cache.m[key]
+	cache.Unlock()
+	if ok && now.Before(c.expires) {
+		return c.status, c.err
+	}
+
+	status, err := read(root, dir)
+
+	cache.Lock()
+	if len(cache.m) >= maxCached {
+		cache.m = map[string]cached{}
+	}
+	cache.m[key] = cached{status: status, err: err, expires: now.Add(cacheTTL)}
+	cache.Unlock()
+
+	return status, err
+}
+
+func read(root, dir string) (*Status, error) {
+	top, err := workTree(root, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := filepath.Rel(top, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix = filepath.ToSlash(prefix); prefix == "." {
+		prefix = ""
+	} else {
+		prefix += "/"
+	}
+
+	out, err := git(top, dir, "status", "--porcelain", "-z", "--branch", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(out, prefix), nil
+}
+
+// workTree returns the work tree dir is in, the nearest of its parents,
+// up to root, with a .git directory. The .git files and links, which
+// can point anywhere, aren't followed.
+func workTree(root, dir string) (string, error) {
+	root, dir = filepath.Clean(root), filepath.Clean(dir)
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrNoRepository
+	}
+
+	for top := dir; ; top = filepath.Dir(top) {
+		if info, err := os.Lstat(filepath.Join(top, ".git")); err == nil && info.IsDir() {
+			return top, nil
+		}
+
+		if top == root || top == filepath.Dir(top) {
+			return "", ErrNoRepository
+		}
+	}
+}
+
+// git runs git in dir, in the work tree at top. The repository is given,
+// so git doesn't look for one above top, and the settings that run
+// commands are overridden.
+func git(top, dir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	out := &limitedBuffer{max: maxOutput}
+	args = append([]string{
+		"-c", "core.fsmonitor=",
+		"-c", "core.hooksPath=" + os.DevNull,
+		"-C", dir,
+	}, args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdout = out
+	// git status doesn't refresh the index then, so it never holds its
+	// lock while the users commit.
+	cmd.Env = append(os.Environ(),
+		"GIT_OPTIONAL_LOCKS=0",
+		"GIT_DIR="+filepath.Join(top, ".git"),
+		"GIT_WORK_TREE="+top,
+		"GIT_CEILING_DIRECTORIES="+filepath.Dir(top),
+	)
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	if out.full {
+		return nil, ErrTooLarge
+	}
+
+	return out.Bytes(), nil
+}
+
+// parse parses the output of git status --porcelain -z --branch, whose
+// paths are relative to the root of the work tree, for the directory at
+// prefix in it.
+func parse(out []byte, prefix string) *Status {
+	status := &Status{Entries: map[string]string{}}
+
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.HasPrefix(field, "## ") {
+			status.Branch = branch(strings.TrimPrefix(field, "## "))
+			continue
+		}
+
+		if len(field) < 4 {
+			continue
+		}
+
+		code, p := field[:2], field[3:]
+		// The renames and copies are followed by their source.
+		if code[0] == 'R' || code[0] == 'C' {
+			i++
+		}
+
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(p, prefix)
+		if j := strings.Index(name, "/"); j >= 0 {
+			name = name[:j]
+		}
+
+		if name == "" {
+			continue
+		}
+
+		s := describe(code)
+		if previous, ok := status.Entries[name]; ok && previous != s {
+			s = StatusModified
+		}
+		status.Entries[name] = s
+	}
+
+	return status
+}
+
+// branch returns the branch of the branch line of git status, such as
+// "main...origin/main [ahead 1]" or "No commits yet on main".
+func branch(line string) string {
+	line = strings.TrimPrefix(line, "No commits yet on ")
+	line = strings.TrimPrefix(line, "Initial commit on ")
+	if i := strings.Index(line, "..."); i >= 0 {
+		line = line[:i]
+	}
+
+	if i := strings.Index(line, " "); i >= 0 {
+		line = line[:i]
+	}
+
+	return line
+}
+
+// describe returns the status of the two letters code of git status.
+func describe(code string) string {
+	switch {
+	case code == "??":
+		return StatusUntracked
+	case code[0] == 'U' || code[1] == 'U' || code == "AA" || code == "DD":
+		return StatusConflicted
+	case code[0] == 'A':
+		return StatusAdded
+	case code[0] == 'R' || code[0] == 'C':
+		return StatusRenamed
+	case code[0] == 'D' || code[1] == 'D':
+		return StatusDeleted
+	default:
+		return StatusModified
+	}
+}
+
+// limitedBuffer keeps the first max bytes written to it, and discards
+// the others.
+type limitedBuffer struct {
+	bytes.Buffer
+	max  int
+	full bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := b.max - b.Len(); n > room {
+		b.full = true
+		p = p[:room]
+	}
+
+	b.Buffer.Write(p)
+	return n, nil
+}
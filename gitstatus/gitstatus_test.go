@@ -0,0 +1,140 @@
+package gitstatus
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkTree(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{
+		"scope/repo/.git",
+		"scope/repo/sub/deep",
+		"scope/plain",
+		"scope/linked",
+		"outer/.git",
+		"outer/scope/dir",
+	} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A .git file can point at any repository, and a link anywhere.
+	if err := os.WriteFile(filepath.Join(base, "scope/plain/.git"), []byte("gitdir: "+base+"/outer/.git"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "outer/.git"), filepath.Join(base, "scope/linked/.git")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		root string
+		dir  string
+		want string
+	}{
+		{"root of the repository", "scope", "scope/repo", "scope/repo"},
+		{"directory in the repository", "scope", "scope/repo/sub/deep", "scope/repo"},
+		{"repository is the root", "scope/repo", "scope/repo/sub", "scope/repo"},
+		{"repository above the root", "outer/scope", "outer/scope/dir", ""},
+		{"git file", "scope", "scope/plain", ""},
+		{"git link", "scope", "scope/linked", ""},
+		{"directory outside the root", "scope/repo", "outer/scope", ""},
+		{"sibling of the root", "scope/repo", "scope/repository", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := workTree(filepath.Join(base, tt.root), filepath.Join(base, tt.dir))
+			if tt.want == "" {
+				if err != ErrNoRepository {
+					t.Fatalf("workTree() = %q, %v, want ErrNoRepository", got, err)
+				}
+				return
+			}
+
+			if want := filepath.Join(base, tt.want); err != nil || got != want {
+				t.Fatalf("workTree() = %q, %v, want %q", got, err, want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	out := "## main...origin/main [ahead 1]\x00" +
+		" M sub/a.txt\x00" +
+		"?? sub/new/\x00" +
+		"A  sub/dir/added.txt\x00" +
+		" D sub/dir/gone.txt\x00" +
+		"R  sub/moved.txt\x00sub/old.txt\x00" +
+		"UU sub/conflict.txt\x00" +
+		" M other/b.txt\x00"
+
+	status := parse([]byte(out), "sub/")
+	if status.Branch != "main" {
+		t.Errorf("Branch = %q, want main", status.Branch)
+	}
+
+	want := map[string]string{
+		"a.txt":        StatusModified,
+		"new":          StatusUntracked,
+		"dir":          StatusModified,
+		"moved.txt":    StatusRenamed,
+		"conflict.txt": StatusConflicted,
+	}
+	if len(status.Entries) != len(want) {
+		t.Errorf("Entries = %v, want %v", status.Entries, want)
+	}
+	for name, s := range want {
+		if status.Entries[name] != s {
+			t.Errorf("Entries[%q] = %q, want %q", name, status.Entries[name], s)
+		}
+	}
+}
+
+func TestOfIgnoresFsmonitor(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	root := t.TempDir()
+	marker := filepath.Join(root, "ran")
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	config := "[core]\n\trepositoryformatversion = 0\n\tfsmonitor = touch " + marker + "\n"
+	for name, content := range map[string]string{
+		"HEAD":   "ref: refs/heads/main\n",
+		"config": config,
+	} {
+		if err := os.WriteFile(filepath.Join(root, ".git", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{"objects", "refs/heads"} {
+		if err := os.MkdirAll(filepath.Join(root, ".git", dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := Of(root, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status.Entries["a.txt"] != StatusUntracked {
+		t.Errorf("Entries = %v, want a.txt untracked", status.Entries)
+	}
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("the fsmonitor of the repository was run")
+	}
+}
@@ -0,0 +1,177 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/logging"
+)
+
+// WithAccessLog makes the handler write a line of JSON to w for every
+// request it serves. The lines are written in the background and
+// buffered, so Close must be called to flush them.
+func WithAccessLog(w io.Writer) Option {
+	return func(h *Handler) {
+		h.accessLog = newAccessLog(w)
+	}
+}
+
+// accessEntry is a line of the access log. Kind tells the listings, the
+// downloads, the writes and the other requests apart.
+type accessEntry struct {
+	Time     time.Time `json:"time"`
+	Request  string    `json:"request,omitempty"`
+	Client   string    `json:"client"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Kind     string    `json:"kind"`
+	User     string    `json:"user,omitempty"`
+	Scope    string    `json:"scope,omitempty"`
+	Format   string    `json:"format,omitempty"`
+	Status   int       `json:"status"`
+	Duration float64   `json:"duration"`
+	// Items is the number of items of the listings that were returned.
+	Items *int  `json:"items,omitempty"`
+	Bytes int64 `json:"bytes"`
+}
+
+const (
+	// accessLogQueue is the number of lines that can wait to be written.
+	// The ones over it are dropped rather than holding the requests.
+	accessLogQueue = 4096
+	// accessLogFlush is how often the lines are flushed.
+	accessLogFlush = time.Second
+)
+
+type accessLog struct {
+	entries chan *accessEntry
+	done    chan struct{}
+	dropped uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAccessLog(w io.Writer) *accessLog {
+	l := &accessLog{
+		entries: make(chan *accessEntry, accessLogQueue),
+		done:    make(chan struct{}),
+	}
+
+	go l.write(bufio.NewWriter(w))
+	return l
+}
+
+func (l *accessLog) write(w *bufio.Writer) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(accessLogFlush)
+	defer ticker.Stop()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case entry, ok := <-l.entries:
+			if !ok {
+				_ = w.Flush()
+				return
+			}
+
+			_ = enc.Encode(entry)
+		case <-ticker.C:
+			_ = w.Flush()
+		}
+	}
+}
+
+// add queues entry without blocking. The entries that don't fit in the
+// queue are counted and reported by report, and the ones of the requests
+// that finish after close are dropped.
+func (l *accessLog) add(entry *accessEntry) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.closed {
+		return
+	}
+
+	select {
+	case l.entries <- entry:
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+	}
+}
+
+// report logs the entries that were dropped since the last report.
+func (l *accessLog) report(logger logging.Logger) {
+	if dropped := atomic.SwapUint64(&l.dropped, 0); dropped > 0 {
+		logger.Warn("the access log couldn't keep up and dropped lines", "dropped", dropped)
+	}
+}
+
+// len returns the number of lines waiting to be written and the number
+// that can wait.
+func (l *accessLog) len() (int, int) {
+	return len(l.entries), cap(l.entries)
+}
+
+// close stops taking entries and waits for the queued ones to be written
+// and flushed, or for ctx to be done.
+func (l *accessLog) close(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.entries)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logAccess queues the entry of a request answered through w, which
+// started at start.
+func (h *Handler) logAccess(r *http.Request, d *data, w *statusWriter, start time.Time) {
+	entry := &accessEntry{
+		Time:     start,
+		Request:  RequestID(r.Context()),
+		Client:   d.clientAddr(r),
+		Method:   r.Method,
+		Path:     strings.SplitN(r.RequestURI, "?", 2)[0],
+		Kind:     "request",
+		Scope:    d.scope(),
+		Format:   d.format,
+		Status:   w.status,
+		Duration: time.Since(start).Seconds(),
+		Bytes:    w.written,
+	}
+
+	if d.user != nil {
+		entry.User = d.user.Username
+	}
+
+	switch {
+	case d.format != "":
+		entry.Kind = "listing"
+		items := d.items
+		entry.Items = &items
+	case d.download:
+		entry.Kind = "download"
+	case !readMethods[r.Method]:
+		entry.Kind = "write"
+	}
+
+	h.accessLog.add(entry)
+	h.accessLog.report(h.logger)
+}
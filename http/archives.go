@@ -0,0 +1,675 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/disk"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/logging"
+	"github.com/gorilla/mux"
+	"github.com/mholt/archiver"
+)
+
+// The states of the archive jobs.
+const (
+	archiveQueued   = "queued"
+	archiveRunning  = "running"
+	archiveDone     = "done"
+	archiveFailed   = "failed"
+	archiveCanceled = "canceled"
+)
+
+// archiveJobsFile is the file of the spool with the state of the jobs.
+const archiveJobsFile = "jobs.json"
+
+var errNoSpace = errors.New("the files of the archive don't fit on the disk of the spool")
+
+// archiveStatus is the state of an archive job, which is what the API
+// returns and what is kept in the spool. Entries is the number of files
+// written to the archive, out of Total, and Bytes the size of the archive
+// so far. TTL is how long, in minutes, the archive is kept once finished.
+type archiveStatus struct {
+	ID       string     `json:"id"`
+	User     uint       `json:"user"`
+	Path     string     `json:"path"`
+	Name     string     `json:"name"`
+	State    string     `json:"state"`
+	Entries  int64      `json:"entries"`
+	Total    int64      `json:"total"`
+	Bytes    int64      `json:"bytes"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	TTL      int        `json:"ttl"`
+}
+
+// finish sets when the job finished, at now, and when it expires.
+func (s *archiveStatus) finish(now time.Time) {
+	expires := now.Add(time.Duration(s.TTL) * time.Minute)
+	s.Finished, s.Expires = &now, &expires
+}
+
+// archiveJob builds an archive in the spool.
+type archiveJob struct {
+	// The counters come first, so they're aligned for the atomic
+	// operations on the 32-bit platforms.
+	entries int64
+	total   int64
+	bytes   int64
+	archiveStatus
+
+	build  func(ctx context.Context, job *archiveJob, w io.Writer) error
+	ctx    context.Context
+	cancel context.CancelFunc
+	timer  *time.Timer
+}
+
+// status returns the state of the job with its progress.
+func (j *archiveJob) status() archiveStatus {
+	s := j.archiveStatus
+	if s.State == archiveRunning {
+		s.Entries = atomic.LoadInt64(&j.entries)
+		s.Total = atomic.LoadInt64(&j.total)
+		s.Bytes = atomic.LoadInt64(&j.bytes)
+	}
+
+	return s
+}
+
+// archiveJobs are the archive jobs of the spool of the server settings.
+// They are kept by the handler, so they outlive the reloads, and their
+// state is saved in the spool whenever it changes, so the finished
+// archives outlive the restarts too. The jobs that were waiting or being
+// built when the server stopped are failed when it starts again.
+type archiveJobs struct {
+	mu      sync.Mutex
+	logger  logging.Logger
+	dir     string
+	jobs    map[string]*archiveJob
+	queue   []*archiveJob
+	running int
+	limit   int
+}
+
+func newArchiveJobs(logger logging.Logger) *archiveJobs {
+	return &archiveJobs{logger: logger, jobs: map[string]*archiveJob{}, limit: 1}
+}
+
+func (a *archiveJobs) file(id, ext string) string {
+	return filepath.Join(a.dir, id+ext)
+}
+
+// open makes the jobs those of the spool dir, loading its state. When the
+// spool changes, the jobs of the previous one are canceled.
+func (a *archiveJobs) open(dir string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if dir == a.dir {
+		return nil
+	}
+
+	var loaded []archiveStatus
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, archiveJobsFile))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if len(content) > 0 {
+			if err := json.Unmarshal(content, &loaded); err != nil {
+				return fmt.Errorf("invalid state of the archive jobs: %v", err)
+			}
+		}
+	}
+
+	for _, job := range a.jobs {
+		if job.timer != nil {
+			job.timer.Stop()
+		}
+		if job.cancel != nil {
+			job.cancel()
+		}
+	}
+	a.queue = nil
+
+	a.dir = dir
+	a.jobs = map[string]*archiveJob{}
+	now := time.Now()
+	for _, status := range loaded {
+		job := &archiveJob{archiveStatus: status}
+		if job.State == archiveQueued || job.State == archiveRunning {
+			os.Remove(a.file(job.ID, ".part"))
+			job.State = archiveFailed
+			job.Error = "the server stopped while the archive was built"
+			job.finish(now)
+		}
+
+		if job.State == archiveDone {
+			if _, err := os.Stat(a.file(job.ID, ".archive")); err != nil {
+				continue
+			}
+		}
+
+		a.jobs[job.ID] = job
+		a.expireLocked(job)
+	}
+
+	a.saveLocked()
+	return nil
+}
+
+// saveLocked saves the state of the jobs in the spool. It's written to a
+// temporary file first, so the state is never partial.
+func (a *archiveJobs) saveLocked() {
+	if a.dir == "" {
+		return
+	}
+
+	list := make([]archiveStatus, 0, len(a.jobs))
+	for _, job := range a.jobs {
+		list = append(list, job.status())
+	}
+
+	content, err := json.Marshal(list)
+	if err == nil {
+		name := filepath.Join(a.dir, archiveJobsFile)
+		if err = os.WriteFile(name+".tmp", content, 0600); err == nil {
+			err = os.Rename(name+".tmp", name)
+		}
+	}
+
+	if err != nil {
+		a.logger.Error("couldn't save the state of the archive jobs", "error", err)
+	}
+}
+
+// expireLocked removes the finished job once its TTL is over.
+func (a *archiveJobs) expireLocked(job *archiveJob) {
+	dir := a.dir
+	var ttl time.Duration
+	if job.Expires != nil {
+		ttl = time.Until(*job.Expires)
+	}
+
+	job.timer = time.AfterFunc(ttl, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.dir == dir && a.jobs[job.ID] == job {
+			a.removeLocked(job)
+		}
+	})
+}
+
+// removeLocked removes a finished job and its archive.
+func (a *archiveJobs) removeLocked(job *archiveJob) {
+	if job.timer != nil {
+		job.timer.Stop()
+	}
+
+	os.Remove(a.file(job.ID, ".archive"))
+	delete(a.jobs, job.ID)
+	a.saveLocked()
+}
+
+// pending returns the number of jobs of user that aren't finished.
+func (a *archiveJobs) pending(user uint) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for _, job := range a.jobs {
+		if job.User == user && (job.State == archiveQueued || job.State == archiveRunning) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// submit queues job, building at most limit archives at once.
+func (a *archiveJobs) submit(job *archiveJob, limit int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.limit = limit
+	a.jobs[job.ID] = job
+	a.queue = append(a.queue, job)
+	a.scheduleLocked()
+	a.saveLocked()
+}
+
+func (a *archiveJobs) scheduleLocked() {
+	for a.running < a.limit && len(a.queue) > 0 {
+		job := a.queue[0]
+		a.queue = a.queue[1:]
+		job.State = archiveRunning
+		a.running++
+		go a.run(job, a.dir)
+	}
+}
+
+// run builds the archive of job to a partial file of the spool, which is
+// renamed once it's complete.
+func (a *archiveJobs) run(job *archiveJob, dir string) {
+	part := filepath.Join(dir, job.ID+".part")
+	err := func() error {
+		fd, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+
+		err = job.build(job.ctx, job, &archiveWriter{fd, &job.bytes})
+		if closeErr := fd.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(part, filepath.Join(dir, job.ID+".archive"))
+		}
+
+		return err
+	}()
+	if err != nil {
+		os.Remove(part)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	job.Entries, job.Total, job.Bytes = atomic.LoadInt64(&job.entries), atomic.LoadInt64(&job.total), atomic.LoadInt64(&job.bytes)
+	a.finishLocked(job, err)
+	a.running--
+	a.scheduleLocked()
+}
+
+// finishLocked sets the final state of job from the error it got.
+func (a *archiveJobs) finishLocked(job *archiveJob, err error) {
+	switch {
+	case err == nil:
+		job.State = archiveDone
+	case job.ctx.Err() != nil:
+		job.State = archiveCanceled
+	default:
+		job.State = archiveFailed
+		job.Error = err.Error()
+		a.logger.Warn("couldn't build the archive", "id", job.ID, "path", job.Path, "error", err)
+	}
+
+	job.cancel()
+	job.finish(time.Now())
+	if a.jobs[job.ID] == job {
+		a.expireLocked(job)
+		a.saveLocked()
+	}
+}
+
+// get returns the job of user with id.
+func (a *archiveJobs) get(user uint, id string) (archiveStatus, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	job, ok := a.jobs[id]
+	if !ok || job.User != user {
+		return archiveStatus{}, false
+	}
+
+	return job.status(), true
+}
+
+// list returns the jobs of user, from the latest.
+func (a *archiveJobs) list(user uint) []archiveStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	list := []archiveStatus{}
+	for _, job := range a.jobs {
+		if job.User == user {
+			list = append(list, job.status())
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].Created.After(list[j].Created) })
+	return list
+}
+
+// cancel cancels the job of user with id if it isn't finished, or removes
+// it and its archive if it is.
+func (a *archiveJobs) cancel(user uint, id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	job, ok := a.jobs[id]
+	if !ok || job.User != user {
+		return false
+	}
+
+	switch job.State {
+	case archiveQueued:
+		for i, queued := range a.queue {
+			if queued == job {
+				a.queue = append(a.queue[:i], a.queue[i+1:]...)
+				break
+			}
+		}
+		job.cancel()
+		a.finishLocked(job, job.ctx.Err())
+	case archiveRunning:
+		// The job sees it's canceled and finishes.
+		job.cancel()
+	default:
+		a.removeLocked(job)
+	}
+
+	return true
+}
+
+func (a *archiveJobs) len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.jobs)
+}
+
+// archiveWriter counts the bytes written to an archive.
+type archiveWriter struct {
+	io.Writer
+	written *int64
+}
+
+func (w *archiveWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	atomic.AddInt64(w.written, int64(n))
+	return n, err
+}
+
+// jobArchive counts the files written to an archive and stops writing
+// them once the job is canceled.
+type jobArchive struct {
+	archiver.Writer
+	ctx     context.Context
+	entries *int64
+}
+
+func (a *jobArchive) Write(f archiver.File) error {
+	if err := a.ctx.Err(); err != nil {
+		return err
+	}
+
+	if f.ReadCloser != nil {
+		f.ReadCloser = &contextReader{f.ReadCloser, a.ctx}
+	}
+
+	if err := a.Writer.Write(f); err != nil {
+		return err
+	}
+
+	atomic.AddInt64(a.entries, 1)
+	return nil
+}
+
+// contextReader stops reading once its context is done, so the big
+// files don't delay the cancellations.
+type contextReader struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.ReadCloser.Read(p)
+}
+
+// measureArchive returns the number of files under path that addFile
+// writes to the archives and the sum of their sizes.
+func measureArchive(ctx context.Context, d *data, path string) (int64, int64, error) {
+	path = strings.Replace(path, "\\", "/", -1)
+	if !d.Check(path) {
+		return 0, 0, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	info, err := d.user.Fs.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if files.SpecialKind(info.Mode()) != "" {
+		return 0, 0, nil
+	}
+
+	if !info.IsDir() {
+		return 1, info.Size(), nil
+	}
+
+	file, err := d.user.Fs.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	names, err := file.Readdirnames(0)
+	file.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	entries, size := int64(1), int64(0)
+	for _, name := range names {
+		n, s, err := measureArchive(ctx, d, slashJoin(path, name))
+		if err != nil {
+			return 0, 0, err
+		}
+		entries += n
+		size += s
+	}
+
+	return entries, size, nil
+}
+
+// archiveJobHandler starts building the archive of the files of a
+// directory in the spool, with the same query as the streamed archives,
+// and returns its job, whose status and archive are then under
+// /api/archives.
+var archiveJobHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.user.Perm.Download {
+		return http.StatusForbidden, nil
+	}
+
+	if d.server.ArchiveSpool == "" {
+		return renderFailure(w, r, http.StatusNotImplemented, "the server has no archive spool: the archives can only be streamed")
+	}
+
+	file, err := files.NewFileInfo(files.FileOptions{
+		Fs:      d.user.Fs,
+		Path:    r.URL.Path,
+		Modify:  d.user.Perm.Modify,
+		Expand:  false,
+		Checker: d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !file.IsDir {
+		return renderFailure(w, r, http.StatusBadRequest, "only the directories are archived")
+	}
+
+	if !d.capabilities(file.Path).CanDownloadArchive {
+		return http.StatusForbidden, nil
+	}
+
+	filenames, err := parseQueryFiles(r, file, d.user)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	extension, ar, err := parseQueryAlgorithm(r)
+	if err != nil {
+		return renderFailure(w, r, http.StatusBadRequest, err.Error())
+	}
+
+	limits := d.settings.Archives
+	if limits.PerUser > 0 && d.archives.pending(d.user.ID) >= limits.PerUser {
+		return renderFailure(w, r, http.StatusTooManyRequests, "too many archives are being built")
+	}
+
+	spool := d.server.ArchiveSpool
+	if usage, err := disk.UsageOf(spool); err == nil && usage.Free <= uint64(limits.MinFree) {
+		return renderFailure(w, r, http.StatusInsufficientStorage, errNoSpace.Error())
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	name := file.Name
+	if name == "." || name == "" {
+		name = "archive"
+	}
+
+	// The job outlives the request, so its filesystem is bound to its own
+	// context.
+	ctx, cancel := context.WithCancel(context.Background())
+	user, err := d.store.Users.Get(d.server.Root, d.user.ID)
+	if err != nil {
+		cancel()
+		return http.StatusInternalServerError, err
+	}
+	jd := *d
+	jd.user = user
+	jd.user.Fs = userFs(&jd, user.Fs, ctx)
+
+	job := &archiveJob{
+		archiveStatus: archiveStatus{
+			ID:      hex.EncodeToString(id[:]),
+			User:    d.user.ID,
+			Path:    file.Path,
+			Name:    name + extension,
+			State:   archiveQueued,
+			Created: time.Now(),
+			TTL:     limits.Lifetime(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	job.build = func(ctx context.Context, job *archiveJob, w io.Writer) error {
+		var total, size int64
+		for _, fname := range filenames {
+			n, s, err := measureArchive(ctx, &jd, fname)
+			if err != nil {
+				return err
+			}
+			total += n
+			size += s
+		}
+		atomic.StoreInt64(&job.total, total)
+
+		usage, err := disk.UsageOf(spool)
+		if err == nil && (usage.Free < uint64(size) || usage.Free-uint64(size) < uint64(limits.MinFree)) {
+			return errNoSpace
+		}
+
+		archive := &jobArchive{Writer: ar, ctx: ctx, entries: &job.entries}
+		if err := archive.Create(w); err != nil {
+			return err
+		}
+
+		for _, fname := range filenames {
+			if err := addFile(archive, &jd, fname); err != nil {
+				archive.Close()
+				return err
+			}
+		}
+
+		return archive.Close()
+	}
+
+	d.archives.submit(job, limits.RunningJobs())
+
+	status, _ := d.archives.get(d.user.ID, job.ID)
+	w.Header().Set("Location", d.server.BaseURL+"/api/archives/"+job.ID)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	return 0, json.NewEncoder(w).Encode(status)
+})
+
+var archivesGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	return renderJSON(w, r, d.archives.list(d.user.ID))
+})
+
+var archiveGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	status, ok := d.archives.get(d.user.ID, mux.Vars(r)["id"])
+	if !ok {
+		return http.StatusNotFound, nil
+	}
+
+	return renderJSON(w, r, status)
+})
+
+// archiveDeleteHandler cancels an archive job, or removes its archive if
+// it's finished.
+var archiveDeleteHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.archives.cancel(d.user.ID, mux.Vars(r)["id"]) {
+		return http.StatusNotFound, nil
+	}
+
+	return http.StatusOK, nil
+})
+
+// archiveFileHandler serves the archive of a finished job, with ranges
+// so the downloads can be resumed.
+var archiveFileHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.user.Perm.Download {
+		return http.StatusForbidden, nil
+	}
+
+	status, ok := d.archives.get(d.user.ID, mux.Vars(r)["id"])
+	if !ok {
+		return http.StatusNotFound, nil
+	}
+
+	if status.State != archiveDone {
+		return renderFailure(w, r, http.StatusConflict, "the archive is "+status.State)
+	}
+
+	fd, err := os.Open(filepath.Join(d.server.ArchiveSpool, status.ID+".archive"))
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer fd.Close()
+
+	w = d.countDownload(w)
+	w.Header().Set("Content-Disposition", attachment(status.Name))
+	var modTime time.Time
+	if status.Finished != nil {
+		modTime = *status.Finished
+	}
+
+	http.ServeContent(w, r, status.Name, modTime, fd)
+	return 0, nil
+})
@@ -0,0 +1,844 @@
+package http
+
+import (
+	"time"
+)
+
+// listingAssets are the stylesheets and scripts of the HTML listings,
+// served by the static handler. The dark theme is applied on top of the
+// light one.
+var listingAssets = map[string]string{
+	"themes/light.css": `body {
+  margin: 0 auto;
+  max-width: 960px;
+  padding: 1em;
+  font-family: sans-serif;
+  color: #212121;
+  background: #fff;
+}
+
+a {
+  color: var(--accent, #2196f3);
+  text-decoration: none;
+}
+
+#branding {
+  margin-bottom: .5em;
+  font-size: 1.2em;
+  font-weight: bold;
+}
+
+#branding img {
+  max-height: 2em;
+  margin-right: .5em;
+  vertical-align: middle;
+}
+
+table {
+  width: 100%;
+  border-collapse: collapse;
+}
+
+th, td {
+  padding: .5em;
+  text-align: left;
+  border-bottom: 1px solid #e0e0e0;
+}
+
+img.thumb {
+  max-width: 3em;
+  max-height: 3em;
+  vertical-align: middle;
+}
+
+footer {
+  margin-top: 1em;
+  font-size: .9em;
+  color: #757575;
+}
+
+#actions {
+  margin-bottom: 1em;
+}
+
+tr.failed {
+  background: #ffebee;
+}
+
+button.rename, button.unpin {
+  padding: 0 .2em;
+  border: 0;
+  background: none;
+  cursor: pointer;
+}
+
+.error {
+  margin-left: .5em;
+  color: #d32f2f;
+}
+
+#favorites .missing {
+  color: #9e9e9e;
+  text-decoration: line-through;
+}
+
+.diff .del {
+  background: #ffebee;
+}
+
+.diff .ins {
+  background: #e8f5e9;
+}
+
+.diff .hunk {
+  color: #757575;
+}
+
+body.dragover {
+  outline: 3px dashed #2196f3;
+  outline-offset: -3px;
+}
+`,
+	"themes/dark.css": `body {
+  color: #e0e0e0;
+  background: #121212;
+}
+
+a {
+  color: var(--accent, #64b5f6);
+}
+
+th, td {
+  border-bottom-color: #333;
+}
+
+footer {
+  color: #9e9e9e;
+}
+
+tr.failed {
+  background: #4a1c1c;
+}
+
+.diff .del {
+  background: #4a1c1c;
+}
+
+.diff .ins {
+  background: #1b3d20;
+}
+
+.error {
+  color: #ef9a9a;
+}
+`,
+	"listing.js": `(function () {
+  var listing = document.getElementById('listing')
+  if (!listing) return
+
+  var baseURL = listing.getAttribute('data-base-url')
+  var dir = listing.getAttribute('data-path')
+  var token = new URLSearchParams(window.location.search).get('auth')
+  var csrf = listing.getAttribute('data-csrf')
+  var actions = document.getElementById('actions')
+  var summary = document.getElementById('summary')
+  var icons = JSON.parse(listing.getAttribute('data-icons') || '{}')
+  var messages = {}
+
+  // The strings are loaded in the background since they are only needed
+  // once the user does something.
+  fetch(baseURL + '/api/translations?lang=' + encodeURIComponent(document.documentElement.lang))
+    .then(function (res) { return res.ok ? res.json() : {} })
+    .then(function (loaded) { messages = loaded })
+    .catch(function () {})
+
+  // t returns the string with key in the locale of the page, replacing
+  // {0}, {1} and so on with the other arguments.
+  function t (key) {
+    var args = arguments
+    return (messages[key] || key).replace(/\{(\d+)\}/g, function (match, i) {
+      return args[+i + 1]
+    })
+  }
+
+  function headers (extra) {
+    var result = extra || {}
+    if (token) result['X-Auth'] = token
+    return result
+  }
+
+  function encodePath (path) {
+    return path.split('/').map(encodeURIComponent).join('/')
+  }
+
+  function humanSize (size) {
+    var units = ['B', 'KiB', 'MiB', 'GiB', 'TiB']
+    var i = 0
+    while (size >= 1024 && i < units.length - 1) {
+      size /= 1024
+      i++
+    }
+
+    return i === 0 ? size + ' B' : size.toFixed(1) + ' ' + units[i]
+  }
+
+  // sortQuery returns the sorting of the listing as query parameters, so
+  // the server can tell the position of the files it returns.
+  function sortQuery () {
+    var params = new URLSearchParams(window.location.search)
+    if (!params.get('sort')) return ''
+    return '&sort=' + encodeURIComponent(params.get('sort')) + '&order=' + encodeURIComponent(params.get('order') || '')
+  }
+
+  // keptQuery returns the query of the page without the search, for the
+  // links to other listings.
+  function keptQuery () {
+    var params = new URLSearchParams(window.location.search)
+    params.delete('search')
+    var query = params.toString()
+    return query ? '?' + query : ''
+  }
+
+  function validName (name) {
+    return name !== '' && name !== '.' && name !== '..' && name.indexOf('/') === -1
+  }
+
+  // failure returns the error of a failed request, which is JSON when the
+  // server can tell what went wrong.
+  function failure (res) {
+    return res.json().then(function (body) {
+      return new Error(body.error)
+    }, function () {
+      return new Error(res.status + ' ' + res.statusText)
+    }).then(function (err) {
+      throw err
+    })
+  }
+
+  function boxes () {
+    return Array.prototype.slice.call(listing.querySelectorAll('input[name=item]'))
+  }
+
+  function selected () {
+    return boxes().filter(function (box) { return box.checked }).map(function (box) { return box.value })
+  }
+
+  function cell (row, content) {
+    var td = document.createElement('td')
+    if (typeof content === 'string') {
+      td.textContent = content
+    } else if (content) {
+      td.appendChild(content)
+    }
+
+    row.appendChild(td)
+    return td
+  }
+
+  // Selection and bulk actions.
+  if (actions) {
+    var update = function () {
+      actions.hidden = selected().length === 0
+    }
+
+    document.getElementById('select-all').addEventListener('change', function (event) {
+      boxes().forEach(function (box) { box.checked = event.target.checked })
+      update()
+    })
+
+    listing.addEventListener('change', function (event) {
+      if (event.target.name === 'item') update()
+    })
+
+    var render = function (response) {
+      var failed = {}
+      response.results.forEach(function (result) {
+        if (result.error) failed[result.path] = result.error
+      })
+
+      if (response.failed === 0) {
+        window.location.reload()
+        return
+      }
+
+      Array.prototype.slice.call(listing.querySelectorAll('tr[data-path]')).forEach(function (row) {
+        var error = failed[row.getAttribute('data-path')]
+        row.className = error ? 'failed' : ''
+        row.title = error || ''
+      })
+
+      summary.textContent = t('bulkSummary', response.succeeded, response.failed)
+    }
+
+    var bulk = function (body) {
+      fetch(baseURL + '/api/bulk', {
+        method: 'POST',
+        headers: headers({ 'Content-Type': 'application/json', 'X-CSRF-Token': csrf }),
+        body: JSON.stringify(body)
+      })
+        .then(function (res) {
+          if (!res.ok) throw new Error(res.status + ' ' + res.statusText)
+          return res.json()
+        })
+        .then(render)
+        .catch(function (err) { summary.textContent = err.message })
+    }
+
+    actions.addEventListener('click', function (event) {
+      var items = selected()
+
+      switch (event.target.getAttribute('data-action')) {
+        case 'delete':
+          if (window.confirm(t('confirmDelete', items.length))) {
+            bulk({ action: 'delete', items: items })
+          }
+          break
+        case 'move':
+          var destination = window.prompt(t('move'), dir)
+          if (destination) {
+            bulk({ action: 'move', items: items, destination: destination })
+          }
+          break
+        case 'download':
+          var files = items.map(function (item) { return encodeURIComponent(encodeURIComponent(item)) })
+          var url = baseURL + '/api/raw/?algo=zip&files=' + files.join(',')
+          if (token) url += '&auth=' + encodeURIComponent(token)
+          window.location.href = url
+          break
+      }
+    })
+  }
+
+  // Search. Without JavaScript, or when the user can't use the search
+  // API, the form reloads the page and the server renders the results
+  // instead.
+  var searchForm = document.getElementById('search')
+  if (searchForm && !searchForm.hasAttribute('data-server-search')) {
+    var searchInput = searchForm.querySelector('input[type=search]')
+    var clearSearch = document.getElementById('clear-search')
+    var searchStatus = document.getElementById('search-status')
+    var serverSearch = new URLSearchParams(window.location.search).has('search')
+    var searchTimer = null
+    var lastSearch = 0
+
+    var listed = function () {
+      return Array.prototype.slice.call(listing.querySelectorAll('tr[data-path]'))
+    }
+
+    var restore = function () {
+      Array.prototype.slice.call(listing.querySelectorAll('tr.result')).forEach(function (row) {
+        row.parentNode.removeChild(row)
+      })
+
+      listed().forEach(function (row) { row.hidden = false })
+      searchStatus.textContent = ''
+      clearSearch.hidden = !serverSearch
+    }
+
+    var showResults = function (results, truncated) {
+      restore()
+      listed().forEach(function (row) { row.hidden = true })
+
+      var tbody = listing.querySelector('tbody')
+      results.forEach(function (result) {
+        var row = document.createElement('tr')
+        row.className = 'result'
+        if (actions) cell(row, '')
+        cell(row, result.dir ? icons.folder : icons[''])
+
+        var relative = result.path.replace(/^\/+/, '')
+        var path = dir.replace(/\/$/, '') + '/' + relative
+        var link = document.createElement('a')
+        link.textContent = relative + (result.dir ? '/' : '')
+        link.href = baseURL + (result.dir ? '/api/resources' : '/api/raw') + encodePath(path) +
+          (result.dir ? '/' : '') + keptQuery()
+        cell(row, link)
+        cell(row, '')
+        cell(row, '')
+        tbody.appendChild(row)
+      })
+
+      searchStatus.textContent = t(truncated ? 'resultsTruncated' : 'results', results.length)
+      clearSearch.hidden = false
+    }
+
+    var runSearch = function () {
+      var query = searchInput.value.trim()
+      if (query === '') {
+        restore()
+        return
+      }
+
+      var id = ++lastSearch
+      fetch(baseURL + '/api/search' + encodePath(dir) + '?limit=100&query=' + encodeURIComponent(query), {
+        headers: headers()
+      })
+        .then(function (res) {
+          if (!res.ok) throw new Error(res.status + ' ' + res.statusText)
+          return res.json().then(function (results) {
+            if (id === lastSearch) showResults(results, res.headers.get('X-Results-Truncated') === 'true')
+          })
+        })
+        .catch(function (err) { searchStatus.textContent = err.message })
+    }
+
+    searchInput.addEventListener('input', function () {
+      window.clearTimeout(searchTimer)
+      searchTimer = window.setTimeout(runSearch, 300)
+    })
+
+    searchInput.addEventListener('keydown', function (event) {
+      if (event.key === 'Escape' && !serverSearch) {
+        searchInput.value = ''
+        restore()
+      }
+    })
+
+    searchForm.addEventListener('submit', function (event) {
+      event.preventDefault()
+      window.clearTimeout(searchTimer)
+      runSearch()
+    })
+
+    clearSearch.addEventListener('click', function (event) {
+      if (serverSearch) return
+      event.preventDefault()
+      searchInput.value = ''
+      restore()
+      searchInput.focus()
+    })
+  }
+
+  // Inline renames.
+  var renames = listing.hasAttribute('data-rename')
+
+  function rename (row) {
+    var src = row.getAttribute('data-path')
+    var link = row.querySelector('a')
+    var button = row.querySelector('.rename')
+    var name = src.replace(/^.*\//, '')
+    var isDir = /\/$/.test(link.textContent)
+
+    var input = document.createElement('input')
+    input.type = 'text'
+    input.value = name
+    input.setAttribute('aria-label', t('newName'))
+    var error = document.createElement('span')
+    error.className = 'error'
+
+    link.hidden = true
+    button.hidden = true
+    link.parentNode.insertBefore(input, link)
+    link.parentNode.insertBefore(error, link)
+    input.focus()
+    input.setSelectionRange(0, isDir || name.lastIndexOf('.') <= 0 ? name.length : name.lastIndexOf('.'))
+
+    var close = function () {
+      input.parentNode.removeChild(input)
+      error.parentNode.removeChild(error)
+      link.hidden = false
+      button.hidden = false
+      button.focus()
+    }
+
+    input.addEventListener('keydown', function (event) {
+      if (event.key === 'Escape') {
+        close()
+        return
+      }
+
+      if (event.key !== 'Enter') return
+      event.preventDefault()
+
+      var newName = input.value.trim()
+      if (newName === name) {
+        close()
+        return
+      }
+
+      if (!validName(newName)) {
+        error.textContent = t('invalidName')
+        return
+      }
+
+      var dst = src.replace(/[^/]*$/, '') + newName
+      var query = '?action=rename&strict=true&destination=' + encodeURIComponent(encodePath(dst)) + sortQuery()
+
+      fetch(baseURL + '/api/resources' + encodePath(src) + query, {
+        method: 'PATCH',
+        headers: headers({ Accept: 'application/json' })
+      })
+        .then(function (res) {
+          if (res.status === 409) throw new Error(t('alreadyExists'))
+          if (!res.ok) return failure(res)
+          return res.json()
+        })
+        .then(function (file) {
+          close()
+          row.setAttribute('data-path', file.path)
+          var box = row.querySelector('input[name=item]')
+          if (box) box.value = file.path
+          link.textContent = file.name + (file.isDir ? '/' : '')
+          link.href = file.url + keptQuery()
+          moveRow(row, file.index)
+          button.focus()
+        })
+        .catch(function (err) {
+          error.textContent = err.message
+        })
+    })
+  }
+
+  listing.addEventListener('click', function (event) {
+    if (renames && event.target.className === 'rename') {
+      rename(event.target.parentNode.parentNode)
+    }
+  })
+
+  // New folders.
+  var newFolder = document.getElementById('new-folder')
+  if (newFolder) {
+    var newFolderError = document.getElementById('new-folder-error')
+
+    newFolder.addEventListener('click', function () {
+      var name = window.prompt(t('newFolder'))
+      if (name === null) return
+
+      name = name.trim()
+      if (!validName(name)) {
+        newFolderError.textContent = t('invalidName')
+        return
+      }
+
+      var path = dir.replace(/\/$/, '') + '/' + name + '/'
+      fetch(baseURL + '/api/resources' + encodePath(path) + '?strict=true' + sortQuery(), {
+        method: 'POST',
+        headers: headers({ Accept: 'application/json' })
+      })
+        .then(function (res) {
+          if (!res.ok) return failure(res)
+          return res.json()
+        })
+        .then(function (file) {
+          newFolderError.textContent = ''
+          addRow(file)
+        })
+        .catch(function (err) {
+          newFolderError.textContent = name + ': ' + err.message
+        })
+    })
+  }
+
+  // Uploads by URL. The server fetches the file into the directory.
+  var fetchURL = document.getElementById('fetch-url')
+  if (fetchURL) {
+    var fetchError = document.getElementById('new-folder-error')
+
+    fetchURL.addEventListener('click', function () {
+      var url = window.prompt(t('fetchPrompt'))
+      if (url === null || url.trim() === '') return
+
+      url = url.trim()
+      var id = Math.random().toString(36).slice(2) + Date.now().toString(36)
+      var done = showProgress(url, id)
+      fetch(baseURL + '/api/resources' + encodePath(dir) + '?action=fetch&' + sortQuery().slice(1), {
+        method: 'POST',
+        headers: headers({ Accept: 'application/json', 'Content-Type': 'application/json', 'X-Upload-ID': id }),
+        body: JSON.stringify({ url: url })
+      })
+        .then(function (res) {
+          if (!res.ok) return failure(res)
+          return res.json()
+        })
+        .then(function (file) {
+          done()
+          fetchError.textContent = ''
+          addRow(file)
+        })
+        .catch(function (err) {
+          done()
+          fetchError.textContent = url + ': ' + err.message
+        })
+    })
+  }
+
+  // Favorites. The page is reloaded since the pins show up in every
+  // listing.
+  var favorites = document.getElementById('favorites')
+  if (favorites) {
+    var pin = function (method, path) {
+      fetch(baseURL + '/api/favorites' + encodePath(path), {
+        method: method,
+        headers: headers({ Accept: 'application/json' })
+      })
+        .then(function (res) {
+          if (!res.ok) return failure(res)
+          window.location.reload()
+        })
+        .catch(function (err) { favorites.title = err.message })
+    }
+
+    favorites.addEventListener('click', function (event) {
+      if (event.target.id === 'pin') {
+        pin('POST', favorites.getAttribute('data-path'))
+      } else if (event.target.className === 'unpin') {
+        pin('DELETE', event.target.getAttribute('data-path'))
+      }
+    })
+  }
+
+  // Drag and drop uploads. Dropping files on a read only listing does
+  // nothing instead of making the browser open them.
+  var uploads = listing.hasAttribute('data-upload')
+
+  function addRow (file) {
+    var row = document.createElement('tr')
+    row.setAttribute('data-path', file.path)
+
+    if (actions) {
+      var box = document.createElement('input')
+      box.type = 'checkbox'
+      box.name = 'item'
+      box.value = file.path
+      cell(row, box)
+    }
+
+    cell(row, icons[file.category] || icons[''])
+
+    var link = document.createElement('a')
+    link.textContent = file.name + (file.isDir ? '/' : '')
+    link.href = file.url + keptQuery()
+    var name = cell(row, link)
+    cell(row, file.isDir ? '-' : humanSize(file.size))
+    cell(row, t('justNow'))
+
+    if (renames) {
+      var button = document.createElement('button')
+      button.type = 'button'
+      button.className = 'rename'
+      button.title = t('rename')
+      button.textContent = '✏️'
+      name.appendChild(document.createTextNode(' '))
+      name.appendChild(button)
+    }
+
+    moveRow(row, file.index)
+    return row
+  }
+
+  // moveRow puts a row at the index of the files of the listing.
+  function moveRow (row, index) {
+    var rows = Array.prototype.slice.call(listing.querySelectorAll('tr[data-path]')).filter(function (other) {
+      return other !== row
+    })
+
+    var tbody = listing.querySelector('tbody')
+    if (index >= 0 && index < rows.length) {
+      tbody.insertBefore(row, rows[index])
+    } else {
+      tbody.appendChild(row)
+    }
+  }
+
+  function addError (path, message) {
+    var row = document.createElement('tr')
+    row.className = 'failed'
+    cell(row, path + ': ' + message).colSpan = listing.rows[0].cells.length
+    listing.querySelector('tbody').appendChild(row)
+  }
+
+  function readEntries (reader) {
+    return new Promise(function (resolve, reject) {
+      var all = []
+      var next = function () {
+        reader.readEntries(function (entries) {
+          if (entries.length === 0) return resolve(all)
+          all = all.concat(entries)
+          next()
+        }, reject)
+      }
+
+      next()
+    })
+  }
+
+  function walk (entry, prefix) {
+    if (entry.isFile) {
+      return new Promise(function (resolve, reject) {
+        entry.file(function (file) {
+          resolve([{ path: prefix + entry.name, file: file }])
+        }, reject)
+      })
+    }
+
+    var path = prefix + entry.name + '/'
+    return readEntries(entry.createReader()).then(function (entries) {
+      return Promise.all(entries.map(function (child) { return walk(child, path) }))
+    }).then(function (children) {
+      return [].concat.apply([{ path: path }], children)
+    })
+  }
+
+  function collect (transfer) {
+    var items = Array.prototype.slice.call(transfer.items || [])
+    var entries = items.map(function (item) {
+      return item.webkitGetAsEntry ? item.webkitGetAsEntry() : null
+    })
+
+    if (entries.length === 0 || entries.some(function (entry) { return !entry })) {
+      return Promise.resolve(Array.prototype.slice.call(transfer.files).map(function (file) {
+        return { path: file.name, file: file }
+      }))
+    }
+
+    return Promise.all(entries.map(function (entry) { return walk(entry, '') })).then(function (lists) {
+      return [].concat.apply([], lists)
+    })
+  }
+
+  // showProgress polls the progress of the upload with id and shows it in
+  // a row with label until the returned function is called. The size of
+  // the fetched files is only known once their download starts.
+  function showProgress (label, id, size) {
+    var row = document.createElement('tr')
+    var bar = document.createElement('progress')
+    if (size) bar.max = size
+    bar.value = 0
+
+    var td = cell(row, label + ' ')
+    td.appendChild(bar)
+    td.colSpan = listing.rows[0].cells.length
+    listing.querySelector('tbody').appendChild(row)
+
+    var timer = window.setInterval(function () {
+      fetch(baseURL + '/api/uploads/' + id, { headers: headers() })
+        .then(function (res) { return res.ok ? res.json() : null })
+        .then(function (progress) {
+          if (!progress) return
+          if (progress.total > 0) bar.max = progress.total
+          bar.value = progress.received
+        })
+        .catch(function () {})
+    }, 500)
+
+    return function () {
+      window.clearInterval(timer)
+      row.parentNode.removeChild(row)
+    }
+  }
+
+  function upload (item) {
+    var path = dir.replace(/\/$/, '') + '/' + item.path
+    var topLevel = item.path.replace(/\/$/, '').indexOf('/') === -1
+    var id = Math.random().toString(36).slice(2) + Date.now().toString(36)
+    var done = item.file && item.file.size > 0 ? showProgress(item.path, id, item.file.size) : function () {}
+
+    return fetch(baseURL + '/api/resources' + encodePath(path) + '?' + sortQuery().slice(1), {
+      method: 'POST',
+      headers: headers({ Accept: 'application/json', 'X-Upload-ID': id }),
+      body: item.file
+    })
+      .then(function (res) {
+        if (!res.ok) return failure(res)
+        return res.json()
+      })
+      .then(function (file) {
+        done()
+        if (topLevel) addRow(file)
+      })
+      .catch(function (err) {
+        done()
+        addError(item.path, err.message)
+      })
+  }
+
+  document.addEventListener('dragover', function (event) {
+    event.preventDefault()
+    if (uploads) document.body.classList.add('dragover')
+  })
+
+  document.addEventListener('dragleave', function (event) {
+    if (!event.relatedTarget) document.body.classList.remove('dragover')
+  })
+
+  document.addEventListener('drop', function (event) {
+    event.preventDefault()
+    document.body.classList.remove('dragover')
+    if (!uploads) return
+
+    // Directories are created before the files inside them because the
+    // uploads run one after the other.
+    collect(event.dataTransfer).then(function (items) {
+      return items.reduce(function (done, item) {
+        return done.then(function () { return upload(item) })
+      }, Promise.resolve())
+    })
+  })
+
+  // Live updates. The server pushes the changes of the directory through
+  // a WebSocket, and the listing polls for them when it can't, reloading
+  // once something changed.
+  if (listing.hasAttribute('data-live')) {
+    var offset = actions ? 1 : 0
+
+    var rowFor = function (path) {
+      return Array.prototype.slice.call(listing.querySelectorAll('tr[data-path]')).filter(function (row) {
+        return row.getAttribute('data-path') === path
+      })[0]
+    }
+
+    var apply = function (event) {
+      if (event.op === 'reload') {
+        window.location.reload()
+        return
+      }
+
+      var row = rowFor(event.item.path)
+      if (event.op === 'remove' || event.op === 'rename') {
+        if (row) row.parentNode.removeChild(row)
+        return
+      }
+
+      if (!row) {
+        addRow(event.item)
+        return
+      }
+
+      row.cells[offset + 2].textContent = event.item.isDir ? '-' : humanSize(event.item.size)
+      row.cells[offset + 3].textContent = t('justNow')
+      moveRow(row, event.item.index)
+    }
+
+    var poll = function () {
+      var since = new Date().toISOString()
+      window.setInterval(function () {
+        fetch(baseURL + '/api/resources' + encodePath(dir) + '?changes_since=' + encodeURIComponent(since), {
+          headers: headers({ Accept: 'application/json' })
+        })
+          .then(function (res) { return res.ok ? res.json() : null })
+          .then(function (changes) {
+            if (!changes) return
+            if (changes.items.length > 0 || (changes.deleted || []).length > 0) window.location.reload()
+            since = changes.now
+          })
+          .catch(function () {})
+      }, 5000)
+    }
+
+    var scheme = window.location.protocol === 'https:' ? 'wss:' : 'ws:'
+    var socket = new window.WebSocket(scheme + '//' + window.location.host + baseURL + '/api/live' + encodePath(dir) + window.location.search)
+    var opened = false
+    socket.onopen = function () { opened = true }
+    socket.onmessage = function (message) {
+      JSON.parse(message.data).forEach(apply)
+    }
+    socket.onclose = function (event) {
+      if (!opened || event.code !== 1000) poll()
+    }
+  }
+})()
+`,
+}
+
+// listingAssetsModTime is the modification time of the listing assets.
+var listingAssetsModTime = time.Now()
@@ -1,8 +1,8 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -11,7 +11,10 @@ import (
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/normfs"
+	"github.com/filebrowser/filebrowser/v2/tracing"
 	"github.com/filebrowser/filebrowser/v2/users"
+	"github.com/spf13/afero"
 )
 
 type userInfo struct {
@@ -50,6 +53,8 @@ func (e extractor) ExtractToken(r *http.Request) (string, error) {
 
 func withUser(fn handleFunc) handleFunc {
 	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		_, span := tracing.Start(r.Context(), "filebrowser.user")
+
 		keyFunc := func(token *jwt.Token) (interface{}, error) {
 			return d.settings.Key, nil
 		}
@@ -58,6 +63,7 @@ func withUser(fn handleFunc) handleFunc {
 		token, err := request.ParseFromRequest(r, &extractor{}, keyFunc, request.WithClaims(&tk))
 
 		if err != nil || !token.Valid {
+			span.End()
 			return http.StatusForbidden, nil
 		}
 
@@ -70,12 +76,34 @@ func withUser(fn handleFunc) handleFunc {
 
 		d.user, err = d.store.Users.Get(d.server.Root, tk.User.ID)
 		if err != nil {
+			span.End()
 			return http.StatusInternalServerError, err
 		}
+
+		d.user.Fs = userFs(d, d.user.Fs, r.Context())
+
+		d.logger.Debug("user", "path", r.URL.Path, "user", d.user.Username, "scope", d.user.Scope)
+		span.SetString("user", d.user.Username)
+		span.SetString("scope", d.user.Scope)
+		span.End()
 		return fn(w, r, d)
 	}
 }
 
+// userFs returns fs, the filesystem of the scope of a user, bound to ctx
+// and with the names and the symbolic links as the settings say.
+func userFs(d *data, fs afero.Fs, ctx context.Context) afero.Fs {
+	if cfs, ok := fs.(contextFs); ok {
+		fs = cfs.WithContext(ctx)
+	}
+
+	if d.settings.NormalizeNames || d.settings.CaseInsensitive {
+		fs = normfs.New(fs, d.settings.CaseInsensitive)
+	}
+
+	return withSymlinks(fs, d)
+}
+
 func withAdmin(fn handleFunc) handleFunc {
 	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 		if !d.user.Perm.Admin {
@@ -141,11 +169,11 @@ var signupHandler = func(w http.ResponseWriter, r *http.Request, d *data) (int,
 
 	userHome, err := d.settings.MakeUserDir(user.Username, user.Scope, d.server.Root)
 	if err != nil {
-		log.Printf("create user: failed to mkdir user home dir: [%s]", userHome)
+		d.logger.Error("couldn't create the home directory", "user", user.Username, "dir", userHome, "error", err)
 		return http.StatusInternalServerError, err
 	}
 	user.Scope = userHome
-	log.Printf("new user: %s, home dir: [%s].", user.Username, userHome)
+	d.logger.Info("signup", "user", user.Username, "dir", userHome)
 
 	err = d.store.Users.Save(user)
 	if err == errors.ErrExist {
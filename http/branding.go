@@ -0,0 +1,235 @@
+package http
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+)
+
+// maxBrandingAssetSize is the maximum size of the stylesheets and scripts
+// of the branding directory.
+const maxBrandingAssetSize = 1 << 20
+
+// brandingAssetsPrefix is the path, inside the static files, where the
+// stylesheets and scripts of the branding directory are served.
+const brandingAssetsPrefix = "branding/"
+
+// isExternalAsset checks if a branding asset is a URL rather than a file
+// of the branding directory.
+func isExternalAsset(asset string) bool {
+	return strings.HasPrefix(asset, "http://") ||
+		strings.HasPrefix(asset, "https://") ||
+		strings.HasPrefix(asset, "//")
+}
+
+// brandingAsset returns the path and the info of a file of the branding
+// directory, which must not be larger than maxBrandingAssetSize.
+func brandingAsset(d *data, name string) (string, os.FileInfo, error) {
+	if d.settings.Branding.Files == "" {
+		return "", nil, os.ErrNotExist
+	}
+
+	p := filepath.Join(d.settings.Branding.Files, filepath.FromSlash(path.Clean("/"+name)))
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if info.IsDir() {
+		return "", nil, os.ErrNotExist
+	}
+
+	if info.Size() > maxBrandingAssetSize {
+		return "", nil, errors.ErrTooLarge
+	}
+
+	return p, info, nil
+}
+
+// brandingAssetVersion identifies a version of a file of the branding
+// directory so its URL changes when the file does.
+func brandingAssetVersion(info os.FileInfo) string {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 36)
+}
+
+// cacheAsset sets the caching headers of an asset whose URLs have its
+// version. The versioned URLs change with the asset, so they can be
+// cached for long. Otherwise, the browsers check for changes every time.
+func cacheAsset(w http.ResponseWriter, r *http.Request, version string) {
+	if r.URL.Query().Get("v") == version {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// brandingAssetURLs returns the URLs of the given branding assets. The
+// files of the branding directory that can't be served are left out.
+func brandingAssetURLs(d *data, baseURL string, assets []string) []string {
+	urls := []string{}
+	for _, asset := range assets {
+		if isExternalAsset(asset) {
+			urls = append(urls, asset)
+			continue
+		}
+
+		_, info, err := brandingAsset(d, asset)
+		if err != nil {
+			d.logger.Warn("couldn't load the branding asset", "asset", asset, "error", err)
+			continue
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+asset), "/")
+		u := url.URL{Path: d.staticURL(baseURL) + "/" + brandingAssetsPrefix + name}
+		urls = append(urls, u.EscapedPath()+"?v="+brandingAssetVersion(info))
+	}
+
+	return urls
+}
+
+// serveBrandingAsset serves one of the stylesheets or scripts of the
+// branding directory. Other files are not served.
+func serveBrandingAsset(w http.ResponseWriter, r *http.Request, d *data, name string) (int, error) {
+	name = path.Clean("/" + name)
+
+	contentType := ""
+	for _, style := range d.settings.Branding.Styles {
+		if !isExternalAsset(style) && path.Clean("/"+style) == name {
+			contentType = "text/css; charset=utf-8"
+		}
+	}
+	for _, script := range d.settings.Branding.Scripts {
+		if !isExternalAsset(script) && path.Clean("/"+script) == name {
+			contentType = "application/javascript; charset=utf-8"
+		}
+	}
+
+	if contentType == "" {
+		return http.StatusNotFound, nil
+	}
+
+	p, info, err := brandingAsset(d, name)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	file, err := os.Open(p)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer file.Close()
+
+	// The file may have grown since it was checked.
+	content, err := ioutil.ReadAll(io.LimitReader(file, maxBrandingAssetSize+1))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if len(content) > maxBrandingAssetSize {
+		return http.StatusInternalServerError, errors.ErrTooLarge
+	}
+
+	cacheAsset(w, r, brandingAssetVersion(info))
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, name, info.ModTime(), bytes.NewReader(content))
+	return 0, nil
+}
+
+// listingBranding is the branding of the HTML listings of the scope of a
+// user. CSS and JS are the contents of its stylesheet and script, which
+// are written in the page.
+type listingBranding struct {
+	Name        string
+	Logo        string
+	Color       string
+	HideCredits bool
+	CSS         template.CSS
+	JS          template.JS
+}
+
+// userBranding returns the branding of the HTML listings of the scope of
+// the user. The stylesheet and the script that can't be read, or that
+// would end the elements they are written in, are left out.
+func userBranding(d *data) listingBranding {
+	branding := d.user.Branding
+	page := listingBranding{
+		Name:        branding.Name,
+		Logo:        branding.Logo,
+		Color:       branding.Color,
+		HideCredits: branding.HideCredits || d.settings.Branding.DisableExternal,
+	}
+
+	if css, ok := inlineAsset(d, branding.CSSFile, "</style"); ok {
+		page.CSS = template.CSS(css)
+	}
+
+	if js, ok := inlineAsset(d, branding.JSFile, "</script", "<!--"); ok {
+		page.JS = template.JS(js)
+	}
+
+	return page
+}
+
+// inlineAsset reads the stylesheet or the script at name, in the scope of
+// the user or, if it's absolute, on the disk, so it can be written in a
+// page. The ones that are larger than maxBrandingAssetSize, or that have
+// one of the forbidden strings in any case, are refused.
+func inlineAsset(d *data, name string, forbidden ...string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+
+	fs := d.user.Fs
+	if filepath.IsAbs(name) {
+		fs = afero.NewOsFs()
+	}
+
+	content, err := readAsset(fs, name)
+	if err != nil {
+		d.logger.Warn("couldn't load the branding asset", "asset", name, "error", err)
+		return "", false
+	}
+
+	lower := strings.ToLower(string(content))
+	for _, s := range forbidden {
+		if strings.Contains(lower, s) {
+			d.logger.Warn("couldn't write the branding asset in the page", "asset", name, "forbidden", s)
+			return "", false
+		}
+	}
+
+	return string(content), true
+}
+
+// readAsset reads the file at name of fs, which must not be larger than
+// maxBrandingAssetSize.
+func readAsset(fs afero.Fs, name string) ([]byte, error) {
+	file, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(file, maxBrandingAssetSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > maxBrandingAssetSize {
+		return nil, errors.ErrTooLarge
+	}
+
+	return content, nil
+}
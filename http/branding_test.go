@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestUserBranding(t *testing.T) {
+	// The relative paths are in the scope of the user, and the absolute
+	// ones on the disk of the server.
+	disk := filepath.Join(t.TempDir(), "disk.css")
+	if err := os.WriteFile(disk, []byte("h1 { color: teal }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		branding users.Branding
+		want     []string
+		unwanted []string
+	}{
+		{"none", users.Branding{}, []string{`class="credits"`}, []string{`id="branding"`, "<style>", "--accent"}},
+		{"name and logo", users.Branding{Name: "Acme & Co", Logo: "/logo.png"}, []string{
+			`<img src="/logo.png" alt="">`, `<span>Acme &amp; Co</span>`,
+		}, nil},
+		{"hostile name", users.Branding{Name: `</span><script>alert(1)</script>`}, []string{
+			`<span>&lt;/span&gt;&lt;script&gt;alert(1)&lt;/script&gt;</span>`,
+		}, []string{"<script>alert(1)"}},
+		{"hostile logo", users.Branding{Logo: `javascript:alert(2)`}, []string{`<img src="#ZgotmplZ"`}, []string{"javascript:"}},
+		{"hostile logo attribute", users.Branding{Logo: `x" onerror="alert(2)`}, []string{`<img src="x%22%20onerror=%22alert%282%29"`}, []string{`onerror="alert`}},
+		{"color", users.Branding{Color: "#c0ffee"}, []string{`style="--accent: #c0ffee"`}, nil},
+		{"hostile color", users.Branding{Color: `red}</style><script>alert(3)</script>`}, []string{`style="--accent: ZgotmplZ"`}, []string{"<script>alert(3)"}},
+		{"styles and scripts", users.Branding{CSSFile: "brand/a.css", JSFile: "brand/a.js"}, []string{
+			"<style>body { margin: 0 }</style>", `<script>console.log("branded")</script>`,
+		}, nil},
+		{"absolute stylesheet", users.Branding{CSSFile: disk}, []string{"<style>h1 { color: teal }</style>"}, nil},
+		{"missing files", users.Branding{CSSFile: "brand/missing.css", JSFile: "brand/missing.js"}, nil, []string{"<style>", "branded"}},
+		{"hostile stylesheet", users.Branding{CSSFile: "brand/hostile.css"}, nil, []string{"alert(4)", "<style>"}},
+		{"hostile script", users.Branding{JSFile: "brand/hostile.js"}, nil, []string{"alert(5)"}},
+		{"hostile script comment", users.Branding{JSFile: "brand/comment.js"}, nil, []string{"alert(6)"}},
+		{"credits hidden", users.Branding{HideCredits: true}, nil, []string{`class="credits"`}},
+	}
+
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt":        {Content: "a"},
+		"/brand/a.css":       {Content: "body { margin: 0 }"},
+		"/brand/a.js":        {Content: `console.log("branded")`},
+		"/brand/hostile.css": {Content: "a {}</STYLE><script>alert(4)</script>"},
+		"/brand/hostile.js":  {Content: "alert(5)</script><script>"},
+		"/brand/comment.js":  {Content: "<!-- alert(6)"},
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updateUser(t, srv, func(u *users.User) { u.Branding = tt.branding })
+
+			w := do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "text/html")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			body := w.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("the listing doesn't contain %s:\n%s", want, body)
+				}
+			}
+			for _, unwanted := range tt.unwanted {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("the listing contains %s:\n%s", unwanted, body)
+				}
+			}
+		})
+	}
+}
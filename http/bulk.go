@@ -0,0 +1,309 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+type bulkRequest struct {
+	Action      string   `json:"action"`
+	Items       []string `json:"items"`
+	Destination string   `json:"destination"`
+	// CrossScope moves the files to another area of the scope, such as
+	// another alias, by copying them.
+	CrossScope bool `json:"crossScope"`
+	// DryRun reports what the action would do without changing anything.
+	DryRun bool `json:"dryRun"`
+	// Purge removes the deleted files rather than moving them to the
+	// trash directory of the settings.
+	Purge bool `json:"purge"`
+}
+
+// bulkResult is the result of the action on a file. Destination is where
+// the file is moved to, and Files and Size are the number and the size
+// of the files removed or moved with it, which only the dry runs count.
+type bulkResult struct {
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty"`
+	Files       int    `json:"files,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type bulkResponse struct {
+	DryRun    bool         `json:"dryRun"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []bulkResult `json:"results"`
+}
+
+// bulkDelete deletes src: it's moved to the trash directory of the
+// settings, if there's one, unless it's purged or already in the trash,
+// and removed otherwise.
+func bulkDelete(d *data, src string, purge bool) error {
+	release, err := lockPath(d, src)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var trashed string
+	if d.settings.TrashPath() != "" && !purge && !inTrash(d, src) {
+		trashed = trashDestination(d, src, time.Now())
+		releaseTrashed, err := lockPath(d, trashed)
+		if err != nil {
+			return err
+		}
+		defer releaseTrashed()
+	}
+
+	err = d.RunHook(func() error {
+		if trashed != "" {
+			return moveFile(d, src, trashed)
+		}
+		return d.user.Fs.RemoveAll(src)
+	}, "delete", src, "", -1, d.user)
+	if err != nil {
+		return err
+	}
+
+	d.notify(settings.EventDelete, src, "", 0)
+	return nil
+}
+
+func bulkMove(d *data, src, dst string) error {
+	if _, err := d.user.Fs.Stat(dst); err == nil {
+		return errors.ErrExist
+	}
+
+	release, err := lockPath(d, src)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	releaseDst, err := lockPath(d, dst)
+	if err != nil {
+		return err
+	}
+	defer releaseDst()
+
+	err = d.RunHook(func() error {
+		return d.user.Fs.Rename(src, dst)
+	}, "rename", src, dst, -1, d.user)
+	if err != nil {
+		return err
+	}
+
+	d.notifyMove(settings.EventRename, src, dst)
+	return nil
+}
+
+// bulkStep is the planned action of a bulk request on a file: it's
+// deleted if dst is empty, and moved to dst otherwise. The deleted files
+// are purged rather than trashed with purge. err is the error the action
+// would fail with, and it's skipped if it's set.
+type bulkStep struct {
+	src, dst string
+	cross    bool
+	purge    bool
+	err      error
+}
+
+// planBulk checks the action of the request on each of its files without
+// changing anything. The dry runs report the plan and the others apply
+// it, so both see the same checks. The files are planned in order, as
+// they will be seen once the steps before have been applied.
+func planBulk(d *data, req *bulkRequest) ([]bulkStep, error) {
+	var dir string
+	switch req.Action {
+	case "delete":
+		if !d.user.Perm.Delete {
+			return nil, os.ErrPermission
+		}
+	case "move":
+		dir = path.Clean("/" + req.Destination)
+		if !d.capabilities(dir).CanRename {
+			return nil, os.ErrPermission
+		}
+	default:
+		return nil, errors.ErrInvalidOption
+	}
+
+	// gone are the files removed or moved by the steps before, and taken
+	// the destinations they move the files to.
+	var gone []string
+	taken := map[string]bool{}
+
+	steps := make([]bulkStep, 0, len(req.Items))
+	for _, item := range req.Items {
+		step := bulkStep{src: path.Clean("/" + item), purge: req.Purge}
+		step.err = planBulkStep(d, req, dir, &step, gone, taken)
+		if step.err == nil {
+			gone = append(gone, step.src)
+			if step.dst != "" {
+				taken[step.dst] = true
+			}
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+func planBulkStep(d *data, req *bulkRequest, dir string, step *bulkStep, gone []string, taken map[string]bool) error {
+	if step.src == "/" || !d.Check(step.src) {
+		return os.ErrPermission
+	}
+
+	if underAny(step.src, gone) {
+		return errors.ErrNotExist
+	}
+
+	if _, err := d.user.Fs.Stat(step.src); err != nil {
+		return err
+	}
+
+	if req.Action == "delete" {
+		if !d.capabilities(step.src).CanDelete {
+			return os.ErrPermission
+		}
+		return nil
+	}
+
+	step.dst = path.Join(dir, path.Base(step.src))
+	if taken[step.dst] {
+		return errors.ErrExist
+	}
+
+	if req.CrossScope && crossesAreas(d, step.src, step.dst) {
+		step.cross = true
+		return checkCrossMove(d, step.src, step.dst)
+	}
+
+	// The files are created at their destinations, which must allow it.
+	dst := d.capabilities(step.dst)
+	if !d.capabilities(step.src).CanRename || !dst.CanRename || !dst.CanUpload {
+		return os.ErrPermission
+	}
+
+	if _, err := d.user.Fs.Stat(step.dst); err == nil && !underAny(step.dst, gone) {
+		return errors.ErrExist
+	}
+
+	return nil
+}
+
+// underAny checks if p is one of dirs or is under one of them.
+func underAny(p string, dirs []string) bool {
+	for _, dir := range dirs {
+		if p == dir || strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyBulkStep applies a step of a plan.
+func applyBulkStep(d *data, step *bulkStep) error {
+	switch {
+	case step.dst == "":
+		return bulkDelete(d, step.src, step.purge)
+	case step.cross:
+		return crossMove(d, step.src, step.dst)
+	default:
+		return bulkMove(d, step.src, step.dst)
+	}
+}
+
+// treeSize returns the number and the size of the files under p, or of
+// p itself if it's a file.
+func treeSize(fs afero.Fs, p string) (int, int64, error) {
+	var n int
+	var size int64
+	err := afero.Walk(fs, p, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			n++
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return n, size, err
+}
+
+// runBulk applies the steps of a plan that can be applied, or only counts
+// the files of each of them for the dry runs, and reports the result of
+// each step.
+func runBulk(d *data, steps []bulkStep, dryRun bool) *bulkResponse {
+	res := &bulkResponse{DryRun: dryRun, Results: []bulkResult{}}
+	for i := range steps {
+		step := &steps[i]
+		result := bulkResult{Path: step.src, Destination: step.dst}
+
+		err := step.err
+		switch {
+		case err != nil:
+		case dryRun:
+			result.Files, result.Size, err = treeSize(d.user.Fs, step.src)
+		default:
+			err = applyBulkStep(d, step)
+		}
+
+		// The errors are reported by their status so the full paths
+		// of the files on the server are not leaked.
+		if err != nil {
+			result.Error = http.StatusText(errToStatus(err))
+			res.Failed++
+		} else {
+			res.Succeeded++
+		}
+
+		res.Results = append(res.Results, result)
+	}
+
+	return res
+}
+
+// bulkHandler deletes or moves several files at once. A file that fails
+// doesn't stop the others: the result of each one is reported. With
+// dryRun, the report is of what would be done, and nothing is. The
+// requests must have the CSRF token of the user, which the listings are
+// sent with, since they can change many files at once.
+var bulkHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !checkCSRF(r, d) {
+		return renderFailure(w, r, http.StatusForbidden, "the CSRF token is missing or invalid")
+	}
+
+	req := &bulkRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	steps, err := planBulk(d, req)
+	switch {
+	case err == errors.ErrInvalidOption:
+		return http.StatusBadRequest, nil
+	case os.IsPermission(err):
+		return http.StatusForbidden, nil
+	case err != nil:
+		return errToStatus(err), err
+	}
+
+	return renderJSON(w, r, runBulk(d, steps, req.DryRun))
+})
@@ -0,0 +1,206 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestBulkMove(t *testing.T) {
+	tests := []struct {
+		name   string
+		csrf   string // the token, or "valid" for the one of the user
+		change func(*users.User)
+		status int
+		moved  bool
+	}{
+		{"moved", "valid", nil, http.StatusOK, true},
+		{"without a CSRF token", "", nil, http.StatusForbidden, false},
+		{"with another CSRF token", "AAAA", nil, http.StatusForbidden, false},
+		{"without create", "valid", func(u *users.User) { u.Perm.Create = false }, http.StatusOK, false},
+		{"without rename", "valid", func(u *users.User) { u.Perm.Rename = false }, http.StatusForbidden, false},
+		{"destination denied", "valid", func(u *users.User) {
+			u.Rules = []rules.Rule{{Path: "/dst"}}
+		}, http.StatusForbidden, false},
+		{"uploads denied at the destination", "valid", func(u *users.User) {
+			u.Rules = []rules.Rule{{Path: "/dst", Methods: []string{"POST"}}}
+		}, http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, map[string]filebrowsertest.File{
+				"/src/a.txt": {Content: "a"},
+				"/dst/":      {},
+			})
+
+			token := tt.csrf
+			if token == "valid" {
+				token = csrfToken(t, srv)
+			}
+			if tt.change != nil {
+				updateUser(t, srv, tt.change)
+			}
+
+			w := do(t, srv, "POST", "/api/bulk", `{"action": "move", "items": ["/src/a.txt"], "destination": "/dst"}`,
+				"Accept", "application/json", "X-CSRF-Token", token)
+			if w.Code != tt.status {
+				t.Fatalf("POST /api/bulk = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if w.Code == http.StatusOK {
+				var res struct{ Succeeded, Failed int }
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+
+				if moved := res.Succeeded == 1 && res.Failed == 0; moved != tt.moved {
+					t.Errorf("the results are %s, want moved: %v", w.Body, tt.moved)
+				}
+			}
+
+			_, err := fs.Stat("/dst/a.txt")
+			if moved := err == nil; moved != tt.moved {
+				t.Errorf("the file was moved: %v, want %v", moved, tt.moved)
+			}
+		})
+	}
+}
+
+func TestBulkCSRFToken(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "a"}})
+	token := csrfToken(t, srv)
+
+	// The HTML listings send it with the bulk requests.
+	w := do(t, srv, "GET", "/api/resources/?format=html", "")
+	if !strings.Contains(w.Body.String(), `data-csrf="`+token+`"`) {
+		t.Errorf("the HTML listing doesn't have the CSRF token %s", token)
+	}
+}
+
+func TestBulkDryRun(t *testing.T) {
+	type result struct {
+		Path        string
+		Destination string
+		Files       int
+		Size        int64
+		Error       string
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []result // the plan of the dry run
+	}{
+		{
+			"move",
+			`{"action": "move", "items": ["/src/a.txt", "/src/dir", "/src/b.txt", "/src/a.txt", "/src/missing"], "destination": "/dst"}`,
+			[]result{
+				{"/src/a.txt", "/dst/a.txt", 1, 1, ""},
+				{"/src/dir", "/dst/dir", 1, 3, ""},
+				{"/src/b.txt", "/dst/b.txt", 0, 0, "Conflict"},
+				{"/src/a.txt", "", 0, 0, "Not Found"},
+				{"/src/missing", "", 0, 0, "Not Found"},
+			},
+		},
+		{
+			"delete",
+			`{"action": "delete", "items": ["/src/dir", "/src/dir/c.txt", "/src/b.txt"], "purge": true}`,
+			[]result{
+				{"/src/dir", "", 1, 3, ""},
+				{"/src/dir/c.txt", "", 0, 0, "Not Found"},
+				{"/src/b.txt", "", 1, 2, ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := func(dryRun bool) ([]result, string, string) {
+				srv, fs := newServer(t, map[string]filebrowsertest.File{
+					"/src/a.txt":     {Content: "a"},
+					"/src/b.txt":     {Content: "bb"},
+					"/src/dir/c.txt": {Content: "ccc"},
+					"/dst/b.txt":     {Content: "old"},
+				})
+				before := snapshot(t, fs)
+
+				body := strings.Replace(tt.body, "{", fmt.Sprintf(`{"dryRun": %v, `, dryRun), 1)
+				w := do(t, srv, "POST", "/api/bulk", body, "Accept", "application/json", "X-CSRF-Token", csrfToken(t, srv))
+				if w.Code != http.StatusOK {
+					t.Fatalf("POST /api/bulk = %d: %s", w.Code, w.Body)
+				}
+
+				var res struct {
+					DryRun  bool
+					Results []result
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+				if res.DryRun != dryRun {
+					t.Errorf("the response is a dry run: %v, want %v", res.DryRun, dryRun)
+				}
+
+				return res.Results, before, snapshot(t, fs)
+			}
+
+			plan, before, after := run(true)
+			if !reflect.DeepEqual(plan, tt.want) {
+				t.Errorf("the plan is %+v, want %+v", plan, tt.want)
+			}
+			if after != before {
+				t.Errorf("the dry run changed the files:\n%s\nwant\n%s", after, before)
+			}
+
+			// The files are only counted by the dry runs, and the rest is
+			// what is applied.
+			applied, before, after := run(false)
+			for i := range plan {
+				plan[i].Files, plan[i].Size = 0, 0
+			}
+			if !reflect.DeepEqual(applied, plan) {
+				t.Errorf("the results are %+v, want the plan %+v", applied, plan)
+			}
+			if after == before {
+				t.Error("the files didn't change")
+			}
+		})
+	}
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	srv, fs := newServer(t, map[string]filebrowsertest.File{
+		"/dir/a.txt":     {Content: "a"},
+		"/dir/sub/b.txt": {Content: "bb"},
+	})
+	before := snapshot(t, fs)
+
+	w := do(t, srv, "DELETE", "/api/resources/dir/?recursive=true&purge=true&dryRun=true", "",
+		"Accept", "application/json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d: %s", w.Code, w.Body)
+	}
+
+	want := `{"dryRun":true,"succeeded":1,"failed":0,"results":[{"path":"/dir","files":2,"size":3}]}`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("the plan is %s, want %s", got, want)
+	}
+	if after := snapshot(t, fs); after != before {
+		t.Errorf("the dry run changed the files:\n%s", after)
+	}
+
+	if w := do(t, srv, "DELETE", "/api/resources/dir/?recursive=true&purge=true", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE = %d: %s", w.Code, w.Body)
+	}
+	if _, err := fs.Stat("/dir"); err == nil {
+		t.Error("the directory wasn't deleted")
+	}
+}
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// capabilities returns what the user can do with the file at p, or in
+// the directory at p. The handlers that change the files check them, so
+// the listings never show more than the user can do. The files of the
+// read-only aliases can't be changed, and the ones hidden by the rules
+// can't be touched at all. Nothing can be changed on the read-only
+// servers. The rules that only apply to some methods are checked with
+// the methods of the changes: POST for the uploads, POST and MKCOL for
+// the directories, DELETE for the deletions, PATCH for the moves and PUT
+// for the edits.
+func (d *data) capabilities(p string) files.Capabilities {
+	p = path.Clean("/" + p)
+	visible := d.Check(p)
+	_, readOnly := d.area(p)
+	writable := visible && !readOnly && !d.server.ReadOnly
+	perm := d.user.Perm
+
+	allows := func(methods ...string) bool {
+		for _, method := range methods {
+			if !d.checkMethod(p, method) {
+				return false
+			}
+		}
+
+		return writable
+	}
+
+	return files.Capabilities{
+		CanUpload:          allows(http.MethodPost) && perm.Create,
+		CanMkdir:           allows(http.MethodPost, "MKCOL") && perm.Create,
+		CanDelete:          allows(http.MethodDelete) && perm.Delete,
+		CanRename:          allows(http.MethodPatch) && perm.Rename,
+		CanEdit:            allows(http.MethodPut) && perm.Modify,
+		CanDownloadArchive: visible && perm.Download,
+		CanShare:           visible && perm.Share,
+	}
+}
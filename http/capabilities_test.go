@@ -0,0 +1,155 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/files"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// capabilityRequests are the requests each capability allows, in the
+// directory /docs.
+var capabilityRequests = []struct {
+	name    string
+	allowed func(files.Capabilities) bool
+	method  string
+	target  string
+	body    string
+}{
+	{"upload", func(c files.Capabilities) bool { return c.CanUpload }, "POST", "/api/resources/docs/new.txt", "new"},
+	{"mkdir", func(c files.Capabilities) bool { return c.CanMkdir }, "POST", "/api/resources/docs/new/", ""},
+	{"delete", func(c files.Capabilities) bool { return c.CanDelete }, "DELETE", "/api/resources/docs/a.txt?purge=true", ""},
+	{"rename", func(c files.Capabilities) bool { return c.CanRename }, "PATCH", "/api/resources/docs/b.txt?action=rename&destination=/docs/c.txt", ""},
+	{"edit", func(c files.Capabilities) bool { return c.CanEdit }, "PUT", "/api/resources/docs/d.txt", "changed"},
+	{"archive", func(c files.Capabilities) bool { return c.CanDownloadArchive }, "GET", "/api/raw/docs/?algo=zip", ""},
+	{"share", func(c files.Capabilities) bool { return c.CanShare }, "POST", "/api/share/docs/e.txt", ""},
+}
+
+// refused checks if the request was refused. The read-only servers refuse
+// the changes as methods they don't allow, and the downloads without the
+// permission are accepted without sending anything, as they always were.
+func refused(w *httptest.ResponseRecorder) bool {
+	switch w.Code {
+	case http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusAccepted:
+		return true
+	}
+
+	return false
+}
+
+func TestCapabilities(t *testing.T) {
+	all := files.Capabilities{
+		CanUpload: true, CanMkdir: true, CanDelete: true, CanRename: true,
+		CanEdit: true, CanDownloadArchive: true, CanShare: true,
+	}
+	readOnly := files.Capabilities{CanDownloadArchive: true, CanShare: true}
+
+	denyOn := func(methods ...string) func(*users.User) {
+		return func(u *users.User) {
+			u.Rules = []rules.Rule{{Path: "/docs", Methods: methods}}
+		}
+	}
+
+	tests := []struct {
+		name     string
+		user     func(*users.User)
+		readOnly bool
+		want     files.Capabilities
+	}{
+		{"admin", func(*users.User) {}, false, all},
+		{"no create", func(u *users.User) { u.Perm.Create = false }, false, func() files.Capabilities {
+			c := all
+			c.CanUpload, c.CanMkdir = false, false
+			return c
+		}()},
+		{"no delete", func(u *users.User) { u.Perm.Delete = false }, false, func() files.Capabilities {
+			c := all
+			c.CanDelete = false
+			return c
+		}()},
+		{"no rename", func(u *users.User) { u.Perm.Rename = false }, false, func() files.Capabilities {
+			c := all
+			c.CanRename = false
+			return c
+		}()},
+		{"no modify", func(u *users.User) { u.Perm.Modify = false }, false, func() files.Capabilities {
+			c := all
+			c.CanEdit = false
+			return c
+		}()},
+		{"no download", func(u *users.User) { u.Perm.Download = false }, false, func() files.Capabilities {
+			c := all
+			c.CanDownloadArchive = false
+			return c
+		}()},
+		{"no share", func(u *users.User) { u.Perm.Share = false }, false, func() files.Capabilities {
+			c := all
+			c.CanShare = false
+			return c
+		}()},
+		{"rule on deletions", denyOn("DELETE"), false, func() files.Capabilities {
+			c := all
+			c.CanDelete = false
+			return c
+		}()},
+		{"rule on edits", denyOn("PUT"), false, func() files.Capabilities {
+			c := all
+			c.CanEdit = false
+			return c
+		}()},
+		{"rule on the directories", denyOn("MKCOL"), false, func() files.Capabilities {
+			c := all
+			c.CanMkdir = false
+			return c
+		}()},
+		{"rule on the changes", denyOn("POST", "PUT", "PATCH", "DELETE"), false, readOnly},
+		{"read-only server", func(*users.User) {}, true, readOnly},
+		{"nothing", func(u *users.User) { u.Perm = users.Permissions{} }, false, files.Capabilities{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, map[string]filebrowsertest.File{
+				"/docs/a.txt": {Content: "a"},
+				"/docs/b.txt": {Content: "b"},
+				"/docs/d.txt": {Content: "d"},
+				"/docs/e.txt": {Content: "e"},
+			})
+			updateUser(t, srv, tt.user)
+			if tt.readOnly {
+				if err := srv.Handler.(*fbhttp.Handler).Reload(&settings.Server{Root: "/", ReadOnly: true}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			listing, err := srv.Listing("/docs")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if listing.Capabilities == nil {
+				t.Fatal("the listing has no capabilities")
+			}
+			if got := *listing.Capabilities; got != tt.want {
+				t.Errorf("the capabilities are %+v, want %+v", got, tt.want)
+			}
+
+			// The handlers allow exactly what the listing says.
+			for _, req := range capabilityRequests {
+				w := do(t, srv, req.method, req.target, req.body, "X-CSRF-Token", csrfToken(t, srv))
+				allowed := req.allowed(*listing.Capabilities)
+				if allowed && w.Code >= 300 {
+					t.Errorf("%s is allowed, but the request got %d: %s", req.name, w.Code, w.Body)
+				}
+				if !allowed && !refused(w) {
+					t.Errorf("%s isn't allowed, but the request got %d: %s", req.name, w.Code, w.Body)
+				}
+			}
+		})
+	}
+}
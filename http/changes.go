@@ -0,0 +1,129 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// maxSnapshots bounds the number of listings kept to detect deletions.
+const maxSnapshots = 1024
+
+// snapshots keeps the paths found by the last poll of each user on each
+// directory when the changes are tracked.
+var snapshots = struct {
+	sync.Mutex
+	m map[string]map[string]bool
+}{m: map[string]map[string]bool{}}
+
+// swapSnapshot stores the paths of a poll and returns the ones from the
+// previous poll that are now gone. The second value is false if there
+// wasn't a previous poll.
+func swapSnapshot(key string, seen []string) ([]string, bool) {
+	current := make(map[string]bool, len(seen))
+	for _, p := range seen {
+		current[p] = true
+	}
+
+	snapshots.Lock()
+	previous, ok := snapshots.m[key]
+	if !ok && len(snapshots.m) >= maxSnapshots {
+		snapshots.m = map[string]map[string]bool{}
+	}
+	snapshots.m[key] = current
+	snapshots.Unlock()
+
+	deleted := []string{}
+	for p := range previous {
+		if !current[p] {
+			deleted = append(deleted, p)
+		}
+	}
+
+	return deleted, ok
+}
+
+// resetSnapshots forgets all the snapshots, so the next polls don't
+// report deletions.
+func resetSnapshots() {
+	snapshots.Lock()
+	snapshots.m = map[string]map[string]bool{}
+	snapshots.Unlock()
+}
+
+// snapshotsLen returns the number of directories whose changes are
+// tracked.
+func snapshotsLen() int {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	return len(snapshots.m)
+}
+
+type changesData struct {
+	Since   time.Time         `json:"since"`
+	Now     time.Time         `json:"now"`
+	Items   []*files.FileInfo `json:"items"`
+	Deleted []string          `json:"deleted"`
+}
+
+// renderChanges renders the entries of a directory modified after the
+// instant in the changes_since query parameter. The deleted entries are
+// only reported when the changes are tracked on the server.
+func renderChanges(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	since, err := time.Parse(time.RFC3339, r.URL.Query().Get("changes_since"))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+
+	maxDepth, _ := d.settings.Tree.Limits()
+	depth := 0
+
+	if raw := r.URL.Query().Get("depth"); raw != "" {
+		depth, err = strconv.Atoi(raw)
+		if err != nil || depth < 0 {
+			return http.StatusBadRequest, err
+		}
+
+		if depth > maxDepth {
+			depth = maxDepth
+		}
+	}
+
+	now := time.Now().UTC()
+	changed, seen, err := files.Changes(files.ChangesOptions{
+		Fs:      d.user.Fs,
+		Path:    r.URL.Path,
+		Since:   since,
+		Depth:   depth,
+		Checker: d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	res := &changesData{
+		Since: since.UTC(),
+		Now:   now,
+		Items: changed,
+	}
+
+	if d.settings.TrackChanges {
+		key := strconv.FormatUint(uint64(d.user.ID), 10) + ":" + strconv.Itoa(depth) + ":" + r.URL.Path
+		if deleted, ok := swapSnapshot(key, seen); ok {
+			res.Deleted = deleted
+		} else {
+			w.Header().Set("X-Deletions-Tracked", "false")
+		}
+	} else {
+		w.Header().Set("X-Deletions-Tracked", "false")
+	}
+
+	return renderJSON(w, r, res)
+}
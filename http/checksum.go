@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// checksumResponse is the checksum of a file.
+type checksumResponse struct {
+	Path     string `json:"path"`
+	Algo     string `json:"algo"`
+	Checksum string `json:"checksum"`
+}
+
+// renderChecksum writes the checksum of the file with the algorithm of
+// the checksum query parameter. The file is streamed through the hash,
+// never read in memory.
+func renderChecksum(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	if !d.user.Perm.Download {
+		return http.StatusForbidden, nil
+	}
+
+	algo := r.URL.Query().Get("checksum")
+	err := file.Checksum(algo)
+	switch {
+	case err == errors.ErrInvalidOption:
+		return renderFailure(w, r, http.StatusBadRequest, "the checksums can be md5, sha1, sha256 or sha512")
+	case err == errors.ErrTooLarge:
+		return http.StatusBadRequest, err
+	case err == errors.ErrSpecialFile:
+		return http.StatusConflict, err
+	case err != nil:
+		return errToStatus(err), err
+	}
+
+	return renderJSON(w, r, &checksumResponse{
+		Path:     file.Path,
+		Algo:     algo,
+		Checksum: file.Checksums[algo],
+	})
+}
+
+// wantsChecksums returns the algorithm of the checksums of the files of
+// the JSON listings that the checksum query parameter asks for, if any.
+func wantsChecksums(r *http.Request, d *data) (string, error) {
+	algo := r.URL.Query().Get("checksum")
+	if algo == "" || listingFormat(r, d) != formatJSON {
+		return "", nil
+	}
+
+	if _, err := files.NewHash(algo); err != nil {
+		return "", err
+	}
+
+	return algo, nil
+}
+
+// annotateChecksums sets the checksums of the files of the listing with
+// algo. The files over the maximum size of the settings and the ones
+// that can't be read are left without theirs.
+func annotateChecksums(d *data, listing *files.Listing, algo string) {
+	limit := d.settings.ChecksumLimit()
+	for _, item := range listing.Items {
+		if item.IsDir || item.Error || item.Special != "" || item.Size > limit {
+			continue
+		}
+
+		if err := item.Checksum(algo); err != nil {
+			d.logger.Debug("couldn't checksum the file", "path", item.Path, "error", err)
+		}
+	}
+}
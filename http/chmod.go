@@ -0,0 +1,146 @@
+package http
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// pathFailure is a path that couldn't be changed, such as its mode or
+// its copy, and why.
+type pathFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// chmodResponse is the result of a change of mode: the number of paths
+// changed and the ones that failed, which only the recursive changes can
+// have.
+type chmodResponse struct {
+	Path    string        `json:"path"`
+	Mode    string        `json:"mode"`
+	Changed int           `json:"changed"`
+	Failed  []pathFailure `json:"failed"`
+}
+
+// parseMode returns the mode of the octal permission bits s, such as
+// 0644 or 755.
+func parseMode(s string) (os.FileMode, bool) {
+	bits, err := strconv.ParseUint(s, 8, 32)
+	if s == "" || err != nil || bits > 07777 {
+		return 0, false
+	}
+
+	return files.FileMode(uint32(bits)), true
+}
+
+// chmodPaths returns p and, if it's recursive, the paths under it, the
+// deepest first, so the directories are changed after their contents and
+// a mode that can't be listed doesn't stop the walk.
+func chmodPaths(fs afero.Fs, p string, recursive bool) ([]string, error) {
+	if !recursive {
+		return []string{p}, nil
+	}
+
+	var paths []string
+	err := afero.Walk(fs, p, func(name string, info os.FileInfo, err error) error {
+		if err != nil && name == p {
+			return err
+		}
+
+		paths = append(paths, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(paths)-1; i < j; i, j = i+1, j-1 {
+		paths[i], paths[j] = paths[j], paths[i]
+	}
+
+	return paths, nil
+}
+
+// chmodHandler changes the mode of the file of the request to the one of
+// its PATCH body and, for the recursive changes, the ones of the files
+// under it, reporting the paths that failed. The setuid, setgid and
+// sticky bits are refused unless the settings allow them, and the files
+// of the read-only aliases, like the ones of the read-only servers, can't
+// be changed. The symbolic links are skipped, since chmod would change
+// their targets. The modes can't be changed on Windows.
+func chmodHandler(w http.ResponseWriter, r *http.Request, d *data, req *patchRequest) (int, error) {
+	if runtime.GOOS == "windows" {
+		return renderFailure(w, r, http.StatusNotImplemented, "the modes of the files can't be changed on Windows")
+	}
+
+	mode, ok := parseMode(req.Mode)
+	if !ok {
+		return renderFailure(w, r, http.StatusBadRequest, "invalid mode: it must be octal, such as 0644")
+	}
+
+	if mode&files.SpecialBits != 0 && !d.settings.AllowSpecialBits {
+		return renderFailure(w, r, http.StatusForbidden, "the setuid, setgid and sticky bits aren't allowed")
+	}
+
+	p := path.Clean("/" + r.URL.Path)
+	if _, readOnly := d.area(p); readOnly {
+		w.Header().Set("Allow", readOnlyAllow)
+		return renderFailure(w, r, http.StatusMethodNotAllowed, "the files are read-only")
+	}
+
+	if !d.capabilities(p).CanEdit {
+		return http.StatusForbidden, nil
+	}
+
+	info, err := d.user.Fs.Stat(p)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	release, err := lockPath(d, p)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer release()
+
+	paths, err := chmodPaths(d.user.Fs, p, req.Recursive && info.IsDir())
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	res := &chmodResponse{Path: p, Mode: files.OctalMode(mode), Failed: []pathFailure{}}
+	for _, name := range paths {
+		var err error
+		switch {
+		case !d.Check(name):
+			continue
+		case !d.capabilities(name).CanEdit:
+			err = os.ErrPermission
+		case isLink(d.user.Fs, name):
+			err = errors.ErrSymlink
+		default:
+			err = d.user.Fs.Chmod(name, mode)
+		}
+
+		if err != nil {
+			res.Failed = append(res.Failed, pathFailure{Path: name, Error: err.Error()})
+			continue
+		}
+		res.Changed++
+	}
+
+	listings.forget(d, p)
+	if len(paths) == 1 && len(res.Failed) == 1 {
+		return renderFailure(w, r, http.StatusForbidden, res.Failed[0].Error)
+	}
+
+	return renderJSON(w, r, res)
+}
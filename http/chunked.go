@@ -0,0 +1,343 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	fbErrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+const (
+	// uploadOffsetHeader tells how many bytes of a chunked upload the
+	// server has, from which it can be resumed.
+	uploadOffsetHeader = "Upload-Offset"
+	// uploadLengthHeader tells the size of the file of a chunked upload.
+	uploadLengthHeader = "Upload-Length"
+	// stagedExt is the extension of the files of the chunked uploads in
+	// the spool.
+	stagedExt = ".upload"
+	// stagedSweepEvery is how often, at most, the spool is swept for the
+	// stale uploads.
+	stagedSweepEvery = time.Minute
+)
+
+// contentRange is the range of a chunk, in the Content-Range header.
+type contentRange struct {
+	start, end, total int64
+}
+
+// parseContentRange parses the complete ranges of the form bytes
+// start-end/total, whose total must be known.
+func parseContentRange(s string) (contentRange, bool) {
+	var cr contentRange
+	spec := strings.TrimPrefix(s, "bytes ")
+	slash := strings.Index(spec, "/")
+	if spec == s || slash < 0 {
+		return cr, false
+	}
+
+	bounds, total := spec[:slash], spec[slash+1:]
+	dash := strings.Index(bounds, "-")
+	if dash < 0 {
+		return cr, false
+	}
+
+	start, end := bounds[:dash], bounds[dash+1:]
+
+	var err [3]error
+	cr.start, err[0] = strconv.ParseInt(start, 10, 64)
+	cr.end, err[1] = strconv.ParseInt(end, 10, 64)
+	cr.total, err[2] = strconv.ParseInt(total, 10, 64)
+	for _, e := range err {
+		if e != nil {
+			return cr, false
+		}
+	}
+
+	return cr, cr.start >= 0 && cr.start <= cr.end && cr.end < cr.total
+}
+
+// staged are the chunked uploads whose chunks are being written, by the
+// names of their files in the spool, so a chunk is only written at once.
+var staged = struct {
+	sync.Mutex
+	busy map[string]bool
+	// swept is when the spool was last swept, in Unix nanoseconds.
+	swept int64
+}{busy: map[string]bool{}}
+
+// stagedName returns the name of the file of the chunked upload of p with
+// id in the spool, of total bytes, or with the glob of its total if
+// total is negative. The names are hashed, so the ids and the paths
+// can't escape the spool.
+func stagedName(d *data, p, id string, total int64) string {
+	sum := sha256.Sum256([]byte(strconv.FormatUint(uint64(d.user.ID), 10) + "\x00" + p + "\x00" + id))
+	size := "*"
+	if total >= 0 {
+		size = strconv.FormatInt(total, 10)
+	}
+
+	return filepath.Join(d.server.UploadSpool, hex.EncodeToString(sum[:])+"."+size+stagedExt)
+}
+
+// findStaged returns the file of the chunked upload of p with id in the
+// spool and its total, or an empty name if there's none.
+func findStaged(d *data, p, id string) (string, int64) {
+	matches, _ := filepath.Glob(stagedName(d, p, id, -1))
+	for _, name := range matches {
+		total := filepath.Ext(strings.TrimSuffix(name, stagedExt))
+		if n, err := strconv.ParseInt(strings.TrimPrefix(total, "."), 10, 64); err == nil {
+			return name, n
+		}
+	}
+
+	return "", 0
+}
+
+// sweepStaged removes the files of the chunked uploads of dir that had no
+// chunk for ttl, at most once in stagedSweepEvery. The ones being written
+// are kept.
+func sweepStaged(dir string, ttl time.Duration) {
+	now := time.Now()
+	last := atomic.LoadInt64(&staged.swept)
+	if now.Sub(time.Unix(0, last)) < stagedSweepEvery || !atomic.CompareAndSwapInt64(&staged.swept, last, now.UnixNano()) {
+		return
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*"+stagedExt))
+	for _, name := range matches {
+		info, err := os.Stat(name)
+		if err != nil || now.Sub(info.ModTime()) < ttl {
+			continue
+		}
+
+		staged.Lock()
+		if !staged.busy[name] {
+			os.Remove(name)
+		}
+		staged.Unlock()
+	}
+}
+
+// chunkOffsetHandler answers the HEAD requests of the chunked uploads,
+// with their X-Upload-ID, with the number of bytes the server has in
+// Upload-Offset and the size of the file in Upload-Length, so the
+// clients know where to resume them.
+func chunkOffsetHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if d.server.UploadSpool == "" {
+		return http.StatusNotImplemented, nil
+	}
+
+	p := path.Clean("/" + r.URL.Path)
+	if !d.Check(p) {
+		return http.StatusNotFound, nil
+	}
+
+	name, total := findStaged(d, p, r.Header.Get("X-Upload-ID"))
+	if name == "" {
+		return http.StatusNotFound, nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(info.Size(), 10))
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(total, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	return 0, nil
+}
+
+// chunkHandler writes the chunk of the PUT request, whose range is in its
+// Content-Range header, to the file of its upload in the spool, keyed by
+// the path, the user and the X-Upload-ID header. The chunks must come in
+// order: the ones that don't start where the stored bytes end, such as
+// the ones out of order or overlapping, fail with 409 and the offset to
+// resume from. The chunks cut short keep their bytes, so they can be
+// resumed too. Once the file is complete, it's moved into place, through
+// the hooks of the uploads, and the request answers with 201 or 204 like
+// the other replacements; before, it answers with 202 and the offset.
+// The chunks of the complete uploads only move them, such as when it
+// failed before.
+func chunkHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	spool := d.server.UploadSpool
+	if spool == "" {
+		return renderFailure(w, r, http.StatusNotImplemented, "the server has no upload spool: the files can only be uploaded at once")
+	}
+
+	cr, ok := parseContentRange(r.Header.Get("Content-Range"))
+	if !ok {
+		w.Header().Set("Content-Range", "bytes */*")
+		return renderFailure(w, r, http.StatusRequestedRangeNotSatisfiable, "invalid range: it must be bytes start-end/total")
+	}
+
+	id := r.Header.Get("X-Upload-ID")
+	if id == "" || len(id) > maxUploadIDLength {
+		return renderFailure(w, r, http.StatusBadRequest, "the chunked uploads need an X-Upload-ID")
+	}
+
+	length := cr.end - cr.start + 1
+	if r.ContentLength >= 0 && r.ContentLength != length {
+		return renderFailure(w, r, http.StatusBadRequest, "the length of the chunk doesn't match its range")
+	}
+
+	p := path.Clean("/" + r.URL.Path)
+	if maxSize := d.settings.Uploads.MaxSize; maxSize > 0 && cr.total > maxSize {
+		return renderFileFailure(w, r, http.StatusRequestEntityTooLarge, p, fbErrors.ErrTooLarge.Error())
+	}
+
+	var mode os.FileMode
+	info, err := d.user.Fs.Stat(p)
+	created := os.IsNotExist(err)
+	capabilities := d.capabilities(p)
+	switch {
+	case err != nil && !created:
+		return errToStatus(err), err
+	case created && !capabilities.CanUpload, !created && !capabilities.CanEdit:
+		return http.StatusForbidden, nil
+	case created:
+	case info.IsDir():
+		return http.StatusMethodNotAllowed, nil
+	default:
+		mode = info.Mode().Perm()
+	}
+
+	if err := os.MkdirAll(spool, 0700); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	sweepStaged(spool, d.settings.Uploads.ChunkLifetime())
+
+	name := stagedName(d, p, id, cr.total)
+	staged.Lock()
+	busy := staged.busy[name]
+	staged.busy[name] = true
+	staged.Unlock()
+	if busy {
+		return renderFailure(w, r, http.StatusConflict, "a chunk of the upload is being written")
+	}
+	defer func() {
+		staged.Lock()
+		delete(staged.busy, name)
+		staged.Unlock()
+	}()
+
+	if other, total := findStaged(d, p, id); other != "" && other != name {
+		return renderFailure(w, r, http.StatusConflict, fmt.Sprintf("the upload is of %d bytes", total))
+	}
+
+	offset := int64(0)
+	if info, err := os.Stat(name); err == nil {
+		offset = info.Size()
+	}
+
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+	if offset == cr.total {
+		return finishChunks(w, d, name, p, mode, created, cr.total)
+	}
+
+	if cr.start != offset {
+		return renderFailure(w, r, http.StatusConflict, fmt.Sprintf("the chunk must start at %d", offset))
+	}
+
+	// The spool is private, so the files get the mode of the new files
+	// when they're renamed into place.
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0775)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	n, err := io.Copy(file, countUploaded(d, io.LimitReader(r.Body, length)))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	offset += n
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if offset < cr.total {
+		w.WriteHeader(http.StatusAccepted)
+		return 0, nil
+	}
+
+	return finishChunks(w, d, name, p, mode, created, cr.total)
+}
+
+// finishChunks moves the complete file of a chunked upload into place and
+// answers like the other replacements.
+func finishChunks(w http.ResponseWriter, d *data, name, p string, mode os.FileMode, created bool, size int64) (int, error) {
+	info, err := finishStaged(d, name, p, mode, size)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	w.Header().Set("ETag", currentETag(d.user.Fs, p, info.ModTime(), info.Size()))
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return 0, nil
+}
+
+// finishStaged moves the complete file of a chunked upload, of size
+// bytes, from the spool to p through the hooks of the uploads. It's
+// renamed into place when the spool is on the same disk as p, and copied
+// next to p first otherwise, so p is never seen half written either way.
+// The files that are replaced keep their modes.
+func finishStaged(d *data, name, p string, mode os.FileMode, size int64) (os.FileInfo, error) {
+	release, err := lockPath(d, p)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var info os.FileInfo
+	err = d.RunHook(func() error {
+		if mode != 0 {
+			if err := os.Chmod(name, mode); err != nil {
+				return err
+			}
+		}
+
+		if local, ok := d.user.LocalPath(p); ok && os.Rename(name, local) == nil {
+			info, err = d.user.Fs.Stat(p)
+			return err
+		}
+
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		info, err = writeTemp(d, p, ".upload-", file, mode, 0)
+		if err == nil {
+			os.Remove(name)
+		}
+		return err
+	}, "upload", p, "", size, d.user)
+	if err != nil {
+		return nil, err
+	}
+
+	d.notify(settings.EventUpload, p, "", info.Size())
+	return info, nil
+}
@@ -3,7 +3,6 @@ package http
 import (
 	"bufio"
 	"io"
-	"log"
 	"net/http"
 	"os/exec"
 	"strings"
@@ -22,10 +21,10 @@ var (
 	cmdNotAllowed = []byte("Command not allowed.")
 )
 
-func wsErr(ws *websocket.Conn, r *http.Request, status int, err error) {
+func wsErr(ws *websocket.Conn, r *http.Request, d *data, status int, err error) {
 	txt := http.StatusText(status)
 	if err != nil || status >= 400 {
-		log.Printf("%s: %v %s %v", r.URL.Path, status, r.RemoteAddr, err)
+		d.logger.Error("command failed", "path", r.URL.Path, "status", status, "client", d.clientAddr(r), "error", err)
 	}
 	ws.WriteControl(websocket.CloseInternalServerErr, []byte(txt), time.Now().Add(10*time.Second))
 }
@@ -42,7 +41,7 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
-			wsErr(conn, r, http.StatusInternalServerError, err)
+			wsErr(conn, r, d, http.StatusInternalServerError, err)
 			return 0, nil
 		}
 
@@ -55,7 +54,7 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 	if !d.user.CanExecute(strings.Split(raw, " ")[0]) {
 		err := conn.WriteMessage(websocket.TextMessage, cmdNotAllowed)
 		if err != nil {
-			wsErr(conn, r, http.StatusInternalServerError, err)
+			wsErr(conn, r, d, http.StatusInternalServerError, err)
 		}
 
 		return 0, nil
@@ -65,7 +64,7 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 	if err != nil {
 		err := conn.WriteMessage(websocket.TextMessage, []byte(err.Error()))
 		if err != nil {
-			wsErr(conn, r, http.StatusInternalServerError, err)
+			wsErr(conn, r, d, http.StatusInternalServerError, err)
 		}
 
 		return 0, nil
@@ -76,18 +75,18 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		wsErr(conn, r, http.StatusInternalServerError, err)
+		wsErr(conn, r, d, http.StatusInternalServerError, err)
 		return 0, nil
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		wsErr(conn, r, http.StatusInternalServerError, err)
+		wsErr(conn, r, d, http.StatusInternalServerError, err)
 		return 0, nil
 	}
 
 	if err := cmd.Start(); err != nil {
-		wsErr(conn, r, http.StatusInternalServerError, err)
+		wsErr(conn, r, d, http.StatusInternalServerError, err)
 		return 0, nil
 	}
 
@@ -97,7 +96,7 @@ var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *d
 	}
 
 	if err := cmd.Wait(); err != nil {
-		wsErr(conn, r, http.StatusInternalServerError, err)
+		wsErr(conn, r, d, http.StatusInternalServerError, err)
 	}
 
 	return 0, nil
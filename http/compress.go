@@ -0,0 +1,207 @@
+package http
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the size under which the responses aren't
+// compressed, since they'd hardly get smaller. It's about a packet.
+const minCompressSize = 1400
+
+// compressor is what gzip.Writer and flate.Writer have in common.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressors keep the writers of the content codings the responses can
+// be compressed with, so they aren't allocated for every response.
+var compressors = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}},
+	"deflate": {New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	}},
+}
+
+// encodingQuality returns the quality the client gives to the content
+// coding in its Accept-Encoding, or the one of *, or zero.
+func encodingQuality(r *http.Request, coding string) float64 {
+	wildcard := 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.SplitN(strings.TrimSpace(part), ";", 2)
+		name := strings.TrimSpace(params[0])
+
+		q := 1.0
+		if len(params) == 2 && strings.HasPrefix(strings.TrimSpace(params[1]), "q=") {
+			value := strings.TrimPrefix(strings.TrimSpace(params[1]), "q=")
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				q = 0
+			}
+		}
+
+		switch {
+		case strings.EqualFold(name, coding):
+			return q
+		case name == "*":
+			wildcard = q
+		}
+	}
+
+	return wildcard
+}
+
+// responseEncoding returns the content coding the response to the
+// request is compressed with, gzip unless the client prefers deflate, or
+// an empty string if it accepts neither.
+func responseEncoding(r *http.Request) string {
+	gzipQ, deflateQ := encodingQuality(r, "gzip"), encodingQuality(r, "deflate")
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// compressWriter compresses the response it's written, for the clients
+// that accept it. The body is buffered until it's larger than
+// minCompressSize, so the smaller ones are written as they are, with
+// their Content-Length. The responses that already have a
+// Content-Encoding, such as the ones of a compressing middleware around
+// the handler, are never compressed twice. Close must be called once the
+// response is written.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	started  bool
+	zw       compressor
+}
+
+// compressResponse returns the writer the response to the request is
+// written to, which compresses it if the client accepts it.
+func compressResponse(w http.ResponseWriter, r *http.Request) *compressWriter {
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressWriter{ResponseWriter: w, encoding: responseEncoding(r)}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	if !bodyAllowed(status) {
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.started {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < minCompressSize {
+			return len(p), nil
+		}
+
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+
+		return len(p), nil
+	}
+
+	if w.zw != nil {
+		return w.zw.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// start sends the headers, compressing the rest of the response if it
+// can be, and writes what was buffered.
+func (w *compressWriter) start(compress bool) error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+
+	header := w.Header()
+	if compress && w.encoding != "" && header.Get("Content-Encoding") == "" {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		w.zw = compressors[w.encoding].Get().(compressor)
+		w.zw.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if w.zw != nil {
+		_, err = w.zw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// Flush implements http.Flusher for the responses that are streamed.
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		return
+	}
+
+	if err := w.start(true); err != nil {
+		return
+	}
+
+	if w.zw != nil {
+		w.zw.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes what's left of the response. Nothing is written if the
+// handler wrote nothing, so it can still answer with an error.
+func (w *compressWriter) Close() error {
+	if w.status == 0 {
+		return nil
+	}
+
+	if err := w.start(false); err != nil {
+		return err
+	}
+
+	if w.zw == nil {
+		return nil
+	}
+
+	err := w.zw.Close()
+	w.zw.Reset(nil)
+	compressors[w.encoding].Put(w.zw)
+	w.zw = nil
+	return err
+}
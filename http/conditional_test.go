@@ -0,0 +1,271 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// fileETag returns the ETag the editors read the file at target with.
+func fileETag(t *testing.T, srv *filebrowsertest.Server, target string) string {
+	t.Helper()
+
+	w := do(t, srv, "GET", "/api/resources"+target+"?content=true", "", "Accept", "application/json")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET %s = %d with the ETag %q", target, w.Code, etag)
+	}
+
+	return etag
+}
+
+func TestConditionalWrites(t *testing.T) {
+	modTime := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		target   string
+		headers  func(etag string) []string
+		required bool // the settings require conditional writes
+		status   int
+		want     string // the content of the target after the write
+	}{
+		{"current If-Match", "/a.txt", func(etag string) []string { return []string{"If-Match", etag} }, false, http.StatusNoContent, "new"},
+		{"one of the If-Match", "/a.txt", func(etag string) []string { return []string{"If-Match", `"other", ` + etag} }, false, http.StatusNoContent, "new"},
+		{"any If-Match", "/a.txt", func(string) []string { return []string{"If-Match", "*"} }, false, http.StatusNoContent, "new"},
+		{"stale If-Match", "/a.txt", func(string) []string { return []string{"If-Match", `"other"`} }, false, http.StatusPreconditionFailed, "old"},
+		{"weak If-Match", "/a.txt", func(etag string) []string { return []string{"If-Match", "W/" + etag} }, false, http.StatusPreconditionFailed, "old"},
+		{"If-Match of a new file", "/new.txt", func(etag string) []string { return []string{"If-Match", etag} }, false, http.StatusPreconditionFailed, ""},
+		{"fresh If-Unmodified-Since", "/a.txt", func(string) []string {
+			return []string{"If-Unmodified-Since", modTime.Format(http.TimeFormat)}
+		}, false, http.StatusNoContent, "new"},
+		{"stale If-Unmodified-Since", "/a.txt", func(string) []string {
+			return []string{"If-Unmodified-Since", modTime.Add(-time.Second).Format(http.TimeFormat)}
+		}, false, http.StatusPreconditionFailed, "old"},
+		{"If-Match over If-Unmodified-Since", "/a.txt", func(etag string) []string {
+			return []string{"If-Match", etag, "If-Unmodified-Since", modTime.Add(-time.Second).Format(http.TimeFormat)}
+		}, false, http.StatusNoContent, "new"},
+		{"If-None-Match of an existing file", "/a.txt", func(string) []string { return []string{"If-None-Match", "*"} }, false, http.StatusPreconditionFailed, "old"},
+		{"If-None-Match of a new file", "/new.txt", func(string) []string { return []string{"If-None-Match", "*"} }, false, http.StatusCreated, "new"},
+		{"unconditional", "/a.txt", func(string) []string { return nil }, false, http.StatusNoContent, "new"},
+		{"unconditional when required", "/a.txt", func(string) []string { return nil }, true, http.StatusPreconditionRequired, "old"},
+		{"conditional when required", "/a.txt", func(etag string) []string { return []string{"If-Match", etag} }, true, http.StatusNoContent, "new"},
+		{"new file when required", "/new.txt", func(string) []string { return nil }, true, http.StatusCreated, "new"},
+		{"If-None-Match of a new file when required", "/new.txt", func(string) []string { return []string{"If-None-Match", "*"} }, true, http.StatusCreated, "new"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "old", ModTime: modTime}})
+			if tt.required {
+				updateSettings(t, srv, func(s *settings.Settings) { s.RequireConditionalWrites = true })
+			}
+
+			etag := fileETag(t, srv, "/a.txt")
+			headers := append([]string{"Accept", "application/json"}, tt.headers(etag)...)
+			w := do(t, srv, "PUT", "/api/resources"+tt.target, "new", headers...)
+			if w.Code != tt.status {
+				t.Fatalf("PUT = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			content, err := afero.ReadFile(fs, tt.target)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("%s was written: %q", tt.target, content)
+				}
+			} else if string(content) != tt.want {
+				t.Errorf("%s has %q, want %q (%v)", tt.target, content, tt.want, err)
+			}
+
+			// The writes answer with the ETag of the new contents, and the
+			// failed preconditions with the one of the current file, so
+			// the clients don't have to read it again to know it.
+			if w.Code < 300 || w.Code == http.StatusPreconditionFailed && tt.want != "" {
+				if got, current := w.Header().Get("ETag"), fileETag(t, srv, tt.target); got != current {
+					t.Errorf("the ETag is %q, want the current one %q", got, current)
+				}
+			}
+
+			if w.Code != http.StatusPreconditionFailed || tt.want == "" {
+				return
+			}
+
+			var stale struct {
+				Status  int    `json:"status"`
+				ETag    string `json:"etag"`
+				Current struct {
+					Path string `json:"path"`
+					Size int64  `json:"size"`
+				} `json:"current"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &stale); err != nil {
+				t.Fatalf("the 412 isn't JSON: %v: %s", err, w.Body)
+			}
+			if stale.Status != http.StatusPreconditionFailed || stale.ETag != etag || stale.Current.Path != tt.target || stale.Current.Size != 3 {
+				t.Errorf("the 412 is %+v, want the current file with the ETag %q", stale, etag)
+			}
+		})
+	}
+}
+
+func TestConditionalWritesETags(t *testing.T) {
+	srv, fs := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "old"}})
+
+	// The editors, the downloads and the JSON reads share the ETag, which
+	// is a strong one.
+	etag := fileETag(t, srv, "/a.txt")
+	if etag[0] != '"' {
+		t.Errorf("the ETag %q is weak", etag)
+	}
+
+	for _, target := range []string{"/api/raw/a.txt", "/api/resources/a.txt"} {
+		w := do(t, srv, "GET", target, "", "Accept", "application/json")
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("GET %s has the ETag %q, want %q", target, got, etag)
+		}
+	}
+
+	// The edits change it, even the ones that keep the size and the time
+	// of the file, as on the filesystems with coarse times.
+	w := do(t, srv, "PUT", "/api/resources/a.txt", "new", "If-Match", etag)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("PUT = %d: %s", w.Code, w.Body)
+	}
+	if err := fs.Chtimes("/a.txt", filebrowsertest.DefaultModTime, filebrowsertest.DefaultModTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := fileETag(t, srv, "/a.txt"); got == etag {
+		t.Errorf("the ETag %q didn't change with the contents", got)
+	}
+}
+
+// putRequest returns a PUT of body on target, with If-Match.
+func putRequest(target, etag string, body io.Reader) *http.Request {
+	r := httptest.NewRequest("PUT", "/api/resources"+target, body)
+	r.Header.Set("If-Match", etag)
+	return r
+}
+
+// TestConditionalWritesRace runs writes with the same If-Match while the
+// first one writes: only it succeeds, and the others are told the file
+// changed since they read it.
+func TestConditionalWritesRace(t *testing.T) {
+	srv, fs := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "old"}})
+	etag := fileETag(t, srv, "/a.txt")
+
+	// The first write is stopped in the middle of its body, once its
+	// precondition passed.
+	body, writer := io.Pipe()
+	first := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w, err := srv.Do(putRequest("/a.txt", etag, body))
+		if err != nil {
+			t.Error(err)
+		}
+		first <- w
+	}()
+	if _, err := writer.Write([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	const others = 8
+	var wg sync.WaitGroup
+	statuses := make(chan int, others)
+	for i := 0; i < others; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, err := srv.Do(putRequest("/a.txt", etag, strings.NewReader(fmt.Sprintf("other %d", i))))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses <- w.Code
+		}(i)
+	}
+
+	// The others are given the time to check their preconditions before
+	// the first one is done.
+	time.Sleep(100 * time.Millisecond)
+	writer.Close()
+	if w := <-first; w.Code != http.StatusNoContent {
+		t.Errorf("the first PUT = %d, want 204: %s", w.Code, w.Body)
+	}
+
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		if status != http.StatusPreconditionFailed {
+			t.Errorf("a concurrent PUT = %d, want 412", status)
+		}
+	}
+
+	content, err := afero.ReadFile(fs, "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first" {
+		t.Errorf("the file has %q, want the first write", content)
+	}
+}
+
+// TestConditionalWritesParallel runs many writes with the same If-Match at
+// once: exactly one of them succeeds.
+func TestConditionalWritesParallel(t *testing.T) {
+	srv, fs := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "old"}})
+	etag := fileETag(t, srv, "/a.txt")
+
+	const writes = 12
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	statuses := make([]int, writes)
+	for i := 0; i < writes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			w, err := srv.Do(putRequest("/a.txt", etag, strings.NewReader(fmt.Sprintf("write %d", i))))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses[i] = w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	for i, status := range statuses {
+		switch {
+		case status == http.StatusNoContent && winner == -1:
+			winner = i
+		case status == http.StatusNoContent:
+			t.Errorf("the writes %d and %d both succeeded", winner, i)
+		case status != http.StatusPreconditionFailed:
+			t.Errorf("the write %d = %d, want 204 or 412", i, status)
+		}
+	}
+	if winner == -1 {
+		t.Fatal("no write succeeded")
+	}
+
+	content, err := afero.ReadFile(fs, "/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("write %d", winner); string(content) != want {
+		t.Errorf("the file has %q, want %q", content, want)
+	}
+}
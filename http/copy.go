@@ -0,0 +1,121 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+)
+
+// copyResponse is the result of a copy: the copy, as a row of the
+// listings, and the paths under the original that couldn't be copied.
+type copyResponse struct {
+	*listedFile
+	Failed []pathFailure `json:"failed"`
+}
+
+// copiedDir is a directory of a copy, whose time is set once its contents
+// are copied.
+type copiedDir struct {
+	path    string
+	modTime time.Time
+}
+
+// copyTree copies src to dst with the modes and the modification times of
+// its files. The hidden files are copied like the others, since they're
+// only hidden from the listings, but the ones the rules of the user deny
+// are left out, so the copies can't show them elsewhere. The files under
+// src that fail don't stop the copy and are returned, and the ones copied
+// before are kept; only the failures of src itself are errors.
+func copyTree(d *data, src, dst string) ([]pathFailure, error) {
+	failed := []pathFailure{}
+	info, err := d.user.Fs.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return failed, fileutils.CopyFile(d.user.Fs, src, dst)
+	}
+
+	var dirs []copiedDir
+	err = afero.Walk(d.user.Fs, src, func(name string, info os.FileInfo, err error) error {
+		name = filepath.ToSlash(name)
+		target := dst + strings.TrimPrefix(name, src)
+		switch {
+		case err != nil && info == nil && name == src:
+			return err
+		case err != nil:
+			failed = append(failed, pathFailure{Path: name, Error: err.Error()})
+			return nil
+		case !d.Check(name) && info.IsDir():
+			return filepath.SkipDir
+		case !d.Check(name):
+			return nil
+		case info.IsDir():
+			if err := d.user.Fs.MkdirAll(target, info.Mode()); err != nil {
+				if name == src {
+					return err
+				}
+
+				failed = append(failed, pathFailure{Path: name, Error: err.Error()})
+				return filepath.SkipDir
+			}
+
+			dirs = append(dirs, copiedDir{path: target, modTime: info.ModTime()})
+			return nil
+		}
+
+		if err := fileutils.CopyFile(d.user.Fs, name, target); err != nil {
+			failed = append(failed, pathFailure{Path: name, Error: err.Error()})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The copies of their contents changed the times of the directories,
+	// so they're set from the deepest up.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d.user.Fs.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime)
+	}
+
+	return failed, nil
+}
+
+// renderCopied answers a copy to dst with 201 and, if the client accepts
+// JSON or sent it, the copy as a row of the listings with the paths that
+// failed.
+func renderCopied(w http.ResponseWriter, r *http.Request, d *data, dst string, failed []pathFailure) (int, error) {
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") && !hasJSONBody(r) {
+		w.WriteHeader(http.StatusCreated)
+		return 0, nil
+	}
+
+	file, err := newListedFile(r, d, dst)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	body, err := json.Marshal(copyResponse{listedFile: file, Failed: failed})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusCreated)
+	if _, err := w.Write(body); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
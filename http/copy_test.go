@@ -0,0 +1,178 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// tree lists the files under root with their modes, modification times
+// and contents, relative to root, so a copy can be compared with its
+// original.
+func tree(t *testing.T, fs afero.Fs, root string) string {
+	t.Helper()
+
+	var lines []string
+	err := afero.Walk(fs, root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		line := fmt.Sprintf("%s %v %s", strings.TrimPrefix(p, root), info.Mode(), info.ModTime().UTC().Format(time.RFC3339))
+		if info.Mode().IsRegular() {
+			content, err := afero.ReadFile(fs, p)
+			if err != nil {
+				return err
+			}
+			line += " " + string(content)
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestCopy(t *testing.T) {
+	old := time.Date(2019, time.March, 4, 5, 6, 7, 0, time.UTC)
+	template := map[string]filebrowsertest.File{
+		"/tpl":              {Mode: os.ModeDir | 0700, ModTime: old},
+		"/tpl/a.txt":        {Content: "a", Mode: 0600, ModTime: old},
+		"/tpl/.hidden":      {Content: "hidden", ModTime: old},
+		"/tpl/sub":          {Mode: os.ModeDir | 0750, ModTime: old.Add(time.Hour)},
+		"/tpl/sub/b.sh":     {Content: "b", Mode: 0755, ModTime: old.Add(2 * time.Hour)},
+		"/tpl/sub/.git":     {Mode: os.ModeDir | 0755, ModTime: old},
+		"/tpl/sub/.git/cfg": {Content: "cfg", ModTime: old},
+		"/other.txt":        {Content: "other"},
+	}
+
+	tests := []struct {
+		name   string
+		src    string
+		query  string
+		files  map[string]filebrowsertest.File // added to the template
+		rules  []rules.Rule
+		status int
+		want   string   // the tree of the copy, or "" to compare it with src
+		failed []string // the paths that failed to be copied
+	}{
+		{name: "file", src: "/tpl/a.txt", query: "destination=/copy.txt", status: http.StatusCreated},
+		{name: "directory with the hidden files", src: "/tpl", query: "destination=/copy", status: http.StatusCreated},
+		{name: "subdirectory", src: "/tpl/sub", query: "destination=/tpl/copy", status: http.StatusCreated},
+		{
+			name:   "denied files left out",
+			src:    "/tpl",
+			query:  "destination=/copy",
+			rules:  []rules.Rule{{Path: "/tpl/sub"}, {Path: "/tpl/a.txt"}},
+			status: http.StatusCreated,
+			want: strings.Join([]string{
+				" drwx------ 2019-03-04T05:06:07Z",
+				"/.hidden -rw-r--r-- 2019-03-04T05:06:07Z hidden",
+			}, "\n"),
+		},
+		{
+			name:   "failures",
+			src:    "/tpl",
+			query:  "destination=/copy",
+			files:  map[string]filebrowsertest.File{"/tpl/sub/broken.txt": {Err: errors.New("broken")}},
+			status: http.StatusCreated,
+			want: strings.Join([]string{
+				" drwx------ 2019-03-04T05:06:07Z",
+				"/.hidden -rw-r--r-- 2019-03-04T05:06:07Z hidden",
+				"/a.txt -rw------- 2019-03-04T05:06:07Z a",
+				"/sub drwxr-x--- 2019-03-04T06:06:07Z",
+				"/sub/.git drwxr-xr-x 2019-03-04T05:06:07Z",
+				"/sub/.git/cfg -rw-r--r-- 2019-03-04T05:06:07Z cfg",
+				"/sub/b.sh -rwxr-xr-x 2019-03-04T07:06:07Z b",
+			}, "\n"),
+			failed: []string{"/tpl/sub/broken.txt"},
+		},
+		{name: "existing replaced", src: "/tpl/a.txt", query: "destination=/other.txt", status: http.StatusCreated},
+		{name: "existing strict", src: "/tpl/a.txt", query: "destination=/other.txt&strict=true", status: http.StatusConflict, want: " -rw-r--r-- 2020-01-01T00:00:00Z other"},
+		{name: "existing strict with overwrite", src: "/tpl/a.txt", query: "destination=/other.txt&strict=true&overwrite=true", status: http.StatusCreated},
+		{name: "onto itself", src: "/tpl/a.txt", query: "destination=/tpl/a.txt", status: http.StatusBadRequest, want: " -rw------- 2019-03-04T05:06:07Z a"},
+		{name: "into itself", src: "/tpl", query: "destination=/tpl/sub/tpl", status: http.StatusBadRequest, want: "-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]filebrowsertest.File{}
+			for name, file := range template {
+				files[name] = file
+			}
+			for name, file := range tt.files {
+				files[name] = file
+			}
+
+			srv, fs := newServer(t, files)
+			if tt.rules != nil {
+				updateSettings(t, srv, func(s *settings.Settings) { s.Rules = tt.rules })
+			}
+
+			original := tree(t, fs, tt.src)
+			w := do(t, srv, "PATCH", "/api/resources"+tt.src+"?action=copy&"+tt.query, "", "Accept", "application/json")
+			if w.Code != tt.status {
+				t.Fatalf("PATCH = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			dst := strings.TrimPrefix(tt.query, "destination=")
+			dst = strings.SplitN(dst, "&", 2)[0]
+
+			want := tt.want
+			switch want {
+			case "":
+				want = original
+			case "-":
+				want = ""
+			}
+			if got := tree(t, fs, dst); got != want {
+				t.Errorf("the copy is:\n%s\nwant:\n%s", got, want)
+			}
+
+			if w.Code != http.StatusCreated {
+				return
+			}
+
+			var copied struct {
+				Path   string `json:"path"`
+				Failed []struct {
+					Path  string `json:"path"`
+					Error string `json:"error"`
+				} `json:"failed"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &copied); err != nil {
+				t.Fatalf("the copy isn't JSON: %v: %s", err, w.Body)
+			}
+			if copied.Path != dst {
+				t.Errorf("the copy is at %q, want %q", copied.Path, dst)
+			}
+
+			var failed []string
+			for _, f := range copied.Failed {
+				if f.Error == "" {
+					t.Errorf("%s failed without a reason", f.Path)
+				}
+				failed = append(failed, f.Path)
+			}
+			if strings.Join(failed, " ") != strings.Join(tt.failed, " ") {
+				t.Errorf("failed %q, want %q", failed, tt.failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// writeMethods are the methods of the files, on top of readOnlyAllow, of
+// the servers that aren't read-only.
+const writeMethods = "POST, PUT, PATCH, DELETE, MKCOL"
+
+// corsHeaders are the headers of the requests that the pages of the
+// other sites can send to the API.
+const corsHeaders = "X-Auth, Content-Type, Range, If-Match, If-None-Match, If-Modified-Since, If-Unmodified-Since, X-Upload-ID, " + csrfHeader + ", " + RequestIDHeader
+
+// corsExposed are the headers of the responses of the API that the pages
+// of the other sites can read.
+const corsExposed = "ETag, Link, Content-Disposition, X-Items-Limited-To, X-Results-Truncated, X-Deletions-Tracked, " + csrfHeader + ", " + RequestIDHeader
+
+// corsMaxAge is the number of seconds the browsers keep the preflights.
+const corsMaxAge = "600"
+
+// resourceMethods returns the methods the files of the server can be
+// requested with.
+func resourceMethods(server *settings.Server) string {
+	if server.ReadOnly {
+		return readOnlyAllow
+	}
+
+	return readOnlyAllow + ", " + writeMethods
+}
+
+// corsOrigin returns the origin of the request if it's one of the CORS
+// origins of the server, or an empty string.
+func corsOrigin(r *http.Request, server *settings.Server) string {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return ""
+	}
+
+	for _, allowed := range server.CORSOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// corsMiddleware lets the pages of the CORS origins of the server read
+// the responses of the API.
+func corsMiddleware(server *settings.Server) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(server.CORSOrigins) > 0 {
+				w.Header().Add("Vary", "Origin")
+			}
+
+			if origin := corsOrigin(r, server); origin != "" {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Expose-Headers", corsExposed)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// resourceOptionsHandler answers with the methods the files can be
+// requested with, which the preflights of the CORS origins get too. It
+// doesn't need a user, since the browsers send the preflights without
+// the token.
+func resourceOptionsHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	methods := resourceMethods(d.server)
+	w.Header().Set("Allow", methods)
+
+	if corsOrigin(r, d.server) != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+		w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
@@ -0,0 +1,115 @@
+package http_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestOptions(t *testing.T) {
+	const (
+		all      = "GET, HEAD, OPTIONS, POST, PUT, PATCH, DELETE, MKCOL"
+		readOnly = "GET, HEAD, OPTIONS"
+		site     = "https://app.example.com"
+	)
+
+	tests := []struct {
+		name     string
+		readOnly bool
+		origins  []string
+		origin   string
+		method   string // the method of the preflight, if it's one
+		allow    string
+		cors     bool
+	}{
+		{"writable", false, nil, "", "", all, false},
+		{"read-only", true, nil, "", "", readOnly, false},
+		{"origin without CORS", false, nil, site, "PUT", all, false},
+		{"preflight", false, []string{site}, site, "PUT", all, true},
+		{"read-only preflight", true, []string{site}, site, "GET", readOnly, true},
+		{"preflight of any origin", false, []string{"*"}, site, "DELETE", all, true},
+		{"origin with a slash", false, []string{site + "/"}, site, "PUT", all, true},
+		{"other origin", false, []string{site}, "https://evil.example.com", "PUT", all, false},
+		{"not a preflight", false, []string{site}, site, "", all, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, map[string]filebrowsertest.File{"/docs/a.txt": {Content: "a"}})
+			server := &settings.Server{Root: "/", ReadOnly: tt.readOnly, CORSOrigins: tt.origins}
+			if err := srv.Handler.(*fbhttp.Handler).Reload(server); err != nil {
+				t.Fatal(err)
+			}
+
+			// The browsers send the preflights without the token.
+			headers := []string{"X-Auth", "none"}
+			if tt.origin != "" {
+				headers = append(headers, "Origin", tt.origin)
+			}
+			if tt.method != "" {
+				headers = append(headers, "Access-Control-Request-Method", tt.method)
+			}
+
+			w := do(t, srv, "OPTIONS", "/api/resources/docs/a.txt", "", headers...)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("OPTIONS = %d, want 204: %s", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+
+			methods := w.Header().Get("Access-Control-Allow-Methods")
+			if tt.cors && methods != tt.allow || !tt.cors && methods != "" {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q: %v", methods, tt.allow, tt.cors)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Headers") != ""; got != tt.cors {
+				t.Errorf("Access-Control-Allow-Headers = %q, want some: %v", w.Header().Get("Access-Control-Allow-Headers"), tt.cors)
+			}
+
+			// The requests of the allowed origins can read the responses.
+			get := do(t, srv, "GET", "/api/resources/docs/a.txt", "", "Origin", tt.origin, "Accept", "application/json")
+			if get.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", get.Code, get.Body)
+			}
+			allowed := tt.cors || tt.method == "" && tt.origins != nil
+			if got := get.Header().Get("Access-Control-Allow-Origin"); allowed && got != tt.origin || !allowed && got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q: %v", got, tt.origin, allowed)
+			}
+			if vary := get.Header().Values("Vary"); (tt.origins != nil) != contains(vary, "Origin") {
+				t.Errorf("Vary = %q, want Origin: %v", vary, tt.origins != nil)
+			}
+		})
+	}
+}
+
+func TestOptionsReload(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "a"}})
+
+	for _, readOnly := range []bool{true, false, true} {
+		if err := srv.Handler.(*fbhttp.Handler).Reload(&settings.Server{Root: "/", ReadOnly: readOnly}); err != nil {
+			t.Fatal(err)
+		}
+
+		want := "GET, HEAD, OPTIONS"
+		if !readOnly {
+			want += ", POST, PUT, PATCH, DELETE, MKCOL"
+		}
+		if got := do(t, srv, "OPTIONS", "/api/resources/a.txt", "").Header().Get("Allow"); got != want {
+			t.Errorf("Allow = %q with readOnly %v, want %q", got, readOnly, want)
+		}
+	}
+}
+
+// contains checks if one of values is s.
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,27 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+)
+
+// csrfHeader is the header of the CSRF token of the bulk requests, which
+// the directory listings are sent with.
+const csrfHeader = "X-CSRF-Token"
+
+// csrfToken returns the CSRF token of the user of d. It's derived from
+// the key of the settings, so it isn't stored and the other sites can't
+// know it.
+func csrfToken(d *data) string {
+	mac := hmac.New(sha256.New, d.settings.Key)
+	mac.Write([]byte("csrf:" + strconv.FormatUint(uint64(d.user.ID), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkCSRF checks if r has the CSRF token of the user of d.
+func checkCSRF(r *http.Request, d *data) bool {
+	return hmac.Equal([]byte(r.Header.Get(csrfHeader)), []byte(csrfToken(d)))
+}
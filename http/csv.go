@@ -0,0 +1,49 @@
+package http
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// csvHeader is the header row of the CSV listings.
+var csvHeader = []string{"name", "is_dir", "size_bytes", "human_size", "modtime_rfc3339", "mode", "url"}
+
+// renderCSV writes a listing as a CSV attachment named after the
+// directory, with a header row and a row for each of its items, even if
+// there are none. The rows are written one by one, so the listing is
+// never held in memory as CSV.
+func renderCSV(w http.ResponseWriter, file *files.FileInfo) (int, error) {
+	setFormatHeaders(w, formatCSV, file)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return 0, err
+	}
+
+	for _, item := range file.Items {
+		size := ""
+		if !item.IsDir || file.DirSizes {
+			size = humanSize(item.Size)
+		}
+
+		err := cw.Write([]string{
+			item.Name,
+			strconv.FormatBool(item.IsDir),
+			strconv.FormatInt(item.Size, 10),
+			size,
+			item.ModTime.Format(time.RFC3339),
+			item.Mode.String(),
+			itemURL(item),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	cw.Flush()
+	return 0, cw.Error()
+}
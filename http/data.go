@@ -3,11 +3,17 @@ package http
 import (
 	"log"
 	"net/http"
-	"strconv"
+	"runtime/debug"
+	"strings"
+	"time"
 
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/logging"
+	"github.com/filebrowser/filebrowser/v2/rules"
 	"github.com/filebrowser/filebrowser/v2/runner"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/tracing"
 	"github.com/filebrowser/filebrowser/v2/users"
 )
 
@@ -20,49 +26,228 @@ type data struct {
 	store    *storage.Storage
 	user     *users.User
 	raw      interface{}
+	logger   logging.Logger
+	metrics  *handlerMetrics
+	events   *events.Bus
+	slow     *slowOps
+	archives *archiveJobs
+	hooks    *hookDispatch
+	// request is the request being served, which the hooks get.
+	request *http.Request
+	// format is the format of the listing the request got, if any, items
+	// the number of its items that were returned and limit the number of
+	// items of its pages, or zero.
+	format string
+	items  int
+	limit  int
+	// download is set for the downloads of files and archives.
+	download bool
+	// span is the span of the listing being rendered, which the templates
+	// tell if they were cached on.
+	span tracing.Span
 }
 
-// Check implements rules.Checker.
+// Check implements rules.Checker: it checks if the file at path can be
+// seen, which the rules that apply to GET tell. The paths it refuses
+// are left out of the listings and aren't found.
 func (d *data) Check(path string) bool {
-	for _, rule := range d.user.Rules {
-		if rule.Matches(path) {
-			return rule.Allow
-		}
+	return d.checkMethod(path, http.MethodGet)
+}
+
+// checkMethod checks if the rules allow the requests with method on the
+// file at path. The methods that don't change anything are checked as
+// GET. The rules match regardless of case on the scopes that find their
+// files regardless of case, so they can't be bypassed by asking for a
+// name in another case. Nothing under a .git directory can be changed,
+// whatever the rules, since its settings can make git run commands.
+func (d *data) checkMethod(path, method string) bool {
+	if readMethods[method] {
+		method = http.MethodGet
+	} else if inGitDir(path) {
+		return false
 	}
 
-	for _, rule := range d.settings.Rules {
-		if rule.Matches(path) {
-			return rule.Allow
-		}
+	match := rules.MatchMethod
+	if d.settings.CaseInsensitive {
+		match = rules.MatchMethodFold
+	}
+
+	if rule := match(d.user.Rules, path, method); rule != nil {
+		return rule.Allow
+	}
+
+	if rule := match(d.settings.Rules, path, method); rule != nil {
+		return rule.Allow
 	}
 
 	return true
 }
 
-func handle(fn handleFunc, prefix string, storage *storage.Storage, server *settings.Server) http.Handler {
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		settings, err := storage.Settings.Get()
+// inGitDir checks if path is a .git directory or is under one, in any
+// case, since some file systems find them regardless of case.
+func inGitDir(path string) bool {
+	for _, name := range strings.Split(path, "/") {
+		if strings.EqualFold(name, ".git") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readMethods are the methods that don't change the files or the
+// settings. The requests with other methods are logged as writes.
+var readMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	"PROPFIND":         true,
+}
+
+func handle(fn handleFunc, prefix string, h *Handler, server *settings.Server) http.Handler {
+	fn = refuseWrites(fn, prefix)
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		settings, err := h.storage.Settings.Get()
 		if err != nil {
 			log.Fatalln("ERROR: couldn't get settings")
 			return
 		}
 
-		status, err := fn(w, r, &data{
-			Runner:   &runner.Runner{Settings: settings},
-			store:    storage,
+		logger := h.logger
+		if id := RequestID(r.Context()); id != "" {
+			logger = logging.With(logger, "request", id)
+		}
+
+		d := &data{
+			Runner:   &runner.Runner{Settings: settings, Logger: logger},
+			store:    h.storage,
 			settings: settings,
 			server:   server,
-		})
+			logger:   logger,
+			metrics:  h.metrics,
+			events:   h.events,
+			slow:     h.slow,
+			archives: h.archives,
+			hooks:    h.hooks,
+			span:     tracing.Nop,
+		}
+
+		// The HEAD requests run like the GET ones, so their headers are
+		// the same, but the bodies are only counted.
+		if r.Method == http.MethodHead {
+			head := &headWriter{ResponseWriter: rw}
+			defer head.finish()
+			rw = head
+		}
+
+		w := &statusWriter{ResponseWriter: rw}
+		start := time.Now()
+		r, span := h.trace(r)
+		d.request = r
+		defer span.End()
+		defer func() {
+			if rec := recover(); rec != nil {
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				d.logger.Error("panic", "path", r.URL.Path, "panic", rec, "stack", string(debug.Stack()))
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				d.countRequest(r, http.StatusInternalServerError)
+				span.SetInt("http.status_code", http.StatusInternalServerError)
+			}
+		}()
+
+		status, err := fn(w, r, d)
 
-		if status != 0 {
-			txt := http.StatusText(status)
-			http.Error(w, strconv.Itoa(status)+" "+txt, status)
+		if hookErr, ok := err.(*runner.HookError); ok && status == http.StatusUnprocessableEntity {
+			renderHookError(w, r, status, hookErr.Reason)
+		} else if veto, ok := err.(*vetoError); ok && status == http.StatusForbidden {
+			renderHookError(w, r, status, veto.Error())
+		} else if status != 0 {
+			renderError(w, r, d, prefix, status)
 		}
 
-		if status >= 400 || err != nil {
-			log.Printf("%s: %v %s %v", r.URL.Path, status, r.RemoteAddr, err)
+		logRequest(r, d, start, status, err)
+		if !readMethods[r.Method] {
+			d.checkSlow(r, "write", d.settings.Slow.Write, time.Since(start), 0)
+		}
+
+		// The responses that weren't written at all are a 200 OK.
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		d.countRequest(r, w.status)
+		span.SetInt("http.status_code", int64(w.status))
+
+		if h.accessLog != nil {
+			h.logAccess(r, d, w, start)
 		}
 	})
 
 	return http.StripPrefix(prefix, handler)
 }
+
+// logRequest logs the failed requests as errors or warnings, and the
+// others as writes or, at the debug level, as reads. A zero status is a
+// response written by the handler.
+func logRequest(r *http.Request, d *data, start time.Time, status int, err error) {
+	fields := []interface{}{
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", status,
+		"client", d.clientAddr(r),
+		"duration", time.Since(start),
+	}
+
+	if d.user != nil {
+		fields = append(fields, "user", d.user.Username)
+	}
+
+	if err != nil {
+		fields = append(fields, "error", err)
+	}
+
+	switch {
+	case status >= 500 || (status == 0 && err != nil):
+		d.logger.Error("request failed", fields...)
+	case status >= 400 || err != nil:
+		d.logger.Warn("request failed", fields...)
+	case !readMethods[r.Method]:
+		d.logger.Info("write", fields...)
+	default:
+		d.logger.Debug("request", fields...)
+	}
+}
+
+// notify publishes event, which succeeded on path, to the subscribers,
+// such as the webhooks, and drops the listings it changed from the cache.
+// dst is the destination of the renames and copies, and size the size of
+// the file, if known.
+func (d *data) notify(event, path, dst string, size int64) {
+	listings.forget(d, path)
+	if dst != "" {
+		listings.forget(d, dst)
+	}
+
+	d.events.Publish(events.Event{
+		Type:        event,
+		Path:        path,
+		Destination: dst,
+		Size:        size,
+		User:        d.user.Username,
+		Scope:       d.user.Scope,
+		Time:        time.Now(),
+	})
+}
+
+// notifyMove publishes a rename or a copy, which succeeded, with the size
+// of the file at dst.
+func (d *data) notifyMove(event, src, dst string) {
+	var size int64
+	if info, err := d.user.Fs.Stat(dst); err == nil && !info.IsDir() {
+		size = info.Size()
+	}
+
+	d.notify(event, src, dst, size)
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestCheckMethod(t *testing.T) {
+	newData := func(caseInsensitive bool) *data {
+		return &data{
+			settings: &settings.Settings{
+				CaseInsensitive: caseInsensitive,
+				Rules:           []rules.Rule{{Path: "/private"}},
+			},
+			user: &users.User{
+				Rules: []rules.Rule{{Path: "/private/shared", Allow: true}},
+			},
+		}
+	}
+
+	tests := []struct {
+		path            string
+		method          string
+		caseInsensitive bool
+		want            bool
+	}{
+		{"/private/x", http.MethodGet, false, false},
+		{"/PRIVATE/x", http.MethodGet, true, false},
+		{"/Private/x", http.MethodPut, true, false},
+		{"/PRIVATE/SHARED/x", http.MethodGet, true, true},
+		{"/public/x", http.MethodGet, true, true},
+		{"/repo/.git/config", http.MethodGet, false, true},
+		{"/repo/.git/config", http.MethodPut, false, false},
+		{"/repo/.GIT/hooks/pre-commit", http.MethodPost, false, false},
+		{"/repo/.git", "MKCOL", false, false},
+		{"/repo/.gitignore", http.MethodPut, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := newData(tt.caseInsensitive).checkMethod(tt.path, tt.method); got != tt.want {
+				t.Errorf("checkMethod(%q, %s) = %v, want %v", tt.path, tt.method, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultDateFormat is the layout of the dates of the listings when the
+// settings don't have one.
+const defaultDateFormat = "2006-01-02 15:04:05"
+
+// locations caches the time zones by name, so their files are only read
+// once.
+var locations sync.Map
+
+// loadLocation returns the time zone with an IANA name. An empty name is
+// the local time zone of the server.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// listingLocation returns the time zone of the dates of the listings,
+// which the tz query parameter overrides. Unknown time zones in the query
+// are ignored, while the ones in the settings were already validated.
+func listingLocation(r *http.Request, d *data) *time.Location {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		if loc, err := loadLocation(tz); err == nil {
+			return loc
+		}
+	}
+
+	loc, err := loadLocation(d.settings.Timezone)
+	if err != nil {
+		return time.Local
+	}
+
+	return loc
+}
+
+// listingDateFormat returns the layout of the dates of the listings.
+func listingDateFormat(d *data) string {
+	if d.settings.DateFormat == "" {
+		return defaultDateFormat
+	}
+
+	return d.settings.DateFormat
+}
@@ -0,0 +1,238 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/diff"
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/spf13/afero"
+)
+
+const (
+	// maxDiffSize is the size of the biggest file compared, since both
+	// files are compared in memory.
+	maxDiffSize = 1 << 20
+	// diffTimeout bounds the time spent comparing two files. The parts
+	// left are then shown as completely replaced.
+	diffTimeout = 5 * time.Second
+	// diffContext is the number of unchanged lines around the changes.
+	diffContext = 3
+	// binarySniffLen is how much of the files is looked at for the NUL
+	// bytes that make them binary, like git does.
+	binarySniffLen = 8000
+)
+
+var errBinaryDiff = fmt.Errorf("binary files can't be compared")
+
+const diffTemplate = `<!DOCTYPE html>
+<html lang="{{ .Locale }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/light.css" }}">
+{{- if eq .Theme "dark" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}">
+{{- else if eq .Theme "" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}" media="(prefers-color-scheme: dark)">
+{{- end }}
+</head>
+<body class="theme-{{ or .Theme "auto" }}">
+<h1>{{ $.T "diffTitle" .Old .New }}</h1>
+{{- if not .Hunks }}
+<p>{{ $.T "noDifferences" }}</p>
+{{- else }}
+<pre class="diff">
+{{- range .Hunks }}
+<span class="hunk">{{ .Header }}</span>
+{{- range .Lines }}
+{{ if eq .Op "-" }}<span class="del">{{ else if eq .Op "+" }}<span class="ins">{{ else }}<span>{{ end }}{{ .Op }}{{ lineText .Text }}</span>
+{{- if not (hasNewline .Text) }}
+<span class="hunk">{{ $.T "noNewline" }}</span>
+{{- end }}
+{{- end }}
+{{- end }}
+</pre>
+{{- end }}
+</body>
+</html>
+`
+
+var defaultDiffPage = template.Must(template.New("diff").Funcs(listingFuncs).Funcs(template.FuncMap{
+	"lineText":   lineText,
+	"hasNewline": func(s string) bool { return strings.HasSuffix(s, "\n") },
+}).Parse(diffTemplate))
+
+// lineText returns a line of a diff without its newline, with its
+// carriage return, if it has one, shown as ␍ so the lines that only
+// differ by it don't look the same.
+func lineText(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	if strings.HasSuffix(s, "\r") {
+		s = strings.TrimSuffix(s, "\r") + "␍"
+	}
+
+	return s
+}
+
+// diffPage is the data the diff template is executed with.
+type diffPage struct {
+	Title     string
+	StaticURL string
+	Theme     string
+	Locale    string
+	Old       string
+	New       string
+	Hunks     []diff.Hunk
+
+	pageMessages
+}
+
+// diffData is the JSON of the differences between two files.
+type diffData struct {
+	Old   string      `json:"old"`
+	New   string      `json:"new"`
+	Hunks []diff.Hunk `json:"hunks"`
+}
+
+// diffText reads the text file at p to compare it.
+func diffText(d *data, p string) (string, error) {
+	if !d.Check(p) {
+		return "", errors.ErrNotExist
+	}
+
+	info, err := d.user.Fs.Stat(p)
+	switch {
+	case err != nil:
+		return "", err
+	case info.IsDir():
+		return "", errors.ErrIsDirectory
+	case files.SpecialKind(info.Mode()) != "":
+		return "", errors.ErrSpecialFile
+	case info.Size() > maxDiffSize:
+		return "", errors.ErrTooLarge
+	}
+
+	content, err := afero.ReadFile(d.user.Fs, p)
+	if err != nil {
+		return "", err
+	}
+
+	if len(content) > maxDiffSize {
+		return "", errors.ErrTooLarge
+	}
+
+	sniff := content
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return "", errBinaryDiff
+	}
+
+	return string(content), nil
+}
+
+// renderDiff renders the differences between the requested file and the
+// one in the diff query parameter, whose path is relative to the
+// directory of the requested file unless it is absolute.
+func renderDiff(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	format := negotiatedFormat(w, r, d)
+	if format == "" {
+		return http.StatusNotAcceptable, nil
+	}
+
+	oldPath := path.Clean("/" + r.URL.Path)
+	newPath := r.URL.Query().Get("diff")
+	if !path.IsAbs(newPath) {
+		newPath = path.Join(path.Dir(oldPath), newPath)
+	}
+	newPath = path.Clean(newPath)
+
+	oldText, err := diffText(d, oldPath)
+	if err == nil {
+		var newText string
+		newText, err = diffText(d, newPath)
+		if err == nil {
+			return writeDiff(w, r, d, format, oldPath, newPath, oldText, newText)
+		}
+	}
+
+	switch err {
+	case errors.ErrTooLarge:
+		return diffFailure(w, format, http.StatusBadRequest, fmt.Errorf("files bigger than %d bytes can't be compared", maxDiffSize))
+	case errBinaryDiff, errors.ErrIsDirectory:
+		return diffFailure(w, format, http.StatusBadRequest, err)
+	}
+
+	return errToStatus(err), err
+}
+
+// diffFailure writes why the files can't be compared, in the format of
+// the request. The HTML pages get the error page of the status.
+func diffFailure(w http.ResponseWriter, format string, status int, err error) (int, error) {
+	switch format {
+	case formatJSON:
+		return 0, writeFailure(w, status, err.Error())
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		_, err = fmt.Fprintln(w, err)
+		return 0, err
+	}
+
+	return status, err
+}
+
+func writeDiff(w http.ResponseWriter, r *http.Request, d *data, format, oldPath, newPath, oldText, newText string) (int, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), diffTimeout)
+	defer cancel()
+
+	hunks := diff.Diff(ctx, oldText, newText, diffContext)
+	if r.Context().Err() != nil {
+		return http.StatusInternalServerError, r.Context().Err()
+	}
+
+	switch format {
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err := diff.WriteUnified(w, oldPath, newPath, hunks); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return 0, nil
+	case formatHTML:
+		locale := detectLocale(r, d.user.Locale)
+		baseURL := d.baseURL(r)
+		page := &diffPage{
+			Title:        listingTitle(d) + " – " + oldPath,
+			StaticURL:    d.staticURL(baseURL),
+			Theme:        activeTheme(r, d.settings.Branding.Theme),
+			Locale:       locale,
+			Old:          oldPath,
+			New:          newPath,
+			Hunks:        hunks,
+			pageMessages: messages(locale),
+		}
+
+		var buf bytes.Buffer
+		if err := defaultDiffPage.Execute(&buf, page); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := buf.WriteTo(w); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return 0, nil
+	}
+
+	return renderJSON(w, r, diffData{Old: oldPath, New: newPath, Hunks: hunks})
+}
@@ -0,0 +1,54 @@
+package http_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+func TestDiff(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/etc/a.conf":     {Content: "port=80\r\nhost=a\r\n"},
+		"/etc/b.conf":     {Content: "port=81\r\nhost=a"},
+		"/etc/image.png":  {Content: "\x89PNG\r\n\x00\x00"},
+		"/etc/large.conf": {Content: strings.Repeat("x\n", 1<<19+1)},
+		"/etc/sub/":       {},
+	})
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		status int
+		want   string
+	}{
+		{
+			"unified", "/api/resources/etc/a.conf?diff=b.conf", "text/plain", http.StatusOK,
+			"--- /etc/a.conf\n+++ /etc/b.conf\n@@ -1,2 +1,2 @@\n-port=80\r\n-host=a\r\n+port=81\r\n+host=a\n\\ No newline at end of file\n",
+		},
+		{"absolute", "/api/resources/etc/a.conf?diff=/etc/b.conf", "text/plain", http.StatusOK, "+++ /etc/b.conf\n"},
+		{"JSON", "/api/resources/etc/a.conf?diff=b.conf", "application/json", http.StatusOK, `"op":"+","text":"port=81\r\n"`},
+		{"HTML", "/api/resources/etc/a.conf?diff=b.conf", "text/html", http.StatusOK, "port=81"},
+		{"identical", "/api/resources/etc/a.conf?diff=a.conf", "text/plain", http.StatusOK, "--- /etc/a.conf\n+++ /etc/a.conf\n"},
+		{"binary", "/api/resources/etc/a.conf?diff=image.png", "text/plain", http.StatusBadRequest, "binary"},
+		{"too large", "/api/resources/etc/a.conf?diff=large.conf", "application/json", http.StatusBadRequest, "can't be compared"},
+		{"directory", "/api/resources/etc/a.conf?diff=sub", "text/plain", http.StatusBadRequest, ""},
+		{"missing", "/api/resources/etc/a.conf?diff=c.conf", "text/plain", http.StatusNotFound, ""},
+		{"outside of the scope", "/api/resources/etc/a.conf?diff=../../a.conf", "text/plain", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, srv, "GET", tt.target, "", "Accept", tt.accept)
+			if w.Code != tt.status {
+				t.Fatalf("GET = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if body := w.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("the response %q doesn't contain %q", body, tt.want)
+			}
+		})
+	}
+}
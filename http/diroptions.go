@@ -0,0 +1,184 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+const (
+	// maxDirOptionsSize is the maximum size of an options file.
+	maxDirOptionsSize = 64 << 10
+	// maxDirTitle and maxDirDescription bound the lengths, in runes, of
+	// the title and the description of the options files.
+	maxDirTitle       = 200
+	maxDirDescription = 2000
+)
+
+// dirOptions are the options of the listing of a directory from its
+// options file, which apply before the query parameters. Only the ones
+// about how the listing is presented are read: the ones who can write to
+// a directory can't change what can be done in it with its options file.
+// Limit is a pointer to tell an unset limit from the zero limit, which
+// lists all the items.
+type dirOptions struct {
+	Sort        string `json:"sort"`
+	Order       string `json:"order"`
+	Limit       *int   `json:"limit"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// dirOptionKeys are the keys of the options files.
+var dirOptionKeys = map[string]bool{
+	"sort":        true,
+	"order":       true,
+	"limit":       true,
+	"title":       true,
+	"description": true,
+}
+
+// parseDirOptions parses and checks an options file. The keys that
+// aren't options are returned to be warned about.
+func parseDirOptions(data []byte) (*dirOptions, []string, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, nil, err
+	}
+
+	var unknown []string
+	for key := range keys {
+		if !dirOptionKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	opts := &dirOptions{}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(opts); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.Sort != "" && !files.IsSortKey(opts.Sort) {
+		return nil, nil, fmt.Errorf("can't sort by %q: it must be name, size, modified or type", opts.Sort)
+	}
+
+	switch opts.Order {
+	case "", "asc", "desc":
+	default:
+		return nil, nil, fmt.Errorf("invalid order %q: it must be asc or desc", opts.Order)
+	}
+
+	if opts.Limit != nil && *opts.Limit < 0 {
+		return nil, nil, fmt.Errorf("the limit can't be negative")
+	}
+
+	if utf8.RuneCountInString(opts.Title) > maxDirTitle {
+		return nil, nil, fmt.Errorf("the title is longer than %d characters", maxDirTitle)
+	}
+
+	if utf8.RuneCountInString(opts.Description) > maxDirDescription {
+		return nil, nil, fmt.Errorf("the description is longer than %d characters", maxDirDescription)
+	}
+
+	return opts, unknown, nil
+}
+
+type cachedDirOptions struct {
+	modTime time.Time
+	opts    *dirOptions
+}
+
+// dirOptionsCache caches the options files by path. They are parsed
+// again whenever their modification time changes. The invalid ones are
+// cached as nil options, so they are only warned about once.
+type dirOptionsCache struct {
+	sync.Mutex
+	m map[string]cachedDirOptions
+}
+
+var dirOptionsFiles = &dirOptionsCache{}
+
+func (c *dirOptionsCache) get(d *data, name string, modTime time.Time) *dirOptions {
+	key := d.user.FullPath(name)
+
+	c.Lock()
+	defer c.Unlock()
+
+	if cached, ok := c.m[key]; ok && cached.modTime.Equal(modTime) {
+		return cached.opts
+	}
+
+	opts, err := readDirOptions(d, name)
+	if err != nil {
+		d.logger.Warn("ignoring the invalid options file", "path", name, "error", err)
+	}
+
+	if c.m == nil {
+		c.m = map[string]cachedDirOptions{}
+	}
+
+	c.m[key] = cachedDirOptions{modTime: modTime, opts: opts}
+	return opts
+}
+
+func readDirOptions(d *data, name string) (*dirOptions, error) {
+	data, err := afero.ReadFile(d.user.Fs, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxDirOptionsSize {
+		return nil, errors.ErrTooLarge
+	}
+
+	opts, unknown, err := parseDirOptions(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range unknown {
+		d.logger.Warn("ignoring an option that can't be set by the options files", "path", name, "option", key)
+	}
+
+	return opts, nil
+}
+
+// listingOptions returns the options of the listing of dir from its
+// options file, or nil if there's none, it's off or it's invalid.
+func listingOptions(d *data, dir string) *dirOptions {
+	if d.settings.DirOptions == "" {
+		return nil
+	}
+
+	name := path.Join(dir, d.settings.DirOptions)
+	if !d.Check(name) {
+		return nil
+	}
+
+	info, err := d.user.Fs.Stat(name)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.logger.Warn("couldn't read the options file", "path", name, "error", err)
+		}
+		return nil
+	}
+
+	if !info.Mode().IsRegular() || info.Size() > maxDirOptionsSize {
+		d.logger.Warn("ignoring the options file", "path", name, "error", "not a regular file or too large")
+		return nil
+	}
+
+	return dirOptionsFiles.get(d, name, info.ModTime())
+}
@@ -0,0 +1,119 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+const (
+	// dirSizesWorkers is the number of directories of a listing walked
+	// at the same time.
+	dirSizesWorkers = 4
+	// dirSizesTimeout bounds the time the directories of a listing are
+	// walked for. The ones that weren't walked completely by then have
+	// estimated sizes.
+	dirSizesTimeout = 5 * time.Second
+	// maxDirSizes bounds the number of sizes of directories cached.
+	maxDirSizes = 4096
+)
+
+// dirSizes caches the sizes of the contents of the directories, by their
+// full path and modification time, so they are only walked again once
+// their entries changed. The changes deeper down don't change the time
+// of the directory, so they are only seen once the cache is cleared.
+// Only the complete walks are cached.
+var dirSizes = struct {
+	sync.Mutex
+	m map[string]int64
+}{m: map[string]int64{}}
+
+// wantsDirSizes checks if the directories of the listings get the sizes
+// of their contents: the dirsizes query parameter, true or false,
+// overrides the settings for the request.
+func wantsDirSizes(r *http.Request, d *data) bool {
+	if value := r.URL.Query().Get("dirsizes"); value != "" {
+		return value == "true"
+	}
+
+	return d.settings.DirSizes
+}
+
+// annotateDirSizes sets the sizes of the directories of the listing to
+// the ones of their contents, walking a few of them at the same time, so
+// they can be sorted by size. The directories that weren't walked
+// completely in time, or that couldn't be, are marked as estimates, and
+// so are the links to directories, which aren't followed.
+func annotateDirSizes(ctx context.Context, d *data, listing *files.Listing) {
+	ctx, cancel := context.WithTimeout(ctx, dirSizesTimeout)
+	defer cancel()
+
+	dirs := make(chan *files.FileInfo)
+	var wg sync.WaitGroup
+	for i := 0; i < dirSizesWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range dirs {
+				item.Size, item.SizeIsEstimate = dirSize(ctx, d, item)
+			}
+		}()
+	}
+
+	for _, item := range listing.Items {
+		switch {
+		case !item.IsDir || item.Error:
+		case item.IsSymlink || isLink(d.user.Fs, item.Path):
+			item.SizeIsEstimate = true
+		default:
+			dirs <- item
+		}
+	}
+	close(dirs)
+	wg.Wait()
+
+	listing.DirSizes = true
+}
+
+// dirSize returns the size of the contents of the directory, from the
+// cache if it didn't change since it was walked, and if it's an estimate.
+func dirSize(ctx context.Context, d *data, dir *files.FileInfo) (int64, bool) {
+	key := d.user.FullPath(dir.Path) + "\x00" + strconv.FormatInt(dir.ModTime.UnixNano(), 10)
+
+	dirSizes.Lock()
+	size, ok := dirSizes.m[key]
+	dirSizes.Unlock()
+	if ok {
+		return size, false
+	}
+
+	usage, _, err := files.DiskUsage(files.DiskUsageOptions{
+		Context:    ctx,
+		Fs:         d.user.Fs,
+		Path:       dir.Path,
+		MaxEntries: maxDiskUsageEntries,
+		Checker:    d,
+		Partial:    true,
+	})
+	if err != nil {
+		d.logger.Debug("couldn't measure the directory", "path", dir.Path, "error", err)
+		return 0, true
+	}
+
+	if usage.Approximate {
+		return usage.Size, true
+	}
+
+	dirSizes.Lock()
+	if len(dirSizes.m) >= maxDirSizes {
+		dirSizes.m = map[string]int64{}
+	}
+	dirSizes.m[key] = usage.Size
+	dirSizes.Unlock()
+
+	return usage.Size, false
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+const (
+	// docMetaWorkers is the number of documents of a listing read at the
+	// same time.
+	docMetaWorkers = 4
+	// maxDocMetaCache bounds the number of documents whose metadata is
+	// cached.
+	maxDocMetaCache = 10000
+)
+
+type cachedDocMeta struct {
+	modTime time.Time
+	size    int64
+	meta    *files.DocMeta
+	err     error
+}
+
+// docMetaCache caches the metadata of the documents by their paths,
+// until their modification time or their size changes. The corrupt
+// documents are cached too, so they aren't read again for every listing.
+type docMetaCache struct {
+	// hits and misses come first to be aligned for the atomic operations.
+	hits, misses uint64
+
+	sync.Mutex
+	m map[string]cachedDocMeta
+}
+
+var docMetas = &docMetaCache{}
+
+// key returns the key of the document at p of the scope of the user,
+// which is its path on the disk if it's on the local one, so the users
+// share it.
+func (c *docMetaCache) key(d *data, p string) string {
+	if local, ok := d.user.LocalPath(p); ok {
+		return local
+	}
+
+	return strconv.FormatUint(uint64(d.user.ID), 10) + ":" + p
+}
+
+// get returns the metadata of the document file, reading it if it isn't
+// cached.
+func (c *docMetaCache) get(d *data, file *files.FileInfo) (*files.DocMeta, error) {
+	key := c.key(d, file.Path)
+
+	c.Lock()
+	cached, ok := c.m[key]
+	c.Unlock()
+
+	if ok && cached.modTime.Equal(file.ModTime) && cached.size == file.Size {
+		atomic.AddUint64(&c.hits, 1)
+		return cached.meta, cached.err
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	meta, err := files.ReadDocMeta(d.user.Fs, file.Path)
+	if err != nil && !isCorrupt(err) {
+		// The errors of the filesystem may not last.
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.m == nil {
+		c.m = map[string]cachedDocMeta{}
+	}
+
+	if _, ok := c.m[key]; !ok && len(c.m) >= maxDocMetaCache {
+		// Any document makes room, which is as good as any other when
+		// the listings are browsed at random.
+		for old := range c.m {
+			delete(c.m, old)
+			break
+		}
+	}
+
+	c.m[key] = cachedDocMeta{modTime: file.ModTime, size: file.Size, meta: meta, err: err}
+	return meta, err
+}
+
+func (c *docMetaCache) status() cacheStatus {
+	c.Lock()
+	size := len(c.m)
+	c.Unlock()
+
+	return newCacheStatus(size, atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses))
+}
+
+// isCorrupt checks if err is about the document itself rather than about
+// reading it.
+func isCorrupt(err error) bool {
+	return errors.Is(err, files.ErrCorruptDoc)
+}
+
+// wantsDocMeta checks if the documents are annotated with their metadata,
+// which the settings or the docmeta query parameter turn on.
+func wantsDocMeta(r *http.Request, d *data) bool {
+	return d.settings.DocMeta || r.URL.Query().Get("docmeta") == "true"
+}
+
+// annotateDocMeta sets the metadata of the documents of the listing, a
+// few at a time. The documents that can't be read are left as they are,
+// and so are the ones that weren't read when the request is canceled.
+func annotateDocMeta(ctx context.Context, d *data, listing *files.Listing) {
+	var wg sync.WaitGroup
+	queue := make(chan *files.FileInfo)
+	for i := 0; i < docMetaWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				// Each worker has its own items, so they aren't locked.
+				meta, err := docMetas.get(d, item)
+				if err != nil {
+					d.logger.Debug("document metadata", "path", item.Path, "error", err)
+					continue
+				}
+				item.DocMeta = meta
+			}
+		}()
+	}
+
+	for _, item := range listing.Items {
+		if ctx.Err() != nil {
+			break
+		}
+
+		if !item.IsDir && !item.Error && item.Special == "" && files.HasDocMeta(item.Name) {
+			queue <- item
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// renderDocMeta writes the metadata of the document file as JSON.
+func renderDocMeta(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	if !files.HasDocMeta(file.Name) || file.Special != "" {
+		return renderFailure(w, r, http.StatusBadRequest, files.ErrNoDocMeta.Error())
+	}
+
+	meta, err := docMetas.get(d, file)
+	switch {
+	case err == nil:
+		return renderJSON(w, r, meta)
+	case isCorrupt(err):
+		return renderFailure(w, r, http.StatusUnprocessableEntity, err.Error())
+	default:
+		return errToStatus(err), err
+	}
+}
@@ -0,0 +1,213 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mholt/archiver"
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// downloadFormat returns the extension and the writer of the archives of
+// the download query parameter, zip or targz.
+func downloadFormat(format string) (string, archiver.Writer, bool) {
+	switch format {
+	case "zip":
+		return ".zip", archiver.NewZip(), true
+	case "targz":
+		return ".tar.gz", archiver.NewTarGz(), true
+	default:
+		return "", nil, false
+	}
+}
+
+// attachment returns the Content-Disposition of the downloads named name,
+// as per RFC 6266: with its UTF-8 name, encoded as per RFC 5987, and an
+// ASCII one, with the other characters replaced, for the older clients.
+func attachment(name string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	return `attachment; filename="` + fallback + `"; filename*=utf-8''` + url.PathEscape(name)
+}
+
+// renderFileDownload serves the requested file as an attachment, like
+// /api/raw does, so the browsers save it rather than show it. The ranges
+// and the conditional requests work the same.
+func renderFileDownload(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.user.Perm.Download {
+		return http.StatusForbidden, nil
+	}
+
+	file, err := files.NewFileInfo(files.FileOptions{
+		Fs:      d.user.Fs,
+		Path:    r.URL.Path,
+		Modify:  d.user.Perm.Modify,
+		Expand:  false,
+		Checker: d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if file.IsDir {
+		return renderFailure(w, r, http.StatusBadRequest, "the directories are downloaded as archives, with zip or targz")
+	}
+
+	return rawFileHandler(d.countDownload(w), r, d, file)
+}
+
+// renderDownload streams an archive of the contents of the requested
+// directory rather than its listing, or the requested file as an
+// attachment with download=true. The archive is written as it's
+// built, so the entries that can't be read are left out of it instead of
+// failing it halfway. The links to files are stored as their targets,
+// like in the other archives, and the links to directories are left out
+// so the walk can't loop.
+func renderDownload(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if r.URL.Query().Get("download") == "true" {
+		return renderFileDownload(w, r, d)
+	}
+
+	extension, ar, ok := downloadFormat(r.URL.Query().Get("download"))
+	if !ok {
+		return renderFailure(w, r, http.StatusBadRequest, "the archives can be zip or targz")
+	}
+
+	// The archives have no headers to give without building them.
+	if r.Method == http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		return http.StatusMethodNotAllowed, nil
+	}
+
+	if !d.user.Perm.Download || !d.capabilities(r.URL.Path).CanDownloadArchive {
+		return http.StatusForbidden, nil
+	}
+
+	info, err := d.user.Fs.Stat(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return renderFailure(w, r, http.StatusBadRequest, "only the directories can be downloaded as archives")
+	}
+
+	name := path.Base(path.Clean("/" + r.URL.Path))
+	if name == "/" {
+		name = "archive"
+	}
+	w.Header().Set("Content-Disposition", attachment(name+extension))
+
+	w = d.countDownload(w)
+	if err := ar.Create(w); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer ar.Close()
+
+	// The archive has started, so a failure can only cut it short.
+	if err := addArchiveEntries(ar, d, r.URL.Path, r.URL.Path, false); err != nil {
+		d.logger.Warn("couldn't finish the archive", "path", r.URL.Path, "error", err)
+	}
+
+	return 0, nil
+}
+
+// addArchiveEntries adds the entries of dir to the archive, named by
+// their paths relative to root, and the ones of its directories. The
+// files whose names start with a dot are left out when hide is set.
+func addArchiveEntries(ar archiver.Writer, d *data, root, dir string, hide bool) error {
+	fd, err := d.user.Fs.Open(dir)
+	if err != nil {
+		d.logger.Warn("left the directory out of the archive", "path", dir, "error", err)
+		return nil
+	}
+
+	names, err := fd.Readdirnames(-1)
+	fd.Close()
+	if err != nil {
+		d.logger.Warn("left the directory out of the archive", "path", dir, "error", err)
+		return nil
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := path.Join(dir, name)
+		if !d.Check(p) || hide && strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		if err := addArchiveEntry(ar, d, root, p, hide); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addArchiveEntry adds the file at p to the archive. Only the errors of
+// the archive itself are returned: the file is left out otherwise.
+func addArchiveEntry(ar archiver.Writer, d *data, root, p string, hide bool) error {
+	info, err := d.user.Fs.Stat(p)
+	if err != nil {
+		d.logger.Warn("left the file out of the archive", "path", p, "error", err)
+		return nil
+	}
+
+	// The special files would block the archive.
+	if kind := files.SpecialKind(info.Mode()); kind != "" {
+		d.logger.Warn("left the special file out of the archive", "path", p, "kind", kind)
+		return nil
+	}
+
+	entry := archiver.File{
+		FileInfo: archiver.FileInfo{
+			FileInfo:   info,
+			CustomName: strings.TrimPrefix(strings.TrimPrefix(p, root), "/"),
+		},
+	}
+
+	if info.IsDir() {
+		if isLink(d.user.Fs, p) {
+			return nil
+		}
+
+		if err := ar.Write(entry); err != nil {
+			return err
+		}
+
+		return addArchiveEntries(ar, d, root, p, hide)
+	}
+
+	file, err := d.user.Fs.Open(p)
+	if err != nil {
+		d.logger.Warn("left the file out of the archive", "path", p, "error", err)
+		return nil
+	}
+	defer file.Close()
+
+	entry.ReadCloser = file
+	return ar.Write(entry)
+}
+
+// isLink checks if p is a symbolic link, on the filesystems that can
+// tell.
+func isLink(fs afero.Fs, p string) bool {
+	lstater, ok := fs.(afero.Lstater)
+	if !ok {
+		return false
+	}
+
+	info, _, err := lstater.LstatIfPossible(p)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
@@ -0,0 +1,182 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// maxDiskUsageEntries bounds the number of entries walked to measure the
+// directories. The directories that weren't walked completely are
+// reported as approximate.
+const maxDiskUsageEntries = 200000
+
+const diskUsageTemplate = `<!DOCTYPE html>
+<html lang="{{ .Locale }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/light.css" }}">
+{{- if eq .Theme "dark" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}">
+{{- else if eq .Theme "" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}" media="(prefers-color-scheme: dark)">
+{{- end }}
+</head>
+<body class="theme-{{ or .Theme "auto" }}">
+<h1>{{ $.T "diskUsage" .Total.Path }}</h1>
+<p>{{ humanSize .Total.Size }} – {{ $.T "summary" .Total.Dirs .Total.Files }}{{ if .Total.Approximate }} ({{ $.T "approximate" }}){{ end }}</p>
+<table id="du">
+<tr><th>{{ $.T "name" }}</th><th>{{ $.T "size" }}</th><th></th><th></th></tr>
+{{- range .Dirs }}
+<tr>
+<td><a href="{{ $.BaseURL }}{{ pathJoinURL "/api/resources" .Path "/" }}{{ $.Query }}">{{ .Name }}/</a></td>
+<td>{{ humanSize .Size }}{{ if .Approximate }} ({{ $.T "approximate" }}){{ end }}</td>
+<td><div style="background: currentColor; opacity: 0.4; height: 0.8em; width: {{ $.Percent .Size }}%"></div></td>
+<td>{{ $.T "summary" .Dirs .Files }}</td>
+</tr>
+{{- end }}
+</table>
+</body>
+</html>
+`
+
+var defaultDiskUsagePage = template.Must(template.New("du").Funcs(listingFuncs).Parse(diskUsageTemplate))
+
+// diskUsagePage is the data the disk usage template is executed with.
+type diskUsagePage struct {
+	Title     string
+	BaseURL   string
+	StaticURL string
+	Query     string
+	Theme     string
+	Locale    string
+	Total     *files.DirUsage
+	Dirs      []*files.DirUsage
+
+	pageMessages
+}
+
+// Percent returns the percentage of the total size size is, for the
+// bars.
+func (p *diskUsagePage) Percent(size int64) int64 {
+	if p.Total.Size == 0 {
+		return 0
+	}
+
+	return size * 100 / p.Total.Size
+}
+
+// renderDiskUsage renders the sizes of the directories under the
+// requested path, down to the depth query parameter, which defaults to
+// one and is capped by the depth of the trees.
+func renderDiskUsage(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+
+	format := negotiatedFormat(w, r, d)
+	if format == "" {
+		return http.StatusNotAcceptable, nil
+	}
+
+	info, err := d.user.Fs.Stat(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return http.StatusBadRequest, nil
+	}
+
+	maxDepth, _ := d.settings.Tree.Limits()
+	depth := 1
+
+	if raw := r.URL.Query().Get("depth"); raw != "" {
+		depth, err = strconv.Atoi(raw)
+		if err != nil || depth < 1 {
+			return http.StatusBadRequest, err
+		}
+
+		if depth > maxDepth {
+			depth = maxDepth
+		}
+	}
+
+	total, dirs, err := files.DiskUsage(files.DiskUsageOptions{
+		Context:    r.Context(),
+		Fs:         d.user.Fs,
+		Path:       r.URL.Path,
+		Depth:      depth,
+		MaxEntries: maxDiskUsageEntries,
+		Checker:    d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if total.Approximate {
+		w.Header().Set("X-Results-Truncated", "true")
+	}
+
+	switch format {
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for _, dir := range dirs {
+			approximate := ""
+			if dir.Approximate {
+				approximate = "~"
+			}
+
+			fmt.Fprintf(tw, "%s%d\t%d\t%d\t\t%s/\n", approximate, dir.Size, dir.Dirs, dir.Files, dir.Name)
+		}
+
+		if err := tw.Flush(); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return 0, nil
+	case formatHTML:
+		return renderDiskUsageHTML(w, r, d, total, dirs)
+	}
+
+	return renderJSON(w, r, dirs)
+}
+
+func renderDiskUsageHTML(w http.ResponseWriter, r *http.Request, d *data, total *files.DirUsage, dirs []*files.DirUsage) (int, error) {
+	locale := detectLocale(r, d.user.Locale)
+	baseURL := d.baseURL(r)
+	page := &diskUsagePage{
+		Title:        listingTitle(d) + " – " + total.Path,
+		BaseURL:      baseURL,
+		StaticURL:    d.staticURL(baseURL),
+		Theme:        activeTheme(r, d.settings.Branding.Theme),
+		Locale:       locale,
+		Total:        total,
+		Dirs:         dirs,
+		pageMessages: messages(locale),
+	}
+
+	if query := keptQuery(r); len(query) > 0 {
+		page.Query = "?" + query.Encode()
+	}
+
+	var buf bytes.Buffer
+	if err := defaultDiskUsagePage.Execute(&buf, page); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,142 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// maxDuplicateSize is the size of the biggest file compared when looking
+// for duplicates, unless the request allows the large ones.
+const maxDuplicateSize = 256 << 20
+
+// duplicatesOptions returns the options of the search for duplicates in
+// dir. The depth query parameter is capped by the settings of the trees,
+// like the number of files compared, and large=true compares the files
+// of any size.
+func duplicatesOptions(r *http.Request, d *data, dir string) (files.DuplicatesOptions, error) {
+	maxDepth, maxFiles := d.settings.Tree.Limits()
+	opts := files.DuplicatesOptions{
+		Context:  r.Context(),
+		Fs:       d.user.Fs,
+		Path:     dir,
+		MaxFiles: maxFiles,
+		MaxSize:  maxDuplicateSize,
+		Checker:  d,
+	}
+
+	if raw := r.URL.Query().Get("depth"); raw != "" {
+		depth, err := strconv.Atoi(raw)
+		if err != nil || depth < 0 {
+			return opts, fmt.Errorf("invalid depth %q", raw)
+		}
+
+		if depth > maxDepth {
+			depth = maxDepth
+		}
+		opts.Depth = depth
+	}
+
+	if r.URL.Query().Get("large") == "true" {
+		opts.MaxSize = 0
+	}
+
+	return opts, nil
+}
+
+// renderDuplicates renders the groups of identical files under the
+// requested path as JSON or plain text. The HTML listings show them
+// instead of the items of the directory.
+func renderDuplicates(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+
+	format := negotiatedFormat(w, r, d)
+	if format == "" {
+		return http.StatusNotAcceptable, nil
+	}
+
+	info, err := d.user.Fs.Stat(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return http.StatusBadRequest, nil
+	}
+
+	opts, err := duplicatesOptions(r, d, r.URL.Path)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	dups, err := files.FindDuplicates(opts)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if dups.Truncated {
+		w.Header().Set("X-Results-Truncated", "true")
+	}
+
+	if format != formatText {
+		return renderJSON(w, r, dups)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for i, group := range dups.Groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "%d copies of %d bytes\n%s\n", len(group.Paths), group.Size, strings.Join(group.Paths, "\n"))
+	}
+
+	return 0, nil
+}
+
+// duplicatesListing returns the duplicates found with opts as a listing,
+// whose items are grouped by their contents and named by their paths
+// relative to the searched directory.
+func duplicatesListing(d *data, opts files.DuplicatesOptions) (*files.Listing, bool, error) {
+	dups, err := files.FindDuplicates(opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	listing := &files.Listing{Items: []*files.FileInfo{}}
+	for _, group := range dups.Groups {
+		for _, p := range group.Paths {
+			info, err := d.user.Fs.Stat(p)
+			if err != nil {
+				continue
+			}
+
+			item := &files.FileInfo{
+				Fs:        d.user.Fs,
+				Path:      p,
+				Name:      strings.TrimPrefix(strings.TrimPrefix(p, opts.Path), "/"),
+				Size:      info.Size(),
+				Extension: path.Ext(p),
+				ModTime:   info.ModTime(),
+				ModUnix:   info.ModTime().Unix(),
+				Mode:      info.Mode(),
+				OctalMode: files.OctalMode(info.Mode()),
+				Checksums: map[string]string{"sha256": group.Hash},
+			}
+			item.Owner, item.Group = files.Ownership(info)
+
+			if err := item.Classify(d.settings.Categories); err == nil {
+				listing.Items = append(listing.Items, item)
+				listing.NumFiles++
+			}
+		}
+	}
+
+	return listing, dups.Truncated, nil
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// replaceHandler replaces the contents of the requested file with the
+// body, or creates the file, and answers with 204 or 201 and the new
+// ETag. The bodies over the maximum size of the edited files, or of the
+// uploads, fail with 413, and the ones whose preconditions fail, such as
+// an If-Match of an older version of the file, with 412.
+func replaceHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := path.Clean("/" + r.URL.Path)
+	capabilities := d.capabilities(p)
+
+	mode := os.FileMode(0775)
+	info, err := d.user.Fs.Stat(p)
+	created := os.IsNotExist(err)
+	switch {
+	case err != nil && !created:
+		return errToStatus(err), err
+	case created && !capabilities.CanUpload, !created && !capabilities.CanEdit:
+		return http.StatusForbidden, nil
+	case created:
+	case info.IsDir():
+		return http.StatusMethodNotAllowed, nil
+	case files.SpecialKind(info.Mode()) != "":
+		return errToStatus(errors.ErrSpecialFile), errors.ErrSpecialFile
+	default:
+		mode = info.Mode().Perm()
+	}
+
+	limit := uploadLimit(d, d.settings.EditLimit())
+	if r.ContentLength > limit {
+		return renderFileFailure(w, r, http.StatusRequestEntityTooLarge, p, errors.ErrTooLarge.Error())
+	}
+
+	check := func() error {
+		return checkWrite(r, d, p)
+	}
+
+	info, err = replaceFile(d, p, r.Body, r.ContentLength, mode, limit, check)
+	switch {
+	case err == errors.ErrTooLarge:
+		return renderFileFailure(w, r, http.StatusRequestEntityTooLarge, p, err.Error())
+	case err == errors.ErrStale:
+		return renderStale(w, r, d, p)
+	case err == errors.ErrUnconditional:
+		return renderFailure(w, r, http.StatusPreconditionRequired, err.Error())
+	case err != nil:
+		return errToStatus(err), err
+	}
+
+	w.Header().Set("ETag", currentETag(d.user.Fs, p, info.ModTime(), info.Size()))
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return 0, nil
+}
+
+// editLocks are the locks of the files being replaced, by full path.
+// Unlike lockPath, which fails when the file is locked, the edits of a
+// file wait for the ones before them, so they check their preconditions
+// against the contents those wrote instead of failing with 423.
+var editLocks = struct {
+	sync.Mutex
+	paths map[string]*editLock
+}{paths: map[string]*editLock{}}
+
+// editLock is the lock of a file and the number of edits holding or
+// waiting for it, so it's dropped once there are none.
+type editLock struct {
+	sync.Mutex
+	edits int
+}
+
+// lockEdit waits for the edits of the file at p before it and locks it
+// for the one of the request until the returned func is called.
+func lockEdit(d *data, p string) func() {
+	key := d.user.FullPath(p)
+
+	editLocks.Lock()
+	lock, ok := editLocks.paths[key]
+	if !ok {
+		lock = &editLock{}
+		editLocks.paths[key] = lock
+	}
+	lock.edits++
+	editLocks.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		editLocks.Lock()
+		if lock.edits--; lock.edits == 0 {
+			delete(editLocks.paths, key)
+		}
+		editLocks.Unlock()
+	}
+}
+
+// replaceFile writes body, of size bytes or -1 if it's unknown, to a
+// temporary file next to p and renames it to p once it's complete, so p
+// is never seen half written. The temporary file has the mode of p. The
+// bodies over limit bytes fail with errors.ErrTooLarge. check is called
+// with p locked before it's written, once the other edits of p are done,
+// so p can't change in between.
+func replaceFile(d *data, p string, body io.Reader, size int64, mode os.FileMode, limit int64, check func() error) (os.FileInfo, error) {
+	defer lockEdit(d, p)()
+
+	release, err := lockPath(d, p)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if err := check(); err != nil {
+		return nil, err
+	}
+
+	var info os.FileInfo
+	err = d.RunHook(func() error {
+		info, err = writeTemp(d, p, ".edit-", countUploaded(d, body), mode, limit)
+		return err
+	}, "upload", p, "", size, d.user)
+	if err != nil {
+		return nil, err
+	}
+
+	d.notify(settings.EventUpload, p, "", info.Size())
+	return info, nil
+}
+
+// renderContent writes the file with its contents as JSON, as a string
+// if they are UTF-8 text and in base64 otherwise, for the editors, with
+// the ETag to replace it with. The files over the maximum size of the
+// edited files fail with 413.
+func renderContent(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	if !d.user.Perm.Download {
+		return http.StatusForbidden, nil
+	}
+
+	if file.Special != "" {
+		return errToStatus(errors.ErrSpecialFile), errors.ErrSpecialFile
+	}
+
+	if file.Size > d.settings.EditLimit() {
+		return renderFailure(w, r, http.StatusRequestEntityTooLarge, errors.ErrTooLarge.Error())
+	}
+
+	content, err := afero.ReadFile(d.user.Fs, file.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	w.Header().Set("ETag", contentETag(file.ModTime, content))
+	w.Header().Set("Last-Modified", file.ModTime.UTC().Format(http.TimeFormat))
+	if utf8.Valid(content) {
+		file.Content, file.Encoding = string(content), ""
+	} else {
+		file.Content, file.Encoding = base64.StdEncoding.EncodeToString(content), "base64"
+	}
+
+	return renderJSON(w, r, file)
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// embeddedAsset is one of the listing assets, with what it's served with,
+// which is computed once at startup.
+type embeddedAsset struct {
+	content     []byte
+	gzipped     []byte
+	contentType string
+	version     string
+}
+
+// embeddedAssets are the listing assets by their name.
+var embeddedAssets = loadEmbeddedAssets(listingAssets)
+
+// loadEmbeddedAssets hashes and compresses the assets. The compressed
+// bodies that aren't smaller are dropped.
+func loadEmbeddedAssets(assets map[string]string) map[string]*embeddedAsset {
+	loaded := make(map[string]*embeddedAsset, len(assets))
+	for name, content := range assets {
+		sum := sha256.Sum256([]byte(content))
+		asset := &embeddedAsset{
+			content:     []byte(content),
+			contentType: mime.TypeByExtension(path.Ext(name)),
+			version:     hex.EncodeToString(sum[:8]),
+		}
+
+		if asset.contentType == "" {
+			asset.contentType = "application/octet-stream"
+		}
+
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, err := zw.Write(asset.content); err == nil && zw.Close() == nil && buf.Len() < len(asset.content) {
+			asset.gzipped = buf.Bytes()
+		}
+
+		loaded[name] = asset
+	}
+
+	return loaded
+}
+
+// assetURL returns the path of the listing asset name, under the URL of
+// the static files, with its version, so it's cached until it changes.
+func assetURL(name string) string {
+	if asset, ok := embeddedAssets[name]; ok {
+		return name + "?v=" + asset.version
+	}
+
+	return name
+}
+
+// serveEmbeddedAsset serves the listing asset name, compressed for the
+// clients that accept gzip. Its ETag is its version, which the
+// compressed body has a suffix on, and its versioned URLs are cached for
+// long. The other names, whatever they are, are not found.
+func serveEmbeddedAsset(w http.ResponseWriter, r *http.Request, name string) (int, error) {
+	asset, ok := embeddedAssets[name]
+	if !ok {
+		return http.StatusNotFound, nil
+	}
+
+	cacheAsset(w, r, asset.version)
+	w.Header().Set("Content-Type", asset.contentType)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	content, etag := asset.content, `"`+asset.version+`"`
+	if asset.gzipped != nil && encodingQuality(r, "gzip") > 0 {
+		content, etag = asset.gzipped, `"`+asset.version+`-gzip"`
+		w.Header().Set("Content-Encoding", "gzip")
+		// The ranges would be of the compressed body.
+		r.Header.Del("Range")
+	}
+
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, name, listingAssetsModTime, bytes.NewReader(content))
+	return 0, nil
+}
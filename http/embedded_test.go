@@ -0,0 +1,109 @@
+package http_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+func TestEmbeddedAssets(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "a"}})
+
+	plain := do(t, srv, "GET", "/static/listing.js", "")
+	if plain.Code != http.StatusOK || plain.Body.Len() == 0 {
+		t.Fatalf("GET = %d: %s", plain.Code, plain.Body)
+	}
+	etag := plain.Header().Get("ETag")
+	version := strings.Trim(etag, `"`)
+	if version == "" {
+		t.Fatal("the asset has no ETag")
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		headers  []string
+		code     int
+		etag     string
+		encoding string
+		cache    string
+	}{
+		{"plain", "GET", "/static/listing.js", nil, http.StatusOK, etag, "", "no-cache"},
+		{"versioned", "GET", "/static/listing.js?v=" + version, nil, http.StatusOK, etag, "", "public, max-age=31536000, immutable"},
+		{"old version", "GET", "/static/listing.js?v=0", nil, http.StatusOK, etag, "", "no-cache"},
+		{"gzip", "GET", "/static/listing.js", []string{"Accept-Encoding", "gzip, deflate, br"}, http.StatusOK, `"` + version + `-gzip"`, "gzip", "no-cache"},
+		{"gzip refused", "GET", "/static/listing.js", []string{"Accept-Encoding", "gzip;q=0, deflate"}, http.StatusOK, etag, "", "no-cache"},
+		{"not modified", "GET", "/static/listing.js", []string{"If-None-Match", etag}, http.StatusNotModified, etag, "", "no-cache"},
+		{"gzip not modified", "GET", "/static/listing.js", []string{"If-None-Match", `"` + version + `-gzip"`, "Accept-Encoding", "gzip"}, http.StatusNotModified, `"` + version + `-gzip"`, "gzip", "no-cache"},
+		{"modified", "GET", "/static/listing.js", []string{"If-None-Match", `"0"`}, http.StatusOK, etag, "", "no-cache"},
+		{"HEAD", "HEAD", "/static/listing.js", nil, http.StatusOK, etag, "", "no-cache"},
+		{"stylesheet", "GET", "/static/themes/dark.css", nil, http.StatusOK, "", "", "no-cache"},
+		{"missing", "GET", "/static/missing.js", nil, http.StatusNotFound, "", "", ""},
+		{"traversal", "GET", "/static/themes/../../http.go", nil, http.StatusNotFound, "", "", ""},
+		{"escaped traversal", "GET", "/static/%2e%2e/http.go", nil, http.StatusNotFound, "", "", ""},
+		{"missing script", "GET", "/static/themes/missing.js", nil, http.StatusNotFound, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, srv, tt.method, tt.target, "", tt.headers...)
+			if w.Code != tt.code {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.target, w.Code, tt.code)
+			}
+			if w.Code == http.StatusNotFound {
+				// The error pages aren't scripts.
+				if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/") {
+					t.Errorf("the 404 has the Content-Type %q", got)
+				}
+				return
+			}
+
+			header := w.Header()
+			if tt.etag != "" && header.Get("ETag") != tt.etag {
+				t.Errorf("ETag = %q, want %q", header.Get("ETag"), tt.etag)
+			}
+			// The responses without a body have no encoding.
+			if got := header.Get("Content-Encoding"); got != tt.encoding && w.Code != http.StatusNotModified {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.encoding)
+			}
+			if got := header.Get("Cache-Control"); got != tt.cache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cache)
+			}
+			if got := header.Get("Vary"); !strings.Contains(got, "Accept-Encoding") {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+
+			switch {
+			case w.Code == http.StatusNotModified || tt.method == "HEAD":
+				if w.Body.Len() != 0 {
+					t.Errorf("the response has a body of %d bytes", w.Body.Len())
+				}
+				if tt.method == "HEAD" && header.Get("Content-Length") != plain.Header().Get("Content-Length") {
+					t.Errorf("Content-Length = %q, want %q", header.Get("Content-Length"), plain.Header().Get("Content-Length"))
+				}
+			case tt.encoding == "gzip":
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, err := ioutil.ReadAll(zr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(body, plain.Body.Bytes()) {
+					t.Error("the compressed body isn't the asset")
+				}
+			case strings.HasPrefix(tt.target, "/static/listing.js"):
+				if !bytes.Equal(w.Body.Bytes(), plain.Body.Bytes()) {
+					t.Error("the body isn't the asset")
+				}
+			}
+		})
+	}
+}
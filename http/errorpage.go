@@ -0,0 +1,255 @@
+package http
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// errorTemplatesDir is the directory, inside the branding directory, with
+// the templates of the error pages. They are named after their status,
+// such as 404.html, and error.html is used for the other statuses.
+const errorTemplatesDir = "errors"
+
+// maxAncestorItems is the maximum number of files of the nearest existing
+// directory listed by the not found pages.
+const maxAncestorItems = 50
+
+const defaultErrorTemplate = `<!DOCTYPE html>
+<html lang="{{ .Locale }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Status }} {{ .StatusText }}</title>
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/light.css" }}">
+{{- if eq .Theme "dark" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}">
+{{- else if eq .Theme "" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}" media="(prefers-color-scheme: dark)">
+{{- end }}
+</head>
+<body class="theme-{{ or .Theme "auto" }}">
+<h1>{{ .Status }} {{ .StatusText }}</h1>
+<p>{{ .Message }}</p>
+{{- with .RequestID }}
+<p><small>{{ $.T "requestID" . }}</small></p>
+{{- end }}
+{{- with .Breadcrumbs }}
+<p>
+{{- range $i, $crumb := . }}{{ if $i }} / {{ end }}<a href="{{ $crumb.URL }}">{{ $crumb.Name }}</a>{{ end -}}
+</p>
+{{- end }}
+{{- with .Ancestor }}
+<h2>{{ $.T "nearestDirectory" .Path }}</h2>
+<ul>
+{{- range .Items }}
+{{- if .IsDir }}
+<li>{{ iconFor . }} <a href="{{ $.BaseURL }}{{ pathJoinURL "/api/resources" .Path "/" }}{{ $.Query }}">{{ .Name }}/</a></li>
+{{- else }}
+<li>{{ iconFor . }} <a href="{{ $.BaseURL }}{{ pathJoinURL "/api/raw" .Path }}{{ $.Query }}">{{ .Name }}</a></li>
+{{- end }}
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`
+
+var defaultErrorPage = template.Must(template.New("error").Funcs(listingFuncs).Parse(defaultErrorTemplate))
+
+// errorMessages are the keys of the translations of the messages of the
+// error pages by status.
+var errorMessages = map[int]string{
+	http.StatusForbidden:           "errorForbidden",
+	http.StatusNotFound:            "errorNotFound",
+	http.StatusInternalServerError: "errorInternal",
+}
+
+// errorPage is the data the error templates are executed with. Ancestor
+// is the nearest existing directory of the files that were not found,
+// if the user can list it.
+type errorPage struct {
+	Status      int
+	StatusText  string
+	Message     string
+	BaseURL     string
+	StaticURL   string
+	Query       string
+	Theme       string
+	Locale      string
+	Breadcrumbs []crumb
+	Ancestor    *dirView
+	// RequestID is the ID of the request, which the users can report.
+	RequestID string
+
+	variables map[string]string
+
+	pageMessages
+}
+
+// Var returns the variable of the user with key, or def if there is no
+// user or it has no such variable.
+func (p *errorPage) Var(key, def string) string {
+	return userVar(p.variables, key, def)
+}
+
+// renderError writes the response of a failed request. Browsers get an
+// HTML page and the clients that accept JSON get an object with the
+// status, while the others keep getting the status as plain text. The
+// pages of the files of the user link to the directories above them.
+func renderError(w http.ResponseWriter, r *http.Request, d *data, prefix string, status int) {
+	accept := r.Header.Get("Accept")
+	text := http.StatusText(status)
+
+	switch {
+	case status < 400:
+	case strings.Contains(accept, "application/json"):
+		if err := writeFailure(w, status, strings.ToLower(text)); err != nil {
+			d.logger.Error("couldn't write the error", "path", r.URL.Path, "error", err)
+		}
+		return
+	case strings.Contains(accept, "text/html"):
+		page, err := errorHTML(r, d, prefix, status)
+		if err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(status)
+			_, _ = page.WriteTo(w)
+			return
+		}
+
+		d.logger.Error("couldn't render the error page", "path", r.URL.Path, "error", err)
+	}
+
+	http.Error(w, strconv.Itoa(status)+" "+text, status)
+}
+
+// errorHTML renders the error page of status with the template of the
+// branding directory if there is one and it works, or with the default
+// template otherwise.
+func errorHTML(r *http.Request, d *data, prefix string, status int) (*bytes.Buffer, error) {
+	locale := detectLocale(r, d.settings.Defaults.Locale)
+	var variables map[string]string
+	if d.user != nil {
+		locale = detectLocale(r, d.user.Locale)
+		variables = d.user.Variables
+	}
+
+	page := &errorPage{
+		Status:       status,
+		StatusText:   http.StatusText(status),
+		BaseURL:      d.baseURL(r),
+		StaticURL:    d.staticURL(d.baseURL(r)),
+		Theme:        activeTheme(r, d.settings.Branding.Theme),
+		Locale:       locale,
+		RequestID:    RequestID(r.Context()),
+		pageMessages: messages(locale),
+		variables:    variables,
+	}
+
+	page.Message = page.StatusText
+	if key, ok := errorMessages[status]; ok {
+		page.Message = page.T(key)
+	}
+
+	if query := keptQuery(r); len(query) > 0 {
+		page.Query = "?" + query.Encode()
+	}
+
+	// Only the paths of the files of the user lead somewhere.
+	if d.user != nil && (prefix == "/api/resources" || prefix == "/api/raw") {
+		p := path.Clean("/" + r.URL.Path)
+		page.Breadcrumbs = breadcrumbs(page.BaseURL, page.Query, path.Dir(p), page.T("home"))
+
+		if status == http.StatusNotFound {
+			if ancestor := nearestAncestor(r, d, p); ancestor != nil {
+				view := newDirView(ancestor)
+				page.Ancestor = &view
+			}
+		}
+	}
+
+	tpl := errorTemplate(d, status)
+
+	var buf bytes.Buffer
+	err := tpl.Execute(&buf, page)
+	if err != nil && tpl != defaultErrorPage {
+		d.logger.Warn("couldn't render the error template, using the default one", "status", status, "error", err)
+		buf.Reset()
+		err = defaultErrorPage.Execute(&buf, page)
+	}
+
+	return &buf, err
+}
+
+// errorTemplate returns the template of the error pages of status.
+func errorTemplate(d *data, status int) *template.Template {
+	if d.settings.Branding.Files == "" {
+		return defaultErrorPage
+	}
+
+	parse := func(name, text string) (interface{}, error) {
+		return template.New(name).Funcs(listingFuncs).Parse(text)
+	}
+
+	for _, name := range []string{strconv.Itoa(status) + ".html", "error.html"} {
+		p := filepath.Join(d.settings.Branding.Files, errorTemplatesDir, name)
+		tpl, err := customTemplate(d, errorTemplates, p, parse)
+		if err != nil {
+			d.logger.Warn("couldn't load the error template, using the default one", "template", p, "error", err)
+			return defaultErrorPage
+		} else if tpl != nil {
+			return tpl.(*template.Template)
+		}
+	}
+
+	return defaultErrorPage
+}
+
+// nearestAncestor returns the nearest directory above p that exists and
+// the user can list, with at most maxAncestorItems files.
+func nearestAncestor(r *http.Request, d *data, p string) *files.FileInfo {
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		file, err := files.NewFileInfo(files.FileOptions{
+			Fs:         d.user.Fs,
+			Path:       dir,
+			Expand:     true,
+			Checker:    d,
+			Categories: d.settings.Categories,
+			Readlink:   d.readlink(),
+			Logger:     d.logger,
+		})
+		if err == nil && file.IsDir {
+			file.Listing.Sorting = d.user.Sorting
+			sortListing(r, d, file.Listing)
+
+			if d.settings.DirTemplates {
+				hideListed(file.Listing, dirTemplateName)
+			}
+
+			if d.settings.DirOptions != "" {
+				hideListed(file.Listing, d.settings.DirOptions)
+			}
+
+			hideTrash(d, file.Listing)
+
+			hideDotfiles(r, d, file.Listing)
+
+			if len(file.Items) > maxAncestorItems {
+				file.Items = file.Items[:maxAncestorItems]
+			}
+
+			return file
+		}
+
+		if dir == "/" {
+			return nil
+		}
+	}
+}
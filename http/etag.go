@@ -0,0 +1,203 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	fbErrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// etagHashLimit is the size of the biggest files whose ETags hash their
+// contents, so the edits that keep their sizes and, on the filesystems
+// with coarse times, their modification times change them too.
+const etagHashLimit = 1 << 20
+
+// listingETag returns the weak ETag of the listing of file as it's
+// rendered for the request: it changes with the items of the page, with
+// their sizes, modification times and annotations, and with what the
+// listing is rendered with, such as its format, sorting, limit, query,
+// user, locale and theme, so the formats of a directory don't share it.
+func listingETag(r *http.Request, d *data, file *files.FileInfo) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s\x00%s\x00%t\x00%d\x00%d\x00%d\x00%s\x00%s\x00%s\x00%d\x00",
+		d.format, file.Sorting.By, file.Sorting.Asc, d.limit, file.Offset, file.TotalItems,
+		r.URL.Query().Encode(), detectLocale(r, d.user.Locale), activeTheme(r, d.settings.Branding.Theme), d.user.ID)
+	fmt.Fprintf(hash, "%d\x00", file.ModTime.UnixNano())
+
+	for _, favorite := range file.Favorites {
+		fmt.Fprintf(hash, "%s\x00%t\x00", favorite.Path, favorite.Missing)
+	}
+
+	for _, item := range file.Items {
+		fmt.Fprintf(hash, "%s\x00%d\x00%d\x00%t\x00%s\x00%s\x00",
+			item.Name, item.Size, item.ModTime.UnixNano(), item.IsDir, item.GitStatus, strings.Join(item.Tags, "\x01"))
+	}
+
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// listingModTime returns the last modification of the listing of file:
+// the one of the directory or of its newest item.
+func listingModTime(file *files.FileInfo) time.Time {
+	modTime := file.ModTime
+	for _, item := range file.Items {
+		if item.ModTime.After(modTime) {
+			modTime = item.ModTime
+		}
+	}
+
+	return modTime
+}
+
+// listingNotModified sets the ETag and the Last-Modified headers of the
+// listing and checks if the copy of the client is still fresh, with
+// If-None-Match or, when there's none, with If-Modified-Since.
+func listingNotModified(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) bool {
+	w.Header().Set("ETag", etag)
+	if !modTime.IsZero() {
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.IsZero() && !modTime.Truncate(time.Second).After(since)
+}
+
+// contentETag returns the strong ETag of a file with content, which
+// changes with its modification time, its size and, if it's small, its
+// contents.
+func contentETag(modTime time.Time, content []byte) string {
+	if len(content) > etagHashLimit {
+		return fileETag(modTime, int64(len(content)))
+	}
+
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf(`"%x%x-%x"`, modTime.UnixNano(), len(content), sum[:8])
+}
+
+// currentETag returns the ETag of the file at p, of size bytes: the one
+// of its contents if it's small, and the one of its modification time and
+// its size otherwise.
+func currentETag(fs afero.Fs, p string, modTime time.Time, size int64) string {
+	if size <= etagHashLimit {
+		if content, err := afero.ReadFile(fs, p); err == nil {
+			return contentETag(modTime, content)
+		}
+	}
+
+	return fileETag(modTime, size)
+}
+
+// etagMatches checks if the list of ETags of an If-Match or If-None-Match
+// header has etag, comparing them strongly: the weak ones never match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkWrite checks the preconditions of a write to p, under its lock so
+// the file can't change before it's written: If-Match or, when there's
+// none, If-Unmodified-Since for the existing files, and If-None-Match,
+// such as * for the creations. The writes to the existing files without
+// them fail with fbErrors.ErrUnconditional if the settings require them,
+// and the ones whose preconditions fail with fbErrors.ErrStale.
+func checkWrite(r *http.Request, d *data, p string) error {
+	info, err := d.user.Fs.Stat(p)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var etag string
+	current := func() string {
+		if etag == "" {
+			etag = currentETag(d.user.Fs, p, info.ModTime(), info.Size())
+		}
+		return etag
+	}
+
+	match := r.Header.Get("If-Match")
+	noneMatch := r.Header.Get("If-None-Match")
+	since, sinceErr := http.ParseTime(r.Header.Get("If-Unmodified-Since"))
+	switch {
+	case match != "":
+		if !exists || !etagMatches(match, current()) {
+			return fbErrors.ErrStale
+		}
+	case sinceErr == nil:
+		if exists && info.ModTime().Truncate(time.Second).After(since) {
+			return fbErrors.ErrStale
+		}
+	case exists && noneMatch == "" && d.settings.RequireConditionalWrites:
+		return fbErrors.ErrUnconditional
+	}
+
+	if noneMatch != "" && exists && etagMatches(noneMatch, current()) {
+		return fbErrors.ErrStale
+	}
+
+	return nil
+}
+
+// staleFailure is the failure of the writes whose preconditions failed,
+// with the file as it is now and its ETag, so the clients can read it
+// again and merge their changes.
+type staleFailure struct {
+	failure
+	ETag    string      `json:"etag,omitempty"`
+	Current *listedFile `json:"current,omitempty"`
+}
+
+// renderStale answers a write to p whose preconditions failed with 412
+// and the ETag of the file as it is now, in the headers and, if the
+// client accepts JSON, in the body with the file.
+func renderStale(w http.ResponseWriter, r *http.Request, d *data, p string) (int, error) {
+	info, err := d.user.Fs.Stat(p)
+	if err == nil {
+		w.Header().Set("ETag", currentETag(d.user.Fs, p, info.ModTime(), info.Size()))
+	}
+
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		return http.StatusPreconditionFailed, nil
+	}
+
+	body := staleFailure{failure: failure{
+		Status:    http.StatusPreconditionFailed,
+		Error:     fbErrors.ErrStale.Error(),
+		RequestID: w.Header().Get(RequestIDHeader),
+	}}
+	if err == nil {
+		body.ETag = w.Header().Get("ETag")
+		body.Current, _ = newListedFile(r, d, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	return 0, json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,90 @@
+package http_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+func TestListingETag(t *testing.T) {
+	modTime := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	srv, fs := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt": {Content: "a", ModTime: modTime},
+		"/docs/b.txt": {Content: "b", ModTime: modTime.Add(-time.Hour)},
+	})
+
+	get := func(target, accept string, headers ...string) (status int, etag string) {
+		t.Helper()
+		w := do(t, srv, "GET", target, "", append([]string{"Accept", accept}, headers...)...)
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("the 304 of %s has a body: %s", target, w.Body)
+		}
+		return w.Code, w.Header().Get("ETag")
+	}
+
+	status, etag := get("/api/resources/docs/", "application/json")
+	if status != http.StatusOK || etag == "" {
+		t.Fatalf("GET = %d with the ETag %q", status, etag)
+	}
+
+	// The validators of the other renderings of the listing differ.
+	for _, other := range []struct{ target, accept string }{
+		{"/api/resources/docs/", "text/html"},
+		{"/api/resources/docs/?format=text", ""},
+		{"/api/resources/docs/?sort=size", "application/json"},
+		{"/api/resources/docs/?sort=name&order=desc", "application/json"},
+		{"/api/resources/docs/?limit=1", "application/json"},
+	} {
+		if _, got := get(other.target, other.accept); got == etag || got == "" {
+			t.Errorf("%s %s has the ETag %q, want another one than %q", other.target, other.accept, got, etag)
+		}
+	}
+
+	lastModified := modTime.Format(http.TimeFormat)
+	tests := []struct {
+		name    string
+		headers []string
+		status  int
+	}{
+		{"fresh ETag", []string{"If-None-Match", etag}, http.StatusNotModified},
+		{"one of the ETags", []string{"If-None-Match", `W/"other", ` + etag}, http.StatusNotModified},
+		{"any ETag", []string{"If-None-Match", "*"}, http.StatusNotModified},
+		{"other ETag", []string{"If-None-Match", `W/"other"`}, http.StatusOK},
+		{"fresh date", []string{"If-Modified-Since", lastModified}, http.StatusNotModified},
+		{"later date", []string{"If-Modified-Since", modTime.Add(time.Hour).Format(http.TimeFormat)}, http.StatusNotModified},
+		{"stale date", []string{"If-Modified-Since", modTime.Add(-time.Second).Format(http.TimeFormat)}, http.StatusOK},
+		{"invalid date", []string{"If-Modified-Since", "yesterday"}, http.StatusOK},
+		// If-None-Match is checked first.
+		{"other ETag and fresh date", []string{"If-None-Match", `W/"other"`, "If-Modified-Since", lastModified}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := get("/api/resources/docs/", "application/json", tt.headers...); status != tt.status {
+				t.Errorf("GET = %d, want %d", status, tt.status)
+			}
+		})
+	}
+
+	// Touching a file changes the listing.
+	if err := fs.Chtimes("/docs/b.txt", modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	status, touched := get("/api/resources/docs/", "application/json", "If-None-Match", etag)
+	if status != http.StatusOK || touched == etag {
+		t.Errorf("GET after touching a file = %d with the ETag %q, want 200 and another ETag", status, touched)
+	}
+
+	// So does a new one, which is newer than the listing was.
+	if err := afero.WriteFile(fs, "/docs/c.txt", []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := get("/api/resources/docs/", "application/json", "If-Modified-Since", lastModified); status != http.StatusOK {
+		t.Errorf("GET after adding a file = %d, want 200", status)
+	}
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// faviconsPrefix is the path, inside the static files, where the icons
+// of the HTML listings are served. The icons of the scopes with their
+// own are under the ID of their user, and the others under "default".
+const faviconsPrefix = "favicons/"
+
+const defaultFavicon = "folder"
+
+// embeddedFavicons are the icons that can be picked by name instead of
+// giving the path of an image.
+var embeddedFavicons = map[string]string{
+	"folder": `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24"><path fill="#2979ff" d="M10 4H4a2 2 0 0 0-2 2v12a2 2 0 0 0 2 2h16a2 2 0 0 0 2-2V8a2 2 0 0 0-2-2h-8z"/></svg>`,
+	"cloud":  `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24"><path fill="#2979ff" d="M19.35 10.04A7.49 7.49 0 0 0 12 4C9.11 4 6.6 5.64 5.35 8.04A5.994 5.994 0 0 0 0 14c0 3.31 2.69 6 6 6h13c2.76 0 5-2.24 5-5 0-2.64-2.05-4.78-4.65-4.96z"/></svg>`,
+	"star":   `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24"><path fill="#ffb300" d="M12 17.27L18.18 21l-1.64-7.03L22 9.24l-7.19-.61L12 2 9.19 8.63 2 9.24l5.46 4.73L5.82 21z"/></svg>`,
+}
+
+// faviconTypes are the content types of the images that can be icons.
+var faviconTypes = map[string]string{
+	".ico":  "image/x-icon",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".svg":  "image/svg+xml",
+}
+
+// favicon is the icon of the HTML listings, either embedded or read from
+// the file at path.
+type favicon struct {
+	name string
+	path string
+	info os.FileInfo
+}
+
+func (f *favicon) version() string {
+	if f.info == nil {
+		return f.name
+	}
+
+	return brandingAssetVersion(f.info)
+}
+
+// listingTitle returns the prefix of the titles of the HTML listings of
+// the scope of the user.
+func listingTitle(d *data) string {
+	switch {
+	case d.user.Title != "":
+		return d.user.Title
+	case d.user.Branding.Name != "":
+		return d.user.Branding.Name
+	case d.settings.Branding.Title != "":
+		return d.settings.Branding.Title
+	case d.settings.Branding.Name != "":
+		return d.settings.Branding.Name
+	default:
+		return "File Browser"
+	}
+}
+
+// faviconFor returns the icon of the HTML listings of the scope of user,
+// or the default one if user is nil. The icons that can't be used are
+// skipped.
+func faviconFor(d *data, user *users.User) *favicon {
+	candidates := []string{d.settings.Branding.Favicon, defaultFavicon}
+	if user != nil {
+		candidates = append([]string{user.Favicon}, candidates...)
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+
+		if _, ok := embeddedFavicons[candidate]; ok {
+			return &favicon{name: candidate}
+		}
+
+		info, err := os.Stat(candidate)
+		switch {
+		case err != nil:
+		case info.IsDir():
+			err = errors.ErrIsDirectory
+		case info.Size() > maxBrandingAssetSize:
+			err = errors.ErrTooLarge
+		case faviconTypes[strings.ToLower(filepath.Ext(candidate))] == "":
+			err = errors.ErrInvalidDataType
+		}
+
+		if err != nil {
+			d.logger.Warn("couldn't load the favicon", "favicon", candidate, "error", err)
+			continue
+		}
+
+		return &favicon{path: candidate, info: info}
+	}
+
+	return &favicon{name: defaultFavicon}
+}
+
+// faviconURL returns the URL of the icon of the HTML listings of the
+// scope of the user of the request.
+func faviconURL(d *data, baseURL string) string {
+	key, user := "default", (*users.User)(nil)
+	if d.user.Favicon != "" {
+		key, user = strconv.FormatUint(uint64(d.user.ID), 10), d.user
+	}
+
+	return d.staticURL(baseURL) + "/" + faviconsPrefix + key + "?v=" + faviconFor(d, user).version()
+}
+
+// serveFavicon serves the icon of the HTML listings of the scope of the
+// user with the ID key, or the default one.
+func serveFavicon(w http.ResponseWriter, r *http.Request, d *data, key string) (int, error) {
+	var user *users.User
+	if key != "default" {
+		id, err := strconv.ParseUint(key, 10, 0)
+		if err != nil {
+			return http.StatusNotFound, nil
+		}
+
+		user, err = d.store.Users.Get(d.server.Root, uint(id))
+		if err != nil {
+			return errToStatus(err), err
+		}
+	}
+
+	f := faviconFor(d, user)
+	cacheAsset(w, r, f.version())
+
+	if f.info == nil {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		http.ServeContent(w, r, f.name+".svg", listingAssetsModTime, strings.NewReader(embeddedFavicons[f.name]))
+		return 0, nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer file.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(file, maxBrandingAssetSize+1))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if len(content) > maxBrandingAssetSize {
+		return http.StatusInternalServerError, errors.ErrTooLarge
+	}
+
+	w.Header().Set("Content-Type", faviconTypes[strings.ToLower(filepath.Ext(f.path))])
+	http.ServeContent(w, r, f.path, f.info.ModTime(), bytes.NewReader(content))
+	return 0, nil
+}
@@ -0,0 +1,88 @@
+package http_test
+
+import (
+	"html"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+var (
+	titleTag   = regexp.MustCompile(`<title>([^<]*)</title>`)
+	faviconTag = regexp.MustCompile(`<link rel="icon" href="([^"]*)">`)
+)
+
+func TestFavicon(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		title    string
+		favicon  string
+		settings settings.Branding
+		wantTag  string
+		wantURL  string
+		wantIcon string
+	}{
+		{"defaults", "", "", "", settings.Branding{}, "File Browser – /docs/", "/static/favicons/default?v=folder", "#2979ff"},
+		{"settings", "", "", "", settings.Branding{Title: "Files", Favicon: "star"}, "Files – /docs/", "/static/favicons/default?v=star", "#ffb300"},
+		{"scope", "", "Photos", "cloud", settings.Branding{Title: "Files", Favicon: "star"}, "Photos – /docs/", "/static/favicons/1?v=cloud", "M19.35"},
+		{"missing icon", "", "", "/missing.png", settings.Branding{}, "File Browser – /docs/", "/static/favicons/1?v=folder", "#2979ff"},
+		{"base URL", "/fb", "Photos", "cloud", settings.Branding{}, "Photos – /docs/", "/fb/static/favicons/1?v=cloud", "M19.35"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, map[string]filebrowsertest.File{"/docs/a.txt": {Content: "a"}})
+			updateSettings(t, srv, func(s *settings.Settings) {
+				s.Branding.Title = tt.settings.Title
+				s.Branding.Favicon = tt.settings.Favicon
+			})
+			updateUser(t, srv, func(u *users.User) {
+				u.Title = tt.title
+				u.Favicon = tt.favicon
+			})
+
+			if tt.baseURL != "" {
+				err := srv.Handler.(*fbhttp.Handler).Reload(&settings.Server{Root: "/", BaseURL: tt.baseURL})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := do(t, srv, "GET", tt.baseURL+"/api/resources/docs/", "", "Accept", "text/html")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			title := titleTag.FindStringSubmatch(w.Body.String())
+			if title == nil || html.UnescapeString(title[1]) != tt.wantTag {
+				t.Errorf("the title is %q, want %q", title, tt.wantTag)
+			}
+
+			link := faviconTag.FindStringSubmatch(w.Body.String())
+			if link == nil {
+				t.Fatal("the listing has no icon")
+			}
+
+			href := html.UnescapeString(link[1])
+			if href != tt.wantURL {
+				t.Errorf("the icon is %q, want %q", href, tt.wantURL)
+			}
+
+			icon := do(t, srv, "GET", href, "")
+			if icon.Code != http.StatusOK || !strings.Contains(icon.Body.String(), tt.wantIcon) {
+				t.Errorf("GET %s = %d %q, want the icon with %q", href, icon.Code, icon.Body, tt.wantIcon)
+			}
+
+			if cache := icon.Header().Get("Cache-Control"); cache == "" {
+				t.Error("the icon has no Cache-Control header")
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// maxFavorites is the number of paths a user can pin.
+const maxFavorites = 50
+
+// favoritesMu serializes the changes of the favorites, which read the
+// user, change its favorites and write them back.
+var favoritesMu sync.Mutex
+
+// userFavorites returns the favorites of the user. The pins the rules
+// hide from the user are left out, and the ones with nothing at their
+// path anymore are marked as missing.
+func userFavorites(d *data) []files.Favorite {
+	favorites := []files.Favorite{}
+	for _, p := range d.user.Favorites {
+		if !d.Check(p) {
+			continue
+		}
+
+		favorite := files.Favorite{Path: p, Name: path.Base(p)}
+		info, err := d.user.Fs.Stat(p)
+		switch {
+		case os.IsNotExist(err):
+			favorite.Missing = true
+		case err != nil:
+			continue
+		default:
+			favorite.IsDir = info.IsDir()
+		}
+
+		favorites = append(favorites, favorite)
+	}
+
+	return favorites
+}
+
+// updateFavorites changes the favorites of the user with fn and saves
+// them.
+func updateFavorites(d *data, fn func([]string) []string) error {
+	favoritesMu.Lock()
+	defer favoritesMu.Unlock()
+
+	user, err := d.store.Users.Get(d.server.Root, d.user.ID)
+	if err != nil {
+		return err
+	}
+
+	user.Favorites = fn(user.Favorites)
+	if err := d.store.Users.Update(user, "Favorites"); err != nil {
+		return err
+	}
+
+	d.user.Favorites = user.Favorites
+	return nil
+}
+
+func favoritePath(r *http.Request) string {
+	return path.Clean("/" + r.URL.Path)
+}
+
+var favoritesGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	return renderJSON(w, r, userFavorites(d))
+})
+
+var favoritesPostHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := favoritePath(r)
+	if !d.Check(p) {
+		return http.StatusForbidden, nil
+	}
+
+	if _, err := d.user.Fs.Stat(p); err != nil {
+		return errToStatus(err), err
+	}
+
+	full := false
+	err := updateFavorites(d, func(list []string) []string {
+		for _, favorite := range list {
+			if favorite == p {
+				return list
+			}
+		}
+
+		if len(list) >= maxFavorites {
+			full = true
+			return list
+		}
+
+		return append(list, p)
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if full {
+		return renderFailure(w, r, http.StatusBadRequest, "too many favorites")
+	}
+
+	return renderJSON(w, r, userFavorites(d))
+})
+
+// favoritesDeleteHandler unpins a path. It doesn't look at the path, so
+// the pins of removed files can be removed too.
+var favoritesDeleteHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	p := favoritePath(r)
+	err := updateFavorites(d, func(list []string) []string {
+		kept := []string{}
+		for _, favorite := range list {
+			if favorite != p {
+				kept = append(kept, favorite)
+			}
+		}
+
+		return kept
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, userFavorites(d))
+})
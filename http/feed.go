@@ -0,0 +1,113 @@
+package http
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// rssFeed is the RSS 2.0 feed of the files of a directory.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+// rssGUID tells the versions of a file apart, so a file that's replaced
+// is a new entry of the feed.
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// feedListing turns the listing into the one of the RSS feeds: its files,
+// without the directories and the unreadable ones, newest first whatever
+// the request sorts by, up to the maximum number of entries of the
+// settings.
+func feedListing(d *data, listing *files.Listing) {
+	items := listing.Items[:0]
+	for _, item := range listing.Items {
+		if !item.IsDir && !item.Error {
+			items = append(items, item)
+		}
+	}
+
+	listing.Items = items
+	listing.NumDirs, listing.NumFiles, listing.NumUnreadable = 0, len(items), 0
+	listing.Sorting = files.Sorting{By: "modified", Asc: false}
+	listing.ApplySort()
+
+	if limit := d.settings.ListingFeedLimit(); len(listing.Items) > limit {
+		listing.Items = listing.Items[:limit]
+		listing.Truncated = true
+	}
+}
+
+// renderRSS writes the listing of a feed as RSS 2.0, with the absolute
+// links of its files and the date of the newest one as the one of the
+// feed, so the readers can tell when it changed.
+func renderRSS(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	base := d.origin(r) + d.baseURL(r)
+	title := file.Name
+	if title == "" {
+		title = file.Path
+	}
+
+	channel := rssChannel{
+		Title:         title,
+		Link:          base + pathJoinURL("/api/resources", file.Path, "/"),
+		Description:   file.Path,
+		LastBuildDate: listingModTime(file).UTC().Format(time.RFC1123Z),
+		Items:         make([]rssItem, 0, len(file.Items)),
+	}
+
+	for _, item := range file.Items {
+		description := humanSize(item.Size)
+		if t := files.MimeTypeByName(item.Name, d.settings.MimeTypes); t != "" {
+			description += ", " + t
+		}
+
+		link := base + pathJoinURL("/api/raw", item.Path)
+		channel.Items = append(channel.Items, rssItem{
+			Title:       item.Name,
+			Link:        link,
+			Description: description,
+			PubDate:     item.ModTime.UTC().Format(time.RFC1123Z),
+			GUID:        rssGUID{Value: link + "#" + strconv.FormatInt(item.ModTime.UnixNano(), 10)},
+		})
+	}
+
+	setFormatHeaders(w, formatRSS, file)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return 0, err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&rssFeed{Version: "2.0", Channel: channel}); err != nil {
+		return 0, err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return 0, err
+}
@@ -0,0 +1,343 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+const (
+	// maxFetchRedirects is the number of redirects followed by a fetch.
+	maxFetchRedirects = 5
+	// fetchTimeout bounds the time taken to connect to the servers and
+	// to get their headers. The bodies take as long as they need.
+	fetchTimeout = 30 * time.Second
+	// maxFetchRequest is the size of the biggest body of the fetches.
+	maxFetchRequest = 64 << 10
+)
+
+var (
+	errFetchTooLarge    = errors.New("the file is bigger than the fetches can be")
+	errPrivateAddress   = errors.New("the server doesn't fetch from private addresses")
+	errChecksumMismatch = errors.New("the file doesn't match the checksum")
+)
+
+// fetchRequest is the body of the uploads by URL. Filename defaults to the
+// name the server gives the file, or the last element of the URL, and
+// Checksum, such as sha256:..., is checked against the fetched file.
+type fetchRequest struct {
+	URL      string `json:"url"`
+	Filename string `json:"filename"`
+	Checksum string `json:"checksum"`
+}
+
+// privateNetworks are the private ranges of RFC 1918 and RFC 4193, and
+// the carrier-grade NAT range, which isn't reachable from the internet
+// either.
+var privateNetworks = []*net.IPNet{
+	{IP: net.IPv4(10, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv4(172, 16, 0, 0), Mask: net.CIDRMask(12, 32)},
+	{IP: net.IPv4(192, 168, 0, 0), Mask: net.CIDRMask(16, 32)},
+	{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)},
+	{IP: net.ParseIP("fc00::"), Mask: net.CIDRMask(7, 128)},
+}
+
+// privateIP checks if ip is an address the server doesn't fetch from: a
+// loopback, private, link-local, multicast or unspecified one.
+func privateIP(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		ip.IsUnspecified() || ip.To4() != nil && ip.To4()[0] == 0
+}
+
+// fetchClient returns the client of the fetches. The addresses are
+// checked when connecting, after they are resolved, so neither a
+// redirect nor a name resolving to another address can reach the
+// private networks that aren't allowed.
+func fetchClient(allow []string) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: fetchTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || privateIP(ip) && !trustedIP(ip, allow) {
+				return errPrivateAddress
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			// The proxies of the environment would connect for the
+			// server, so they aren't used.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   fetchTimeout,
+			ResponseHeaderTimeout: fetchTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxFetchRedirects {
+				return fmt.Errorf("more than %d redirects", maxFetchRedirects)
+			}
+
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirected to a %s URL", req.URL.Scheme)
+			}
+
+			return nil
+		},
+	}
+}
+
+// fetchName returns the name of the file of res: the one of its
+// Content-Disposition, or else the last element of its path. The names
+// that aren't valid are replaced by "download".
+func fetchName(res *http.Response) string {
+	var name string
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+
+	if name == "" {
+		name = path.Base(res.Request.URL.Path)
+	}
+
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if !validName(name) {
+		return "download"
+	}
+
+	return name
+}
+
+// parseChecksum returns the hash and the expected sum of a checksum such
+// as sha256:..., or a nil hash if there's none.
+func parseChecksum(checksum string) (hash.Hash, []byte, error) {
+	if checksum == "" {
+		return nil, nil, nil
+	}
+
+	colon := strings.Index(checksum, ":")
+	if colon < 0 {
+		return nil, nil, fmt.Errorf("the checksum must be an algorithm and a sum, such as sha256:…")
+	}
+
+	algo, sum := checksum[:colon], checksum[colon+1:]
+
+	h, err := files.NewHash(algo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid checksum algorithm %q: it must be md5, sha1, sha256 or sha512", algo)
+	}
+
+	expected, err := hex.DecodeString(sum)
+	if err != nil || len(expected) != h.Size() {
+		return nil, nil, fmt.Errorf("invalid %s sum %q", algo, sum)
+	}
+
+	return h, expected, nil
+}
+
+// fetchHandler uploads a file to the directory of the request by fetching
+// its URL. The file is written to a temporary file next to it first, and
+// renamed into place once it's complete and checked. The limits of the
+// uploads apply to it too.
+func fetchHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.settings.Fetch.Enabled {
+		return renderFailure(w, r, http.StatusForbidden, "the uploads by URL are off")
+	}
+
+	req := &fetchRequest{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxFetchRequest)).Decode(req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return renderFailure(w, r, http.StatusBadRequest, "only the http and https URLs can be fetched")
+	}
+
+	if req.Filename != "" && (!validName(req.Filename) || strings.Contains(req.Filename, "/")) {
+		return renderFailure(w, r, http.StatusBadRequest, "invalid name")
+	}
+
+	h, expected, err := parseChecksum(req.Checksum)
+	if err != nil {
+		return renderFailure(w, r, http.StatusBadRequest, err.Error())
+	}
+
+	dir := path.Clean("/" + r.URL.Path)
+	if !d.Check(dir) {
+		return http.StatusForbidden, nil
+	}
+
+	if info, err := d.user.Fs.Stat(dir); err != nil {
+		return errToStatus(err), err
+	} else if !info.IsDir() {
+		return renderFailure(w, r, http.StatusBadRequest, "the files are fetched into a directory")
+	}
+
+	fetchReq, err := http.NewRequestWithContext(r.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	res, err := fetchClient(d.settings.Fetch.AllowNetworks).Do(fetchReq)
+	if errors.Is(err, errPrivateAddress) {
+		return renderFailure(w, r, http.StatusForbidden, errPrivateAddress.Error())
+	} else if err != nil {
+		return renderFailure(w, r, http.StatusBadGateway, "couldn't fetch the URL: "+err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return renderFailure(w, r, http.StatusBadGateway, "the server answered "+res.Status)
+	}
+
+	maxSize := uploadLimit(d, d.settings.Fetch.MaxSize)
+	if maxSize > 0 && res.ContentLength > maxSize {
+		return renderFailure(w, r, http.StatusRequestEntityTooLarge, errFetchTooLarge.Error())
+	}
+
+	name := req.Filename
+	if name == "" {
+		name = fetchName(res)
+	}
+
+	dst := path.Join(dir, name)
+	if !d.capabilities(dst).CanUpload {
+		return http.StatusForbidden, nil
+	}
+
+	if !uploadAllowed(d, dst) {
+		return renderFileFailure(w, r, http.StatusUnsupportedMediaType, dst, refusedExtension)
+	}
+
+	if !overwriteRequested(r) {
+		if _, err := d.user.Fs.Stat(dst); err == nil {
+			return renderFailure(w, r, http.StatusConflict, "already exists")
+		}
+	}
+
+	release, err := lockPath(d, dst)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer release()
+
+	body, stop := trackProgress(r, d, res.Body, res.ContentLength)
+	defer stop()
+
+	var size int64
+	err = d.RunHook(func() error {
+		size, err = fetchFile(r.Context(), d, body, dir, dst, maxSize, h, expected)
+		return err
+	}, "upload", dst, "", res.ContentLength, d.user)
+
+	switch {
+	case err == errFetchTooLarge:
+		return renderFailure(w, r, http.StatusRequestEntityTooLarge, err.Error())
+	case err == errChecksumMismatch:
+		return renderFailure(w, r, http.StatusUnprocessableEntity, err.Error())
+	case err != nil:
+		return errToStatus(err), err
+	}
+
+	d.notify(settings.EventUpload, dst, "", size)
+
+	return renderListed(w, r, d, dst)
+}
+
+// fetchFile writes body to a temporary file of dir and renames it to dst
+// once it's complete and matches the checksum, if any.
+func fetchFile(ctx context.Context, d *data, body io.Reader, dir, dst string, maxSize int64, h hash.Hash, expected []byte) (int64, error) {
+	// The temporary file is created like the uploaded ones, rather than
+	// with afero.TempFile, so it has their mode.
+	var random [8]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return 0, err
+	}
+
+	name := path.Join(dir, ".fetch-"+hex.EncodeToString(random[:]))
+	tmp, err := d.user.Fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0775)
+	if err != nil {
+		return 0, err
+	}
+
+	done := false
+	defer func() {
+		if !done {
+			tmp.Close()
+			d.user.Fs.Remove(name)
+		}
+	}()
+
+	var w io.Writer = tmp
+	if h != nil {
+		w = io.MultiWriter(tmp, h)
+	}
+
+	if maxSize > 0 {
+		// One more byte tells the bodies that are too long.
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	n, err := io.Copy(w, body)
+	d.metrics.uploaded.Add(float64(n), d.scope())
+	if err != nil {
+		return 0, err
+	}
+
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+
+	if maxSize > 0 && n > maxSize {
+		return 0, errFetchTooLarge
+	}
+
+	if h != nil && !bytes.Equal(h.Sum(nil), expected) {
+		return 0, errChecksumMismatch
+	}
+
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	done = true
+	if err := d.user.Fs.Rename(name, dst); err != nil {
+		d.user.Fs.Remove(name)
+		return 0, err
+	}
+
+	return n, nil
+}
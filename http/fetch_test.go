@@ -0,0 +1,147 @@
+package http
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"127.255.255.254", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"10.0.0.1", true},
+		{"10.255.255.255", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"172.15.255.255", false},
+		{"192.168.1.1", true},
+		{"::ffff:192.168.1.1", true},
+		{"192.169.0.1", false},
+		{"100.64.0.1", true},
+		{"100.127.255.255", true},
+		{"100.128.0.1", false},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fc00::1", true},
+		{"fd12:3456::1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"::", true},
+		{"224.0.0.1", true},
+		{"ff02::1", true},
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2001:4860:4860::8888", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := privateIP(net.ParseIP(tt.ip)); got != tt.private {
+				t.Errorf("privateIP(%s) = %v, want %v", tt.ip, got, tt.private)
+			}
+		})
+	}
+}
+
+func TestFetchClient(t *testing.T) {
+	// /redirect/N redirects N times before answering, and /to?url=...
+	// redirects to the URL.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/redirect/"):
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+			if n > 0 {
+				http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), http.StatusFound)
+				return
+			}
+		case r.URL.Path == "/to":
+			http.Redirect(w, r, r.URL.Query().Get("url"), http.StatusFound)
+			return
+		}
+
+		w.Write([]byte("fetched"))
+	}))
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := func(target string) string {
+		return srv.URL + "/to?url=" + url.QueryEscape(target)
+	}
+	loopback := []string{"127.0.0.1"}
+
+	tests := []struct {
+		name    string
+		target  string
+		allow   []string
+		private bool   // the fetch is refused for the address
+		err     string // in the other failures
+	}{
+		{name: "loopback", target: srv.URL, private: true},
+		{name: "loopback allowed by network", target: srv.URL, allow: []string{"127.0.0.0/8"}},
+		{name: "loopback allowed by address", target: srv.URL, allow: loopback},
+		{name: "other network allowed", target: srv.URL, allow: []string{"10.0.0.0/8", "192.168.1.1"}, private: true},
+		{name: "IPv6 loopback", target: "http://[::1]:" + port, allow: loopback, private: true},
+		{name: "name of the loopback", target: "http://localhost:" + port, private: true},
+		{name: "unspecified", target: "http://0.0.0.0:" + port, private: true},
+		{name: "RFC 1918", target: "http://10.1.2.3/", private: true},
+		{name: "RFC 1918 172", target: "http://172.20.0.1/", private: true},
+		{name: "RFC 1918 192", target: "http://192.168.0.1/", private: true},
+		{name: "link-local", target: "http://169.254.169.254/latest/meta-data/", private: true},
+		{name: "IPv6 link-local", target: "http://[fe80::1]/", private: true},
+		{name: "IPv6 unique local", target: "http://[fd00::1]/", private: true},
+		{name: "redirect to the link-local", target: to("http://169.254.169.254/latest/meta-data/"), allow: loopback, private: true},
+		{name: "redirect to a private network", target: to("http://10.0.0.1/"), allow: loopback, private: true},
+		{name: "redirect to the IPv6 loopback", target: to("http://[::1]:" + port + "/"), allow: loopback, private: true},
+		{name: "redirect to another scheme", target: to("ftp://example.com/file"), allow: loopback, err: "redirected to a ftp URL"},
+		{name: "redirects up to the cap", target: srv.URL + "/redirect/" + strconv.Itoa(maxFetchRedirects), allow: loopback},
+		{name: "redirects over the cap", target: srv.URL + "/redirect/" + strconv.Itoa(maxFetchRedirects+1), allow: loopback, err: "more than 5 redirects"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := fetchClient(tt.allow).Get(tt.target)
+			if tt.private || tt.err != "" {
+				if err == nil {
+					res.Body.Close()
+					t.Fatalf("fetched %s: %s", tt.target, res.Status)
+				}
+				if tt.private && !errors.Is(err, errPrivateAddress) {
+					t.Errorf("the fetch failed with %v, want %v", err, errPrivateAddress)
+				}
+				if !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("the fetch failed with %v, want %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			body, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != "fetched" {
+				t.Errorf("fetched %q", body)
+			}
+		})
+	}
+}
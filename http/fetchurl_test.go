@@ -0,0 +1,67 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestFetchPrivateAddresses(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.URL.Query().Get("to"); target != "" {
+			http.Redirect(w, r, target, http.StatusFound)
+			return
+		}
+
+		w.Write([]byte("fetched"))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name   string
+		target string
+		allow  []string
+		status int
+	}{
+		{"loopback", origin.URL + "/a.txt", nil, http.StatusForbidden},
+		{"loopback allowed", origin.URL + "/a.txt", []string{"127.0.0.0/8"}, http.StatusOK},
+		{"private network", "http://192.168.0.1/a.txt", []string{"127.0.0.0/8"}, http.StatusForbidden},
+		{"redirect to the link-local", origin.URL + "/a.txt?to=" + url.QueryEscape("http://169.254.169.254/latest/meta-data/"), []string{"127.0.0.0/8"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, nil)
+			updateSettings(t, srv, func(s *settings.Settings) {
+				s.Fetch = settings.Fetch{Enabled: true, AllowNetworks: tt.allow}
+			})
+
+			body, err := json.Marshal(map[string]string{"url": tt.target, "filename": "a.txt"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := do(t, srv, "POST", "/api/resources/?action=fetch", string(body), "Content-Type", "application/json", "Accept", "application/json")
+			if w.Code != tt.status {
+				t.Fatalf("POST = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			content, err := afero.ReadFile(fs, "/a.txt")
+			if tt.status != http.StatusOK {
+				if err == nil {
+					t.Errorf("the refused fetch wrote %q", content)
+				}
+				return
+			}
+			if string(content) != "fetched" {
+				t.Errorf("the file has %q (%v)", content, err)
+			}
+		})
+	}
+}
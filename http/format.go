@@ -0,0 +1,353 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+const (
+	formatJSON = "json"
+	formatText = "text"
+	formatHTML = "html"
+	formatXML  = "xml"
+	formatCSV  = "csv"
+	formatRSS  = "rss"
+)
+
+// cliAgents are the products of the user agents of command line clients.
+var cliAgents = []string{
+	"curl/",
+	"wget/",
+	"httpie/",
+	"powershell/",
+	"windowspowershell/",
+}
+
+// isCLIAgent checks if a user agent belongs to a command line client.
+func isCLIAgent(agent string) bool {
+	for _, product := range strings.Fields(strings.ToLower(agent)) {
+		for _, prefix := range cliAgents {
+			if strings.HasPrefix(product, prefix) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// humanFormats are the formats of the listings meant for people rather
+// than programs. They are the only ones the users with MachineFormats set
+// can get, so the formats added later are denied to them too.
+var humanFormats = map[string]bool{
+	formatHTML: true,
+}
+
+// listingFormat returns the format a listing should be rendered with, or
+// an empty string if the user can't get the one the request asks for.
+// This is where the machine readable formats are denied to the users
+// with MachineFormats set, so every format goes through it.
+func listingFormat(r *http.Request, d *data) string {
+	format := negotiateFormat(r, d.settings.PlainTextCLI)
+	if format == "" || humanFormats[format] || d.user.MachineFormats == "" {
+		return format
+	}
+
+	if d.user.MachineFormats == users.MachineFormatsHTML {
+		return formatHTML
+	}
+
+	return ""
+}
+
+// apiOnly checks if the user can't be served the routes that only serve
+// machine readable formats, such as the search, the tree and WebDAV.
+func apiOnly(d *data) bool {
+	return d.user.MachineFormats != ""
+}
+
+// formatMediaTypes are the media types of the formats, in the order the
+// formats are picked in when the client accepts several of them as much
+// and as specifically.
+var formatMediaTypes = []struct {
+	format    string
+	mediaType string
+}{
+	{formatHTML, "text/html"},
+	{formatJSON, "application/json"},
+	{formatRSS, "application/rss+xml"},
+	{formatXML, "application/xml"},
+	{formatXML, "text/xml"},
+	{formatCSV, "text/csv"},
+	{formatText, "text/plain"},
+}
+
+// mediaRange is one of the media ranges of an Accept header.
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept returns the media ranges of an Accept header. The ones that
+// can't be parsed are skipped.
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+
+	return ranges
+}
+
+// acceptance returns the quality the ranges give to mediaType, from the
+// most specific range that matches it, and how specific that range is:
+// 2 for the media type, 1 for its type with any subtype, 0 for */* and
+// -1 if none matches.
+func acceptance(ranges []mediaRange, mediaType string) (float64, int) {
+	quality, specificity := 0.0, -1
+	main := mediaType[:strings.IndexByte(mediaType, '/')]
+	for _, rng := range ranges {
+		s := -1
+		switch rng.mediaType {
+		case mediaType:
+			s = 2
+		case main + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		}
+
+		if s > specificity {
+			quality, specificity = rng.quality, s
+		}
+	}
+
+	return quality, specificity
+}
+
+// negotiateFormat returns the format the request asks for. The format
+// query parameter wins over the Accept header, whose media ranges are
+// weighed by their q-values and then by how specific they are. If
+// neither of them ask for a specific format, command line clients get
+// plain text when cliText is set, and the others JSON. It returns an
+// empty string only when the client excludes every format.
+func negotiateFormat(r *http.Request, cliText bool) string {
+	switch format := r.URL.Query().Get("format"); format {
+	case formatText, formatJSON, formatHTML, formatXML, formatCSV, formatRSS:
+		return format
+	}
+
+	fallback := formatJSON
+	if cliText && isCLIAgent(r.Header.Get("User-Agent")) {
+		fallback = formatText
+	}
+
+	ranges := parseAccept(r.Header.Get("Accept"))
+	if len(ranges) == 0 {
+		return fallback
+	}
+
+	best, bestQuality, bestSpecificity := "", 0.0, 0
+	excluded := map[string]bool{}
+	for _, candidate := range formatMediaTypes {
+		quality, specificity := acceptance(ranges, candidate.mediaType)
+		if specificity >= 0 && quality == 0 {
+			excluded[candidate.format] = true
+			continue
+		}
+
+		// Only */* matches, which doesn't ask for a format.
+		if specificity <= 0 {
+			continue
+		}
+
+		if quality > bestQuality || quality == bestQuality && specificity > bestSpecificity {
+			best, bestQuality, bestSpecificity = candidate.format, quality, specificity
+		}
+	}
+
+	if best != "" {
+		return best
+	}
+
+	// The client doesn't ask for any of the formats, so it gets the one
+	// it would without the header, or any other it doesn't exclude.
+	if !excluded[fallback] {
+		return fallback
+	}
+
+	for _, candidate := range formatMediaTypes {
+		if !excluded[candidate.format] {
+			return candidate.format
+		}
+	}
+
+	return ""
+}
+
+// negotiatedFormat is listingFormat for the handlers that render the
+// format, which vary on the headers that chose it.
+func negotiatedFormat(w http.ResponseWriter, r *http.Request, d *data) string {
+	if r.URL.Query().Get("format") == "" {
+		w.Header().Add("Vary", "Accept")
+		if d.settings.PlainTextCLI {
+			w.Header().Add("Vary", "User-Agent")
+		}
+	}
+
+	return listingFormat(r, d)
+}
+
+// formatContentTypes are the content types of the formats of the
+// listings.
+var formatContentTypes = map[string]string{
+	formatJSON: "application/json; charset=utf-8",
+	formatText: "text/plain; charset=utf-8",
+	formatHTML: "text/html; charset=utf-8",
+	formatXML:  "application/xml; charset=utf-8",
+	formatCSV:  "text/csv; charset=utf-8",
+	formatRSS:  "application/rss+xml; charset=utf-8",
+}
+
+// setFormatHeaders sets the headers of the listing of file in format
+// that don't depend on its body, which the HEAD requests get without it
+// being rendered.
+func setFormatHeaders(w http.ResponseWriter, format string, file *files.FileInfo) {
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	if format != formatCSV {
+		return
+	}
+
+	name := file.Name
+	if name == "" || name == "/" {
+		name = "listing"
+	}
+	w.Header().Set("Content-Disposition", attachment(name+".csv"))
+}
+
+// renderText writes a listing as plain text, one entry per line, with
+// the human sizes of the files, and of the directories when they are
+// measured, and the dates in loc.
+func renderText(w http.ResponseWriter, file *files.FileInfo, loc *time.Location) (int, error) {
+	setFormatHeaders(w, formatText, file)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for _, item := range file.Items {
+		name, size := textName(item.Name), humanSize(item.Size)
+		if item.IsDir {
+			name += "/"
+			switch {
+			case !file.DirSizes:
+				size = "-"
+			case item.SizeIsEstimate:
+				size = "~" + size
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\t%s\n", item.Mode, size, item.ModTime.In(loc).Format("2006-01-02 15:04"), name)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
+
+// textName returns name as it's written in the plain text listings: as
+// it is, or quoted with the escapes of Go if it has control characters,
+// such as newlines or tabs, which would break the lines and the columns,
+// or starts with a quote, which would be taken for one of those.
+func textName(name string) string {
+	if strings.IndexFunc(name, unicode.IsControl) == -1 && !strings.HasPrefix(name, `"`) {
+		return name
+	}
+
+	return strconv.Quote(name)
+}
+
+// itemURL returns the URL of an item of a listing relative to the
+// listing, escaped, with a trailing slash for the directories. The
+// results of the searches have their own.
+func itemURL(item *files.FileInfo) string {
+	if item.URL != "" {
+		return item.URL
+	}
+
+	link := (&url.URL{Path: item.Name}).String()
+	if item.IsDir {
+		link += "/"
+	}
+
+	return link
+}
+
+// jsonItemsStart is how the JSON listings start, since the items are the
+// first field of their listing.
+const jsonItemsStart = `{"items":[`
+
+// renderJSONListing writes a listing as JSON like renderJSON does, but
+// encodes its items one by one, straight to w, rather than marshaling
+// them all at once, so the JSON of large directories is never held in
+// memory. Everything that can fail the request must be checked before,
+// since the status is sent with the first bytes.
+func renderJSONListing(w http.ResponseWriter, r *http.Request, file *files.FileInfo) (int, error) {
+	listing := *file.Listing
+	listing.Items = []*files.FileInfo{}
+	head := *file
+	head.Listing = &listing
+
+	rest, err := json.Marshal(&head)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if !bytes.HasPrefix(rest, []byte(jsonItemsStart+"]")) {
+		return renderJSON(w, r, file)
+	}
+	rest = rest[len(jsonItemsStart):]
+
+	setFormatHeaders(w, formatJSON, file)
+	bw := bufio.NewWriter(w)
+	bw.WriteString(jsonItemsStart)
+
+	enc := json.NewEncoder(bw)
+	for i, item := range file.Items {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+
+		if err := enc.Encode(item); err != nil {
+			return 0, err
+		}
+	}
+
+	bw.Write(rest)
+	return 0, bw.Flush()
+}
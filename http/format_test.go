@@ -0,0 +1,206 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestListingFormatMachineFormats(t *testing.T) {
+	// Every format with a content type is asked for, with the query
+	// parameter, and every media type with the Accept header, so the
+	// formats added later are checked too.
+	type request struct{ query, accept string }
+	var requests []request
+	for format := range formatContentTypes {
+		requests = append(requests, request{query: "format=" + format})
+	}
+	for _, candidate := range formatMediaTypes {
+		requests = append(requests, request{accept: candidate.mediaType})
+	}
+
+	for _, mode := range []string{"", users.MachineFormatsHTML, users.MachineFormatsReject} {
+		for _, req := range requests {
+			t.Run(mode+" "+req.query+req.accept, func(t *testing.T) {
+				r := httptest.NewRequest("GET", "/api/resources/?"+req.query, nil)
+				r.Header.Set("Accept", req.accept)
+				d := &data{
+					settings: &settings.Settings{},
+					user:     &users.User{MachineFormats: mode},
+				}
+
+				want := negotiateFormat(r, false)
+				switch {
+				case mode == users.MachineFormatsHTML:
+					want = formatHTML
+				case mode == users.MachineFormatsReject && want != formatHTML:
+					want = ""
+				}
+
+				if got := listingFormat(r, d); got != want {
+					t.Errorf("listingFormat() = %q, want %q", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestIsCLIAgent(t *testing.T) {
+	tests := []struct {
+		agent string
+		want  bool
+	}{
+		{"curl/8.4.0", true},
+		{"Wget/1.21.4", true},
+		{"HTTPie/3.2.2", true},
+		{"Mozilla/5.0 (Windows NT; Windows NT 10.0; en-US) WindowsPowerShell/5.1.19041.3803", true},
+		{"PowerShell/7.4.0", true},
+		{"CURL/7.0", true},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", false},
+		{"curling/1.0", false},
+		{"libcurl", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agent, func(t *testing.T) {
+			if got := isCLIAgent(tt.agent); got != tt.want {
+				t.Errorf("isCLIAgent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateFormatCLI(t *testing.T) {
+	tests := []struct {
+		name    string
+		agent   string
+		accept  string
+		query   string
+		cliText bool
+		want    string
+	}{
+		{"curl", "curl/8.4.0", "*/*", "", true, formatText},
+		{"curl without the setting", "curl/8.4.0", "*/*", "", false, formatJSON},
+		{"curl asking for HTML", "curl/8.4.0", "text/html", "", true, formatHTML},
+		{"curl asking for JSON", "curl/8.4.0", "application/json", "", true, formatJSON},
+		{"curl with the format", "curl/8.4.0", "*/*", "format=html", true, formatHTML},
+		{"curl excluding text", "curl/8.4.0", "*/*, text/plain;q=0", "", true, formatHTML},
+		{"browser", "Mozilla/5.0 Firefox/121.0", "*/*", "", true, formatJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/resources/?"+tt.query, nil)
+			r.Header.Set("User-Agent", tt.agent)
+			r.Header.Set("Accept", tt.accept)
+			if got := negotiateFormat(r, tt.cliText); got != tt.want {
+				t.Errorf("negotiateFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		query  string
+		want   string
+	}{
+		{"Firefox", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8", "", formatHTML},
+		{"Chrome", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7", "", formatHTML},
+		{"Safari", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "", formatHTML},
+		{"HTML before weak JSON", "text/html, application/json;q=0.1", "", formatHTML},
+		{"JSON before weak HTML", "text/html;q=0.1, application/json", "", formatJSON},
+		{"fetch", "*/*", "", formatJSON},
+		{"axios", "application/json, text/plain, */*", "", formatJSON},
+		{"JSON patch", "application/json-patch+json, text/html;q=0.5", "", formatHTML},
+		{"JSON with parameters", "application/json; charset=utf-8", "", formatJSON},
+		{"feed reader", "application/rss+xml, application/xml;q=0.9, */*;q=0.1", "", formatRSS},
+		{"XML", "text/xml", "", formatXML},
+		{"spreadsheet", "text/csv;q=0.9, text/plain;q=0.5", "", formatCSV},
+		{"any text", "text/*", "", formatHTML},
+		{"any text but HTML", "text/*, text/html;q=0", "", formatXML},
+		{"equal qualities", "text/plain, text/html", "", formatHTML},
+		{"more specific wins", "text/*;q=0.5, text/plain;q=0.5", "", formatText},
+		{"case", "TEXT/HTML", "", formatHTML},
+		{"invalid q", "text/html;q=2, text/plain", "", formatText},
+		{"garbage", ";;;, text/plain", "", formatText},
+		{"empty", "", "", formatJSON},
+		{"image", "image/png", "", formatJSON},
+		{"JSON excluded", "application/json;q=0", "", formatHTML},
+		{"everything excluded", "*/*;q=0", "", ""},
+		{"format wins", "text/html", "format=csv", formatCSV},
+		{"unknown format", "text/html", "format=yaml", formatHTML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/resources/?"+tt.query, nil)
+			r.Header.Set("Accept", tt.accept)
+			if got := negotiateFormat(r, false); got != tt.want {
+				t.Errorf("negotiateFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// discardResponse is a http.ResponseWriter that throws the body away, so
+// the benchmarks only measure what the handlers hold.
+type discardResponse struct{ header http.Header }
+
+func (w *discardResponse) Header() http.Header         { return w.header }
+func (w *discardResponse) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponse) WriteHeader(int)             {}
+
+func BenchmarkRenderJSONListing(b *testing.B) {
+	const entries = 100000
+
+	modTime := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	listing := &files.Listing{Items: make([]*files.FileInfo, entries), NumFiles: entries}
+	for i := range listing.Items {
+		name := fmt.Sprintf("file-%06d.txt", i)
+		listing.Items[i] = &files.FileInfo{
+			Path:      "/big/" + name,
+			Name:      name,
+			Size:      int64(i),
+			Extension: ".txt",
+			ModTime:   modTime,
+			ModUnix:   modTime.Unix(),
+			Mode:      0644,
+			OctalMode: "0644",
+			Type:      "text",
+		}
+	}
+	dir := &files.FileInfo{Listing: listing, Path: "/big/", Name: "big", IsDir: true, Mode: os.ModeDir | 0755}
+
+	renders := []struct {
+		name   string
+		render func(w http.ResponseWriter, r *http.Request, file *files.FileInfo) (int, error)
+	}{
+		{"streamed", renderJSONListing},
+		{"marshaled", func(w http.ResponseWriter, r *http.Request, file *files.FileInfo) (int, error) {
+			return renderJSON(w, r, file)
+		}},
+	}
+
+	for _, render := range renders {
+		b.Run(render.name, func(b *testing.B) {
+			r := httptest.NewRequest("GET", "/api/resources/big/", nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := render.render(&discardResponse{header: http.Header{}}, r, dir); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// listingFuncs are the functions available to the listing templates.
+var listingFuncs = template.FuncMap{
+	"humanSize":     humanSize,
+	"humanDuration": humanDuration,
+	"iconFor":       iconFor,
+	"sortURL":       sortURL,
+	"pathJoinURL":   pathJoinURL,
+	"urlPath":       urlPath,
+	"hasPrefix":     strings.HasPrefix,
+	"hasSuffix":     strings.HasSuffix,
+	"asset":         assetURL,
+}
+
+// humanSize formats a size in bytes with binary units.
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// humanDuration describes how long ago t was, such as "3 hours ago".
+func humanDuration(t time.Time) string {
+	d := time.Since(t)
+	suffix := "ago"
+	if d < 0 {
+		d = -d
+		suffix = "from now"
+	}
+
+	const day = 24 * time.Hour
+
+	var n int64
+	var unit string
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int64(d/time.Minute), "minute"
+	case d < day:
+		n, unit = int64(d/time.Hour), "hour"
+	case d < 30*day:
+		n, unit = int64(d/day), "day"
+	case d < 365*day:
+		n, unit = int64(d/(30*day)), "month"
+	default:
+		n, unit = int64(d/(365*day)), "year"
+	}
+
+	if n != 1 {
+		unit += "s"
+	}
+
+	return fmt.Sprintf("%d %s %s", n, unit, suffix)
+}
+
+// userVar returns the variable with key, or def if it is missing.
+func userVar(variables map[string]string, key, def string) string {
+	if value, ok := variables[key]; ok {
+		return value
+	}
+
+	return def
+}
+
+// categoryIcons are the icons of the file categories.
+var categoryIcons = map[string]string{
+	files.CategoryFolder:   "📁",
+	files.CategoryImage:    "🖼️",
+	files.CategoryVideo:    "🎞️",
+	files.CategoryAudio:    "🎵",
+	files.CategoryArchive:  "📦",
+	files.CategoryDocument: "📕",
+	files.CategoryCode:     "📜",
+	files.CategoryText:     "📝",
+}
+
+// iconFor returns the icon of a file.
+func iconFor(file fileView) string {
+	if file.Special != "" {
+		return "🔌"
+	}
+
+	if icon, ok := categoryIcons[file.Category]; ok {
+		return icon
+	}
+
+	if file.IsDir {
+		return categoryIcons[files.CategoryFolder]
+	}
+
+	return "📄"
+}
+
+// sortURL returns the URL of the listing sorted by key.
+func sortURL(page *listingPage, key string) string {
+	return page.SortLink(key)
+}
+
+// pathJoinURL joins the parts of a path and escapes it to be used in a
+// URL. A trailing slash in the last part is kept.
+func pathJoinURL(parts ...string) string {
+	p := path.Join(parts...)
+	if len(parts) > 0 && strings.HasSuffix(parts[len(parts)-1], "/") && p != "/" {
+		p += "/"
+	}
+
+	return urlPath(p)
+}
+
+// urlPath escapes a path to be used in a URL.
+func urlPath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+func TestSortURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		sorting files.Sorting
+		key     string
+		want    string
+	}{
+		{"name", "", files.Sorting{By: "size"}, "name", "?order=asc&sort=name"},
+		{"size", "", files.Sorting{By: "name"}, "size", "?order=desc&sort=size"},
+		{"flipped", "", files.Sorting{By: "name", Asc: true}, "name", "?order=desc&sort=name"},
+		{"kept", "limit=10&showhidden=true&format=html", files.Sorting{}, "name", "?format=html&limit=10&order=asc&showhidden=true&sort=name"},
+		{"token", "auth=a.b.c&limit=10", files.Sorting{}, "name", "?limit=10&order=asc&sort=name"},
+		{"others", "offset=20&search=x&checksum=sha256&sort=size&order=asc", files.Sorting{}, "name", "?order=asc&sort=name"},
+		{"filter", "filter=*.log&filterdirs=true&offset=20", files.Sorting{}, "name", "?filter=%2A.log&filterdirs=true&order=asc&sort=name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			page := &listingPage{query: query}
+			page.Sorting = tt.sorting
+			if got := sortURL(page, tt.key); got != tt.want {
+				t.Errorf("sortURL(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormParams(t *testing.T) {
+	query := url.Values{"auth": {"a.b.c"}, "lang": {"fr"}}
+	if got := formParams(query); len(got) != 1 || got["lang"] != "fr" {
+		t.Errorf("formParams() = %v, want only lang", got)
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		query   string
+		dir     string
+		want    []crumb
+	}{
+		{"root", "", "", "/", []crumb{
+			{"Home", "/api/resources/"},
+		}},
+		{"empty", "", "", "", []crumb{
+			{"Home", "/api/resources/"},
+		}},
+		{"nested", "", "", "/a/b", []crumb{
+			{"Home", "/api/resources/"},
+			{"a", "/api/resources/a/"},
+			{"b", "/api/resources/a/b/"},
+		}},
+		{"trailing slash", "", "", "/a/b/", []crumb{
+			{"Home", "/api/resources/"},
+			{"a", "/api/resources/a/"},
+			{"b", "/api/resources/a/b/"},
+		}},
+		{"special characters", "", "", "/my docs/50%/a#b/what?", []crumb{
+			{"Home", "/api/resources/"},
+			{"my docs", "/api/resources/my%20docs/"},
+			{"50%", "/api/resources/my%20docs/50%25/"},
+			{"a#b", "/api/resources/my%20docs/50%25/a%23b/"},
+			{"what?", "/api/resources/my%20docs/50%25/a%23b/what%3F/"},
+		}},
+		{"colons and spaces", "", "", "/C: drive/12:30 notes", []crumb{
+			{"Home", "/api/resources/"},
+			{"C: drive", "/api/resources/C:%20drive/"},
+			{"12:30 notes", "/api/resources/C:%20drive/12:30%20notes/"},
+		}},
+		// The names are decoded already, so an escaped slash is a part
+		// of a name and is escaped again.
+		{"escaped slash", "", "", "/a%2Fb/c", []crumb{
+			{"Home", "/api/resources/"},
+			{"a%2Fb", "/api/resources/a%252Fb/"},
+			{"c", "/api/resources/a%252Fb/c/"},
+		}},
+		{"double slashes", "", "", "//a//b", []crumb{
+			{"Home", "/api/resources/"},
+			{"a", "/api/resources/a/"},
+			{"b", "/api/resources/a/b/"},
+		}},
+		{"unicode", "", "", "/café/日本", []crumb{
+			{"Home", "/api/resources/"},
+			{"café", "/api/resources/caf%C3%A9/"},
+			{"日本", "/api/resources/caf%C3%A9/%E6%97%A5%E6%9C%AC/"},
+		}},
+		{"base URL and query", "/fb", "?lang=fr", "/a", []crumb{
+			{"Home", "/fb/api/resources/?lang=fr"},
+			{"a", "/fb/api/resources/a/?lang=fr"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := breadcrumbs(tt.baseURL, tt.query, tt.dir, "Home")
+			if len(got) != len(tt.want) {
+				t.Fatalf("breadcrumbs() = %v, want %v", got, tt.want)
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("crumb %d is %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUserVar(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables map[string]string
+		key       string
+		want      string
+	}{
+		{"set", map[string]string{"team": "ops"}, "team", "ops"},
+		{"empty", map[string]string{"team": ""}, "team", ""},
+		{"missing", map[string]string{"team": "ops"}, "office", "remote"},
+		{"none", nil, "team", "remote"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := userVar(tt.variables, tt.key, "remote"); got != tt.want {
+				t.Errorf("userVar(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/search"
+)
+
+const (
+	// globalSearchLimit is the maximum number of results of the searches
+	// of the landing page, over all its roots.
+	globalSearchLimit = 200
+	// globalSearchTimeout bounds the time the roots are searched for. The
+	// roots that aren't searched by then only have the results found so
+	// far.
+	globalSearchTimeout = 10 * time.Second
+)
+
+// searchGroup is the results of a search of the landing page in one of
+// its roots, whose paths are relative to the root. Truncated is set when
+// the search stopped at the limit, and Error when the root couldn't be
+// searched, or not in time, which doesn't fail the other roots.
+type searchGroup struct {
+	Name      string         `json:"name"`
+	Path      string         `json:"path"`
+	URL       string         `json:"url"`
+	Results   []searchResult `json:"results"`
+	Truncated bool           `json:"truncated,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// rootChecker checks the paths of a root with the rules of the user,
+// and skips the aliases under it, which are searched as roots of their
+// own.
+type rootChecker struct {
+	*data
+	aliases []string
+}
+
+// Skip implements search.Skipper.
+func (c *rootChecker) Skip(p string) bool {
+	for _, alias := range c.aliases {
+		if p == alias {
+			return true
+		}
+	}
+
+	return false
+}
+
+// globalSearch searches for query in all the roots at the same time, up
+// to limit results over all of them.
+func globalSearch(ctx context.Context, d *data, roots []landingRoot, query string, limit int) []searchGroup {
+	ctx, cancel := context.WithTimeout(ctx, globalSearchTimeout)
+	defer cancel()
+
+	var aliases []string
+	for _, alias := range d.user.Aliases {
+		aliases = append(aliases, path.Clean("/"+alias.Path))
+	}
+
+	var (
+		wg     sync.WaitGroup
+		total  int64
+		groups = make([]searchGroup, len(roots))
+	)
+
+	for i, root := range roots {
+		root, group := root, &groups[i]
+		*group = searchGroup{Name: root.Name, Path: root.Path, URL: root.URL, Results: []searchResult{}}
+
+		checker := &rootChecker{data: d}
+		if root.Path == "/" {
+			checker.aliases = aliases
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// Each search has its own group, so they aren't locked.
+			err := search.SearchContext(ctx, d.user.Fs, root.Path, query, checker, func(p string, f os.FileInfo) error {
+				if atomic.AddInt64(&total, 1) > int64(limit) {
+					return errSearchLimit
+				}
+
+				group.Results = append(group.Results, searchResult{Dir: f.IsDir(), Path: strings.TrimPrefix(p, "/")})
+				return nil
+			})
+
+			switch {
+			case err == nil:
+			case errors.Is(err, errSearchLimit):
+				group.Truncated = true
+			case errors.Is(err, context.DeadlineExceeded):
+				group.Error = "the search didn't finish in time"
+			default:
+				// The errors are reported by their status so the full
+				// paths of the files on the server are not leaked.
+				group.Error = http.StatusText(errToStatus(err))
+			}
+		}()
+	}
+
+	wg.Wait()
+	return groups
+}
@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestHead(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt":   {Content: "hello"},
+		"/docs/b.md":    {Content: "# B"},
+		"/docs/sub/c":   {Content: "c"},
+		"/docs/sub/d/e": {Content: "e"},
+		"/docs/secret":  {Content: "s"},
+	})
+	updateUser(t, srv, func(u *users.User) {
+		u.Rules = []rules.Rule{{Path: "/docs/secret"}}
+	})
+
+	// The listings aren't rendered for the HEAD requests, so they have no
+	// length. Their errors happen before, so they are the same as GET's.
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		code   int
+		length bool
+	}{
+		{"HTML listing", "/api/resources/docs/", "text/html", http.StatusOK, false},
+		{"JSON listing", "/api/resources/docs/", "application/json", http.StatusOK, false},
+		{"text listing", "/api/resources/docs/?format=text", "", http.StatusOK, false},
+		{"CSV listing", "/api/resources/docs/?format=csv", "", http.StatusOK, false},
+		{"sorted listing", "/api/resources/docs/?sort=size&order=desc&limit=2", "text/html", http.StatusOK, false},
+		{"HTML bad sort", "/api/resources/docs/?sort=bogus", "text/html", http.StatusBadRequest, true},
+		{"JSON bad sort", "/api/resources/docs/?sort=bogus", "application/json", http.StatusBadRequest, true},
+		{"bad filter", "/api/resources/docs/?filter=[", "text/html", http.StatusBadRequest, true},
+		{"hidden by a rule", "/api/resources/docs/secret", "application/json", http.StatusNotFound, true},
+		{"HTML missing", "/api/resources/docs/missing/", "text/html", http.StatusNotFound, true},
+		{"file", "/api/resources/docs/a.txt", "application/json", http.StatusOK, true},
+		{"download", "/api/raw/docs/a.txt", "", http.StatusOK, true},
+		{"asset", "/static/listing.js", "", http.StatusOK, true},
+		{"missing", "/api/resources/docs/missing", "application/json", http.StatusNotFound, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get := do(t, srv, "GET", tt.target, "", "Accept", tt.accept)
+			head := do(t, srv, "HEAD", tt.target, "", "Accept", tt.accept)
+
+			if head.Code != get.Code {
+				t.Errorf("HEAD = %d, GET = %d", head.Code, get.Code)
+			}
+			if get.Code != tt.code {
+				t.Errorf("GET = %d, want %d", get.Code, tt.code)
+			}
+
+			if head.Body.Len() != 0 {
+				t.Errorf("HEAD has a body of %d bytes", head.Body.Len())
+			}
+
+			for _, key := range []string{"Content-Type", "ETag", "Last-Modified", "Cache-Control", "Vary"} {
+				if got, want := head.Header().Get(key), get.Header().Get(key); got != want {
+					t.Errorf("HEAD has %s %q, GET has %q", key, got, want)
+				}
+			}
+
+			want := ""
+			if tt.length {
+				want = strconv.Itoa(get.Body.Len())
+			}
+			if got := head.Header().Get("Content-Length"); got != want {
+				t.Errorf("HEAD has Content-Length %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/logging"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// EventContext is an operation on the files that the hooks of WithHooks
+// are called around. Event is one of the events of the settings, such as
+// settings.EventUpload, which the edits are too. Path and Destination are
+// in the scope of the user, while FullPath and FullDestination are where
+// they are on the disk. The destination is only set for the renames and
+// the copies. Request is the request the operation was asked with.
+type EventContext struct {
+	Event           string
+	Path            string
+	FullPath        string
+	Destination     string
+	FullDestination string
+	User            *users.User
+	Request         *http.Request
+}
+
+// Hooks are called around every operation on the files made through the
+// handler, by the API and by WebDAV.
+//
+// Before is called on the goroutine of the request, before the before
+// hooks of the commands and before anything is changed. When it returns
+// an error, nothing is changed nor run, and the API refuses the operation
+// with 403 and the message of the error. It is called once for every file
+// of the bulk operations.
+//
+// After is called once the operation succeeded and the after hooks of the
+// commands ran, never for the failed or refused ones. It's called on its
+// own goroutine, so the After of the concurrent operations can run in any
+// order and at the same time. Close waits for them.
+type Hooks struct {
+	Before func(ctx EventContext) error
+	After  func(ctx EventContext)
+}
+
+// WithHooks makes the handler call hooks around the operations on the
+// files. Without it, only the hooks of the commands of the settings are
+// run.
+func WithHooks(hooks Hooks) Option {
+	return func(h *Handler) {
+		h.hooks.Hooks = hooks
+	}
+}
+
+// hookDispatch calls the hooks of WithHooks and keeps track of the After
+// ones that are running.
+type hookDispatch struct {
+	Hooks
+	running sync.WaitGroup
+}
+
+// vetoError is the error a Before hook refused an operation with.
+type vetoError struct {
+	err error
+}
+
+func (e *vetoError) Error() string {
+	return e.err.Error()
+}
+
+func isVetoError(err error) bool {
+	_, ok := err.(*vetoError)
+	return ok
+}
+
+func (h *hookDispatch) before(ctx EventContext) error {
+	if h.Before == nil {
+		return nil
+	}
+
+	if err := h.Before(ctx); err != nil {
+		return &vetoError{err: err}
+	}
+
+	return nil
+}
+
+func (h *hookDispatch) after(logger logging.Logger, ctx EventContext) {
+	if h.After == nil {
+		return
+	}
+
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		defer func() {
+			if rec := recover(); rec != nil {
+				logger.Error("the after hook panicked", "event", ctx.Event, "path", ctx.Path, "panic", rec, "stack", string(debug.Stack()))
+			}
+		}()
+
+		h.After(ctx)
+	}()
+}
+
+// eventContext returns the context of the hooks of the operation evt of
+// user on path.
+func (d *data) eventContext(evt, path, dst string, user *users.User) EventContext {
+	ctx := EventContext{
+		Event:    evt,
+		Path:     path,
+		FullPath: user.FullPath(path),
+		User:     user,
+		Request:  d.request,
+	}
+
+	if dst != "" {
+		ctx.Destination = dst
+		ctx.FullDestination = user.FullPath(dst)
+	}
+
+	return ctx
+}
+
+// RunHook runs the operation fn between the hooks of WithHooks and the
+// ones of the commands of the settings. It shadows the one of the runner,
+// so all the handlers that change the files go through it.
+func (d *data) RunHook(fn func() error, evt, path, dst string, size int64, user *users.User) error {
+	ctx := d.eventContext(evt, path, dst, user)
+	if err := d.hooks.before(ctx); err != nil {
+		return err
+	}
+
+	if err := d.Runner.RunHook(fn, evt, path, dst, size, user); err != nil {
+		return err
+	}
+
+	d.hooks.after(d.logger, ctx)
+	return nil
+}
+
+// runDavHook is RunHook for WebDAV, whose operations don't run the hooks
+// of the commands.
+func (d *data) runDavHook(fn func() error, evt, path, dst string) error {
+	if err := d.davBefore(evt, path, dst); err != nil {
+		return err
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	d.hooks.after(d.logger, d.eventContext(evt, path, dst, d.user))
+	return nil
+}
+
+// davBefore calls the Before hook for a WebDAV operation. Its refusals
+// are logged, since the clients only get the status WebDAV gives to the
+// permission errors.
+func (d *data) davBefore(evt, path, dst string) error {
+	if err := d.hooks.before(d.eventContext(evt, path, dst, d.user)); err != nil {
+		d.logger.Info("webdav operation refused by a hook", "event", evt, "path", path, "error", err)
+		return &os.PathError{Op: evt, Path: path, Err: os.ErrPermission}
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// hookOperations are the operations on the files the hooks are called
+// around, with the status they get when Before refuses them and the
+// context the hooks get. WebDAV answers the refusals with the statuses
+// it gives to the permission errors of each method.
+var hookOperations = []struct {
+	name    string
+	method  string
+	target  string
+	body    string
+	headers []string
+	refused int
+	want    fbhttp.EventContext
+}{
+	{"upload", "POST", "/api/resources/docs/new.txt", "new", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventUpload, Path: "/docs/new.txt"}},
+	{"overwrite", "POST", "/api/resources/docs/a.txt?override=true", "changed", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventUpload, Path: "/docs/a.txt"}},
+	{"edit", "PUT", "/api/resources/docs/a.txt", "changed", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventUpload, Path: "/docs/a.txt"}},
+	{"mkdir", "POST", "/api/resources/docs/new/", "", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventMkdir, Path: "/docs/new/"}},
+	{"delete", "DELETE", "/api/resources/docs/a.txt", "", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventDelete, Path: "/docs/a.txt"}},
+	{"purge", "DELETE", "/api/resources/docs/a.txt?purge=true", "", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventDelete, Path: "/docs/a.txt"}},
+	{"rename", "PATCH", "/api/resources/docs/a.txt?action=rename&destination=/docs/b.txt", "", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventRename, Path: "/docs/a.txt", Destination: "/docs/b.txt"}},
+	{"copy", "PATCH", "/api/resources/docs/a.txt?action=copy&destination=/docs/b.txt", "", nil, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventCopy, Path: "/docs/a.txt", Destination: "/docs/b.txt"}},
+	{"webdav upload", "PUT", "/dav/docs/new.txt", "new", []string{"X-Auth", "none"}, http.StatusNotFound, fbhttp.EventContext{Event: settings.EventUpload, Path: "/docs/new.txt"}},
+	{"webdav delete", "DELETE", "/dav/docs/a.txt", "", []string{"X-Auth", "none"}, http.StatusMethodNotAllowed, fbhttp.EventContext{Event: settings.EventDelete, Path: "/docs/a.txt"}},
+	{"webdav mkdir", "MKCOL", "/dav/docs/new", "", []string{"X-Auth", "none"}, http.StatusMethodNotAllowed, fbhttp.EventContext{Event: settings.EventMkdir, Path: "/docs/new"}},
+	{"webdav move", "MOVE", "/dav/docs/a.txt", "", []string{"X-Auth", "none", "Destination", "http://example.com/dav/docs/b.txt"}, http.StatusForbidden, fbhttp.EventContext{Event: settings.EventRename, Path: "/docs/a.txt", Destination: "/docs/b.txt"}},
+}
+
+// newHookServer returns a server whose handler calls hooks.
+func newHookServer(t *testing.T, hooks fbhttp.Hooks) (*filebrowsertest.Server, *filebrowsertest.FS, *fbhttp.Handler) {
+	t.Helper()
+
+	srv, fs := newServer(t, map[string]filebrowsertest.File{"/docs/a.txt": {Content: "a"}})
+	handler, err := fbhttp.NewHandler(srv.Storage, &settings.Server{Root: "/"}, fbhttp.WithHooks(hooks), fbhttp.WithAssets(filebrowsertest.Assets()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Handler = handler
+
+	return srv, fs, handler
+}
+
+func TestHooksVeto(t *testing.T) {
+	for _, tt := range hookOperations {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []fbhttp.EventContext
+			srv, fs, handler := newHookServer(t, fbhttp.Hooks{
+				Before: func(ctx fbhttp.EventContext) error {
+					got = append(got, ctx)
+					return errors.New("frozen by the release")
+				},
+				After: func(ctx fbhttp.EventContext) {
+					t.Errorf("After was called for the refused %s of %s", ctx.Event, ctx.Path)
+				},
+			})
+			before := snapshot(t, fs)
+
+			headers := append([]string{"X-CSRF-Token", csrfToken(t, srv)}, tt.headers...)
+			w := do(t, srv, tt.method, tt.target, tt.body, headers...)
+			if w.Code != tt.refused {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.target, w.Code, tt.refused, w.Body)
+			}
+			if !strings.HasPrefix(tt.target, "/dav/") && !strings.Contains(w.Body.String(), "frozen by the release") {
+				t.Errorf("the response doesn't have the message of the hook: %s", w.Body)
+			}
+			if after := snapshot(t, fs); after != before {
+				t.Errorf("the files changed:\n%s\nwant:\n%s", after, before)
+			}
+
+			if len(got) != 1 {
+				t.Fatalf("Before was called %d times, want once", len(got))
+			}
+			checkEventContext(t, got[0], tt.want)
+
+			if err := handler.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestHooksAfter(t *testing.T) {
+	for _, tt := range hookOperations {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				before []fbhttp.EventContext
+				after  []fbhttp.EventContext
+			)
+			srv, fs, handler := newHookServer(t, fbhttp.Hooks{
+				Before: func(ctx fbhttp.EventContext) error {
+					mu.Lock()
+					defer mu.Unlock()
+					before = append(before, ctx)
+					return nil
+				},
+				After: func(ctx fbhttp.EventContext) {
+					// The hook is slow, so Close has to wait for it.
+					time.Sleep(10 * time.Millisecond)
+					mu.Lock()
+					defer mu.Unlock()
+					after = append(after, ctx)
+				},
+			})
+			start := snapshot(t, fs)
+
+			headers := append([]string{"X-CSRF-Token", csrfToken(t, srv)}, tt.headers...)
+			w := do(t, srv, tt.method, tt.target, tt.body, headers...)
+			if w.Code >= 300 {
+				t.Fatalf("%s %s = %d: %s", tt.method, tt.target, w.Code, w.Body)
+			}
+			if snapshot(t, fs) == start {
+				t.Error("the files didn't change")
+			}
+
+			if err := handler.Close(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(before) != 1 || len(after) != 1 {
+				t.Fatalf("the hooks were called %d and %d times, want once", len(before), len(after))
+			}
+			checkEventContext(t, before[0], tt.want)
+			checkEventContext(t, after[0], tt.want)
+		})
+	}
+}
+
+// checkEventContext checks the event and the paths of got.
+func checkEventContext(t *testing.T, got, want fbhttp.EventContext) {
+	t.Helper()
+
+	if got.Event != want.Event || got.Path != want.Path || got.Destination != want.Destination {
+		t.Errorf("the hook got %s of %q to %q, want %s of %q to %q", got.Event, got.Path, got.Destination, want.Event, want.Path, want.Destination)
+	}
+	if got.FullPath == "" || got.User == nil || got.Request == nil {
+		t.Errorf("the hook got %+v, want the full path, the user and the request", got)
+	}
+	if (got.FullDestination != "") != (want.Destination != "") {
+		t.Errorf("the hook got the full destination %q, want one: %v", got.FullDestination, want.Destination != "")
+	}
+}
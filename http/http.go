@@ -2,9 +2,16 @@ package http
 
 import (
 	"net/http"
+	"time"
 
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/logging"
+	"github.com/filebrowser/filebrowser/v2/metrics"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/tracing"
+	"github.com/filebrowser/filebrowser/v2/webhooks"
 	"github.com/gorilla/mux"
 )
 
@@ -13,11 +20,103 @@ type modifyRequest struct {
 	Which []string `json:"which"` // Answer to: which fields?
 }
 
-func NewHandler(storage *storage.Storage, server *settings.Server) (http.Handler, error) {
+// Option changes the Handler made by NewHandler.
+type Option func(*Handler)
+
+// WithLogger makes the handler log the requests, the listings and the
+// errors through logger. Without it, the handler doesn't log them.
+func WithLogger(logger logging.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithMetrics makes the handler register its metrics on reg, which can't
+// be given to another handler. Without it, they are kept by the handler,
+// which serves them at the metrics path of the server settings.
+func WithMetrics(reg metrics.Registerer) Option {
+	return func(h *Handler) {
+		h.registerer = reg
+	}
+}
+
+// WithTracer makes the handler trace the requests that carry a trace
+// context with tracer: the user and scope lookup, and the reading,
+// enrichment, sorting and rendering of the listings. Without it, or for
+// the other requests, nothing is traced.
+func WithTracer(tracer tracing.Tracer) Option {
+	return func(h *Handler) {
+		h.tracer = tracer
+	}
+}
+
+// WithAssets makes the handler serve the frontend from box, with its
+// index.html and its static files. Without it, the frontend is the one of
+// frontend/dist, embedded in the binary by rice.
+func WithAssets(box *rice.Box) Option {
+	return func(h *Handler) {
+		h.assets = box
+	}
+}
+
+// NewHandler returns the http.Handler of File Browser, which serves the
+// frontend, the API and WebDAV under server.BaseURL. It only depends on
+// net/http, so it can be mounted in any server.
+func NewHandler(storage *storage.Storage, server *settings.Server, opts ...Option) (*Handler, error) {
 	server.Clean()
 
+	h := &Handler{
+		storage:    storage,
+		logger:     logging.Nop,
+		registerer: metrics.NewRegistry(),
+		started:    time.Now(),
+		slow:       &slowOps{},
+		hooks:      &hookDispatch{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if _, err := loadTranslations(); err != nil {
+		h.logger.Warn("couldn't load the translations", "error", err)
+	}
+
+	h.metrics = newHandlerMetrics(h.registerer)
+	h.events = &events.Bus{}
+	h.webhooks = webhooks.NewQueue(h.logger)
+	h.webhooks.Listen(h.events, h.webhookSettings)
+	h.archives = newArchiveJobs(h.logger)
+	if err := h.archives.open(server.ArchiveSpool); err != nil {
+		return nil, err
+	}
+	h.current.Store(&handlerState{
+		server:  server,
+		handler: newRouter(h, server),
+	})
+
+	return h, nil
+}
+
+// webhookSettings returns the webhooks of the settings in the database.
+func (h *Handler) webhookSettings() []settings.Webhook {
+	set, err := h.storage.Settings.Get()
+	if err != nil {
+		h.logger.Error("couldn't get the webhooks", "error", err)
+		return nil
+	}
+
+	return set.Webhooks
+}
+
+func newRouter(h *Handler, server *settings.Server) http.Handler {
+	return stripPrefix(server.BaseURL, routes(h, server))
+}
+
+// routes returns the router of the routes of server, without its base
+// URL.
+func routes(h *Handler, server *settings.Server) *mux.Router {
 	r := mux.NewRouter()
-	index, static := getStaticHandlers(storage, server)
+	index, static := getStaticHandlers(h, server)
 
 	// NOTE: This fixes the issue where it would redirect if people did not put a
 	// trailing slash in the end. I hate this decision since this allows some awful
@@ -25,17 +124,36 @@ func NewHandler(storage *storage.Storage, server *settings.Server) (http.Handler
 	r = r.SkipClean(true)
 
 	monkey := func(fn handleFunc, prefix string) http.Handler {
-		return handle(fn, prefix, storage, server)
+		return handle(fn, prefix, h, server)
+	}
+
+	if server.LandingPath != "" {
+		r.Handle(server.LandingPath, monkey(landingHandler, "")).Methods("GET", "HEAD")
+	}
+
+	if server.MetricsPath != "" {
+		r.Handle(server.MetricsPath, monkey(metricsHandler, "")).Methods("GET")
 	}
 
-	r.PathPrefix("/static").Handler(static)
+	if server.StatusPath != "" {
+		r.Handle(server.StatusPath, monkey(statusHandler(h), "")).Methods("GET")
+	}
+
+	r.PathPrefix(server.StaticPath + "/").Handler(static)
+	r.Handle("/robots.txt", monkey(robotsHandler, "")).Methods("GET")
+	r.PathPrefix("/dav").Handler(monkey(webdavHandler, ""))
 	r.NotFoundHandler = index
 
 	api := r.PathPrefix("/api").Subrouter()
+	api.Use(corsMiddleware(server))
+
+	api.Handle("/openapi.json", monkey(newOpenAPI(server.BaseURL).handler(), "")).Methods("GET")
 
 	api.Handle("/login", monkey(loginHandler, ""))
 	api.Handle("/signup", monkey(signupHandler, ""))
 	api.Handle("/renew", monkey(renewHandler, ""))
+	api.Handle("/theme", monkey(themeHandler, "")).Methods("GET")
+	api.Handle("/translations", monkey(translationsHandler, "")).Methods("GET")
 
 	users := api.PathPrefix("/users").Subrouter()
 	users.Handle("", monkey(usersGetHandler, "")).Methods("GET")
@@ -44,11 +162,17 @@ func NewHandler(storage *storage.Storage, server *settings.Server) (http.Handler
 	users.Handle("/{id:[0-9]+}", monkey(userGetHandler, "")).Methods("GET")
 	users.Handle("/{id:[0-9]+}", monkey(userDeleteHandler, "")).Methods("DELETE")
 
-	api.PathPrefix("/resources").Handler(monkey(resourceGetHandler, "/api/resources")).Methods("GET")
+	api.PathPrefix("/resources").Handler(monkey(resourceGetHandler, "/api/resources")).Methods("GET", "HEAD")
 	api.PathPrefix("/resources").Handler(monkey(resourceDeleteHandler, "/api/resources")).Methods("DELETE")
 	api.PathPrefix("/resources").Handler(monkey(resourcePostPutHandler, "/api/resources")).Methods("POST")
 	api.PathPrefix("/resources").Handler(monkey(resourcePostPutHandler, "/api/resources")).Methods("PUT")
 	api.PathPrefix("/resources").Handler(monkey(resourcePatchHandler, "/api/resources")).Methods("PATCH")
+	api.PathPrefix("/resources").Handler(monkey(mkcolHandler, "/api/resources")).Methods("MKCOL")
+	api.PathPrefix("/resources").Handler(monkey(resourceOptionsHandler, "/api/resources")).Methods("OPTIONS")
+
+	api.Handle("/bulk", monkey(bulkHandler, "")).Methods("POST")
+	api.PathPrefix("/rename").Handler(monkey(renameHandler, "/api/rename")).Methods("POST")
+	api.PathPrefix("/uploads").Handler(monkey(uploadProgressHandler, "/api/uploads/")).Methods("GET")
 
 	api.PathPrefix("/share").Handler(monkey(shareGetsHandler, "/api/share")).Methods("GET")
 	api.PathPrefix("/share").Handler(monkey(sharePostHandler, "/api/share")).Methods("POST")
@@ -57,13 +181,25 @@ func NewHandler(storage *storage.Storage, server *settings.Server) (http.Handler
 	api.Handle("/settings", monkey(settingsGetHandler, "")).Methods("GET")
 	api.Handle("/settings", monkey(settingsPutHandler, "")).Methods("PUT")
 
-	api.PathPrefix("/raw").Handler(monkey(rawHandler, "/api/raw")).Methods("GET")
+	api.PathPrefix("/raw").Handler(monkey(rawHandler, "/api/raw")).Methods("GET", "HEAD")
+	api.PathPrefix("/raw").Handler(monkey(archiveJobHandler, "/api/raw")).Methods("POST")
+	api.Handle("/archives", monkey(archivesGetHandler, "")).Methods("GET")
+	api.Handle("/archives/{id:[0-9a-f]+}", monkey(archiveGetHandler, "")).Methods("GET")
+	api.Handle("/archives/{id:[0-9a-f]+}", monkey(archiveDeleteHandler, "")).Methods("DELETE")
+	api.Handle("/archives/{id:[0-9a-f]+}/file", monkey(archiveFileHandler, "")).Methods("GET", "HEAD")
 	api.PathPrefix("/command").Handler(monkey(commandsHandler, "/api/command")).Methods("GET")
+	api.PathPrefix("/live").Handler(monkey(liveHandler, "/api/live")).Methods("GET")
 	api.PathPrefix("/search").Handler(monkey(searchHandler, "/api/search")).Methods("GET")
+	api.PathPrefix("/tags").Handler(monkey(tagsGetHandler, "/api/tags")).Methods("GET")
+	api.PathPrefix("/tags").Handler(monkey(tagsPutHandler, "/api/tags")).Methods("PUT")
+	api.PathPrefix("/favorites").Handler(monkey(favoritesGetHandler, "/api/favorites")).Methods("GET")
+	api.PathPrefix("/favorites").Handler(monkey(favoritesPostHandler, "/api/favorites")).Methods("POST")
+	api.PathPrefix("/favorites").Handler(monkey(favoritesDeleteHandler, "/api/favorites")).Methods("DELETE")
 
 	public := api.PathPrefix("/public").Subrouter()
-	public.PathPrefix("/dl").Handler(monkey(publicDlHandler, "/api/public/dl/")).Methods("GET")
+	public.PathPrefix("/dl").Handler(monkey(publicDlHandler, "/api/public/dl/")).Methods("GET", "HEAD")
 	public.PathPrefix("/share").Handler(monkey(publicShareHandler, "/api/public/share/")).Methods("GET")
+	public.PathPrefix("/qr").Handler(monkey(publicQRHandler, "/api/public/qr/")).Methods("GET")
 
-	return stripPrefix(server.BaseURL, r), nil
+	return r
 }
@@ -0,0 +1,272 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/resize"
+)
+
+// resizedMaxAge is how long, in seconds, the browsers keep the resized
+// images before they check them again with their ETag.
+const resizedMaxAge = 30 * 24 * 60 * 60
+
+// resizeSlots limits the images resized at once, since each one is
+// decoded whole in memory.
+var resizeSlots = make(chan struct{}, runtime.NumCPU())
+
+// thumbExtensions are the extensions of the images that have thumbnails.
+var thumbExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// wantsResize checks if the request asks for a resized image or for a
+// thumbnail.
+func wantsResize(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Get("w") != "" || q.Get("h") != "" || q.Get("fit") != "" || q.Get("fmt") != "" || q.Get("thumb") != ""
+}
+
+// resizeOptions returns the options of the resized image the request
+// asks for, which must be allowed by the settings. The thumbnails, which
+// the thumb query parameter asks for, are JPEG images that fit in a
+// square box of its size.
+func resizeOptions(r *http.Request, d *data) (resize.Options, error) {
+	q := r.URL.Query()
+	images := d.settings.Images
+	if value := q.Get("thumb"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || !images.AllowsThumb(size) {
+			return resize.Options{}, fmt.Errorf("the images have no thumbnails of %s", value)
+		}
+
+		return resize.Options{Width: size, Height: size, Fit: resize.FitInside, Format: resize.FormatJPEG}, nil
+	}
+
+	if len(images.Sizes) == 0 {
+		return resize.Options{}, fmt.Errorf("image resizing is off")
+	}
+
+	opts := resize.Options{Fit: q.Get("fit"), Format: q.Get("fmt")}
+	for _, side := range []struct {
+		name string
+		size *int
+	}{{"w", &opts.Width}, {"h", &opts.Height}} {
+		value := q.Get(side.name)
+		if value == "" {
+			continue
+		}
+
+		size, err := strconv.Atoi(value)
+		if err != nil || !images.AllowsSize(size) {
+			return opts, fmt.Errorf("the images can't be resized to a %s of %s", side.name, value)
+		}
+		*side.size = size
+	}
+
+	if opts.Width == 0 && opts.Height == 0 {
+		return opts, resize.ErrNoBox
+	}
+
+	switch opts.Fit {
+	case "":
+		opts.Fit = resize.FitInside
+	case resize.FitInside, resize.FitCover:
+	default:
+		return opts, fmt.Errorf("invalid fit %q: it must be %s or %s", opts.Fit, resize.FitInside, resize.FitCover)
+	}
+
+	if opts.Format != "" && !images.AllowsFormat(opts.Format) {
+		return opts, fmt.Errorf("the images can't be resized to %q", opts.Format)
+	}
+
+	return opts, nil
+}
+
+// resizedKey returns the key of the resized image of file with opts,
+// which changes with the file.
+func resizedKey(d *data, file *files.FileInfo, opts resize.Options) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%d\x00%d\x00%d\x00%d\x00%s\x00%s",
+		d.user.ID, file.Path, file.ModTime.UnixNano(), file.Size, opts.Width, opts.Height, opts.Fit, opts.Format)))
+	return hex.EncodeToString(sum[:])
+}
+
+// resizedFileHandler serves the image of file resized as the query
+// parameters w, h, fit and fmt, or thumb, say. The resized images are
+// kept in the image cache of the server settings, if any.
+func resizedFileHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	opts, err := resizeOptions(r, d)
+	if err != nil {
+		return renderFailure(w, r, http.StatusBadRequest, err.Error())
+	}
+
+	// The format is part of the key, so it's set before it's made.
+	ext := strings.ToLower(filepath.Ext(file.Name))
+	if opts.Format == "" {
+		opts.Format = resize.FormatPNG
+		if ext == ".jpg" || ext == ".jpeg" {
+			opts.Format = resize.FormatJPEG
+		}
+	}
+
+	key := resizedKey(d, file, opts)
+	etag := `"` + key[:32] + `"`
+	name := strings.TrimSuffix(file.Name, filepath.Ext(file.Name)) + "." + opts.Format
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(resizedMaxAge))
+	w.Header().Set("Content-Type", "image/"+opts.Format)
+	if r.URL.Query().Get("inline") == "true" {
+		w.Header().Set("Content-Disposition", "inline")
+	} else {
+		w.Header().Set("Content-Disposition", attachment(name))
+	}
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return 0, nil
+	}
+
+	var cached string
+	if dir := d.server.ImageCache; dir != "" {
+		cached = filepath.Join(dir, key[:2], key+"."+opts.Format)
+		if fd, err := os.Open(cached); err == nil {
+			defer fd.Close()
+			http.ServeContent(w, r, name, file.ModTime, fd)
+			return 0, nil
+		}
+	}
+
+	buf, err := resizeOnce(d, key, file, opts, cached)
+	switch err {
+	case nil:
+	case image.ErrFormat:
+		return renderFailure(w, r, http.StatusUnsupportedMediaType, "the file isn't an image that can be resized")
+	case resize.ErrTooBig:
+		return renderFailure(w, r, http.StatusBadRequest, err.Error())
+	default:
+		return errToStatus(err), err
+	}
+
+	http.ServeContent(w, r, name, file.ModTime, bytes.NewReader(buf.Bytes()))
+	return 0, nil
+}
+
+// resizing is a resize in progress, whose result the requests for the
+// same image wait for.
+type resizing struct {
+	done chan struct{}
+	buf  *bytes.Buffer
+	err  error
+}
+
+var (
+	resizingMu sync.Mutex
+	resizings  = map[string]*resizing{}
+)
+
+// resizeOnce resizes the image of file with opts and keeps it in cached,
+// if it's set. The requests for the image of key that come while it's
+// resized wait for it and get the same one, so the images that aren't
+// cached yet are only decoded once. The failures aren't cached.
+func resizeOnce(d *data, key string, file *files.FileInfo, opts resize.Options, cached string) (*bytes.Buffer, error) {
+	resizingMu.Lock()
+	if run, ok := resizings[key]; ok {
+		resizingMu.Unlock()
+		<-run.done
+		return run.buf, run.err
+	}
+
+	run := &resizing{done: make(chan struct{})}
+	resizings[key] = run
+	resizingMu.Unlock()
+
+	defer func() {
+		resizingMu.Lock()
+		delete(resizings, key)
+		resizingMu.Unlock()
+		close(run.done)
+	}()
+
+	resizeSlots <- struct{}{}
+	run.buf, run.err = resizeFile(file, opts)
+	<-resizeSlots
+
+	if run.err == nil && cached != "" {
+		if err := writeCached(cached, run.buf.Bytes()); err != nil {
+			d.logger.Warn("couldn't keep the resized image", "path", file.Path, "error", err)
+		}
+	}
+
+	return run.buf, run.err
+}
+
+// annotateThumbnails sets the URLs of the thumbnails of the JPEG, PNG
+// and GIF images of the listing, in the first size of the settings, for
+// the users who can download them.
+func annotateThumbnails(r *http.Request, d *data, listing *files.Listing) {
+	thumbs := d.settings.Images.Thumbs
+	if len(thumbs) == 0 || !d.user.Perm.Download {
+		return
+	}
+
+	query := "?thumb=" + strconv.Itoa(thumbs[0])
+	for _, item := range listing.Items {
+		if item.IsDir || item.Error || item.Special != "" || !thumbExtensions[strings.ToLower(item.Extension)] {
+			continue
+		}
+
+		item.Thumbnail = d.baseURL(r) + pathJoinURL("/api/raw", item.Path) + query
+	}
+}
+
+// resizeFile resizes the image of file with opts.
+func resizeFile(file *files.FileInfo, opts resize.Options) (*bytes.Buffer, error) {
+	fd, err := file.Fs.Open(file.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var buf bytes.Buffer
+	if err := resize.Resize(&buf, fd, opts); err != nil {
+		return nil, err
+	}
+
+	return &buf, nil
+}
+
+// writeCached writes a resized image to the cache. It's written to a
+// temporary file first, so the requests never see a partial image.
+func writeCached(name string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".resize-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
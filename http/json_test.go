@@ -0,0 +1,55 @@
+package http_test
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestJSONGolden checks the JSON of the files and the listings byte for
+// byte, so their shapes don't change by accident. The golden files are
+// written again with -update.
+func TestJSONGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		target string
+	}{
+		{"listing.json", "/api/resources/docs/"},
+		{"listing-limited.json", "/api/resources/docs/?sort=size&order=desc&limit=2&offset=1"},
+		{"search.json", "/api/resources/docs/?search=txt"},
+		{"file.json", "/api/resources/docs/a.txt"},
+	}
+
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt":     {Content: "hello"},
+		"/docs/b.md":      {Content: "# B\n"},
+		"/docs/c.bin":     {Content: "\x00\x01\x02"},
+		"/docs/sub/d.txt": {Content: "d"},
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			w := do(t, srv, "GET", tt.target, "", "Accept", "application/json")
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := ioutil.WriteFile(golden, w.Body.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(w.Body.Bytes(), want) {
+				t.Errorf("GET %s =\n%s\nwant:\n%s", tt.target, w.Body, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"strconv"
+	"text/tabwriter"
+)
+
+const landingTemplate = `<!DOCTYPE html>
+<html lang="{{ .Locale }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<link rel="icon" href="{{ .Favicon }}">
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/light.css" }}">
+{{- if eq .Theme "dark" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}">
+{{- else if eq .Theme "" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}" media="(prefers-color-scheme: dark)">
+{{- end }}
+{{- range .Styles }}
+<link rel="stylesheet" href="{{ . }}">
+{{- end }}
+</head>
+<body class="theme-{{ or .Theme "auto" }}">
+<h1>{{ .Title }}</h1>
+{{- if .Roots }}
+<form id="search" method="get" role="search">
+{{- range $key, $value := .Params }}
+<input type="hidden" name="{{ $key }}" value="{{ $value }}">
+{{- end }}
+<input type="search" name="search" value="{{ .Search }}" placeholder="{{ $.T "search" }}" aria-label="{{ $.T "search" }}">
+<button type="submit">{{ $.T "search" }}</button>
+{{- if .Search }} <a href="{{ or $.Query "?" }}">{{ $.T "clear" }}</a>{{ end }}
+</form>
+{{- end }}
+{{- if .Search }}
+{{- range .Groups }}
+<section class="search-group">
+<h2><a href="{{ .URL }}">{{ .Name }}</a> <small>{{ .Path }}</small></h2>
+{{- with .Error }}
+<p class="error">{{ . }}</p>
+{{- end }}
+<ul>
+{{- $group := . }}
+{{- range .Results }}
+<li><a href="{{ $.ResultURL $group.Path . }}">{{ .Path }}{{ if .Dir }}/{{ end }}</a></li>
+{{- else }}
+{{- if not $group.Error }}
+<li>{{ $.T "noResults" }}</li>
+{{- end }}
+{{- end }}
+</ul>
+{{- if .Truncated }}
+<p>{{ $.T "searchTruncated" }}</p>
+{{- end }}
+</section>
+{{- end }}
+{{- else }}
+<ul>
+{{- range .Roots }}
+<li><a href="{{ .URL }}">{{ .Name }}</a> <small>{{ .Path }}</small></li>
+{{- else }}
+<li>{{ $.T "noRoots" }}</li>
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`
+
+var landingPage = template.Must(template.New("landing").Funcs(listingFuncs).Parse(landingTemplate))
+
+// landingRoot is one of the directories the landing page lists.
+type landingRoot struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	URL  string `json:"url"`
+}
+
+// landing is the data the landing template is executed with. With
+// Search, Groups are the results of the search in each of the Roots.
+type landing struct {
+	Title     string
+	Favicon   string
+	BaseURL   string
+	StaticURL string
+	Theme     string
+	Locale    string
+	Styles    []string
+	Roots     []landingRoot
+	Query     string
+	Params    map[string]string
+	Search    string
+	Groups    []searchGroup
+
+	pageMessages
+}
+
+// ResultURL returns the URL of a result of the search in the root at
+// root: the listing of the directories and the download of the files.
+func (p *landing) ResultURL(root string, result searchResult) string {
+	if result.Dir {
+		return p.BaseURL + pathJoinURL("/api/resources", root, result.Path, "/") + p.Query
+	}
+
+	return p.BaseURL + pathJoinURL("/api/raw", root, result.Path) + p.Query
+}
+
+// landingRoots returns the roots the user can browse: the root of the
+// scope and its aliases, unless they are private, the rules deny them or
+// they can't be listed.
+func landingRoots(r *http.Request, d *data, home string) []landingRoot {
+	query := ""
+	if q := keptQuery(r); len(q) > 0 {
+		query = "?" + q.Encode()
+	}
+
+	candidates := []landingRoot{{Name: home, Path: "/"}}
+	for _, alias := range d.user.Aliases {
+		if !alias.Private {
+			p := path.Clean("/" + alias.Path)
+			candidates = append(candidates, landingRoot{Name: path.Base(p), Path: p})
+		}
+	}
+
+	roots := []landingRoot{}
+	for _, root := range candidates {
+		if !d.Check(root.Path) {
+			continue
+		}
+
+		if info, err := d.user.Fs.Stat(root.Path); err != nil || !info.IsDir() {
+			continue
+		}
+
+		root.URL = d.baseURL(r) + pathJoinURL("/api/resources", root.Path, "/") + query
+		roots = append(roots, root)
+	}
+
+	return roots
+}
+
+// landingHandler lists the roots the user can browse, as HTML, JSON or
+// plain text like the listings. With the search query parameter, it
+// searches all of them instead, and the results are grouped by root.
+var landingHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	locale := detectLocale(r, d.user.Locale)
+	msgs := messages(locale)
+	roots := landingRoots(r, d, translate(msgs, "home"))
+
+	query := r.URL.Query().Get("search")
+	var groups []searchGroup
+	if query != "" {
+		groups = globalSearch(r.Context(), d, roots, query, globalSearchLimit)
+	}
+
+	switch negotiatedFormat(w, r, d) {
+	case "":
+		return http.StatusNotAcceptable, nil
+	case formatJSON:
+		if query != "" {
+			return renderJSON(w, r, groups)
+		}
+		return renderJSON(w, r, roots)
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, group := range groups {
+			for _, result := range group.Results {
+				fmt.Fprintf(tw, "%s\t%s\n", group.Name, path.Join(group.Path, result.Path))
+			}
+		}
+
+		if query == "" {
+			for _, root := range roots {
+				fmt.Fprintf(tw, "%s\t%s\n", root.Name, root.Path)
+			}
+		}
+
+		if err := tw.Flush(); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return 0, nil
+	}
+
+	baseURL := d.baseURL(r)
+	page := &landing{
+		Title:        listingTitle(d),
+		Favicon:      faviconURL(d, baseURL),
+		BaseURL:      baseURL,
+		StaticURL:    d.staticURL(baseURL),
+		Theme:        activeTheme(r, d.settings.Branding.Theme),
+		Locale:       locale,
+		Styles:       brandingAssetURLs(d, baseURL, d.settings.Branding.Styles),
+		Roots:        roots,
+		Search:       query,
+		Groups:       groups,
+		pageMessages: msgs,
+	}
+
+	kept := keptQuery(r)
+	page.Params = formParams(kept)
+	if len(kept) > 0 {
+		page.Query = "?" + kept.Encode()
+	}
+
+	var buf bytes.Buffer
+	if err := landingPage.Execute(&buf, page); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := buf.WriteTo(w); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+})
@@ -0,0 +1,1124 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/gitstatus"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// dirTemplateName is the name of the file that replaces the listing
+// template for the directory it is in. Like the default one, it's an
+// html/template, so what it writes is escaped for where it's written,
+// whether or not it calls html.
+const dirTemplateName = ".template.html"
+
+// maxDirTemplateSize is the maximum size of a directory template.
+const maxDirTemplateSize = 1 << 20
+
+const defaultListingTemplate = `
+{{- define "arrow" }}{{ if eq . "asc" }} ↑{{ else if eq . "desc" }} ↓{{ end }}{{ end -}}
+{{- define "link" }}{{ if .IsSymlink }} <span class="link-target{{ if .BrokenLink }} broken{{ end }}">→ {{ or .LinkTarget "?" }}</span>{{ end }}{{ end -}}
+{{- define "git" }}{{ with .GitStatus }} <span class="git-status git-{{ . }}">{{ . }}</span>{{ end }}{{ end -}}
+{{- define "rename" }}{{ if .Capabilities.CanRename }} <button type="button" class="rename" title="{{ $.T "rename" }}">✏️</button>{{ end }}{{ end -}}
+<!DOCTYPE html>
+<html lang="{{ .Locale }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<link rel="icon" href="{{ .Favicon }}">
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/light.css" }}">
+{{- if eq .Theme "dark" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}">
+{{- else if eq .Theme "" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}" media="(prefers-color-scheme: dark)">
+{{- end }}
+{{- range .Styles }}
+<link rel="stylesheet" href="{{ . }}">
+{{- end }}
+{{- with .Branding.CSS }}
+<style>{{ . }}</style>
+{{- end }}
+</head>
+<body class="theme-{{ or .Theme "auto" }}"{{ with .Branding.Color }} style="--accent: {{ . }}"{{ end }}>
+{{- if or .Branding.Name .Branding.Logo }}
+<header id="branding">
+{{- with .Branding.Logo }}<img src="{{ . }}" alt="">{{ end }}
+{{- with .Branding.Name }}<span>{{ . }}</span>{{ end -}}
+</header>
+{{- end }}
+<h1>
+{{- range $i, $crumb := .Breadcrumbs }}{{ if $i }} / {{ end }}<a href="{{ $crumb.URL }}">{{ $crumb.Name }}</a>{{ end -}}
+</h1>
+{{- with .Description }}
+<p id="description">{{ . }}</p>
+{{- end }}
+<form id="search" method="get" role="search"{{ if $.ServerSearch }} data-server-search{{ end }}>
+{{- range $key, $value := .Params }}
+<input type="hidden" name="{{ $key }}" value="{{ $value }}">
+{{- end }}
+<input type="search" name="search" value="{{ .Search }}" placeholder="{{ $.T "search" }}" aria-label="{{ $.T "search" }}">
+<button type="submit">{{ $.T "search" }}</button>
+<a href="{{ or $.Query "?" }}" id="clear-search"{{ if not .Search }} hidden{{ end }}>{{ $.T "clear" }}</a>
+<span id="search-status">{{ if .Truncated }}{{ $.T "searchTruncated" }}{{ end }}</span>
+</form>
+<nav id="favorites" data-path="{{ .Path }}">{{ $.T "favorites" }}
+{{- range .Favorites }}
+{{- if .Missing }} <span class="missing" title="{{ $.T "missingFavorite" }}">{{ .Name }}</span>
+{{- else if .IsDir }} <a href="{{ $.BaseURL }}{{ pathJoinURL "/api/resources" .Path "/" }}{{ $.Query }}">{{ .Name }}/</a>
+{{- else }} <a href="{{ $.BaseURL }}{{ pathJoinURL "/api/raw" .Path }}{{ $.Query }}">{{ .Name }}</a>
+{{- end }} <button type="button" class="unpin" data-path="{{ .Path }}" title="{{ $.T "unpin" }}">✕</button>
+{{- end }}
+{{- if not $.Pinned }} <button type="button" id="pin">{{ $.T "pin" }}</button>{{ end }}</nav>
+<p id="recent">{{ $.T "recent" }}
+{{- range .RecentLinks }} <a href="{{ .URL }}"{{ if eq .Name $.Recent }} aria-current="page"{{ end }}>{{ .Name }}</a>{{ end }}
+{{- with .Recent }} – {{ $.T "recentWindow" . }} <a href="{{ or $.Query "?" }}">{{ $.T "clear" }}</a>{{ end }}</p>
+{{- if .Capabilities.CanMkdir }}
+<p><button type="button" id="new-folder">{{ $.T "newFolder" }}</button>
+{{- if .Fetch }} <button type="button" id="fetch-url">{{ $.T "fetchURL" }}</button>{{ end }}<span class="error" id="new-folder-error"></span></p>
+{{- end }}
+{{- if .Selectable }}
+<div id="actions" hidden>
+{{- if .Capabilities.CanDelete }}
+<button type="button" data-action="delete">{{ $.T "delete" }}</button>
+{{- end }}
+{{- if .Capabilities.CanRename }}
+<button type="button" data-action="move">{{ $.T "moveTo" }}</button>
+{{- end }}
+{{- if .Capabilities.CanDownloadArchive }}
+<button type="button" data-action="download">{{ $.T "downloadZip" }}</button>
+{{- end }}
+<span id="summary"></span>
+</div>
+{{- end }}
+{{- with .Index }}
+<section id="index">
+{{ . }}
+</section>
+{{- else }}{{ with .Readme }}
+<section id="readme">
+{{ . }}
+</section>
+{{- end }}{{ end }}
+<table id="listing" data-base-url="{{ $.BaseURL }}" data-csrf="{{ $.CSRFToken }}" data-path="{{ .Path }}" data-icons="{{ .Icons }}"{{ if .Capabilities.CanUpload }} data-upload{{ end }}{{ if .Capabilities.CanRename }} data-rename{{ end }}{{ if and .Live (not .Search) (not .Recent) }} data-live{{ end }}>
+<tr>
+{{- if .Selectable }}
+<th><input type="checkbox" id="select-all" title="{{ $.T "selectAll" }}"></th>
+{{- end }}
+<th><a href="{{ $.SortLink "type" }}">{{ $.T "type" }}</a>{{ template "arrow" ($.OrderFor "type") }}</th>
+<th><a href="{{ $.SortLink "name" }}">{{ $.T "name" }}</a>{{ template "arrow" ($.OrderFor "name") }}</th>
+<th><a href="{{ $.SortLink "size" }}">{{ $.T "size" }}</a>{{ template "arrow" ($.OrderFor "size") }}</th>
+<th><a href="{{ $.SortLink "modified" }}">{{ $.T "modified" }}</a>{{ template "arrow" ($.OrderFor "modified") }}</th>
+</tr>
+{{- if ne .Path "/" }}
+<tr>{{ if .Selectable }}<td></td>{{ end }}<td></td><td><a href="../{{ $.Query }}">../</a></td><td></td><td></td></tr>
+{{- end }}
+{{- range $i, $item := .Items }}
+{{- with $.DuplicateGroup $i }}
+<tr class="duplicates"><td colspan="{{ if $.Selectable }}5{{ else }}4{{ end }}">{{ . }}</td></tr>
+{{- end }}
+<tr data-path="{{ .Path }}"{{ with .Category }} data-category="{{ . }}"{{ end }}{{ with .MimeType }} data-mime-type="{{ . }}"{{ end }}{{ if .IsText }} data-text{{ end }}>
+{{- if $.Selectable }}
+<td><input type="checkbox" name="item" value="{{ .Path }}"></td>
+{{- end }}
+<td>{{ with $.ThumbLink . }}<img class="thumb" src="{{ . }}" alt="" loading="lazy">{{ else }}{{ iconFor . }}{{ end }}</td>
+{{- if .Error }}
+<td title="{{ $.T "unreadableItem" }}">{{ .Name }}</td><td></td><td></td>
+{{- else if .Special }}
+<td title="{{ $.T "specialFile" }}">{{ .Name }} <span class="special">{{ .Special }}</span></td><td>-</td>
+<td title="{{ $.Date .ModTime }}">{{ humanDuration .ModTime }}</td>
+{{- else if .IsDir }}
+<td><a href="{{ $.BaseURL }}{{ pathJoinURL "/api/resources" .Path "/" }}{{ $.Query }}">{{ .Name }}/</a>{{ template "link" . }}{{ template "git" . }}{{ template "rename" $ }}</td><td>{{ if $.DirSizes }}{{ if .SizeIsEstimate }}~{{ end }}{{ humanSize .Size }}{{ else }}-{{ end }}</td>
+<td title="{{ $.Date .ModTime }}">{{ humanDuration .ModTime }}</td>
+{{- else }}
+<td><a href="{{ $.BaseURL }}{{ pathJoinURL "/api/raw" .Path }}{{ $.Query }}">{{ .Name }}</a>{{ template "link" . }}{{ template "git" . }}{{ with $.DocSummary . }} <span class="doc-meta">{{ . }}</span>{{ end }}{{ template "rename" $ }}</td><td>{{ humanSize .Size }}</td>
+<td title="{{ $.Date .ModTime }}">{{ humanDuration .ModTime }}</td>
+{{- end }}
+</tr>
+{{- end }}
+</table>
+<footer>
+<p>{{ $.T "summary" .NumDirs .NumFiles }}</p>
+{{- if .NumUnreadable }}
+<p>{{ $.T "unreadable" .NumUnreadable }}</p>
+{{- end }}
+{{- if .NumHidden }}
+<p>{{ $.T "hidden" .NumHidden }}</p>
+{{- end }}
+{{- with $.Wasted }}
+<p>{{ . }}</p>
+{{- end }}
+{{- with $.DiskFree }}
+<p>{{ . }}</p>
+{{- end }}
+{{- with .Branch }}
+<p>{{ $.T "gitBranch" . }}</p>
+{{- end }}
+{{- if or .ItemsLimitedTo .Offset }}
+<p>{{ $.PageSummary }}
+{{- with $.PrevLink }} <a href="{{ . }}" rel="prev">{{ $.T "previousPage" }}</a>{{ end }}
+{{- with $.NextLink }} <a href="{{ . }}" rel="next">{{ $.T "nextPage" }}</a>{{ end }}
+{{- with $.MoreLink }} <a href="{{ . }}">{{ $.MoreLabel }}</a>{{ end }}</p>
+{{- end }}
+<p>{{ $.T "theme" }}
+<a href="{{ $.BaseURL }}/api/theme?theme=light">{{ $.T "themeLight" }}</a>
+<a href="{{ $.BaseURL }}/api/theme?theme=dark">{{ $.T "themeDark" }}</a>
+<a href="{{ $.BaseURL }}/api/theme?theme=auto">{{ $.T "themeAuto" }}</a></p>
+{{- if not .Branding.HideCredits }}
+<p class="credits"><a href="https://filebrowser.org" rel="noopener noreferrer">File Browser</a></p>
+{{- end }}
+</footer>
+<script src="{{ $.StaticURL }}/{{ asset "listing.js" }}"></script>
+{{- range .Scripts }}
+<script src="{{ . }}"></script>
+{{- end }}
+{{- with .Branding.JS }}
+<script>{{ . }}</script>
+{{- end }}
+</body>
+</html>
+`
+
+var defaultListing = template.Must(template.New("listing").Funcs(listingFuncs).Parse(defaultListingTemplate))
+
+// listingPage is the data the listing templates are executed with. The
+// default template, the one of the branding settings and the ones of the
+// directories all get the same page.
+type listingPage struct {
+	dirView
+	BaseURL      string
+	StaticURL    string
+	Query        string            // the query the links keep, with the token
+	Params       map[string]string // the same parameters but the token, for the forms
+	Title        string
+	Description  string
+	Favicon      string
+	Theme        string
+	Locale       string
+	Styles       []string          // the URLs of the stylesheets of the branding
+	Scripts      []string          // the URLs of the scripts of the branding
+	Perm         users.Permissions // for the custom templates; the buttons follow the Capabilities
+	ServerSearch bool              // the user can't use the search API, so the form reloads the page
+	Search       string
+	Truncated    bool
+	Index        template.HTML   // the sanitized index file of the directory, if it's shown
+	Readme       template.HTML   // the sanitized README file, when there is no index
+	Duplicates   bool            // the items are the groups of identical files under the directory
+	Recent       string          // the items are the files modified in this window, such as 7d
+	Fetch        bool            // the uploads by URL are shown
+	ReadOnly     bool            // nothing can be changed on the server
+	Live         bool            // the listing follows the changes of the directory
+	Branding     listingBranding // the branding of the scope of the user
+	Format       string          // the format the listing was negotiated in, for the links to the others
+	CSRFToken    string          // sent with the bulk requests
+
+	query      url.Values
+	location   *time.Location
+	dateFormat string
+	maxLimit   int
+	limit      int
+	variables  map[string]string
+
+	pageMessages
+}
+
+// Var returns the variable of the user with key, or def if the user has
+// no such variable, so templates don't fail on missing variables.
+func (p *listingPage) Var(key, def string) string {
+	return userVar(p.variables, key, def)
+}
+
+// Date formats t with the date format and in the time zone of the
+// listings.
+func (p *listingPage) Date(t time.Time) string {
+	return t.In(p.location).Format(p.dateFormat)
+}
+
+// Pinned checks if the directory of the listing is a favorite of the
+// user.
+func (p *listingPage) Pinned() bool {
+	for _, favorite := range p.Favorites {
+		if favorite.Path == path.Clean(p.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Selectable checks if the user can act on the selected files.
+func (p *listingPage) Selectable() bool {
+	c := &p.Capabilities
+	return c.CanDelete || c.CanRename || c.CanDownloadArchive
+}
+
+// IsSortedBy checks if the listing is sorted by key.
+func (p *listingPage) IsSortedBy(key string) bool {
+	return p.Sorting.By == key
+}
+
+// OrderFor returns the order of the listing if it is sorted by key, or
+// an empty string otherwise.
+func (p *listingPage) OrderFor(key string) string {
+	switch {
+	case !p.IsSortedBy(key):
+		return ""
+	case p.Sorting.Asc:
+		return "asc"
+	default:
+		return "desc"
+	}
+}
+
+// SortLink returns the URL of the listing sorted by key. The order is
+// flipped if the listing is already sorted by key. Otherwise, names and
+// types are sorted in ascending order and sizes and dates in descending
+// order.
+// Only the sortParams of the query are kept.
+func (p *listingPage) SortLink(key string) string {
+	query := url.Values{}
+	for _, k := range sortParams {
+		if value := p.query.Get(k); value != "" {
+			query.Set(k, value)
+		}
+	}
+
+	order := "asc"
+	switch {
+	case p.IsSortedBy(key) && p.Sorting.Asc:
+		order = "desc"
+	case !p.IsSortedBy(key) && key != "name" && key != "type":
+		order = "desc"
+	}
+
+	query.Set("sort", key)
+	query.Set("order", order)
+	return "?" + query.Encode()
+}
+
+// sortParams are the query parameters that the sort links keep, besides
+// the sort and the order they set. The authentication token isn't one of
+// them, so it doesn't spread through the Referer, the history and the
+// links that are shared.
+var sortParams = []string{"limit", "format", "lang", "tz", "showhidden", "dirsfirst", "dirsizes", "filter", "filterdirs"}
+
+// ThumbLink returns the URL of the thumbnail of item, with the token of
+// the request, or an empty string if it has none.
+func (p *listingPage) ThumbLink(item fileView) string {
+	if item.Thumbnail == "" {
+		return ""
+	}
+
+	if auth := p.query.Get("auth"); auth != "" {
+		return item.Thumbnail + "&auth=" + url.QueryEscape(auth)
+	}
+
+	return item.Thumbnail
+}
+
+// PageSummary describes which items of the listing are on the page.
+func (p *listingPage) PageSummary() string {
+	if len(p.Items) == 0 {
+		return p.T("pageEmpty", p.TotalItems)
+	}
+
+	return p.T("pageItems", p.Offset+1, p.Offset+len(p.Items), p.TotalItems)
+}
+
+// PrevLink returns the link to the previous page of the listing, or an
+// empty string if it's the first one.
+func (p *listingPage) PrevLink() string {
+	if prev, _ := pageOffsets(p.Offset, p.TotalItems, p.limit); prev >= 0 {
+		return pageQuery(p.query, prev)
+	}
+
+	return ""
+}
+
+// NextLink returns the link to the next page of the listing, or an empty
+// string if it's the last one.
+func (p *listingPage) NextLink() string {
+	if _, next := pageOffsets(p.Offset, p.TotalItems, p.limit); next >= 0 {
+		return pageQuery(p.query, next)
+	}
+
+	return ""
+}
+
+// MoreLink returns the link to the listing with as many items as the
+// requests can ask for, or an empty string if it can't list more.
+func (p *listingPage) MoreLink() string {
+	if p.maxLimit > 0 && p.maxLimit <= p.ItemsLimitedTo {
+		return ""
+	}
+
+	query := url.Values{}
+	for k, v := range p.query {
+		query[k] = v
+	}
+
+	query.Set("limit", strconv.Itoa(p.maxLimit))
+	query.Del("offset")
+	return "?" + query.Encode()
+}
+
+// MoreLabel returns the text of MoreLink.
+func (p *listingPage) MoreLabel() string {
+	if p.maxLimit == 0 || p.maxLimit >= p.NumDirs+p.NumFiles {
+		return p.T("showAll")
+	}
+
+	return p.T("showFirst", p.maxLimit)
+}
+
+// DiskFree describes the free space of the disk of the scope, or returns
+// an empty string if it isn't known.
+func (p *listingPage) DiskFree() string {
+	if p.Quota == 0 {
+		return ""
+	}
+
+	return p.T("diskFree", humanSize(int64(p.Free)), humanSize(int64(p.Quota)))
+}
+
+// DocSummary describes the metadata of a document of the listing, such
+// as its title, author and number of pages, or returns an empty string
+// if it has none.
+func (p *listingPage) DocSummary(item fileView) string {
+	meta := item.DocMeta
+	if meta == nil {
+		return ""
+	}
+
+	var parts []string
+	if meta.Title != "" {
+		parts = append(parts, meta.Title)
+	}
+	if meta.Author != "" {
+		parts = append(parts, meta.Author)
+	}
+	if meta.Pages > 0 {
+		parts = append(parts, p.T("pages", meta.Pages))
+	}
+
+	return strings.Join(parts, " – ")
+}
+
+// DuplicateGroup describes the group of identical files the item at i
+// starts, or returns an empty string if it isn't the first of a group or
+// the page doesn't show the duplicates.
+func (p *listingPage) DuplicateGroup(i int) string {
+	if !p.Duplicates || i >= len(p.Items) {
+		return ""
+	}
+
+	hash := p.Items[i].Checksums["sha256"]
+	if i > 0 && p.Items[i-1].Checksums["sha256"] == hash {
+		return ""
+	}
+
+	n := 1
+	for _, item := range p.Items[i+1:] {
+		if item.Checksums["sha256"] != hash {
+			break
+		}
+		n++
+	}
+
+	return p.T("duplicateGroup", n, humanSize(p.Items[i].Size))
+}
+
+// Wasted describes the space taken by all the copies but one of the
+// identical files, or returns an empty string if the page doesn't show
+// the duplicates.
+func (p *listingPage) Wasted() string {
+	if !p.Duplicates {
+		return ""
+	}
+
+	var wasted int64
+	for i, item := range p.Items {
+		if i > 0 && p.Items[i-1].Checksums["sha256"] == item.Checksums["sha256"] {
+			wasted += item.Size
+		}
+	}
+
+	return p.T("duplicatesWasted", humanSize(wasted))
+}
+
+// RecentLinks returns the links to the files under the directory modified
+// in the usual windows.
+func (p *listingPage) RecentLinks() []crumb {
+	links := make([]crumb, 0, len(recentWindows))
+	for _, window := range recentWindows {
+		query := url.Values{}
+		for _, key := range keptParams {
+			if value := p.query.Get(key); value != "" {
+				query.Set(key, value)
+			}
+		}
+
+		query.Set("recent", window)
+		links = append(links, crumb{Name: window, URL: "?" + query.Encode()})
+	}
+
+	return links
+}
+
+// Icons returns the icons of the file categories as JSON, for the
+// scripts that add files to the listing. The default icon has an empty
+// category.
+func (p *listingPage) Icons() (string, error) {
+	icons := map[string]string{"": iconFor(fileView{})}
+	for category, icon := range categoryIcons {
+		icons[category] = icon
+	}
+
+	b, err := json.Marshal(icons)
+	return string(b), err
+}
+
+// crumb is a link to one of the directories of a path. Name is the name
+// of the directory as it is on the disk, which the templates escape for
+// where they write it, and URL is its escaped link.
+type crumb struct {
+	Name string
+	URL  string
+}
+
+// Breadcrumbs returns the links to the directories from the root of the
+// scope to the listed one, in that order.
+func (p *listingPage) Breadcrumbs() []crumb {
+	return breadcrumbs(p.BaseURL, p.Query, p.Path, p.T("home"))
+}
+
+// breadcrumbs returns the links to the listings of the directories from
+// the root of the scope, which is named home, to dir, in that order. dir
+// is already decoded, so the names are only split on its slashes and
+// escaped again in the links.
+func breadcrumbs(baseURL, query, dir, home string) []crumb {
+	crumbs := []crumb{{
+		Name: home,
+		URL:  baseURL + "/api/resources/" + query,
+	}}
+
+	p := "/"
+	for _, name := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if name == "" {
+			continue
+		}
+
+		p = path.Join(p, name)
+		crumbs = append(crumbs, crumb{
+			Name: name,
+			URL:  baseURL + pathJoinURL("/api/resources", p, "/") + query,
+		})
+	}
+
+	return crumbs
+}
+
+// keptParams are the query parameters that the links of the HTML pages
+// keep.
+var keptParams = []string{"auth", "format", "lang", "tz", "showhidden", "dirsfirst", "dirsizes"}
+
+// keptQuery returns the query parameters of the request that the links
+// of the HTML pages keep.
+func keptQuery(r *http.Request) url.Values {
+	query := url.Values{}
+	for _, key := range keptParams {
+		if value := r.URL.Query().Get(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	return query
+}
+
+// formParams returns the kept query parameters as the hidden inputs of
+// the forms of the HTML pages, without the authentication token, which
+// the forms would copy into the URLs of their results.
+func formParams(query url.Values) map[string]string {
+	params := map[string]string{}
+	for key := range query {
+		if key != "auth" {
+			params[key] = query.Get(key)
+		}
+	}
+
+	return params
+}
+
+// listingSorting returns the sorting of the listings, which the options
+// file of the directory, and then the sort and order query parameters,
+// override.
+func listingSorting(r *http.Request, d *data, opts *dirOptions) files.Sorting {
+	sorting := d.user.Sorting
+	if opts != nil {
+		if opts.Sort != "" {
+			sorting.By, sorting.Asc = opts.Sort, true
+		}
+		if opts.Order != "" {
+			sorting.Asc = opts.Order == "asc"
+		}
+	}
+
+	if by := r.URL.Query().Get("sort"); by != "" {
+		sorting.By = by
+		sorting.Asc = r.URL.Query().Get("order") != "desc"
+	}
+
+	return sorting
+}
+
+// dirsFirst checks if the directories of the listings are grouped before
+// the files: the dirsfirst query parameter, true or false, overrides the
+// settings for the request.
+func dirsFirst(r *http.Request, d *data) bool {
+	if value := r.URL.Query().Get("dirsfirst"); value != "" {
+		return value == "true"
+	}
+
+	return d.settings.DirsFirst
+}
+
+// sortCollator returns the collator of the sort locale of the settings,
+// which compares the names regardless of case and with their numbers by
+// value, or nil if there's none. The collators can't be shared between
+// the requests, so each sort gets its own.
+func sortCollator(d *data) *collate.Collator {
+	if d.settings.SortLocale == "" {
+		return nil
+	}
+
+	tag, err := language.Parse(d.settings.SortLocale)
+	if err != nil {
+		return nil
+	}
+
+	return collate.New(tag, collate.IgnoreCase, collate.Numeric)
+}
+
+// sortListing sorts the listing with its sorting, and then groups its
+// directories before its files if they are.
+func sortListing(r *http.Request, d *data, listing *files.Listing) {
+	listing.Collator = sortCollator(d)
+	listing.ApplySort()
+	if dirsFirst(r, d) {
+		listing.GroupDirs()
+	}
+}
+
+// listingLimit returns the number of items to list, which the options
+// file of the directory, and then the limit query parameter, override up
+// to the maximum limit. Zero lists them all.
+func listingLimit(r *http.Request, d *data, opts *dirOptions) (int, error) {
+	limit := d.settings.DefaultLimit
+	if opts != nil && opts.Limit != nil {
+		limit = *opts.Limit
+	}
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return 0, errors.ErrInvalidOption
+		}
+		limit = n
+	}
+
+	if maxLimit := d.settings.MaxLimit; maxLimit > 0 && (limit == 0 || limit > maxLimit) {
+		limit = maxLimit
+	}
+
+	return limit, nil
+}
+
+// listingOffset returns the position of the first item to list from the
+// offset query parameter.
+func listingOffset(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("offset")
+	if raw == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, errors.ErrInvalidOption
+	}
+
+	return n, nil
+}
+
+// readLimit returns the number of entries to read from the directory of
+// the listing: with sort=none, the ones of the page and one more, so the
+// next page is linked, since the entries are then in the order of the
+// filesystem. The HTML listings read them all, for their index files.
+// Zero reads them all.
+func readLimit(r *http.Request, d *data) int {
+	if r.URL.Query().Get("sort") != "none" || listingFormat(r, d) == formatHTML {
+		return 0
+	}
+
+	limit, err := listingLimit(r, d, nil)
+	if err != nil || limit == 0 {
+		return 0
+	}
+
+	offset, err := listingOffset(r)
+	if err != nil {
+		return 0
+	}
+
+	return offset + limit + 1
+}
+
+// pageOffsets returns the offsets of the previous and the next pages of
+// a listing of total items at offset with limit items per page, or -1 if
+// there's none.
+func pageOffsets(offset, total, limit int) (prev, next int) {
+	prev, next = -1, -1
+	if offset > 0 {
+		prev = 0
+		if limit > 0 && offset > limit {
+			prev = offset - limit
+		}
+	}
+
+	if limit > 0 && offset+limit < total {
+		next = offset + limit
+	}
+
+	return prev, next
+}
+
+// pageQuery returns query with the offset of another page.
+func pageQuery(query url.Values, offset int) string {
+	page := url.Values{}
+	for k, v := range query {
+		page[k] = v
+	}
+
+	if offset > 0 {
+		page.Set("offset", strconv.Itoa(offset))
+	} else {
+		page.Del("offset")
+	}
+
+	return "?" + page.Encode()
+}
+
+// setPageLinks sets the Link header to the previous and the next pages
+// of the listing of the request.
+func setPageLinks(w http.ResponseWriter, r *http.Request, d *data, listing *files.Listing) {
+	target := d.baseURL(r) + pathJoinURL("/api/resources", r.URL.Path, "/")
+	prev, next := pageOffsets(listing.Offset, listing.TotalItems, d.limit)
+	if next >= 0 {
+		w.Header().Add("Link", "<"+target+pageQuery(r.URL.Query(), next)+`>; rel="next"`)
+	}
+
+	if prev >= 0 {
+		w.Header().Add("Link", "<"+target+pageQuery(r.URL.Query(), prev)+`>; rel="prev"`)
+	}
+}
+
+// listedFile is a file as a row of the listing of its directory.
+type listedFile struct {
+	*files.FileInfo
+	// URL is the URL of the listing of the directory or the contents of
+	// the file.
+	URL string `json:"url"`
+	// Index is the position of the file in the sorted listing of its
+	// directory.
+	Index int `json:"index"`
+}
+
+// newListedFile returns the file at p as a row of the listing of its
+// directory, sorted as requested.
+func newListedFile(r *http.Request, d *data, p string) (*listedFile, error) {
+	p = path.Clean("/" + p)
+
+	// The files that were just made are found even if they are hidden.
+	listing, err := shownListing(r, d, path.Dir(p), strings.HasPrefix(path.Base(p), "."))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range listing.Items {
+		if item.Path == p {
+			return newListedItem(r, d, item, i), nil
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// shownListing returns the listing of dir the way it's shown, sorted as
+// requested and without the files it hides, but for the ones whose names
+// start with a dot when dotfiles is true.
+func shownListing(r *http.Request, d *data, dir string, dotfiles bool) (*files.Listing, error) {
+	file, err := files.NewFileInfo(files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       dir,
+		Modify:     d.user.Perm.Modify,
+		Expand:     true,
+		Checker:    d,
+		Categories: d.settings.Categories,
+		Readlink:   d.readlink(),
+		Logger:     d.logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	file.Listing.Sorting = listingSorting(r, d, listingOptions(d, file.Path))
+	sortListing(r, d, file.Listing)
+
+	if d.settings.DirTemplates {
+		hideListed(file.Listing, dirTemplateName)
+	}
+
+	if d.settings.DirOptions != "" {
+		hideListed(file.Listing, d.settings.DirOptions)
+	}
+
+	hideTrash(d, file.Listing)
+
+	if !dotfiles {
+		hideDotfiles(r, d, file.Listing)
+	}
+
+	return file.Listing, nil
+}
+
+// newListedItem returns the item of a listing at index as a row of it.
+func newListedItem(r *http.Request, d *data, item *files.FileInfo, index int) *listedFile {
+	file := &listedFile{FileInfo: item, Index: index}
+	if item.IsDir {
+		file.URL = d.baseURL(r) + pathJoinURL("/api/resources", item.Path, "/")
+	} else {
+		file.URL = d.baseURL(r) + pathJoinURL("/api/raw", item.Path)
+	}
+
+	return file
+}
+
+// dirTemplates caches the directory templates by their full path.
+var dirTemplates = &templateCache{}
+
+// dirTemplate returns the template of the directory dir. It returns nil
+// if the directory has no template.
+func dirTemplate(d *data, dir string) (*template.Template, error) {
+	name := path.Join(dir, dirTemplateName)
+	if !d.Check(name) {
+		return nil, nil
+	}
+
+	info, err := d.user.Fs.Stat(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil, errors.ErrSpecialFile
+	}
+
+	if info.Size() > maxDirTemplateSize {
+		return nil, errors.ErrTooLarge
+	}
+
+	tpl, err := dirTemplates.get(d, d.user.FullPath(name), info.ModTime(), func() (interface{}, error) {
+		text, err := afero.ReadFile(d.user.Fs, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(text) > maxDirTemplateSize {
+			return nil, errors.ErrTooLarge
+		}
+
+		return template.New(dirTemplateName).Funcs(listingFuncs).Parse(string(text))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tpl.(*template.Template), nil
+}
+
+// hideListed removes the file named name, such as the directory
+// template, from the listing.
+func hideListed(listing *files.Listing, name string) {
+	for i, item := range listing.Items {
+		if item.Name == name && !item.IsDir {
+			listing.Items = append(listing.Items[:i], listing.Items[i+1:]...)
+			listing.NumFiles--
+			return
+		}
+	}
+}
+
+// showHidden checks if the files whose names start with a dot are
+// listed: the showhidden query parameter, true or false, overrides the
+// settings for the request.
+func showHidden(r *http.Request, d *data) bool {
+	if value := r.URL.Query().Get("showhidden"); value != "" {
+		return value == "true"
+	}
+
+	return d.settings.ShowHidden
+}
+
+// hideDotfiles removes the files whose names start with a dot from the
+// listing, unless they are shown, and counts them.
+func hideDotfiles(r *http.Request, d *data, listing *files.Listing) {
+	if showHidden(r, d) {
+		return
+	}
+
+	listing.HiddenSuppressed = true
+	items := listing.Items[:0]
+	for _, item := range listing.Items {
+		if strings.HasPrefix(item.Name, ".") {
+			switch {
+			case item.Error:
+				listing.NumUnreadable--
+			case item.IsDir:
+				listing.NumDirs--
+			default:
+				listing.NumFiles--
+			}
+			listing.NumHidden++
+			continue
+		}
+
+		items = append(items, item)
+	}
+	listing.Items = items
+}
+
+// filterListing keeps the files of the listing whose names match the
+// glob of the filter query parameter, with the syntax of path.Match, and
+// counts only them. The directories are kept whatever their names, so
+// the listing can still be browsed, unless filterdirs is true. The
+// patterns that can't be matched fail with path.ErrBadPattern.
+func filterListing(r *http.Request, listing *files.Listing) error {
+	pattern := r.URL.Query().Get("filter")
+	if pattern == "" {
+		return nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	dirs := r.URL.Query().Get("filterdirs") == "true"
+	items := listing.Items[:0]
+	for _, item := range listing.Items {
+		if item.IsDir && !dirs {
+			items = append(items, item)
+			continue
+		}
+
+		// The names of the results of the searches are paths.
+		if ok, _ := path.Match(pattern, path.Base(item.Name)); ok {
+			items = append(items, item)
+			continue
+		}
+
+		switch {
+		case item.Error:
+			listing.NumUnreadable--
+		case item.IsDir:
+			listing.NumDirs--
+		default:
+			listing.NumFiles--
+		}
+	}
+	listing.Items = items
+	return nil
+}
+
+// annotateGitStatus sets the git status of the items of the listing of
+// the directory at r's path and its branch, if it is in a work tree of
+// the area of the scope it's in, on a local disk, and removes its .git. The listing is left as it is if git
+// fails, such as when it isn't installed.
+func annotateGitStatus(r *http.Request, d *data, listing *files.Listing) {
+	dir, ok := d.user.LocalPath(r.URL.Path)
+	if !ok {
+		return
+	}
+
+	area, _ := d.area(r.URL.Path)
+	root, ok := d.user.LocalPath(area)
+	if !ok {
+		return
+	}
+
+	status, err := gitstatus.Of(root, dir)
+	if err != nil {
+		d.logger.Debug("git status", "path", r.URL.Path, "error", err)
+		return
+	}
+
+	listing.Branch = status.Branch
+	items := listing.Items[:0]
+	for _, item := range listing.Items {
+		if item.Name == ".git" {
+			switch {
+			case item.Error:
+				listing.NumUnreadable--
+			case item.IsDir:
+				listing.NumDirs--
+			default:
+				listing.NumFiles--
+			}
+			continue
+		}
+
+		item.GitStatus = status.Entries[item.Name]
+		items = append(items, item)
+	}
+	listing.Items = items
+}
+
+// renderHTML writes a listing as an HTML page. The page is rendered with
+// the template of the directory if there is one and it works, or with
+// the default template otherwise.
+func renderHTML(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	query := keptQuery(r)
+	locale := detectLocale(r, d.user.Locale)
+	baseURL := d.baseURL(r)
+	page := &listingPage{
+		dirView:      newDirView(file),
+		BaseURL:      baseURL,
+		StaticURL:    d.staticURL(baseURL),
+		Params:       formParams(query),
+		Title:        listingTitle(d) + " – " + file.Path,
+		Favicon:      faviconURL(d, baseURL),
+		Theme:        activeTheme(r, d.settings.Branding.Theme),
+		Locale:       locale,
+		Styles:       brandingAssetURLs(d, baseURL, d.settings.Branding.Styles),
+		Scripts:      brandingAssetURLs(d, baseURL, d.settings.Branding.Scripts),
+		Perm:         d.user.Perm,
+		ServerSearch: apiOnly(d),
+		query:        r.URL.Query(),
+		pageMessages: messages(locale),
+		location:     listingLocation(r, d),
+		dateFormat:   listingDateFormat(d),
+		maxLimit:     d.settings.MaxLimit,
+		limit:        d.limit,
+		variables:    d.user.Variables,
+		Recent:       r.URL.Query().Get("recent"),
+		Truncated:    file.Truncated,
+		Fetch:        d.settings.Fetch.Enabled,
+		ReadOnly:     d.server.ReadOnly,
+		Live:         !d.settings.Live.Disabled,
+		Branding:     userBranding(d),
+		Format:       d.format,
+		CSRFToken:    csrfToken(d),
+	}
+
+	if len(query) > 0 {
+		page.Query = "?" + query.Encode()
+	}
+
+	if opts := listingOptions(d, file.Path); opts != nil {
+		if opts.Title != "" {
+			page.Title = opts.Title
+		}
+		page.Description = opts.Description
+	}
+
+	// Without JavaScript, the search form reloads the page with the
+	// search query parameter and the results replace the listing.
+	search := r.URL.Query().Get("search")
+	switch {
+	case r.URL.Query().Get("duplicates") == "true":
+		opts, err := duplicatesOptions(r, d, file.Path)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		listing, truncated, err := duplicatesListing(d, opts)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		listing.Favorites = file.Favorites
+		listing.Capabilities = file.Capabilities
+		results := *file
+		results.Listing = listing
+		page.dirView = newDirView(&results)
+		page.Duplicates = true
+		page.Truncated = truncated
+	case strings.TrimSpace(search) == "":
+		// The index and the README files are sanitized, so their HTML is
+		// kept as it is.
+		page.Index = template.HTML(listingIndex(d, file, baseURL, page.Query))
+		if page.Index == "" {
+			page.Readme = template.HTML(listingReadme(d, file, baseURL, page.Query))
+		}
+
+		// The index file may have been left out of the items.
+		page.dirView = newDirView(file)
+	default:
+		// The results of the search are already the items.
+		page.Search = search
+	}
+
+	tpl, err := brandingListing(d)
+	if err != nil {
+		d.logger.Error("couldn't parse the listing template", "path", d.settings.Branding.Listing, "error", err)
+		http.Error(w, "couldn't parse the listing template: "+err.Error(), http.StatusInternalServerError)
+		return 0, nil
+	} else if tpl == nil {
+		tpl = defaultListing
+	}
+
+	if d.settings.DirTemplates {
+		custom, err := dirTemplate(d, file.Path)
+		if err != nil {
+			d.logger.Warn("couldn't load the listing template, using the default one", "path", file.Path, "error", err)
+		} else if custom != nil {
+			tpl = custom
+		}
+	}
+
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, page)
+	if err != nil && tpl != defaultListing {
+		d.logger.Warn("couldn't render the listing template, using the default one", "path", file.Path, "error", err)
+		buf.Reset()
+		err = defaultListing.Execute(&buf, page)
+	}
+
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	setFormatHeaders(w, formatHTML, file)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := buf.WriteTo(w); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
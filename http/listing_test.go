@@ -0,0 +1,324 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/excludefs"
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestListing(t *testing.T) {
+	modTime := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt":   {Content: "hello", ModTime: modTime},
+		"/docs/sub/":    {},
+		"/docs/latest":  {Link: "a.txt"},
+		"/docs/private": {Mode: os.ModeDir, Err: os.ErrPermission},
+	}, settings.WithDefaultSort("name", false))
+	updateSettings(t, srv, func(s *settings.Settings) { s.Symlinks = settings.SymlinksShow })
+
+	listing, err := srv.Listing("/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type item struct {
+		name   string
+		dir    bool
+		size   int64
+		target string
+		error  bool
+	}
+
+	want := []item{
+		{name: "a.txt", size: 5},
+		{name: "latest", size: 5, target: "a.txt"},
+		{name: "private", error: true},
+		{name: "sub", dir: true},
+	}
+
+	if len(listing.Items) != len(want) {
+		t.Fatalf("the listing has %d items, want %d", len(listing.Items), len(want))
+	}
+
+	for i, got := range listing.Items {
+		if got := (item{got.Name, got.IsDir, got.Size, got.LinkTarget, got.Error}); got != want[i] {
+			t.Errorf("item %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	if got := listing.Items[0].ModTime; !got.Equal(modTime) {
+		t.Errorf("the modification time of a.txt is %v, want %v", got, modTime)
+	}
+
+	if listing.NumDirs != 1 || listing.NumFiles != 2 {
+		t.Errorf("the listing has %d directories and %d files, want 1 and 2", listing.NumDirs, listing.NumFiles)
+	}
+}
+
+func TestListingFormsToken(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "a"}})
+
+	w := do(t, srv, "GET", "/api/resources/?format=html&lang=fr&auth=a.b.c", "")
+	body := w.Body.String()
+	if !strings.Contains(body, `name="lang" value="fr"`) {
+		t.Error("the search form doesn't keep the language")
+	}
+
+	if strings.Contains(body, `name="auth"`) {
+		t.Error("the search form copies the token")
+	}
+}
+
+func TestListingIgnored(t *testing.T) {
+	tests := []struct {
+		query    string
+		want     string
+		numDirs  int
+		numFiles int
+	}{
+		{"", "b.txt d.txt e.txt keep", 1, 3},
+		// The limits apply to what the patterns leave.
+		{"?limit=2", "b.txt d.txt", 1, 3},
+		{"?limit=3", "b.txt d.txt e.txt", 1, 3},
+		// Only the entries of the page are read in the order of the
+		// filesystem, and one more to tell there are others.
+		{"?sort=none&limit=2", "b.txt d.txt", 0, 3},
+		{"?sort=none&limit=2&offset=2", "e.txt keep", 1, 3},
+	}
+
+	// The scopes of the users are wrapped the same way.
+	fs := excludefs.New(filebrowsertest.NewFS(map[string]filebrowsertest.File{
+		"/docs/a.bak":             {Content: "a"},
+		"/docs/b.txt":             {Content: "b"},
+		"/docs/c.BAK":             {Content: "c"},
+		"/docs/d.txt":             {Content: "d"},
+		"/docs/e.txt":             {Content: "e"},
+		"/docs/keep/":             {},
+		"/docs/node_modules/x.js": {Content: "x"},
+	}), nil, []string{"*.bak", "*.BAK", "node_modules/"})
+	srv, err := filebrowsertest.New(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := do(t, srv, "GET", "/api/resources/docs/"+tt.query, "", "Accept", "application/json")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			var listing files.Listing
+			if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+				t.Fatal(err)
+			}
+
+			names := make([]string, len(listing.Items))
+			for i, item := range listing.Items {
+				names[i] = item.Name
+			}
+			sort.Strings(names)
+
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("the listing has %s, want %s", got, tt.want)
+			}
+			if listing.NumDirs != tt.numDirs || listing.NumFiles != tt.numFiles {
+				t.Errorf("the listing has %d directories and %d files, want %d and %d", listing.NumDirs, listing.NumFiles, tt.numDirs, tt.numFiles)
+			}
+		})
+	}
+
+	for _, name := range []string{"a.bak", "c.BAK", "node_modules/x.js"} {
+		if w := do(t, srv, "GET", "/api/raw/docs/"+name, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want %d", name, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestListingDirsFirst(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/big.iso":     {Content: strings.Repeat("b", 300)},
+		"/docs/small.txt":   {Content: "s"},
+		"/docs/mid.txt":     {Content: strings.Repeat("m", 100)},
+		"/docs/zebra/a.txt": {Content: "a"},
+		"/docs/apple/":      {},
+	})
+	updateSettings(t, srv, func(s *settings.Settings) { s.DirsFirst = true })
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"?sort=size&order=desc", "apple zebra big.iso mid.txt small.txt"},
+		{"?sort=size&order=asc", "apple zebra small.txt mid.txt big.iso"},
+		{"?sort=size&order=desc&dirsfirst=false", "big.iso mid.txt small.txt apple zebra"},
+		{"?sort=size&order=desc&dirsfirst=true", "apple zebra big.iso mid.txt small.txt"},
+	}
+
+	rowPath := regexp.MustCompile(`<tr data-path="/docs/([^"]+)"`)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := do(t, srv, "GET", "/api/resources/docs/"+tt.query, "", "Accept", "application/json")
+			var listing files.Listing
+			if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, item := range listing.Items {
+				names = append(names, item.Name)
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("the JSON listing is %s, want %s", got, tt.want)
+			}
+
+			// The HTML listing has the same order.
+			w = do(t, srv, "GET", "/api/resources/docs/"+tt.query, "", "Accept", "text/html")
+			names = nil
+			for _, match := range rowPath.FindAllStringSubmatch(w.Body.String(), -1) {
+				names = append(names, match[1])
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("the HTML listing is %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListingFilter(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/logs/a.log":        {Content: "a"},
+		"/logs/b.log":        {Content: "b"},
+		"/logs/c.log":        {Content: "c"},
+		"/logs/IMG_1.jpg":    {Content: "i"},
+		"/logs/notes.txt":    {Content: "n"},
+		"/logs/archive/":     {},
+		"/logs/old.log/x":    {Content: "x"},
+		"/logs/IMG_dir/a.go": {Content: "g"},
+	})
+
+	tests := []struct {
+		query    string
+		status   int
+		want     string
+		numDirs  int
+		numFiles int
+	}{
+		{"?filter=*.log", http.StatusOK, "archive IMG_dir old.log a.log b.log c.log", 3, 3},
+		{"?filter=IMG_*.jpg", http.StatusOK, "archive IMG_dir old.log IMG_1.jpg", 3, 1},
+		{"?filter=*.none", http.StatusOK, "archive IMG_dir old.log", 3, 0},
+		// The directories are only filtered with filterdirs.
+		{"?filter=*.log&filterdirs=true", http.StatusOK, "old.log a.log b.log c.log", 1, 3},
+		{"?filter=IMG_*&filterdirs=true", http.StatusOK, "IMG_dir IMG_1.jpg", 1, 1},
+		// The filter applies before the limit.
+		{"?filter=*.log&filterdirs=true&limit=2", http.StatusOK, "old.log a.log", 1, 3},
+		{"?filter=*.log&filterdirs=true&limit=2&offset=2", http.StatusOK, "b.log c.log", 1, 3},
+		{"?filter=[", http.StatusBadRequest, "", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := do(t, srv, "GET", "/api/resources/logs/"+tt.query+"&dirsfirst=true", "", "Accept", "application/json")
+			if w.Code != tt.status {
+				t.Fatalf("GET = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var listing files.Listing
+			if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+				t.Fatal(err)
+			}
+
+			names := make([]string, len(listing.Items))
+			for i, item := range listing.Items {
+				names[i] = item.Name
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("the listing is %s, want %s", got, tt.want)
+			}
+			if listing.NumDirs != tt.numDirs || listing.NumFiles != tt.numFiles {
+				t.Errorf("the listing has %d directories and %d files, want %d and %d", listing.NumDirs, listing.NumFiles, tt.numDirs, tt.numFiles)
+			}
+		})
+	}
+
+	// The sort links keep the filter.
+	w := do(t, srv, "GET", "/api/resources/logs/?filter=*.log", "", "Accept", "text/html")
+	if !strings.Contains(w.Body.String(), `href="?filter=%2A.log&amp;order=desc&amp;sort=size"`) {
+		t.Errorf("the sort links don't keep the filter:\n%s", w.Body)
+	}
+}
+
+func TestListingBreadcrumbs(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/C: drive/a%2Fb/what?/a.txt": {Content: "a"},
+	})
+
+	w := do(t, srv, "GET", "/api/resources/C:%20drive/a%252Fb/what%3F/?lang=en", "", "Accept", "text/html")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET = %d: %s", w.Code, w.Body)
+	}
+
+	// The crumbs are in order, with their names as they are and their
+	// links escaped, keeping the query.
+	want := regexp.QuoteMeta(`<a href="/api/resources/?lang=en">`) + `[^<]*</a> / ` +
+		regexp.QuoteMeta(`<a href="/api/resources/C:%20drive/?lang=en">C: drive</a> / `) +
+		regexp.QuoteMeta(`<a href="/api/resources/C:%20drive/a%252Fb/?lang=en">a%2Fb</a> / `) +
+		regexp.QuoteMeta(`<a href="/api/resources/C:%20drive/a%252Fb/what%3F/?lang=en">what?</a>`)
+	if !regexp.MustCompile(want).MatchString(w.Body.String()) {
+		t.Errorf("the breadcrumbs don't match %s:\n%s", want, w.Body)
+	}
+}
+
+func TestListingNegotiation(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{"/docs/a.txt": {Content: "a"}})
+
+	tests := []struct {
+		name        string
+		target      string
+		accept      string
+		code        int
+		contentType string
+		vary        bool
+	}{
+		{"browser", "/api/resources/docs/", "text/html,application/xml;q=0.9,*/*;q=0.8", http.StatusOK, "text/html; charset=utf-8", true},
+		{"API", "/api/resources/docs/", "application/json, text/plain, */*", http.StatusOK, "application/json; charset=utf-8", true},
+		{"weak JSON", "/api/resources/docs/", "text/html, application/json;q=0.1", http.StatusOK, "text/html; charset=utf-8", true},
+		{"format", "/api/resources/docs/?format=csv", "text/html", http.StatusOK, "text/csv; charset=utf-8", false},
+		{"nothing acceptable", "/api/resources/docs/", "*/*;q=0", http.StatusNotAcceptable, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, srv, "GET", tt.target, "", "Accept", tt.accept)
+			if w.Code != tt.code {
+				t.Fatalf("GET = %d, want %d: %s", w.Code, tt.code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); tt.contentType != "" && got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+
+			vary := false
+			for _, v := range w.Header().Values("Vary") {
+				for _, key := range strings.Split(v, ",") {
+					vary = vary || strings.TrimSpace(key) == "Accept"
+				}
+			}
+			if vary != tt.vary {
+				t.Errorf("Vary = %q, want Accept: %v", w.Header().Values("Vary"), tt.vary)
+			}
+		})
+	}
+}
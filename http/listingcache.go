@@ -0,0 +1,267 @@
+package http
+
+import (
+	"container/list"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// listingCacheKey is what a cached listing is read with.
+type listingCacheKey struct {
+	user  uint
+	path  string
+	limit int
+}
+
+type cachedListing struct {
+	key     listingCacheKey
+	dir     string
+	file    *files.FileInfo
+	expires time.Time
+}
+
+// listingCache caches the listings of the directories as they're read,
+// before they're sorted, filtered and paged, so the requests for them in
+// any order share them. The listings are dropped once their TTL is over
+// or, for the directories on the local disk, as soon as the watcher sees
+// them change. The ones the watcher can't watch, such as the ones on
+// remote filesystems, only expire, unless they're changed through the
+// server. The least recently used listings are dropped to keep the
+// numbers of listings and of items under the limits of the settings.
+type listingCache struct {
+	// hits and misses come first to be aligned for the atomic operations.
+	hits, misses uint64
+
+	sync.Mutex
+	entries map[listingCacheKey]*list.Element
+	lru     *list.List
+	items   int
+	// dirs are the keys of the listings by their directory, which is the
+	// path on the disk for the watched ones.
+	dirs     map[string]map[listingCacheKey]struct{}
+	watcher  *fsnotify.Watcher
+	watchErr error
+}
+
+var listings = &listingCache{}
+
+// cacheDir returns the directory of the listing of p of the scope of the
+// user, which is its path on the disk if it's on the local one, and if
+// it's on it.
+func cacheDir(d *data, p string) (string, bool) {
+	if local, ok := d.user.LocalPath(p); ok {
+		return filepath.Clean(local), true
+	}
+
+	return strconv.FormatUint(uint64(d.user.ID), 10) + ":" + p, false
+}
+
+// readResource returns the file of opts with, if it's a directory, its
+// listing, which comes from the cache when it's on. The directories are
+// the paths ending with a slash, and the nocache query parameter skips
+// the cache.
+func readResource(r *http.Request, d *data, opts files.FileOptions) (*files.FileInfo, error) {
+	ttl := d.settings.ListingCache.TTL
+	if ttl <= 0 || !strings.HasSuffix(opts.Path, "/") || r.URL.Query().Get("nocache") == "true" {
+		return files.NewFileInfo(opts)
+	}
+
+	key := listingCacheKey{user: d.user.ID, path: path.Clean("/" + opts.Path), limit: opts.ReadLimit}
+	if file := listings.get(key, opts.Fs); file != nil {
+		return file, nil
+	}
+
+	file, err := files.NewFileInfo(opts)
+	if err != nil || !file.IsDir {
+		return file, err
+	}
+
+	listings.put(d, key, file, time.Duration(ttl)*time.Second)
+	return file, nil
+}
+
+// get returns a copy of the listing of key, read with fs, or nil if it
+// isn't cached.
+func (c *listingCache) get(key listingCacheKey, fs afero.Fs) *files.FileInfo {
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+
+	cached := elem.Value.(*cachedListing)
+	if time.Now().After(cached.expires) {
+		c.remove(elem)
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	c.lru.MoveToFront(elem)
+	return cached.file.Clone(fs)
+}
+
+// put caches a copy of the listing of key, watching its directory if it
+// can.
+func (c *listingCache) put(d *data, key listingCacheKey, file *files.FileInfo, ttl time.Duration) {
+	maxEntries, maxItems := d.settings.ListingCache.Limits()
+	if len(file.Items) > maxItems {
+		return
+	}
+
+	dir, local := cacheDir(d, key.path)
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[listingCacheKey]*list.Element{}
+		c.dirs = map[string]map[listingCacheKey]struct{}{}
+		c.lru = list.New()
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	if local && c.dirs[dir] == nil && !c.watch(dir) {
+		d.logger.Debug("the listing is only cached for its TTL", "path", key.path, "error", c.watchErr)
+	}
+
+	cached := &cachedListing{key: key, dir: dir, file: file.Clone(nil), expires: time.Now().Add(ttl)}
+	c.entries[key] = c.lru.PushFront(cached)
+	c.items += len(file.Items)
+	if c.dirs[dir] == nil {
+		c.dirs[dir] = map[listingCacheKey]struct{}{}
+	}
+	c.dirs[dir][key] = struct{}{}
+
+	for len(c.entries) > maxEntries || c.items > maxItems {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops a cached listing, and stops watching its directory if no
+// other listing needs it. It must be called with the lock held.
+func (c *listingCache) remove(elem *list.Element) {
+	cached := elem.Value.(*cachedListing)
+	c.lru.Remove(elem)
+	delete(c.entries, cached.key)
+	c.items -= len(cached.file.Items)
+
+	keys := c.dirs[cached.dir]
+	delete(keys, cached.key)
+	if len(keys) > 0 {
+		return
+	}
+
+	delete(c.dirs, cached.dir)
+	if c.watcher != nil && filepath.IsAbs(cached.dir) {
+		// The directory may be gone, which removes its watch.
+		_ = c.watcher.Remove(cached.dir)
+	}
+}
+
+// watch watches dir, starting the watcher the first time. It returns
+// false if the directory can't be watched, such as when the system has
+// no more watches. It must be called with the lock held.
+func (c *listingCache) watch(dir string) bool {
+	if c.watcher == nil {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			c.watchErr = err
+			return false
+		}
+
+		c.watcher = watcher
+		go c.watchEvents(watcher)
+	}
+
+	if err := c.watcher.Add(dir); err != nil {
+		c.watchErr = err
+		return false
+	}
+
+	return true
+}
+
+// watchEvents drops the listings of the directories the watcher sees
+// change: the ones the changed files are in, and the changed directories
+// themselves. The listings are all dropped when the watcher fails, since
+// it may have missed events.
+func (c *listingCache) watchEvents(watcher *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			c.Lock()
+			c.invalidate(filepath.Dir(event.Name))
+			c.invalidate(event.Name)
+			c.Unlock()
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			c.clear()
+		}
+	}
+}
+
+// invalidate drops the listings of dir. It must be called with the lock
+// held.
+func (c *listingCache) invalidate(dir string) {
+	for key := range c.dirs[dir] {
+		c.remove(c.entries[key])
+	}
+}
+
+// forget drops the listings of the file at p of the scope of the user,
+// which the request changed, and of its directory, so they're fresh even
+// if they aren't watched.
+func (c *listingCache) forget(d *data, p string) {
+	p = path.Clean("/" + p)
+	dir, _ := cacheDir(d, p)
+	parent, _ := cacheDir(d, path.Dir(p))
+
+	c.Lock()
+	c.invalidate(dir)
+	c.invalidate(parent)
+	c.Unlock()
+}
+
+// clear drops all the listings, such as when the rules or the settings
+// they're read with change.
+func (c *listingCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, elem := range c.entries {
+		c.remove(elem)
+	}
+}
+
+func (c *listingCache) status() cacheStatus {
+	c.Lock()
+	size := len(c.entries)
+	c.Unlock()
+
+	return newCacheStatus(size, atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses))
+}
@@ -0,0 +1,231 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/files"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// cacheServer returns a server on fs whose listings are cached with cache,
+// with the status of its caches at /status.
+func cacheServer(t *testing.T, fs afero.Fs, cache settings.ListingCache) *filebrowsertest.Server {
+	t.Helper()
+
+	srv, err := filebrowsertest.New(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateSettings(t, srv, func(s *settings.Settings) { s.ListingCache = cache })
+
+	server := &settings.Server{Root: "/", StatusPath: "/status", MetricsAllow: []string{"192.0.2.0/24"}}
+	if err := srv.Handler.(*fbhttp.Handler).Reload(server); err != nil {
+		t.Fatal(err)
+	}
+
+	return srv
+}
+
+// listingCounters returns the hits and the misses of the listing cache.
+func listingCounters(t *testing.T, srv *filebrowsertest.Server) (hits, misses uint64) {
+	t.Helper()
+
+	w := do(t, srv, "GET", "/status", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /status = %d: %s", w.Code, w.Body)
+	}
+
+	var status struct {
+		Caches map[string]struct {
+			Hits   uint64 `json:"hits"`
+			Misses uint64 `json:"misses"`
+		} `json:"caches"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	return status.Caches["listings"].Hits, status.Caches["listings"].Misses
+}
+
+// cachedNames returns the names of the items of the listing of target,
+// and checks the hits and the misses of the cache it took.
+func cachedNames(t *testing.T, srv *filebrowsertest.Server, target string, hits, misses uint64) string {
+	t.Helper()
+
+	hits0, misses0 := listingCounters(t, srv)
+	w := do(t, srv, "GET", target, "", "Accept", "application/json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d: %s", target, w.Code, w.Body)
+	}
+
+	if hits1, misses1 := listingCounters(t, srv); hits1-hits0 != hits || misses1-misses0 != misses {
+		t.Errorf("GET %s got %d hits and %d misses, want %d and %d", target, hits1-hits0, misses1-misses0, hits, misses)
+	}
+
+	file := &files.FileInfo{}
+	if err := json.Unmarshal(w.Body.Bytes(), file); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, item := range file.Items {
+		names = append(names, item.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestListingCache(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"docs/a.txt", "docs/bb.txt", "docs/ccc.txt", "docs/sub/d.txt"} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(filepath.Base(name)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := cacheServer(t, afero.NewBasePathFs(afero.NewOsFs(), dir), settings.ListingCache{TTL: 60})
+
+	// The sorts, the orders and the limits share the listing.
+	steps := []struct {
+		target       string
+		hits, misses uint64
+		want         string
+	}{
+		{"/api/resources/docs/", 0, 1, "a.txt bb.txt ccc.txt sub"},
+		{"/api/resources/docs/", 1, 0, "a.txt bb.txt ccc.txt sub"},
+		{"/api/resources/docs/?sort=size&order=desc", 1, 0, "ccc.txt bb.txt a.txt sub"},
+		{"/api/resources/docs/?sort=size&order=desc&limit=2", 1, 0, "ccc.txt bb.txt"},
+		{"/api/resources/docs/?nocache=true", 0, 0, "a.txt bb.txt ccc.txt sub"},
+		{"/api/resources/docs/sub/", 0, 1, "d.txt"},
+	}
+	for _, step := range steps {
+		if got := cachedNames(t, srv, step.target, step.hits, step.misses); got != step.want {
+			t.Errorf("GET %s = %q, want %q", step.target, got, step.want)
+		}
+	}
+
+	// The concurrent requests share the listing, sorting their copies.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			target := []string{"/api/resources/docs/?sort=size", "/api/resources/docs/?sort=name&order=asc", "/api/resources/docs/?limit=1"}[i%3]
+			r := httptest.NewRequest("GET", target, nil)
+			r.Header.Set("Accept", "application/json")
+			if w, err := srv.Do(r); err != nil || w.Code != http.StatusOK {
+				t.Errorf("GET %s failed: %v %v", target, err, w)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// The validators come from the cached listing.
+	w := do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "application/json")
+	etag := w.Header().Get("ETag")
+	w = do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "application/json", "If-None-Match", etag)
+	if etag == "" || w.Code != http.StatusNotModified {
+		t.Errorf("GET with the ETag %q = %d, want 304", etag, w.Code)
+	}
+
+	// The changes through the server drop the listing at once.
+	w = do(t, srv, "POST", "/api/resources/docs/new.txt", "new", "X-CSRF-Token", csrfToken(t, srv))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST = %d: %s", w.Code, w.Body)
+	}
+	if got := cachedNames(t, srv, "/api/resources/docs/", 0, 1); got != "a.txt bb.txt ccc.txt new.txt sub" {
+		t.Errorf("the listing after the upload is %q", got)
+	}
+
+	// The changes on the disk drop it once the watcher sees them.
+	if err := os.Remove(filepath.Join(dir, "docs", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "application/json")
+		if !strings.Contains(w.Body.String(), `"a.txt"`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the listing still has the removed file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestListingCacheTTL(t *testing.T) {
+	// The memory filesystems aren't watched, so their listings expire.
+	fs := filebrowsertest.NewFS(map[string]filebrowsertest.File{"/ttl/a.txt": {Content: "a"}})
+	srv := cacheServer(t, fs, settings.ListingCache{TTL: 1})
+
+	if got := cachedNames(t, srv, "/api/resources/ttl/", 0, 1); got != "a.txt" {
+		t.Fatalf("the listing is %q", got)
+	}
+	if err := afero.WriteFile(fs, "/ttl/b.txt", []byte("b"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := cachedNames(t, srv, "/api/resources/ttl/", 1, 0); got != "a.txt" {
+		t.Errorf("the cached listing is %q, want the one before the change", got)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if got := cachedNames(t, srv, "/api/resources/ttl/", 0, 1); got != "a.txt b.txt" {
+		t.Errorf("the expired listing is %q", got)
+	}
+}
+
+func TestListingCacheLimits(t *testing.T) {
+	fs := filebrowsertest.NewFS(map[string]filebrowsertest.File{
+		"/lru/a/1.txt": {Content: "1"},
+		"/lru/b/1.txt": {Content: "1"},
+		"/lru/b/2.txt": {Content: "2"},
+		"/lru/c/1.txt": {Content: "1"},
+		"/lru/c/2.txt": {Content: "2"},
+		"/lru/c/3.txt": {Content: "3"},
+		"/lru/d/1.txt": {Content: "1"},
+		"/lru/d/2.txt": {Content: "2"},
+		"/lru/d/3.txt": {Content: "3"},
+		"/lru/d/4.txt": {Content: "4"},
+		"/lru/d/5.txt": {Content: "5"},
+	})
+	srv := cacheServer(t, fs, settings.ListingCache{TTL: 60, MaxEntries: 2, MaxItems: 4})
+
+	// The least recently used listing is dropped, and the listings over
+	// the items are never kept.
+	steps := []struct {
+		dir          string
+		hits, misses uint64
+	}{
+		{"a", 0, 1},
+		{"b", 0, 1},
+		{"a", 1, 0},
+		{"c", 0, 1}, // three listings: b is dropped
+		{"c", 1, 0},
+		{"a", 1, 0},
+		{"b", 0, 1}, // six items: c is dropped
+		{"c", 0, 1}, // three listings and six items: a and b are dropped
+		{"b", 0, 1}, // five items: c is dropped
+		{"b", 1, 0},
+		{"d", 0, 1}, // five items: never kept
+		{"d", 0, 1},
+	}
+	for _, step := range steps {
+		cachedNames(t, srv, "/api/resources/lru/"+step.dir+"/", step.hits, step.misses)
+	}
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/markdown"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// listingIndexNames are the names of the index files of the HTML
+// listings, by priority.
+var listingIndexNames = []string{"index.html", "index.htm", "index.md"}
+
+// maxListingIndexSize is the maximum size of the index files shown above
+// the HTML listings. Larger ones are only listed.
+const maxListingIndexSize = 256 << 10
+
+// maxReadmeSize is the maximum size of the README files shown above the
+// HTML listings. Larger ones are only listed.
+const maxReadmeSize = 512 << 10
+
+// listingIndex returns the sanitized content of the index file of the
+// listed directory, or an empty string if it has none or the index files
+// are not shown. In the ListingIndexHide mode, the index file is removed
+// from the listing. Markdown files are rendered to HTML. The mode of the
+// user wins over the one of the settings.
+func listingIndex(d *data, file *files.FileInfo, baseURL, query string) string {
+	mode := d.settings.ListingIndex
+	if d.user.ListingIndex != "" {
+		mode = d.user.ListingIndex
+	}
+
+	if mode != settings.ListingIndexShow && mode != settings.ListingIndexHide {
+		return ""
+	}
+
+	for _, name := range listingIndexNames {
+		for i, item := range file.Items {
+			if item.IsDir || item.Special != "" || !strings.EqualFold(item.Name, name) {
+				continue
+			}
+
+			if item.Size > maxListingIndexSize {
+				d.logger.Warn("the index file is too large", "path", item.Path, "limit", maxListingIndexSize)
+				return ""
+			}
+
+			content, err := renderIndex(d, item, baseURL, query, maxListingIndexSize)
+			if err != nil {
+				d.logger.Warn("couldn't render the index file", "path", item.Path, "error", err)
+				return ""
+			}
+
+			if mode == settings.ListingIndexHide {
+				file.Items = append(file.Items[:i], file.Items[i+1:]...)
+				file.NumFiles--
+			}
+
+			return content
+		}
+	}
+
+	return ""
+}
+
+// findReadme returns the README file of the listing whose name comes
+// first in the settings, or nil if it has none.
+func findReadme(d *data, listing *files.Listing) *files.FileInfo {
+	for _, name := range d.settings.Readmes {
+		for _, item := range listing.Items {
+			if !item.IsDir && !item.Error && item.Special == "" && strings.EqualFold(item.Name, name) {
+				return item
+			}
+		}
+	}
+
+	return nil
+}
+
+// listingReadme returns the sanitized content of the README file of the
+// listed directory, rendered like the index files, or an empty string if
+// it has none or it can't be rendered, so the listing is still shown.
+// The README file stays in the listing.
+func listingReadme(d *data, file *files.FileInfo, baseURL, query string) string {
+	item := findReadme(d, file.Listing)
+	if item == nil {
+		return ""
+	}
+
+	if item.Size > maxReadmeSize {
+		d.logger.Warn("the README file is too large", "path", item.Path, "limit", maxReadmeSize)
+		return ""
+	}
+
+	content, err := renderIndex(d, item, baseURL, query, maxReadmeSize)
+	if err != nil {
+		d.logger.Warn("couldn't render the README file", "path", item.Path, "error", err)
+		return ""
+	}
+
+	return content
+}
+
+// annotateReadme sets the source of the README file of the listing, for
+// the requests with readme=true.
+func annotateReadme(r *http.Request, d *data, listing *files.Listing) {
+	if r.URL.Query().Get("readme") != "true" {
+		return
+	}
+
+	item := findReadme(d, listing)
+	if item == nil || item.Size > maxReadmeSize {
+		return
+	}
+
+	src, err := afero.ReadFile(d.user.Fs, item.Path)
+	if err != nil {
+		d.logger.Warn("couldn't read the README file", "path", item.Path, "error", err)
+		return
+	}
+
+	listing.Readme = string(src)
+}
+
+// renderIndex returns the sanitized HTML of the index or the README file
+// item, of which at most limit bytes are read. The Markdown files are
+// rendered and the text files are shown as they are.
+func renderIndex(d *data, item *files.FileInfo, baseURL, query string, limit int) (string, error) {
+	src, err := afero.ReadFile(d.user.Fs, item.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if len(src) > limit {
+		src = src[:limit]
+	}
+
+	content := string(src)
+	switch strings.ToLower(path.Ext(item.Name)) {
+	case ".md":
+		content = markdown.Render(src)
+	case ".txt":
+		content = "<pre>" + template.HTMLEscapeString(content) + "</pre>"
+	}
+
+	// The relative links point to the files next to the index file, which
+	// are listed if their path ends with a slash or served otherwise.
+	dir := path.Dir(item.Path)
+	return sanitizeHTML(content, func(rel string) string {
+		u, err := url.Parse(rel)
+		if err != nil {
+			return ""
+		}
+
+		p := path.Join(dir, u.Path)
+		target := baseURL + pathJoinURL("/api/raw", p) + query
+		if u.Path == "" || strings.HasSuffix(u.Path, "/") {
+			target = baseURL + pathJoinURL("/api/resources", p, "/") + query
+		}
+
+		if i := strings.IndexByte(rel, '#'); i >= 0 {
+			target += rel[i:]
+		}
+
+		return target
+	})
+}
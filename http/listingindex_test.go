@@ -0,0 +1,265 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// indexSection returns the content of the section of the index file of
+// an HTML listing, and if there is one.
+func indexSection(body string) (string, bool) {
+	start := strings.Index(body, `<section id="index">`)
+	if start == -1 {
+		return "", false
+	}
+
+	end := strings.Index(body[start:], "</section>")
+	return body[start : start+end], true
+}
+
+func TestListingIndex(t *testing.T) {
+	big := "<p>" + strings.Repeat("x", 256<<10) + "</p>"
+
+	tests := []struct {
+		name  string
+		mode  string
+		files map[string]filebrowsertest.File
+		want  string // in the section of the index file, or "" without one
+		row   bool   // the index file is listed
+	}{
+		{"not shown by default", "", map[string]filebrowsertest.File{
+			"/docs/index.html": {Content: "<p>Hello</p>"},
+		}, "", true},
+		{"off", settings.ListingIndexOff, map[string]filebrowsertest.File{
+			"/docs/index.html": {Content: "<p>Hello</p>"},
+		}, "", true},
+		{"shown", settings.ListingIndexShow, map[string]filebrowsertest.File{
+			"/docs/index.html": {Content: "<p>Hello</p>"},
+		}, "<p>Hello</p>", true},
+		{"hidden from the rows", settings.ListingIndexHide, map[string]filebrowsertest.File{
+			"/docs/index.html": {Content: "<p>Hello</p>"},
+		}, "<p>Hello</p>", false},
+		{"any case", settings.ListingIndexShow, map[string]filebrowsertest.File{
+			"/docs/INDEX.HTML": {Content: "<p>Hello</p>"},
+		}, "<p>Hello</p>", true},
+		{"Markdown", settings.ListingIndexShow, map[string]filebrowsertest.File{
+			"/docs/index.md": {Content: "# Hello"},
+		}, "Hello</h1>", true},
+		{"HTML first", settings.ListingIndexShow, map[string]filebrowsertest.File{
+			"/docs/index.html": {Content: "<p>HTML</p>"},
+			"/docs/index.md":   {Content: "Markdown"},
+		}, "<p>HTML</p>", true},
+		{"sanitized", settings.ListingIndexShow, map[string]filebrowsertest.File{
+			"/docs/index.html": {Content: `<p onclick="steal()">Hello</p><script>steal()</script>`},
+		}, "<p>Hello</p>", true},
+		{"too large", settings.ListingIndexHide, map[string]filebrowsertest.File{
+			"/docs/index.html": {Content: big},
+		}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["/docs/a.txt"] = filebrowsertest.File{Content: "a"}
+			srv, _ := newServer(t, tt.files)
+			updateSettings(t, srv, func(s *settings.Settings) {
+				s.ListingIndex = tt.mode
+			})
+
+			w := do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "text/html")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			body := w.Body.String()
+			section, ok := indexSection(body)
+			switch {
+			case tt.want == "" && ok:
+				t.Errorf("the index file is shown: %q", section)
+			case tt.want != "" && !strings.Contains(section, tt.want):
+				t.Errorf("the index section is %q, want it with %q", section, tt.want)
+			}
+
+			if strings.Contains(section, "script") || strings.Contains(section, "onclick") {
+				t.Errorf("the index section isn't sanitized: %q", section)
+			}
+
+			rows := strings.Count(strings.ToLower(body), `<tr data-path="/docs/index.`)
+			if listed := rows > 0; listed != tt.row {
+				t.Errorf("the index file is listed: %v, want %v", listed, tt.row)
+			}
+
+			// The JSON listings are never changed.
+			listing, err := srv.Listing("/docs")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(listing.Items) != len(tt.files) {
+				t.Errorf("the JSON listing has %d items, want %d", len(listing.Items), len(tt.files))
+			}
+		})
+	}
+}
+
+func TestListingIndexUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings string
+		user     string
+		shown    bool
+		row      bool
+	}{
+		{"settings", settings.ListingIndexShow, "", true, true},
+		{"user off", settings.ListingIndexShow, settings.ListingIndexOff, false, true},
+		{"user hide", settings.ListingIndexShow, settings.ListingIndexHide, true, false},
+		{"user show", "", settings.ListingIndexShow, true, true},
+		{"user show over off", settings.ListingIndexOff, settings.ListingIndexShow, true, true},
+	}
+
+	// The same handler serves the scope with and without its own mode.
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/index.html": {Content: "<p>Hello</p>"},
+		"/docs/a.txt":      {Content: "a"},
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updateSettings(t, srv, func(s *settings.Settings) {
+				s.ListingIndex = tt.settings
+			})
+			updateUser(t, srv, func(u *users.User) {
+				u.ListingIndex = tt.user
+			})
+
+			w := do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "text/html")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			if _, shown := indexSection(w.Body.String()); shown != tt.shown {
+				t.Errorf("the index file is shown: %v, want %v", shown, tt.shown)
+			}
+
+			if row := strings.Contains(w.Body.String(), `<tr data-path="/docs/index.html"`); row != tt.row {
+				t.Errorf("the index file is listed: %v, want %v", row, tt.row)
+			}
+		})
+	}
+}
+
+func TestListingReadme(t *testing.T) {
+	big := strings.Repeat("x", 512<<10+1)
+
+	tests := []struct {
+		name    string
+		readmes []string
+		mode    string
+		files   map[string]filebrowsertest.File
+		want    string // in the section of the README, or "" without one
+		index   bool   // the index file is shown instead
+		source  string // the readme field of the JSON listing
+	}{
+		{"Markdown", settings.DefaultReadmes, "", map[string]filebrowsertest.File{
+			"/docs/README.md": {Content: "# Hello"},
+		}, "Hello</h1>", false, "# Hello"},
+		{"any case", settings.DefaultReadmes, "", map[string]filebrowsertest.File{
+			"/docs/readme.MD": {Content: "# Hello"},
+		}, "Hello</h1>", false, "# Hello"},
+		{"text", settings.DefaultReadmes, "", map[string]filebrowsertest.File{
+			"/docs/README.txt": {Content: "<b>bold</b>"},
+		}, "&lt;b&gt;bold&lt;/b&gt;", false, "<b>bold</b>"},
+		{"priority", settings.DefaultReadmes, "", map[string]filebrowsertest.File{
+			"/docs/README.txt": {Content: "text"},
+			"/docs/README.md":  {Content: "*markdown*"},
+		}, "<em>markdown</em>", false, "*markdown*"},
+		{"other names", []string{"NOTES.md"}, "", map[string]filebrowsertest.File{
+			"/docs/README.md": {Content: "readme"},
+			"/docs/NOTES.md":  {Content: "notes"},
+		}, "notes", false, "notes"},
+		{"off", nil, "", map[string]filebrowsertest.File{
+			"/docs/README.md": {Content: "# Hello"},
+		}, "", false, ""},
+		{"sanitized", settings.DefaultReadmes, "", map[string]filebrowsertest.File{
+			"/docs/README.md": {Content: `<script>steal()</script><p onclick="steal()">Hi</p>`},
+		}, "Hi", false, `<script>steal()</script><p onclick="steal()">Hi</p>`},
+		{"too large", settings.DefaultReadmes, "", map[string]filebrowsertest.File{
+			"/docs/README.md": {Content: big},
+		}, "", false, ""},
+		{"directory", settings.DefaultReadmes, "", map[string]filebrowsertest.File{
+			"/docs/README.md/a.txt": {Content: "a"},
+		}, "", false, ""},
+		// README.md isn't an index file, so it's shown as the README
+		// whatever the mode of the index files.
+		{"not an index", settings.DefaultReadmes, settings.ListingIndexHide, map[string]filebrowsertest.File{
+			"/docs/README.md": {Content: "# Hello"},
+		}, "Hello</h1>", false, "# Hello"},
+		{"index first", settings.DefaultReadmes, settings.ListingIndexShow, map[string]filebrowsertest.File{
+			"/docs/README.md":  {Content: "# Hello"},
+			"/docs/index.html": {Content: "<p>Index</p>"},
+		}, "", true, "# Hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.files["/docs/a.txt"] = filebrowsertest.File{Content: "a"}
+			srv, _ := newServer(t, tt.files)
+			updateSettings(t, srv, func(s *settings.Settings) {
+				s.Readmes = tt.readmes
+				s.ListingIndex = tt.mode
+			})
+
+			w := do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "text/html")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			body := w.Body.String()
+			start := strings.Index(body, `<section id="readme">`)
+			section := ""
+			if start != -1 {
+				section = body[start : start+strings.Index(body[start:], "</section>")]
+			}
+			switch {
+			case tt.want == "" && section != "":
+				t.Errorf("the README is shown: %q", section)
+			case tt.want != "" && !strings.Contains(section, tt.want):
+				t.Errorf("the README section is %q, want it with %q", section, tt.want)
+			}
+			if strings.Contains(section, "<script") || strings.Contains(section, `onclick="`) {
+				t.Errorf("the README section isn't sanitized: %q", section)
+			}
+			if _, index := indexSection(body); index != tt.index {
+				t.Errorf("the index file is shown: %v, want %v", index, tt.index)
+			}
+
+			// The README stays in the listing.
+			if strings.Count(body, `<tr data-path="/docs/`) != len(tt.files) {
+				t.Errorf("the listing doesn't have the %d files:\n%s", len(tt.files), body)
+			}
+
+			// The JSON listings only have the source when it's asked for.
+			for _, query := range []string{"", "?readme=true"} {
+				w := do(t, srv, "GET", "/api/resources/docs/"+query, "", "Accept", "application/json")
+				listing := &files.FileInfo{}
+				if err := json.Unmarshal(w.Body.Bytes(), listing); err != nil {
+					t.Fatal(err)
+				}
+
+				want := ""
+				if query != "" {
+					want = tt.source
+				}
+				if listing.Readme != want {
+					t.Errorf("GET %s has the readme %q, want %q", query, listing.Readme, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package http
+
+import (
+	"os"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// fileView is a file of the listings as the templates see it. It only has
+// values: the users write some of the templates, such as the ones of the
+// directories, so they must not reach the filesystem, whose user may be
+// another one, nor the methods that read or change the files.
+type fileView struct {
+	Path           string
+	Name           string
+	Size           int64
+	Extension      string
+	ModTime        time.Time
+	ModUnix        int64
+	Mode           os.FileMode
+	OctalMode      string
+	IsDir          bool
+	Type           string
+	Category       string
+	Subtitles      []string
+	Checksums      map[string]string
+	Error          bool
+	IsSymlink      bool
+	LinkTarget     string
+	SizeIsEstimate bool
+	BrokenLink     bool
+	GitStatus      string
+	DocMeta        *files.DocMeta
+	Special        string
+	Hidden         bool
+	Owner          string
+	Group          string
+	URL            string
+	Thumbnail      string
+	Tags           []string
+	MimeType       string
+}
+
+// IsText checks if the file is text, which can be previewed as it is.
+func (f fileView) IsText() bool {
+	return !f.IsDir && (f.Type == "text" || f.Type == "textImmutable")
+}
+
+// listingView is the listing of a directory as the templates see it.
+type listingView struct {
+	Items          []fileView
+	NumDirs        int
+	NumFiles       int
+	NumUnreadable  int
+	Sorting        files.Sorting
+	Usage          uint64
+	Free           uint64
+	Quota          uint64
+	ItemsLimitedTo int
+	Offset         int
+	TotalItems     int
+	Branch         string
+	Truncated      bool
+	DirSizes       bool
+	NumHidden      int
+	Favorites      []files.Favorite
+	Capabilities   files.Capabilities
+}
+
+// dirView is a directory and its listing as the templates see them.
+type dirView struct {
+	fileView
+	listingView
+}
+
+// newFileView returns the view of file, with copies of its values.
+func newFileView(file *files.FileInfo) fileView {
+	view := fileView{
+		Path:           file.Path,
+		Name:           file.Name,
+		Size:           file.Size,
+		Extension:      file.Extension,
+		ModTime:        file.ModTime,
+		ModUnix:        file.ModUnix,
+		Mode:           file.Mode,
+		OctalMode:      file.OctalMode,
+		IsDir:          file.IsDir,
+		Type:           file.Type,
+		Category:       file.Category,
+		Subtitles:      append([]string(nil), file.Subtitles...),
+		Error:          file.Error,
+		IsSymlink:      file.IsSymlink,
+		LinkTarget:     file.LinkTarget,
+		SizeIsEstimate: file.SizeIsEstimate,
+		BrokenLink:     file.BrokenLink,
+		GitStatus:      file.GitStatus,
+		Special:        file.Special,
+		Hidden:         file.Hidden,
+		Owner:          file.Owner,
+		Group:          file.Group,
+		URL:            file.URL,
+		Thumbnail:      file.Thumbnail,
+		Tags:           append([]string(nil), file.Tags...),
+		MimeType:       file.MimeType,
+	}
+
+	if file.Checksums != nil {
+		view.Checksums = make(map[string]string, len(file.Checksums))
+		for algo, sum := range file.Checksums {
+			view.Checksums[algo] = sum
+		}
+	}
+
+	if file.DocMeta != nil {
+		meta := *file.DocMeta
+		view.DocMeta = &meta
+	}
+
+	return view
+}
+
+// newDirView returns the view of the directory file and of its listing.
+func newDirView(file *files.FileInfo) dirView {
+	view := dirView{fileView: newFileView(file)}
+	listing := file.Listing
+	if listing == nil {
+		return view
+	}
+
+	view.listingView = listingView{
+		Items:          make([]fileView, len(listing.Items)),
+		NumDirs:        listing.NumDirs,
+		NumFiles:       listing.NumFiles,
+		NumUnreadable:  listing.NumUnreadable,
+		Sorting:        listing.Sorting,
+		Usage:          listing.Usage,
+		Free:           listing.Free,
+		Quota:          listing.Quota,
+		ItemsLimitedTo: listing.ItemsLimitedTo,
+		Offset:         listing.Offset,
+		TotalItems:     listing.TotalItems,
+		Branch:         listing.Branch,
+		Truncated:      listing.Truncated,
+		DirSizes:       listing.DirSizes,
+		NumHidden:      listing.NumHidden,
+		Favorites:      append([]files.Favorite(nil), listing.Favorites...),
+	}
+
+	for i, item := range listing.Items {
+		view.Items[i] = newFileView(item)
+	}
+
+	if listing.Capabilities != nil {
+		view.Capabilities = *listing.Capabilities
+	}
+
+	return view
+}
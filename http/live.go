@@ -0,0 +1,300 @@
+package http
+
+import (
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+const (
+	// maxLiveChanges is the number of changed files sent at once. The
+	// storms of more changes, such as the ones of a large copy, are sent
+	// as a reload instead.
+	maxLiveChanges = 1000
+	// livePing is how often the connections are pinged, so the ones of
+	// the clients that are gone are closed.
+	livePing = 30 * time.Second
+	// liveWriteWait is how long the messages have to be written.
+	liveWriteWait = 10 * time.Second
+)
+
+// liveEvent is a change of a directory: a file was created, removed,
+// renamed away or written. The removed and the renamed files only have a
+// name and a path. The reload events have no item, and tell the client
+// to read the listing again, since too much changed.
+type liveEvent struct {
+	Op   string      `json:"op"`
+	Item *listedFile `json:"item,omitempty"`
+}
+
+// liveConns counts the live connections, in all and by user.
+var liveConns = struct {
+	sync.Mutex
+	total int
+	users map[uint]int
+}{users: map[uint]int{}}
+
+// acquireLive takes a live connection for the user, returning the
+// function that gives it back, or false if the limits of the settings
+// are reached.
+func acquireLive(d *data) (func(), bool) {
+	liveConns.Lock()
+	defer liveConns.Unlock()
+
+	perUser := d.settings.Live.PerUser
+	if liveConns.total >= d.settings.Live.MaxConnections() || perUser > 0 && liveConns.users[d.user.ID] >= perUser {
+		return nil, false
+	}
+
+	id := d.user.ID
+	liveConns.total++
+	liveConns.users[id]++
+
+	return func() {
+		liveConns.Lock()
+		liveConns.total--
+		liveConns.users[id]--
+		if liveConns.users[id] == 0 {
+			delete(liveConns.users, id)
+		}
+		liveConns.Unlock()
+	}, true
+}
+
+// liveLen returns the number of live connections.
+func liveLen() int {
+	liveConns.Lock()
+	defer liveConns.Unlock()
+	return liveConns.total
+}
+
+// liveOp returns the change of a file of op. The events with several
+// changes are the most destructive of them.
+func liveOp(op fsnotify.Op) string {
+	switch {
+	case op&fsnotify.Remove != 0:
+		return "remove"
+	case op&fsnotify.Rename != 0:
+		return "rename"
+	case op&fsnotify.Create != 0:
+		return "create"
+	default:
+		return "write"
+	}
+}
+
+// liveVisible checks if the file at p is shown in the listing of its
+// directory dir, so the changes of the hidden files never leak.
+func liveVisible(r *http.Request, d *data, dir, p string) bool {
+	checker := &listingChecker{data: d, hide: !showHidden(r, d)}
+	if !inTrash(d, dir) {
+		checker.trash = d.settings.TrashPath()
+	}
+
+	name := path.Base(p)
+	switch {
+	case !checker.Check(p) || checker.Skip(p):
+		return false
+	case d.settings.DirTemplates && name == dirTemplateName:
+		return false
+	case d.settings.DirOptions != "" && name == d.settings.DirOptions:
+		return false
+	}
+
+	return true
+}
+
+// liveEvents returns the events of the changes of the files of dir, whose
+// ops are by path. The created and the written files are read the way
+// they are listed, sorted as requested, so the clients can put them in
+// their places; the ones that are gone since are removed.
+func liveEvents(r *http.Request, d *data, dir string, changes map[string]string) []liveEvent {
+	if len(changes) > maxLiveChanges {
+		return []liveEvent{{Op: "reload"}}
+	}
+
+	paths := make([]string, 0, len(changes))
+	for p := range changes {
+		if liveVisible(r, d, dir, p) {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var listed map[string]*listedFile
+	events := make([]liveEvent, 0, len(paths))
+	for _, p := range paths {
+		op := changes[p]
+		if op == "create" || op == "write" {
+			if listed == nil {
+				listing, err := shownListing(r, d, dir, false)
+				if err != nil {
+					return []liveEvent{{Op: "reload"}}
+				}
+
+				listed = make(map[string]*listedFile, len(listing.Items))
+				for i, item := range listing.Items {
+					listed[item.Path] = newListedItem(r, d, item, i)
+				}
+			}
+
+			if file, ok := listed[p]; ok {
+				events = append(events, liveEvent{Op: op, Item: file})
+				continue
+			}
+
+			op = "remove"
+		}
+
+		item := &files.FileInfo{Name: path.Base(p), Path: p}
+		events = append(events, liveEvent{Op: op, Item: &listedFile{FileInfo: item, Index: -1}})
+	}
+
+	return events
+}
+
+// liveHandler pushes the changes of the directory of the request through
+// a WebSocket as they happen, as JSON arrays of events. The changes are
+// gathered for the delay of the settings and sent at once. The
+// directories that aren't on the local disk can't be watched, nor can
+// the ones past the limits of the connections: the clients poll for
+// their changes instead.
+var liveHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if d.settings.Live.Disabled {
+		return http.StatusNotFound, nil
+	}
+
+	dir := path.Clean("/" + r.URL.Path)
+	if !d.Check(dir) {
+		return http.StatusNotFound, nil
+	}
+
+	info, err := d.user.Fs.Stat(dir)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return renderFailure(w, r, http.StatusBadRequest, "only the directories can be watched")
+	}
+
+	local, ok := d.user.LocalPath(dir)
+	if !ok {
+		return renderFailure(w, r, http.StatusNotImplemented, "the directory isn't on the local disk")
+	}
+
+	if !websocket.IsWebSocketUpgrade(r) {
+		return renderFailure(w, r, http.StatusBadRequest, "the changes are sent through a WebSocket")
+	}
+
+	release, ok := acquireLive(d)
+	if !ok {
+		w.Header().Set("Retry-After", "60")
+		return renderFailure(w, r, http.StatusServiceUnavailable, "too many live updates")
+	}
+	defer release()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return renderFailure(w, r, http.StatusServiceUnavailable, "the directory can't be watched")
+	}
+	defer watcher.Close()
+
+	local = filepath.Clean(local)
+	if err := watcher.Add(local); err != nil {
+		return renderFailure(w, r, http.StatusServiceUnavailable, "the directory can't be watched")
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already answered.
+		return 0, nil
+	}
+	defer conn.Close()
+
+	// The client sends nothing, but its messages must be read for the
+	// connection to notice the pongs and the close.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(events []liveEvent) error {
+		conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+		return conn.WriteJSON(events)
+	}
+
+	ping := time.NewTicker(livePing)
+	defer ping.Stop()
+
+	changes := map[string]string{}
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-gone:
+			return 0, nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return 0, nil
+			}
+
+			if filepath.Clean(event.Name) == local {
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "the directory is gone")
+					conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(liveWriteWait))
+					return 0, nil
+				}
+				continue
+			}
+
+			p := path.Join(dir, filepath.Base(event.Name))
+			op := liveOp(event.Op)
+			if op != "write" || changes[p] != "create" {
+				changes[p] = op
+			}
+
+			if flush == nil {
+				flush = time.After(d.settings.Live.Batching())
+			}
+		case <-flush:
+			flush = nil
+			events := liveEvents(r, d, dir, changes)
+			changes = map[string]string{}
+			if len(events) == 0 {
+				continue
+			}
+
+			if err := send(events); err != nil {
+				return 0, nil
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return 0, nil
+			}
+
+			// The watcher may have missed changes, such as when its queue
+			// overflows.
+			d.logger.Debug("the live updates missed changes", "path", dir, "error", err)
+			if err := send([]liveEvent{{Op: "reload"}}); err != nil {
+				return 0, nil
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait)); err != nil {
+				return 0, nil
+			}
+		}
+	}
+})
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/text/language"
+)
+
+// locales are the locales the frontend has translations for. The
+// first one is used when nothing else matches.
+var locales = []string{
+	"en", "ar", "de", "es", "fr", "is", "it", "ja", "ko", "nl-be",
+	"pl", "pt", "pt-br", "ro", "ru", "sv-se", "zh-cn", "zh-tw",
+}
+
+var localeMatcher = func() language.Matcher {
+	tags := make([]language.Tag, len(locales))
+	for i, locale := range locales {
+		tags[i] = language.Make(locale)
+	}
+
+	return language.NewMatcher(tags)
+}()
+
+// maxCachedLocales bounds the cache of parsed Accept-Language headers
+// since their values come from the clients.
+const maxCachedLocales = 256
+
+var localeCache = struct {
+	hits, misses uint64
+
+	sync.RWMutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// matchLocale returns the supported locale that best fits the given
+// Accept-Language header. Regional variants fall back to their base
+// language, such as de-AT to de. It returns an empty string if no
+// locale fits.
+func matchLocale(header string) string {
+	localeCache.RLock()
+	locale, ok := localeCache.m[header]
+	localeCache.RUnlock()
+	if ok {
+		atomic.AddUint64(&localeCache.hits, 1)
+		return locale
+	}
+	atomic.AddUint64(&localeCache.misses, 1)
+
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err == nil && len(tags) > 0 {
+		_, i, confidence := localeMatcher.Match(tags...)
+		if confidence != language.No {
+			locale = locales[i]
+		}
+	}
+
+	localeCache.Lock()
+	if len(localeCache.m) >= maxCachedLocales {
+		localeCache.m = map[string]string{}
+	}
+	localeCache.m[header] = locale
+	localeCache.Unlock()
+
+	return locale
+}
+
+func localeCacheStatus() cacheStatus {
+	localeCache.RLock()
+	size := len(localeCache.m)
+	localeCache.RUnlock()
+
+	return newCacheStatus(size, atomic.LoadUint64(&localeCache.hits), atomic.LoadUint64(&localeCache.misses))
+}
+
+// detectLocale picks the locale for a request from the lang query
+// parameter or the Accept-Language header, returning fallback if
+// neither of them match a supported locale.
+func detectLocale(r *http.Request, fallback string) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if locale := matchLocale(strings.ToLower(lang)); locale != "" {
+			return locale
+		}
+	}
+
+	if header := r.Header.Get("Accept-Language"); header != "" {
+		if locale := matchLocale(header); locale != "" {
+			return locale
+		}
+	}
+
+	return fallback
+}
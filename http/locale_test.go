@@ -0,0 +1,107 @@
+package http
+
+import (
+	"bytes"
+	"html/template"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDetectLocale(t *testing.T) {
+	tests := []struct {
+		lang   string
+		header string
+		want   string
+	}{
+		{"", "", "en"},
+		{"", "de-AT", "de"},
+		{"", "de-AT,de;q=0.9,en;q=0.8", "de"},
+		{"", "pt-BR", "pt-br"},
+		{"", "pt-PT,pt;q=0.9", "pt"},
+		{"", "fr-CA;q=0.5,it;q=0.9", "it"},
+		{"", "zh-Hant-TW", "zh-tw"},
+		{"", "xx-YY", "en"},
+		{"", "*", "en"},
+		{"", "not a header;;", "en"},
+		{"ja", "de", "ja"},
+		{"JA", "de", "ja"},
+		{"xx", "de", "de"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lang+" "+tt.header, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?lang="+tt.lang, nil)
+			r.Header.Set("Accept-Language", tt.header)
+			if got := detectLocale(r, "en"); got != tt.want {
+				t.Errorf("detectLocale() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchLocaleCache(t *testing.T) {
+	const header = "sv-SE,sv;q=0.9,en-US;q=0.5"
+	hits := atomic.LoadUint64(&localeCache.hits)
+
+	for i := 0; i < 3; i++ {
+		if got := matchLocale(header); got != "sv-se" {
+			t.Fatalf("matchLocale() = %q, want sv-se", got)
+		}
+	}
+
+	if got := atomic.LoadUint64(&localeCache.hits) - hits; got < 2 {
+		t.Errorf("the header was parsed again: %d hits, want 2", got)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en", "Search"},
+		{"pt", "Pesquisar"},
+		{"pt-br", "Pesquisar"}, // from pt
+		{"de-at", "Search"},    // from en, through de
+		{"xx", "Search"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			if got := translate(messages(tt.locale), "search"); got != tt.want {
+				t.Errorf("the search string is %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageMessages(t *testing.T) {
+	msgs := pageMessages{"greeting": "Hello {0}, {1}"}
+
+	// Every page translates with the T of the strings it embeds.
+	pages := []struct {
+		name string
+		page interface{}
+	}{
+		{"listing", &listingPage{pageMessages: msgs}},
+		{"error", &errorPage{pageMessages: msgs}},
+		{"landing", &landing{pageMessages: msgs}},
+		{"disk usage", &diskUsagePage{pageMessages: msgs}},
+		{"diff", &diffPage{pageMessages: msgs}},
+		{"stats", &statsPage{pageMessages: msgs}},
+	}
+
+	tpl := template.Must(template.New("page").Parse(`{{ .T "greeting" "Ann" 2 }} {{ .T "missing" }}`))
+	for _, tt := range pages {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tpl.Execute(&buf, tt.page); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := buf.String(), "Hello Ann, 2 missing"; got != want {
+				t.Errorf("the page is %q, want %q", got, want)
+			}
+		})
+	}
+}
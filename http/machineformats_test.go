@@ -0,0 +1,52 @@
+package http_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestMachineFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		allowed int // the status of the users with every format
+		html    int // the status of the users with MachineFormats html
+		reject  int // the status of the users with MachineFormats reject
+	}{
+		{"JSON", "GET", "/api/resources/?format=json", http.StatusOK, http.StatusOK, http.StatusNotAcceptable},
+		{"text", "GET", "/api/resources/?format=text", http.StatusOK, http.StatusOK, http.StatusNotAcceptable},
+		{"CSV", "GET", "/api/resources/?format=csv", http.StatusOK, http.StatusOK, http.StatusNotAcceptable},
+		{"HTML", "GET", "/api/resources/?format=html", http.StatusOK, http.StatusOK, http.StatusOK},
+		{"duplicates", "GET", "/api/resources/?duplicates=true&format=text", http.StatusOK, http.StatusOK, http.StatusNotAcceptable},
+		{"statistics", "GET", "/api/resources/?stats=true&format=text", http.StatusOK, http.StatusOK, http.StatusNotAcceptable},
+		{"tree", "GET", "/api/resources/?tree=true", http.StatusOK, http.StatusNotFound, http.StatusNotFound},
+		{"file", "GET", "/api/resources/a.txt", http.StatusOK, http.StatusNotFound, http.StatusNotFound},
+		{"search", "GET", "/api/search/?query=a", http.StatusOK, http.StatusNotFound, http.StatusNotFound},
+		{"WebDAV", "PROPFIND", "/dav/", http.StatusMultiStatus, http.StatusNotFound, http.StatusNotFound},
+	}
+
+	for _, mode := range []string{"", users.MachineFormatsHTML, users.MachineFormatsReject} {
+		for _, tt := range tests {
+			t.Run(mode+" "+tt.name, func(t *testing.T) {
+				srv, _ := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "a"}})
+				updateUser(t, srv, func(u *users.User) { u.MachineFormats = mode })
+
+				want := map[string]int{"": tt.allowed, users.MachineFormatsHTML: tt.html, users.MachineFormatsReject: tt.reject}[mode]
+				w := do(t, srv, tt.method, tt.target, "", "Depth", "1")
+				if w.Code != want {
+					t.Fatalf("%s %s = %d, want %d", tt.method, tt.target, w.Code, want)
+				}
+
+				contentType := w.Header().Get("Content-Type")
+				if mode != "" && w.Code == http.StatusOK && !strings.HasPrefix(contentType, "text/html") {
+					t.Errorf("%s %s is %s, want HTML", tt.method, tt.target, contentType)
+				}
+			})
+		}
+	}
+}
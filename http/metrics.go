@@ -0,0 +1,157 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/filebrowser/filebrowser/v2/metrics"
+)
+
+// handlerMetrics are the metrics of a Handler. They are created once, so
+// they survive the reloads. Their labels are bounded: they have the scope
+// of the user rather than the path of the request.
+type handlerMetrics struct {
+	registerer      metrics.Registerer
+	requests        metrics.Counter
+	listingDuration metrics.Histogram
+	listingItems    metrics.Histogram
+	cacheHits       metrics.Counter
+	cacheMisses     metrics.Counter
+	uploaded        metrics.Counter
+	downloaded      metrics.Counter
+	slow            metrics.Counter
+}
+
+func newHandlerMetrics(reg metrics.Registerer) *handlerMetrics {
+	return &handlerMetrics{
+		registerer: reg,
+		requests: reg.NewCounter("filebrowser_requests_total",
+			"Requests by scope, method, status and listing format.", "scope", "method", "status", "format"),
+		listingDuration: reg.NewHistogram("filebrowser_listing_duration_seconds",
+			"Time taken to read and sort the directories that are listed.", metrics.DurationBuckets, "scope"),
+		listingItems: reg.NewHistogram("filebrowser_listing_items",
+			"Items of the directories that are listed.", metrics.CountBuckets, "scope"),
+		cacheHits: reg.NewCounter("filebrowser_template_cache_hits_total",
+			"Custom templates found parsed in the cache."),
+		cacheMisses: reg.NewCounter("filebrowser_template_cache_misses_total",
+			"Custom templates parsed because they weren't cached or had changed."),
+		uploaded: reg.NewCounter("filebrowser_uploaded_bytes_total",
+			"Bytes written to files by uploads and WebDAV.", "scope"),
+		downloaded: reg.NewCounter("filebrowser_downloaded_bytes_total",
+			"Bytes of files and archives sent by downloads and WebDAV.", "scope"),
+		slow: reg.NewCounter("filebrowser_slow_operations_total",
+			"Listings, renders and writes that took longer than their threshold.", "kind"),
+	}
+}
+
+// metricMethods are the methods counted by name. The others are counted
+// as OTHER, so the clients can't make up labels.
+var metricMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+	"PROPFIND":         true,
+	"PROPPATCH":        true,
+	"MKCOL":            true,
+	"COPY":             true,
+	"MOVE":             true,
+	"LOCK":             true,
+	"UNLOCK":           true,
+}
+
+// scope is the scope label of the request, which is empty for the ones
+// without a user.
+func (d *data) scope() string {
+	if d.user == nil {
+		return ""
+	}
+
+	return d.user.Scope
+}
+
+// countRequest counts a request that was answered with status.
+func (d *data) countRequest(r *http.Request, status int) {
+	method := r.Method
+	if !metricMethods[method] {
+		method = "OTHER"
+	}
+
+	d.metrics.requests.Add(1, d.scope(), method, strconv.Itoa(status), d.format)
+}
+
+// countDownload returns w counting the bytes written to it as downloaded.
+func (d *data) countDownload(w http.ResponseWriter) http.ResponseWriter {
+	d.download = true
+	return &countingWriter{ResponseWriter: w, count: func(n int) {
+		d.metrics.downloaded.Add(float64(n), d.scope())
+	}}
+}
+
+// countUpload returns body counting the bytes read from it as uploaded.
+func (d *data) countUpload(body io.ReadCloser) io.ReadCloser {
+	return &countingReader{ReadCloser: body, count: func(n int) {
+		d.metrics.uploaded.Add(float64(n), d.scope())
+	}}
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	count func(n int)
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.count(n)
+	return n, err
+}
+
+// Flush implements http.Flusher for the archives that are streamed.
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	count func(n int)
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count(n)
+	return n, err
+}
+
+// metricsClient tells if the client of r is in MetricsAllow, or on the
+// loopback interface if it is empty.
+func metricsClient(r *http.Request, d *data) bool {
+	ip := d.clientIP(r)
+	if len(d.server.MetricsAllow) == 0 {
+		return ip != nil && ip.IsLoopback()
+	}
+
+	return trustedIP(ip, d.server.MetricsAllow)
+}
+
+// metricsHandler serves the metrics to the metrics clients. They are only
+// served if they are kept by the handler: the ones registered on the
+// registry of an embedding program are served by it.
+func metricsHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !metricsClient(r, d) {
+		return http.StatusForbidden, nil
+	}
+
+	handler, ok := d.metrics.registerer.(http.Handler)
+	if !ok {
+		return http.StatusNotFound, nil
+	}
+
+	handler.ServeHTTP(w, r)
+	return 0, nil
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// maxSniffed bounds the number of sniffed MIME types cached.
+const maxSniffed = 4096
+
+// sniffed caches the MIME types sniffed from the contents of the files,
+// by their full path and modification time.
+var sniffed = struct {
+	sync.Mutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// mimeType returns the MIME type of file: the one of the overrides of the
+// settings or of its extension, or the one sniffed from its contents if
+// neither is known. It returns files.DirMimeType for the directories, and
+// an empty string for the special files and the ones that can't be read.
+func mimeType(d *data, file *files.FileInfo) string {
+	if file.IsDir {
+		return files.DirMimeType
+	}
+
+	if files.SpecialKind(file.Mode) != "" {
+		return ""
+	}
+
+	if t := files.MimeTypeByName(file.Name, d.settings.MimeTypes); t != "" {
+		return t
+	}
+
+	key := d.user.FullPath(file.Path) + "\x00" + strconv.FormatInt(file.ModTime.UnixNano(), 10)
+
+	sniffed.Lock()
+	t, ok := sniffed.m[key]
+	sniffed.Unlock()
+	if ok {
+		return t
+	}
+
+	t, err := files.SniffMimeType(file.Fs, file.Path)
+	if err != nil {
+		return ""
+	}
+
+	sniffed.Lock()
+	if len(sniffed.m) >= maxSniffed {
+		sniffed.m = map[string]string{}
+	}
+	sniffed.m[key] = t
+	sniffed.Unlock()
+
+	return t
+}
+
+// annotateMimeTypes sets the MIME types of the files of the listing.
+func annotateMimeTypes(d *data, listing *files.Listing) {
+	for _, item := range listing.Items {
+		if !item.Error {
+			item.MimeType = mimeType(d, item)
+		}
+	}
+}
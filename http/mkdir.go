@@ -0,0 +1,90 @@
+package http
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// makeDir makes the directory at p with the mode of the settings, and the
+// missing directories above it with parents, like mkdir -p. An existing
+// path fails with errors.ErrExist.
+func makeDir(d *data, p string, parents bool) error {
+	if _, err := d.user.Fs.Stat(p); err == nil {
+		return errors.ErrExist
+	}
+
+	err := d.RunHook(func() error {
+		if parents {
+			return d.user.Fs.MkdirAll(p, d.settings.NewDirMode())
+		}
+		return d.user.Fs.Mkdir(p, d.settings.NewDirMode())
+	}, "mkdir", p, "", -1, d.user)
+	if err != nil {
+		return err
+	}
+
+	d.notify(settings.EventMkdir, p, "", 0)
+	return nil
+}
+
+// isFormPost checks if the request was sent by an HTML form.
+func isFormPost(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data")
+}
+
+// mkdirHandler makes a directory: the requested path for MKCOL, or the
+// name form value in the requested directory for the POST requests with
+// action=mkdir. The name can have several elements, such as a/b/c, which
+// need parents=true unless the directories above the last one exist.
+// MKCOL answers with 201, like WebDAV, the forms of the HTML listings go
+// back to the listing, and the other POST requests get the directory as
+// a row of the listings.
+func mkdirHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	dir := path.Clean("/" + r.URL.Path)
+	p := dir
+	if r.Method == http.MethodPost {
+		name := strings.Trim(r.FormValue("name"), "/")
+		for _, elem := range strings.Split(name, "/") {
+			if !validName(elem) {
+				return renderFailure(w, r, http.StatusBadRequest, fmt.Sprintf("invalid name %q", name))
+			}
+		}
+		p = path.Join(dir, name)
+	}
+
+	if p == "/" || !d.capabilities(p).CanMkdir {
+		return http.StatusForbidden, nil
+	}
+
+	err := makeDir(d, p, r.URL.Query().Get("parents") == "true")
+	switch {
+	case err == errors.ErrExist:
+		return renderFailure(w, r, http.StatusConflict, "already exists")
+	case err != nil:
+		return errToStatus(err), err
+	}
+
+	switch {
+	case r.Method != http.MethodPost:
+		w.WriteHeader(http.StatusCreated)
+		return 0, nil
+	case isFormPost(r) && !strings.Contains(r.Header.Get("Accept"), "application/json"):
+		target := d.baseURL(r) + pathJoinURL("/api/resources", dir, "/")
+		if query := keptQuery(r); len(query) > 0 {
+			target += "?" + query.Encode()
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return 0, nil
+	}
+
+	return renderListed(w, r, d, p)
+}
+
+var mkcolHandler = withUser(mkdirHandler)
@@ -0,0 +1,245 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	fbErrors "github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// isMultipartUpload checks if the request uploads its files as the parts
+// of a multipart form.
+func isMultipartUpload(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// partFileName returns the file name of a part as the client sent it.
+// The one of multipart.Part is only its last element, which would hide
+// the names with separators rather than reject them.
+func partFileName(header map[string][]string) string {
+	values := header["Content-Disposition"]
+	if len(values) == 0 {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(values[0])
+	if err != nil {
+		return ""
+	}
+
+	return params["filename"]
+}
+
+// uploadPartsHandler writes the files of the parts of a multipart form
+// into the requested directory, and writes them back as rows of the
+// listings with 201. Each part is streamed to its file as it's read, so
+// the parts before one that fails are kept. The parts without a file
+// name, such as the other fields of the form, are skipped. The parts
+// over the maximum size of the uploads fail with 413, and the ones whose
+// extensions aren't allowed with 415, naming them.
+func uploadPartsHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	dir := path.Clean("/" + r.URL.Path)
+	info, err := d.user.Fs.Stat(dir)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return renderFailure(w, r, http.StatusBadRequest, "the files of the forms are uploaded to directories")
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	overwrite := overwriteRequested(r)
+	created := []*listedFile{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		name := partFileName(part.Header)
+		if name == "" {
+			part.Close()
+			continue
+		}
+
+		if !validName(name) || strings.ContainsAny(name, `/\`) {
+			part.Close()
+			return renderFailure(w, r, http.StatusBadRequest, fmt.Sprintf("invalid name %q", name))
+		}
+
+		p := path.Join(dir, name)
+		if !d.capabilities(p).CanUpload {
+			part.Close()
+			return http.StatusForbidden, nil
+		}
+
+		if !uploadAllowed(d, p) {
+			part.Close()
+			return renderFileFailure(w, r, http.StatusUnsupportedMediaType, name, refusedExtension)
+		}
+
+		if !overwrite {
+			if _, err := d.user.Fs.Stat(p); err == nil {
+				part.Close()
+				return renderFailure(w, r, http.StatusConflict, fmt.Sprintf("%q already exists", name))
+			}
+		}
+
+		_, err = writeUpload(d, p, part, -1)
+		part.Close()
+		if err == fbErrors.ErrTooLarge {
+			return renderFileFailure(w, r, http.StatusRequestEntityTooLarge, name, err.Error())
+		} else if err != nil {
+			return errToStatus(err), err
+		}
+
+		file, err := newListedFile(r, d, p)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		created = append(created, file)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	return 0, json.NewEncoder(w).Encode(created)
+}
+
+// writeUpload writes body, of size bytes or -1 if it's unknown, to the
+// file at p through the hooks of the uploads, and returns the info of
+// the file. The files that are replaced keep their modes. The bodies
+// over the maximum size of the uploads fail with errors.ErrTooLarge, and
+// leave p as it was.
+func writeUpload(d *data, p string, body io.Reader, size int64) (os.FileInfo, error) {
+	release, err := lockPath(d, p)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var info os.FileInfo
+	err = d.RunHook(func() error {
+		var mode os.FileMode
+		if old, err := d.user.Fs.Stat(p); err == nil {
+			mode = old.Mode().Perm()
+		}
+
+		info, err = writeTemp(d, p, ".upload-", countUploaded(d, body), mode, d.settings.Uploads.MaxSize)
+		return err
+	}, "upload", p, "", size, d.user)
+	if err != nil {
+		return nil, err
+	}
+
+	d.notify(settings.EventUpload, p, "", info.Size())
+	return info, nil
+}
+
+// writeTemp writes body to a temporary file next to p, whose name starts
+// with prefix, and renames it to p once it's complete, so p is never
+// seen half written and the writes that fail leave nothing behind. The
+// temporary file has mode, or the mode of the new files if it's zero.
+// The bodies over limit bytes, when it's positive, fail with
+// errors.ErrTooLarge, and so do the ones http.MaxBytesReader cuts.
+func writeTemp(d *data, p, prefix string, body io.Reader, mode os.FileMode, limit int64) (os.FileInfo, error) {
+	var random [8]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return nil, err
+	}
+
+	perm := mode
+	if perm == 0 {
+		perm = 0775
+	}
+
+	name := path.Join(path.Dir(p), prefix+hex.EncodeToString(random[:]))
+	tmp, err := d.user.Fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	// One more byte tells the bodies that are too long.
+	if limit > 0 {
+		body = io.LimitReader(body, limit+1)
+	}
+
+	n, err := io.Copy(tmp, body)
+	if isBodyTooLarge(err) || err == nil && limit > 0 && n > limit {
+		err = fbErrors.ErrTooLarge
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && mode != 0 {
+		// The mode the file is created with is masked.
+		err = d.user.Fs.Chmod(name, mode)
+	}
+	if err == nil {
+		err = d.user.Fs.Rename(name, p)
+	}
+	if err != nil {
+		d.user.Fs.Remove(name)
+		return nil, err
+	}
+
+	return d.user.Fs.Stat(p)
+}
+
+// uploadedCounter counts the bytes read from the bodies of the uploads in
+// the metrics.
+type uploadedCounter struct {
+	io.Reader
+	d *data
+}
+
+func (c uploadedCounter) Read(b []byte) (int, error) {
+	n, err := c.Reader.Read(b)
+	c.d.metrics.uploaded.Add(float64(n), c.d.scope())
+	return n, err
+}
+
+// countUploaded returns body counting the bytes read from it as uploaded.
+func countUploaded(d *data, body io.Reader) io.Reader {
+	return uploadedCounter{body, d}
+}
+
+// uploadAllowed checks if the file at p can be uploaded with the
+// extensions of the settings, once its name is normalized the way it's
+// written.
+func uploadAllowed(d *data, p string) bool {
+	return d.settings.Uploads.AllowsName(norm.NFC.String(path.Base(p)))
+}
+
+// uploadLimit returns limit, lowered to the maximum size of the uploads
+// if it's smaller. Zero is no limit for both.
+func uploadLimit(d *data, limit int64) int64 {
+	if max := d.settings.Uploads.MaxSize; max > 0 && (limit <= 0 || max < limit) {
+		return max
+	}
+
+	return limit
+}
+
+// refusedExtension is the reason of the uploads refused for the
+// extensions of their files.
+const refusedExtension = "the extension of the file isn't allowed"
@@ -0,0 +1,54 @@
+package http_test
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+func TestMultipartOverwrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		replaced bool
+	}{
+		{"kept by default", "", http.StatusConflict, false},
+		{"overwrite", "?overwrite=true", http.StatusCreated, true},
+		{"override", "?override=true", http.StatusCreated, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, map[string]filebrowsertest.File{"/docs/a.txt": {Content: "old"}})
+
+			body := &bytes.Buffer{}
+			form := multipart.NewWriter(body)
+			part, err := form.CreateFormFile("file", "a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte("new"))
+			form.Close()
+
+			w := do(t, srv, "POST", "/api/resources/docs/"+tt.query, body.String(), "Content-Type", form.FormDataContentType())
+			if w.Code != tt.status {
+				t.Fatalf("POST = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			content, err := afero.ReadFile(fs, "/docs/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if replaced := string(content) == "new"; replaced != tt.replaced {
+				t.Errorf("the file has %q, want replaced: %v", content, tt.replaced)
+			}
+		})
+	}
+}
@@ -0,0 +1,466 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/share"
+	"github.com/filebrowser/filebrowser/v2/users"
+	"github.com/filebrowser/filebrowser/v2/version"
+)
+
+// apiOperation describes a route of the API in its OpenAPI document.
+// Request and Response are values of the types of the JSON bodies, or
+// strings with the media types of the other bodies, and nil when there
+// is no body. Prefix routes serve everything under them, which is the
+// last parameter of Path, such as {path}.
+type apiOperation struct {
+	ID       string
+	Method   string
+	Path     string
+	Prefix   bool
+	Summary  string
+	Public   bool
+	Query    map[string]string
+	Request  interface{}
+	Response interface{}
+}
+
+// loginBody is the body of the logins with the JSON auth method.
+type loginBody struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	ReCaptcha string `json:"recaptcha"`
+}
+
+// apiOperations are the routes of the API. Building the router fails if
+// it has a route under /api that isn't here, or the other way around, so
+// the document can't drift from the handlers.
+var apiOperations = []apiOperation{
+	{ID: "getOpenAPI", Method: "GET", Path: "/api/openapi.json", Summary: "Get this document", Response: map[string]interface{}{}},
+	{ID: "login", Method: "POST", Path: "/api/login", Summary: "Log in and get a token", Public: true, Request: loginBody{}, Response: "text/plain"},
+	{ID: "signup", Method: "POST", Path: "/api/signup", Summary: "Sign up, when it is allowed", Public: true, Request: signupBody{}},
+	{ID: "renew", Method: "POST", Path: "/api/renew", Summary: "Renew the token", Response: "text/plain"},
+	{ID: "setTheme", Method: "GET", Path: "/api/theme", Summary: "Set the theme of the HTML listings and go back", Public: true,
+		Query: map[string]string{"theme": "light, dark or auto"}},
+	{ID: "getTranslations", Method: "GET", Path: "/api/translations", Summary: "Get the strings of the HTML listings", Public: true,
+		Query: map[string]string{"lang": "the locale"}, Response: map[string]string{}},
+
+	{ID: "listUsers", Method: "GET", Path: "/api/users", Summary: "List the users", Response: []*users.User{}},
+	{ID: "createUser", Method: "POST", Path: "/api/users", Summary: "Create a user", Request: modifyUserRequest{}},
+	{ID: "getUser", Method: "GET", Path: "/api/users/{id}", Summary: "Get a user", Response: users.User{}},
+	{ID: "updateUser", Method: "PUT", Path: "/api/users/{id}", Summary: "Update the fields of a user in which", Request: modifyUserRequest{}},
+	{ID: "deleteUser", Method: "DELETE", Path: "/api/users/{id}", Summary: "Delete a user"},
+
+	{ID: "getResource", Method: "GET", Path: "/api/resources/{path}", Prefix: true,
+		Summary: "Get a file, or the listing of a directory",
+		Query: map[string]string{
+			"sort":          "name, size, modified or type, or none for the order of the filesystem, which only reads the entries of the page",
+			"order":         "asc or desc",
+			"limit":         "the number of items of the listings",
+			"offset":        "the position of the first item of the page of the listings",
+			"format":        "json, text, html, xml, csv or rss, whose feeds are the newest files up to the feed limit of the settings",
+			"checksum":      "md5, sha1, sha256 or sha512 to get the checksum of a file, or the ones of the files of a JSON listing up to the maximum size of the settings",
+			"recent":        "only list the files under the directory changed in this window, such as 7d",
+			"tags":          "true to get the tags of the items",
+			"tag":           "only list the items with this tag",
+			"mime":          "true to get the MIME types of the items, inode/directory for the directories",
+			"docmeta":       "true to get the titles, authors and numbers of pages of the PDFs and the OOXML documents",
+			"meta":          "true to only get the title, author and number of pages of a PDF or an OOXML document",
+			"tree":          "true to get the tree of the directories",
+			"changes_since": "only list the entries changed after this instant",
+			"du":            "true to get the sizes of the directories",
+			"stats":         "true to get the numbers and sizes of the files of a directory by type and size",
+			"recursive":     "true to count the files of the directories below too in the statistics, or to get the children of the directories of the JSON listings",
+			"depth":         "the number of levels of the trees, the maximum by default, or of the children of the recursive listings, 1 by default",
+			"largest":       "the number of the largest files of the statistics",
+			"duplicates":    "true to get the groups of identical files",
+			"diff":          "the path of a text file to compare the file with, relative to its directory",
+			"download":      "zip or targz to download an archive of the contents of a directory, or true to download a file as an attachment",
+			"content":       "true to get the contents of a file, in base64 if they aren't UTF-8 text",
+			"showhidden":    "true or false to list the files whose names start with a dot, or not, whatever the settings",
+			"dirsfirst":     "true or false to list the directories before the files, or not, whatever the settings",
+			"filter":        "a glob, with the syntax of path.Match, that the names of the files must match, whose counts are the ones of the matching files",
+			"filterdirs":    "true to filter the directories too rather than keeping them all",
+			"dirsizes":      "true or false to list the directories with the sizes of their contents, or not, whatever the settings",
+			"search":        "the names to look for under the directory, whose results replace the items, up to the search limit of the settings",
+			"nocache":       "true to read the listing from the disk rather than from the cache of the listings",
+		},
+		Response: files.FileInfo{}},
+	{ID: "getResourceHeaders", Method: "HEAD", Path: "/api/resources/{path}", Prefix: true, Summary: "Get the headers of a file or of the listing of a directory, such as their length, or, with an X-Upload-ID, the Upload-Offset of its chunked upload"},
+	{ID: "deleteResource", Method: "DELETE", Path: "/api/resources/{path}", Prefix: true, Summary: "Delete a file or a directory",
+		Query: map[string]string{
+			"recursive": "true to delete a directory that isn't empty with a strict request",
+			"strict":    "true to fail with 409 for the directories that aren't empty, and with 404 if the file doesn't exist, rather than succeeding, and to answer with 204",
+			"dryRun":    "true to get the report of what would be deleted without deleting anything",
+			"purge":     "true to remove the file rather than moving it to the trash directory of the settings",
+		},
+		Response: bulkResponse{}},
+	{ID: "uploadResource", Method: "POST", Path: "/api/resources/{path}", Prefix: true,
+		Summary: "Upload a file, create a directory if the path ends with a slash, or upload the files of a multipart form into a directory",
+		Query: map[string]string{
+			"overwrite": "true to replace the existing files, or to keep the existing directories of the strict requests",
+			"override":  "the older name of overwrite",
+			"strict":    "true to fail with 409 if the directory to create exists rather than keeping it",
+			"action": "fetch to fetch the URL of a JSON body, with url, filename and checksum, into the directory, " +
+				"verify to verify the files of the directory against the sums file of the body and get a JSON report, " +
+				"archive to download a zip of the files of the directory whose relative paths are in the JSON array of the body, " +
+				"mkdir to make the directory of the name form value in the directory, " +
+				"or restore to move a file of the trash back to where it was deleted from",
+			"parents": "true to make the missing directories above the one of action=mkdir",
+		},
+		Request: "application/octet-stream", Response: listedFile{}},
+	{ID: "replaceResource", Method: "PUT", Path: "/api/resources/{path}", Prefix: true,
+		Summary: "Replace the contents of a file at once, or create it, or upload it in chunks with a Content-Range and an X-Upload-ID; an If-Match or an If-Unmodified-Since makes it fail with 412 if the file changed, and an If-None-Match of * if it exists", Request: "application/octet-stream"},
+	{ID: "moveResource", Method: "PATCH", Path: "/api/resources/{path}", Prefix: true, Summary: "Rename or copy a file or a directory, with the query parameters or a JSON body, or change its mode with a JSON body with an octal mode; the copies and the changes of mode answer with the paths that failed",
+		Query: map[string]string{
+			"action":      "rename or copy",
+			"destination": "the new path",
+			"crossScope":  "true to move the file to another area of the scope, such as another alias",
+			"overwrite":   "true to replace the destination of the strict requests",
+			"override":    "the older name of overwrite",
+			"strict":      "true to fail with 409 if the destination exists rather than replacing it, as the requests with a JSON body without overwrite do",
+		},
+		Request: patchRequest{}, Response: listedFile{}},
+	{ID: "makeDirectory", Method: "MKCOL", Path: "/api/resources/{path}", Prefix: true, Summary: "Make a directory, like WebDAV",
+		Query: map[string]string{
+			"parents": "true to make the missing directories above it",
+		}},
+	{ID: "getResourceMethods", Method: "OPTIONS", Path: "/api/resources/{path}", Prefix: true, Public: true,
+		Summary: "Get the methods the files can be requested with in Allow, and the CORS headers of the preflights of the allowed origins"},
+
+	{ID: "bulk", Method: "POST", Path: "/api/bulk", Summary: "Act on several files at once, with the X-CSRF-Token header of the listings",
+		Request: bulkRequest{}, Response: bulkResponse{}},
+	{ID: "batchRename", Method: "POST", Path: "/api/rename/{path}", Prefix: true, Summary: "Rename several files of a directory with a pattern",
+		Request: renameRequest{}, Response: renameResponse{}},
+	{ID: "getUploadProgress", Method: "GET", Path: "/api/uploads/{id}", Prefix: true, Summary: "Get the progress of an upload", Response: uploadProgress{}},
+
+	{ID: "listShares", Method: "GET", Path: "/api/share/{path}", Prefix: true, Summary: "List the share links of a path", Response: []*share.Link{}},
+	{ID: "createShare", Method: "POST", Path: "/api/share/{path}", Prefix: true, Summary: "Share a path",
+		Query:    map[string]string{"expires": "the number of units the link lasts", "unit": "seconds, minutes, hours or days"},
+		Response: sharedLink{}},
+	{ID: "deleteShare", Method: "DELETE", Path: "/api/share/{path}", Prefix: true, Summary: "Delete the share link whose hash is path"},
+
+	{ID: "getSettings", Method: "GET", Path: "/api/settings", Summary: "Get the settings", Response: settingsData{}},
+	{ID: "updateSettings", Method: "PUT", Path: "/api/settings", Summary: "Update the settings", Request: settingsData{}},
+
+	{ID: "download", Method: "GET", Path: "/api/raw/{path}", Prefix: true, Summary: "Download a file, or a directory as an archive",
+		Query: map[string]string{"algo": "zip, tar, targz, tarbz2, tarxz, tarlz4 or tarsz", "files": "the files of the archive", "inline": "true to show the file in the browser",
+			"w": "the width to resize the image to", "h": "the height to resize the image to", "fit": "inside or cover", "fmt": "jpeg or png",
+			"thumb": "the size of the box the JPEG thumbnail of the image fits in"},
+		Response: "application/octet-stream"},
+	{ID: "downloadHeaders", Method: "HEAD", Path: "/api/raw/{path}", Prefix: true, Summary: "Get the headers of the download of a file, such as its size and ETag"},
+	{ID: "createArchive", Method: "POST", Path: "/api/raw/{path}", Prefix: true, Summary: "Build the archive of a directory in the background",
+		Query:    map[string]string{"algo": "zip, tar, targz, tarbz2, tarxz, tarlz4 or tarsz", "files": "the files of the archive"},
+		Response: archiveStatus{}},
+	{ID: "listArchives", Method: "GET", Path: "/api/archives", Summary: "List the archives built in the background", Response: []archiveStatus{}},
+	{ID: "getArchive", Method: "GET", Path: "/api/archives/{id}", Summary: "Get the status of an archive built in the background", Response: archiveStatus{}},
+	{ID: "deleteArchive", Method: "DELETE", Path: "/api/archives/{id}", Summary: "Cancel an archive, or remove it once built"},
+	{ID: "downloadArchive", Method: "GET", Path: "/api/archives/{id}/file", Summary: "Download an archive built in the background", Response: "application/octet-stream"},
+	{ID: "downloadArchiveHeaders", Method: "HEAD", Path: "/api/archives/{id}/file", Summary: "Get the headers of the download of an archive built in the background"},
+	{ID: "runCommand", Method: "GET", Path: "/api/command/{path}", Prefix: true, Summary: "Run commands through a WebSocket"},
+	{ID: "watchDirectory", Method: "GET", Path: "/api/live/{path}", Prefix: true,
+		Summary: "Get the changes of a directory as they happen through a WebSocket, as arrays of create, remove, rename, write and reload events",
+		Query:   map[string]string{"showhidden": "true or false to get the changes of the files whose names start with a dot, or not, whatever the settings"}},
+	{ID: "search", Method: "GET", Path: "/api/search/{path}", Prefix: true, Summary: "Search under a directory",
+		Query: map[string]string{"query": "what to look for", "limit": "the number of results"}, Response: []searchResult{}},
+	{ID: "getTags", Method: "GET", Path: "/api/tags/{path}", Prefix: true, Summary: "Get the tags of a file", Response: []string{}},
+	{ID: "setTags", Method: "PUT", Path: "/api/tags/{path}", Prefix: true, Summary: "Set the tags of a file", Request: []string{}, Response: []string{}},
+	{ID: "listFavorites", Method: "GET", Path: "/api/favorites", Prefix: true, Summary: "List the favorites", Response: []files.Favorite{}},
+	{ID: "pinFavorite", Method: "POST", Path: "/api/favorites/{path}", Prefix: true, Summary: "Pin a path", Response: []files.Favorite{}},
+	{ID: "unpinFavorite", Method: "DELETE", Path: "/api/favorites/{path}", Prefix: true, Summary: "Unpin a path", Response: []files.Favorite{}},
+
+	{ID: "downloadShared", Method: "GET", Path: "/api/public/dl/{hash}", Prefix: true, Summary: "Download a shared file or directory", Public: true,
+		Query: map[string]string{"w": "the width to resize the image to", "h": "the height to resize the image to", "fit": "inside or cover", "fmt": "jpeg or png",
+			"thumb": "the size of the box the JPEG thumbnail of the image fits in"},
+		Response: "application/octet-stream"},
+	{ID: "downloadSharedHeaders", Method: "HEAD", Path: "/api/public/dl/{hash}", Prefix: true, Summary: "Get the headers of the download of a shared file", Public: true},
+	{ID: "getShared", Method: "GET", Path: "/api/public/share/{hash}", Prefix: true, Summary: "Get a shared file or directory", Public: true,
+		Response: files.FileInfo{}},
+	{ID: "getShareQR", Method: "GET", Path: "/api/public/qr/{hash}", Prefix: true, Summary: "Get the QR code of a share link", Public: true,
+		Query: map[string]string{"size": "the size of the image in pixels"}, Response: "image/png"},
+}
+
+// pathParam matches the parameters of the paths of the operations.
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPIMethods are the methods OpenAPI knows. The others, such as the
+// ones of WebDAV, are documented as extensions.
+var openAPIMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+func documentedMethod(method string) string {
+	method = strings.ToLower(method)
+	if !openAPIMethods[method] {
+		return "x-" + method
+	}
+
+	return method
+}
+
+// openAPIDocument returns the OpenAPI document of the API served under
+// baseURL.
+func openAPIDocument(baseURL string) ([]byte, error) {
+	schemas := newSchemaSet()
+	schemas.of(reflect.TypeOf(failure{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range apiOperations {
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][documentedMethod(op.Method)] = op.document(schemas)
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "File Browser",
+			"version": version.Version,
+		},
+		"servers": []map[string]string{{"url": baseURL + "/"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.schemas,
+			"securitySchemes": map[string]interface{}{
+				"token": map[string]string{"type": "apiKey", "in": "header", "name": "X-Auth"},
+			},
+		},
+		"security": []map[string][]string{{"token": {}}},
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// document returns the OpenAPI operation object of the operation.
+func (op apiOperation) document(schemas *schemaSet) map[string]interface{} {
+	params := []map[string]interface{}{}
+	for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]string{"type": "string"},
+		})
+	}
+
+	names := make([]string, 0, len(op.Query))
+	for name := range op.Query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		params = append(params, map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"description": op.Query[name],
+			"schema":      map[string]string{"type": "string"},
+		})
+	}
+
+	ok := map[string]interface{}{"description": "OK"}
+	if op.Response != nil {
+		ok["content"] = schemas.content(op.Response)
+	}
+
+	doc := map[string]interface{}{
+		"operationId": op.ID,
+		"summary":     op.Summary,
+		"responses": map[string]interface{}{
+			"200": ok,
+			"default": map[string]interface{}{
+				"description": "The failure, as JSON when the request accepts it",
+				"content":     schemas.content(failure{}),
+			},
+		},
+	}
+
+	if len(params) > 0 {
+		doc["parameters"] = params
+	}
+
+	if op.Request != nil {
+		doc["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  schemas.content(op.Request),
+		}
+	}
+
+	if op.Public {
+		doc["security"] = []map[string][]string{}
+	}
+
+	return doc
+}
+
+// schemaSet has the schemas of the named types of the document.
+type schemaSet struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{
+		schemas: map[string]interface{}{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+// content returns the content of a body, which is JSON unless v is a
+// media type.
+func (s *schemaSet) content(v interface{}) map[string]interface{} {
+	if mediaType, ok := v.(string); ok {
+		return map[string]interface{}{
+			mediaType: map[string]interface{}{
+				"schema": map[string]string{"type": "string", "format": "binary"},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": s.of(reflect.TypeOf(v)),
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// of returns the schema of the JSON encoding of the values of t. The
+// named structs are added to the set and referenced.
+func (s *schemaSet) of(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+
+		name, ok := s.names[t]
+		if !ok {
+			name = s.name(t)
+			s.names[t] = name
+			s.schemas[name] = nil
+			s.schemas[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	return map[string]interface{}{}
+}
+
+// name returns a name for the schema of t, prefixed with its package if
+// another type has the same name.
+func (s *schemaSet) name(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := s.schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	return name
+}
+
+// object returns the schema of a struct, with the fields of the embedded
+// structs as its own like encoding/json does.
+func (s *schemaSet) object(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	s.fields(t, props)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func (s *schemaSet) fields(t reflect.Type, props map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			s.fields(ft, props)
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		props[name] = s.of(field.Type)
+	}
+}
+
+// openAPI serves the OpenAPI document of the API. Its operations are
+// written by hand like the routes, and the tests check that they match.
+type openAPI struct {
+	doc []byte
+	err error
+}
+
+// newOpenAPI builds the document of the API served under baseURL.
+func newOpenAPI(baseURL string) *openAPI {
+	doc, err := openAPIDocument(baseURL)
+	return &openAPI{doc: doc, err: err}
+}
+
+// handler serves the document, except to the users that can't get the
+// machine readable formats.
+func (o *openAPI) handler() handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if apiOnly(d) {
+			return http.StatusNotFound, nil
+		}
+
+		if o.err != nil {
+			return http.StatusInternalServerError, o.err
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if _, err := w.Write(o.doc); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return 0, nil
+	})
+}
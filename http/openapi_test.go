@@ -0,0 +1,91 @@
+package http
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/gorilla/mux"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestOpenAPIOperations(t *testing.T) {
+	// The routes and the operations of the document are both written by
+	// hand, so a mismatch is a bug. The frontend isn't served, so it can
+	// be an empty box.
+	if err := checkOperations(routes(&Handler{assets: &rice.Box{}}, &settings.Server{})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	if _, err := openAPIDocument("/files"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// routeParam matches the parameters of the routes with their patterns,
+// such as {id:[0-9]+}.
+var routeParam = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// route returns the template of the route of the operation.
+func (op apiOperation) route() string {
+	if !op.Prefix || !strings.HasSuffix(op.Path, "}") {
+		return op.Path
+	}
+
+	return op.Path[:strings.LastIndex(op.Path, "/")]
+}
+
+// checkOperations checks that the routes of r under /api and the
+// operations match.
+func checkOperations(r *mux.Router) error {
+	described := map[string]bool{}
+	for _, op := range apiOperations {
+		described[op.Method+" "+op.route()] = true
+		described[op.route()] = true
+	}
+
+	routed := map[string]bool{}
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tpl, "/api/") || route.GetHandler() == nil {
+			return nil
+		}
+
+		tpl = routeParam.ReplaceAllString(tpl, "{$1}")
+		methods, err := route.GetMethods()
+		if err != nil {
+			// The routes without methods are described with the
+			// methods they are used with.
+			if !described[tpl] {
+				return fmt.Errorf("route %s isn't in the OpenAPI document", tpl)
+			}
+			routed[tpl] = true
+			return nil
+		}
+
+		for _, method := range methods {
+			if !described[method+" "+tpl] {
+				return fmt.Errorf("route %s %s isn't in the OpenAPI document", method, tpl)
+			}
+			routed[method+" "+tpl] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, op := range apiOperations {
+		if !routed[op.Method+" "+op.route()] && !routed[op.route()] {
+			return fmt.Errorf("operation %s %s of the OpenAPI document has no route", op.Method, op.Path)
+		}
+	}
+
+	return nil
+}
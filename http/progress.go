@@ -0,0 +1,116 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// maxTrackedUploads is the maximum number of uploads whose progress is
+// tracked at once. The uploads above it work but report no progress.
+const maxTrackedUploads = 256
+
+// maxUploadIDLength is the maximum length of the upload IDs.
+const maxUploadIDLength = 64
+
+type uploadProgress struct {
+	Received int64 `json:"received"`
+	Total    int64 `json:"total"`
+}
+
+// progressReader counts the bytes read from an upload.
+type progressReader struct {
+	io.ReadCloser
+	received *int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	atomic.AddInt64(r.received, int64(n))
+	return n, err
+}
+
+// uploads are the uploads in progress by user and upload ID.
+var uploads = struct {
+	sync.Mutex
+	m map[string]*uploadProgress
+}{m: map[string]*uploadProgress{}}
+
+func uploadKey(d *data, id string) string {
+	return strconv.FormatUint(uint64(d.user.ID), 10) + "/" + id
+}
+
+// uploadsLen returns the number of uploads whose progress is tracked.
+func uploadsLen() int {
+	uploads.Lock()
+	defer uploads.Unlock()
+	return len(uploads.m)
+}
+
+// trackUpload tracks the progress of the upload of the request if the
+// client set an ID to it in the X-Upload-ID header. The returned func
+// stops tracking it.
+func trackUpload(r *http.Request, d *data) func() {
+	body, stop := trackProgress(r, d, r.Body, r.ContentLength)
+	r.Body = body
+	return stop
+}
+
+// trackProgress tracks the bytes read from body, of total bytes, as the
+// progress of the upload with the ID of the X-Upload-ID header of the
+// request, if it has one. It returns body counting them and the func
+// that stops tracking them.
+func trackProgress(r *http.Request, d *data, body io.ReadCloser, total int64) (io.ReadCloser, func()) {
+	progress, stop := startProgress(r, d, total)
+	if progress == nil {
+		return body, stop
+	}
+
+	return &progressReader{body, &progress.Received}, stop
+}
+
+// startProgress starts tracking the progress, out of total, of the
+// operation with the ID of the X-Upload-ID header of the request, if it
+// has one. It returns the progress, or nil if it isn't tracked, and the
+// func that stops tracking it.
+func startProgress(r *http.Request, d *data, total int64) (*uploadProgress, func()) {
+	id := r.Header.Get("X-Upload-ID")
+	if id == "" || len(id) > maxUploadIDLength {
+		return nil, func() {}
+	}
+
+	key := uploadKey(d, id)
+	progress := &uploadProgress{Total: total}
+
+	uploads.Lock()
+	defer uploads.Unlock()
+
+	if _, ok := uploads.m[key]; ok || len(uploads.m) >= maxTrackedUploads {
+		return nil, func() {}
+	}
+
+	uploads.m[key] = progress
+
+	return progress, func() {
+		uploads.Lock()
+		delete(uploads.m, key)
+		uploads.Unlock()
+	}
+}
+
+var uploadProgressHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	uploads.Lock()
+	progress, ok := uploads.m[uploadKey(d, r.URL.Path)]
+	uploads.Unlock()
+
+	if !ok {
+		return http.StatusNotFound, nil
+	}
+
+	return renderJSON(w, r, &uploadProgress{
+		Received: atomic.LoadInt64(&progress.Received),
+		Total:    progress.Total,
+	})
+})
@@ -0,0 +1,154 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// fromTrustedProxy checks if the request comes from one of the proxies,
+// which are either IPs or CIDRs.
+func fromTrustedProxy(r *http.Request, proxies []string) bool {
+	return trustedIP(remoteIP(r), proxies)
+}
+
+// remoteIP returns the IP of the peer of the request, or nil if it is
+// not on an IP network, such as a Unix socket.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+// trustedIP checks if ip is one of the proxies.
+func trustedIP(ip net.IP, proxies []string) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range proxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP returns the IP of the client that made the request. Trusted
+// proxies add the IPs they forward for to X-Forwarded-For, so it is
+// walked from the right while the hops are trusted, and the first one
+// that isn't is the client. The hops left of it could be made up by the
+// client. Trusted proxies that only send X-Real-IP are also supported.
+// Every feature that depends on who the client is must use it rather
+// than the peer of the request, which is the proxy behind one.
+func clientIP(r *http.Request, proxies []string) net.IP {
+	ip := remoteIP(r)
+	if !trustedIP(ip, proxies) {
+		return ip
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+			return realIP
+		}
+
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(forwarded, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// What is left of a malformed hop can't be trusted either.
+			break
+		}
+
+		ip = hop
+		if !trustedIP(ip, proxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// clientIP returns the IP of the client that made the request.
+func (d *data) clientIP(r *http.Request) net.IP {
+	return clientIP(r, d.server.TrustedProxies)
+}
+
+// clientAddr describes the client that made the request in the logs.
+func (d *data) clientAddr(r *http.Request) string {
+	if ip := d.clientIP(r); ip != nil {
+		return ip.String()
+	}
+
+	return r.RemoteAddr
+}
+
+// forwardedPrefix returns the prefix a trusted proxy stripped from the
+// path before forwarding the request. Proxies that are behind other
+// proxies may send a comma separated list of prefixes, outermost first.
+func forwardedPrefix(r *http.Request, proxies []string) string {
+	header := r.Header.Get("X-Forwarded-Prefix")
+	if header == "" || !fromTrustedProxy(r, proxies) {
+		return ""
+	}
+
+	prefix := ""
+	for _, p := range strings.Split(header, ",") {
+		p = strings.Trim(strings.TrimSpace(p), "/")
+		if p != "" {
+			prefix += "/" + p
+		}
+	}
+
+	if prefix == "" {
+		return ""
+	}
+
+	return (&url.URL{Path: path.Clean(prefix)}).EscapedPath()
+}
+
+// baseURL returns the base URL as seen by the browser.
+func (d *data) baseURL(r *http.Request) string {
+	return forwardedPrefix(r, d.server.TrustedProxies) + d.server.BaseURL
+}
+
+// origin returns the scheme and host of the request as seen by the
+// browser, which are forwarded by trusted proxies.
+func (d *data) origin(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if fromTrustedProxy(r, d.server.TrustedProxies) {
+		if proto := firstForwarded(r.Header.Get("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+
+		if fwd := firstForwarded(r.Header.Get("X-Forwarded-Host")); fwd != "" {
+			host = fwd
+		}
+	}
+
+	return scheme + "://" + host
+}
+
+// firstForwarded returns the value set by the outermost proxy of a comma
+// separated forwarded header.
+func firstForwarded(header string) string {
+	return strings.TrimSpace(strings.Split(header, ",")[0])
+}
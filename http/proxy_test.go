@@ -0,0 +1,91 @@
+package http
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  string
+		prefix  string
+		baseURL string
+		want    string
+	}{
+		{"direct access", "192.0.2.1:1234", "", "", ""},
+		{"direct access with a base URL", "192.0.2.1:1234", "", "/fb", "/fb"},
+		{"trusted proxy", "10.0.0.1:1234", "/storage", "", "/storage"},
+		{"trusted proxy with a base URL", "10.0.0.1:1234", "/storage", "/fb", "/storage/fb"},
+		{"nested prefixes", "10.0.0.1:1234", "/outer, /inner/", "", "/outer/inner"},
+		{"nested prefix in one", "10.0.0.1:1234", "/outer/inner", "/fb", "/outer/inner/fb"},
+		{"empty prefixes", "10.0.0.1:1234", " , /", "/fb", "/fb"},
+		{"escaped prefix", "10.0.0.1:1234", "/my files", "", "/my%20files"},
+		{"dot segments", "10.0.0.1:1234", "/a/../b", "", "/b"},
+		{"untrusted client", "192.0.2.1:1234", "/storage", "/fb", "/fb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/resources/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.prefix != "" {
+				r.Header.Set("X-Forwarded-Prefix", tt.prefix)
+			}
+
+			d := &data{server: &settings.Server{BaseURL: tt.baseURL, TrustedProxies: []string{"10.0.0.0/8"}}}
+			if got := d.baseURL(r); got != tt.want {
+				t.Errorf("baseURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "192.0.2.10"}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{"direct access", "198.51.100.1:1234", nil, "", "198.51.100.1"},
+		{"no port", "198.51.100.1", nil, "", "198.51.100.1"},
+		{"unix socket", "@", nil, "", "<nil>"},
+		{"spoofed by a client", "198.51.100.1:1234", []string{"203.0.113.1"}, "", "198.51.100.1"},
+		{"spoofed real IP", "198.51.100.1:1234", nil, "203.0.113.1", "198.51.100.1"},
+		{"trusted proxy", "10.0.0.1:1234", []string{"203.0.113.1"}, "", "203.0.113.1"},
+		{"trusted proxy IP", "192.0.2.10:1234", []string{"203.0.113.1"}, "", "203.0.113.1"},
+		{"chain of proxies", "10.0.0.1:1234", []string{"203.0.113.1, 10.0.0.2"}, "", "203.0.113.1"},
+		{"chain in headers", "10.0.0.1:1234", []string{"203.0.113.1", "10.0.0.2"}, "", "203.0.113.1"},
+		{"spoofed behind a proxy", "10.0.0.1:1234", []string{"10.0.0.3, 203.0.113.1"}, "", "203.0.113.1"},
+		{"spoofed before a client", "10.0.0.1:1234", []string{"198.51.100.7, 203.0.113.1, 10.0.0.2"}, "", "203.0.113.1"},
+		{"malformed hop", "10.0.0.1:1234", []string{"203.0.113.1, garbage, 10.0.0.2"}, "", "10.0.0.2"},
+		{"only proxies", "10.0.0.1:1234", []string{"10.0.0.3"}, "", "10.0.0.3"},
+		{"real IP", "10.0.0.1:1234", nil, "203.0.113.1", "203.0.113.1"},
+		{"malformed real IP", "10.0.0.1:1234", nil, "garbage", "10.0.0.1"},
+		{"forwarded over real IP", "10.0.0.1:1234", []string{"203.0.113.1"}, "203.0.113.2", "203.0.113.1"},
+		{"IPv6", "[2001:db8::1]:1234", []string{"203.0.113.1"}, "", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/resources/", nil)
+			r.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := clientIP(r, proxies).String(); got != tt.want {
+				t.Errorf("clientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
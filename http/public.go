@@ -23,6 +23,7 @@ var withHashFile = func(fn handleFunc) handleFunc {
 		}
 
 		d.user = user
+		d.user.Fs = withSymlinks(withContext(d.user.Fs, r), d)
 
 		file, err := files.NewFileInfo(files.FileOptions{
 			Fs:      d.user.Fs,
@@ -59,8 +60,9 @@ var publicShareHandler = withHashFile(func(w http.ResponseWriter, r *http.Reques
 
 var publicDlHandler = withHashFile(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 	file := d.raw.(*files.FileInfo)
+	w = d.countDownload(w)
 	if !file.IsDir {
-		return rawFileHandler(w, r, file)
+		return rawFileHandler(w, r, d, file)
 	}
 
 	return rawDirHandler(w, r, d, file)
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/qr"
+)
+
+// qrSizes are the sizes, in pixels, of the QR code images.
+var qrSizes = []int{128, 256, 512}
+
+const (
+	defaultQRSize = 256
+	// qrMaxAge is how long the browsers may cache the images of the
+	// links that don't expire.
+	qrMaxAge = time.Hour
+)
+
+// qrURL returns the URL of the QR code image of a share link.
+func qrURL(r *http.Request, d *data, hash string) string {
+	return d.baseURL(r) + "/api/public/qr/" + hash
+}
+
+var publicQRHandler = func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	hash := strings.Trim(r.URL.Path, "/")
+
+	link, err := d.store.Share.GetByHash(hash)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	size := defaultQRSize
+	if raw := r.URL.Query().Get("size"); raw != "" {
+		size, err = strconv.Atoi(raw)
+		if err != nil || !validQRSize(size) {
+			return http.StatusBadRequest, nil
+		}
+	}
+
+	code, err := qr.Encode([]byte(d.origin(r) + d.baseURL(r) + "/share/" + link.Hash))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code.Image(size)); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	maxAge := qrMaxAge
+	if link.Expire != 0 {
+		if left := time.Until(time.Unix(link.Expire, 0)); left < maxAge {
+			maxAge = left
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := buf.WriteTo(w); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
+
+func validQRSize(size int) bool {
+	for _, s := range qrSizes {
+		if s == size {
+			return true
+		}
+	}
+
+	return false
+}
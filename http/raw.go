@@ -2,11 +2,13 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
-	"path/filepath"
 	"strings"
+	"time"
 
+	fbErrors "github.com/filebrowser/filebrowser/v2/errors"
 	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/users"
 	"github.com/hacdias/fileutils"
@@ -27,7 +29,7 @@ func parseQueryFiles(r *http.Request, f *files.FileInfo, u *users.User) ([]strin
 			}
 
 			name = fileutils.SlashClean(name)
-			files = append(files, filepath.Join(f.Path, name))
+			files = append(files, slashJoin(f.Path, name))
 		}
 	}
 
@@ -71,16 +73,15 @@ var rawHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data)
 		return errToStatus(err), err
 	}
 
+	w = d.countDownload(w)
 	if !file.IsDir {
-		return rawFileHandler(w, r, file)
+		return rawFileHandler(w, r, d, file)
 	}
 
 	return rawDirHandler(w, r, d, file)
 })
 
 func addFile(ar archiver.Writer, d *data, path string) error {
-	// Checks are always done with paths with "/" as path separator.
-	path = strings.Replace(path, "\\", "/", -1)
 	if !d.Check(path) {
 		return nil
 	}
@@ -90,6 +91,12 @@ func addFile(ar archiver.Writer, d *data, path string) error {
 		return err
 	}
 
+	// The special files would block the archive, so they are left out.
+	if kind := files.SpecialKind(info.Mode()); kind != "" {
+		d.logger.Warn("left the special file out of the archive", "path", path, "kind", kind)
+		return nil
+	}
+
 	file, err := d.user.Fs.Open(path)
 	if err != nil {
 		return err
@@ -114,7 +121,7 @@ func addFile(ar archiver.Writer, d *data, path string) error {
 		}
 
 		for _, name := range names {
-			err = addFile(ar, d, filepath.Join(path, name))
+			err = addFile(ar, d, slashJoin(path, name))
 			if err != nil {
 				return err
 			}
@@ -125,6 +132,17 @@ func addFile(ar archiver.Writer, d *data, path string) error {
 }
 
 func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	// The archives are streamed as they are built, so they have no
+	// headers to give without building them.
+	if r.Method == http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		return http.StatusMethodNotAllowed, nil
+	}
+
+	if !d.capabilities(file.Path).CanDownloadArchive {
+		return http.StatusForbidden, nil
+	}
+
 	filenames, err := parseQueryFiles(r, file, d.user)
 	if err != nil {
 		return http.StatusInternalServerError, err
@@ -140,7 +158,7 @@ func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.
 		name = "archive"
 	}
 	name += extension
-	w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(name))
+	w.Header().Set("Content-Disposition", attachment(name))
 
 	err = ar.Create(w)
 	if err != nil {
@@ -158,7 +176,26 @@ func rawDirHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.
 	return 0, nil
 }
 
-func rawFileHandler(w http.ResponseWriter, r *http.Request, file *files.FileInfo) (int, error) {
+// fileETag returns the ETag of the contents of a file, which changes with
+// its modification time and its size.
+func fileETag(modTime time.Time, size int64) string {
+	return fmt.Sprintf(`"%x%x"`, modTime.UnixNano(), size)
+}
+
+// rawFileHandler serves the contents of a file with its MIME type, which
+// is sniffed from them when its name doesn't tell, or the file resized
+// if it's an image and the request asks for it. The file has an ETag and
+// a modification time, so the conditional requests and the ranges, with
+// If-Range, let the clients resume the downloads and seek the media.
+func rawFileHandler(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	if files.SpecialKind(file.Mode) != "" {
+		return http.StatusConflict, fbErrors.ErrSpecialFile
+	}
+
+	if wantsResize(r) {
+		return resizedFileHandler(w, r, d, file)
+	}
+
 	fd, err := file.Fs.Open(file.Path)
 	if err != nil {
 		return http.StatusInternalServerError, err
@@ -169,9 +206,14 @@ func rawFileHandler(w http.ResponseWriter, r *http.Request, file *files.FileInfo
 		w.Header().Set("Content-Disposition", "inline")
 	} else {
 		// As per RFC6266 section 4.3
-		w.Header().Set("Content-Disposition", "attachment; filename*=utf-8''"+url.PathEscape(file.Name))
+		w.Header().Set("Content-Disposition", attachment(file.Name))
+	}
+
+	if t := mimeType(d, file); t != "" {
+		w.Header().Set("Content-Type", t)
 	}
 
+	w.Header().Set("ETag", currentETag(file.Fs, file.Path, file.ModTime, file.Size))
 	http.ServeContent(w, r, file.Name, file.ModTime, fd)
 	return 0, nil
 }
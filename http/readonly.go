@@ -0,0 +1,86 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+)
+
+// readOnlyAllow is the Allow header of the requests refused by the
+// read-only servers.
+const readOnlyAllow = "GET, HEAD, OPTIONS"
+
+// fileWrite describes the requests under a path that change the files.
+// The ones with the methods that aren't readMethods do unless methods
+// says which do, and the POST requests with one of readActions don't.
+type fileWrite struct {
+	prefix      string
+	methods     []string
+	readActions []string
+}
+
+// fileWrites are the requests that change the files. It's the only list
+// the read-only servers check, before any handler runs, so the routes
+// that change the files must be here. The commands are run by a
+// WebSocket, which is opened with GET, and can change anything.
+var fileWrites = []fileWrite{
+	{prefix: "/api/resources", readActions: []string{"verify", "archive"}},
+	{prefix: "/api/bulk"},
+	{prefix: "/api/rename"},
+	{prefix: "/api/tags"},
+	{prefix: "/api/command", methods: []string{http.MethodGet}},
+	{prefix: "/dav"},
+}
+
+// changesFiles checks if the request r for the path p, without the base
+// URL, would change the files.
+func changesFiles(r *http.Request, p string) bool {
+	for _, write := range fileWrites {
+		if p != write.prefix && !strings.HasPrefix(p, write.prefix+"/") {
+			continue
+		}
+
+		if write.methods != nil {
+			for _, method := range write.methods {
+				if r.Method == method {
+					return true
+				}
+			}
+			return false
+		}
+
+		return !readMethods[r.Method] && !readAction(r, write.readActions)
+	}
+
+	return false
+}
+
+// readAction checks if r is a POST request with one of actions.
+func readAction(r *http.Request, actions []string) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+
+	action := r.URL.Query().Get("action")
+	for _, a := range actions {
+		if action == a {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refuseWrites wraps fn, which serves the paths under prefix, so that,
+// on the read-only servers, the requests that would change the files are
+// refused with 405 before fn runs, so nothing is touched on the disk.
+// Every route is wrapped by handle, so they're all checked.
+func refuseWrites(fn handleFunc, prefix string) handleFunc {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.server.ReadOnly || !changesFiles(r, prefix+r.URL.Path) {
+			return fn(w, r, d)
+		}
+
+		w.Header().Set("Allow", readOnlyAllow)
+		return renderFailure(w, r, http.StatusMethodNotAllowed, "the server is read-only")
+	}
+}
@@ -0,0 +1,203 @@
+package http_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// snapshot returns the paths, modes and contents of the files of fs.
+func snapshot(t *testing.T, fs afero.Fs) string {
+	t.Helper()
+
+	var lines []string
+	err := afero.Walk(fs, "/", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		line := fmt.Sprintf("%s %v", p, info.Mode())
+		if info.Mode().IsRegular() {
+			content, err := afero.ReadFile(fs, p)
+			if err != nil {
+				return err
+			}
+			line += " " + string(content)
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// writeRequest is a request of an operation that doesn't only read. If
+// refused, it changes the files, and the read-only servers refuse it.
+// The changes of the hidden ones can't be seen in the files.
+type writeRequest struct {
+	target  string
+	body    string
+	headers []string
+	refused bool
+	hidden  bool
+}
+
+// lockBody is the body of the WebDAV LOCK requests.
+const lockBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+
+// proppatchBody is the body of the WebDAV PROPPATCH requests.
+const proppatchBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:"><D:set><D:prop><D:displayname>A</D:displayname></D:prop></D:set></D:propertyupdate>`
+
+// writeRequests are the requests of the operations of the API whose
+// methods aren't GET, HEAD or OPTIONS, by ID, and of WebDAV. A new
+// operation must be added, so it's checked.
+var writeRequests = map[string]writeRequest{
+	"login":           {target: "/api/login"},
+	"signup":          {target: "/api/signup", body: `{"username": "new", "password": "new"}`},
+	"renew":           {target: "/api/renew"},
+	"createUser":      {target: "/api/users", body: `{"what": "user", "which": [], "data": {"username": "new", "password": "new"}}`},
+	"updateUser":      {target: "/api/users/1", body: `{"what": "user", "which": ["locale"], "data": {"locale": "fr"}}`},
+	"deleteUser":      {target: "/api/users/2"},
+	"deleteResource":  {target: "/api/resources/dir/a.txt?purge=true", refused: true},
+	"uploadResource":  {target: "/api/resources/dir/new.txt", body: "new", refused: true},
+	"replaceResource": {target: "/api/resources/dir/a.txt", body: "changed", refused: true},
+	"moveResource":    {target: "/api/resources/dir/a.txt?action=rename&destination=/dir/c.txt", refused: true},
+	"makeDirectory":   {target: "/api/resources/dir/new", refused: true},
+	"bulk":            {target: "/api/bulk", body: `{"action": "delete", "items": ["/dir/a.txt"], "purge": true}`, refused: true},
+	"batchRename":     {target: "/api/rename/dir/", body: `{"items": ["a.txt"], "find": "a", "replace": "c"}`, refused: true},
+	"createShare":     {target: "/api/share/dir/a.txt"},
+	"deleteShare":     {target: "/api/share/nohash"},
+	"updateSettings":  {target: "/api/settings", body: `{}`},
+	"createArchive":   {target: "/api/raw/dir/?algo=zip"},
+	"deleteArchive":   {target: "/api/archives/00"},
+	"setTags":         {target: "/api/tags/dir/a.txt", body: `["red"]`, refused: true},
+	"pinFavorite":     {target: "/api/favorites/dir/a.txt"},
+	"unpinFavorite":   {target: "/api/favorites/dir/a.txt"},
+
+	"dav PUT":       {target: "/dav/dir/a.txt", body: "changed", refused: true},
+	"dav DELETE":    {target: "/dav/dir/a.txt", refused: true},
+	"dav MKCOL":     {target: "/dav/dir/new", refused: true},
+	"dav COPY":      {target: "/dav/dir/a.txt", headers: []string{"Destination", "http://example.com/dav/dir/c.txt"}, refused: true},
+	"dav MOVE":      {target: "/dav/dir/a.txt", headers: []string{"Destination", "http://example.com/dav/dir/c.txt"}, refused: true},
+	"dav PROPPATCH": {target: "/dav/dir/a.txt", body: proppatchBody, refused: true, hidden: true},
+	"dav LOCK":      {target: "/dav/dir/new.txt", body: lockBody, refused: true},
+}
+
+// readRequests are the operations with the methods that only read whose
+// requests are refused, by ID: the commands, which can change anything.
+var readRequests = map[string]bool{
+	"runCommand": true,
+}
+
+type operation struct {
+	id, method, target string
+}
+
+// operations returns the operations of the OpenAPI document of srv, with
+// their parameters filled, and the ones of WebDAV.
+func operations(t *testing.T, srv *filebrowsertest.Server) []operation {
+	t.Helper()
+
+	w := do(t, srv, "GET", "/api/openapi.json", "")
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	fill := strings.NewReplacer("{path}", "dir/a.txt", "{id}", "00", "{hash}", "nohash")
+	var ops []operation
+	for p, methods := range doc.Paths {
+		for method, op := range methods {
+			// The methods that OpenAPI doesn't have are extensions.
+			method = strings.ToUpper(strings.TrimPrefix(method, "x-"))
+			ops = append(ops, operation{op.OperationID, method, fill.Replace(p)})
+		}
+	}
+
+	for _, method := range []string{"PUT", "DELETE", "MKCOL", "COPY", "MOVE", "PROPPATCH", "LOCK"} {
+		ops = append(ops, operation{"dav " + method, method, ""})
+	}
+	for _, method := range []string{"GET", "PROPFIND", "OPTIONS"} {
+		ops = append(ops, operation{"dav " + method, method, "/dav/dir/a.txt"})
+	}
+
+	if len(ops) < 50 {
+		t.Fatalf("the document has %d operations", len(ops))
+	}
+
+	return ops
+}
+
+func TestReadOnly(t *testing.T) {
+	files := map[string]filebrowsertest.File{
+		"/dir/a.txt": {Content: "a"},
+		"/dir/b.txt": {Content: "b"},
+	}
+
+	probe, _ := newServer(t, files)
+	for _, op := range operations(t, probe) {
+		t.Run(op.id, func(t *testing.T) {
+			req, write := writeRequests[op.id]
+			switch op.method {
+			case "GET", "HEAD", "OPTIONS", "PROPFIND":
+				req = writeRequest{target: op.target, refused: readRequests[op.id], hidden: readRequests[op.id]}
+			default:
+				if !write {
+					t.Fatalf("%s %s isn't in writeRequests", op.method, op.target)
+				}
+			}
+
+			headers := append([]string{"Accept", "application/json"}, req.headers...)
+			if op.method == "PROPFIND" {
+				headers = append(headers, "Depth", "0")
+			}
+
+			// The requests that are refused change the files otherwise,
+			// so the refusals are the ones of changes.
+			if req.refused && !req.hidden {
+				srv, fs := newServer(t, files)
+				before := snapshot(t, fs)
+				do(t, srv, op.method, req.target, req.body, append(headers, "X-CSRF-Token", csrfToken(t, srv))...)
+				if snapshot(t, fs) == before {
+					t.Errorf("%s %s didn't change the files of a server that isn't read-only", op.method, req.target)
+				}
+			}
+
+			srv, fs := newServer(t, files)
+			if err := srv.Handler.(*fbhttp.Handler).Reload(&settings.Server{Root: "/", ReadOnly: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			before := snapshot(t, fs)
+			w := do(t, srv, op.method, req.target, req.body, append(headers, "X-CSRF-Token", csrfToken(t, srv))...)
+			if after := snapshot(t, fs); after != before {
+				t.Errorf("%s %s changed the files from\n%s\nto\n%s", op.method, req.target, before, after)
+			}
+
+			refused := w.Code == http.StatusMethodNotAllowed && w.Header().Get("Allow") == "GET, HEAD, OPTIONS"
+			if refused != req.refused {
+				t.Errorf("%s %s = %d, Allow %q, want refused: %v", op.method, req.target, w.Code, w.Header().Get("Allow"), req.refused)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// recentWindows are the windows the HTML listings link to.
+var recentWindows = []string{"1d", "7d", "30d"}
+
+// parseWindow parses the window of the recent files, which is a Go
+// duration such as 12h or a number of days or weeks such as 7d or 2w.
+func parseWindow(raw string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(raw, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(raw, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	if unit == 0 {
+		window, err := time.ParseDuration(raw)
+		if err != nil || window <= 0 {
+			return 0, errors.ErrInvalidOption
+		}
+		return window, nil
+	}
+
+	n, err := strconv.Atoi(raw[:len(raw)-1])
+	if err != nil || n <= 0 {
+		return 0, errors.ErrInvalidOption
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+// recentListing returns the listing of the files under dir modified in
+// the last window, walked up to the depth and the number of nodes of the
+// trees.
+func recentListing(r *http.Request, d *data, dir string, window time.Duration) (*files.Listing, error) {
+	maxDepth, maxNodes := d.settings.Tree.Limits()
+	return files.Recent(files.RecentOptions{
+		Context:    r.Context(),
+		Fs:         d.user.Fs,
+		Path:       dir,
+		Since:      time.Now().Add(-window),
+		Depth:      maxDepth,
+		MaxEntries: maxNodes,
+		Checker:    d,
+		Categories: d.settings.Categories,
+	})
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// wantsChildren returns the depth of the directories below the items of
+// the JSON listings that the recursive query parameter asks for, 1 by
+// default and capped by the settings of the trees, or zero.
+func wantsChildren(r *http.Request, d *data) (int, error) {
+	if r.URL.Query().Get("recursive") != "true" || listingFormat(r, d) != formatJSON {
+		return 0, nil
+	}
+
+	maxDepth, _ := d.settings.Tree.Limits()
+	depth := 1
+	if raw := r.URL.Query().Get("depth"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return 0, errors.ErrInvalidOption
+		}
+		depth = n
+	}
+
+	if depth > maxDepth {
+		depth = maxDepth
+	}
+
+	return depth, nil
+}
+
+// addChildren sets the children of the directories of the listing, and
+// the ones of their directories, down to depth levels. They are filtered
+// and sorted like the listing. The directories are read breadth first so,
+// once the maximum number of entries of the trees is reached, the
+// deepest ones are left out and the listing is marked as truncated. The
+// links to directories are never followed, so the walk can't loop, and
+// the directories that can't be read are marked rather than failing the
+// listing.
+func addChildren(r *http.Request, d *data, listing *files.Listing, depth int) {
+	_, maxNodes := d.settings.Tree.Limits()
+	nodes := len(listing.Items)
+	level := listing.Items
+
+	for ; depth > 0 && len(level) > 0; depth-- {
+		var next []*files.FileInfo
+		for _, item := range level {
+			if !item.IsDir || item.Error || item.IsSymlink || isLink(d.user.Fs, item.Path) {
+				continue
+			}
+
+			dir, err := files.NewFileInfo(files.FileOptions{
+				Fs:         d.user.Fs,
+				Path:       item.Path,
+				Modify:     d.user.Perm.Modify,
+				Expand:     true,
+				Checker:    d,
+				Categories: d.settings.Categories,
+				Readlink:   d.readlink(),
+				Logger:     d.logger,
+				Context:    r.Context(),
+			})
+			if err != nil {
+				d.logger.Debug("couldn't list the children", "path", item.Path, "error", err)
+				item.ChildrenError = true
+				continue
+			}
+
+			if d.settings.DirTemplates {
+				hideListed(dir.Listing, dirTemplateName)
+			}
+
+			if d.settings.DirOptions != "" {
+				hideListed(dir.Listing, d.settings.DirOptions)
+			}
+
+			hideTagsSidecar(dir.Listing)
+			hideTrash(d, dir.Listing)
+			hideDotfiles(r, d, dir.Listing)
+
+			if nodes+len(dir.Items) > maxNodes {
+				listing.Truncated = true
+				return
+			}
+			nodes += len(dir.Items)
+
+			dir.Listing.Sorting = listing.Sorting
+			sortListing(r, d, dir.Listing)
+			item.Children = dir.Items
+			next = append(next, dir.Items...)
+		}
+
+		level = next
+	}
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	rice "github.com/GeertJohan/go.rice"
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/logging"
+	"github.com/filebrowser/filebrowser/v2/metrics"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/storage"
+	"github.com/filebrowser/filebrowser/v2/tracing"
+	"github.com/filebrowser/filebrowser/v2/webhooks"
+)
+
+// Handler is the http.Handler of File Browser. Its server settings can be
+// replaced with Reload while it serves. The settings stored in the
+// database are read by every request, so they never need reloading.
+type Handler struct {
+	storage    *storage.Storage
+	logger     logging.Logger
+	registerer metrics.Registerer
+	metrics    *handlerMetrics
+	events     *events.Bus
+	webhooks   *webhooks.Queue
+	accessLog  *accessLog
+	tracer     tracing.Tracer
+	started    time.Time
+	slow       *slowOps
+	archives   *archiveJobs
+	hooks      *hookDispatch
+	assets     *rice.Box
+
+	// reloading serializes the reloads. The requests don't take it: they
+	// load the current state once and keep it until they finish.
+	reloading sync.Mutex
+	current   atomic.Value // *handlerState
+}
+
+type handlerState struct {
+	server  *settings.Server
+	handler http.Handler
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r = withRequestID(w, r)
+	h.current.Load().(*handlerState).handler.ServeHTTP(w, r)
+}
+
+// Close waits for the After hooks to return, for the notifications of the
+// webhooks to be sent and for the access log to be flushed, and gives up
+// when ctx is done. It closes the channels of the subscribers of the
+// events. It is called once the server stopped serving the handler.
+func (h *Handler) Close(ctx context.Context) error {
+	hooksDone := make(chan struct{})
+	go func() {
+		h.hooks.running.Wait()
+		close(hooksDone)
+	}()
+
+	select {
+	case <-hooksDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	err := h.webhooks.Close(ctx)
+	h.events.Close()
+	if h.accessLog != nil {
+		if logErr := h.accessLog.close(ctx); err == nil {
+			err = logErr
+		}
+	}
+
+	return err
+}
+
+// Subscribe returns a channel with the operations on the files of the
+// given types, such as settings.EventUpload, or of all of them if there
+// are none, and the function that unsubscribes from them. The operations
+// are published without waiting for the subscribers: when one falls
+// behind by events.Buffer events, its oldest ones are dropped. The
+// channel is closed by the function and by Close.
+func (h *Handler) Subscribe(types ...string) (<-chan events.Event, func()) {
+	return h.events.Subscribe(types...)
+}
+
+// Server returns a copy of the server settings in use.
+func (h *Handler) Server() settings.Server {
+	return *h.current.Load().(*handlerState).server
+}
+
+// Reload validates the server settings and swaps them in. The requests
+// being served finish with the previous ones. The address, the port, the
+// socket and the TLS files of the listener are not used by the handler.
+func (h *Handler) Reload(server *settings.Server) error {
+	s := *server
+	s.TrustedProxies = append([]string(nil), server.TrustedProxies...)
+	s.MetricsAllow = append([]string(nil), server.MetricsAllow...)
+	s.AliasRoots = append([]string(nil), server.AliasRoots...)
+	s.Clean()
+
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	h.reloading.Lock()
+	defer h.reloading.Unlock()
+
+	if err := h.archives.open(s.ArchiveSpool); err != nil {
+		return err
+	}
+
+	previous := h.current.Load().(*handlerState)
+	state := &handlerState{
+		server:  &s,
+		handler: newRouter(h, &s),
+	}
+
+	// The snapshots of the tracked changes are paths inside the previous
+	// root, so they mean nothing inside another one.
+	if previous.server.Root != s.Root {
+		resetSnapshots()
+	}
+
+	h.current.Store(state)
+	return nil
+}
@@ -0,0 +1,338 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// renameRequest renames several files of a directory at once. The new
+// names come either from Find and Replace, which are literal unless
+// Regex is set, or from Template. Items are the names of the files, all
+// the files of the directory if it's empty. DryRun returns the new names
+// without renaming anything.
+type renameRequest struct {
+	Items    []string `json:"items"`
+	Find     string   `json:"find"`
+	Replace  string   `json:"replace"`
+	Regex    bool     `json:"regex"`
+	Template string   `json:"template"`
+	Start    int      `json:"start"`
+	DryRun   bool     `json:"dryRun"`
+}
+
+// renameResult is the new name of a file and why it can't be renamed,
+// if it can't.
+type renameResult struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Error string `json:"error,omitempty"`
+}
+
+type renameResponse struct {
+	DryRun    bool           `json:"dryRun"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Results   []renameResult `json:"results"`
+}
+
+// renamer returns the new name of the nth file named name.
+type renamer func(name string, n int) string
+
+// newRenamer returns the renamer of the request.
+func newRenamer(req *renameRequest) (renamer, error) {
+	switch {
+	case req.Template != "" && req.Find != "":
+		return nil, fmt.Errorf("the names come from either a template or find and replace")
+	case req.Template != "":
+		return templateRenamer(req.Template, req.Start)
+	case req.Find == "":
+		return nil, fmt.Errorf("the names need a template or something to find")
+	case req.Regex:
+		re, err := regexp.Compile(req.Find)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		return func(name string, _ int) string {
+			return re.ReplaceAllString(name, req.Replace)
+		}, nil
+	}
+
+	return func(name string, _ int) string {
+		return strings.ReplaceAll(name, req.Find, req.Replace)
+	}, nil
+}
+
+// templateRenamer returns the renamer of a template, in which %d is the
+// counter, which starts at start and can have a width of up to
+// maxCounterWidth padded with zeros such as %03d, %n is the name
+// without its extension, %x is the extension with its dot and %% is a
+// percent sign.
+func templateRenamer(template string, start int) (renamer, error) {
+	// The template is checked once, and then formatted for every file.
+	if _, err := formatTemplate(template, "", "", 0); err != nil {
+		return nil, err
+	}
+
+	return func(name string, n int) string {
+		ext := path.Ext(name)
+		s, _ := formatTemplate(template, strings.TrimSuffix(name, ext), ext, start+n)
+		return s
+	}, nil
+}
+
+// maxCounterWidth is the widest the counter of the templates can be
+// padded to, so a template can't make names of any length.
+const maxCounterWidth = 32
+
+func formatTemplate(template, base, ext string, counter int) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			b.WriteByte(template[i])
+			continue
+		}
+
+		j := i + 1
+		for j < len(template) && template[j] >= '0' && template[j] <= '9' {
+			j++
+		}
+		if j == len(template) {
+			return "", fmt.Errorf("the template ends with an unfinished %%")
+		}
+
+		width := template[i+1 : j]
+		switch verb := template[j]; {
+		case verb == 'd':
+			n, err := strconv.Atoi(width)
+			if width != "" && (err != nil || n > maxCounterWidth) {
+				return "", fmt.Errorf("the width of %%d can't be over %d in the template", maxCounterWidth)
+			}
+
+			digits := strconv.Itoa(counter)
+			if n > len(digits) {
+				pad := " "
+				if strings.HasPrefix(width, "0") {
+					pad = "0"
+				}
+				digits = strings.Repeat(pad, n-len(digits)) + digits
+			}
+			b.WriteString(digits)
+		case width != "":
+			return "", fmt.Errorf("only %%d can have a width in the template")
+		case verb == 'n':
+			b.WriteString(base)
+		case verb == 'x':
+			b.WriteString(ext)
+		case verb == '%':
+			b.WriteByte('%')
+		default:
+			return "", fmt.Errorf("unknown %%%c in the template: it can have %%d, %%n, %%x and %%%%", verb)
+		}
+		i = j
+	}
+
+	return b.String(), nil
+}
+
+// nameKey returns what tells the names apart on the scope of the user,
+// which only looks at their normalization form and their case when the
+// settings say so.
+func nameKey(d *data, name string) string {
+	if d.settings.NormalizeNames || d.settings.CaseInsensitive {
+		name = norm.NFC.String(name)
+	}
+	if d.settings.CaseInsensitive {
+		name = strings.ToLower(name)
+	}
+
+	return name
+}
+
+// planRenames returns the renames of the files of dir, in an order in
+// which no file is renamed to the name of another before that one is
+// renamed, or the renames that conflict, with the reasons.
+func planRenames(d *data, dir string, results []renameResult) ([]renameResult, bool) {
+	conflicts := false
+	conflict := func(result *renameResult, reason string) {
+		if result.Error == "" {
+			result.Error = reason
+			conflicts = true
+		}
+	}
+
+	sources := map[string]int{}
+	for i, result := range results {
+		sources[nameKey(d, result.From)] = i
+	}
+
+	targets := map[string]int{}
+	for i := range results {
+		result := &results[i]
+		key := nameKey(d, result.To)
+		dst := path.Join(dir, result.To)
+
+		switch {
+		case !validName(result.To) || strings.Contains(result.To, "/"):
+			conflict(result, "invalid name")
+		case !d.capabilities(dst).CanRename:
+			conflict(result, http.StatusText(http.StatusForbidden))
+		case key == nameKey(d, result.From):
+			// The file can't be renamed to a name the scope sees as the
+			// same, which is taken by the file itself.
+			conflict(result, "the names are the same on the scope")
+		}
+
+		if other, ok := targets[key]; ok {
+			conflict(result, "the name is taken by "+results[other].From)
+			conflict(&results[other], "the name is taken by "+result.From)
+			continue
+		}
+		targets[key] = i
+
+		if _, renamed := sources[key]; !renamed {
+			if _, err := d.user.Fs.Stat(dst); err == nil {
+				conflict(result, "the name is taken by a file that isn't renamed")
+			}
+		}
+	}
+
+	if conflicts {
+		return results, false
+	}
+
+	// The files whose new names are still taken by the files to rename
+	// wait for them.
+	plan := make([]renameResult, 0, len(results))
+	pending := results
+	for len(pending) > 0 {
+		var waiting []renameResult
+		for _, result := range pending {
+			if i, ok := sources[nameKey(d, result.To)]; ok && results[i].From != result.From {
+				waiting = append(waiting, result)
+				continue
+			}
+
+			plan = append(plan, result)
+			delete(sources, nameKey(d, result.From))
+		}
+
+		if len(waiting) == len(pending) {
+			for i := range waiting {
+				conflict(&waiting[i], "the names go round in a cycle")
+			}
+			return append(plan, waiting...), false
+		}
+		pending = waiting
+	}
+
+	return plan, true
+}
+
+// renameHandler renames several files of a directory with a pattern. All
+// the new names are checked before any file is renamed, and the files are
+// then renamed one by one, like with the bulk moves.
+var renameHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	req := &renameRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	rename, err := newRenamer(req)
+	if err != nil {
+		return renderFailure(w, r, http.StatusBadRequest, err.Error())
+	}
+
+	dir := path.Clean("/" + r.URL.Path)
+	if !d.capabilities(dir).CanRename {
+		return http.StatusForbidden, nil
+	}
+
+	names := req.Items
+	if len(names) == 0 {
+		fd, err := d.user.Fs.Open(dir)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		names, err = fd.Readdirnames(-1)
+		fd.Close()
+		if err != nil {
+			return errToStatus(err), err
+		}
+		sort.Strings(names)
+	}
+
+	results := []renameResult{}
+	listed := map[string]bool{}
+	counter := 0
+	for _, name := range names {
+		if !validName(name) || strings.Contains(name, "/") {
+			return renderFailure(w, r, http.StatusBadRequest, fmt.Sprintf("invalid name %q", name))
+		}
+
+		if listed[name] {
+			return renderFailure(w, r, http.StatusBadRequest, fmt.Sprintf("%q is listed twice", name))
+		}
+		listed[name] = true
+
+		src := path.Join(dir, name)
+		if !d.Check(src) {
+			if len(req.Items) == 0 {
+				continue
+			}
+			return http.StatusForbidden, nil
+		}
+
+		if _, err := d.user.Fs.Stat(src); err != nil {
+			return errToStatus(err), err
+		}
+
+		// The counter of the templates counts the files kept as they are
+		// too, so their numbers follow the order of the names.
+		if to := rename(name, counter); to != name {
+			results = append(results, renameResult{From: name, To: to})
+		}
+		counter++
+	}
+
+	plan, ok := planRenames(d, dir, results)
+	res := &renameResponse{DryRun: req.DryRun, Results: plan}
+	for _, result := range plan {
+		if result.Error != "" {
+			res.Failed++
+		}
+	}
+
+	if req.DryRun {
+		res.Succeeded = len(plan) - res.Failed
+		return renderJSON(w, r, res)
+	}
+
+	if !ok {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusConflict)
+		return 0, json.NewEncoder(w).Encode(res)
+	}
+
+	for i := range res.Results {
+		result := &res.Results[i]
+		err := bulkMove(d, path.Join(dir, result.From), path.Join(dir, result.To))
+		// The errors are reported by their status so the full paths of
+		// the files on the server are not leaked.
+		if err != nil {
+			result.Error = http.StatusText(errToStatus(err))
+			res.Failed++
+		} else {
+			res.Succeeded++
+		}
+	}
+
+	return renderJSON(w, r, res)
+})
@@ -0,0 +1,73 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+func TestRenameTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		status   int
+		want     []string // the names of the files after the renames
+		error    string   // in the failure
+	}{
+		{"counter", "photo-%d%x", http.StatusOK, []string{"photo-1.jpg", "photo-2.jpg"}, ""},
+		{"padded with zeros", "%03d-%n%x", http.StatusOK, []string{"001-a.jpg", "002-b.jpg"}, ""},
+		{"padded with spaces", "%3d%x", http.StatusOK, []string{"  1.jpg", "  2.jpg"}, ""},
+		{"widest", "%032d%x", http.StatusOK, []string{strings.Repeat("0", 31) + "1.jpg", strings.Repeat("0", 31) + "2.jpg"}, ""},
+		{"percent sign", "100%% %d%x", http.StatusOK, []string{"100% 1.jpg", "100% 2.jpg"}, ""},
+		{"too wide", "%033d%x", http.StatusBadRequest, nil, "the width of %d can't be over 32"},
+		{"much too wide", "%2000000000d", http.StatusBadRequest, nil, "the width of %d can't be over 32"},
+		{"width over an int", "%99999999999999999999d", http.StatusBadRequest, nil, "the width of %d can't be over 32"},
+		{"width of the name", "%3n", http.StatusBadRequest, nil, "only %d can have a width"},
+		{"unfinished", "a%", http.StatusBadRequest, nil, "unfinished %"},
+		{"unknown", "%q", http.StatusBadRequest, nil, "unknown %q"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, map[string]filebrowsertest.File{
+				"/dir/a.jpg": {Content: "a"},
+				"/dir/b.jpg": {Content: "b"},
+			})
+
+			body, err := json.Marshal(map[string]interface{}{"items": []string{"a.jpg", "b.jpg"}, "template": tt.template, "start": 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := do(t, srv, "POST", "/api/rename/dir/", string(body), "Content-Type", "application/json", "Accept", "application/json")
+			if w.Code != tt.status {
+				t.Fatalf("POST = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.error) {
+				t.Errorf("the failure is %s, want %q", w.Body, tt.error)
+			}
+
+			want := tt.want
+			if want == nil {
+				want = []string{"a.jpg", "b.jpg"}
+			}
+
+			infos, err := afero.ReadDir(fs, "/dir")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, info := range infos {
+				names = append(names, info.Name())
+			}
+			if strings.Join(names, "|") != strings.Join(want, "|") {
+				t.Errorf("the files are %q, want %q", names, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header with the ID of a request. The handler
+// adopts the one of the requests that have it, such as the ones from a
+// proxy, and generates one for the others. It is echoed in the responses
+// and logged with everything about the request.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestID is the length of the longest ID adopted from a request.
+const maxRequestID = 128
+
+type requestIDKey struct{}
+
+// withRequestID returns r with its ID in its context, and sets it in the
+// headers of the response.
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+
+	w.Header().Set(RequestIDHeader, id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// RequestID returns the ID of the request ctx is the context of, or an
+// empty string if it has none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// validRequestID checks that id can be adopted: it can't be too long nor
+// have characters that would garble the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
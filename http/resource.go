@@ -1,158 +1,701 @@
 package http
 
 import (
-	"fmt"
+	"encoding/json"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
+	fbErrors "github.com/filebrowser/filebrowser/v2/errors"
 	"github.com/filebrowser/filebrowser/v2/files"
 
-	"github.com/filebrowser/filebrowser/v2/errors"
-	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/tracing"
 )
 
 var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	file, err := files.NewFileInfo(files.FileOptions{
-		Fs:      d.user.Fs,
-		Path:    r.URL.Path,
-		Modify:  d.user.Perm.Modify,
-		Expand:  true,
-		Checker: d,
+	if apiOnly(d) && (r.URL.Query().Get("tree") == "true" || r.URL.Query().Get("changes_since") != "") {
+		return http.StatusNotFound, nil
+	}
+
+	if r.Method == http.MethodHead && r.Header.Get("X-Upload-ID") != "" {
+		return chunkOffsetHandler(w, r, d)
+	}
+
+	if r.URL.Query().Get("tree") == "true" {
+		return renderTree(w, r, d)
+	}
+
+	if r.URL.Query().Get("changes_since") != "" {
+		return renderChanges(w, r, d)
+	}
+
+	if r.URL.Query().Get("diff") != "" {
+		return renderDiff(w, r, d)
+	}
+
+	if r.URL.Query().Get("du") == "true" {
+		return renderDiskUsage(w, r, d)
+	}
+
+	if r.URL.Query().Get("stats") == "true" {
+		return renderStats(w, r, d)
+	}
+
+	if r.URL.Query().Get("download") != "" {
+		return renderDownload(w, r, d)
+	}
+
+	// The HTML listings show the duplicates in place of the items.
+	if r.URL.Query().Get("duplicates") == "true" && listingFormat(r, d) != formatHTML {
+		return renderDuplicates(w, r, d)
+	}
+
+	start := time.Now()
+	file, err := readResource(r, d, files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       r.URL.Path,
+		Modify:     d.user.Perm.Modify,
+		Expand:     true,
+		Checker:    d,
+		Categories: d.settings.Categories,
+		Readlink:   d.readlink(),
+		Logger:     d.logger,
+		Context:    r.Context(),
+		ReadLimit:  readLimit(r, d),
 	})
 	if err != nil {
 		return errToStatus(err), err
 	}
+	read := time.Since(start)
+
+	if !file.IsDir && r.URL.Query().Get("content") == "true" {
+		return renderContent(w, r, d, file)
+	}
 
 	if file.IsDir {
-		file.Listing.Sorting = d.user.Sorting
-		file.Listing.ApplySort()
-		return renderJSON(w, r, file)
+		// Only the HTML listings are redirected, since their relative
+		// links need the trailing slash, and the clients of the API get
+		// the listing from either path.
+		d.format = negotiatedFormat(w, r, d)
+		if r.URL.Path != "" && !strings.HasSuffix(r.URL.Path, "/") && d.format == formatHTML {
+			redirectDir(w, r, d.server.RedirectStatus)
+			return 0, nil
+		}
+
+		// The clients of the API get the CSRF token of the bulk requests
+		// with the listings, as the HTML ones do.
+		w.Header().Set(csrfHeader, csrfToken(d))
+
+		// The results of the searches replace the listing, unless the
+		// duplicates of the HTML listings do.
+		query := r.URL.Query().Get("search")
+		searched := strings.TrimSpace(query) != "" && r.URL.Query().Get("duplicates") != "true"
+		if searched {
+			file.Listing, err = searchListing(r, d, file.Path, query)
+			if err != nil {
+				return errToStatus(err), err
+			}
+			read = time.Since(start)
+		}
+
+		// The recent files are listed newest first unless the request
+		// sorts them.
+		recent := r.URL.Query().Get("recent") != "" && !searched
+		if recent {
+			window, err := parseWindow(r.URL.Query().Get("recent"))
+			if err != nil {
+				return http.StatusBadRequest, err
+			}
+
+			file.Listing, err = recentListing(r, d, file.Path, window)
+			if err != nil {
+				return errToStatus(err), err
+			}
+			read = time.Since(start)
+		}
+
+		if wantsDirSizes(r, d) {
+			annotateDirSizes(r.Context(), d, file.Listing)
+		}
+
+		if by := r.URL.Query().Get("sort"); by != "" && by != "none" && !files.IsSortKey(by) {
+			return renderFailure(w, r, http.StatusBadRequest, "the listings can be sorted by name, size, modified, type or none")
+		}
+
+		opts := listingOptions(d, file.Path)
+		_, span := tracing.Start(r.Context(), "filebrowser.sort")
+		if !recent || r.URL.Query().Get("sort") != "" {
+			file.Listing.Sorting = listingSorting(r, d, opts)
+		}
+		sortListing(r, d, file.Listing)
+		span.SetString("by", file.Listing.Sorting.By)
+		span.SetInt("items", int64(len(file.Items)))
+		span.End()
+		elapsed := time.Since(start)
+		d.logger.Debug("listing", "path", r.URL.Path, "items", len(file.Items),
+			"unreadable", file.NumUnreadable, "duration", elapsed)
+		d.checkSlow(r, "listing", d.settings.Slow.Listing, elapsed, len(file.Items),
+			phase{"read", read}, phase{"sort", elapsed - read})
+		d.metrics.listingDuration.Observe(elapsed.Seconds(), d.scope())
+		d.metrics.listingItems.Observe(float64(len(file.Items)), d.scope())
+
+		if usage, ok := scopeUsage(d); ok {
+			file.Listing.Usage = usage.Used
+			file.Listing.Free = usage.Free
+			file.Listing.Quota = usage.Total
+		}
+
+		if d.settings.DirTemplates {
+			hideListed(file.Listing, dirTemplateName)
+		}
+
+		if d.settings.DirOptions != "" {
+			hideListed(file.Listing, d.settings.DirOptions)
+		}
+
+		if d.settings.GitStatus && !recent && !searched {
+			annotateGitStatus(r, d, file.Listing)
+		}
+
+		file.Listing.Favorites = userFavorites(d)
+		capabilities := d.capabilities(file.Path)
+		file.Listing.Capabilities = &capabilities
+		hideTagsSidecar(file.Listing)
+		hideTrash(d, file.Listing)
+		hideDotfiles(r, d, file.Listing)
+		if err := filterListing(r, file.Listing); err != nil {
+			return renderFailure(w, r, http.StatusBadRequest, "the filter isn't a valid glob pattern")
+		}
+		if listingFormat(r, d) == formatRSS {
+			feedListing(d, file.Listing)
+		}
+		if err := annotateTags(r, d, file.Listing); err != nil {
+			return errToStatus(err), err
+		}
+		annotateReadme(r, d, file.Listing)
+
+		limit, err := listingLimit(r, d, opts)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		offset, err := listingOffset(r)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		depth, err := wantsChildren(r, d)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		algo, err := wantsChecksums(r, d)
+		if err != nil {
+			return renderFailure(w, r, http.StatusBadRequest, "the checksums can be md5, sha1, sha256 or sha512")
+		}
+		if algo != "" && !d.user.Perm.Download {
+			return http.StatusForbidden, nil
+		}
+
+		file.Listing.Page(offset, limit)
+		d.items, d.limit = len(file.Items), limit
+		setPageLinks(w, r, d, file.Listing)
+		addChildren(r, d, file.Listing, depth)
+		if algo != "" {
+			annotateChecksums(d, file.Listing, algo)
+		}
+		if file.Truncated {
+			w.Header().Set("X-Results-Truncated", "true")
+		}
+		if r.URL.Query().Get("mime") == "true" {
+			annotateMimeTypes(d, file.Listing)
+		}
+		if wantsDocMeta(r, d) {
+			annotateDocMeta(r.Context(), d, file.Listing)
+		}
+		annotateThumbnails(r, d, file.Listing)
+		if file.ItemsLimitedTo > 0 {
+			w.Header().Set("X-Items-Limited-To", strconv.Itoa(file.ItemsLimitedTo))
+		}
+
+		if d.format == "" {
+			return http.StatusNotAcceptable, nil
+		}
+
+		if listingNotModified(w, r, listingETag(r, d, file), listingModTime(file)) {
+			w.WriteHeader(http.StatusNotModified)
+			return 0, nil
+		}
+
+		start = time.Now()
+		_, d.span = tracing.Start(r.Context(), "filebrowser.render")
+		d.span.SetString("format", d.format)
+		d.span.SetInt("items", int64(d.items))
+		status, err := renderListing(w, r, d, file)
+		d.span.End()
+		d.checkSlow(r, "render", d.settings.Slow.Render, time.Since(start), d.items)
+		return status, err
+	}
+
+	// The information of the files is only served as JSON.
+	if apiOnly(d) {
+		return http.StatusNotFound, nil
+	}
+
+	if r.URL.Query().Get("checksum") != "" {
+		return renderChecksum(w, r, d, file)
+	}
+
+	if r.URL.Query().Get("meta") == "true" {
+		return renderDocMeta(w, r, d, file)
+	}
+
+	file.MimeType = mimeType(d, file)
+	if wantsDocMeta(r, d) && files.HasDocMeta(file.Name) && file.Special == "" {
+		// The corrupt documents are served without their metadata.
+		file.DocMeta, err = docMetas.get(d, file)
+		if err != nil && !isCorrupt(err) {
+			return errToStatus(err), err
+		}
 	}
 
-	if checksum := r.URL.Query().Get("checksum"); checksum != "" {
-		err := file.Checksum(checksum)
-		if err == errors.ErrInvalidOption {
-			return http.StatusBadRequest, nil
-		} else if err != nil {
-			return http.StatusInternalServerError, err
+	if r.URL.Query().Get("tags") == "true" {
+		file.Tags, err = tagStore(d).Get(file.Path)
+		if err != nil {
+			return errToStatus(err), err
 		}
+	}
 
-		// do not waste bandwidth if we just want the checksum
-		file.Content = ""
+	// The ETag of the text files is the one of the contents they're
+	// served with, so the editors can't replace newer ones with them.
+	switch {
+	case file.Special != "":
+	case file.Type == "text" || file.Type == "textImmutable":
+		w.Header().Set("ETag", contentETag(file.ModTime, []byte(file.Content)))
+	default:
+		w.Header().Set("ETag", currentETag(d.user.Fs, file.Path, file.ModTime, file.Size))
 	}
+	w.Header().Set("Last-Modified", file.ModTime.UTC().Format(http.TimeFormat))
 
 	return renderJSON(w, r, file)
 })
 
+// renderListing renders the listing of file in the format of the request,
+// compressed for the clients that accept it.
+func renderListing(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	// The body of a HEAD request isn't written, so it isn't rendered
+	// either. Its length and encoding, which depend on it, are left out.
+	if r.Method == http.MethodHead {
+		w.Header().Add("Vary", "Accept-Encoding")
+		setFormatHeaders(w, d.format, file)
+		skipBody(w)
+		return 0, nil
+	}
+
+	cw := compressResponse(w, r)
+	status, err := renderFormat(cw, r, d, file)
+	if closeErr := cw.Close(); err == nil && closeErr != nil {
+		return 0, closeErr
+	}
+
+	return status, err
+}
+
+func renderFormat(w http.ResponseWriter, r *http.Request, d *data, file *files.FileInfo) (int, error) {
+	switch d.format {
+	case formatText:
+		return renderText(w, file, listingLocation(r, d))
+	case formatHTML:
+		return renderHTML(w, r, d, file)
+	case formatXML:
+		return renderXML(w, file)
+	case formatCSV:
+		return renderCSV(w, file)
+	case formatRSS:
+		return renderRSS(w, r, d, file)
+	}
+
+	return renderJSONListing(w, r, file)
+}
+
+// resourceDeleteHandler deletes a file or a directory. The strict requests
+// only delete the directories that are empty, unless the recursive query
+// parameter is true, and are answered with 204. The files are moved to the
+// trash directory of the settings, if there's one, unless the purge query
+// parameter is true.
 var resourceDeleteHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	if r.URL.Path == "/" || !d.user.Perm.Delete {
+	if path.Clean("/"+r.URL.Path) == "/" || !d.capabilities(r.URL.Path).CanDelete {
 		return http.StatusForbidden, nil
 	}
 
-	err := d.RunHook(func() error {
-		return d.user.Fs.RemoveAll(r.URL.Path)
-	}, "delete", r.URL.Path, "", d.user)
-
+	// The deletions are planned like the bulk ones, so the dry runs
+	// report what the others do.
+	steps, err := planBulk(d, &bulkRequest{
+		Action: "delete",
+		Items:  []string{r.URL.Path},
+		Purge:  r.URL.Query().Get("purge") == "true",
+	})
 	if err != nil {
 		return errToStatus(err), err
 	}
 
-	return http.StatusOK, nil
+	strict := strictRequest(r)
+	if strict && steps[0].err == nil && r.URL.Query().Get("recursive") != "true" {
+		full, err := hasEntries(d, steps[0].src)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if full {
+			return renderFailure(w, r, http.StatusConflict, "the directory isn't empty: it's only deleted with recursive=true")
+		}
+	}
+
+	if r.URL.Query().Get("dryRun") == "true" {
+		return renderJSON(w, r, runBulk(d, steps, true))
+	}
+
+	// The files that are already gone were deleted, as they always
+	// were, unless the request is strict.
+	if err := steps[0].err; os.IsNotExist(err) && !strict {
+		return http.StatusOK, nil
+	} else if err != nil {
+		return errToStatus(err), err
+	}
+
+	if err := applyBulkStep(d, &steps[0]); err != nil {
+		return errToStatus(err), err
+	}
+
+	if !strict {
+		return http.StatusOK, nil
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
 })
 
+// hasEntries checks if p is a directory with entries.
+func hasEntries(d *data, p string) (bool, error) {
+	info, err := d.user.Fs.Stat(p)
+	if err != nil || !info.IsDir() {
+		return false, err
+	}
+
+	fd, err := d.user.Fs.Open(p)
+	if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+
+	names, err := fd.Readdirnames(1)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	return len(names) > 0, nil
+}
+
 var resourcePostPutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	if !d.user.Perm.Create && r.Method == http.MethodPost {
+	// The verifications only read the files.
+	if r.Method == http.MethodPost && r.URL.Query().Get("action") == "verify" {
+		return verifyHandler(w, r, d)
+	}
+
+	if r.Method == http.MethodPost && r.URL.Query().Get("action") == "mkdir" {
+		return mkdirHandler(w, r, d)
+	}
+
+	if r.Method == http.MethodPost && r.URL.Query().Get("action") == "restore" {
+		return restoreHandler(w, r, d)
+	}
+
+	// The archives of the selections only read the files.
+	if r.Method == http.MethodPost && r.URL.Query().Get("action") == "archive" {
+		return archiveSelectionHandler(w, r, d)
+	}
+
+	// The files of the forms are uploaded into the directory, so they
+	// don't need to make it.
+	multipart := r.Method == http.MethodPost && isMultipartUpload(r)
+
+	capabilities := d.capabilities(r.URL.Path)
+	if r.Method == http.MethodPost && multipart && !capabilities.CanUpload {
 		return http.StatusForbidden, nil
 	}
 
-	if !d.user.Perm.Modify && r.Method == http.MethodPut {
+	if r.Method == http.MethodPost && !multipart && (!capabilities.CanUpload || strings.HasSuffix(r.URL.Path, "/") && !capabilities.CanMkdir) {
 		return http.StatusForbidden, nil
 	}
 
+	// The uploads by URL track the progress of the fetch rather than of
+	// the request.
+	if r.Method == http.MethodPost && r.URL.Query().Get("action") == "fetch" {
+		return fetchHandler(w, r, d)
+	}
+
+	defer trackUpload(r, d)()
+
 	defer func() {
 		io.Copy(ioutil.Discard, r.Body)
 	}()
 
+	if multipart {
+		return uploadPartsHandler(w, r, d)
+	}
+
+	if r.Method == http.MethodPost && !validName(path.Base(r.URL.Path)) {
+		return renderFailure(w, r, http.StatusBadRequest, "invalid name")
+	}
+
 	// For directories, only allow POST for creation.
 	if strings.HasSuffix(r.URL.Path, "/") {
 		if r.Method == http.MethodPut {
 			return http.StatusMethodNotAllowed, nil
 		}
 
-		err := d.user.Fs.MkdirAll(r.URL.Path, 0775)
-		return errToStatus(err), err
-	}
-
-	if r.Method == http.MethodPost && r.URL.Query().Get("override") != "true" {
-		if _, err := d.user.Fs.Stat(r.URL.Path); err == nil {
-			return http.StatusConflict, nil
+		// The directories that exist are kept, as they always were,
+		// unless the request is strict.
+		if strictRequest(r) && !overwriteRequested(r) {
+			if _, err := d.user.Fs.Stat(r.URL.Path); err == nil {
+				return renderFailure(w, r, http.StatusConflict, "already exists")
+			}
 		}
-	}
 
-	err := d.RunHook(func() error {
-		file, err := d.user.Fs.OpenFile(r.URL.Path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0775)
+		err := d.RunHook(func() error {
+			return d.user.Fs.MkdirAll(r.URL.Path, d.settings.NewDirMode())
+		}, "mkdir", r.URL.Path, "", -1, d.user)
 		if err != nil {
-			return err
+			return errToStatus(err), err
 		}
-		defer file.Close()
 
-		_, err = io.Copy(file, r.Body)
-		if err != nil {
-			return err
+		d.notify(settings.EventMkdir, r.URL.Path, "", 0)
+		return renderCreated(w, r, d)
+	}
+
+	if !uploadAllowed(d, r.URL.Path) {
+		return renderFileFailure(w, r, http.StatusUnsupportedMediaType, r.URL.Path, refusedExtension)
+	}
+
+	// The bodies that are too long are cut, so the ones without a length
+	// can't go over the maximum size of the uploads either.
+	if maxSize := d.settings.Uploads.MaxSize; maxSize > 0 {
+		if r.ContentLength > maxSize {
+			return renderFileFailure(w, r, http.StatusRequestEntityTooLarge, r.URL.Path, fbErrors.ErrTooLarge.Error())
 		}
 
-		// Gets the info about the file.
-		info, err := file.Stat()
-		if err != nil {
-			return err
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	}
+
+	if r.Method == http.MethodPut && r.Header.Get("Content-Range") != "" {
+		return chunkHandler(w, r, d)
+	}
+
+	if r.Method == http.MethodPut {
+		return replaceHandler(w, r, d)
+	}
+
+	if r.Method == http.MethodPost && !overwriteRequested(r) {
+		if _, err := d.user.Fs.Stat(r.URL.Path); err == nil {
+			return renderFailure(w, r, http.StatusConflict, "already exists")
 		}
+	}
 
-		etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
-		w.Header().Set("ETag", etag)
-		return nil
-	}, "upload", r.URL.Path, "", d.user)
+	info, err := writeUpload(d, r.URL.Path, r.Body, r.ContentLength)
+	if err == fbErrors.ErrTooLarge {
+		return renderFileFailure(w, r, http.StatusRequestEntityTooLarge, r.URL.Path, err.Error())
+	} else if err != nil {
+		return errToStatus(err), err
+	}
 
-	return errToStatus(err), err
+	w.Header().Set("ETag", currentETag(d.user.Fs, r.URL.Path, info.ModTime(), info.Size()))
+	return renderCreated(w, r, d)
 })
 
-var resourcePatchHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	src := r.URL.Path
-	dst := r.URL.Query().Get("destination")
-	action := r.URL.Query().Get("action")
-	dst, err := url.QueryUnescape(dst)
+// renderCreated writes the file created by the request as a row of the
+// listings if the client accepts JSON, so it can show it without listing
+// the directory again.
+func renderCreated(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	return renderListed(w, r, d, strings.TrimSuffix(r.URL.Path, "/"))
+}
+
+// renderListed writes the file at p as a row of the listings if the
+// client accepts JSON or sent it.
+func renderListed(w http.ResponseWriter, r *http.Request, d *data, p string) (int, error) {
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") && !hasJSONBody(r) {
+		return http.StatusOK, nil
+	}
 
+	file, err := newListedFile(r, d, p)
 	if err != nil {
 		return errToStatus(err), err
 	}
 
+	return renderJSON(w, r, file)
+}
+
+// patchRequest is the JSON body of the PATCH requests, which can give
+// what their query parameters give. The ones with a mode change the mode
+// of the file, and of the ones under it if they're recursive, rather than
+// moving it.
+type patchRequest struct {
+	Action      string `json:"action"`
+	Destination string `json:"destination"`
+	Overwrite   bool   `json:"overwrite"`
+	Override    bool   `json:"override"` // the older name of Overwrite
+	CrossScope  bool   `json:"crossScope"`
+	Mode        string `json:"mode"`
+	Recursive   bool   `json:"recursive"`
+}
+
+// parsePatchRequest returns the request of the JSON body, or of the query
+// parameters if there's none.
+func parsePatchRequest(r *http.Request) (*patchRequest, error) {
+	if hasJSONBody(r) {
+		req := &patchRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return nil, err
+		}
+		req.Overwrite = req.Overwrite || req.Override
+		return req, nil
+	}
+
+	dst, err := url.QueryUnescape(r.URL.Query().Get("destination"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The destinations are replaced, as they always were, unless the
+	// request is strict.
+	return &patchRequest{
+		Action:      r.URL.Query().Get("action"),
+		Destination: dst,
+		Overwrite:   overwriteRequested(r) || !strictRequest(r),
+		CrossScope:  r.URL.Query().Get("crossScope") == "true",
+	}, nil
+}
+
+// strictRequest checks if the request for a resource asks, with
+// strict=true, for the conflicts and the missing files to fail rather
+// than be replaced or ignored. The other clients of the API keep the
+// behaviour they were written for.
+func strictRequest(r *http.Request) bool {
+	return r.URL.Query().Get("strict") == "true"
+}
+
+// overwriteRequested checks if the request asks for the existing files to
+// be replaced, with overwrite=true or with override=true, its older name.
+func overwriteRequested(r *http.Request) bool {
+	return r.URL.Query().Get("overwrite") == "true" || r.URL.Query().Get("override") == "true"
+}
+
+// hasJSONBody checks if the body of the request is JSON.
+func hasJSONBody(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// resourcePatchHandler renames, moves or copies a file or a directory to
+// the destination of the query parameters or of the JSON body, or changes
+// its mode. A directory can't go into itself. The copies answer with 201
+// and the paths under the original that couldn't be copied, which don't
+// stop them.
+var resourcePatchHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	req, err := parsePatchRequest(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if req.Mode != "" {
+		return chmodHandler(w, r, d, req)
+	}
+
+	src := path.Clean("/" + r.URL.Path)
+	dst := path.Clean("/" + req.Destination)
+	action := req.Action
+
 	if dst == "/" || src == "/" {
 		return http.StatusForbidden, nil
 	}
 
+	if strings.HasPrefix(dst, src+"/") {
+		return renderFailure(w, r, http.StatusBadRequest, "a directory can't go into itself")
+	}
+
+	if action == "copy" && dst == src {
+		return renderFailure(w, r, http.StatusBadRequest, "a file can't be copied onto itself")
+	}
+
 	switch action {
 	case "copy":
-		if !d.user.Perm.Create {
+		if !d.Check(src) || !d.capabilities(dst).CanUpload {
 			return http.StatusForbidden, nil
 		}
-	case "rename":
 	default:
 		action = "rename"
-		if !d.user.Perm.Rename {
+		if !d.capabilities(src).CanRename || !d.capabilities(dst).CanRename {
 			return http.StatusForbidden, nil
 		}
 	}
 
+	// The moves to another area of the scope, such as another alias, are
+	// copies, which are only made when asked for.
+	if action == "rename" && req.CrossScope && crossesAreas(d, src, dst) {
+		if err := crossMove(d, src, dst); err != nil {
+			return errToStatus(err), err
+		}
+
+		return renderListed(w, r, d, dst)
+	}
+
+	if !req.Overwrite {
+		if _, err := d.user.Fs.Stat(dst); err == nil {
+			return http.StatusConflict, nil
+		}
+	}
+
+	release, err := lockPath(d, dst)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer release()
+
+	if action == "rename" {
+		releaseSrc, err := lockPath(d, src)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		defer releaseSrc()
+	}
+
+	var failed []pathFailure
 	err = d.RunHook(func() error {
 		if action == "copy" {
-			return fileutils.Copy(d.user.Fs, src, dst)
+			var err error
+			failed, err = copyTree(d, src, dst)
+			return err
 		}
 
 		return d.user.Fs.Rename(src, dst)
-	}, action, src, dst, d.user)
+	}, action, src, dst, -1, d.user)
+
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	d.notifyMove(action, src, dst)
+
+	if action == "copy" {
+		return renderCopied(w, r, d, dst, failed)
+	}
 
-	return errToStatus(err), err
+	return renderListed(w, r, d, dst)
 })
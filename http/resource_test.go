@@ -0,0 +1,173 @@
+package http_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+func TestPatchConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		body     string
+		status   int
+		replaced bool
+	}{
+		{"replaced by default", "?action=rename&destination=/b.txt", "", http.StatusOK, true},
+		{"strict", "?action=rename&destination=/b.txt&strict=true", "", http.StatusConflict, false},
+		{"strict with override", "?action=rename&destination=/b.txt&strict=true&override=true", "", http.StatusOK, true},
+		{"JSON", "", `{"destination": "/b.txt"}`, http.StatusConflict, false},
+		{"strict with overwrite", "?action=rename&destination=/b.txt&strict=true&overwrite=true", "", http.StatusOK, true},
+		{"JSON with overwrite", "", `{"destination": "/b.txt", "overwrite": true}`, http.StatusOK, true},
+		{"JSON with override", "", `{"destination": "/b.txt", "override": true}`, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, map[string]filebrowsertest.File{
+				"/a.txt": {Content: "a"},
+				"/b.txt": {Content: "b"},
+			})
+
+			headers := []string{"Accept", "application/json"}
+			if tt.body != "" {
+				headers = append(headers, "Content-Type", "application/json")
+			}
+
+			w := do(t, srv, "PATCH", "/api/resources/a.txt"+tt.query, tt.body, headers...)
+			if w.Code != tt.status {
+				t.Fatalf("PATCH = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			content, err := afero.ReadFile(fs, "/b.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if replaced := string(content) == "a"; replaced != tt.replaced {
+				t.Errorf("the destination has %q, want replaced: %v", content, tt.replaced)
+			}
+		})
+	}
+}
+
+func TestPostExistingDirectory(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"kept by default", "", http.StatusOK},
+		{"strict", "?strict=true", http.StatusConflict},
+		{"strict with override", "?strict=true&override=true", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, map[string]filebrowsertest.File{"/docs/a.txt": {Content: "a"}})
+
+			w := do(t, srv, "POST", "/api/resources/docs/"+tt.query, "")
+			if w.Code != tt.status {
+				t.Fatalf("POST = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if _, err := fs.Stat("/docs/a.txt"); err != nil {
+				t.Errorf("the file of the directory is gone: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteMissing(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"succeeds by default", "", http.StatusOK},
+		{"strict", "?strict=true", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "a"}})
+
+			if w := do(t, srv, "DELETE", "/api/resources/missing.txt"+tt.query, ""); w.Code != tt.status {
+				t.Errorf("DELETE = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestDeleteDirectory(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		status  int
+		deleted bool
+	}{
+		{"recursive by default", "", http.StatusOK, true},
+		{"strict", "?strict=true", http.StatusConflict, false},
+		{"strict and recursive", "?strict=true&recursive=true", http.StatusNoContent, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := newServer(t, map[string]filebrowsertest.File{"/docs/a.txt": {Content: "a"}})
+
+			w := do(t, srv, "DELETE", "/api/resources/docs"+tt.query, "")
+			if w.Code != tt.status {
+				t.Fatalf("DELETE = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			_, err := fs.Stat("/docs")
+			if deleted := err != nil; deleted != tt.deleted {
+				t.Errorf("the directory was deleted: %v, want %v", deleted, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestRedirectDirectory(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		accept   string
+		status   int
+		location string
+	}{
+		{"HTML", "GET", "/api/resources/my%20docs", "text/html", http.StatusMovedPermanently, "my%20docs/"},
+		{"HTML with the query", "GET", "/api/resources/my%20docs?sort=size", "text/html", http.StatusMovedPermanently, "my%20docs/?sort=size"},
+		{"HTML format", "GET", "/api/resources/my%20docs?format=html", "", http.StatusMovedPermanently, "my%20docs/?format=html"},
+		{"HEAD", "HEAD", "/api/resources/my%20docs", "text/html", http.StatusMovedPermanently, "my%20docs/"},
+		{"hash", "GET", "/api/resources/a%23b", "text/html", http.StatusMovedPermanently, "a%23b/"},
+		{"JSON", "GET", "/api/resources/my%20docs", "application/json", http.StatusOK, ""},
+		{"no Accept", "GET", "/api/resources/my%20docs", "", http.StatusOK, ""},
+		{"JSON format", "GET", "/api/resources/my%20docs?format=json", "text/html", http.StatusOK, ""},
+		{"with the slash", "GET", "/api/resources/my%20docs/", "text/html", http.StatusOK, ""},
+		{"write", "PATCH", "/api/resources/my%20docs?action=rename&destination=/other", "text/html", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, map[string]filebrowsertest.File{
+				"/my docs/a.txt": {Content: "a"},
+				"/a#b/a.txt":     {Content: "a"},
+			})
+
+			w := do(t, srv, tt.method, tt.target, "", "Accept", tt.accept)
+			if w.Code != tt.status {
+				t.Fatalf("%s = %d, want %d: %s", tt.method, w.Code, tt.status, w.Body)
+			}
+
+			if location := w.Header().Get("Location"); location != tt.location {
+				t.Errorf("the location is %q, want %q", location, tt.location)
+			}
+		})
+	}
+}
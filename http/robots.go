@@ -0,0 +1,76 @@
+package http
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+)
+
+// noIndex checks if search engines should not index the page at p.
+func noIndex(paths []string, p string) bool {
+	for _, prefix := range paths {
+		// They match like the path rules, whole segments at a time.
+		rule := rules.Rule{Path: prefix}
+		if rule.Matches(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// robotsDisallow returns the paths to disallow in robots.txt, without
+// the ones already covered by another entry.
+func robotsDisallow(baseURL string, paths []string) []string {
+	cleaned := make([]string, 0, len(paths))
+	for _, p := range paths {
+		cleaned = append(cleaned, path.Clean("/"+p))
+	}
+
+	sort.Strings(cleaned)
+
+	kept := []string{}
+	for _, p := range cleaned {
+		if !noIndex(kept, p) {
+			kept = append(kept, p)
+		}
+	}
+
+	entries := make([]string, len(kept))
+	for i, p := range kept {
+		entries[i] = baseURL + p
+	}
+
+	return entries
+}
+
+var robotsHandler = func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if d.settings.Branding.Files != "" {
+		file := filepath.Join(d.settings.Branding.Files, "robots.txt")
+		if _, err := os.Stat(file); err == nil {
+			http.ServeFile(w, r, file)
+			return 0, nil
+		}
+	}
+
+	if len(d.settings.NoIndex) == 0 {
+		return http.StatusNotFound, nil
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	body := "User-agent: *\n"
+	for _, entry := range robotsDisallow(d.baseURL(r), d.settings.NoIndex) {
+		body += "Disallow: " + entry + "\n"
+	}
+
+	if _, err := w.Write([]byte(body)); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,20 @@
+package http_test
+
+import (
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestRobots(t *testing.T) {
+	srv, _ := newServer(t, nil)
+	updateSettings(t, srv, func(s *settings.Settings) {
+		s.NoIndex = []string{"/private/drafts", "/private/", "pub", "/publicity"}
+	})
+
+	w := do(t, srv, "GET", "/robots.txt", "")
+	want := "User-agent: *\nDisallow: /private\nDisallow: /pub\nDisallow: /publicity\n"
+	if w.Body.String() != want {
+		t.Errorf("robots.txt is %q, want %q", w.Body, want)
+	}
+}
@@ -0,0 +1,113 @@
+package http_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestRules(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/private/a.txt":      {Content: "a"},
+		"/private/sub/b.txt":  {Content: "b"},
+		"/drafts/c.txt":       {Content: "c"},
+		"/docs/d.txt":         {Content: "d"},
+		"/docs/secret.key":    {Content: "key"},
+		"/docs/keys/e.txt":    {Content: "e"},
+		"/docs/keys/open.key": {Content: "open"},
+	})
+	updateUser(t, srv, func(u *users.User) {
+		u.Rules = []rules.Rule{
+			{Glob: true, Path: "/private/**"},
+			{Path: "/drafts", Methods: []string{"POST", "PUT", "PATCH", "DELETE"}},
+			{Glob: true, Path: "/**/*.key"},
+			{Glob: true, Path: "/docs/keys/*.key", Allow: true},
+		}
+	})
+	token := csrfToken(t, srv)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"hidden directory", "GET", "/api/resources/private/", "", http.StatusNotFound},
+		{"hidden file", "GET", "/api/resources/private/a.txt", "", http.StatusNotFound},
+		{"hidden nested file", "GET", "/api/raw/private/sub/b.txt", "", http.StatusNotFound},
+		{"hidden extension", "GET", "/api/raw/docs/secret.key", "", http.StatusNotFound},
+		{"allowed by a more specific glob", "GET", "/api/raw/docs/keys/open.key", "", http.StatusOK},
+		{"read-only directory", "GET", "/api/raw/drafts/c.txt", "", http.StatusOK},
+		{"HEAD is checked as GET", "HEAD", "/api/raw/drafts/c.txt", "", http.StatusOK},
+		{"edit in read-only directory", "PUT", "/api/resources/drafts/c.txt", "changed", http.StatusForbidden},
+		{"upload in read-only directory", "POST", "/api/resources/drafts/new.txt", "new", http.StatusForbidden},
+		{"delete in read-only directory", "DELETE", "/api/resources/drafts/c.txt?purge=true", "", http.StatusForbidden},
+		{"move out of read-only directory", "PATCH", "/api/resources/drafts/c.txt?action=rename&destination=/docs/c.txt", "", http.StatusForbidden},
+		{"edit elsewhere", "PUT", "/api/resources/docs/d.txt", "changed", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, srv, tt.method, tt.target, tt.body, "X-CSRF-Token", token)
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.target, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	listings := []struct {
+		dir  string
+		want []string
+	}{
+		{"/", []string{"docs", "drafts"}},
+		{"/docs", []string{"d.txt", "keys"}},
+		{"/docs/keys", []string{"e.txt", "open.key"}},
+	}
+
+	for _, tt := range listings {
+		t.Run("listing "+tt.dir, func(t *testing.T) {
+			listing, err := srv.Listing(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, item := range listing.Items {
+				names = append(names, item.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("the listing has %q, want %q", names, tt.want)
+			}
+		})
+	}
+
+	t.Run("search", func(t *testing.T) {
+		w := do(t, srv, "GET", "/api/resources/?search=.", "", "Accept", "application/json")
+		if w.Code != http.StatusOK {
+			t.Fatalf("the search got %d: %s", w.Code, w.Body)
+		}
+		for _, name := range []string{"private", "a.txt", "b.txt", "secret.key"} {
+			if strings.Contains(w.Body.String(), name) {
+				t.Errorf("the search found %s: %s", name, w.Body)
+			}
+		}
+		if !strings.Contains(w.Body.String(), "open.key") {
+			t.Errorf("the search didn't find open.key: %s", w.Body)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		w := do(t, srv, "GET", "/api/resources/", "", "Accept", "text/html")
+		if w.Code != http.StatusOK {
+			t.Fatalf("the listing got %d: %s", w.Code, w.Body)
+		}
+		if strings.Contains(w.Body.String(), "private") {
+			t.Errorf("the HTML listing shows private: %s", w.Body)
+		}
+	})
+}
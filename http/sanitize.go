@@ -0,0 +1,178 @@
+package http
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedElements are the elements sanitizeHTML keeps, with the
+// attributes they keep. The other elements are replaced by their
+// contents.
+var allowedElements = map[string][]string{
+	"a":          {"href", "title"},
+	"img":        {"src", "alt", "title", "width", "height"},
+	"p":          nil,
+	"br":         nil,
+	"hr":         nil,
+	"h1":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"h5":         nil,
+	"h6":         nil,
+	"strong":     nil,
+	"b":          nil,
+	"em":         nil,
+	"i":          nil,
+	"u":          nil,
+	"s":          nil,
+	"del":        nil,
+	"small":      nil,
+	"sub":        nil,
+	"sup":        nil,
+	"code":       nil,
+	"pre":        nil,
+	"blockquote": nil,
+	"ul":         nil,
+	"ol":         {"start"},
+	"li":         nil,
+	"dl":         nil,
+	"dt":         nil,
+	"dd":         nil,
+	"table":      nil,
+	"thead":      nil,
+	"tbody":      nil,
+	"tfoot":      nil,
+	"tr":         nil,
+	"th":         {"colspan", "rowspan", "align"},
+	"td":         {"colspan", "rowspan", "align"},
+	"div":        nil,
+	"span":       nil,
+}
+
+// droppedElements are removed by sanitizeHTML with their contents.
+var droppedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"title":    true,
+	"iframe":   true,
+	"frame":    true,
+	"object":   true,
+	"embed":    true,
+	"template": true,
+	"noscript": true,
+	"textarea": true,
+	"select":   true,
+	"svg":      true,
+	"math":     true,
+}
+
+var voidElements = map[string]bool{
+	"br":  true,
+	"hr":  true,
+	"img": true,
+}
+
+// sanitizeHTML keeps the elements and attributes of src that can't run
+// scripts or change the page around them. The relative URLs are passed
+// through resolve.
+func sanitizeHTML(src string, resolve func(string) string) (string, error) {
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, n := range nodes {
+		sanitizeNode(&b, n, resolve)
+	}
+
+	return b.String(), nil
+}
+
+func sanitizeNode(b *strings.Builder, n *html.Node, resolve func(string) string) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(html.EscapeString(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if droppedElements[n.Data] {
+		return
+	}
+
+	attrs, allowed := allowedElements[n.Data]
+	if allowed {
+		b.WriteString("<" + n.Data)
+		for _, attr := range n.Attr {
+			if attr.Namespace != "" || !contains(attrs, attr.Key) {
+				continue
+			}
+
+			value := attr.Val
+			if attr.Key == "href" || attr.Key == "src" {
+				if value = safeURL(value, resolve); value == "" {
+					continue
+				}
+			}
+
+			b.WriteString(" " + attr.Key + `="` + html.EscapeString(value) + `"`)
+		}
+		b.WriteString(">")
+
+		if voidElements[n.Data] {
+			return
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sanitizeNode(b, c, resolve)
+	}
+
+	if allowed {
+		b.WriteString("</" + n.Data + ">")
+	}
+}
+
+// safeURL returns the URL if it can't run scripts, or an empty string
+// otherwise. The relative URLs are passed through resolve.
+func safeURL(raw string, resolve func(string) string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return raw
+	case "":
+		if u.Host != "" || strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "#") {
+			return raw
+		}
+
+		return resolve(raw)
+	default:
+		return ""
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
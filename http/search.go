@@ -1,28 +1,160 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 
+	"github.com/filebrowser/filebrowser/v2/files"
 	"github.com/filebrowser/filebrowser/v2/search"
 )
 
+// errSearchLimit stops a search once it found enough results.
+var errSearchLimit = errors.New("search limit reached")
+
+// searchResults searches for query in scope. If limit is positive, the
+// search stops after that many results and reports it was truncated.
+func searchResults(d *data, scope, query string, limit int, found func(path string, f os.FileInfo)) (bool, error) {
+	n := 0
+	err := search.Search(d.user.Fs, scope, query, d, func(path string, f os.FileInfo) error {
+		if limit > 0 && n == limit {
+			return errSearchLimit
+		}
+
+		n++
+		found(path, f)
+		return nil
+	})
+
+	if err == errSearchLimit {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// listingChecker checks the paths of the searches of the listings with
+// the rules of the user and, unless they are shown, skips the files whose
+// names start with a dot and the directories under them. It skips trash,
+// the trash directory, when the search doesn't start in it.
+type listingChecker struct {
+	*data
+	hide  bool
+	trash string
+}
+
+// Check implements rules.Checker.
+func (c *listingChecker) Check(p string) bool {
+	if c.hide && strings.HasPrefix(path.Base(p), ".") {
+		return false
+	}
+
+	return c.data.Check(p)
+}
+
+// Skip implements search.Skipper.
+func (c *listingChecker) Skip(p string) bool {
+	return c.hide && strings.HasPrefix(path.Base(p), ".") || c.trash != "" && p == c.trash
+}
+
+// searchListing returns the files under dir whose names match query as a
+// listing, up to the maximum number of results of the settings, which
+// sets ItemsLimitedTo and Truncated once it's reached. The names of the
+// items are their paths relative to dir, and so are their URLs, escaped,
+// so they can be followed from the listing of dir.
+func searchListing(r *http.Request, d *data, dir, query string) (*files.Listing, error) {
+	listing := &files.Listing{Items: []*files.FileInfo{}}
+	checker := &listingChecker{data: d, hide: !showHidden(r, d)}
+	if !inTrash(d, dir) {
+		checker.trash = d.settings.TrashPath()
+	}
+	limit := d.settings.ListingSearchLimit()
+
+	n := 0
+	err := search.SearchNames(r.Context(), d.user.Fs, dir, query, checker, func(p string, f os.FileInfo) error {
+		if n == limit {
+			return errSearchLimit
+		}
+		n++
+
+		p = strings.TrimPrefix(p, "/")
+		item := &files.FileInfo{
+			Fs:        d.user.Fs,
+			Path:      path.Join("/", dir, p),
+			Name:      p,
+			Size:      f.Size(),
+			Extension: path.Ext(p),
+			ModTime:   f.ModTime(),
+			ModUnix:   f.ModTime().Unix(),
+			Mode:      f.Mode(),
+			OctalMode: files.OctalMode(f.Mode()),
+			IsDir:     f.IsDir(),
+			Hidden:    files.IsHidden(f),
+			URL:       (&url.URL{Path: p}).String(),
+		}
+		item.Owner, item.Group = files.Ownership(f)
+
+		if item.IsDir {
+			item.URL += "/"
+			listing.NumDirs++
+		} else {
+			listing.NumFiles++
+		}
+
+		if err := item.Classify(d.settings.Categories); err == nil {
+			listing.Items = append(listing.Items, item)
+		}
+		return nil
+	})
+
+	if err == errSearchLimit {
+		listing.ItemsLimitedTo = limit
+		listing.Truncated = true
+		err = nil
+	}
+
+	return listing, err
+}
+
+// searchResult is a result of the search API. Path is relative to the
+// searched directory.
+type searchResult struct {
+	Dir  bool   `json:"dir"`
+	Path string `json:"path"`
+}
+
 var searchHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	response := []map[string]interface{}{}
+	if apiOnly(d) {
+		return http.StatusNotFound, nil
+	}
+
+	response := []searchResult{}
 	query := r.URL.Query().Get("query")
 
-	err := search.Search(d.user.Fs, r.URL.Path, query, d, func(path string, f os.FileInfo) error {
-		response = append(response, map[string]interface{}{
-			"dir":  f.IsDir(),
-			"path": path,
-		})
+	limit := 0
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return http.StatusBadRequest, err
+		}
+	}
 
-		return nil
+	truncated, err := searchResults(d, r.URL.Path, query, limit, func(path string, f os.FileInfo) {
+		response = append(response, searchResult{Dir: f.IsDir(), Path: path})
 	})
 
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
+	if truncated {
+		w.Header().Set("X-Results-Truncated", "true")
+	}
+
 	return renderJSON(w, r, response)
 })
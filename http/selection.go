@@ -0,0 +1,107 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mholt/archiver"
+)
+
+// maxSelectionSize is the size of the biggest selection of files archived.
+const maxSelectionSize = 1 << 20
+
+// archiveSelectionHandler streams a zip of the files and the directories
+// of the requested directory that the body selects, as a JSON array of
+// their paths relative to it, which keep their structure in the archive.
+// The whole selection is checked before the archive starts: the paths
+// that leave the directory fail with 400 and the missing ones with 404,
+// both naming the path. The directories are archived with everything
+// below them, but for the files hidden from their listings.
+func archiveSelectionHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	dir := path.Clean("/" + r.URL.Path)
+	if !d.user.Perm.Download || !d.capabilities(dir).CanDownloadArchive {
+		return http.StatusForbidden, nil
+	}
+
+	info, err := d.user.Fs.Stat(dir)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return renderFailure(w, r, http.StatusBadRequest, "only the files of the directories are archived")
+	}
+
+	var selection []string
+	err = json.NewDecoder(io.LimitReader(r.Body, maxSelectionSize)).Decode(&selection)
+	if err != nil {
+		return renderFailure(w, r, http.StatusBadRequest, "the body must be a JSON array of the paths of the files")
+	}
+
+	if len(selection) == 0 {
+		return renderFailure(w, r, http.StatusBadRequest, "the selection is empty")
+	}
+
+	hide := !showHidden(r, d)
+	paths := make([]string, 0, len(selection))
+	for _, name := range selection {
+		clean := path.Clean(name)
+		if name == "" || strings.HasPrefix(name, "/") || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return renderFailure(w, r, http.StatusBadRequest, "the path isn't under the directory: "+name)
+		}
+
+		p := path.Join(dir, clean)
+		if !d.Check(p) {
+			return renderFailure(w, r, http.StatusNotFound, "not found: "+name)
+		}
+
+		_, err := d.user.Fs.Stat(p)
+		switch {
+		case os.IsNotExist(err):
+			return renderFailure(w, r, http.StatusNotFound, "not found: "+name)
+		case err != nil:
+			return errToStatus(err), err
+		}
+
+		paths = append(paths, p)
+	}
+
+	// The paths under a directory of the selection are already in its
+	// entry, so they are left out rather than archived twice.
+	sort.Strings(paths)
+	selected := paths[:0]
+	for _, p := range paths {
+		if n := len(selected); n > 0 && (p == selected[n-1] || strings.HasPrefix(p, selected[n-1]+"/")) {
+			continue
+		}
+		selected = append(selected, p)
+	}
+
+	name := path.Base(dir)
+	if name == "/" {
+		name = "archive"
+	}
+	w.Header().Set("Content-Disposition", attachment(name+".zip"))
+
+	ar := archiver.NewZip()
+	w = d.countDownload(w)
+	if err := ar.Create(w); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer ar.Close()
+
+	// The archive has started, so a failure can only cut it short.
+	for _, p := range selected {
+		if err := addArchiveEntry(ar, d, dir, p, hide); err != nil {
+			d.logger.Warn("couldn't finish the archive", "path", dir, "error", err)
+			break
+		}
+	}
+
+	return 0, nil
+}
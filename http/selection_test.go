@@ -0,0 +1,94 @@
+package http_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestArchiveSelection(t *testing.T) {
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt":         {Content: "a"},
+		"/docs/b.txt":         {Content: "b"},
+		"/docs/sub/c.txt":     {Content: "c"},
+		"/docs/sub/.hidden":   {Content: "h"},
+		"/docs/sub/deep/d.md": {Content: "d"},
+		"/docs/.env":          {Content: "e"},
+		"/secret.txt":         {Content: "s"},
+	})
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		want   string // the files of the archive, or what the error says
+	}{
+		{"files", "/docs/", `["a.txt", "b.txt"]`, http.StatusOK, "a.txt b.txt"},
+		{"directory", "/docs/", `["sub"]`, http.StatusOK, "sub/ sub/c.txt sub/deep/ sub/deep/d.md"},
+		{"nested", "/docs/", `["sub/deep/d.md", "a.txt"]`, http.StatusOK, "a.txt sub/deep/d.md"},
+		{"once", "/docs/", `["sub", "sub/c.txt", "a.txt", "a.txt", "./a.txt"]`, http.StatusOK, "a.txt sub/ sub/c.txt sub/deep/ sub/deep/d.md"},
+		{"cleaned", "/docs/", `["sub/../b.txt"]`, http.StatusOK, "b.txt"},
+		{"hidden", "/docs/?showhidden=true", `["sub", ".env"]`, http.StatusOK, ".env sub/ sub/.hidden sub/c.txt sub/deep/ sub/deep/d.md"},
+		{"subdirectory", "/docs/sub/", `["c.txt", "deep"]`, http.StatusOK, "c.txt deep/ deep/d.md"},
+		{"parent", "/docs/", `["a.txt", "../secret.txt"]`, http.StatusBadRequest, "../secret.txt"},
+		{"parent through a directory", "/docs/", `["sub/../../secret.txt"]`, http.StatusBadRequest, "sub/../../secret.txt"},
+		{"absolute", "/docs/", `["/secret.txt"]`, http.StatusBadRequest, "/secret.txt"},
+		{"itself", "/docs/", `["."]`, http.StatusBadRequest, "."},
+		{"empty path", "/docs/", `[""]`, http.StatusBadRequest, "the path isn't under the directory"},
+		{"missing", "/docs/", `["a.txt", "missing.txt"]`, http.StatusNotFound, "missing.txt"},
+		{"empty selection", "/docs/", `[]`, http.StatusBadRequest, "the selection is empty"},
+		{"not an array", "/docs/", `{"a.txt": true}`, http.StatusBadRequest, "JSON array"},
+		{"file", "/docs/a.txt", `["b.txt"]`, http.StatusBadRequest, "only the files of the directories"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/resources" + tt.target
+			if strings.Contains(target, "?") {
+				target += "&action=archive"
+			} else {
+				target += "?action=archive"
+			}
+
+			w := do(t, srv, "POST", target, tt.body, "Accept", "application/json", "X-CSRF-Token", csrfToken(t, srv))
+			if w.Code != tt.status {
+				t.Fatalf("POST = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if w.Code != http.StatusOK {
+				if !strings.Contains(w.Body.String(), tt.want) {
+					t.Errorf("the error %s doesn't say %q", w.Body, tt.want)
+				}
+				return
+			}
+
+			archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, file := range archive.File {
+				names = append(names, file.Name)
+			}
+			sort.Strings(names)
+
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("the archive has %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	updateUser(t, srv, func(u *users.User) { u.Perm.Download = false })
+	w := do(t, srv, "POST", "/api/resources/docs/?action=archive", `["a.txt"]`, "X-CSRF-Token", csrfToken(t, srv))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("POST without the download permission = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,92 @@
+package http_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// newServer returns a filebrowsertest.Server on the files.
+func newServer(t *testing.T, files map[string]filebrowsertest.File, opts ...settings.Option) (*filebrowsertest.Server, *filebrowsertest.FS) {
+	t.Helper()
+
+	fs := filebrowsertest.NewFS(files)
+	srv, err := filebrowsertest.New(fs, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return srv, fs
+}
+
+// do runs a request with method on target with body, if any, and the
+// headers, as key and value pairs.
+func do(t *testing.T, srv *filebrowsertest.Server, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	r := httptest.NewRequest(method, target, reader)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	w, err := srv.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+// updateSettings changes the settings of srv with change.
+func updateSettings(t *testing.T, srv *filebrowsertest.Server, change func(*settings.Settings)) {
+	t.Helper()
+
+	set, err := srv.Storage.Settings.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change(set)
+	if err := srv.Storage.Settings.Save(set); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// csrfToken returns the CSRF token of the user of srv, which its
+// listings are sent with.
+func csrfToken(t *testing.T, srv *filebrowsertest.Server) string {
+	t.Helper()
+
+	w := do(t, srv, "GET", "/api/resources/", "")
+	token := w.Header().Get("X-CSRF-Token")
+	if token == "" {
+		t.Fatalf("the listing has no CSRF token: %d", w.Code)
+	}
+
+	return token
+}
+
+// updateUser changes the user of srv with change.
+func updateUser(t *testing.T, srv *filebrowsertest.Server, change func(*users.User)) {
+	t.Helper()
+
+	user, err := srv.Storage.Users.Get("/", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change(user)
+	if err := srv.Storage.Users.Update(user); err != nil {
+		t.Fatal(err)
+	}
+}
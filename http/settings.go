@@ -9,24 +9,104 @@ import (
 )
 
 type settingsData struct {
-	Signup        bool                  `json:"signup"`
-	CreateUserDir bool                  `json:"createUserDir"`
-	Defaults      settings.UserDefaults `json:"defaults"`
-	Rules         []rules.Rule          `json:"rules"`
-	Branding      settings.Branding     `json:"branding"`
-	Shell         []string              `json:"shell"`
-	Commands      map[string][]string   `json:"commands"`
+	Signup                   bool                  `json:"signup"`
+	CreateUserDir            bool                  `json:"createUserDir"`
+	Defaults                 settings.UserDefaults `json:"defaults"`
+	Rules                    []rules.Rule          `json:"rules"`
+	Branding                 settings.Branding     `json:"branding"`
+	Tree                     settings.Tree         `json:"tree"`
+	ListingCache             settings.ListingCache `json:"listingCache"`
+	Live                     settings.Live         `json:"live"`
+	Shell                    []string              `json:"shell"`
+	Commands                 map[string][]string   `json:"commands"`
+	NormalizeNames           bool                  `json:"normalizeNames"`
+	CaseInsensitive          bool                  `json:"caseInsensitive"`
+	PlainTextCLI             bool                  `json:"plainTextCLI"`
+	NoIndex                  []string              `json:"noIndex"`
+	TrackChanges             bool                  `json:"trackChanges"`
+	DirTemplates             bool                  `json:"dirTemplates"`
+	GitStatus                bool                  `json:"gitStatus"`
+	DocMeta                  bool                  `json:"docMeta"`
+	DirOptions               string                `json:"dirOptions"`
+	TrashDir                 string                `json:"trashDir"`
+	ShowHidden               bool                  `json:"showHidden"`
+	DirsFirst                bool                  `json:"dirsFirst"`
+	DirSizes                 bool                  `json:"dirSizes"`
+	DirMode                  string                `json:"dirMode"`
+	AllowSpecialBits         bool                  `json:"allowSpecialBits"`
+	MaxEditSize              int64                 `json:"maxEditSize"`
+	RequireConditionalWrites bool                  `json:"requireConditionalWrites"`
+	MaxChecksumSize          int64                 `json:"maxChecksumSize"`
+	SearchLimit              int                   `json:"searchLimit"`
+	FeedLimit                int                   `json:"feedLimit"`
+	ListingIndex             string                `json:"listingIndex"`
+	Readmes                  []string              `json:"readmes"`
+	DateFormat               string                `json:"dateFormat"`
+	Timezone                 string                `json:"timezone"`
+	SortLocale               string                `json:"sortLocale"`
+	DefaultLimit             int                   `json:"defaultLimit"`
+	MaxLimit                 int                   `json:"maxLimit"`
+	Symlinks                 string                `json:"symlinks"`
+	Categories               map[string]string     `json:"categories"`
+	MimeTypes                map[string]string     `json:"mimeTypes"`
+	Webhooks                 []settings.Webhook    `json:"webhooks"`
+	HookTimeout              int                   `json:"hookTimeout"`
+	Slow                     settings.Slow         `json:"slow"`
+	Images                   settings.Images       `json:"images"`
+	Uploads                  settings.Uploads      `json:"uploads"`
+	Fetch                    settings.Fetch        `json:"fetch"`
+	Archives                 settings.Archives     `json:"archives"`
 }
 
 var settingsGetHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 	data := &settingsData{
-		Signup:        d.settings.Signup,
-		CreateUserDir: d.settings.CreateUserDir,
-		Defaults:      d.settings.Defaults,
-		Rules:         d.settings.Rules,
-		Branding:      d.settings.Branding,
-		Shell:         d.settings.Shell,
-		Commands:      d.settings.Commands,
+		Signup:                   d.settings.Signup,
+		CreateUserDir:            d.settings.CreateUserDir,
+		Defaults:                 d.settings.Defaults,
+		Rules:                    d.settings.Rules,
+		Branding:                 d.settings.Branding,
+		Tree:                     d.settings.Tree,
+		ListingCache:             d.settings.ListingCache,
+		Live:                     d.settings.Live,
+		Shell:                    d.settings.Shell,
+		Commands:                 d.settings.Commands,
+		NormalizeNames:           d.settings.NormalizeNames,
+		CaseInsensitive:          d.settings.CaseInsensitive,
+		PlainTextCLI:             d.settings.PlainTextCLI,
+		NoIndex:                  d.settings.NoIndex,
+		TrackChanges:             d.settings.TrackChanges,
+		DirTemplates:             d.settings.DirTemplates,
+		GitStatus:                d.settings.GitStatus,
+		DocMeta:                  d.settings.DocMeta,
+		DirOptions:               d.settings.DirOptions,
+		TrashDir:                 d.settings.TrashDir,
+		ShowHidden:               d.settings.ShowHidden,
+		DirsFirst:                d.settings.DirsFirst,
+		DirSizes:                 d.settings.DirSizes,
+		DirMode:                  d.settings.DirMode,
+		AllowSpecialBits:         d.settings.AllowSpecialBits,
+		MaxEditSize:              d.settings.MaxEditSize,
+		RequireConditionalWrites: d.settings.RequireConditionalWrites,
+		MaxChecksumSize:          d.settings.MaxChecksumSize,
+		SearchLimit:              d.settings.SearchLimit,
+		FeedLimit:                d.settings.FeedLimit,
+		ListingIndex:             d.settings.ListingIndex,
+		Readmes:                  d.settings.Readmes,
+		DateFormat:               d.settings.DateFormat,
+		Timezone:                 d.settings.Timezone,
+		SortLocale:               d.settings.SortLocale,
+		DefaultLimit:             d.settings.DefaultLimit,
+		MaxLimit:                 d.settings.MaxLimit,
+		Symlinks:                 d.settings.Symlinks,
+		Categories:               d.settings.Categories,
+		MimeTypes:                d.settings.MimeTypes,
+		Webhooks:                 d.settings.Webhooks,
+		HookTimeout:              d.settings.HookTimeout,
+		Slow:                     d.settings.Slow,
+		Images:                   d.settings.Images,
+		Uploads:                  d.settings.Uploads,
+		Fetch:                    d.settings.Fetch,
+		Archives:                 d.settings.Archives,
 	}
 
 	return renderJSON(w, r, data)
@@ -44,9 +124,54 @@ var settingsPutHandler = withAdmin(func(w http.ResponseWriter, r *http.Request,
 	d.settings.Defaults = req.Defaults
 	d.settings.Rules = req.Rules
 	d.settings.Branding = req.Branding
+	d.settings.Tree = req.Tree
+	d.settings.ListingCache = req.ListingCache
+	d.settings.Live = req.Live
 	d.settings.Shell = req.Shell
 	d.settings.Commands = req.Commands
+	d.settings.NormalizeNames = req.NormalizeNames
+	d.settings.CaseInsensitive = req.CaseInsensitive
+	d.settings.PlainTextCLI = req.PlainTextCLI
+	d.settings.NoIndex = req.NoIndex
+	d.settings.TrackChanges = req.TrackChanges
+	d.settings.DirTemplates = req.DirTemplates
+	d.settings.GitStatus = req.GitStatus
+	d.settings.DocMeta = req.DocMeta
+	d.settings.DirOptions = req.DirOptions
+	d.settings.TrashDir = req.TrashDir
+	d.settings.ShowHidden = req.ShowHidden
+	d.settings.DirsFirst = req.DirsFirst
+	d.settings.DirSizes = req.DirSizes
+	d.settings.DirMode = req.DirMode
+	d.settings.AllowSpecialBits = req.AllowSpecialBits
+	d.settings.MaxEditSize = req.MaxEditSize
+	d.settings.RequireConditionalWrites = req.RequireConditionalWrites
+	d.settings.MaxChecksumSize = req.MaxChecksumSize
+	d.settings.SearchLimit = req.SearchLimit
+	d.settings.FeedLimit = req.FeedLimit
+	d.settings.ListingIndex = req.ListingIndex
+	d.settings.Readmes = req.Readmes
+	d.settings.DateFormat = req.DateFormat
+	d.settings.Timezone = req.Timezone
+	d.settings.SortLocale = req.SortLocale
+	d.settings.DefaultLimit = req.DefaultLimit
+	d.settings.MaxLimit = req.MaxLimit
+	d.settings.Symlinks = req.Symlinks
+	d.settings.Categories = req.Categories
+	d.settings.MimeTypes = req.MimeTypes
+	d.settings.Webhooks = req.Webhooks
+	d.settings.HookTimeout = req.HookTimeout
+	d.settings.Slow = req.Slow
+	d.settings.Images = req.Images
+	d.settings.Uploads = req.Uploads
+	d.settings.Fetch = req.Fetch
+	d.settings.Archives = req.Archives
+
+	if err := d.settings.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
 
 	err = d.store.Settings.Save(d.settings)
+	listings.clear()
 	return errToStatus(err), err
 })
@@ -48,6 +48,10 @@ var shareDeleteHandler = withPermShare(func(w http.ResponseWriter, r *http.Reque
 })
 
 var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.capabilities(r.URL.Path).CanShare {
+		return http.StatusForbidden, nil
+	}
+
 	var s *share.Link
 	rawExpire := r.URL.Query().Get("expires")
 	unit := r.URL.Query().Get("unit")
@@ -56,7 +60,7 @@ var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request
 		var err error
 		s, err = d.store.Share.GetPermanent(r.URL.Path, d.user.ID)
 		if err == nil {
-			w.Write([]byte(d.server.BaseURL + "/share/" + s.Hash))
+			w.Write([]byte(d.baseURL(r) + "/share/" + s.Hash))
 			return 0, nil
 		}
 	}
@@ -103,5 +107,11 @@ var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request
 		return http.StatusInternalServerError, err
 	}
 
-	return renderJSON(w, r, s)
+	return renderJSON(w, r, sharedLink{s, qrURL(r, d, s.Hash)})
 })
+
+// sharedLink is a share link with the URL of its QR code image.
+type sharedLink struct {
+	*share.Link
+	QR string `json:"qr"`
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxSlowOps is the number of slow operations the status shows.
+const maxSlowOps = 32
+
+// slowOp is an operation that took longer than its threshold. Its
+// durations are in seconds.
+type slowOp struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Request  string    `json:"request,omitempty"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	User     string    `json:"user,omitempty"`
+	Scope    string    `json:"scope,omitempty"`
+	Items    int       `json:"items"`
+	Duration float64   `json:"duration"`
+	// Phases are the durations of the steps of the listings.
+	Phases map[string]float64 `json:"phases,omitempty"`
+}
+
+// slowOps keeps the last maxSlowOps slow operations.
+type slowOps struct {
+	mu   sync.Mutex
+	ops  [maxSlowOps]slowOp
+	next int
+	full bool
+}
+
+func (s *slowOps) add(op slowOp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops[s.next] = op
+	s.next = (s.next + 1) % maxSlowOps
+	s.full = s.full || s.next == 0
+}
+
+// list returns the slow operations, from the latest.
+func (s *slowOps) list() []slowOp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.next
+	if s.full {
+		n = maxSlowOps
+	}
+
+	list := make([]slowOp, 0, n)
+	for i := 1; i <= n; i++ {
+		list = append(list, s.ops[(s.next-i+maxSlowOps)%maxSlowOps])
+	}
+
+	return list
+}
+
+// phase is the duration of a step of an operation.
+type phase struct {
+	name     string
+	duration time.Duration
+}
+
+// checkSlow warns about an operation of kind on r that took longer than
+// threshold milliseconds, which disable the warnings if zero. items is
+// the number of items it handled.
+func (d *data) checkSlow(r *http.Request, kind string, threshold int, duration time.Duration, items int, phases ...phase) {
+	if threshold <= 0 || duration <= time.Duration(threshold)*time.Millisecond {
+		return
+	}
+
+	op := slowOp{
+		Time:     time.Now(),
+		Kind:     kind,
+		Request:  RequestID(r.Context()),
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Scope:    d.scope(),
+		Items:    items,
+		Duration: duration.Seconds(),
+	}
+
+	fields := []interface{}{"kind", kind, "method", r.Method, "path", r.URL.Path,
+		"items", items, "duration", duration}
+	if d.user != nil {
+		op.User = d.user.Username
+		fields = append(fields, "user", d.user.Username)
+	}
+
+	if len(phases) > 0 {
+		op.Phases = map[string]float64{}
+		for _, p := range phases {
+			op.Phases[p.name] = p.duration.Seconds()
+			fields = append(fields, p.name, p.duration)
+		}
+	}
+
+	d.logger.Warn("slow operation", fields...)
+	d.metrics.slow.Add(1, kind)
+	d.slow.add(op)
+}
@@ -0,0 +1,101 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package http_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+)
+
+func TestSpecialFiles(t *testing.T) {
+	// The pipe is on the disk, so opening it would block until something
+	// writes to it.
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(dir, "docs", "pipe"), 0644); err != nil {
+		t.Skip("named pipes aren't supported:", err)
+	}
+
+	srv, err := filebrowsertest.New(afero.NewBasePathFs(afero.NewOsFs(), dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		status int
+		want   string
+	}{
+		{"JSON listing", "/api/resources/docs/", "application/json", http.StatusOK, `"special":"pipe"`},
+		{"HTML listing", "/api/resources/docs/", "text/html", http.StatusOK, `pipe <span class="special">pipe</span>`},
+		{"info", "/api/resources/docs/pipe", "application/json", http.StatusOK, `"special":"pipe"`},
+		{"download", "/api/raw/docs/pipe", "", http.StatusConflict, ""},
+		{"checksum", "/api/resources/docs/pipe?checksum=sha256", "application/json", http.StatusConflict, ""},
+		{"archive", "/api/raw/docs/?algo=zip", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan *http.Response, 1)
+			var body string
+			go func() {
+				w := do(t, srv, "GET", tt.target, "", "Accept", tt.accept)
+				body = w.Body.String()
+				done <- w.Result()
+			}()
+
+			var res *http.Response
+			select {
+			case res = <-done:
+			case <-time.After(5 * time.Second):
+				// Writing to the pipe lets the request that opened it end.
+				if pipe, err := os.OpenFile(filepath.Join(dir, "docs", "pipe"), os.O_WRONLY, 0); err == nil {
+					pipe.Close()
+				}
+				t.Fatal("the request hangs on the pipe")
+			}
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("GET = %d, want %d: %s", res.StatusCode, tt.status, body)
+			}
+
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("the response doesn't contain %q:\n%s", tt.want, body)
+			}
+
+			if tt.name == "archive" {
+				archive, err := zip.NewReader(bytes.NewReader([]byte(body)), int64(len(body)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var names []string
+				for _, file := range archive.File {
+					names = append(names, file.Name)
+				}
+				if got := strings.Join(names, ","); got != "docs/,docs/a.txt" {
+					t.Errorf("the archive has %s, want docs/ and docs/a.txt", got)
+				}
+			}
+		})
+	}
+}
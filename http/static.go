@@ -1,25 +1,24 @@
 package http
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"text/template"
 
 	rice "github.com/GeertJohan/go.rice"
 	"github.com/filebrowser/filebrowser/v2/auth"
 	"github.com/filebrowser/filebrowser/v2/settings"
-	"github.com/filebrowser/filebrowser/v2/storage"
 	"github.com/filebrowser/filebrowser/v2/version"
 )
 
 func handleWithStaticData(w http.ResponseWriter, r *http.Request, d *data, box *rice.Box, file, contentType string) (int, error) {
 	w.Header().Set("Content-Type", contentType)
 
-	staticURL := strings.TrimPrefix(d.server.BaseURL+"/static", "/")
+	baseURL := d.baseURL(r)
+	staticURL := strings.TrimPrefix(d.staticURL(baseURL), "/")
 
 	auther, err := d.store.Auth.Get(d.settings.AuthMethod)
 	if err != nil {
@@ -29,12 +28,13 @@ func handleWithStaticData(w http.ResponseWriter, r *http.Request, d *data, box *
 	data := map[string]interface{}{
 		"Name":            d.settings.Branding.Name,
 		"DisableExternal": d.settings.Branding.DisableExternal,
-		"BaseURL":         d.server.BaseURL,
+		"BaseURL":         baseURL,
 		"Version":         version.Version,
 		"StaticURL":       staticURL,
 		"Signup":          d.settings.Signup,
 		"NoAuth":          d.settings.AuthMethod == auth.MethodNoAuth,
 		"LoginPage":       auther.LoginPage(),
+		"Locale":          detectLocale(r, d.settings.Defaults.Locale),
 		"CSS":             false,
 		"ReCaptcha":       false,
 	}
@@ -44,7 +44,7 @@ func handleWithStaticData(w http.ResponseWriter, r *http.Request, d *data, box *
 		_, err := os.Stat(path)
 
 		if err != nil && !os.IsNotExist(err) {
-			log.Printf("couldn't load custom styles: %v", err)
+			d.logger.Warn("couldn't load the custom styles", "error", err)
 		}
 
 		if err == nil {
@@ -74,35 +74,64 @@ func handleWithStaticData(w http.ResponseWriter, r *http.Request, d *data, box *
 
 	data["Json"] = string(b)
 
-	index := template.Must(template.New("index").Delims("[{[", "]}]").Parse(box.MustString(file)))
-	err = index.Execute(w, data)
+	index, err := getTemplate(d, box, file)
 	if err != nil {
+		// The scripts that aren't in the box are not found.
+		return errToStatus(err), err
+	}
+
+	// Execute into a buffer so a failing custom template doesn't
+	// leave a partial page behind the error.
+	var buf bytes.Buffer
+	if err := index.Execute(&buf, data); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if _, err := buf.WriteTo(w); err != nil {
 		return http.StatusInternalServerError, err
 	}
 
 	return 0, nil
 }
 
-func getStaticHandlers(storage *storage.Storage, server *settings.Server) (http.Handler, http.Handler) {
-	box := rice.MustFindBox("../frontend/dist")
+// staticURL returns the URL of the static files under baseURL.
+func (d *data) staticURL(baseURL string) string {
+	return baseURL + d.server.StaticPath
+}
+
+func getStaticHandlers(h *Handler, server *settings.Server) (http.Handler, http.Handler) {
+	box := h.assets
+	if box == nil {
+		box = rice.MustFindBox("../frontend/dist")
+	}
 	handler := http.FileServer(box.HTTPBox())
 
 	index := handle(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-		if r.Method != http.MethodGet {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
 			return http.StatusNotFound, nil
 		}
 
 		w.Header().Set("x-xss-protection", "1; mode=block")
+		if noIndex(d.settings.NoIndex, r.URL.Path) {
+			w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		}
+
 		return handleWithStaticData(w, r, d, box, "index.html", "text/html; charset=utf-8")
-	}, "", storage, server)
+	}, "", h, server)
 
 	static := handle(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-		if r.Method != http.MethodGet {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
 			return http.StatusNotFound, nil
 		}
 
+		if strings.HasPrefix(r.URL.Path, faviconsPrefix) {
+			return serveFavicon(w, r, d, strings.TrimPrefix(r.URL.Path, faviconsPrefix))
+		}
+
 		if d.settings.Branding.Files != "" {
-			if strings.HasPrefix(r.URL.Path, "img/") {
+			if strings.HasPrefix(r.URL.Path, brandingAssetsPrefix) {
+				return serveBrandingAsset(w, r, d, strings.TrimPrefix(r.URL.Path, brandingAssetsPrefix))
+			} else if strings.HasPrefix(r.URL.Path, "img/") {
 				path := filepath.Join(d.settings.Branding.Files, r.URL.Path)
 				if _, err := os.Stat(path); err == nil {
 					http.ServeFile(w, r, path)
@@ -114,13 +143,17 @@ func getStaticHandlers(storage *storage.Storage, server *settings.Server) (http.
 			}
 		}
 
+		if _, ok := embeddedAssets[r.URL.Path]; ok {
+			return serveEmbeddedAsset(w, r, r.URL.Path)
+		}
+
 		if !strings.HasSuffix(r.URL.Path, ".js") {
 			handler.ServeHTTP(w, r)
 			return 0, nil
 		}
 
 		return handleWithStaticData(w, r, d, box, r.URL.Path, "application/javascript; charset=utf-8")
-	}, "/static/", storage, server)
+	}, server.StaticPath+"/", h, server)
 
 	return index, static
 }
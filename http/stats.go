@@ -0,0 +1,239 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+const (
+	// defaultStatsLargest is the number of the largest files of the
+	// statistics, unless the request asks for another one.
+	defaultStatsLargest = 10
+	// maxStatsLargest bounds the number of the largest files.
+	maxStatsLargest = 100
+)
+
+const statsTemplate = `<!DOCTYPE html>
+<html lang="{{ .Locale }}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{ .Title }}</title>
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/light.css" }}">
+{{- if eq .Theme "dark" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}">
+{{- else if eq .Theme "" }}
+<link rel="stylesheet" href="{{ $.StaticURL }}/{{ asset "themes/dark.css" }}" media="(prefers-color-scheme: dark)">
+{{- end }}
+</head>
+<body class="theme-{{ or .Theme "auto" }}">
+<h1>{{ $.T "statistics" .Stats.Path }}</h1>
+<p>{{ humanSize .Stats.Size }} – {{ $.T "summary" .Stats.Dirs .Stats.Files }}{{ if .Stats.Approximate }} ({{ $.T "approximate" }}){{ end }}</p>
+<h2>{{ $.T "categories" }}</h2>
+{{ template "bars" .CategoryBars }}
+<h2>{{ $.T "sizes" }}</h2>
+{{ template "bars" .HistogramBars }}
+<h2>{{ $.T "largest" }}</h2>
+<table id="largest">
+<tr><th>{{ $.T "name" }}</th><th>{{ $.T "size" }}</th></tr>
+{{- range .Stats.Largest }}
+<tr>
+<td><a href="{{ $.BaseURL }}{{ pathJoinURL "/api/resources" $.Stats.Path .Path }}{{ $.Query }}">{{ .Path }}</a></td>
+<td>{{ humanSize .Size }}</td>
+</tr>
+{{- end }}
+</table>
+</body>
+</html>
+{{- define "bars" }}
+<table class="bars">
+{{- range . }}
+<tr>
+<td>{{ .Label }}</td>
+<td>{{ .Files }}</td>
+<td>{{ humanSize .Size }}</td>
+<td><div style="background: currentColor; opacity: 0.4; height: 0.8em; width: {{ .Percent }}%"></div></td>
+</tr>
+{{- end }}
+</table>
+{{- end }}
+`
+
+var defaultStatsPage = template.Must(template.New("stats").Funcs(listingFuncs).Parse(statsTemplate))
+
+// statsPage is the data the statistics template is executed with.
+type statsPage struct {
+	Title     string
+	BaseURL   string
+	StaticURL string
+	Query     string
+	Theme     string
+	Locale    string
+	Stats     *files.DirStats
+
+	pageMessages
+}
+
+// statsBar is a row of the bars of the statistics, with the share of
+// the total size it has.
+type statsBar struct {
+	Label   string
+	Files   int
+	Size    int64
+	Percent int64
+}
+
+// CategoryBars returns the bars of the categories, with their icons.
+func (p *statsPage) CategoryBars() []statsBar {
+	bars := make([]statsBar, 0, len(p.Stats.Categories))
+	for _, category := range p.Stats.Categories {
+		icon, ok := categoryIcons[category.Category]
+		if !ok {
+			icon = "📄"
+		}
+
+		bars = append(bars, p.bar(icon+" "+category.Category, category.Files, category.Size))
+	}
+
+	return bars
+}
+
+// HistogramBars returns the bars of the buckets of the sizes.
+func (p *statsPage) HistogramBars() []statsBar {
+	bars := make([]statsBar, 0, len(p.Stats.Histogram))
+	for _, bucket := range p.Stats.Histogram {
+		label := "< " + humanSize(bucket.Max)
+		switch {
+		case bucket.Max == 0:
+			label = "≥ " + humanSize(bucket.Min)
+		case bucket.Min > 0:
+			label = humanSize(bucket.Min) + " – " + humanSize(bucket.Max)
+		}
+
+		bars = append(bars, p.bar(label, bucket.Files, bucket.Size))
+	}
+
+	return bars
+}
+
+func (p *statsPage) bar(label string, n int, size int64) statsBar {
+	bar := statsBar{Label: label, Files: n, Size: size}
+	if p.Stats.Size > 0 {
+		bar.Percent = size * 100 / p.Stats.Size
+	}
+
+	return bar
+}
+
+// renderStats renders the statistics of the files of the requested
+// directory. With the recursive query parameter, the directories below
+// it are walked too, down to the depth of the trees.
+func renderStats(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+
+	format := negotiatedFormat(w, r, d)
+	if format == "" {
+		return http.StatusNotAcceptable, nil
+	}
+
+	info, err := d.user.Fs.Stat(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return http.StatusBadRequest, nil
+	}
+
+	largest := defaultStatsLargest
+	if raw := r.URL.Query().Get("largest"); raw != "" {
+		largest, err = strconv.Atoi(raw)
+		if err != nil || largest < 0 {
+			return http.StatusBadRequest, err
+		}
+
+		if largest > maxStatsLargest {
+			largest = maxStatsLargest
+		}
+	}
+
+	depth := 0
+	if r.URL.Query().Get("recursive") == "true" {
+		depth, _ = d.settings.Tree.Limits()
+	}
+
+	stats, err := files.Stats(files.StatsOptions{
+		Context:    r.Context(),
+		Fs:         d.user.Fs,
+		Path:       r.URL.Path,
+		Depth:      depth,
+		MaxEntries: maxDiskUsageEntries,
+		Largest:    largest,
+		Checker:    d,
+		Categories: d.settings.Categories,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if stats.Approximate {
+		w.Header().Set("X-Results-Truncated", "true")
+	}
+
+	switch format {
+	case formatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for _, category := range stats.Categories {
+			fmt.Fprintf(tw, "%d\t%d\t\t%s\n", category.Size, category.Files, category.Category)
+		}
+
+		if err := tw.Flush(); err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		return 0, nil
+	case formatHTML:
+		return renderStatsHTML(w, r, d, stats)
+	}
+
+	return renderJSON(w, r, stats)
+}
+
+func renderStatsHTML(w http.ResponseWriter, r *http.Request, d *data, stats *files.DirStats) (int, error) {
+	locale := detectLocale(r, d.user.Locale)
+	baseURL := d.baseURL(r)
+	page := &statsPage{
+		Title:        listingTitle(d) + " – " + stats.Path,
+		BaseURL:      baseURL,
+		StaticURL:    d.staticURL(baseURL),
+		Theme:        activeTheme(r, d.settings.Branding.Theme),
+		Locale:       locale,
+		Stats:        stats,
+		pageMessages: messages(locale),
+	}
+
+	if query := keptQuery(r); len(query) > 0 {
+		page.Query = "?" + query.Encode()
+	}
+
+	var buf bytes.Buffer
+	if err := defaultStatsPage.Execute(&buf, page); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/runner"
+)
+
+// statusData is the status of the handler. It is gathered from the
+// counters the caches and the queues keep, so it never reads the files:
+// it is served quickly even when the disks are slow.
+type statusData struct {
+	Started time.Time `json:"started"`
+	// Uptime is in seconds.
+	Uptime   float64                `json:"uptime"`
+	Server   statusServer           `json:"server"`
+	Caches   map[string]cacheStatus `json:"caches"`
+	Tracked  map[string]int         `json:"tracked"`
+	Queues   map[string]queueStatus `json:"queues"`
+	Features map[string]bool        `json:"features"`
+	// Slow are the last slow operations, from the latest.
+	Slow []slowOp `json:"slow"`
+}
+
+type statusServer struct {
+	Root         string `json:"root"`
+	BaseURL      string `json:"baseURL"`
+	AuthMethod   string `json:"authMethod"`
+	DefaultScope string `json:"defaultScope"`
+}
+
+type cacheStatus struct {
+	Size    int     `json:"size"`
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+func newCacheStatus(size int, hits, misses uint64) cacheStatus {
+	s := cacheStatus{Size: size, Hits: hits, Misses: misses}
+	if hits+misses > 0 {
+		s.HitRate = float64(hits) / float64(hits+misses)
+	}
+
+	return s
+}
+
+type queueStatus struct {
+	Length   int `json:"length"`
+	Capacity int `json:"capacity"`
+	// Dropped is only counted for the events, whose subscribers each
+	// have a buffer of Capacity events.
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// statusHandler serves the status of h to the same clients as the
+// metrics.
+func statusHandler(h *Handler) handleFunc {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !metricsClient(r, d) {
+			return http.StatusForbidden, nil
+		}
+
+		status := &statusData{
+			Started: h.started,
+			Uptime:  time.Since(h.started).Seconds(),
+			Server: statusServer{
+				Root:         d.server.Root,
+				BaseURL:      d.server.BaseURL,
+				AuthMethod:   string(d.settings.AuthMethod),
+				DefaultScope: d.settings.Defaults.Scope,
+			},
+			Caches: map[string]cacheStatus{
+				"templates":    customTemplates.status(),
+				"dirTemplates": dirTemplates.status(),
+				"listing":      listingTemplates.status(),
+				"docMeta":      docMetas.status(),
+				"listings":     listings.status(),
+				"locales":      localeCacheStatus(),
+			},
+			Tracked: map[string]int{
+				"changes":  snapshotsLen(),
+				"uploads":  uploadsLen(),
+				"archives": h.archives.len(),
+				"live":     liveLen(),
+			},
+			Queues: map[string]queueStatus{},
+			Features: map[string]bool{
+				"accessLog": h.accessLog != nil,
+				"tracing":   h.tracer != nil,
+				"webhooks":  len(d.settings.Webhooks) > 0,
+			},
+			Slow: h.slow.list(),
+		}
+
+		length, capacity := h.webhooks.Len()
+		status.Queues["webhooks"] = queueStatus{Length: length, Capacity: capacity}
+		length, capacity = runner.Running()
+		status.Queues["hooks"] = queueStatus{Length: length, Capacity: capacity}
+		status.Queues["events"] = queueStatus{Capacity: events.Buffer, Dropped: h.events.Dropped()}
+		if h.accessLog != nil {
+			length, capacity = h.accessLog.len()
+			status.Queues["accessLog"] = queueStatus{Length: length, Capacity: capacity}
+		}
+
+		return renderJSON(w, r, status)
+	}
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/linkfs"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// withSymlinks hides the symbolic links of fs when the settings say so,
+// so they are left out of the listings, archives and WebDAV, and the
+// requests for them fail as if they didn't exist.
+func withSymlinks(fs afero.Fs, d *data) afero.Fs {
+	if d.settings.Symlinks == settings.SymlinksHide {
+		return linkfs.New(fs)
+	}
+
+	return fs
+}
+
+// linkReader is implemented by the filesystems that can read the targets
+// of their symbolic links.
+type linkReader interface {
+	Readlink(name string) (string, error)
+}
+
+// readlink returns the function the listings read the targets of the
+// symbolic links with, or nil if they are not shown as links.
+func (d *data) readlink() func(name string) (string, error) {
+	if d.settings.Symlinks != settings.SymlinksShow {
+		return nil
+	}
+
+	return func(name string) (string, error) {
+		// The filesystems that aren't on the disk, such as the in-memory
+		// ones of the tests, read their links themselves.
+		if fs, ok := d.user.Fs.(linkReader); ok {
+			return fs.Readlink(name)
+		}
+
+		local, ok := d.user.LocalPath(name)
+		if !ok {
+			return "", os.ErrInvalid
+		}
+
+		target, err := os.Readlink(local)
+		if err != nil {
+			return "", err
+		}
+
+		if !filepath.IsAbs(target) {
+			return filepath.ToSlash(target), nil
+		}
+
+		// The absolute targets are shown as paths of the scope, and the
+		// ones outside of it are not shown, so the paths on the server
+		// are never revealed.
+		root, _ := d.user.LocalPath("/")
+		rel, err := filepath.Rel(root, target)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil
+		}
+
+		if rel == "." {
+			return "/", nil
+		}
+
+		return "/" + filepath.ToSlash(rel), nil
+	}
+}
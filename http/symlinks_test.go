@@ -0,0 +1,89 @@
+package http_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestSymlinks(t *testing.T) {
+	type want struct {
+		listed bool   // the link is in the listing
+		target string // the target shown in the listing
+		status int    // the status of a direct request for the link
+	}
+
+	tests := []struct {
+		mode   string
+		link   string
+		dir    bool
+		broken bool
+		want   want
+	}{
+		{settings.SymlinksFollow, "file", false, false, want{true, "", http.StatusOK}},
+		{settings.SymlinksFollow, "dir", true, false, want{true, "", http.StatusOK}},
+		{settings.SymlinksFollow, "broken", false, true, want{true, "", http.StatusNotFound}},
+		{settings.SymlinksShow, "file", false, false, want{true, "a.txt", http.StatusOK}},
+		{settings.SymlinksShow, "dir", true, false, want{true, "sub", http.StatusOK}},
+		{settings.SymlinksShow, "broken", false, true, want{true, "missing", http.StatusNotFound}},
+		{settings.SymlinksHide, "file", false, false, want{false, "", http.StatusNotFound}},
+		{settings.SymlinksHide, "dir", true, false, want{false, "", http.StatusNotFound}},
+		{settings.SymlinksHide, "broken", false, true, want{false, "", http.StatusNotFound}},
+	}
+
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt":     {Content: "hello"},
+		"/docs/sub/b.txt": {Content: "b"},
+		"/docs/file":      {Link: "a.txt"},
+		"/docs/dir":       {Link: "sub"},
+		"/docs/broken":    {Link: "missing"},
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.mode+" "+tt.link, func(t *testing.T) {
+			updateSettings(t, srv, func(s *settings.Settings) { s.Symlinks = tt.mode })
+
+			listing, err := srv.Listing("/docs")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listed := false
+			for _, item := range listing.Items {
+				if item.Name != tt.link {
+					continue
+				}
+
+				listed = true
+				if item.IsDir != tt.dir || item.BrokenLink != tt.broken {
+					t.Errorf("the link is a directory: %v and broken: %v, want %v and %v", item.IsDir, item.BrokenLink, tt.dir, tt.broken)
+				}
+				if item.IsSymlink != (tt.mode == settings.SymlinksShow) || item.LinkTarget != tt.want.target {
+					t.Errorf("the link is marked: %v with the target %q, want the target %q", item.IsSymlink, item.LinkTarget, tt.want.target)
+				}
+			}
+			if listed != tt.want.listed {
+				t.Errorf("the link is listed: %v, want %v", listed, tt.want.listed)
+			}
+
+			w := do(t, srv, "GET", "/api/resources/docs/"+tt.link, "")
+			if w.Code != tt.want.status {
+				t.Errorf("GET = %d, want %d: %s", w.Code, tt.want.status, w.Body)
+			}
+
+			if !tt.dir {
+				if w := do(t, srv, "GET", "/api/raw/docs/"+tt.link, ""); w.Code != tt.want.status {
+					t.Errorf("GET raw = %d, want %d: %s", w.Code, tt.want.status, w.Body)
+				}
+			}
+
+			w = do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "text/html")
+			if shown := strings.Contains(w.Body.String(), "→ "+tt.want.target+"</span>"); shown != (tt.want.target != "") {
+				t.Errorf("the HTML listing shows the target: %v", shown)
+			}
+		})
+	}
+}
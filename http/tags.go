@@ -0,0 +1,115 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/tags"
+)
+
+// tagStore returns the store of the tags of the files of the user.
+func tagStore(d *data) *tags.Store {
+	return tags.New(d.user.Fs, d.user.LocalPath)
+}
+
+var tagsGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+
+	if _, err := d.user.Fs.Stat(r.URL.Path); err != nil {
+		return errToStatus(err), err
+	}
+
+	list, err := tagStore(d).Get(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	return renderJSON(w, r, list)
+})
+
+var tagsPutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.capabilities(r.URL.Path).CanEdit {
+		return http.StatusForbidden, nil
+	}
+
+	var list []string
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	list, err := tags.Clean(list)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if err := tagStore(d).Set(r.URL.Path, list); err != nil {
+		return errToStatus(err), err
+	}
+
+	return renderJSON(w, r, list)
+})
+
+// annotateTags sets the tags of the items of the listing when the request
+// asks for them, and only keeps the items with the tag of the tag query
+// parameter if it has one.
+func annotateTags(r *http.Request, d *data, listing *files.Listing) error {
+	want := r.URL.Query().Get("tag")
+	if r.URL.Query().Get("tags") != "true" && want == "" {
+		return nil
+	}
+
+	paths := make([]string, 0, len(listing.Items))
+	for _, item := range listing.Items {
+		paths = append(paths, item.Path)
+	}
+
+	all, err := tagStore(d).GetAll(paths)
+	if err != nil {
+		return err
+	}
+
+	items := listing.Items[:0]
+	for _, item := range listing.Items {
+		item.Tags = all[item.Path]
+		if want != "" && !hasTag(item.Tags, want) {
+			switch {
+			case item.Error:
+				listing.NumUnreadable--
+			case item.IsDir:
+				listing.NumDirs--
+			default:
+				listing.NumFiles--
+			}
+			continue
+		}
+
+		items = append(items, item)
+	}
+	listing.Items = items
+
+	return nil
+}
+
+func hasTag(list []string, tag string) bool {
+	for _, t := range list {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hideTagsSidecar removes the sidecar file of the tags from the listing.
+func hideTagsSidecar(listing *files.Listing) {
+	for i, item := range listing.Items {
+		if item.Name == tags.Sidecar && !item.IsDir {
+			listing.Items = append(listing.Items[:i], listing.Items[i+1:]...)
+			listing.NumFiles--
+			return
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	rice "github.com/GeertJohan/go.rice"
+)
+
+type cachedTemplate struct {
+	modTime time.Time
+	tpl     interface{}
+}
+
+// templateCache caches parsed templates by path. They are parsed again
+// whenever their modification time changes. The templates are the ones
+// of the text/template or the html/template packages, and each cache
+// holds only one of the two.
+type templateCache struct {
+	// hits and misses come first to be aligned for the atomic operations.
+	hits, misses uint64
+
+	sync.Mutex
+	m map[string]cachedTemplate
+}
+
+func (c *templateCache) get(d *data, path string, modTime time.Time, parse func() (interface{}, error)) (interface{}, error) {
+	return c.load(d, path, modTime, parse, false)
+}
+
+// getKeep is like get, but when the template can't be parsed again it
+// keeps the last one that could for the next requests, until the file
+// changes again. The request that parsed it still gets the error.
+func (c *templateCache) getKeep(d *data, path string, modTime time.Time, parse func() (interface{}, error)) (interface{}, error) {
+	return c.load(d, path, modTime, parse, true)
+}
+
+func (c *templateCache) load(d *data, path string, modTime time.Time, parse func() (interface{}, error), keep bool) (interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if cached, ok := c.m[path]; ok && cached.modTime.Equal(modTime) {
+		d.logger.Debug("template cache hit", "template", path)
+		d.metrics.cacheHits.Add(1)
+		d.span.SetBool("template.cache_hit", true)
+		atomic.AddUint64(&c.hits, 1)
+		return cached.tpl, nil
+	}
+
+	d.logger.Debug("template cache miss", "template", path)
+	d.metrics.cacheMisses.Add(1)
+	d.span.SetBool("template.cache_hit", false)
+	atomic.AddUint64(&c.misses, 1)
+
+	tpl, err := parse()
+	if err != nil {
+		if cached, ok := c.m[path]; ok && keep {
+			c.m[path] = cachedTemplate{modTime: modTime, tpl: cached.tpl}
+		}
+		return tpl, err
+	}
+
+	if c.m == nil {
+		c.m = map[string]cachedTemplate{}
+	}
+
+	c.m[path] = cachedTemplate{modTime: modTime, tpl: tpl}
+	return tpl, nil
+}
+
+func (c *templateCache) status() cacheStatus {
+	c.Lock()
+	size := len(c.m)
+	c.Unlock()
+
+	return newCacheStatus(size, atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses))
+}
+
+// customTemplates caches the templates of the static files from the
+// branding directory, and errorTemplates the ones of the error pages,
+// which are HTML templates.
+var (
+	customTemplates = &templateCache{}
+	errorTemplates  = &templateCache{}
+)
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Delims("[{[", "]}]").Parse(text)
+}
+
+// customTemplate returns the template at path, parsed with parse and
+// kept in cache. It returns nil if the file does not exist.
+func customTemplate(d *data, cache *templateCache, path string, parse func(name, text string) (interface{}, error)) (interface{}, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return cache.get(d, path, info.ModTime(), func() (interface{}, error) {
+		text, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return parse(filepath.Base(path), string(text))
+	})
+}
+
+// getTemplate returns the template for a static file, which might be
+// overridden by a file with the same name in the branding directory.
+// If the custom template can't be parsed, the embedded one is used.
+func getTemplate(d *data, box *rice.Box, file string) (*template.Template, error) {
+	if d.settings.Branding.Files != "" {
+		path := filepath.Join(d.settings.Branding.Files, file)
+		tpl, err := customTemplate(d, customTemplates, path, func(name, text string) (interface{}, error) {
+			return parseTemplate(name, text)
+		})
+		if err != nil {
+			d.logger.Warn("couldn't load the custom template, using the default one", "template", path, "error", err)
+		} else if tpl != nil {
+			return tpl.(*template.Template), nil
+		}
+	}
+
+	text, err := box.String(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTemplate(file, text)
+}
+
+// listingTemplates caches the template of the HTML listings of the
+// branding settings.
+var listingTemplates = &templateCache{}
+
+// brandingListing returns the template of the HTML listings of the
+// branding settings. It returns nil if there's none or if its file is
+// missing, and the parse errors of the file, whose last good template is
+// kept for the next requests.
+func brandingListing(d *data) (*htmltemplate.Template, error) {
+	name := d.settings.Branding.Listing
+	if name == "" {
+		return nil, nil
+	}
+
+	info, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	tpl, err := listingTemplates.getKeep(d, name, info.ModTime(), func() (interface{}, error) {
+		text, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return htmltemplate.New(filepath.Base(name)).Funcs(listingFuncs).Parse(string(text))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tpl.(*htmltemplate.Template), nil
+}
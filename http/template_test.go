@@ -0,0 +1,194 @@
+package http_test
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestTemplateVariables(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		variables map[string]string
+		want      string
+	}{
+		{"set", `<p>{{ $.Var "team" "none" }}</p>`, map[string]string{"team": "ops"}, "<p>ops</p>"},
+		{"missing", `<p>{{ $.Var "team" "none" }}</p>`, nil, "<p>none</p>"},
+		{"escaped", `<p>{{ $.Var "team" "none" }}</p>`, map[string]string{"team": "<b>ops</b>"}, "<p>&lt;b&gt;ops&lt;/b&gt;</p>"},
+		// A template that fails is replaced by the default one.
+		{"failing", `<p>{{ $.Var "team" }}</p>`, nil, `<table`},
+	}
+
+	fs := map[string]filebrowsertest.File{}
+	for _, tt := range tests {
+		fs["/"+tt.name+"/.template.html"] = filebrowsertest.File{Content: tt.template}
+		fs["/"+tt.name+"/a.txt"] = filebrowsertest.File{Content: "a"}
+	}
+
+	srv, _ := newServer(t, fs)
+	updateSettings(t, srv, func(s *settings.Settings) { s.DirTemplates = true })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updateUser(t, srv, func(u *users.User) { u.Variables = tt.variables })
+
+			w := do(t, srv, "GET", "/api/resources/"+tt.name+"/", "", "Accept", "text/html")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			if body := w.Body.String(); !strings.Contains(body, tt.want) {
+				t.Errorf("the listing doesn't contain %q:\n%s", tt.want, body)
+			}
+		})
+	}
+}
+
+func TestTemplateEscaping(t *testing.T) {
+	const (
+		file = `<img src=x onerror=alert(1)>.txt`
+		dir  = `<svg onload=alert(2)>`
+		attr = `" onmouseover="alert(3).txt`
+	)
+
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+	}{
+		{"default", "", []string{
+			`&lt;img src=x onerror=alert(1)&gt;.txt`,
+			`&lt;svg onload=alert(2)&gt;/`,
+			`&#34; onmouseover=&#34;alert(3).txt`,
+		}},
+		{
+			"custom",
+			`<h1>{{ .Name }}</h1>{{ range .Items }}<a href="{{ .Path }}" title="{{ .Name }}">{{ .Name }}</a>{{ end }}<p>{{ $.Var "motto" "" }}</p>`,
+			[]string{
+				`<h1>&lt;svg onload=alert(2)&gt;</h1>`,
+				`title="&lt;img src=x onerror=alert(1)&gt;.txt">&lt;img src=x onerror=alert(1)&gt;.txt</a>`,
+				`href="/custom/%3csvg%20onload=alert%282%29%3e/%22%20onmouseover=%22alert%283%29.txt"`,
+				`title="&#34; onmouseover=&#34;alert(3).txt"`,
+				`<p>&lt;b onclick=alert(4)&gt;</p>`,
+			},
+		},
+		{
+			// The templates written for text/template called html, which
+			// doesn't escape twice now.
+			"custom calling html",
+			`{{ range .Items }}<span>{{ html .Name }}</span>{{ end }}`,
+			[]string{
+				`<span>&lt;img src=x onerror=alert(1)&gt;.txt</span>`,
+				`<span>&#34; onmouseover=&#34;alert(3).txt</span>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each test has its own directory, since the templates are
+			// cached by their path.
+			base := "/" + tt.name + "/" + dir + "/"
+			fs := map[string]filebrowsertest.File{
+				base + file: {Content: "a"},
+				base + attr: {Content: "b"},
+				base + dir:  {Mode: os.ModeDir},
+			}
+			if tt.template != "" {
+				fs[base+".template.html"] = filebrowsertest.File{Content: tt.template}
+			}
+
+			srv, _ := newServer(t, fs)
+			updateSettings(t, srv, func(s *settings.Settings) { s.DirTemplates = true })
+			updateUser(t, srv, func(u *users.User) {
+				u.Variables = map[string]string{"motto": "<b onclick=alert(4)>"}
+			})
+
+			w := do(t, srv, "GET", "/api/resources/"+url.PathEscape(tt.name)+"/"+url.PathEscape(dir)+"/", "", "Accept", "text/html")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			body := w.Body.String()
+			for _, hostile := range []string{"<img", "<svg", "<b onclick", `" onmouseover`} {
+				if strings.Contains(body, hostile) {
+					t.Errorf("the listing has %s unescaped:\n%s", hostile, body)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("the listing doesn't contain %s:\n%s", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestBrandingListing(t *testing.T) {
+	const minimal = `<title>{{ .Name }}</title>{{ range .Items }}<a href="{{ pathJoinURL "/api/raw" .Path }}">{{ .Name }}</a>{{ end }}`
+
+	// The steps run in order on the same file, which is changed between
+	// them. An empty template removes the file.
+	steps := []struct {
+		name     string
+		template string
+		code     int
+		want     string
+	}{
+		{"missing", "", http.StatusOK, `<table`},
+		{"minimal", minimal, http.StatusOK, `<title>docs</title><a href="/api/raw/docs/a.txt">a.txt</a><a href="/api/raw/docs/b.txt">b.txt</a>`},
+		{"changed", `<ul>{{ range .Items }}<li>{{ .Name }}{{ end }}</ul>`, http.StatusOK, `<ul><li>a.txt<li>b.txt</ul>`},
+		{"broken", `{{ range .Items }}`, http.StatusInternalServerError, `couldn't parse the listing template`},
+		{"kept", "-", http.StatusOK, `<ul><li>a.txt<li>b.txt</ul>`},
+		{"fixed", minimal, http.StatusOK, `<title>docs</title>`},
+		{"removed", "", http.StatusOK, `<table`},
+	}
+
+	srv, _ := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt": {Content: "a"},
+		"/docs/b.txt": {Content: "b"},
+	})
+	name := filepath.Join(t.TempDir(), "listing.html")
+	updateSettings(t, srv, func(s *settings.Settings) { s.Branding.Listing = name })
+
+	modTime := time.Now().Add(-time.Hour)
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			switch step.template {
+			case "-":
+				// The file doesn't change.
+			case "":
+				if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+					t.Fatal(err)
+				}
+			default:
+				// The files written in the same tick of the clock have
+				// the same time, so it's set.
+				modTime = modTime.Add(time.Second)
+				if err := os.WriteFile(name, []byte(step.template), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(name, modTime, modTime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := do(t, srv, "GET", "/api/resources/docs/", "", "Accept", "text/html")
+			if w.Code != step.code {
+				t.Fatalf("GET = %d, want %d: %s", w.Code, step.code, w.Body)
+			}
+			if body := w.Body.String(); !strings.Contains(body, step.want) {
+				t.Errorf("the listing doesn't contain %q:\n%s", step.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// themeCookie is the cookie with the theme the user picked.
+const themeCookie = "theme"
+
+// themeAuto is the value of the theme cookie when the user wants to
+// follow the preference of the browser.
+const themeAuto = "auto"
+
+func validTheme(theme string) bool {
+	return theme == settings.ThemeLight || theme == settings.ThemeDark
+}
+
+// activeTheme returns the theme of the listings for the request. An
+// empty theme means the one preferred by the browser.
+func activeTheme(r *http.Request, fallback string) string {
+	if cookie, err := r.Cookie(themeCookie); err == nil {
+		if cookie.Value == themeAuto {
+			return ""
+		}
+
+		if validTheme(cookie.Value) {
+			return cookie.Value
+		}
+	}
+
+	if validTheme(fallback) {
+		return fallback
+	}
+
+	return ""
+}
+
+// themeHandler stores the theme the user picked in a cookie and
+// redirects back to the page the user came from.
+var themeHandler = func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	theme := r.URL.Query().Get("theme")
+	if theme != themeAuto && !validTheme(theme) {
+		return http.StatusBadRequest, nil
+	}
+
+	baseURL := d.baseURL(r)
+	http.SetCookie(w, &http.Cookie{
+		Name:     themeCookie,
+		Value:    theme,
+		Path:     baseURL + "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Only the path of the referer is kept so the handler can't be used
+	// to redirect to other sites.
+	target := baseURL + "/"
+	if referer, err := url.Parse(r.Referer()); err == nil && strings.HasPrefix(referer.Path, "/") {
+		p := path.Clean(referer.Path)
+		if strings.HasSuffix(referer.Path, "/") && p != "/" {
+			p += "/"
+		}
+
+		target = (&url.URL{Path: p, RawQuery: referer.RawQuery}).String()
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+	return 0, nil
+}
@@ -0,0 +1,26 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/filebrowser/filebrowser/v2/tracing"
+)
+
+// trace starts the span of r if the handler has a tracer and r carries a
+// trace context, and returns r with it. The spans of the steps of the
+// request are children of it. Otherwise, it returns r and tracing.Nop.
+func (h *Handler) trace(r *http.Request) (*http.Request, tracing.Span) {
+	if h.tracer == nil {
+		return r, tracing.Nop
+	}
+
+	ctx, ok := h.tracer.Extract(r.Context(), r.Header)
+	if !ok {
+		return r, tracing.Nop
+	}
+
+	ctx, span := h.tracer.Start(tracing.WithTracer(ctx, h.tracer), "filebrowser.request")
+	span.SetString("http.method", r.Method)
+	span.SetString("http.target", r.URL.Path)
+	return r.WithContext(ctx), span
+}
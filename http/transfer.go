@@ -0,0 +1,96 @@
+package http
+
+import (
+	"os"
+	"path"
+
+	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// area returns the area of the scope of the user p is in, and whether
+// it's read-only, finding the aliases regardless of case when the
+// settings say so.
+func (d *data) area(p string) (string, bool) {
+	if d.settings.CaseInsensitive {
+		return d.user.AreaFold(p)
+	}
+
+	return d.user.Area(p)
+}
+
+// crossesAreas checks if src and dst are in different areas of the scope
+// of the user, such as two aliases, between which files can't be renamed.
+func crossesAreas(d *data, src, dst string) bool {
+	from, _ := d.area(src)
+	to, _ := d.area(dst)
+	return from != to
+}
+
+// crossMove moves src to dst, in another area of the scope of the user.
+// The areas must be writable, the user must be allowed to delete and to
+// move files between them, and the file is copied, checked against the
+// original and only then removed, since the areas have different roots.
+func crossMove(d *data, src, dst string) error {
+	src, dst = path.Clean("/"+src), path.Clean("/"+dst)
+	if err := checkCrossMove(d, src, dst); err != nil {
+		return err
+	}
+
+	release, err := lockPath(d, src)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	releaseDst, err := lockPath(d, dst)
+	if err != nil {
+		return err
+	}
+	defer releaseDst()
+
+	err = d.RunHook(func() error {
+		err := fileutils.Copy(d.user.Fs, src, dst)
+		if err == nil {
+			err = fileutils.Verify(d.user.Fs, src, dst)
+		}
+		if err != nil {
+			// The original is kept, so the partial copy is removed.
+			if removeErr := d.user.Fs.RemoveAll(dst); removeErr != nil {
+				d.logger.Warn("couldn't remove the partial copy", "path", dst, "error", removeErr)
+			}
+			return err
+		}
+
+		return d.user.Fs.RemoveAll(src)
+	}, "rename", src, dst, -1, d.user)
+	if err != nil {
+		return err
+	}
+
+	d.notifyMove(settings.EventRename, src, dst)
+	return nil
+}
+
+// checkCrossMove checks if src can be moved to dst, in another area of
+// the scope of the user, without moving it.
+func checkCrossMove(d *data, src, dst string) error {
+	from, fromReadOnly := d.area(src)
+	to, toReadOnly := d.area(dst)
+
+	switch {
+	case fromReadOnly, toReadOnly, src == from:
+		return os.ErrPermission
+	case !d.capabilities(src).CanRename, !d.capabilities(src).CanDelete, !d.capabilities(dst).CanRename, !d.capabilities(dst).CanUpload:
+		return os.ErrPermission
+	case !d.user.AllowsTransfer(from, to):
+		return os.ErrPermission
+	}
+
+	if _, err := d.user.Fs.Stat(dst); err == nil {
+		return errors.ErrExist
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	rice "github.com/GeertJohan/go.rice"
+)
+
+// fallbackLocale is the locale of the strings used when a locale has no
+// translation for them.
+const fallbackLocale = "en"
+
+// translations are the strings of the HTML listings by locale. They are
+// loaded from the JSON files of the locales box, which are named after
+// their locale, so adding a locale only takes a new file.
+var translations struct {
+	once    sync.Once
+	locales map[string]map[string]string
+	err     error
+}
+
+// loadTranslations returns the translations, loading them the first
+// time. The error tells why some of them couldn't be loaded.
+func loadTranslations() (map[string]map[string]string, error) {
+	translations.once.Do(func() {
+		translations.locales = map[string]map[string]string{}
+
+		box, err := rice.FindBox("locales")
+		if err != nil {
+			translations.err = err
+			return
+		}
+
+		translations.err = box.Walk("", func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+				return err
+			}
+
+			b, err := box.Bytes(path)
+			if err != nil {
+				return err
+			}
+
+			var messages map[string]string
+			if err := json.Unmarshal(b, &messages); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+
+			locale := strings.TrimSuffix(filepath.Base(path), ".json")
+			translations.locales[strings.ToLower(locale)] = messages
+			return nil
+		})
+	})
+
+	return translations.locales, translations.err
+}
+
+// messages returns the strings of a locale. The strings it has no
+// translation for are taken from its base language, such as pt for
+// pt-br, and then from the fallback locale.
+func messages(locale string) map[string]string {
+	all, _ := loadTranslations()
+	result := map[string]string{}
+
+	chain := []string{fallbackLocale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		chain = append(chain, locale[:i])
+	}
+	chain = append(chain, locale)
+
+	for _, l := range chain {
+		for key, message := range all[strings.ToLower(l)] {
+			if message != "" {
+				result[key] = message
+			}
+		}
+	}
+
+	return result
+}
+
+// pageMessages are the strings of the locale of an HTML page, which the
+// pages embed so their templates can translate with T.
+type pageMessages map[string]string
+
+// T returns the string with key, replacing {0}, {1} and so on with args.
+func (m pageMessages) T(key string, args ...interface{}) string {
+	return translate(m, key, args...)
+}
+
+// translate returns the string with key, replacing {0}, {1} and so on
+// with args. It returns the key if there is no such string.
+func translate(messages map[string]string, key string, args ...interface{}) string {
+	message, ok := messages[key]
+	if !ok {
+		return key
+	}
+
+	for i, arg := range args {
+		message = strings.Replace(message, "{"+strconv.Itoa(i)+"}", fmt.Sprint(arg), -1)
+	}
+
+	return message
+}
+
+// translationsHandler serves the strings of the locale of the request to
+// the scripts of the HTML listings.
+var translationsHandler = func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	locale := detectLocale(r, d.settings.Defaults.Locale)
+	w.Header().Set("Content-Language", locale)
+	w.Header().Set("Vary", "Accept-Language")
+	return renderJSON(w, r, messages(locale))
+}
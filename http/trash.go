@@ -0,0 +1,125 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/fileutils"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// trashTimeFormat is the layout of the times the names of the trashed
+// files start with.
+const trashTimeFormat = "20060102T150405"
+
+// trashedName matches the names of the trashed files: the time they were
+// trashed, a number when several files of the same name were trashed in
+// the same second, and their original name.
+var trashedName = regexp.MustCompile(`^\d{8}T\d{6}(\.\d+)?-(.+)$`)
+
+// inTrash checks if p is the trash directory of the settings or is in it.
+func inTrash(d *data, p string) bool {
+	trash := d.settings.TrashPath()
+	return trash != "" && (p == trash || strings.HasPrefix(p, trash+"/"))
+}
+
+// trashDestination returns where src is moved to in the trash: under the
+// path of its directory, so the trashed files keep their trees, with the
+// time it's trashed at before its name. The names taken get a number
+// after the time.
+func trashDestination(d *data, src string, now time.Time) string {
+	dir := path.Join(d.settings.TrashPath(), path.Dir(src))
+	stamp := now.Format(trashTimeFormat)
+	dst := path.Join(dir, stamp+"-"+path.Base(src))
+	for n := 2; ; n++ {
+		if _, err := d.user.Fs.Stat(dst); err != nil {
+			return dst
+		}
+
+		dst = path.Join(dir, fmt.Sprintf("%s.%d-%s", stamp, n, path.Base(src)))
+	}
+}
+
+// moveFile moves src to dst, making the directories above dst. The files
+// are renamed and, between the areas of the scope or the devices, copied,
+// checked and only then removed.
+func moveFile(d *data, src, dst string) error {
+	if err := d.user.Fs.MkdirAll(path.Dir(dst), d.settings.NewDirMode()); err != nil {
+		return err
+	}
+
+	if crossesAreas(d, src, dst) {
+		return fileutils.MoveByCopy(d.user.Fs, src, dst)
+	}
+
+	return fileutils.Move(d.user.Fs, src, dst)
+}
+
+// hideTrash removes the trash directory from the listing of its parent.
+// It's still listed when it's browsed.
+func hideTrash(d *data, listing *files.Listing) {
+	trash := d.settings.TrashPath()
+	if trash == "" {
+		return
+	}
+
+	for i, item := range listing.Items {
+		if path.Clean("/"+item.Path) == trash && item.IsDir {
+			listing.Items = append(listing.Items[:i], listing.Items[i+1:]...)
+			listing.NumDirs--
+			return
+		}
+	}
+}
+
+// restoreHandler moves the trashed file of the request back to where it
+// was deleted from, making the directories above it again, and answers
+// with the paths of the move. The paths taken since are a conflict.
+func restoreHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	src := path.Clean("/" + r.URL.Path)
+	trash := d.settings.TrashPath()
+	match := trashedName.FindStringSubmatch(path.Base(src))
+	if trash == "" || !strings.HasPrefix(src, trash+"/") || match == nil {
+		return renderFailure(w, r, http.StatusBadRequest, "only the files trashed at the top of their trees can be restored")
+	}
+
+	dst := path.Join(path.Dir(strings.TrimPrefix(src, trash)), match[2])
+	if !d.capabilities(src).CanRename || !d.capabilities(dst).CanRename {
+		return http.StatusForbidden, nil
+	}
+
+	if _, err := d.user.Fs.Stat(src); err != nil {
+		return errToStatus(err), err
+	}
+
+	if _, err := d.user.Fs.Stat(dst); err == nil {
+		return renderFailure(w, r, http.StatusConflict, "a file is already at "+dst)
+	}
+
+	release, err := lockPath(d, src)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer release()
+
+	releaseDst, err := lockPath(d, dst)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	defer releaseDst()
+
+	err = d.RunHook(func() error {
+		return moveFile(d, src, dst)
+	}, "rename", src, dst, -1, d.user)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	d.notifyMove(settings.EventRename, src, dst)
+	return renderJSON(w, r, &bulkResult{Path: src, Destination: dst})
+}
@@ -0,0 +1,164 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// trashed lists the names in the directory dir of the trash.
+func trashed(t *testing.T, fs afero.Fs, dir string) []string {
+	t.Helper()
+
+	infos, err := afero.ReadDir(fs, "/.trash"+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func TestTrash(t *testing.T) {
+	srv, fs := newServer(t, map[string]filebrowsertest.File{
+		"/docs/a.txt":         {Content: "first"},
+		"/docs/c.txt":         {Content: "c"},
+		"/docs/d.txt":         {Content: "d"},
+		"/docs/dir/sub/b.txt": {Content: "b"},
+	})
+	updateSettings(t, srv, func(s *settings.Settings) { s.TrashDir = ".trash/" })
+	token := csrfToken(t, srv)
+
+	request := func(method, target, body string, want int) {
+		t.Helper()
+		w := do(t, srv, method, target, body, "X-CSRF-Token", token)
+		if w.Code != want {
+			t.Fatalf("%s %s = %d, want %d: %s", method, target, w.Code, want, w.Body)
+		}
+	}
+
+	// The deleted files get the time before their names, and a number
+	// after it when the name is taken.
+	request("DELETE", "/api/resources/docs/a.txt", "", http.StatusOK)
+	request("POST", "/api/resources/docs/a.txt", "second", http.StatusOK)
+	request("DELETE", "/api/resources/docs/a.txt", "", http.StatusOK)
+	request("DELETE", "/api/resources/docs/dir", "", http.StatusOK)
+
+	// The names sort by time, and the numbered ones after the others of
+	// the same second.
+	stamp := regexp.MustCompile(`^(\d{8}T\d{6})(\.\d+)?-(.+)$`)
+	var trashedA []string
+	var dir string
+	for _, name := range trashed(t, fs, "/docs") {
+		m := stamp.FindStringSubmatch(name)
+		switch {
+		case m == nil:
+			t.Errorf("the trash has %q, without the time", name)
+		case m[3] == "a.txt":
+			trashedA = append(trashedA, name)
+		case m[3] == "dir":
+			dir = name
+		default:
+			t.Errorf("the trash has %q", name)
+		}
+	}
+	if len(trashedA) != 2 || dir == "" {
+		t.Fatalf("the trash has %q and %q, want two a.txt and dir", trashedA, dir)
+	}
+	first, second := trashedA[0], trashedA[1]
+	if a, b := stamp.FindStringSubmatch(first), stamp.FindStringSubmatch(second); a[1] == b[1] && (a[2] != "" || b[2] != ".2") {
+		t.Errorf("the trash has %q and %q, want the second numbered", first, second)
+	}
+	if content, err := afero.ReadFile(fs, "/.trash/docs/"+first); err != nil || string(content) != "first" {
+		t.Errorf("the first trashed file is %q %v, want first", content, err)
+	}
+	if content, err := afero.ReadFile(fs, "/.trash/docs/"+dir+"/sub/b.txt"); err != nil || string(content) != "b" {
+		t.Errorf("the trashed directory lost its tree: %q %v", content, err)
+	}
+	if ok, _ := afero.Exists(fs, "/docs/a.txt"); ok {
+		t.Error("the deleted file is still there")
+	}
+
+	// Purging and deleting in the trash remove the files for good.
+	request("DELETE", "/api/resources/docs/c.txt?purge=true", "", http.StatusOK)
+	request("DELETE", "/api/resources/.trash/docs/"+dir, "", http.StatusOK)
+	if ok, _ := afero.Exists(fs, "/docs/c.txt"); ok {
+		t.Error("the purged file is still there")
+	}
+	if names := trashed(t, fs, "/docs"); len(names) != 2 {
+		t.Errorf("the trash has %q, want the two a.txt", names)
+	}
+	if ok, _ := afero.Exists(fs, "/.trash/.trash"); ok {
+		t.Error("the deletions in the trash were trashed")
+	}
+
+	// The trash is only listed when it's browsed.
+	listings := []struct {
+		target string
+		want   []string
+	}{
+		{"/api/resources/?showhidden=true", []string{"docs"}},
+		{"/api/resources/.trash/?showhidden=true", []string{"docs"}},
+		{"/api/resources/?showhidden=true&search=a.txt", nil},
+		{"/api/resources/.trash/?search=a.txt", []string{"docs/" + first, "docs/" + second}},
+	}
+	for _, tt := range listings {
+		t.Run(tt.target, func(t *testing.T) {
+			w := do(t, srv, "GET", tt.target, "", "Accept", "application/json")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d: %s", tt.target, w.Code, w.Body)
+			}
+
+			file := &files.FileInfo{}
+			if err := json.Unmarshal(w.Body.Bytes(), file); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range file.Items {
+				got = append(got, item.Name)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("the listing has %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("the listing has %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+
+	restores := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"restore", "/.trash/docs/" + second, http.StatusOK},
+		{"taken since", "/.trash/docs/" + first, http.StatusConflict},
+		{"not trashed", "/docs/d.txt", http.StatusBadRequest},
+		{"the trash", "/.trash/docs", http.StatusBadRequest},
+		{"missing", "/.trash/docs/20200101T000000-missing.txt", http.StatusNotFound},
+	}
+	for _, tt := range restores {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, srv, "POST", "/api/resources"+tt.target+"?action=restore", "", "X-CSRF-Token", token)
+			if w.Code != tt.want {
+				t.Errorf("restoring %s = %d, want %d: %s", tt.target, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	if content, err := afero.ReadFile(fs, "/docs/a.txt"); err != nil || string(content) != "second" {
+		t.Errorf("the restored file is %q %v, want second", content, err)
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/tags"
+)
+
+// treeChecker leaves out of the tree what the listings leave out: the
+// files the rules hide, the dotfiles unless they're shown, the trash and
+// the files the directories are set up with.
+type treeChecker struct {
+	d          *data
+	showHidden bool
+}
+
+func (c treeChecker) Check(p string) bool {
+	name := path.Base(p)
+	switch {
+	case !c.d.Check(p):
+		return false
+	case !c.showHidden && strings.HasPrefix(name, "."):
+		return false
+	case path.Clean("/"+p) == c.d.settings.TrashPath(), name == tags.Sidecar:
+		return false
+	case c.d.settings.DirTemplates && name == dirTemplateName:
+		return false
+	case c.d.settings.DirOptions != "" && name == c.d.settings.DirOptions:
+		return false
+	}
+
+	return true
+}
+
+// renderTree renders the tree of the directories under the requested
+// path. The depth query parameter is capped by the settings and the
+// files one includes the files on the tree.
+func renderTree(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+
+	info, err := d.user.Fs.Stat(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return http.StatusBadRequest, nil
+	}
+
+	maxDepth, maxNodes := d.settings.Tree.Limits()
+	depth := maxDepth
+
+	if raw := r.URL.Query().Get("depth"); raw != "" {
+		depth, err = strconv.Atoi(raw)
+		if err != nil || depth < 0 {
+			return http.StatusBadRequest, err
+		}
+
+		if depth > maxDepth {
+			depth = maxDepth
+		}
+	}
+
+	tree, err := files.NewTree(files.TreeOptions{
+		Fs:       d.user.Fs,
+		Path:     r.URL.Path,
+		Depth:    depth,
+		MaxNodes: maxNodes,
+		Files:    r.URL.Query().Get("files") == "true",
+		Checker:  treeChecker{d: d, showHidden: showHidden(r, d)},
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	body, err := json.Marshal(tree)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write(body)
+	etag := fmt.Sprintf(`"%x"`, hash.Sum64())
+
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return 0, nil
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if _, err := w.Write(body); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,84 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/tags"
+)
+
+// treePaths returns the paths on the tree, sorted.
+func treePaths(node *files.TreeNode) []string {
+	var paths []string
+	for _, child := range node.Children {
+		paths = append(paths, child.Path)
+		paths = append(paths, treePaths(child)...)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+func TestTreeHidden(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		change func(*settings.Settings)
+		want   string
+	}{
+		{"nothing hidden", "", func(*settings.Settings) {}, "/.dot /.dot/c.txt /docs /docs/.template.html /docs/a.txt /docs/secret /docs/secret/b.txt /trash /trash/x.txt"},
+		{"rules", "", func(s *settings.Settings) {
+			s.Rules = []rules.Rule{{Path: "/docs/secret"}}
+		}, "/.dot /.dot/c.txt /docs /docs/.template.html /docs/a.txt /trash /trash/x.txt"},
+		{"dotfiles", "", func(s *settings.Settings) {
+			s.ShowHidden = false
+		}, "/docs /docs/a.txt /docs/secret /docs/secret/b.txt /trash /trash/x.txt"},
+		{"dotfiles shown", "&showhidden=true", func(s *settings.Settings) {
+			s.ShowHidden = false
+		}, "/.dot /.dot/c.txt /docs /docs/.template.html /docs/a.txt /docs/secret /docs/secret/b.txt /trash /trash/x.txt"},
+		{"trash", "", func(s *settings.Settings) {
+			s.TrashDir = "/trash"
+		}, "/.dot /.dot/c.txt /docs /docs/.template.html /docs/a.txt /docs/secret /docs/secret/b.txt"},
+		{"directory templates", "", func(s *settings.Settings) {
+			s.DirTemplates = true
+		}, "/.dot /.dot/c.txt /docs /docs/a.txt /docs/secret /docs/secret/b.txt /trash /trash/x.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, map[string]filebrowsertest.File{
+				"/.dot/c.txt":           {Content: "c"},
+				"/docs/.template.html":  {Content: "{{.Name}}"},
+				"/docs/a.txt":           {Content: "a"},
+				"/docs/" + tags.Sidecar: {Content: "{}"},
+				"/docs/secret/b.txt":    {Content: "b"},
+				"/trash/x.txt":          {Content: "x"},
+			})
+			updateSettings(t, srv, func(s *settings.Settings) {
+				s.ShowHidden = true
+				tt.change(s)
+			})
+
+			w := do(t, srv, "GET", "/api/resources/?tree=true&files=true"+tt.query, "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET = %d: %s", w.Code, w.Body)
+			}
+
+			var tree files.TreeNode
+			if err := json.Unmarshal(w.Body.Bytes(), &tree); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Join(treePaths(&tree), " "); got != tt.want {
+				t.Errorf("the tree has %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
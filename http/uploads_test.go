@@ -0,0 +1,212 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// unsizedReader hides the length of a body, so the request is sent
+// chunked.
+type unsizedReader struct {
+	io.Reader
+}
+
+// brokenReader is a body that breaks after its first bytes, as the
+// uploads aborted by the clients.
+type brokenReader struct {
+	io.Reader
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, err
+}
+
+// uploadServer returns a server whose uploads are limited to 10 bytes
+// and refuse .exe and .tar.gz.
+func uploadServer(t *testing.T) (*filebrowsertest.Server, *filebrowsertest.FS) {
+	t.Helper()
+
+	srv, fs := newServer(t, map[string]filebrowsertest.File{
+		"/docs/old.txt": {Content: "old"},
+	})
+	updateSettings(t, srv, func(s *settings.Settings) {
+		s.Uploads = settings.Uploads{
+			MaxSize:           10,
+			BlockedExtensions: []string{".exe", ".tar.gz"},
+		}
+	})
+
+	return srv, fs
+}
+
+func TestUploadLimits(t *testing.T) {
+	small := "0123456789"
+	large := small + "!"
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   io.Reader
+		status int
+		file   string // named in the failure, if any
+		want   string // the content of the target after the request, or "" if missing
+	}{
+		{"at the limit", "POST", "/docs/a.txt", strings.NewReader(small), http.StatusOK, "", small},
+		{"over the limit", "POST", "/docs/a.txt", strings.NewReader(large), http.StatusRequestEntityTooLarge, "/docs/a.txt", ""},
+		{"chunked at the limit", "POST", "/docs/a.txt", unsizedReader{strings.NewReader(small)}, http.StatusOK, "", small},
+		{"chunked over the limit", "POST", "/docs/a.txt", unsizedReader{strings.NewReader(large)}, http.StatusRequestEntityTooLarge, "/docs/a.txt", ""},
+		{"chunked much over the limit", "POST", "/docs/a.txt", unsizedReader{strings.NewReader(strings.Repeat(large, 1000))}, http.StatusRequestEntityTooLarge, "/docs/a.txt", ""},
+		{"aborted", "POST", "/docs/a.txt", &brokenReader{strings.NewReader("01234")}, http.StatusInternalServerError, "", ""},
+		{"edit over the limit", "PUT", "/docs/old.txt", strings.NewReader(large), http.StatusRequestEntityTooLarge, "/docs/old.txt", "old"},
+		{"chunked edit over the limit", "PUT", "/docs/old.txt", unsizedReader{strings.NewReader(large)}, http.StatusRequestEntityTooLarge, "/docs/old.txt", "old"},
+		{"aborted edit", "PUT", "/docs/old.txt", &brokenReader{strings.NewReader("01234")}, http.StatusInternalServerError, "", "old"},
+		{"blocked", "POST", "/docs/a.exe", strings.NewReader(small), http.StatusUnsupportedMediaType, "/docs/a.exe", ""},
+		{"blocked upper case", "POST", "/docs/a.EXE", strings.NewReader(small), http.StatusUnsupportedMediaType, "/docs/a.EXE", ""},
+		{"blocked trailing dot", "POST", "/docs/a.exe.", strings.NewReader(small), http.StatusUnsupportedMediaType, "/docs/a.exe.", ""},
+		{"blocked double extension", "POST", "/docs/b.TAR.gz", strings.NewReader(small), http.StatusUnsupportedMediaType, "/docs/b.TAR.gz", ""},
+		{"blocked edit", "PUT", "/docs/a.exe", strings.NewReader(small), http.StatusUnsupportedMediaType, "/docs/a.exe", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := uploadServer(t)
+			before := snapshot(t, fs)
+
+			r := httptest.NewRequest(tt.method, "/api/resources"+tt.target, tt.body)
+			r.Header.Set("Accept", "application/json")
+			if _, ok := tt.body.(*strings.Reader); !ok && r.ContentLength > 0 {
+				t.Fatalf("the body has a length: %d", r.ContentLength)
+			}
+
+			w, err := srv.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("%s = %d, want %d: %s", tt.method, w.Code, tt.status, w.Body)
+			}
+
+			if tt.file != "" {
+				var failure struct {
+					File string `json:"file"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &failure); err != nil {
+					t.Fatalf("the failure isn't JSON: %v: %s", err, w.Body)
+				}
+				if failure.File != tt.file {
+					t.Errorf("the failure names %q, want %q", failure.File, tt.file)
+				}
+			}
+
+			content, err := afero.ReadFile(fs, tt.target)
+			switch {
+			case tt.want == "" && err == nil:
+				t.Errorf("%s was written: %q", tt.target, content)
+			case tt.want != "" && err != nil:
+				t.Errorf("%s can't be read: %v", tt.target, err)
+			case string(content) != tt.want:
+				t.Errorf("%s has %q, want %q", tt.target, content, tt.want)
+			}
+
+			// The failures leave nothing behind, not even the temporary
+			// files.
+			if w.Code >= 400 {
+				if after := snapshot(t, fs); after != before {
+					t.Errorf("the files changed:\n%s\nwant:\n%s", after, before)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadLimitsMultipart(t *testing.T) {
+	tests := []struct {
+		name    string
+		parts   []string // the names of the files, of 5 bytes each but "big"
+		status  int
+		file    string
+		written []string
+	}{
+		{"allowed", []string{"a.txt", "b.txt"}, http.StatusCreated, "", []string{"a.txt", "b.txt"}},
+		{"blocked part", []string{"a.txt", "b.exe", "c.txt"}, http.StatusUnsupportedMediaType, "b.exe", []string{"a.txt"}},
+		{"part over the limit", []string{"a.txt", "big", "c.txt"}, http.StatusRequestEntityTooLarge, "big", []string{"a.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fs := uploadServer(t)
+
+			body := &bytes.Buffer{}
+			form := multipart.NewWriter(body)
+			for _, name := range tt.parts {
+				part, err := form.CreateFormFile("file", name)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				content := "01234"
+				if name == "big" {
+					content = strings.Repeat(content, 3)
+				}
+				part.Write([]byte(content))
+			}
+			form.Close()
+
+			// The form is sent chunked, so only its parts are limited.
+			r := httptest.NewRequest("POST", "/api/resources/docs/", unsizedReader{body})
+			r.Header.Set("Content-Type", form.FormDataContentType())
+			r.Header.Set("Accept", "application/json")
+			w, err := srv.Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("POST = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			if tt.file != "" {
+				var failure struct {
+					File string `json:"file"`
+				}
+				if err := json.Unmarshal(w.Body.Bytes(), &failure); err != nil {
+					t.Fatalf("the failure isn't JSON: %v: %s", err, w.Body)
+				}
+				if failure.File != tt.file {
+					t.Errorf("the failure names %q, want %q", failure.File, tt.file)
+				}
+			}
+
+			names, err := afero.ReadDir(fs, "/docs")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, info := range names {
+				if info.Name() != "old.txt" {
+					got = append(got, info.Name())
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.written, " ") {
+				t.Errorf("/docs has %q, want %q", got, tt.written)
+			}
+		})
+	}
+}
@@ -2,13 +2,15 @@ package http
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/excludefs"
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/sftpfs"
 	"github.com/filebrowser/filebrowser/v2/users"
 	"github.com/gorilla/mux"
 )
@@ -45,6 +47,54 @@ func getUser(w http.ResponseWriter, r *http.Request) (*modifyUserRequest, error)
 	return req, nil
 }
 
+// redactUser leaves the credentials of the SFTP scopes of u out, so the
+// responses never have them. The targets of the aliases are only sent to
+// the admins, since the users only see their paths.
+func redactUser(u *users.User, admin bool) {
+	u.Scope = sftpfs.Redact(u.Scope)
+	for i, scope := range u.UnionScopes {
+		u.UnionScopes[i] = sftpfs.Redact(scope)
+	}
+
+	for i := range u.Aliases {
+		if admin {
+			u.Aliases[i].Target = sftpfs.Redact(u.Aliases[i].Target)
+		} else {
+			u.Aliases[i].Target = ""
+		}
+	}
+}
+
+// unredactUser puts back the credentials of the SFTP scopes of stored
+// in the ones of u that were sent back as redactUser left them, so
+// saving a user that was read doesn't lose them.
+func unredactUser(u, stored *users.User) {
+	u.Scope = unredact(u.Scope, []string{stored.Scope})
+	for i, scope := range u.UnionScopes {
+		u.UnionScopes[i] = unredact(scope, stored.UnionScopes)
+	}
+
+	targets := make([]string, len(stored.Aliases))
+	for i, alias := range stored.Aliases {
+		targets[i] = alias.Target
+	}
+
+	for i := range u.Aliases {
+		u.Aliases[i].Target = unredact(u.Aliases[i].Target, targets)
+	}
+}
+
+// unredact returns the one of stored that redacts to scope, or scope.
+func unredact(scope string, stored []string) string {
+	for _, s := range stored {
+		if s != scope && sftpfs.Redact(s) == scope {
+			return s
+		}
+	}
+
+	return scope
+}
+
 func withSelfOrAdmin(fn handleFunc) handleFunc {
 	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 		id, err := getUserID(r)
@@ -69,6 +119,7 @@ var usersGetHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *
 
 	for _, u := range users {
 		u.Password = ""
+		redactUser(u, true)
 	}
 
 	sort.Slice(users, func(i, j int) bool {
@@ -89,6 +140,7 @@ var userGetHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 	}
 
 	u.Password = ""
+	redactUser(u, d.user.Perm.Admin)
 	return renderJSON(w, r, u)
 })
 
@@ -122,14 +174,24 @@ var userPostHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *
 
 	userHome, err := d.settings.MakeUserDir(req.Data.Username, req.Data.Scope, d.server.Root)
 	if err != nil {
-		log.Printf("create user: failed to mkdir user home dir: [%s]", userHome)
+		d.logger.Error("couldn't create the home directory", "user", req.Data.Username, "dir", userHome, "error", err)
 		return http.StatusInternalServerError, err
 	}
 	req.Data.Scope = userHome
-	log.Printf("user: %s, home dir: [%s].", req.Data.Username, userHome)
+	d.logger.Info("user created", "user", req.Data.Username, "dir", userHome)
+
+	if err := users.CheckAliasRoots(req.Data.Aliases, d.server.AliasTargets()); err != nil {
+		return http.StatusBadRequest, err
+	}
 
 	err = d.store.Users.Save(req.Data)
-	if err != nil {
+	if _, ok := err.(*users.AliasError); ok {
+		return http.StatusBadRequest, err
+	} else if _, ok := err.(*excludefs.PatternError); ok {
+		return http.StatusBadRequest, err
+	} else if _, ok := err.(*rules.RuleError); ok {
+		return http.StatusBadRequest, err
+	} else if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
@@ -137,6 +199,15 @@ var userPostHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *
 	return http.StatusCreated, nil
 })
 
+// selfFields are the fields, in lower case, of their own accounts the
+// users who aren't admins can change.
+var selfFields = map[string]bool{
+	"password": true,
+	"locale":   true,
+	"viewmode": true,
+	"sorting":  true,
+}
+
 var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 	req, err := getUser(w, r)
 	if err != nil {
@@ -147,16 +218,28 @@ var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 		return http.StatusBadRequest, nil
 	}
 
+	// Without fields, the whole user would be saved as sent.
+	if len(req.Which) == 0 {
+		return http.StatusBadRequest, nil
+	}
+
 	if len(req.Which) == 1 && req.Which[0] == "all" {
 		if !d.user.Perm.Admin {
 			return http.StatusForbidden, err
 		}
 
+		var suser *users.User
+		suser, err = d.store.Users.Get(d.server.Root, d.raw.(uint))
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		// The favorites are only changed through their own routes.
+		req.Data.Favorites = suser.Favorites
+		unredactUser(req.Data, suser)
 		if req.Data.Password != "" {
 			req.Data.Password, err = users.HashPwd(req.Data.Password)
 		} else {
-			var suser *users.User
-			suser, err = d.store.Users.Get(d.server.Root, d.raw.(uint))
 			req.Data.Password = suser.Password
 		}
 
@@ -167,8 +250,18 @@ var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 		req.Which = []string{}
 	}
 
+	// The fields are found by their names whatever their case, so they're
+	// checked the same way.
 	for k, v := range req.Which {
-		if v == "password" {
+		field := strings.ToLower(v)
+
+		// The favorites are only changed through their own routes, which
+		// cap them and check them against the rules, even for the admins.
+		if field == "favorites" {
+			return http.StatusForbidden, nil
+		}
+
+		if field == "password" {
 			if !d.user.Perm.Admin && d.user.LockPassword {
 				return http.StatusForbidden, nil
 			}
@@ -179,15 +272,37 @@ var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 			}
 		}
 
-		if !d.user.Perm.Admin && (v == "scope" || v == "perm" || v == "username") {
+		if !d.user.Perm.Admin && !selfFields[field] {
 			return http.StatusForbidden, nil
 		}
 
 		req.Which[k] = strings.Title(v)
 	}
 
+	if len(req.Which) != 0 {
+		suser, err := d.store.Users.Get(d.server.Root, d.raw.(uint))
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		unredactUser(req.Data, suser)
+	}
+
+	if len(req.Which) == 0 || contains(req.Which, "Aliases") {
+		if err := users.CheckAliasRoots(req.Data.Aliases, d.server.AliasTargets()); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
 	err = d.store.Users.Update(req.Data, req.Which...)
-	if err != nil {
+	listings.clear()
+	if _, ok := err.(*users.AliasError); ok {
+		return http.StatusBadRequest, err
+	} else if _, ok := err.(*excludefs.PatternError); ok {
+		return http.StatusBadRequest, err
+	} else if _, ok := err.(*rules.RuleError); ok {
+		return http.StatusBadRequest, err
+	} else if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
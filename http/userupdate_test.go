@@ -0,0 +1,150 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	fbhttp "github.com/filebrowser/filebrowser/v2/http"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// putUser changes the fields in which of the user of srv to the ones of
+// data, and returns the status.
+func putUser(t *testing.T, srv *filebrowsertest.Server, which []string, data map[string]interface{}) int {
+	t.Helper()
+
+	user, err := srv.Storage.Users.Get("/", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data["id"] = user.ID
+	body, err := json.Marshal(map[string]interface{}{"what": "user", "which": which, "data": data})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return do(t, srv, "PUT", "/api/users/"+strconv.FormatUint(uint64(user.ID), 10), string(body), "Content-Type", "application/json").Code
+}
+
+func TestUserUpdateFields(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		admin  bool
+		which  []string
+		data   map[string]interface{}
+		status int
+	}{
+		{"locale", false, []string{"locale"}, map[string]interface{}{"locale": "fr"}, http.StatusOK},
+		{"view mode", false, []string{"viewMode"}, map[string]interface{}{"viewMode": "list"}, http.StatusOK},
+		{"sorting", false, []string{"sorting"}, map[string]interface{}{"sorting": map[string]interface{}{"by": "size"}}, http.StatusOK},
+		{"password", false, []string{"password"}, map[string]interface{}{"password": "secret"}, http.StatusOK},
+		{"password in upper case", false, []string{"Password"}, map[string]interface{}{"password": "secret"}, http.StatusOK},
+		{"aliases", false, []string{"aliases"}, map[string]interface{}{"aliases": []users.Alias{{Path: "/host", Target: dir}}}, http.StatusForbidden},
+		{"aliases in upper case", false, []string{"Aliases"}, map[string]interface{}{"aliases": []users.Alias{{Path: "/host", Target: dir}}}, http.StatusForbidden},
+		{"permissions", false, []string{"perm"}, map[string]interface{}{"perm": map[string]bool{"admin": true}}, http.StatusForbidden},
+		{"permissions in upper case", false, []string{"PERM"}, map[string]interface{}{"perm": map[string]bool{"admin": true}}, http.StatusForbidden},
+		{"rules", false, []string{"rules"}, map[string]interface{}{"rules": []interface{}{}}, http.StatusForbidden},
+		{"commands", false, []string{"commands"}, map[string]interface{}{"commands": []string{"rm"}}, http.StatusForbidden},
+		{"lock of the password", false, []string{"lockPassword"}, map[string]interface{}{"lockPassword": false}, http.StatusForbidden},
+		{"no fields", false, []string{}, map[string]interface{}{"perm": map[string]bool{"admin": true}}, http.StatusBadRequest},
+		{"all", false, []string{"all"}, map[string]interface{}{"perm": map[string]bool{"admin": true}}, http.StatusForbidden},
+		{"favorites", false, []string{"favorites"}, map[string]interface{}{"favorites": []string{"/secret"}}, http.StatusForbidden},
+		{"favorites in upper case", false, []string{"Favorites"}, map[string]interface{}{"favorites": []string{"/secret"}}, http.StatusForbidden},
+		{"favorites of an admin", true, []string{"favorites"}, map[string]interface{}{"favorites": []string{"/secret"}}, http.StatusForbidden},
+		{"favorites with other fields", true, []string{"locale", "favorites"}, map[string]interface{}{"locale": "fr", "favorites": []string{"/secret"}}, http.StatusForbidden},
+		{"all the fields with favorites", true, []string{"all"}, map[string]interface{}{"username": "admin", "favorites": []string{"/secret"}}, http.StatusOK},
+		{"admin permissions", true, []string{"perm"}, map[string]interface{}{"perm": map[string]bool{"admin": true}}, http.StatusOK},
+		{"admin without fields", true, []string{}, map[string]interface{}{}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, nil)
+			updateUser(t, srv, func(u *users.User) { u.Perm.Admin = tt.admin })
+
+			if status := putUser(t, srv, tt.which, tt.data); status != tt.status {
+				t.Fatalf("PUT = %d, want %d", status, tt.status)
+			}
+
+			user, err := srv.Storage.Users.Get("/", "admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Perm.Admin != tt.admin && tt.status != http.StatusOK {
+				t.Error("the refused request made the user an admin")
+			}
+			if len(user.Aliases) != 0 {
+				t.Errorf("the user has the aliases %v", user.Aliases)
+			}
+			if len(user.Favorites) != 0 {
+				t.Errorf("the user has the favorites %v", user.Favorites)
+			}
+			if tt.status == http.StatusOK && strings.EqualFold(tt.which[0], "password") && !users.CheckPwd("secret", user.Password) {
+				t.Errorf("the password was saved as %q", user.Password)
+			}
+		})
+	}
+}
+
+func TestUserUpdateAliasRoots(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	nas := filepath.Join(dir, "nas")
+	outside := filepath.Join(dir, "outside")
+	for _, d := range []string{filepath.Join(root, "shared"), filepath.Join(nas, "shared"), outside, root + "-other"} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"under the root", filepath.Join(root, "shared"), http.StatusOK},
+		{"the root", root, http.StatusOK},
+		{"under an alias root", filepath.Join(nas, "shared"), http.StatusOK},
+		{"outside", outside, http.StatusBadRequest},
+		{"above the root", dir, http.StatusBadRequest},
+		{"system", "/", http.StatusBadRequest},
+		{"through a link", filepath.Join(root, "escape"), http.StatusBadRequest},
+		{"through dots", filepath.Join(root, "..", "outside"), http.StatusBadRequest},
+		{"prefix of a root", root + "-other", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, nil)
+			if err := srv.Handler.(*fbhttp.Handler).Reload(&settings.Server{Root: root, AliasRoots: []string{nas}}); err != nil {
+				t.Fatal(err)
+			}
+
+			aliases := []users.Alias{{Path: "/host", Target: tt.target}}
+			if status := putUser(t, srv, []string{"aliases"}, map[string]interface{}{"aliases": aliases}); status != tt.status {
+				t.Fatalf("PUT = %d, want %d", status, tt.status)
+			}
+
+			user, err := srv.Storage.Users.Get("/", "admin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved := len(user.Aliases) == 1; saved != (tt.status == http.StatusOK) {
+				t.Errorf("the aliases are %v", user.Aliases)
+			}
+		})
+	}
+}
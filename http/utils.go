@@ -1,13 +1,22 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 
+	"github.com/filebrowser/filebrowser/v2/disk"
 	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/runner"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
 )
 
 func renderJSON(w http.ResponseWriter, r *http.Request, data interface{}) (int, error) {
@@ -18,6 +27,7 @@ func renderJSON(w http.ResponseWriter, r *http.Request, data interface{}) (int,
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(marsh)))
 	if _, err := w.Write(marsh); err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -25,6 +35,87 @@ func renderJSON(w http.ResponseWriter, r *http.Request, data interface{}) (int,
 	return 0, nil
 }
 
+// renderFailure writes the reason of a failed request as JSON if the
+// client accepts it, so it can be shown next to the field that caused
+// it. Otherwise, only the status is written.
+func renderFailure(w http.ResponseWriter, r *http.Request, status int, reason string) (int, error) {
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		return status, nil
+	}
+
+	return 0, writeFailure(w, status, reason)
+}
+
+// renderFileFailure is renderFailure for the requests with several
+// files, such as the uploads of the forms, naming the file that failed.
+func renderFileFailure(w http.ResponseWriter, r *http.Request, status int, file, reason string) (int, error) {
+	if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+		return status, nil
+	}
+
+	return 0, writeFailureBody(w, failure{Status: status, Error: reason, File: file})
+}
+
+// failure is the JSON object of the failed requests.
+type failure struct {
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+	File      string `json:"file,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// writeFailure writes a failure as a JSON object with its status, reason
+// and the ID of the request, if it has one.
+func writeFailure(w http.ResponseWriter, status int, reason string) error {
+	return writeFailureBody(w, failure{Status: status, Error: reason})
+}
+
+// writeFailureBody writes body with the ID of the request, if it has one.
+func writeFailureBody(w http.ResponseWriter, body failure) error {
+	body.RequestID = w.Header().Get(RequestIDHeader)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(body.Status)
+
+	return json.NewEncoder(w).Encode(body)
+}
+
+// windowsReserved are the characters the names of the files can't have
+// on Windows, where the backslash is also a separator.
+const windowsReserved = `\:*?"<>|`
+
+// validName checks if a new file can be named name.
+func validName(name string) bool {
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return false
+	}
+
+	if runtime.GOOS == "windows" && strings.ContainsAny(name, windowsReserved) {
+		return false
+	}
+
+	for _, c := range name {
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// slashJoin joins the elements of a path of the scope, such as the names
+// read from a directory, with slashes. The paths of the scopes use them
+// on every system, so they never have backslashes, which would end up
+// in the rules, the archives and the URLs.
+func slashJoin(elem ...string) string {
+	for i := range elem {
+		elem[i] = filepath.ToSlash(elem[i])
+	}
+
+	return path.Join(elem...)
+}
+
 func errToStatus(err error) int {
 	switch {
 	case err == nil:
@@ -35,6 +126,18 @@ func errToStatus(err error) int {
 		return http.StatusNotFound
 	case os.IsExist(err), err == errors.ErrExist:
 		return http.StatusConflict
+	case err == webdav.ErrLocked:
+		return http.StatusLocked
+	case err == errors.ErrSpecialFile:
+		return http.StatusConflict
+	case err == errors.ErrStale:
+		return http.StatusPreconditionFailed
+	case err == errors.ErrUnconditional:
+		return http.StatusPreconditionRequired
+	case isHookError(err):
+		return http.StatusUnprocessableEntity
+	case isVetoError(err):
+		return http.StatusForbidden
 	default:
 		return http.StatusInternalServerError
 	}
@@ -57,3 +160,70 @@ func stripPrefix(prefix string, h http.Handler) http.Handler {
 		h.ServeHTTP(w, r2)
 	})
 }
+
+// redirectDir redirects a request for a directory without a trailing
+// slash to the same path with one, keeping the query. The location is
+// relative to the request since the prefixes of the path were already
+// stripped.
+func redirectDir(w http.ResponseWriter, r *http.Request, status int) {
+	target := &url.URL{
+		Path:     path.Base(r.URL.Path) + "/",
+		RawQuery: r.URL.RawQuery,
+	}
+	w.Header().Set("Location", target.String())
+	w.WriteHeader(status)
+}
+
+// scopeUsage returns the usage of the disk that hosts the scope of
+// the user, if it is on the local disk.
+func scopeUsage(d *data) (disk.Usage, bool) {
+	root, ok := d.user.LocalPath("/")
+	if !ok {
+		return disk.Usage{}, false
+	}
+
+	usage, err := disk.UsageOf(root)
+	if err != nil {
+		d.logger.Warn("couldn't get the disk usage", "path", root, "error", err)
+		return disk.Usage{}, false
+	}
+
+	return usage, true
+}
+
+type contextFs interface {
+	WithContext(ctx context.Context) afero.Fs
+}
+
+// withContext binds the filesystem to the request context when it
+// supports it so remote operations stop once the client is gone.
+func withContext(fs afero.Fs, r *http.Request) afero.Fs {
+	if cfs, ok := fs.(contextFs); ok {
+		return cfs.WithContext(r.Context())
+	}
+
+	return fs
+}
+
+func isHookError(err error) bool {
+	_, ok := err.(*runner.HookError)
+	return ok
+}
+
+// renderHookError writes the reason a before hook gave to veto the
+// operation with status, as JSON if the client accepts it or as text
+// otherwise.
+func renderHookError(w http.ResponseWriter, r *http.Request, status int, reason string) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		_ = writeFailure(w, status, reason)
+		return
+	}
+
+	http.Error(w, reason, status)
+}
+
+// isBodyTooLarge tells if err is the one of the bodies cut by
+// http.MaxBytesReader, which has no type of its own before Go 1.19.
+func isBodyTooLarge(err error) bool {
+	return err != nil && err.Error() == "http: request body too large"
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRedirectDir(t *testing.T) {
+	tests := []struct {
+		target   string
+		location string
+	}{
+		{"/api/resources/docs", "docs/"},
+		{"/api/resources/docs?sort=size&order=desc", "docs/?sort=size&order=desc"},
+		{"/api/resources/docs?q=a%26b&auth=x%2By", "docs/?q=a%26b&auth=x%2By"},
+		{"/api/resources/my%20dir", "my%20dir/"},
+		{"/api/resources/what%3F?sort=name", "what%3F/?sort=name"},
+		{"/api/resources/a%23b", "a%23b/"},
+		{"/api/resources/100%25", "100%25/"},
+		{"/api/resources/a:b", "./a:b/"},
+		{"/api/resources/%C3%BCber", "%C3%BCber/"},
+		{"/api/resources/sub/dir?x", "dir/?x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			w := httptest.NewRecorder()
+			redirectDir(w, r, http.StatusMovedPermanently)
+
+			if w.Code != http.StatusMovedPermanently {
+				t.Errorf("the status is %d", w.Code)
+			}
+
+			location := w.Header().Get("Location")
+			if location != tt.location {
+				t.Errorf("the location is %q, want %q", location, tt.location)
+			}
+
+			// The location must lead to the same directory, with the
+			// same query.
+			target, err := url.Parse(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resolved := r.URL.ResolveReference(target)
+			if resolved.Path != r.URL.Path+"/" || resolved.RawQuery != r.URL.RawQuery {
+				t.Errorf("the location leads to %q, want %q", resolved, r.URL.Path+"/?"+r.URL.RawQuery)
+			}
+		})
+	}
+}
+
+func TestSlashJoin(t *testing.T) {
+	tests := []struct {
+		elem []string
+		want string
+	}{
+		{[]string{"/docs", "a.txt"}, "/docs/a.txt"},
+		{[]string{"/", "a.txt"}, "/a.txt"},
+		{[]string{"/docs/", "sub/", "a.txt"}, "/docs/sub/a.txt"},
+		{[]string{"/docs", "../a.txt"}, "/a.txt"},
+		{[]string{"/docs", ""}, "/docs"},
+	}
+
+	if filepath.Separator == '\\' {
+		tests = append(tests, []struct {
+			elem []string
+			want string
+		}{
+			{[]string{`\docs`, `sub\a.txt`}, "/docs/sub/a.txt"},
+			{[]string{"/docs", `..\a.txt`}, "/a.txt"},
+		}...)
+	}
+
+	for _, tt := range tests {
+		elem := append([]string{}, tt.elem...)
+		if got := slashJoin(elem...); got != tt.want {
+			t.Errorf("slashJoin(%q) = %q, want %q", tt.elem, got, tt.want)
+		}
+	}
+}
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"a.txt", true},
+		{".hidden", true},
+		{"my file", true},
+		{"über", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"/", false},
+		{"a\nb", false},
+		{"a\x00b", false},
+		{"a\x7fb", false},
+		// Windows reserves them, and the backslash is a separator there.
+		{`a\b`, runtime.GOOS != "windows"},
+		{"a:b", runtime.GOOS != "windows"},
+		{"a?b", runtime.GOOS != "windows"},
+		{`a"b`, runtime.GOOS != "windows"},
+	}
+
+	for _, tt := range tests {
+		if got := validName(tt.name); got != tt.want {
+			t.Errorf("validName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
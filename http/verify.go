@@ -0,0 +1,75 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// maxSumsSize is the size of the biggest sums file verified.
+const maxSumsSize = 16 << 20
+
+// verifyHandler verifies the files of a directory against the sums file
+// of the body, such as a SHA256SUMS, and reports the state of each one,
+// and the files of the directory that aren't in it. The progress, in
+// files, is tracked like the one of the uploads.
+func verifyHandler(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+
+	info, err := d.user.Fs.Stat(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !info.IsDir() {
+		return renderFailure(w, r, http.StatusBadRequest, "only the directories are verified")
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r.Body, maxSumsSize+1))
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if len(content) > maxSumsSize {
+		return renderFailure(w, r, http.StatusRequestEntityTooLarge, "the sums file is too big")
+	}
+
+	sums, err := files.ParseSums(bytes.NewReader(content))
+	if err != nil {
+		return renderFailure(w, r, http.StatusBadRequest, "invalid sums file: "+err.Error())
+	}
+
+	if len(sums) == 0 {
+		return renderFailure(w, r, http.StatusBadRequest, "the sums file has no sums")
+	}
+
+	progress, stop := startProgress(r, d, int64(len(sums)))
+	defer stop()
+
+	var done func()
+	if progress != nil {
+		done = func() { atomic.AddInt64(&progress.Received, 1) }
+	}
+
+	maxDepth, maxFiles := d.settings.Tree.Limits()
+	res, err := files.Verify(files.VerifyOptions{
+		Context:  r.Context(),
+		Fs:       d.user.Fs,
+		Path:     r.URL.Path,
+		Sums:     sums,
+		Depth:    maxDepth,
+		MaxFiles: maxFiles,
+		Checker:  d,
+		Done:     done,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	return renderJSON(w, r, res)
+}
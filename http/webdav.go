@@ -0,0 +1,361 @@
+package http
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/disk"
+	"github.com/filebrowser/filebrowser/v2/settings"
+	"github.com/filebrowser/filebrowser/v2/users"
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+)
+
+// locks is the lock table shared by the WebDAV clients and the API.
+// The locks live in memory and are lost when the server restarts.
+var locks webdav.LockSystem = webdav.NewMemLS()
+
+// scopedLocks is a webdav.LockSystem that keys the locks by the full
+// path of the resources so users with different scopes lock the same
+// files.
+type scopedLocks struct {
+	webdav.LockSystem
+	user *users.User
+}
+
+func (l scopedLocks) path(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	return l.user.FullPath(name)
+}
+
+func (l scopedLocks) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	return l.LockSystem.Confirm(now, l.path(name0), l.path(name1), conditions...)
+}
+
+func (l scopedLocks) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	details.Root = l.path(details.Root)
+	return l.LockSystem.Create(now, details)
+}
+
+// lockPath takes a short lived lock on name for the duration of an API
+// request so it fails with 423 Locked when a WebDAV client holds a lock
+// on it or on a file under it, since deleting or moving a directory
+// changes all of its files.
+func lockPath(d *data, name string) (func(), error) {
+	ls := scopedLocks{locks, d.user}
+	now := time.Now()
+
+	token, err := ls.Create(now, webdav.LockDetails{
+		Root:     name,
+		Duration: -1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = ls.Unlock(now, token)
+	}, nil
+}
+
+// davFs adapts an afero.Fs to a webdav.FileSystem. The webhooks are
+// notified of the changes the clients make through it.
+type davFs struct {
+	afero.Fs
+	d *data
+}
+
+func (fs davFs) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	err := fs.d.runDavHook(func() error {
+		return fs.Fs.Mkdir(name, perm)
+	}, settings.EventMkdir, name, "")
+	if err != nil {
+		return err
+	}
+
+	fs.d.notify(settings.EventMkdir, name, "", 0)
+	return nil
+}
+
+func (fs davFs) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	writing := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	if writing {
+		if err := fs.d.davBefore(settings.EventUpload, name, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := fs.Fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	if writing {
+		return davUpload{file, fs.d, name}, nil
+	}
+
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		dir := davDir{File: file, d: fs.d, name: name}
+		if usage, ok := scopeUsage(fs.d); ok {
+			dir.usage = &usage
+		}
+		return dir, nil
+	}
+
+	return file, nil
+}
+
+func (fs davFs) RemoveAll(ctx context.Context, name string) error {
+	err := fs.d.runDavHook(func() error {
+		return fs.Fs.RemoveAll(name)
+	}, settings.EventDelete, name, "")
+	if err != nil {
+		return err
+	}
+
+	fs.d.notify(settings.EventDelete, name, "", 0)
+	return nil
+}
+
+func (fs davFs) Rename(ctx context.Context, oldName, newName string) error {
+	err := fs.d.runDavHook(func() error {
+		return fs.Fs.Rename(oldName, newName)
+	}, settings.EventRename, oldName, newName)
+	if err != nil {
+		return err
+	}
+
+	fs.d.notifyMove(settings.EventRename, oldName, newName)
+	return nil
+}
+
+// davUpload is a file opened for writing, whose upload is notified to
+// the webhooks and to the After hook once it is closed.
+type davUpload struct {
+	afero.File
+	d    *data
+	name string
+}
+
+func (f davUpload) Close() error {
+	info, statErr := f.File.Stat()
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+
+	var size int64
+	if statErr == nil {
+		size = info.Size()
+	}
+
+	f.d.notify(settings.EventUpload, f.name, "", size)
+	f.d.hooks.after(f.d.logger, f.d.eventContext(settings.EventUpload, f.name, "", f.d.user))
+	return nil
+}
+
+func (fs davFs) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return fs.Fs.Stat(name)
+}
+
+var (
+	quotaAvailable = xml.Name{Space: "DAV:", Local: "quota-available-bytes"}
+	quotaUsed      = xml.Name{Space: "DAV:", Local: "quota-used-bytes"}
+)
+
+// davDir is a directory whose entries the rules of the user deny are
+// left out, as in the listings, with the RFC 4331 quota properties when
+// the usage of the scope is known.
+type davDir struct {
+	webdav.File
+	d     *data
+	name  string
+	usage *disk.Usage
+}
+
+func (d davDir) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := d.File.Readdir(count)
+	kept := infos[:0]
+	for _, info := range infos {
+		if d.d.Check(path.Join(d.name, info.Name())) {
+			kept = append(kept, info)
+		}
+	}
+
+	return kept, err
+}
+
+func (d davDir) DeadProps() (map[xml.Name]webdav.Property, error) {
+	if d.usage == nil {
+		return nil, nil
+	}
+
+	return map[xml.Name]webdav.Property{
+		quotaAvailable: {
+			XMLName:  quotaAvailable,
+			InnerXML: []byte(strconv.FormatUint(d.usage.Free, 10)),
+		},
+		quotaUsed: {
+			XMLName:  quotaUsed,
+			InnerXML: []byte(strconv.FormatUint(d.usage.Used, 10)),
+		},
+	}, nil
+}
+
+func (d davDir) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	stat := webdav.Propstat{Status: http.StatusForbidden}
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			stat.Props = append(stat.Props, webdav.Property{XMLName: prop.XMLName})
+		}
+	}
+
+	return []webdav.Propstat{stat}, nil
+}
+
+// davAllowed checks if the user has the permissions a WebDAV method
+// needs.
+func davAllowed(method string, perm users.Permissions) bool {
+	switch method {
+	case http.MethodGet:
+		return perm.Download
+	case http.MethodPut, "PROPPATCH", "LOCK", "UNLOCK":
+		return perm.Modify || perm.Create
+	case "MKCOL", "COPY":
+		return perm.Create
+	case "MOVE":
+		return perm.Rename
+	case http.MethodDelete:
+		return perm.Delete
+	default:
+		return true
+	}
+}
+
+// withBasicAuth authenticates the WebDAV clients with the auth method of
+// the settings. With the JSON one, which the clients can't use, they
+// send the credentials of the users through HTTP basic auth; the others
+// authenticate them as they do the web interface, from the headers of a
+// proxy or as the first user.
+func withBasicAuth(fn handleFunc) handleFunc {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		user, err := davUser(r, d)
+		if err == os.ErrPermission {
+			if d.settings.AuthMethod == auth.MethodJSONAuth {
+				w.Header().Set("WWW-Authenticate", `Basic realm="File Browser"`)
+			}
+			return http.StatusUnauthorized, nil
+		} else if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		d.user = user
+		d.user.Fs = withSymlinks(withContext(d.user.Fs, r), d)
+		return fn(w, r, d)
+	}
+}
+
+// davUser returns the user of a WebDAV request, or os.ErrPermission if
+// it isn't authenticated.
+func davUser(r *http.Request, d *data) (*users.User, error) {
+	if d.settings.AuthMethod != auth.MethodJSONAuth {
+		auther, err := d.store.Auth.Get(d.settings.AuthMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return auther.Auth(r, d.store.Users, d.server.Root)
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, os.ErrPermission
+	}
+
+	user, err := d.store.Users.Get(d.server.Root, username)
+	if err != nil || !users.CheckPwd(password, user.Password) {
+		return nil, os.ErrPermission
+	}
+
+	return user, nil
+}
+
+var webdavHandler = withBasicAuth(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if apiOnly(d) {
+		return http.StatusNotFound, nil
+	}
+
+	if !davAllowed(r.Method, d.user.Perm) {
+		return http.StatusForbidden, nil
+	}
+
+	// The paths that can't be seen aren't found, and the ones that can
+	// be seen but not changed are forbidden.
+	p := strings.TrimPrefix(r.URL.Path, "/dav")
+	if !d.Check(p) {
+		return http.StatusNotFound, nil
+	}
+
+	if !d.checkMethod(p, r.Method) {
+		return http.StatusForbidden, nil
+	}
+
+	// A PROPFIND of a whole tree would walk all of it, so it's refused
+	// as RFC 4918 allows. A missing depth is an infinite one.
+	if r.Method == "PROPFIND" {
+		if depth := r.Header.Get("Depth"); depth != "0" && depth != "1" {
+			w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+			w.WriteHeader(http.StatusForbidden)
+			_, err := io.WriteString(w, xml.Header+`<D:error xmlns:D="DAV:"><D:propfind-finite-depth/></D:error>`)
+			return 0, err
+		}
+	}
+
+	// The destination of COPY and MOVE is a full URL, which includes
+	// the base URL that was stripped from the request path.
+	if dst := r.Header.Get("Destination"); dst != "" {
+		u, err := url.Parse(dst)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		u.Path = strings.TrimPrefix(u.Path, d.server.BaseURL)
+		if p := strings.TrimPrefix(u.Path, "/dav"); !d.Check(p) || !d.checkMethod(p, r.Method) {
+			return http.StatusForbidden, nil
+		}
+
+		r.Header.Set("Destination", u.String())
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: davFs{d.user.Fs, d},
+		LockSystem: scopedLocks{locks, d.user},
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				d.logger.Warn("webdav", "method", r.Method, "path", r.URL.Path, "error", err)
+			}
+		},
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w = d.countDownload(w)
+	case http.MethodPut:
+		r.Body = d.countUpload(r.Body)
+	}
+
+	handler.ServeHTTP(w, r)
+	return 0, nil
+})
@@ -0,0 +1,56 @@
+package http_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/auth"
+	"github.com/filebrowser/filebrowser/v2/filebrowsertest"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+func TestWebDAVAuth(t *testing.T) {
+	basic := "Basic YWRtaW46YWRtaW4="   // admin:admin
+	wrong := "Basic YWRtaW46d3Jvbmc="   // admin:wrong
+	unknown := "Basic bm9ib2R5OmFkbWlu" // nobody:admin
+
+	tests := []struct {
+		name    string
+		method  settings.AuthMethod
+		headers []string
+		want    int
+	}{
+		{"json with credentials", auth.MethodJSONAuth, []string{"Authorization", basic}, http.StatusMultiStatus},
+		{"json with a wrong password", auth.MethodJSONAuth, []string{"Authorization", wrong}, http.StatusUnauthorized},
+		{"json with an unknown user", auth.MethodJSONAuth, []string{"Authorization", unknown}, http.StatusUnauthorized},
+		{"json without credentials", auth.MethodJSONAuth, nil, http.StatusUnauthorized},
+		{"proxy with the header", auth.MethodProxyAuth, []string{"X-Remote-User", "admin"}, http.StatusMultiStatus},
+		{"proxy with an unknown user", auth.MethodProxyAuth, []string{"X-Remote-User", "nobody"}, http.StatusUnauthorized},
+		{"proxy ignores credentials", auth.MethodProxyAuth, []string{"Authorization", basic}, http.StatusUnauthorized},
+		{"none", auth.MethodNoAuth, nil, http.StatusMultiStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newServer(t, map[string]filebrowsertest.File{"/a.txt": {Content: "a"}})
+			for _, auther := range []auth.Auther{&auth.JSONAuth{}, &auth.ProxyAuth{Header: "X-Remote-User"}} {
+				if err := srv.Storage.Auth.Save(auther); err != nil {
+					t.Fatal(err)
+				}
+			}
+			updateSettings(t, srv, func(s *settings.Settings) { s.AuthMethod = tt.method })
+
+			// The tokens of the API aren't used by WebDAV.
+			headers := append([]string{"X-Auth", "none", "Depth", "1"}, tt.headers...)
+			w := do(t, srv, "PROPFIND", "/dav/", "", headers...)
+			if w.Code != tt.want {
+				t.Fatalf("PROPFIND = %d, want %d", w.Code, tt.want)
+			}
+
+			challenged := w.Header().Get("WWW-Authenticate") != ""
+			if want := w.Code == http.StatusUnauthorized && tt.method == auth.MethodJSONAuth; challenged != want {
+				t.Errorf("WWW-Authenticate = %q, want a challenge: %v", w.Header().Get("WWW-Authenticate"), want)
+			}
+		})
+	}
+}
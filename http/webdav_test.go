@@ -0,0 +1,50 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+func TestLockPath(t *testing.T) {
+	d := &data{user: &users.User{Fs: afero.NewMemMapFs()}}
+	ls := scopedLocks{locks, d.user}
+
+	// A WebDAV client locks a file of a directory.
+	now := time.Now()
+	token, err := ls.Create(now, webdav.LockDetails{Root: "/locked/dir/a.txt", Duration: time.Minute, ZeroDepth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Unlock(now, token)
+
+	tests := []struct {
+		path   string
+		locked bool
+	}{
+		{"/locked/dir/a.txt", true},
+		{"/locked/dir", true},
+		{"/locked", true},
+		{"/locked/dir/b.txt", false},
+		{"/locked/other", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			release, err := lockPath(d, tt.path)
+			if err == nil {
+				release()
+			}
+
+			if tt.locked && err != webdav.ErrLocked {
+				t.Errorf("lockPath() = %v, want ErrLocked", err)
+			} else if !tt.locked && err != nil {
+				t.Errorf("lockPath() = %v, want no error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// statusWriter remembers the status and the size of the response, so the
+// responses written by the handlers are counted and logged with them. It
+// keeps the interfaces of the http.ResponseWriter it wraps that the
+// handlers use.
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Unwrap returns the http.ResponseWriter that statusWriter wraps.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher for the responses that are streamed.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for the WebSockets of the commands.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response can't be hijacked")
+	}
+
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Push implements http.Pusher for the servers with HTTP/2.
+func (w *statusWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+
+	return p.Push(target, opts)
+}
+
+// headWriter answers a HEAD request with the headers of the response to
+// a GET, without its body. The body is counted rather than written, so
+// the Content-Length is the one of the GET even when the body is too long
+// for the server to buffer, and the headers are only sent by finish, once
+// the handler is done. The handlers that don't render the body at all
+// call skipBody, so the length is left out rather than zero.
+type headWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+	skipped bool
+}
+
+func (w *headWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.written += int64(len(p))
+	return len(p), nil
+}
+
+// finish sends the headers, with the length of the body unless the
+// handler set it, such as http.ServeContent does.
+func (w *headWriter) finish() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	header := w.Header()
+	if header.Get("Content-Length") == "" && bodyAllowed(w.status) && !w.skipped {
+		header.Set("Content-Length", strconv.FormatInt(w.written, 10))
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// skipBody marks the response to a HEAD request, written to w or to a
+// writer it wraps, as one whose body isn't rendered.
+func skipBody(w http.ResponseWriter) {
+	for {
+		switch v := w.(type) {
+		case *headWriter:
+			v.skipped = true
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+// bodyAllowed checks if the responses with status can have a body.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+)
+
+// xmlItem is an item of the XML listings.
+type xmlItem struct {
+	XMLName  xml.Name `xml:"item"`
+	Name     string   `xml:"name"`
+	Size     int64    `xml:"size"`
+	IsDir    bool     `xml:"is_dir"`
+	URL      string   `xml:"url"`
+	Mode     string   `xml:"mode"`
+	Modified string   `xml:"modified"`
+}
+
+// renderXML writes a listing as XML: a listing element with the path,
+// the counts and the sorting of the listing as attributes, and an item
+// element for each of its items. The items are encoded one by one, so
+// the document is never held in memory. The characters that XML can't
+// hold are replaced by the encoder.
+func renderXML(w http.ResponseWriter, file *files.FileInfo) (int, error) {
+	setFormatHeaders(w, formatXML, file)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return 0, err
+	}
+
+	start := xml.StartElement{
+		Name: xml.Name{Local: "listing"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "path"}, Value: file.Path},
+			{Name: xml.Name{Local: "num_dirs"}, Value: strconv.Itoa(file.NumDirs)},
+			{Name: xml.Name{Local: "num_files"}, Value: strconv.Itoa(file.NumFiles)},
+			{Name: xml.Name{Local: "sort"}, Value: file.Sorting.By},
+			{Name: xml.Name{Local: "asc"}, Value: strconv.FormatBool(file.Sorting.Asc)},
+		},
+	}
+	if file.Truncated {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "truncated"}, Value: "true"})
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.EncodeToken(start); err != nil {
+		return 0, err
+	}
+
+	for _, item := range file.Items {
+		err := enc.Encode(&xmlItem{
+			Name:     item.Name,
+			Size:     item.Size,
+			IsDir:    item.IsDir,
+			URL:      itemURL(item),
+			Mode:     item.Mode.String(),
+			Modified: item.ModTime.Format(time.RFC3339),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := enc.EncodeToken(start.End()); err != nil {
+		return 0, err
+	}
+
+	if err := enc.Flush(); err != nil {
+		return 0, err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return 0, err
+}
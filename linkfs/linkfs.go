@@ -0,0 +1,237 @@
+// Package linkfs hides the symbolic links of an afero.Fs, as if they
+// didn't exist.
+package linkfs
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Fs is an afero.Fs that hides the symbolic links and everything under
+// them. They are left out of the directories and reading them fails as
+// if they didn't exist, while writing them is not permitted.
+//
+// The links are only found on filesystems that implement afero.Lstater.
+type Fs struct {
+	source afero.Fs
+}
+
+// New creates a new Fs on top of source that hides its links.
+func New(source afero.Fs) *Fs {
+	return &Fs{source: source}
+}
+
+// Source returns the underlying Fs.
+func (fs *Fs) Source() afero.Fs {
+	return fs.source
+}
+
+// WithContext binds the underlying Fs to ctx if it supports it.
+func (fs *Fs) WithContext(ctx context.Context) afero.Fs {
+	if cfs, ok := fs.source.(interface {
+		WithContext(ctx context.Context) afero.Fs
+	}); ok {
+		return &Fs{source: cfs.WithContext(ctx)}
+	}
+
+	return fs
+}
+
+// Hidden checks if name is a link or is under one.
+func (fs *Fs) Hidden(name string) bool {
+	lstater, ok := fs.source.(afero.Lstater)
+	if !ok {
+		return false
+	}
+
+	for p := path.Clean("/" + filepath.ToSlash(name)); p != "/"; p = path.Dir(p) {
+		info, lstated, err := lstater.LstatIfPossible(p)
+		if err == nil && lstated && info.Mode()&os.ModeSymlink != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func denied(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+// Name implements afero.Fs.
+func (fs *Fs) Name() string {
+	return "linkfs"
+}
+
+// Create implements afero.Fs.
+func (fs *Fs) Create(name string) (afero.File, error) {
+	if fs.Hidden(name) {
+		return nil, denied("create", name)
+	}
+
+	return fs.source.Create(name)
+}
+
+// Mkdir implements afero.Fs.
+func (fs *Fs) Mkdir(name string, perm os.FileMode) error {
+	if fs.Hidden(name) {
+		return denied("mkdir", name)
+	}
+
+	return fs.source.Mkdir(name, perm)
+}
+
+// MkdirAll implements afero.Fs.
+func (fs *Fs) MkdirAll(name string, perm os.FileMode) error {
+	if fs.Hidden(name) {
+		return denied("mkdir", name)
+	}
+
+	return fs.source.MkdirAll(name, perm)
+}
+
+// Open implements afero.Fs.
+func (fs *Fs) Open(name string) (afero.File, error) {
+	if fs.Hidden(name) {
+		return nil, notExist("open", name)
+	}
+
+	f, err := fs.source.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f}, nil
+}
+
+// OpenFile implements afero.Fs.
+func (fs *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if fs.Hidden(name) {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+			return nil, denied("open", name)
+		}
+		return nil, notExist("open", name)
+	}
+
+	f, err := fs.source.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &file{File: f}, nil
+}
+
+// Remove implements afero.Fs.
+func (fs *Fs) Remove(name string) error {
+	if fs.Hidden(name) {
+		return notExist("remove", name)
+	}
+
+	return fs.source.Remove(name)
+}
+
+// RemoveAll implements afero.Fs.
+func (fs *Fs) RemoveAll(name string) error {
+	if fs.Hidden(name) {
+		return notExist("removeall", name)
+	}
+
+	return fs.source.RemoveAll(name)
+}
+
+// Rename implements afero.Fs.
+func (fs *Fs) Rename(oldname, newname string) error {
+	if fs.Hidden(oldname) {
+		return notExist("rename", oldname)
+	}
+
+	if fs.Hidden(newname) {
+		return denied("rename", oldname)
+	}
+
+	return fs.source.Rename(oldname, newname)
+}
+
+// Stat implements afero.Fs.
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	if fs.Hidden(name) {
+		return nil, notExist("stat", name)
+	}
+
+	return fs.source.Stat(name)
+}
+
+// LstatIfPossible implements afero.Lstater.
+func (fs *Fs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if fs.Hidden(name) {
+		return nil, false, notExist("lstat", name)
+	}
+
+	if lstater, ok := fs.source.(afero.Lstater); ok {
+		return lstater.LstatIfPossible(name)
+	}
+
+	info, err := fs.source.Stat(name)
+	return info, false, err
+}
+
+// Chmod implements afero.Fs.
+func (fs *Fs) Chmod(name string, mode os.FileMode) error {
+	if fs.Hidden(name) {
+		return notExist("chmod", name)
+	}
+
+	return fs.source.Chmod(name, mode)
+}
+
+// Chtimes implements afero.Fs.
+func (fs *Fs) Chtimes(name string, atime, mtime time.Time) error {
+	if fs.Hidden(name) {
+		return notExist("chtimes", name)
+	}
+
+	return fs.source.Chtimes(name, atime, mtime)
+}
+
+// file leaves the links out of the directories.
+type file struct {
+	afero.File
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	for {
+		infos, err := f.File.Readdir(count)
+
+		kept := infos[:0]
+		for _, info := range infos {
+			if info.Mode()&os.ModeSymlink == 0 {
+				kept = append(kept, info)
+			}
+		}
+
+		// Reading a few entries only returns none at the end, so the
+		// links are replaced by the next entries.
+		if len(kept) > 0 || len(infos) == 0 || count <= 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+func (f *file) Readdirnames(count int) ([]string, error) {
+	infos, err := f.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+
+	return names, err
+}
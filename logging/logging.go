@@ -0,0 +1,195 @@
+// Package logging defines the interface File Browser logs through, so the
+// programs that embed it can use their own loggers, and adapters for the
+// standard library logger and for JSON lines.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger logs messages with fields, which are pairs of a key and a value,
+// such as Info("listing", "path", "/docs", "items", 3).
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// Level is the severity of a message.
+type Level int
+
+// The levels, from the least to the most severe.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// ParseLevel returns the level named s, which is one of debug, info,
+// warn and error.
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelError; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown log level %q: it must be debug, info, warn or error", s)
+}
+
+// Nop is a Logger that discards everything.
+var Nop Logger = nop{}
+
+type nop struct{}
+
+func (nop) Debug(string, ...interface{}) {}
+func (nop) Info(string, ...interface{})  {}
+func (nop) Warn(string, ...interface{})  {}
+func (nop) Error(string, ...interface{}) {}
+
+// With returns a Logger that logs the messages through l with fields
+// before their own, such as the ID of the request they are about.
+func With(l Logger, fields ...interface{}) Logger {
+	if l == Nop {
+		return l
+	}
+
+	return &with{logger: l, fields: fields}
+}
+
+type with struct {
+	logger Logger
+	fields []interface{}
+}
+
+func (w *with) join(fields []interface{}) []interface{} {
+	all := make([]interface{}, 0, len(w.fields)+len(fields))
+	return append(append(all, w.fields...), fields...)
+}
+
+func (w *with) Debug(msg string, fields ...interface{}) { w.logger.Debug(msg, w.join(fields)...) }
+func (w *with) Info(msg string, fields ...interface{})  { w.logger.Info(msg, w.join(fields)...) }
+func (w *with) Warn(msg string, fields ...interface{})  { w.logger.Warn(msg, w.join(fields)...) }
+func (w *with) Error(msg string, fields ...interface{}) { w.logger.Error(msg, w.join(fields)...) }
+
+// leveled implements Logger on top of a function that writes the
+// messages of at least min.
+type leveled struct {
+	min   Level
+	write func(level Level, msg string, fields []interface{})
+}
+
+func (l *leveled) log(level Level, msg string, fields []interface{}) {
+	if level >= l.min {
+		l.write(level, msg, fields)
+	}
+}
+
+func (l *leveled) Debug(msg string, fields ...interface{}) { l.log(LevelDebug, msg, fields) }
+func (l *leveled) Info(msg string, fields ...interface{})  { l.log(LevelInfo, msg, fields) }
+func (l *leveled) Warn(msg string, fields ...interface{})  { l.log(LevelWarn, msg, fields) }
+func (l *leveled) Error(msg string, fields ...interface{}) { l.log(LevelError, msg, fields) }
+
+// pairs calls fn with the keys and values of fields. A value without a
+// key gets the key "extra".
+func pairs(fields []interface{}, fn func(key string, value interface{})) {
+	for i := 0; i < len(fields); i += 2 {
+		if i+1 == len(fields) {
+			fn("extra", fields[i])
+			return
+		}
+
+		fn(fmt.Sprint(fields[i]), fields[i+1])
+	}
+}
+
+// NewStd returns a Logger that writes the messages of at least min
+// through l as the message followed by key=value fields. A nil l is the
+// standard logger of the log package.
+func NewStd(l *log.Logger, min Level) Logger {
+	output := log.Print
+	if l != nil {
+		output = l.Print
+	}
+
+	return &leveled{min: min, write: func(level Level, msg string, fields []interface{}) {
+		var b strings.Builder
+		b.WriteString(strings.ToUpper(level.String()))
+		b.WriteString(" ")
+		b.WriteString(msg)
+
+		pairs(fields, func(key string, value interface{}) {
+			s := fmt.Sprint(value)
+			if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+				s = strconv.Quote(s)
+			}
+
+			fmt.Fprintf(&b, " %s=%s", key, s)
+		})
+
+		output(b.String())
+	}}
+}
+
+// NewJSON returns a Logger that writes the messages of at least min to w
+// as JSON objects, one per line, with the time, the level, the message
+// and the fields. Errors and durations are written as strings.
+func NewJSON(w io.Writer, min Level) Logger {
+	var mu sync.Mutex
+	return &leveled{min: min, write: func(level Level, msg string, fields []interface{}) {
+		entry := map[string]interface{}{}
+		pairs(fields, func(key string, value interface{}) {
+			switch v := value.(type) {
+			case time.Time:
+			case error:
+				value = v.Error()
+			case time.Duration:
+				value = v.String()
+			case fmt.Stringer:
+				value = v.String()
+			}
+
+			entry[key] = value
+		})
+
+		entry["time"] = time.Now().Format(time.RFC3339Nano)
+		entry["level"] = level.String()
+		entry["msg"] = msg
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			line, _ = json.Marshal(map[string]interface{}{
+				"time":  entry["time"],
+				"level": level.String(),
+				"msg":   msg,
+				"error": "couldn't encode the fields: " + err.Error(),
+			})
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = w.Write(append(line, '\n'))
+	}}
+}
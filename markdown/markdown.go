@@ -0,0 +1,390 @@
+// Package markdown renders the common subset of Markdown to HTML:
+// headings, paragraphs, lists, block quotes, code blocks, rules,
+// emphasis, code spans, links and images. Raw HTML is escaped.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Render renders Markdown to HTML.
+func Render(src []byte) string {
+	text := strings.Replace(string(src), "\r\n", "\n", -1)
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(text, "\n"))
+	return b.String()
+}
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+		case isFence(trimmed):
+			fence := trimmed[:3]
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), fence) {
+				j++
+			}
+
+			writeCode(b, lines[i+1:min(j, len(lines))])
+			i = j + 1
+		case isIndented(line):
+			j := i
+			for j < len(lines) && (isIndented(lines[j]) || strings.TrimSpace(lines[j]) == "") {
+				j++
+			}
+			for strings.TrimSpace(lines[j-1]) == "" {
+				j--
+			}
+
+			code := make([]string, 0, j-i)
+			for _, l := range lines[i:j] {
+				code = append(code, unindent(l))
+			}
+
+			writeCode(b, code)
+			i = j
+		case headingLevel(trimmed) > 0:
+			level := headingLevel(trimmed)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, inline(headingText(trimmed[level:])), level)
+			i++
+		case isRule(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+		case listMarker(trimmed) != "":
+			i = renderList(b, lines, i)
+		default:
+			var paragraph []string
+			for ; i < len(lines) && !startsBlock(lines[i]); i++ {
+				paragraph = append(paragraph, strings.TrimSpace(lines[i]))
+			}
+
+			b.WriteString("<p>" + inline(strings.Join(paragraph, "\n")) + "</p>\n")
+		}
+	}
+}
+
+// renderList renders the list starting at lines[i] and returns the index
+// of the line after it. Lines that are not items continue the previous
+// one.
+func renderList(b *strings.Builder, lines []string, i int) int {
+	ordered := isOrdered(listMarker(strings.TrimSpace(lines[i])))
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+
+	var items []string
+	for i < len(lines) {
+		trimmed := strings.TrimSpace(lines[i])
+		marker := listMarker(trimmed)
+
+		switch {
+		case marker != "" && isOrdered(marker) == ordered:
+			items = append(items, strings.TrimSpace(trimmed[len(marker):]))
+		case trimmed == "":
+			// A blank line ends the list unless another item follows.
+			if i+1 >= len(lines) || listMarker(strings.TrimSpace(lines[i+1])) == "" {
+				return writeList(b, tag, items, i+1)
+			}
+		case marker == "" && !startsBlock(lines[i]):
+			items[len(items)-1] += "\n" + trimmed
+		default:
+			return writeList(b, tag, items, i)
+		}
+
+		i++
+	}
+
+	return writeList(b, tag, items, i)
+}
+
+func writeList(b *strings.Builder, tag string, items []string, next int) int {
+	b.WriteString("<" + tag + ">\n")
+	for _, item := range items {
+		b.WriteString("<li>" + inline(item) + "</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+	return next
+}
+
+func writeCode(b *strings.Builder, lines []string) {
+	b.WriteString("<pre><code>")
+	for _, line := range lines {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+// startsBlock checks if a line ends a paragraph.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" ||
+		isFence(trimmed) ||
+		headingLevel(trimmed) > 0 ||
+		isRule(trimmed) ||
+		strings.HasPrefix(trimmed, ">") ||
+		listMarker(trimmed) != ""
+}
+
+func isFence(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+func unindent(line string) string {
+	if strings.HasPrefix(line, "\t") {
+		return line[1:]
+	}
+
+	return strings.TrimPrefix(line, "    ")
+}
+
+// headingLevel returns the level of an ATX heading, or 0 if the line is
+// not one.
+func headingLevel(trimmed string) int {
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+
+	if level == 0 || level > 6 || (level < len(trimmed) && trimmed[level] != ' ') {
+		return 0
+	}
+
+	return level
+}
+
+// headingText removes the optional closing sequence of a heading.
+func headingText(text string) string {
+	text = strings.TrimSpace(text)
+	closing := strings.TrimRight(text, "#")
+	if closing == "" || strings.HasSuffix(closing, " ") {
+		text = closing
+	}
+
+	return strings.TrimSpace(text)
+}
+
+func isRule(trimmed string) bool {
+	s := strings.Replace(trimmed, " ", "", -1)
+	if len(s) < 3 {
+		return false
+	}
+
+	return strings.Count(s, s[:1]) == len(s) && strings.Contains("-*_", s[:1])
+}
+
+// listMarker returns the marker of a list item with the space after it,
+// or an empty string if the line is not an item.
+func listMarker(trimmed string) string {
+	if len(trimmed) >= 2 && strings.Contains("-*+", trimmed[:1]) && trimmed[1] == ' ' {
+		return trimmed[:2]
+	}
+
+	i := 0
+	for i < len(trimmed) && i < 9 && trimmed[i] >= '0' && trimmed[i] <= '9' {
+		i++
+	}
+
+	if i > 0 && i+1 < len(trimmed) && (trimmed[i] == '.' || trimmed[i] == ')') && trimmed[i+1] == ' ' {
+		return trimmed[:i+2]
+	}
+
+	return ""
+}
+
+func isOrdered(marker string) bool {
+	return marker != "" && marker[0] >= '0' && marker[0] <= '9'
+}
+
+// escapable are the characters that can be escaped with a backslash.
+const escapable = "\\`*_{}[]()#+-.!<>"
+
+// inline renders the inline elements of text.
+func inline(text string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte(escapable, text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+		case c == '`':
+			n := 1
+			for i+n < len(text) && text[i+n] == '`' {
+				n++
+			}
+
+			end := strings.Index(text[i+n:], text[i:i+n])
+			if end < 0 {
+				b.WriteString(text[i : i+n])
+				i += n
+				continue
+			}
+
+			code := strings.TrimSpace(text[i+n : i+n+end])
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			i += 2*n + end
+		case c == '!' && strings.HasPrefix(text[i+1:], "["):
+			label, target, n, ok := link(text[i+1:])
+			if !ok {
+				b.WriteByte('!')
+				i++
+				continue
+			}
+
+			if target = safeURL(target); target != "" {
+				fmt.Fprintf(&b, `<img src="%s" alt="%s">`, html.EscapeString(target), html.EscapeString(label))
+			} else {
+				b.WriteString(html.EscapeString(label))
+			}
+			i += 1 + n
+		case c == '[':
+			label, target, n, ok := link(text[i:])
+			if !ok {
+				b.WriteByte('[')
+				i++
+				continue
+			}
+
+			if target = safeURL(target); target != "" {
+				fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(target), inline(label))
+			} else {
+				b.WriteString(inline(label))
+			}
+			i += n
+		case c == '<':
+			end := strings.IndexByte(text[i:], '>')
+			if end > 0 {
+				target := text[i+1 : i+end]
+				if !strings.ContainsAny(target, " \n") && strings.Contains(target, ":") && safeURL(target) != "" {
+					fmt.Fprintf(&b, `<a href="%s">%s</a>`, html.EscapeString(target), html.EscapeString(target))
+					i += end + 1
+					continue
+				}
+			}
+
+			b.WriteString("&lt;")
+			i++
+		case c == '*' || c == '_':
+			n := 1
+			if i+1 < len(text) && text[i+1] == c {
+				n = 2
+			}
+
+			delim := text[i : i+n]
+			end := strings.Index(text[i+n:], delim)
+			intraword := c == '_' && i > 0 && isWordByte(text[i-1])
+			if end <= 0 || intraword || text[i+n] == ' ' {
+				b.WriteString(text[i : i+n])
+				i += n
+				continue
+			}
+
+			tag := "em"
+			if n == 2 {
+				tag = "strong"
+			}
+
+			b.WriteString("<" + tag + ">" + inline(text[i+n:i+n+end]) + "</" + tag + ">")
+			i += 2*n + end
+		case c == '&' || c == '>' || c == '"' || c == '\'':
+			b.WriteString(html.EscapeString(text[i : i+1]))
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// link parses a link that starts text, such as [label](target), and
+// returns its label, its target and its length.
+func link(text string) (label, target string, n int, ok bool) {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+
+			if !strings.HasPrefix(text[i+1:], "(") {
+				return "", "", 0, false
+			}
+
+			end := strings.IndexByte(text[i+2:], ')')
+			if end < 0 {
+				return "", "", 0, false
+			}
+
+			target = strings.TrimSpace(text[i+2 : i+2+end])
+			// Drops the title.
+			if space := strings.IndexAny(target, " \t"); space >= 0 {
+				target = target[:space]
+			}
+
+			return text[1:i], strings.Trim(target, "<>"), i + 3 + end, true
+		}
+	}
+
+	return "", "", 0, false
+}
+
+// safeURL returns the URL if it is relative or uses a scheme that can't
+// run scripts, or an empty string otherwise.
+func safeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return raw
+	default:
+		return ""
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,36 @@
+// Package metrics defines the interface File Browser counts its requests
+// and transfers through, so the programs that embed it can register the
+// metrics on their own Prometheus registry, and a Registry that keeps
+// them in memory and serves them in the Prometheus text format.
+//
+// A prometheus.Registerer is adapted by creating a CounterVec or a
+// HistogramVec in NewCounter and NewHistogram, and calling Add or Observe
+// on the result of WithLabelValues.
+package metrics
+
+// Registerer creates the metrics. The names of the metrics and of their
+// labels must be valid Prometheus names, and each name can only be
+// registered once.
+type Registerer interface {
+	NewCounter(name, help string, labels ...string) Counter
+	NewHistogram(name, help string, buckets []float64, labels ...string) Histogram
+}
+
+// Counter is a metric that only goes up. The label values are given in
+// the order of the labels it was created with.
+type Counter interface {
+	Add(value float64, labelValues ...string)
+}
+
+// Histogram counts observations in buckets. The label values are given
+// in the order of the labels it was created with.
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// DurationBuckets are the buckets, in seconds, of the histograms of
+// durations. They are the default ones of Prometheus.
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// CountBuckets are the buckets of the histograms of numbers of items.
+var CountBuckets = []float64{0, 1, 10, 100, 1000, 10000, 100000}
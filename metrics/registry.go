@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry is a Registerer that keeps the metrics in memory. It is an
+// http.Handler that serves them in the Prometheus text format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+const (
+	kindCounter   = "counter"
+	kindHistogram = "histogram"
+)
+
+type metric struct {
+	registry *Registry
+	name     string
+	help     string
+	kind     string
+	labels   []string
+	buckets  []float64
+	series   map[string]*series
+}
+
+// series holds the values of a metric for a set of label values. The
+// counters only use sum.
+type series struct {
+	labelValues []string
+	sum         float64
+	count       uint64
+	buckets     []uint64
+}
+
+func (r *Registry) register(name, help, kind string, buckets []float64, labels []string) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.metrics {
+		if m.name == name {
+			panic(fmt.Sprintf("metrics: %s is already registered", name))
+		}
+	}
+
+	m := &metric{
+		registry: r,
+		name:     name,
+		help:     help,
+		kind:     kind,
+		labels:   append([]string(nil), labels...),
+		buckets:  append([]float64(nil), buckets...),
+		series:   map[string]*series{},
+	}
+	sort.Float64s(m.buckets)
+
+	// The metrics without labels have a single series, which is written
+	// even if it was never changed.
+	if len(labels) == 0 {
+		m.get(nil)
+	}
+
+	r.metrics = append(r.metrics, m)
+	return m
+}
+
+// NewCounter implements Registerer.
+func (r *Registry) NewCounter(name, help string, labels ...string) Counter {
+	return r.register(name, help, kindCounter, nil, labels)
+}
+
+// NewHistogram implements Registerer.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) Histogram {
+	return r.register(name, help, kindHistogram, buckets, labels)
+}
+
+// get returns the series of the label values. The registry must be
+// locked.
+func (m *metric) get(labelValues []string) *series {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{
+			labelValues: append([]string(nil), labelValues...),
+			buckets:     make([]uint64, len(m.buckets)),
+		}
+		m.series[key] = s
+	}
+
+	return s
+}
+
+func (m *metric) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		panic(fmt.Sprintf("metrics: the counter %s can't go down", m.name))
+	}
+
+	m.registry.mu.Lock()
+	defer m.registry.mu.Unlock()
+
+	m.get(labelValues).sum += value
+}
+
+func (m *metric) Observe(value float64, labelValues ...string) {
+	m.registry.mu.Lock()
+	defer m.registry.mu.Unlock()
+
+	s := m.get(labelValues)
+	s.sum += value
+	s.count++
+	for i, bound := range m.buckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	bw := bufio.NewWriter(w)
+	r.write(bw)
+	_ = bw.Flush()
+}
+
+func (r *Registry) write(w *bufio.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+		keys := make([]string, 0, len(m.series))
+		for key := range m.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		leNames := append(append([]string(nil), m.labels...), "le")
+		for _, key := range keys {
+			s := m.series[key]
+			labels := formatLabels(m.labels, s.labelValues)
+
+			if m.kind == kindCounter {
+				fmt.Fprintf(w, "%s%s %s\n", m.name, labels, formatValue(s.sum))
+				continue
+			}
+
+			leValues := append(append([]string(nil), s.labelValues...), "")
+			for i, bound := range m.buckets {
+				leValues[len(leValues)-1] = formatValue(bound)
+				fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(leNames, leValues), s.buckets[i])
+			}
+
+			leValues[len(leValues)-1] = "+Inf"
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(leNames, leValues), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", m.name, labels, formatValue(s.sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", m.name, labels, s.count)
+		}
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,161 @@
+package normfs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Fs is an afero.Fs that finds the files whose names differ from the
+// requested ones only in their Unicode normalization form, such as the
+// NFD names written by macOS, or, optionally, in their case.
+//
+// The exact name always wins. Otherwise, a name with the same NFC form
+// is used and then, if enabled, one that only differs in case. When
+// several names on a directory match at the same level, the first one
+// in lexical order wins.
+type Fs struct {
+	source          afero.Fs
+	caseInsensitive bool
+}
+
+// New creates a new Fs on top of source.
+func New(source afero.Fs, caseInsensitive bool) *Fs {
+	return &Fs{source: source, caseInsensitive: caseInsensitive}
+}
+
+// Source returns the underlying Fs.
+func (fs *Fs) Source() afero.Fs {
+	return fs.source
+}
+
+// resolve returns the name on disk of name. If some element of the
+// path does not exist, the rest of the path is kept as is so new
+// files are created inside the existing directories.
+func (fs *Fs) resolve(name string) string {
+	if _, err := fs.source.Stat(name); err == nil || !os.IsNotExist(err) {
+		return name
+	}
+
+	elems := strings.Split(strings.Trim(filepath.ToSlash(name), "/"), "/")
+	current := "/"
+
+	for i, elem := range elems {
+		next := path.Join(current, elem)
+		if _, err := fs.source.Stat(next); err == nil {
+			current = next
+			continue
+		}
+
+		match, ok := fs.lookup(current, elem)
+		if !ok {
+			return path.Join(append([]string{current}, elems[i:]...)...)
+		}
+
+		current = path.Join(current, match)
+	}
+
+	return current
+}
+
+// lookup finds the entry of dir that matches elem.
+func (fs *Fs) lookup(dir, elem string) (string, bool) {
+	infos, err := afero.ReadDir(fs.source, dir)
+	if err != nil {
+		return "", false
+	}
+
+	want := norm.NFC.String(elem)
+	for _, info := range infos {
+		if norm.NFC.String(info.Name()) == want {
+			return info.Name(), true
+		}
+	}
+
+	if !fs.caseInsensitive {
+		return "", false
+	}
+
+	for _, info := range infos {
+		if strings.EqualFold(norm.NFC.String(info.Name()), want) {
+			return info.Name(), true
+		}
+	}
+
+	return "", false
+}
+
+// Name implements afero.Fs.
+func (fs *Fs) Name() string {
+	return "normfs"
+}
+
+// Create implements afero.Fs.
+func (fs *Fs) Create(name string) (afero.File, error) {
+	return fs.source.Create(fs.resolve(name))
+}
+
+// Mkdir implements afero.Fs.
+func (fs *Fs) Mkdir(name string, perm os.FileMode) error {
+	return fs.source.Mkdir(fs.resolve(name), perm)
+}
+
+// MkdirAll implements afero.Fs.
+func (fs *Fs) MkdirAll(name string, perm os.FileMode) error {
+	return fs.source.MkdirAll(fs.resolve(name), perm)
+}
+
+// Open implements afero.Fs.
+func (fs *Fs) Open(name string) (afero.File, error) {
+	return fs.source.Open(fs.resolve(name))
+}
+
+// OpenFile implements afero.Fs.
+func (fs *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	return fs.source.OpenFile(fs.resolve(name), flag, perm)
+}
+
+// Remove implements afero.Fs.
+func (fs *Fs) Remove(name string) error {
+	return fs.source.Remove(fs.resolve(name))
+}
+
+// RemoveAll implements afero.Fs.
+func (fs *Fs) RemoveAll(name string) error {
+	return fs.source.RemoveAll(fs.resolve(name))
+}
+
+// Rename implements afero.Fs.
+func (fs *Fs) Rename(oldname, newname string) error {
+	return fs.source.Rename(fs.resolve(oldname), fs.resolve(newname))
+}
+
+// Stat implements afero.Fs.
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	return fs.source.Stat(fs.resolve(name))
+}
+
+// LstatIfPossible implements afero.Lstater.
+func (fs *Fs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	if lstater, ok := fs.source.(afero.Lstater); ok {
+		return lstater.LstatIfPossible(fs.resolve(name))
+	}
+
+	info, err := fs.Stat(name)
+	return info, false, err
+}
+
+// Chmod implements afero.Fs.
+func (fs *Fs) Chmod(name string, mode os.FileMode) error {
+	return fs.source.Chmod(fs.resolve(name), mode)
+}
+
+// Chtimes implements afero.Fs.
+func (fs *Fs) Chtimes(name string, atime, mtime time.Time) error {
+	return fs.source.Chtimes(fs.resolve(name), atime, mtime)
+}
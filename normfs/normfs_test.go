@@ -0,0 +1,93 @@
+package normfs
+
+import (
+	"io"
+	"testing"
+
+	"github.com/spf13/afero"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	nfc = norm.NFC.String("café")
+	nfd = norm.NFD.String("café")
+)
+
+// newSource returns a file system with the files at paths, whose
+// contents are their paths.
+func newSource(t *testing.T, paths ...string) afero.Fs {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	for _, p := range paths {
+		if err := afero.WriteFile(fs, p, []byte(p), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return fs
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name            string
+		files           []string
+		caseInsensitive bool
+		open            string
+		want            string
+	}{
+		{"exact NFC", []string{"/" + nfc, "/" + nfd}, false, "/" + nfc, "/" + nfc},
+		{"exact NFD", []string{"/" + nfc, "/" + nfd}, false, "/" + nfd, "/" + nfd},
+		{"NFC of NFD", []string{"/" + nfd}, false, "/" + nfc, "/" + nfd},
+		{"NFD of NFC", []string{"/" + nfc}, false, "/" + nfd, "/" + nfc},
+		{"NFC in a NFD directory", []string{"/" + nfd + "/a.txt"}, false, "/" + nfc + "/a.txt", "/" + nfd + "/a.txt"},
+		{"case sensitive", []string{"/Docs/a.txt"}, false, "/docs/a.txt", ""},
+		{"case insensitive", []string{"/Docs/a.txt"}, true, "/docs/A.TXT", "/Docs/a.txt"},
+		{"exact case wins", []string{"/Docs", "/docs"}, true, "/docs", "/docs"},
+		{"normalization before case", []string{"/CAFÉ", "/" + nfd}, true, "/" + nfc, "/" + nfd},
+		{"first in lexical order", []string{"/DOCS", "/Docs"}, true, "/docs", "/DOCS"},
+		{"missing", []string{"/a.txt"}, true, "/b.txt", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := New(newSource(t, tt.files...), tt.caseInsensitive)
+
+			f, err := fs.Open(tt.open)
+			if tt.want == "" {
+				if err == nil {
+					f.Close()
+					t.Fatalf("Open(%q) succeeded, want an error", tt.open)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Open(%q): %v", tt.open, err)
+			}
+			defer f.Close()
+
+			got, err := io.ReadAll(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("Open(%q) opened %q, want %q", tt.open, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateInExistingDirectory(t *testing.T) {
+	source := newSource(t, "/"+nfd+"/a.txt")
+	fs := New(source, true)
+
+	if err := afero.WriteFile(fs, "/"+norm.NFC.String("CAFÉ")+"/b.txt", []byte("b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.Stat("/" + nfd + "/b.txt"); err != nil {
+		t.Errorf("the file wasn't created in the existing directory: %v", err)
+	}
+}
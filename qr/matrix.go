@@ -0,0 +1,274 @@
+package qr
+
+// matrix is a QR code being drawn. The function modules are the ones of
+// the patterns, which the data and the masks don't touch.
+type matrix struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newCode(v int) *matrix {
+	size := 17 + 4*v
+	m := &matrix{
+		version:  v,
+		size:     size,
+		modules:  make([][]bool, size),
+		function: make([][]bool, size),
+	}
+
+	for i := range m.modules {
+		m.modules[i] = make([]bool, size)
+		m.function[i] = make([]bool, size)
+	}
+
+	// Timing patterns.
+	for i := 0; i < size; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(size-4, 3)
+	m.drawFinder(3, size-4)
+
+	align := versions[v].alignment
+	for i, x := range align {
+		for j, y := range align {
+			// The ones that would overlap the finder patterns are skipped.
+			if i == 0 && j == 0 || i == 0 && j == len(align)-1 || i == len(align)-1 && j == 0 {
+				continue
+			}
+
+			m.drawAlignment(x, y)
+		}
+	}
+
+	// Reserves the format areas, which are drawn after masking.
+	m.drawFormat(0)
+	m.drawVersion()
+	return m
+}
+
+func (m *matrix) set(x, y int, black bool) {
+	m.modules[y][x] = black
+	m.function[y][x] = true
+}
+
+func (m *matrix) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= m.size || y >= m.size {
+				continue
+			}
+
+			dist := max(abs(dx), abs(dy))
+			m.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *matrix) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws the error correction level, which is always medium,
+// and the mask.
+func (m *matrix) drawFormat(mask int) {
+	data := 0<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool {
+		return bits>>uint(i)&1 == 1
+	}
+
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+	m.set(8, m.size-8, true)
+}
+
+func (m *matrix) drawVersion() {
+	if m.version < 7 {
+		return
+	}
+
+	rem := m.version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := m.version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		black := bits>>uint(i)&1 == 1
+		a, b := m.size-11+i%3, i/3
+		m.set(a, b, black)
+		m.set(b, a, black)
+	}
+}
+
+// drawCodewords places the codewords in the two modules wide columns,
+// going up and down from the bottom right corner.
+func (m *matrix) drawCodewords(codewords []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = m.size - 1 - vert
+				}
+
+				if m.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+
+				m.modules[y][x] = codewords[i/8]>>uint(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules selected by mask. Applying a mask
+// twice undoes it.
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+
+			if flip && !m.function[y][x] {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to read, which is used to pick
+// the mask.
+func (m *matrix) penalty() int {
+	penalty := 0
+	dark := 0
+
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i < m.size; i++ {
+			if get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				penalty += run - 2
+			}
+			run = 1
+		}
+		if run >= 5 {
+			penalty += run - 2
+		}
+
+		// Patterns that look like the finder ones.
+		for i := 0; i+11 <= m.size; i++ {
+			pattern := 0
+			for j := 0; j < 11; j++ {
+				pattern <<= 1
+				if get(i + j) {
+					pattern |= 1
+				}
+			}
+			if pattern == 0x5D0 || pattern == 0x05D {
+				penalty += 40
+			}
+		}
+	}
+
+	for y := 0; y < m.size; y++ {
+		line(func(x int) bool { return m.modules[y][x] })
+	}
+
+	for x := 0; x < m.size; x++ {
+		line(func(y int) bool { return m.modules[y][x] })
+	}
+
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+
+			if x > 0 && y > 0 {
+				c := m.modules[y][x]
+				if c == m.modules[y-1][x] && c == m.modules[y][x-1] && c == m.modules[y-1][x-1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	total := m.size * m.size
+	penalty += abs(dark*20-total*10) / total * 10
+	return penalty
+}
+
+func (m *matrix) code() *Code {
+	return &Code{Size: m.size, modules: m.modules}
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,195 @@
+// Package qr encodes QR codes in byte mode with the medium error
+// correction level, which is enough for URLs of up to 213 bytes.
+package qr
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ErrTooLong is returned when the data doesn't fit in a QR code.
+var ErrTooLong = errors.New("data is too long for a QR code")
+
+// quietZone is the width of the margin around the code, in modules.
+const quietZone = 4
+
+// version has the error correction blocks of a QR code version with the
+// medium error correction level.
+type version struct {
+	ecPerBlock int
+	// blocks has the number of data codewords of each block.
+	blocks []int
+	// alignment has the coordinates of the alignment patterns.
+	alignment []int
+}
+
+var versions = []version{
+	1:  {10, []int{16}, nil},
+	2:  {16, []int{28}, []int{6, 18}},
+	3:  {26, []int{44}, []int{6, 22}},
+	4:  {18, []int{32, 32}, []int{6, 26}},
+	5:  {24, []int{43, 43}, []int{6, 30}},
+	6:  {16, []int{27, 27, 27, 27}, []int{6, 34}},
+	7:  {18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	8:  {22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	9:  {22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	10: {26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+func (v version) dataCodewords() int {
+	n := 0
+	for _, b := range v.blocks {
+		n += b
+	}
+	return n
+}
+
+// Code is a QR code.
+type Code struct {
+	// Size is the number of modules of each side of the code.
+	Size    int
+	modules [][]bool
+}
+
+// Black checks if the module at x, y is black.
+func (c *Code) Black(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode encodes data in the smallest QR code it fits in.
+func Encode(data []byte) (*Code, error) {
+	for v := 1; v < len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+
+		if 4+countBits+8*len(data) <= 8*versions[v].dataCodewords() {
+			return encode(v, countBits, data), nil
+		}
+	}
+
+	return nil, ErrTooLong
+}
+
+func encode(v, countBits int, data []byte) *Code {
+	info := versions[v]
+	capacity := info.dataCodewords()
+
+	var b bitBuffer
+	b.append(0x4, 4) // byte mode
+	b.append(len(data), countBits)
+	for _, c := range data {
+		b.append(int(c), 8)
+	}
+
+	// Terminator, padding to a whole byte and pad codewords.
+	b.append(0, min(4, 8*capacity-len(b)))
+	b.append(0, (8-len(b)%8)%8)
+	for pad := 0xEC; len(b) < 8*capacity; pad ^= 0xEC ^ 0x11 {
+		b.append(pad, 8)
+	}
+
+	codewords := interleave(b.bytes(), info)
+
+	c := newCode(v)
+	c.drawCodewords(codewords)
+
+	best, bestPenalty := -1, 0
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if penalty := c.penalty(); best == -1 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormat(best)
+	return c.code()
+}
+
+// interleave splits the data in blocks, adds their error correction
+// codewords and interleaves them.
+func interleave(data []byte, info version) []byte {
+	var blocks, ecBlocks [][]byte
+	for _, n := range info.blocks {
+		blocks = append(blocks, data[:n])
+		ecBlocks = append(ecBlocks, reedSolomon(data[:n], info.ecPerBlock))
+		data = data[n:]
+	}
+
+	var result []byte
+	for i := 0; i < info.blocks[len(info.blocks)-1]; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	for i := 0; i < info.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+
+	return result
+}
+
+// Image renders the code in a square image of side pixels with at least
+// the quiet zone around it. The modules are a whole number of pixels wide
+// and the pixels left over widen the margin.
+func (c *Code) Image(side int) image.Image {
+	scale := side / (c.Size + 2*quietZone)
+	if scale < 1 {
+		scale = 1
+		side = c.Size + 2*quietZone
+	}
+
+	offset := (side - c.Size*scale) / 2
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, value>>uint(i)&1 == 1)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package qr
+
+// exp and log are the tables of the powers and logarithms of the
+// generator of GF(256) with the polynomial x^8 + x^4 + x^3 + x^2 + 1.
+var exp, log [256]byte
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return exp[(int(log[a])+int(log[b]))%255]
+}
+
+// reedSolomon returns the n error correction codewords of data.
+func reedSolomon(data []byte, n int) []byte {
+	// The generator polynomial is the product of (x - 2^i) for i < n,
+	// without its leading coefficient, which is always 1.
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			gen[j] = mul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = mul(root, 2)
+	}
+
+	result := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[n-1] = 0
+		for j := range result {
+			result[j] ^= mul(gen[j], factor)
+		}
+	}
+
+	return result
+}
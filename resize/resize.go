@@ -0,0 +1,284 @@
+// Package resize scales the JPEG, PNG and GIF images down with the
+// standard library, turning the photos upright as their EXIF orientation
+// says, and encodes them as JPEG or PNG.
+package resize
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // The GIF images are resized too.
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+)
+
+// The ways the images fit in their boxes. FitInside scales them to fit
+// entirely in the box, and FitCover to fill it, cropping the center.
+const (
+	FitInside = "inside"
+	FitCover  = "cover"
+)
+
+// The formats of the resized images.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// MaxPixels is the number of pixels of the biggest image resized, since
+// the images are decoded in memory.
+const MaxPixels = 50_000_000
+
+// jpegQuality is the quality of the JPEG images.
+const jpegQuality = 85
+
+var (
+	// ErrTooBig is returned for the images with more than MaxPixels.
+	ErrTooBig = errors.New("the image is too big to be resized")
+	// ErrNoBox is returned when the options have neither a width nor a
+	// height.
+	ErrNoBox = errors.New("the image needs a width or a height")
+)
+
+// Options are the box, in pixels, the images are resized to, and their
+// format. A zero width or height doesn't constrain them, and FitCover
+// needs both to crop. The images are never scaled up.
+type Options struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// Resize decodes the image of r, resizes it and writes it to w. The
+// format defaults to JPEG for the JPEG images and PNG for the others. It
+// returns image.ErrFormat if r isn't an image of a known format.
+func Resize(w io.Writer, r io.ReadSeeker, opts Options) error {
+	if opts.Width <= 0 && opts.Height <= 0 {
+		return ErrNoBox
+	}
+
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return err
+	}
+
+	if config.Width*config.Height > MaxPixels {
+		return ErrTooBig
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		orientation = Orientation(r)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == "" {
+		opts.Format = FormatPNG
+		if format == "jpeg" {
+			opts.Format = FormatJPEG
+		}
+	}
+
+	// The image is resized before it's turned, so the box is turned the
+	// other way for the orientations that swap the sides.
+	width, height := opts.Width, opts.Height
+	if orientation >= 5 {
+		width, height = height, width
+	}
+
+	crop, dw, dh := layout(src.Bounds(), width, height, opts.Fit)
+	img := orient(scale(src, crop, dw, dh), orientation)
+
+	if opts.Format == FormatJPEG {
+		return jpeg.Encode(w, opaque(img), &jpeg.Options{Quality: jpegQuality})
+	}
+
+	return png.Encode(w, img)
+}
+
+// layout returns the part of an image with bounds b that is resized and
+// the size it's resized to, to fit in a box of width by height.
+func layout(b image.Rectangle, width, height int, fit string) (image.Rectangle, int, int) {
+	sw, sh := b.Dx(), b.Dy()
+
+	if fit == FitCover && width > 0 && height > 0 {
+		// The center with the proportions of the box is cropped.
+		cw, ch := sw, sh
+		if sw*height > sh*width {
+			cw = atLeastOne(sh * width / height)
+		} else {
+			ch = atLeastOne(sw * height / width)
+		}
+
+		x0 := b.Min.X + (sw-cw)/2
+		y0 := b.Min.Y + (sh-ch)/2
+		crop := image.Rect(x0, y0, x0+cw, y0+ch)
+		if cw <= width {
+			return crop, cw, ch
+		}
+		return crop, width, height
+	}
+
+	dw, dh := sw, sh
+	if width > 0 && dw > width {
+		dh = atLeastOne(dh * width / dw)
+		dw = width
+	}
+	if height > 0 && dh > height {
+		dw = atLeastOne(dw * height / dh)
+		dh = height
+	}
+
+	return b, dw, dh
+}
+
+// weight is the share of a source pixel in a resized one.
+type weight struct {
+	index int
+	value float64
+}
+
+// weights returns, for each of the n resized pixels of a line of size
+// source pixels, the weights of the source pixels it covers, which add
+// up to one.
+func weights(size, n int) [][]weight {
+	ratio := float64(size) / float64(n)
+	result := make([][]weight, n)
+	for i := range result {
+		start, end := float64(i)*ratio, float64(i+1)*ratio
+		for j := int(start); j < size && float64(j) < end; j++ {
+			covered := math.Min(end, float64(j+1)) - math.Max(start, float64(j))
+			if covered > 0 {
+				result[i] = append(result[i], weight{j, covered / ratio})
+			}
+		}
+	}
+
+	return result
+}
+
+// scale resizes the crop of src to dw by dh pixels, averaging the source
+// pixels each resized one covers. The source is read a row at a time, so
+// it's never converted whole.
+func scale(src image.Image, crop image.Rectangle, dw, dh int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	columns := weights(crop.Dx(), dw)
+	rows := weights(crop.Dy(), dh)
+
+	line := image.NewRGBA(image.Rect(0, 0, crop.Dx(), 1))
+	scaled := make([]float64, dw*4)
+	sum := make([]float64, dw*4)
+	last := -1
+
+	for y, row := range rows {
+		for i := range sum {
+			sum[i] = 0
+		}
+
+		for _, wy := range row {
+			// The rows shared by two resized rows are scaled once.
+			if wy.index != last {
+				draw.Draw(line, line.Rect, src, image.Pt(crop.Min.X, crop.Min.Y+wy.index), draw.Src)
+				for x, column := range columns {
+					var r, g, b, a float64
+					for _, wx := range column {
+						p := line.Pix[wx.index*4 : wx.index*4+4]
+						r += float64(p[0]) * wx.value
+						g += float64(p[1]) * wx.value
+						b += float64(p[2]) * wx.value
+						a += float64(p[3]) * wx.value
+					}
+					scaled[x*4], scaled[x*4+1], scaled[x*4+2], scaled[x*4+3] = r, g, b, a
+				}
+				last = wy.index
+			}
+
+			for i, v := range scaled {
+				sum[i] += v * wy.value
+			}
+		}
+
+		pix := dst.Pix[y*dst.Stride : y*dst.Stride+dw*4]
+		for i, v := range sum {
+			pix[i] = uint8(math.Min(255, v+0.5))
+		}
+	}
+
+	return dst
+}
+
+// orient turns img upright from the EXIF orientation, which tells how
+// the camera was held.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // Flipped sideways.
+				dx, dy = w-1-x, y
+			case 3: // Turned halfway.
+				dx, dy = w-1-x, h-1-y
+			case 4: // Flipped upside down.
+				dx, dy = x, h-1-y
+			case 5: // Flipped on the diagonal.
+				dx, dy = y, x
+			case 6: // Turned clockwise.
+				dx, dy = h-1-y, x
+			case 7: // Flipped on the other diagonal.
+				dx, dy = h-1-y, w-1-x
+			case 8: // Turned counterclockwise.
+				dx, dy = y, w-1-x
+			}
+
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], img.Pix[img.PixOffset(x, y):img.PixOffset(x, y)+4])
+		}
+	}
+
+	return dst
+}
+
+// opaque puts the transparent images on white, since JPEG has no
+// transparency and they would be on black otherwise.
+func opaque(img *image.RGBA) image.Image {
+	if img.Opaque() {
+		return img
+	}
+
+	dst := image.NewRGBA(img.Rect)
+	draw.Draw(dst, dst.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Rect, img, img.Rect.Min, draw.Over)
+	return dst
+}
+
+func atLeastOne(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
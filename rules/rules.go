@@ -1,8 +1,15 @@
 package rules
 
 import (
+	"fmt"
+	"net/http"
+	"path"
 	"regexp"
+	"regexp/syntax"
 	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // Checker is a Rules checker.
@@ -10,35 +17,310 @@ type Checker interface {
 	Check(path string) bool
 }
 
-// Rule is a allow/disallow rule.
+// Rule is a allow/disallow rule. With Glob, Path is a glob pattern
+// rather than a prefix. Methods, when set, are the methods of the
+// requests the rule applies to; it applies to all of them otherwise.
 type Rule struct {
-	Regex  bool    `json:"regex"`
-	Allow  bool    `json:"allow"`
-	Path   string  `json:"path"`
-	Regexp *Regexp `json:"regexp"`
+	Regex   bool     `json:"regex"`
+	Glob    bool     `json:"glob,omitempty"`
+	Allow   bool     `json:"allow"`
+	Path    string   `json:"path"`
+	Regexp  *Regexp  `json:"regexp"`
+	Methods []string `json:"methods,omitempty"`
 }
 
-// Matches matches a path against a rule.
+// Matches matches a path against a rule. Non regex rules match
+// whole path segments so "/files" doesn't match "/filesystem". Paths
+// are compared in NFC so a rule can't be bypassed by requesting the
+// same name in another normalization form.
 func (r *Rule) Matches(path string) bool {
+	return r.matches(path, false)
+}
+
+// MatchesFold is like Matches, but regardless of case, for the scopes
+// that find their files regardless of case, where "/Files" is the same
+// file as "/files".
+func (r *Rule) MatchesFold(path string) bool {
+	return r.matches(path, true)
+}
+
+func (r *Rule) matches(path string, fold bool) bool {
+	path = norm.NFC.String(path)
+
 	if r.Regex {
+		// The expressions are checked when the rules are saved, so only
+		// the ones saved before can't compile: they deny everything
+		// rather than allow nothing, since they were meant to.
+		if r.Regexp == nil || r.Regexp.Compile() != nil {
+			return !r.Allow
+		}
+
+		if fold {
+			return r.Regexp.MatchStringFold(path)
+		}
 		return r.Regexp.MatchString(path)
 	}
 
-	return strings.HasPrefix(path, r.Path)
+	if r.Glob {
+		pattern := norm.NFC.String(r.Path)
+		if fold {
+			path, pattern = strings.ToLower(path), strings.ToLower(pattern)
+		}
+		return matchGlob(segments(pattern), segments(path))
+	}
+
+	prefix := norm.NFC.String(strings.TrimSuffix(r.Path, "/"))
+	if fold {
+		path, prefix = strings.ToLower(path), strings.ToLower(prefix)
+	}
+
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// appliesTo checks if the rule applies to the requests with method.
+func (r *Rule) appliesTo(method string) bool {
+	if len(r.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range r.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// specificity ranks the rules that match the same path by the length of
+// the path they spell out: the path of the path rules, the segments of
+// the glob rules before the first one with a wildcard, and the literal
+// prefix of the regex rules anchored with ^. So "/private/**" is as
+// specific as "/private", and the regex rules that aren't anchored, which
+// match anywhere, are the least specific.
+func (r *Rule) specificity() int {
+	switch {
+	case r.Regex:
+		if r.Regexp == nil || r.Regexp.Compile() != nil {
+			return 0
+		}
+		return len(r.Regexp.prefix)
+	case r.Glob:
+		return len(globPrefix(r.Path))
+	}
+
+	return len(strings.TrimSuffix(r.Path, "/"))
+}
+
+// Match returns the most specific rule that matches path: the one that
+// spells out the longest path, regardless of the order they were
+// declared. When two matching rules are equally specific, the one
+// declared first wins. It returns nil if no rule matches. Only the rules
+// that apply to GET are matched, since they tell what can be seen.
+func Match(rules []Rule, path string) *Rule {
+	return match(rules, path, http.MethodGet, false)
+}
+
+// MatchFold is like Match, but matches the rules regardless of case.
+func MatchFold(rules []Rule, path string) *Rule {
+	return match(rules, path, http.MethodGet, true)
+}
+
+// MatchMethod is like Match, but for the requests with method.
+func MatchMethod(rules []Rule, path, method string) *Rule {
+	return match(rules, path, method, false)
+}
+
+// MatchMethodFold is like MatchMethod, but matches the rules regardless
+// of case.
+func MatchMethodFold(rules []Rule, path, method string) *Rule {
+	return match(rules, path, method, true)
+}
+
+func match(rules []Rule, path, method string, fold bool) *Rule {
+	var match *Rule
+
+	for i := range rules {
+		rule := &rules[i]
+		if !rule.appliesTo(method) || !rule.matches(path, fold) {
+			continue
+		}
+
+		if match == nil || rule.specificity() > match.specificity() {
+			match = rule
+		}
+	}
+
+	return match
+}
+
+// CheckGlob checks the glob pattern of a rule: its segments have the
+// syntax of path.Match, and a segment that is only ** matches any
+// number of segments.
+func CheckGlob(pattern string) error {
+	for _, segment := range segments(pattern) {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// segments splits p in its segments, which are none for the root.
+func segments(p string) []string {
+	if p = strings.Trim(p, "/"); p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+// globPrefix returns the segments of the glob pattern before the first
+// one with a wildcard.
+func globPrefix(pattern string) string {
+	prefix := ""
+	for _, segment := range segments(pattern) {
+		if strings.ContainsAny(segment, `*?[\`) {
+			break
+		}
+		prefix += "/" + segment
+	}
+
+	return prefix
+}
+
+// matchGlob matches the names of a path against the segments of a glob
+// pattern. A ** segment matches any number of names, none included, so
+// "/private/**" matches "/private" and everything under it.
+func matchGlob(pattern, names []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchGlob(pattern[1:], names[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(names) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], names[0]); !ok {
+			return false
+		}
+
+		pattern, names = pattern[1:], names[1:]
+	}
+
+	return len(names) == 0
+}
+
+// RuleError describes why a rule is invalid.
+type RuleError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("invalid rule %q: %s", e.Rule, e.Reason)
+}
+
+// Check checks the expressions of the regex rules and the patterns of the
+// glob rules, so the invalid ones are reported when they are set rather
+// than when the files are requested.
+func Check(rules []Rule) error {
+	for _, rule := range rules {
+		switch {
+		case rule.Regex && rule.Regexp == nil:
+			return &RuleError{Reason: "a regex rule has no expression"}
+		case rule.Regex:
+			if err := rule.Regexp.Compile(); err != nil {
+				return &RuleError{Rule: rule.Regexp.Raw, Reason: err.Error()}
+			}
+		case rule.Glob:
+			if err := CheckGlob(rule.Path); err != nil {
+				return &RuleError{Rule: rule.Path, Reason: err.Error()}
+			}
+		}
+	}
+
+	return nil
 }
 
 // Regexp is a wrapper to the native regexp type where we
-// save the raw expression.
+// save the raw expression. It is compiled once, when it's checked or
+// first matched.
 type Regexp struct {
 	Raw    string `json:"raw"`
+	once   sync.Once
 	regexp *regexp.Regexp
+	folded *regexp.Regexp
+	prefix string
+	err    error
+}
+
+// Compile compiles the expression, and the one that matches regardless
+// of case, and returns the error of the expression if it's invalid.
+func (r *Regexp) Compile() error {
+	r.once.Do(func() {
+		if r.regexp, r.err = regexp.Compile(r.Raw); r.err != nil {
+			return
+		}
+
+		if r.folded, r.err = regexp.Compile("(?i)" + r.Raw); r.err != nil {
+			return
+		}
+
+		r.prefix = anchoredPrefix(r.Raw)
+	})
+
+	return r.err
+}
+
+// anchoredPrefix returns the literal text that the matches of the
+// expression start with if it's anchored with ^, and "" otherwise.
+func anchoredPrefix(raw string) string {
+	re, err := syntax.Parse(raw, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || re.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	prefix := ""
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral {
+			break
+		}
+		prefix += string(sub.Rune)
+	}
+
+	return prefix
 }
 
-// MatchString checks if a string matches the regexp.
+// MatchString checks if a string matches the regexp. The invalid
+// expressions match nothing.
 func (r *Regexp) MatchString(s string) bool {
-	if r.regexp == nil {
-		r.regexp = regexp.MustCompile(r.Raw)
+	if r.Compile() != nil {
+		return false
 	}
 
 	return r.regexp.MatchString(s)
 }
+
+// MatchStringFold checks if a string matches the regexp regardless of
+// case. The invalid expressions match nothing.
+func (r *Regexp) MatchStringFold(s string) bool {
+	if r.Compile() != nil {
+		return false
+	}
+
+	return r.folded.MatchString(s)
+}
@@ -0,0 +1,248 @@
+package rules
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestMatchFold(t *testing.T) {
+	rules := []Rule{
+		{Path: "/private"},
+		{Path: "/private/shared", Allow: true},
+		{Path: "/**/*.KEY", Glob: true},
+		{Path: "/" + norm.NFD.String("café")},
+	}
+
+	tests := []struct {
+		path string
+		want string // the path of the rule, or "" for none
+		fold string
+	}{
+		{"/private", "/private", "/private"},
+		{"/private/x", "/private", "/private"},
+		{"/PRIVATE/x", "", "/private"},
+		{"/Private/Shared/x", "", "/private/shared"},
+		{"/privateer", "", ""},
+		{"/a/b.key", "", "/**/*.KEY"},
+		{"/a/b.KEY", "/**/*.KEY", "/**/*.KEY"},
+		{"/" + norm.NFC.String("café"), "/" + norm.NFD.String("café"), "/" + norm.NFD.String("café")},
+		{"/" + norm.NFC.String("CAFÉ") + "/x", "", "/" + norm.NFD.String("café")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := rulePath(Match(rules, tt.path)); got != tt.want {
+				t.Errorf("Match() = %q, want %q", got, tt.want)
+			}
+
+			if got := rulePath(MatchFold(rules, tt.path)); got != tt.fold {
+				t.Errorf("MatchFold() = %q, want %q", got, tt.fold)
+			}
+		})
+	}
+}
+
+func TestMatchSpecificity(t *testing.T) {
+	rules := []Rule{
+		{Path: "/docs", Allow: true},
+		{Regex: true, Regexp: &Regexp{Raw: `\.key$`}},
+		{Regex: true, Regexp: &Regexp{Raw: `^/docs/private/`}},
+		{Path: "/docs/private/shared", Allow: true},
+		{Glob: true, Path: "/docs/*/drafts/**"},
+		{Path: "/docs/public", Allow: true},
+		{Regex: true, Allow: true, Regexp: &Regexp{Raw: `^/docs/public(/|$)`}},
+		{Path: "/logs"},
+		{Glob: true, Path: "/logs/**", Allow: true},
+	}
+
+	tests := []struct {
+		path string
+		want int // the index of the rule, or -1 for none
+	}{
+		{"/a.key", 1},                     // only the regex that isn't anchored
+		{"/docs/a.key", 0},                // the path is more specific than anywhere
+		{"/docs/private/a.txt", 2},        // the anchored prefix is longer than /docs
+		{"/docs/private/shared/a.txt", 3}, // the path is longer than the prefix
+		{"/docs/x/drafts/a.txt", 0},       // "/docs/*/drafts/**" only spells out /docs, declared after it
+		{"/docs/public/a.txt", 5},         // "/docs/public" ties, declared first
+		{"/logs/a.txt", 7},                // "/logs/**" ties with "/logs", declared first
+		{"/other", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := Match(rules, tt.path)
+			if tt.want == -1 && got != nil || tt.want >= 0 && got != &rules[tt.want] {
+				t.Errorf("Match() = %+v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/private/**", "/private", true},
+		{"/private/**", "/private/a.txt", true},
+		{"/private/**", "/private/a/b/c.txt", true},
+		{"/private/**", "/privates", false},
+		{"/**/*.key", "/a.key", true},
+		{"/**/*.key", "/a/b/c.key", true},
+		{"/**/*.key", "/a/b/c.key/d", false},
+		{"/docs/*/drafts/**", "/docs/x/drafts", true},
+		{"/docs/*/drafts/**", "/docs/x/y/drafts", false},
+		{"/docs/*.txt", "/docs/a.txt", true},
+		{"/docs/*.txt", "/docs/a/b.txt", false},
+		{"/**/tmp/**", "/a/tmp/b/c", true},
+		{"/**/tmp/**", "/tmp", true},
+		{"/**/tmp/**", "/a/tmpx", false},
+		{"/a?c", "/abc", true},
+		{"/a?c", "/a/c", false},
+		{"/[ab]*", "/b.txt", true},
+		{"/[ab]*", "/c.txt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			rules := []Rule{{Glob: true, Path: tt.pattern}}
+			if got := Match(rules, tt.path) != nil; got != tt.match {
+				t.Errorf("Match() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+}
+
+func TestMatchGlobOrder(t *testing.T) {
+	// The glob and regex rules that spell out as much of the path tie,
+	// however long their patterns are, and the first declared wins.
+	rules := []Rule{
+		{Glob: true, Path: "/docs/**/*.txt", Allow: true},
+		{Glob: true, Path: "/docs/*/private/**"},
+		{Regex: true, Regexp: &Regexp{Raw: `^/docs.*\.md$`}},
+		{Glob: true, Path: "/**/*.key"},
+		{Regex: true, Allow: true, Regexp: &Regexp{Raw: `\.(key|pem)$`}},
+		{Glob: true, Path: "/docs/private/**"},
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/docs/x/private/a.txt", 0},
+		{"/docs/x/private/a.md", 1},
+		{"/docs/a.md", 2},
+		{"/docs/private/a.md", 5}, // "/docs/private" is longer than "/docs"
+		{"/a.key", 3},
+		{"/a.pem", 4},
+		{"/other.md", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := Match(rules, tt.path)
+			if tt.want == -1 && got != nil || tt.want >= 0 && got != &rules[tt.want] {
+				t.Errorf("Match() = %+v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchMethod(t *testing.T) {
+	rules := []Rule{
+		{Path: "/uploads", Methods: []string{http.MethodDelete}},
+		{Path: "/uploads", Allow: true},
+	}
+
+	tests := []struct {
+		method string
+		allow  bool
+	}{
+		{http.MethodGet, true},
+		{http.MethodPost, true},
+		{http.MethodDelete, false},
+		{"delete", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rule := MatchMethodFold(rules, "/UPLOADS/a.txt", tt.method)
+			if rule == nil || rule.Allow != tt.allow {
+				t.Errorf("MatchMethodFold() = %+v, want allow %v", rule, tt.allow)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"path", Rule{Path: "/private"}, true},
+		{"regex", Rule{Regex: true, Regexp: &Regexp{Raw: `\.(key|pem)$`}}, true},
+		{"invalid regex", Rule{Regex: true, Regexp: &Regexp{Raw: `(unclosed`}}, false},
+		{"regex without expression", Rule{Regex: true}, false},
+		{"glob", Rule{Glob: true, Path: "/**/*.key"}, true},
+		{"invalid glob", Rule{Glob: true, Path: "/[a"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check([]Rule{tt.rule})
+			if (err == nil) != tt.valid {
+				t.Fatalf("Check() = %v, want valid %v", err, tt.valid)
+			}
+
+			if _, ok := err.(*RuleError); err != nil && !ok {
+				t.Errorf("Check() = %T, want *RuleError", err)
+			}
+		})
+	}
+}
+
+func TestInvalidRegexDenies(t *testing.T) {
+	// The rules saved before they were checked can be invalid: they must
+	// neither panic nor allow anything.
+	rules := []Rule{
+		{Regex: true, Regexp: &Regexp{Raw: `(deny`}},
+		{Regex: true, Allow: true, Regexp: &Regexp{Raw: `(allow`}},
+	}
+
+	for _, match := range []func([]Rule, string) *Rule{Match, MatchFold} {
+		rule := match(rules, "/any/file")
+		if rule == nil || rule.Allow {
+			t.Errorf("match() = %+v, want the deny rule", rule)
+		}
+	}
+}
+
+func TestRegexpConcurrent(t *testing.T) {
+	rule := Rule{Regex: true, Regexp: &Regexp{Raw: `^/private/`}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !rule.matches("/PRIVATE/x", true) || rule.matches("/PRIVATE/x", false) {
+				t.Error("the regex rule matched wrongly")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func rulePath(rule *Rule) string {
+	if rule == nil {
+		return ""
+	}
+
+	return rule.Path
+}
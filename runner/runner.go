@@ -1,12 +1,16 @@
 package runner
 
 import (
+	"bytes"
+	"context"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/filebrowser/filebrowser/v2/logging"
 	"github.com/filebrowser/filebrowser/v2/settings"
 	"github.com/filebrowser/filebrowser/v2/users"
 )
@@ -14,19 +18,55 @@ import (
 // Runner is a commands runner.
 type Runner struct {
 	*settings.Settings
+	// Logger logs the commands that are run and the failures of the
+	// after hooks and of the commands that don't block.
+	Logger logging.Logger
 }
 
-// RunHook runs the hooks for the before and after event.
-func (r *Runner) RunHook(fn func() error, evt, path, dst string, user *users.User) error {
-	path = user.FullPath(path)
-	dst = user.FullPath(dst)
+// maxRunning is the number of hooks that can run at once. The others
+// wait for one of them to finish.
+const maxRunning = 8
 
-	if val, ok := r.Commands["before_"+evt]; ok {
-		for _, command := range val {
-			err := r.exec(command, "before_"+evt, path, dst, user)
-			if err != nil {
-				return err
-			}
+var running = make(chan struct{}, maxRunning)
+
+// Running returns the number of hooks running and the number that can run
+// at once.
+func Running() (int, int) {
+	return len(running), cap(running)
+}
+
+// HookError is the failure of a before hook, which vetoes the operation.
+type HookError struct {
+	Event string
+	// Reason is the first line the command wrote to its standard error,
+	// or how it failed if it wrote none.
+	Reason string
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("the %s hook failed: %s", e.Event, e.Reason)
+}
+
+// RunHook runs the hooks for the before and after event. A failing
+// before hook stops the operation with a HookError, while the failures
+// of the after hooks are only logged. The details of the operation are
+// passed to the commands in environment variables, never in their
+// arguments. size is the size of the file of the operation if it is
+// known beforehand, such as the size of an upload, or -1.
+func (r *Runner) RunHook(fn func() error, evt, path, dst string, size int64, user *users.User) error {
+	hook := hookEnv{
+		path:    path,
+		dst:     dst,
+		full:    user.FullPath(path),
+		fullDst: user.FullPath(dst),
+		size:    size,
+		user:    user,
+		scope:   user.FullPath("/"),
+	}
+
+	for _, command := range r.Commands["before_"+evt] {
+		if err := r.exec(command, "before_"+evt, hook); err != nil {
+			return err
 		}
 	}
 
@@ -35,19 +75,76 @@ func (r *Runner) RunHook(fn func() error, evt, path, dst string, user *users.Use
 		return err
 	}
 
-	if val, ok := r.Commands["after_"+evt]; ok {
-		for _, command := range val {
-			err := r.exec(command, "after_"+evt, path, dst, user)
-			if err != nil {
-				return err
-			}
+	// The after hooks get the size of the file as it ended up.
+	hook.size = -1
+	for _, command := range r.Commands["after_"+evt] {
+		if err := r.exec(command, "after_"+evt, hook); err != nil {
+			r.logger().Error("the hook failed", "event", "after_"+evt, "command", command, "path", path, "error", err)
 		}
 	}
 
 	return nil
 }
 
-func (r *Runner) exec(raw, evt, path, dst string, user *users.User) error {
+// hookEnv are the details of an operation given to its hooks.
+type hookEnv struct {
+	path, dst     string
+	full, fullDst string
+	size          int64
+	user          *users.User
+	scope         string
+}
+
+func (h hookEnv) environ(evt string) []string {
+	size := ""
+	if h.size >= 0 {
+		size = strconv.FormatInt(h.size, 10)
+	} else {
+		// The file of a rename or a copy is at its destination once it
+		// is done.
+		name := h.full
+		if h.dst != "" && strings.HasPrefix(evt, "after_") {
+			name = h.fullDst
+		}
+
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			size = strconv.FormatInt(info.Size(), 10)
+		}
+	}
+
+	return append(os.Environ(),
+		"FM_EVENT="+evt,
+		"FM_PATH="+h.path,
+		"FM_DESTINATION="+h.dst,
+		"FM_SCOPE="+h.scope,
+		"FM_USER="+h.user.Username,
+		"FM_SIZE="+size,
+		// The variables of the first versions of the hooks.
+		"FILE="+h.full,
+		"SCOPE="+h.user.Scope,
+		"TRIGGER="+evt,
+		"USERNAME="+h.user.Username,
+		"DESTINATION="+h.fullDst,
+	)
+}
+
+func (r *Runner) logger() logging.Logger {
+	if r.Logger == nil {
+		return logging.Nop
+	}
+
+	return r.Logger
+}
+
+func (r *Runner) timeout() time.Duration {
+	if r.HookTimeout > 0 {
+		return time.Duration(r.HookTimeout) * time.Second
+	}
+
+	return settings.DefaultHookTimeout * time.Second
+}
+
+func (r *Runner) exec(raw, evt string, hook hookEnv) error {
 	blocking := true
 
 	if strings.HasSuffix(raw, "&") {
@@ -60,22 +157,87 @@ func (r *Runner) exec(raw, evt, path, dst string, user *users.User) error {
 		return err
 	}
 
-	cmd := exec.Command(command[0], command[1:]...)
-	cmd.Env = append(os.Environ(), fmt.Sprintf("FILE=%s", path))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("SCOPE=%s", user.Scope))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("TRIGGER=%s", evt))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("USERNAME=%s", user.Username))
-	cmd.Env = append(cmd.Env, fmt.Sprintf("DESTINATION=%s", dst))
+	timeout := r.timeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 
+	stderr := &firstLine{}
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = hook.environ(evt)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = stderr
+
+	running <- struct{}{}
+	if err := cmd.Start(); err != nil {
+		<-running
+		cancel()
+		return err
+	}
+
+	wait := func() error {
+		defer cancel()
+		defer func() { <-running }()
+
+		err := cmd.Wait()
+		if ctx.Err() == context.DeadlineExceeded {
+			return &HookError{Event: evt, Reason: fmt.Sprintf("timed out after %s", timeout)}
+		} else if _, ok := err.(*exec.ExitError); ok {
+			reason := stderr.String()
+			if reason == "" {
+				reason = err.Error()
+			}
+			return &HookError{Event: evt, Reason: reason}
+		}
+
+		return err
+	}
 
 	if !blocking {
-		log.Printf("[INFO] Nonblocking Command: \"%s\"", strings.Join(command, " "))
-		return cmd.Start()
+		r.logger().Info("nonblocking command", "event", evt, "command", strings.Join(command, " "))
+		go func() {
+			if err := wait(); err != nil {
+				r.logger().Error("the command failed", "event", evt, "command", strings.Join(command, " "), "error", err)
+			}
+		}()
+		return nil
+	}
+
+	r.logger().Info("blocking command", "event", evt, "command", strings.Join(command, " "))
+	return wait()
+}
+
+// maxReason is the length the reasons of the hooks are cut to.
+const maxReason = 256
+
+// firstLine copies what is written to it to the standard error and keeps
+// the first line that isn't blank.
+type firstLine struct {
+	buf  bytes.Buffer
+	done bool
+}
+
+func (w *firstLine) Write(p []byte) (int, error) {
+	_, _ = os.Stderr.Write(p)
+
+	for _, c := range p {
+		if w.done {
+			break
+		}
+
+		switch {
+		case c == '\n':
+			w.done = strings.TrimSpace(w.buf.String()) != ""
+			if !w.done {
+				w.buf.Reset()
+			}
+		case w.buf.Len() < maxReason:
+			w.buf.WriteByte(c)
+		}
 	}
 
-	log.Printf("[INFO] Blocking Command: \"%s\"", strings.Join(command, " "))
-	return cmd.Run()
+	return len(p), nil
+}
+
+func (w *firstLine) String() string {
+	return strings.TrimSpace(w.buf.String())
 }
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
@@ -75,6 +77,8 @@ func parseSearch(value string) *searchOptions {
 		value = typeRegexp.ReplaceAllString(value, "")
 	}
 
+	value = norm.NFC.String(value)
+
 	// If it's canse insensitive, put everything in lowercase.
 	if !opts.CaseSensitive {
 		value = strings.ToLower(value)
@@ -1,11 +1,14 @@
 package search
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/filebrowser/filebrowser/v2/rules"
 	"github.com/spf13/afero"
+	"golang.org/x/text/unicode/norm"
 )
 
 type searchOptions struct {
@@ -14,9 +17,36 @@ type searchOptions struct {
 	Terms         []string
 }
 
+// Skipper is implemented by the checkers that leave some directories
+// out of the searches entirely, so they aren't walked.
+type Skipper interface {
+	Skip(path string) bool
+}
+
 // Search searches for a query in a fs.
 func Search(fs afero.Fs, scope, query string, checker rules.Checker, found func(path string, f os.FileInfo) error) error {
+	return SearchContext(context.Background(), fs, scope, query, checker, found)
+}
+
+// SearchContext is like Search, but stops walking the fs with the error
+// of ctx once it's done.
+func SearchContext(ctx context.Context, fs afero.Fs, scope, query string, checker rules.Checker, found func(path string, f os.FileInfo) error) error {
+	return walk(ctx, fs, scope, query, checker, false, found)
+}
+
+// SearchNames is like SearchContext, but only matches the names of the
+// files rather than their whole paths, so the directories above them
+// don't match.
+func SearchNames(ctx context.Context, fs afero.Fs, scope, query string, checker rules.Checker, found func(path string, f os.FileInfo) error) error {
+	return walk(ctx, fs, scope, query, checker, true, found)
+}
+
+// walk searches for query in scope, matching the whole paths or only
+// the names of the files. The files that can't be read, and the
+// directories that can't be listed, are left out of the results.
+func walk(ctx context.Context, fs afero.Fs, scope, query string, checker rules.Checker, names bool, found func(path string, f os.FileInfo) error) error {
 	search := parseSearch(query)
+	skipper, _ := checker.(Skipper)
 
 	scope = strings.Replace(scope, "\\", "/", -1)
 	scope = strings.TrimPrefix(scope, "/")
@@ -29,14 +59,28 @@ func Search(fs afero.Fs, scope, query string, checker rules.Checker, found func(
 		originalPath = "/" + originalPath
 		path := originalPath
 
-		if path == scope {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if path == scope || err != nil {
 			return nil
 		}
 
+		if skipper != nil && f != nil && f.IsDir() && skipper.Skip(path) {
+			return filepath.SkipDir
+		}
+
 		if !checker.Check(path) {
 			return nil
 		}
 
+		// Names are compared in NFC so the ones written in NFD, such
+		// as the ones from macOS, are found too.
+		if names {
+			path = f.Name()
+		}
+		path = norm.NFC.String(path)
 		if !search.CaseSensitive {
 			path = strings.ToLower(path)
 		}
@@ -0,0 +1,39 @@
+package settings
+
+// DefaultArchiveTTL is how long, in minutes, the archives built in the
+// background are kept when the settings don't say.
+const DefaultArchiveTTL = 24 * 60
+
+// Archives contains the limits of the archives built in the background,
+// in the archive spool of the server settings.
+type Archives struct {
+	// Jobs is the number of archives built at once. The others wait for
+	// their turn. Zero is one.
+	Jobs int `json:"jobs"`
+	// PerUser is the number of archives a user can have waiting or being
+	// built. Zero is no limit.
+	PerUser int `json:"perUser"`
+	// TTL is how long, in minutes, the finished archives are kept. Zero is
+	// DefaultArchiveTTL.
+	TTL int `json:"ttl"`
+	// MinFree is the space, in bytes, kept free on the disk of the spool.
+	// The archives whose files don't fit above it aren't built.
+	MinFree int64 `json:"minFree"`
+}
+
+// RunningJobs returns the number of archives built at once.
+func (a Archives) RunningJobs() int {
+	if a.Jobs < 1 {
+		return 1
+	}
+	return a.Jobs
+}
+
+// Lifetime returns how long, in minutes, the finished archives are kept.
+func (a Archives) Lifetime() int {
+	if a.TTL == 0 {
+		return DefaultArchiveTTL
+	}
+
+	return a.TTL
+}
@@ -1,8 +1,29 @@
 package settings
 
+// Themes of the HTML listings. An empty theme follows the preference of
+// the browser.
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
 // Branding contains the branding settings of the app.
 type Branding struct {
 	Name            string `json:"name"`
 	DisableExternal bool   `json:"disableExternal"`
 	Files           string `json:"files"`
+	Theme           string `json:"theme"`
+	// Title prefixes the titles of the HTML listings and Favicon is their
+	// icon, either one of the embedded ones or the path of a file.
+	Title   string `json:"title"`
+	Favicon string `json:"favicon"`
+	// Styles and Scripts are added to the HTML listings. They are either
+	// URLs or the paths of files inside the branding directory.
+	Styles  []string `json:"styles"`
+	Scripts []string `json:"scripts"`
+	// Listing, when set, is the path of the file of the template of the
+	// HTML listings, which replaces the embedded one. It's executed with
+	// the same page, and parsed again whenever the file changes. The
+	// embedded template is used while the file is missing.
+	Listing string `json:"listing"`
 }
@@ -0,0 +1,35 @@
+package settings
+
+const (
+	// DefaultListingCacheEntries is the number of listings cached when no
+	// limit is set.
+	DefaultListingCacheEntries = 1000
+	// DefaultListingCacheItems is the number of items of all the cached
+	// listings when no limit is set.
+	DefaultListingCacheItems = 200000
+)
+
+// ListingCache contains how long the listings of the directories are
+// cached, in seconds, and how many of them and of their items. The cache
+// is off when TTL is zero.
+type ListingCache struct {
+	TTL        int `json:"ttl"`
+	MaxEntries int `json:"maxEntries"`
+	MaxItems   int `json:"maxItems"`
+}
+
+// Limits returns the limits of the cache, using the defaults for the
+// ones that aren't set.
+func (c ListingCache) Limits() (maxEntries, maxItems int) {
+	maxEntries, maxItems = c.MaxEntries, c.MaxItems
+
+	if maxEntries <= 0 {
+		maxEntries = DefaultListingCacheEntries
+	}
+
+	if maxItems <= 0 {
+		maxItems = DefaultListingCacheItems
+	}
+
+	return maxEntries, maxItems
+}
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/filebrowser/filebrowser/v2/sftpfs"
 	"github.com/spf13/afero"
 )
 
@@ -24,7 +25,7 @@ func (settings *Settings) MakeUserDir(username, userScope, serverRoot string) (s
 		userScope = "."
 	}
 
-	if !settings.CreateUserDir {
+	if !settings.CreateUserDir || sftpfs.IsURL(userScope) {
 		return userScope, nil
 	}
 
@@ -0,0 +1,15 @@
+package settings
+
+// Fetch contains what the server can fetch when the users upload files
+// by their URLs.
+type Fetch struct {
+	// Enabled lets the users who can create files upload them by URL.
+	Enabled bool `json:"enabled"`
+	// MaxSize is the size, in bytes, of the biggest file fetched. Zero is
+	// no limit.
+	MaxSize int64 `json:"maxSize"`
+	// AllowNetworks are the IPs or CIDRs of the private networks the
+	// server fetches from anyway. The loopback, private and link-local
+	// addresses are refused otherwise.
+	AllowNetworks []string `json:"allowNetworks"`
+}
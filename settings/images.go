@@ -0,0 +1,60 @@
+package settings
+
+// The formats the images can be resized to.
+const (
+	ImageFormatJPEG = "jpeg"
+	ImageFormatPNG  = "png"
+)
+
+// DefaultThumbSize is the size of the thumbnails of the new settings.
+const DefaultThumbSize = 200
+
+// MaxImageSize is the biggest width or height the images can be resized
+// to.
+const MaxImageSize = 8192
+
+// Images contains what the images can be resized to. Only the widths and
+// heights in Sizes and the formats in Formats can be asked for, so the
+// clients can't fill the cache with every size. Resizing is off when
+// Sizes is empty. Thumbs are the sizes of the boxes the JPEG thumbnails
+// of the images fit in, the first of which the listings link to. The
+// thumbnails are off when it's empty.
+type Images struct {
+	Sizes   []int    `json:"sizes"`
+	Formats []string `json:"formats"`
+	Thumbs  []int    `json:"thumbs"`
+}
+
+// AllowsSize checks if the images can be resized to a width or a height
+// of size.
+func (i Images) AllowsSize(size int) bool {
+	for _, s := range i.Sizes {
+		if s == size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsThumb checks if the images can have thumbnails of size.
+func (i Images) AllowsThumb(size int) bool {
+	for _, s := range i.Thumbs {
+		if s == size {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsFormat checks if the images can be resized to format.
+func (i Images) AllowsFormat(format string) bool {
+	for _, f := range i.Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
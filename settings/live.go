@@ -0,0 +1,49 @@
+package settings
+
+import "time"
+
+const (
+	// DefaultLiveConnections is the number of live connections when no
+	// limit is set.
+	DefaultLiveConnections = 100
+	// DefaultLiveDelay is how long, in milliseconds, the changes are
+	// gathered when no delay is set.
+	DefaultLiveDelay = 250
+)
+
+// Live contains the limits of the live updates of the listings, whose
+// changes are pushed through WebSockets as they happen.
+type Live struct {
+	// Disabled turns the live updates off.
+	Disabled bool `json:"disabled"`
+	// Connections is the number of connections at once. Zero is
+	// DefaultLiveConnections.
+	Connections int `json:"connections"`
+	// PerUser is the number of connections a user can have at once. Zero
+	// is no limit.
+	PerUser int `json:"perUser"`
+	// Delay is how long, in milliseconds, the changes are gathered before
+	// they're sent, so the storms of changes are sent at once. Zero is
+	// DefaultLiveDelay.
+	Delay int `json:"delay"`
+}
+
+// MaxConnections returns the number of connections at once.
+func (l Live) MaxConnections() int {
+	if l.Connections == 0 {
+		return DefaultLiveConnections
+	}
+
+	return l.Connections
+}
+
+// Batching returns how long the changes are gathered before they're
+// sent.
+func (l Live) Batching() time.Duration {
+	delay := l.Delay
+	if delay == 0 {
+		delay = DefaultLiveDelay
+	}
+
+	return time.Duration(delay) * time.Millisecond
+}
@@ -0,0 +1,136 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/users"
+)
+
+// Option changes the Settings made by New. It returns an error if its
+// input is not valid.
+type Option func(*Settings) error
+
+// New returns the settings of a new installation, with a new key and the
+// defaults of the quick setup, changed by opts in order. The result is
+// validated, so options that conflict make it fail.
+func New(opts ...Option) (*Settings, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	set := &Settings{
+		Key:             key,
+		CaseInsensitive: DefaultCaseInsensitive,
+		Defaults: UserDefaults{
+			Scope:  ".",
+			Locale: "en",
+			Perm: users.Permissions{
+				Execute:  true,
+				Create:   true,
+				Rename:   true,
+				Modify:   true,
+				Delete:   true,
+				Share:    true,
+				Download: true,
+			},
+		},
+		Images:  Images{Thumbs: []int{DefaultThumbSize}},
+		Readmes: DefaultReadmes,
+	}
+
+	for _, opt := range opts {
+		if err := opt(set); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// WithScope sets the default scope of the users. Relative scopes are
+// inside the root.
+func WithScope(scope string) Option {
+	return func(s *Settings) error {
+		if strings.TrimSpace(scope) == "" {
+			return fmt.Errorf("the scope is empty")
+		}
+
+		s.Defaults.Scope = scope
+		return nil
+	}
+}
+
+// WithDefaultSort sets the default sorting of the listings of the users.
+// by is one of name, size and modified.
+func WithDefaultSort(by string, asc bool) Option {
+	return func(s *Settings) error {
+		if err := checkSorting(by); err != nil {
+			return err
+		}
+
+		s.Defaults.Sorting.By = by
+		s.Defaults.Sorting.Asc = asc
+		return nil
+	}
+}
+
+// WithReadOnly only lets the users download and share the files by
+// default.
+func WithReadOnly() Option {
+	return func(s *Settings) error {
+		s.Defaults.Perm = users.Permissions{
+			Share:    true,
+			Download: true,
+		}
+		s.Defaults.Commands = []string{}
+		return nil
+	}
+}
+
+// WithListingIndex sets the mode of the index files of the HTML listings,
+// which is ListingIndexShow, ListingIndexHide, ListingIndexOff or empty.
+func WithListingIndex(mode string) Option {
+	return func(s *Settings) error {
+		if err := checkListingIndex(mode); err != nil {
+			return err
+		}
+
+		s.ListingIndex = mode
+		return nil
+	}
+}
+
+// WithBrandingFiles sets the directory with the branding files, such as
+// the stylesheets and the templates of the error pages.
+func WithBrandingFiles(dir string) Option {
+	return func(s *Settings) error {
+		if err := checkBrandingFiles(dir); err != nil {
+			return err
+		}
+
+		s.Branding.Files = dir
+		return nil
+	}
+}
+
+// WithRules adds rules that allow or disallow paths to all the users.
+// The regular expressions and the globs must compile.
+func WithRules(list ...rules.Rule) Option {
+	return func(s *Settings) error {
+		for _, rule := range list {
+			if err := checkRule(rule); err != nil {
+				return err
+			}
+		}
+
+		s.Rules = append(s.Rules, list...)
+		return nil
+	}
+}
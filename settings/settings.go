@@ -2,6 +2,11 @@ package settings
 
 import (
 	"crypto/rand"
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
 	"strings"
 
 	"github.com/filebrowser/filebrowser/v2/rules"
@@ -10,17 +15,228 @@ import (
 // AuthMethod describes an authentication method.
 type AuthMethod string
 
+// Modes of the index files of the HTML listings. Their content is shown
+// above the listing and, with ListingIndexHide, they are left out of it.
+// An empty mode doesn't show them, and ListingIndexOff doesn't either
+// but, unlike an empty mode, overrides the settings for a user.
+const (
+	ListingIndexShow = "show"
+	ListingIndexHide = "hide"
+	ListingIndexOff  = "off"
+)
+
+// Ways the symbolic links of the scopes are handled. SymlinksFollow, the
+// default, lists them as their targets, SymlinksShow also marks them as
+// links with their targets, and SymlinksHide leaves them out as if they
+// didn't exist.
+const (
+	SymlinksFollow = "follow"
+	SymlinksShow   = "show"
+	SymlinksHide   = "hide"
+)
+
+// DefaultCaseInsensitive is whether the scopes find their files
+// regardless of case by default, which they do on the systems whose
+// filesystems do too, so the rules match them the same way.
+const DefaultCaseInsensitive = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
 // Settings contain the main settings of the application.
 type Settings struct {
-	Key           []byte              `json:"key"`
-	Signup        bool                `json:"signup"`
-	CreateUserDir bool                `json:"createUserDir"`
-	Defaults      UserDefaults        `json:"defaults"`
-	AuthMethod    AuthMethod          `json:"authMethod"`
-	Branding      Branding            `json:"branding"`
-	Commands      map[string][]string `json:"commands"`
-	Shell         []string            `json:"shell"`
-	Rules         []rules.Rule        `json:"rules"`
+	Key             []byte              `json:"key"`
+	Signup          bool                `json:"signup"`
+	CreateUserDir   bool                `json:"createUserDir"`
+	Defaults        UserDefaults        `json:"defaults"`
+	AuthMethod      AuthMethod          `json:"authMethod"`
+	Branding        Branding            `json:"branding"`
+	Tree            Tree                `json:"tree"`
+	Commands        map[string][]string `json:"commands"`
+	Shell           []string            `json:"shell"`
+	Rules           []rules.Rule        `json:"rules"`
+	NormalizeNames  bool                `json:"normalizeNames"`
+	CaseInsensitive bool                `json:"caseInsensitive"`
+	PlainTextCLI    bool                `json:"plainTextCLI"`
+	NoIndex         []string            `json:"noIndex"`
+	TrackChanges    bool                `json:"trackChanges"`
+	DirTemplates    bool                `json:"dirTemplates"`
+	Categories      map[string]string   `json:"categories"`
+	ListingIndex    string              `json:"listingIndex"`
+	// Readmes are the names of the README files, by priority and
+	// regardless of case, whose content is shown above the HTML listings
+	// of their directories. They are off when it's empty.
+	Readmes []string `json:"readmes"`
+	// DateFormat is the Go layout of the dates of the listings and
+	// Timezone is the IANA name of their time zone. The server's local
+	// time zone is used if it is empty.
+	DateFormat string `json:"dateFormat"`
+	Timezone   string `json:"timezone"`
+	// SortLocale is the BCP 47 tag of the language whose collation sorts
+	// the names of the listings, such as de or sv, regardless of case.
+	// They are only compared case-folded if it is empty.
+	SortLocale string `json:"sortLocale"`
+	// DefaultLimit is the number of items listed when the requests don't
+	// set a limit, and MaxLimit is the maximum they can ask for. Zero is
+	// no limit.
+	DefaultLimit int `json:"defaultLimit"`
+	MaxLimit     int `json:"maxLimit"`
+	// Symlinks is how the symbolic links are handled. An empty mode
+	// follows them.
+	Symlinks string `json:"symlinks"`
+	// Webhooks are notified of the operations on the files.
+	Webhooks []Webhook `json:"webhooks"`
+	// HookTimeout is the number of seconds the commands of the hooks can
+	// run before they are killed. Zero is DefaultHookTimeout.
+	HookTimeout int `json:"hookTimeout"`
+	// Slow are the thresholds of the warnings about the slow operations.
+	Slow Slow `json:"slow"`
+	// GitStatus annotates the listings of the directories in git work
+	// trees with the status of their files, and hides their .git.
+	GitStatus bool `json:"gitStatus"`
+	// DocMeta annotates the PDFs and the OOXML documents of the listings
+	// with the titles, authors and numbers of pages read from them.
+	DocMeta bool `json:"docMeta"`
+	// ShowHidden lists the files whose names start with a dot, which are
+	// left out of the listings otherwise.
+	ShowHidden bool `json:"showHidden"`
+	// DirsFirst lists the directories before the files, whatever the
+	// sorting of the listings.
+	DirsFirst bool `json:"dirsFirst"`
+	// DirSizes lists the directories with the sizes of their contents
+	// rather than their own, walking them.
+	DirSizes bool `json:"dirSizes"`
+	// DirOptions is the name of the options files of the directories,
+	// such as .filemanager.json, which set how their listings are sorted,
+	// limited and titled. It's off when empty.
+	DirOptions string `json:"dirOptions"`
+	// TrashDir, when set, is the directory of the scopes, such as /.trash,
+	// where the deleted files are moved to rather than removed. It's left
+	// out of the listing of its parent.
+	TrashDir string `json:"trashDir"`
+	// DirMode is the octal mode of the new directories, such as 0750. It
+	// defaults to DefaultDirMode.
+	DirMode string `json:"dirMode"`
+	// AllowSpecialBits lets the modes the files are changed to have the
+	// setuid, setgid and sticky bits.
+	AllowSpecialBits bool `json:"allowSpecialBits"`
+	// MaxEditSize is the maximum size, in bytes, of the files replaced
+	// with PUT and of the ones read with content=true. It defaults to
+	// DefaultMaxEditSize.
+	MaxEditSize int64 `json:"maxEditSize"`
+	// RequireConditionalWrites makes the files replaced with PUT need an
+	// If-Match or an If-Unmodified-Since, so the edits made at once can't
+	// overwrite each other. The new files can always be created.
+	RequireConditionalWrites bool `json:"requireConditionalWrites"`
+	// MaxChecksumSize is the maximum size, in bytes, of the files whose
+	// checksums the listings get with checksum. It defaults to
+	// DefaultMaxChecksumSize.
+	MaxChecksumSize int64 `json:"maxChecksumSize"`
+	// SearchLimit is the maximum number of results of the searches of the
+	// listings. It defaults to DefaultSearchLimit.
+	SearchLimit int `json:"searchLimit"`
+	// FeedLimit is the maximum number of entries of the RSS feeds of the
+	// listings. It defaults to DefaultFeedLimit.
+	FeedLimit int `json:"feedLimit"`
+	// MimeTypes are custom MIME types by file name or extension, such as
+	// "README": "text/markdown" or ".log": "text/plain", which take
+	// precedence over the ones of the extensions and the sniffed ones.
+	MimeTypes map[string]string `json:"mimeTypes"`
+	// Images are the sizes and the formats the images can be resized to.
+	Images Images `json:"images"`
+	// Uploads are the sizes and the extensions of the files uploaded.
+	Uploads Uploads `json:"uploads"`
+	// Fetch is what the server fetches for the uploads by URL.
+	Fetch Fetch `json:"fetch"`
+	// Archives are the limits of the archives built in the background.
+	Archives Archives `json:"archives"`
+	// ListingCache is how long and how many listings are cached.
+	ListingCache ListingCache `json:"listingCache"`
+	// Live are the limits of the live updates of the listings.
+	Live Live `json:"live"`
+}
+
+// DefaultReadmes are the names of the README files of the new settings.
+var DefaultReadmes = []string{"README.md", "README.txt"}
+
+// DefaultHookTimeout is the number of seconds the commands of the hooks
+// can run when the settings don't say.
+const DefaultHookTimeout = 30
+
+// DefaultDirMode is the mode of the new directories when the settings
+// don't have one.
+const DefaultDirMode os.FileMode = 0755
+
+// NewDirMode returns the mode of the new directories.
+func (s *Settings) NewDirMode() os.FileMode {
+	mode, err := strconv.ParseUint(s.DirMode, 8, 32)
+	if s.DirMode == "" || err != nil {
+		return DefaultDirMode
+	}
+
+	return os.FileMode(mode) & os.ModePerm
+}
+
+// TrashPath returns the path of the trash directory in the scopes, or an
+// empty string if the deleted files are removed.
+func (s *Settings) TrashPath() string {
+	if s.TrashDir == "" {
+		return ""
+	}
+
+	return path.Clean("/" + s.TrashDir)
+}
+
+// DefaultMaxEditSize is the maximum size of the edited files when the
+// settings don't have one.
+const DefaultMaxEditSize = 10 << 20
+
+// EditLimit returns the maximum size of the edited files.
+func (s *Settings) EditLimit() int64 {
+	if s.MaxEditSize <= 0 {
+		return DefaultMaxEditSize
+	}
+
+	return s.MaxEditSize
+}
+
+// DefaultMaxChecksumSize is the maximum size of the files checksummed in
+// the listings when the settings don't have one.
+const DefaultMaxChecksumSize = 64 << 20
+
+// ChecksumLimit returns the maximum size of the files checksummed in the
+// listings.
+func (s *Settings) ChecksumLimit() int64 {
+	if s.MaxChecksumSize <= 0 {
+		return DefaultMaxChecksumSize
+	}
+
+	return s.MaxChecksumSize
+}
+
+// DefaultSearchLimit is the maximum number of results of the searches of
+// the listings when the settings don't have one.
+const DefaultSearchLimit = 500
+
+// ListingSearchLimit returns the maximum number of results of the
+// searches of the listings.
+func (s *Settings) ListingSearchLimit() int {
+	if s.SearchLimit <= 0 {
+		return DefaultSearchLimit
+	}
+
+	return s.SearchLimit
+}
+
+// DefaultFeedLimit is the maximum number of entries of the RSS feeds of
+// the listings when the settings don't have one.
+const DefaultFeedLimit = 50
+
+// ListingFeedLimit returns the maximum number of entries of the RSS feeds
+// of the listings.
+func (s *Settings) ListingFeedLimit() int {
+	if s.FeedLimit <= 0 {
+		return DefaultFeedLimit
+	}
+
+	return s.FeedLimit
 }
 
 // GetRules implements rules.Provider.
@@ -30,19 +246,82 @@ func (s *Settings) GetRules() []rules.Rule {
 
 // Server specific settings.
 type Server struct {
-	Root    string `json:"root"`
-	BaseURL string `json:"baseURL"`
-	Socket  string `json:"socket"`
-	TLSKey  string `json:"tlsKey"`
-	TLSCert string `json:"tlsCert"`
-	Port    string `json:"port"`
-	Address string `json:"address"`
-	Log     string `json:"log"`
+	Root           string   `json:"root"`
+	BaseURL        string   `json:"baseURL"`
+	Socket         string   `json:"socket"`
+	TLSKey         string   `json:"tlsKey"`
+	TLSCert        string   `json:"tlsCert"`
+	Port           string   `json:"port"`
+	Address        string   `json:"address"`
+	Log            string   `json:"log"`
+	RedirectStatus int      `json:"redirectStatus"`
+	TrustedProxies []string `json:"trustedProxies"`
+	// LandingPath, when set, is the path, under the base URL, of the page
+	// that lists the roots the user can browse.
+	LandingPath string `json:"landingPath"`
+	// StaticPath is the path, under the base URL, of the static files.
+	// It defaults to DefaultStaticPath.
+	StaticPath string `json:"staticPath"`
+	// MetricsPath, when set, is the path, under the base URL, of the
+	// metrics in the Prometheus text format. They are only served to the
+	// clients in MetricsAllow, which are IPs or CIDRs, or to the ones on
+	// the loopback interface if it is empty.
+	MetricsPath  string   `json:"metricsPath"`
+	MetricsAllow []string `json:"metricsAllow"`
+	// StatusPath, when set, is the path, under the base URL, of the
+	// status of the handler in JSON: its uptime, caches and queues. It is
+	// served to the same clients as the metrics.
+	StatusPath string `json:"statusPath"`
+	// AccessLog, when set, is where a line of JSON is written for every
+	// request: stdout, stderr or a file. It only changes on restart.
+	AccessLog string `json:"accessLog"`
+	// ImageCache, when set, is the directory where the resized images are
+	// kept, so they are only resized again when the images change.
+	ImageCache string `json:"imageCache"`
+	// ArchiveSpool, when set, is the directory where the archives are
+	// built in the background, along with the state of their jobs. The
+	// archives are only streamed if it is empty.
+	ArchiveSpool string `json:"archiveSpool"`
+	// UploadSpool, when set, is the directory where the chunked uploads
+	// are kept until they're complete. The uploads can only be sent at
+	// once if it is empty.
+	UploadSpool string `json:"uploadSpool"`
+	// ReadOnly, when set, refuses every request that would change the
+	// files of the scopes, whatever the permissions of the users.
+	ReadOnly bool `json:"readOnly"`
+	// AliasRoots are the directories, along with the root, the targets of
+	// the aliases set through the API can be in.
+	AliasRoots []string `json:"aliasRoots"`
+	// CORSOrigins are the origins of the other sites whose pages can use
+	// the API from the browsers, such as https://app.example.com, or *
+	// for any of them.
+	CORSOrigins []string `json:"corsOrigins"`
+}
+
+// AliasTargets returns the directories the targets of the aliases set
+// through the API can be in: the root and AliasRoots.
+func (s *Server) AliasTargets() []string {
+	return append([]string{s.Root}, s.AliasRoots...)
 }
 
+// DefaultStaticPath is the path of the static files when the server
+// settings don't have one.
+const DefaultStaticPath = "/static"
+
 // Clean cleans any variables that might need cleaning.
 func (s *Server) Clean() {
 	s.BaseURL = strings.TrimSuffix(s.BaseURL, "/")
+
+	s.StaticPath = strings.TrimSuffix(s.StaticPath, "/")
+	if s.StaticPath == "" {
+		s.StaticPath = DefaultStaticPath
+	}
+
+	switch s.RedirectStatus {
+	case http.StatusMovedPermanently, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		s.RedirectStatus = http.StatusMovedPermanently
+	}
 }
 
 // GenerateKey generates a key of 256 bits.
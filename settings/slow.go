@@ -0,0 +1,14 @@
+package settings
+
+// Slow contains the thresholds, in milliseconds, over which the
+// operations are logged as slow. Zero disables the warnings of an
+// operation.
+type Slow struct {
+	// Listing is the time taken to read, enrich and sort a directory.
+	Listing int `json:"listing"`
+	// Render is the time taken to write a listing in its format.
+	Render int `json:"render"`
+	// Write is the time taken by the requests that change the files, such
+	// as the uploads, the renames and the deletions.
+	Write int `json:"write"`
+}
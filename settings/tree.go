@@ -0,0 +1,32 @@
+package settings
+
+const (
+	// DefaultTreeMaxDepth is the depth of the directory trees when no
+	// limit is set.
+	DefaultTreeMaxDepth = 5
+	// DefaultTreeMaxNodes is the number of nodes of the directory trees
+	// when no limit is set.
+	DefaultTreeMaxNodes = 1000
+)
+
+// Tree contains the limits of the directory trees.
+type Tree struct {
+	MaxDepth int `json:"maxDepth"`
+	MaxNodes int `json:"maxNodes"`
+}
+
+// Limits returns the limits of the trees, using the defaults for the
+// ones that aren't set.
+func (t Tree) Limits() (maxDepth, maxNodes int) {
+	maxDepth, maxNodes = t.MaxDepth, t.MaxNodes
+
+	if maxDepth <= 0 {
+		maxDepth = DefaultTreeMaxDepth
+	}
+
+	if maxNodes <= 0 {
+		maxNodes = DefaultTreeMaxNodes
+	}
+
+	return maxDepth, maxNodes
+}
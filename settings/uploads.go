@@ -0,0 +1,62 @@
+package settings
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultChunkTTL is how long, in minutes, the incomplete chunked uploads
+// are kept when no TTL is set.
+const DefaultChunkTTL = 24 * 60
+
+// Uploads contains what the users can upload, through the forms, the
+// bodies of the requests and the edits.
+type Uploads struct {
+	// MaxSize is the size, in bytes, of the biggest file uploaded. Zero is
+	// no limit.
+	MaxSize int64 `json:"maxSize"`
+	// AllowedExtensions are the extensions, such as .jpg or .tar.gz, of
+	// the only files that can be uploaded. When there are some, the
+	// blocked ones are ignored.
+	AllowedExtensions []string `json:"allowedExtensions"`
+	// BlockedExtensions are the extensions of the files that can't be
+	// uploaded.
+	BlockedExtensions []string `json:"blockedExtensions"`
+	// ChunkTTL is how long, in minutes, the chunked uploads are kept
+	// after their last chunk until they're complete. Zero is
+	// DefaultChunkTTL.
+	ChunkTTL int `json:"chunkTTL"`
+}
+
+// ChunkLifetime returns how long the incomplete chunked uploads are kept
+// after their last chunk.
+func (u Uploads) ChunkLifetime() time.Duration {
+	ttl := u.ChunkTTL
+	if ttl == 0 {
+		ttl = DefaultChunkTTL
+	}
+
+	return time.Duration(ttl) * time.Minute
+}
+
+// AllowsName checks if a file named name can be uploaded. The extensions
+// are compared with the end of the name whatever their case, without the
+// trailing dots and spaces Windows drops from the names.
+func (u Uploads) AllowsName(name string) bool {
+	name = strings.ToLower(strings.TrimRight(name, ". "))
+	matches := func(extensions []string) bool {
+		for _, ext := range extensions {
+			if strings.HasSuffix(name, strings.ToLower(ext)) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(u.AllowedExtensions) > 0 {
+		return matches(u.AllowedExtensions)
+	}
+
+	return !matches(u.BlockedExtensions)
+}
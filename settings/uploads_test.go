@@ -0,0 +1,38 @@
+package settings
+
+import "testing"
+
+func TestUploadsAllowsName(t *testing.T) {
+	blocked := Uploads{BlockedExtensions: []string{".exe", ".tar.gz"}}
+	allowed := Uploads{AllowedExtensions: []string{".jpg"}, BlockedExtensions: []string{".jpg"}}
+
+	tests := []struct {
+		name    string
+		uploads Uploads
+		file    string
+		want    bool
+	}{
+		{"no extensions", Uploads{}, "a.exe", true},
+		{"blocked", blocked, "a.exe", false},
+		{"blocked upper case", blocked, "a.EXE", false},
+		{"blocked trailing dot", blocked, "a.exe.", false},
+		{"blocked trailing space", blocked, "a.exe ", false},
+		{"blocked double extension", blocked, "b.TAR.gz", false},
+		{"not blocked", blocked, "a.txt", true},
+		{"blocked in the middle", blocked, "a.exe.txt", true},
+		{"blocked plain gz", blocked, "a.gz", true},
+		{"allowed", allowed, "a.jpg", true},
+		{"allowed upper case", allowed, "a.JPG", true},
+		{"allowed over blocked", allowed, "b.jpg", true},
+		{"not allowed", allowed, "a.png", false},
+		{"not allowed without extension", allowed, "jpg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.uploads.AllowsName(tt.file); got != tt.want {
+				t.Errorf("AllowsName(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
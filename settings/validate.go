@@ -0,0 +1,421 @@
+package settings
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/rules"
+)
+
+// ValidationError lists all the problems of invalid settings, so they
+// can be fixed at once.
+type ValidationError []error
+
+func (e ValidationError) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, "invalid settings:")
+	for _, err := range e {
+		lines = append(lines, "  - "+err.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// errorList returns the problems as a ValidationError, or nil if there
+// are none.
+func errorList(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return ValidationError(problems)
+}
+
+// Validate checks the settings and returns a ValidationError with all
+// their problems, if any.
+func (s *Settings) Validate() error {
+	var problems []error
+	add := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if len(s.Key) == 0 {
+		add(fmt.Errorf("the key is empty"))
+	}
+
+	if s.Defaults.Sorting.By != "" {
+		add(checkSorting(s.Defaults.Sorting.By))
+	}
+
+	add(checkListingIndex(s.ListingIndex))
+
+	for _, name := range s.Readmes {
+		if name == "" || strings.Contains(name, "/") {
+			add(fmt.Errorf("invalid README name %q: it must be the name of a file, such as README.md", name))
+		}
+	}
+
+	if s.DirOptions != "" && (strings.Contains(s.DirOptions, "/") || s.DirOptions == "." || s.DirOptions == "..") {
+		add(fmt.Errorf("invalid name of the options files %q: it must be the name of a file", s.DirOptions))
+	}
+
+	if s.TrashDir != "" && path.Clean("/"+s.TrashDir) == "/" {
+		add(fmt.Errorf("invalid trash directory %q: it can't be the root of the scopes", s.TrashDir))
+	}
+
+	switch s.Symlinks {
+	case "", SymlinksFollow, SymlinksShow, SymlinksHide:
+	default:
+		add(fmt.Errorf("invalid symlinks mode %q: it must be %s, %s, %s or empty", s.Symlinks, SymlinksFollow, SymlinksShow, SymlinksHide))
+	}
+
+	if s.DefaultLimit < 0 || s.MaxLimit < 0 {
+		add(fmt.Errorf("the item limits can't be negative"))
+	}
+
+	if s.DirMode != "" {
+		if mode, err := strconv.ParseUint(s.DirMode, 8, 32); err != nil || mode > 0777 {
+			add(fmt.Errorf("invalid mode of the new directories %q: it must be octal, such as 0755", s.DirMode))
+		}
+	}
+
+	if s.MaxEditSize < 0 {
+		add(fmt.Errorf("the maximum size of the edited files can't be negative"))
+	}
+
+	if s.SearchLimit < 0 {
+		add(fmt.Errorf("the maximum number of results of the searches can't be negative"))
+	}
+
+	if s.FeedLimit < 0 {
+		add(fmt.Errorf("the maximum number of entries of the feeds can't be negative"))
+	}
+
+	if s.MaxChecksumSize < 0 {
+		add(fmt.Errorf("the maximum size of the files checksummed in the listings can't be negative"))
+	}
+
+	if s.HookTimeout < 0 {
+		add(fmt.Errorf("the hook timeout can't be negative"))
+	}
+
+	if s.Slow.Listing < 0 || s.Slow.Render < 0 || s.Slow.Write < 0 {
+		add(fmt.Errorf("the thresholds of the slow operations can't be negative"))
+	}
+
+	if s.MaxLimit > 0 && s.DefaultLimit > s.MaxLimit {
+		add(fmt.Errorf("the default item limit %d is over the maximum limit %d", s.DefaultLimit, s.MaxLimit))
+	}
+
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		add(fmt.Errorf("invalid time zone %q: %v", s.Timezone, err))
+	}
+
+	if s.SortLocale != "" {
+		if _, err := language.Parse(s.SortLocale); err != nil {
+			add(fmt.Errorf("invalid sort locale %q: %v", s.SortLocale, err))
+		}
+	}
+
+	switch s.Branding.Theme {
+	case "", ThemeLight, ThemeDark:
+	default:
+		add(fmt.Errorf("invalid theme %q: it must be %s, %s or empty", s.Branding.Theme, ThemeLight, ThemeDark))
+	}
+
+	if s.Branding.Files != "" {
+		add(checkBrandingFiles(s.Branding.Files))
+	}
+
+	for _, rule := range s.Rules {
+		add(checkRule(rule))
+	}
+	add(checkRules(s.Rules))
+
+	for _, hook := range s.Webhooks {
+		add(checkWebhook(hook))
+	}
+
+	for name, t := range s.MimeTypes {
+		if _, _, err := mime.ParseMediaType(t); err != nil {
+			add(fmt.Errorf("invalid MIME type %q of %s: %v", t, name, err))
+		}
+	}
+
+	for _, size := range s.Images.Sizes {
+		if size <= 0 || size > MaxImageSize {
+			add(fmt.Errorf("invalid image size %d: it must be between 1 and %d", size, MaxImageSize))
+		}
+	}
+
+	for _, size := range s.Images.Thumbs {
+		if size <= 0 || size > MaxImageSize {
+			add(fmt.Errorf("invalid thumbnail size %d: it must be between 1 and %d", size, MaxImageSize))
+		}
+	}
+
+	if s.Uploads.MaxSize < 0 || s.Uploads.ChunkTTL < 0 {
+		add(fmt.Errorf("the limits of the uploads can't be negative"))
+	}
+
+	for _, ext := range append(append([]string{}, s.Uploads.AllowedExtensions...), s.Uploads.BlockedExtensions...) {
+		if len(ext) < 2 || ext[0] != '.' || strings.ContainsAny(ext, `/\`) {
+			add(fmt.Errorf("invalid upload extension %q: it must start with a dot, such as .jpg", ext))
+		}
+	}
+
+	if s.Fetch.MaxSize < 0 {
+		add(fmt.Errorf("the maximum size of the fetched files can't be negative"))
+	}
+
+	for _, network := range s.Fetch.AllowNetworks {
+		add(checkIP("fetched network", network))
+	}
+
+	if s.ListingCache.TTL < 0 || s.ListingCache.MaxEntries < 0 || s.ListingCache.MaxItems < 0 {
+		add(fmt.Errorf("the limits of the listing cache can't be negative"))
+	}
+
+	if s.Live.Connections < 0 || s.Live.PerUser < 0 || s.Live.Delay < 0 {
+		add(fmt.Errorf("the limits of the live updates can't be negative"))
+	}
+
+	if s.Archives.Jobs < 0 || s.Archives.PerUser < 0 || s.Archives.TTL < 0 || s.Archives.MinFree < 0 {
+		add(fmt.Errorf("the limits of the archives can't be negative"))
+	}
+
+	for _, format := range s.Images.Formats {
+		switch format {
+		case ImageFormatJPEG, ImageFormatPNG:
+		default:
+			add(fmt.Errorf("invalid image format %q: it must be %s or %s", format, ImageFormatJPEG, ImageFormatPNG))
+		}
+	}
+
+	return errorList(problems)
+}
+
+// Validate checks the server settings and returns a ValidationError with
+// all their problems, if any.
+func (s *Server) Validate() error {
+	var problems []error
+	add := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if info, err := os.Stat(s.Root); err != nil {
+		add(fmt.Errorf("invalid root: %v", err))
+	} else if !info.IsDir() {
+		add(fmt.Errorf("the root %s is not a directory", s.Root))
+	}
+
+	if s.BaseURL != "" && !strings.HasPrefix(s.BaseURL, "/") {
+		add(fmt.Errorf("the base URL %q doesn't start with a slash", s.BaseURL))
+	}
+
+	if strings.ContainsAny(s.BaseURL, "?#") {
+		add(fmt.Errorf("the base URL %q has a query or a fragment", s.BaseURL))
+	}
+
+	if (s.TLSKey == "") != (s.TLSCert == "") {
+		add(fmt.Errorf("TLS needs both a key and a certificate"))
+	}
+
+	staticPath := DefaultStaticPath
+	if s.StaticPath != "" {
+		if err := checkStaticPath(s.StaticPath); err != nil {
+			add(err)
+		} else {
+			staticPath = strings.TrimSuffix(s.StaticPath, "/")
+		}
+	}
+
+	if s.LandingPath != "" {
+		add(checkPagePath("landing", s.LandingPath, staticPath))
+	}
+
+	if s.MetricsPath != "" {
+		add(checkPagePath("metrics", s.MetricsPath, staticPath))
+		if s.MetricsPath == s.LandingPath {
+			add(fmt.Errorf("the metrics path %q is taken by the landing page", s.MetricsPath))
+		}
+	}
+
+	if s.StatusPath != "" {
+		add(checkPagePath("status", s.StatusPath, staticPath))
+		if s.StatusPath == s.LandingPath || s.StatusPath == s.MetricsPath {
+			add(fmt.Errorf("the status path %q is taken by the landing page or the metrics", s.StatusPath))
+		}
+	}
+
+	for _, proxy := range s.TrustedProxies {
+		add(checkIP("trusted proxy", proxy))
+	}
+
+	for _, ip := range s.MetricsAllow {
+		add(checkIP("metrics client", ip))
+	}
+
+	for _, root := range s.AliasRoots {
+		if !filepath.IsAbs(root) {
+			add(fmt.Errorf("the alias root %q is not an absolute path", root))
+		}
+	}
+
+	return errorList(problems)
+}
+
+func checkSorting(by string) error {
+	if !files.IsSortKey(by) {
+		return fmt.Errorf("can't sort by %q: it must be name, size, modified or type", by)
+	}
+
+	return nil
+}
+
+// reservedPaths are the routes of the server the landing page, the
+// metrics, the status and the static files can't take.
+var reservedPaths = []string{"/api", "/dav", "/robots.txt"}
+
+// frontendPaths are the pages of the frontend, which the static files
+// would hide.
+var frontendPaths = []string{"/files", "/share", "/settings", "/login", "/403", "/404", "/500"}
+
+// checkPagePath checks the path p of the landing page or the metrics,
+// which are named by page.
+func checkPagePath(page, p, staticPath string) error {
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "?#") {
+		return fmt.Errorf("the %s path %q must start with a slash and have no query or fragment", page, p)
+	}
+
+	for _, reserved := range append([]string{staticPath}, reservedPaths...) {
+		if p == reserved || strings.HasPrefix(p, reserved+"/") {
+			return fmt.Errorf("the %s path %q is taken by %s", page, p, reserved)
+		}
+	}
+
+	return nil
+}
+
+// checkIP checks that s, which is described by what, is an IP or a CIDR.
+func checkIP(what, s string) error {
+	if _, _, err := net.ParseCIDR(s); err != nil && net.ParseIP(s) == nil {
+		return fmt.Errorf("the %s %q is neither an IP nor a CIDR range", what, s)
+	}
+
+	return nil
+}
+
+func checkStaticPath(raw string) error {
+	p := strings.TrimSuffix(raw, "/")
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "?#") {
+		return fmt.Errorf("the static path %q must start with a slash, not be the root and have no query or fragment", raw)
+	}
+
+	for _, taken := range append(append([]string(nil), reservedPaths...), frontendPaths...) {
+		if p == taken || strings.HasPrefix(p, taken+"/") || strings.HasPrefix(taken, p+"/") {
+			return fmt.Errorf("the static path %q overlaps %s", p, taken)
+		}
+	}
+
+	return nil
+}
+
+func checkListingIndex(mode string) error {
+	switch mode {
+	case "", ListingIndexShow, ListingIndexHide, ListingIndexOff:
+		return nil
+	default:
+		return fmt.Errorf("invalid listing index mode %q: it must be %s, %s, %s or empty", mode, ListingIndexShow, ListingIndexHide, ListingIndexOff)
+	}
+}
+
+func checkBrandingFiles(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("invalid branding directory: %v", err)
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("the branding directory %s is not a directory", dir)
+	}
+
+	return nil
+}
+
+func checkRule(rule rules.Rule) error {
+	for _, method := range rule.Methods {
+		if method == "" || strings.ContainsAny(method, " \t/") {
+			return fmt.Errorf("invalid method %q of a rule", method)
+		}
+	}
+
+	if !rule.Regex {
+		if rule.Path == "" {
+			return fmt.Errorf("a rule has an empty path")
+		}
+
+		if rule.Glob {
+			if err := rules.CheckGlob(rule.Path); err != nil {
+				return fmt.Errorf("invalid rule %q: %v", rule.Path, err)
+			}
+		}
+
+		return nil
+	}
+
+	if rule.Regexp == nil {
+		return fmt.Errorf("a regex rule has no expression")
+	}
+
+	if err := rule.Regexp.Compile(); err != nil {
+		return fmt.Errorf("invalid rule %q: %v", rule.Regexp.Raw, err)
+	}
+
+	return nil
+}
+
+// checkRules checks that no two rules of the same path or expression,
+// and for the same methods, disagree on allowing it.
+func checkRules(list []rules.Rule) error {
+	type target struct {
+		regex   bool
+		glob    bool
+		expr    string
+		methods string
+	}
+
+	seen := map[target]bool{}
+	for _, rule := range list {
+		methods := strings.ToUpper(strings.Join(rule.Methods, ","))
+		t := target{glob: rule.Glob, expr: rule.Path, methods: methods}
+		if rule.Regex {
+			if rule.Regexp == nil {
+				continue
+			}
+			t = target{regex: true, expr: rule.Regexp.Raw, methods: methods}
+		}
+
+		if allow, ok := seen[t]; ok && allow != rule.Allow {
+			return fmt.Errorf("the rules of %q both allow and disallow it", t.expr)
+		}
+
+		seen[t] = rule.Allow
+	}
+
+	return nil
+}
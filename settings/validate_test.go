@@ -0,0 +1,134 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/rules"
+)
+
+func TestSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *Settings)
+		want   string // in the error, or "" if the settings are valid
+	}{
+		{"defaults", func(s *Settings) {}, ""},
+		{"key", func(s *Settings) { s.Key = nil }, "the key is empty"},
+		{"sorting", func(s *Settings) { s.Defaults.Sorting = files.Sorting{By: "color"} }, `can't sort by "color"`},
+		{"listing index", func(s *Settings) { s.ListingIndex = "always" }, `invalid listing index mode "always"`},
+		{"README", func(s *Settings) { s.Readmes = []string{"docs/README.md"} }, `invalid README name "docs/README.md"`},
+		{"options files", func(s *Settings) { s.DirOptions = ".." }, `invalid name of the options files ".."`},
+		{"trash", func(s *Settings) { s.TrashDir = "/" }, `invalid trash directory "/"`},
+		{"symlinks", func(s *Settings) { s.Symlinks = "resolve" }, `invalid symlinks mode "resolve"`},
+		{"negative limit", func(s *Settings) { s.DefaultLimit = -1 }, "the item limits can't be negative"},
+		{"limits", func(s *Settings) { s.DefaultLimit, s.MaxLimit = 100, 10 }, "the default item limit 100 is over the maximum limit 10"},
+		{"directory mode", func(s *Settings) { s.DirMode = "0999" }, `invalid mode of the new directories "0999"`},
+		{"edit size", func(s *Settings) { s.MaxEditSize = -1 }, "the maximum size of the edited files can't be negative"},
+		{"time zone", func(s *Settings) { s.Timezone = "Mars/Olympus" }, `invalid time zone "Mars/Olympus"`},
+		{"sort locale", func(s *Settings) { s.SortLocale = "not a locale" }, `invalid sort locale "not a locale"`},
+		{"theme", func(s *Settings) { s.Branding.Theme = "pink" }, `invalid theme "pink"`},
+		{"branding files", func(s *Settings) { s.Branding.Files = "/does/not/exist" }, "invalid branding directory"},
+		{"empty rule", func(s *Settings) { s.Rules = []rules.Rule{{}} }, "a rule has an empty path"},
+		{"glob rule", func(s *Settings) { s.Rules = []rules.Rule{{Glob: true, Path: "/[a"}} }, `invalid rule "/[a"`},
+		{"regex rule", func(s *Settings) { s.Rules = []rules.Rule{{Regex: true, Regexp: &rules.Regexp{Raw: "(a"}}} }, `invalid rule "(a"`},
+		{"rule method", func(s *Settings) { s.Rules = []rules.Rule{{Path: "/a", Methods: []string{"GET POST"}}} }, `invalid method "GET POST"`},
+		{"conflicting rules", func(s *Settings) {
+			s.Rules = []rules.Rule{{Path: "/a"}, {Path: "/a", Allow: true}}
+		}, `the rules of "/a" both allow and disallow it`},
+		{"rules of other methods", func(s *Settings) {
+			s.Rules = []rules.Rule{{Path: "/a"}, {Path: "/a", Allow: true, Methods: []string{"GET"}}}
+		}, ""},
+		{"webhook", func(s *Settings) { s.Webhooks = []Webhook{{URL: "ftp://example.com"}} }, `the webhook URL "ftp://example.com"`},
+		{"webhook event", func(s *Settings) {
+			s.Webhooks = []Webhook{{URL: "https://example.com", Events: []string{"explode"}}}
+		}, `unknown event "explode"`},
+		{"MIME type", func(s *Settings) { s.MimeTypes = map[string]string{".x": "not/a type"} }, `invalid MIME type "not/a type"`},
+		{"image size", func(s *Settings) { s.Images.Sizes = []int{0} }, "invalid image size 0"},
+		{"thumbnail size", func(s *Settings) { s.Images.Thumbs = []int{MaxImageSize + 1} }, "invalid thumbnail size"},
+		{"image format", func(s *Settings) { s.Images.Formats = []string{"gif"} }, `invalid image format "gif"`},
+		{"upload extension", func(s *Settings) { s.Uploads.BlockedExtensions = []string{"exe"} }, `invalid upload extension "exe"`},
+		{"fetched network", func(s *Settings) { s.Fetch.AllowNetworks = []string{"10.0.0.0/33"} }, `the fetched network "10.0.0.0/33" is neither an IP nor a CIDR range`},
+		{"listing cache", func(s *Settings) { s.ListingCache.MaxEntries = -1 }, "the limits of the listing cache can't be negative"},
+		{"live updates", func(s *Settings) { s.Live.Connections = -1 }, "the limits of the live updates can't be negative"},
+		{"archives", func(s *Settings) { s.Archives.Jobs = -1 }, "the limits of the archives can't be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.change(s)
+			err = s.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want an error with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerValidate(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *Server)
+		want   string
+	}{
+		{"valid", func(s *Server) {}, ""},
+		{"missing root", func(s *Server) { s.Root = filepath.Join(root, "missing") }, "invalid root"},
+		{"file root", func(s *Server) { s.Root = file }, "is not a directory"},
+		{"base URL", func(s *Server) { s.BaseURL = "fb" }, `the base URL "fb" doesn't start with a slash`},
+		{"base URL query", func(s *Server) { s.BaseURL = "/fb?x" }, `the base URL "/fb?x" has a query or a fragment`},
+		{"TLS", func(s *Server) { s.TLSKey = "key.pem" }, "TLS needs both a key and a certificate"},
+		{"static path", func(s *Server) { s.StaticPath = "/api/static" }, `the static path "/api/static" overlaps /api`},
+		{"landing path", func(s *Server) { s.LandingPath = "/dav/home" }, `the landing path "/dav/home" is taken by /dav`},
+		{"landing path under static", func(s *Server) { s.LandingPath = "/static/home" }, "is taken by /static"},
+		{"metrics path", func(s *Server) { s.LandingPath, s.MetricsPath = "/home", "/home" }, `the metrics path "/home" is taken by the landing page`},
+		{"status path", func(s *Server) { s.MetricsPath, s.StatusPath = "/metrics", "/metrics" }, `the status path "/metrics" is taken`},
+		{"trusted proxy", func(s *Server) { s.TrustedProxies = []string{"proxy.local"} }, `the trusted proxy "proxy.local" is neither an IP nor a CIDR range`},
+		{"metrics client", func(s *Server) { s.MetricsAllow = []string{"10.0.0.1/8", "::1", "nope"} }, `the metrics client "nope"`},
+		{"alias root", func(s *Server) { s.AliasRoots = []string{root, "mnt/nas"} }, `the alias root "mnt/nas" is not an absolute path`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Root: root}
+			tt.change(s)
+			err := s.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want an error with %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrorListsAll(t *testing.T) {
+	s := &Server{Root: "/does/not/exist", BaseURL: "fb", TLSCert: "cert.pem"}
+	err := s.Validate()
+
+	problems, ok := err.(ValidationError)
+	if !ok || len(problems) != 3 {
+		t.Fatalf("Validate() = %v, want the three problems", err)
+	}
+
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 4 || lines[0] != "invalid settings:" {
+		t.Errorf("the error is %q, want a line per problem", err)
+	}
+}
@@ -0,0 +1,69 @@
+package settings
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// The events of the operations on the files the webhooks are notified of.
+const (
+	EventUpload = "upload"
+	EventDelete = "delete"
+	EventRename = "rename"
+	EventCopy   = "copy"
+	EventMkdir  = "mkdir"
+)
+
+// Events are the events the webhooks can be notified of.
+var Events = []string{EventUpload, EventDelete, EventRename, EventCopy, EventMkdir}
+
+// Webhook is a URL that is sent a POST request with a JSON payload after
+// each successful operation on the files it is notified of.
+type Webhook struct {
+	URL string `json:"url"`
+	// Events are the events it is notified of. It is notified of all of
+	// them if it is empty.
+	Events []string `json:"events"`
+	// Secret, when set, signs the payloads with HMAC-SHA256.
+	Secret string `json:"secret"`
+}
+
+// Wants checks if the webhook is notified of event.
+func (w Webhook) Wants(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkWebhook(w Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("the webhook URL %q must be an absolute http or https URL", w.URL)
+	}
+
+	for _, event := range w.Events {
+		if !isEvent(event) {
+			return fmt.Errorf("the webhook %s has the unknown event %q: it must be one of %v", w.URL, event, Events)
+		}
+	}
+
+	return nil
+}
+
+func isEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
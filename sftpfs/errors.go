@@ -0,0 +1,38 @@
+package sftpfs
+
+import (
+	"os"
+
+	"github.com/pkg/sftp"
+)
+
+// Status codes from the SFTP protocol specification.
+const (
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFileExists       = 11
+)
+
+// mapErr converts the errors returned by the SFTP server into the
+// ones from the os package so they're treated as local ones.
+func mapErr(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	status, ok := err.(*sftp.StatusError)
+	if !ok {
+		return err
+	}
+
+	switch status.Code {
+	case fxNoSuchFile:
+		err = os.ErrNotExist
+	case fxPermissionDenied:
+		err = os.ErrPermission
+	case fxFileExists:
+		err = os.ErrExist
+	}
+
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
@@ -0,0 +1,170 @@
+package sftpfs
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/sftp"
+)
+
+// File is an afero.File stored on a remote SFTP server.
+type File struct {
+	fs   *Fs
+	name string
+	file *sftp.File
+
+	mu      sync.Mutex
+	entries []os.FileInfo
+	read    bool
+}
+
+// Name implements afero.File.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Close implements afero.File.
+func (f *File) Close() error {
+	return mapErr("close", f.name, f.file.Close())
+}
+
+// Read implements afero.File.
+func (f *File) Read(b []byte) (int, error) {
+	if err := f.fs.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.file.Read(b)
+	if err == io.EOF {
+		return n, err
+	}
+	return n, mapErr("read", f.name, err)
+}
+
+// ReadAt implements afero.File.
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Seek(off, io.SeekStart); err != nil {
+		return 0, mapErr("seek", f.name, err)
+	}
+
+	return io.ReadFull(f, b)
+}
+
+// Seek implements afero.File.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	n, err := f.file.Seek(offset, whence)
+	return n, mapErr("seek", f.name, err)
+}
+
+// Write implements afero.File.
+func (f *File) Write(b []byte) (int, error) {
+	if err := f.fs.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := f.file.Write(b)
+	return n, mapErr("write", f.name, err)
+}
+
+// WriteAt implements afero.File.
+func (f *File) WriteAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.file.Seek(off, io.SeekStart); err != nil {
+		return 0, mapErr("seek", f.name, err)
+	}
+
+	return f.Write(b)
+}
+
+// WriteString implements afero.File.
+func (f *File) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// ReadFrom makes io.Copy stream into the file using concurrent
+// requests instead of one write per buffer.
+func (f *File) ReadFrom(r io.Reader) (int64, error) {
+	n, err := f.file.ReadFrom(&ctxReader{f: f, r: r})
+	return n, mapErr("write", f.name, err)
+}
+
+type ctxReader struct {
+	f *File
+	r io.Reader
+}
+
+func (r *ctxReader) Read(b []byte) (int, error) {
+	if err := r.f.fs.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(b)
+}
+
+// Readdir implements afero.File.
+func (f *File) Readdir(count int) ([]os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.read {
+		p := f.fs.RealPath(f.name)
+		err := f.fs.do("readdir", f.name, func(c *sftp.Client) error {
+			var err error
+			f.entries, err = c.ReadDir(p)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		f.read = true
+	}
+
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(f.entries) {
+		count = len(f.entries)
+	}
+
+	entries := f.entries[:count]
+	f.entries = f.entries[count:]
+	return entries, nil
+}
+
+// Readdirnames implements afero.File.
+func (f *File) Readdirnames(n int) ([]string, error) {
+	infos, err := f.Readdir(n)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+// Stat implements afero.File.
+func (f *File) Stat() (os.FileInfo, error) {
+	info, err := f.file.Stat()
+	return info, mapErr("stat", f.name, err)
+}
+
+// Sync implements afero.File.
+func (f *File) Sync() error {
+	return nil
+}
+
+// Truncate implements afero.File.
+func (f *File) Truncate(size int64) error {
+	return mapErr("truncate", f.name, f.file.Truncate(size))
+}
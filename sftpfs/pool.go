@@ -0,0 +1,129 @@
+package sftpfs
+
+import (
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+type conn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+func (c *conn) close() {
+	c.sftp.Close()
+	c.ssh.Close()
+}
+
+// connPool keeps one connection per server and credentials. The
+// connections are dialed without holding the lock, so a slow server
+// doesn't hold up the others, and the operations that need the same
+// connection while it's dialed wait for it.
+type connPool struct {
+	mu      sync.Mutex
+	conns   map[string]*conn
+	dialing map[string]*dial
+}
+
+// dial is a connection being dialed. done is closed once it is.
+type dial struct {
+	done   chan struct{}
+	client *sftp.Client
+	err    error
+}
+
+var pool = &connPool{conns: map[string]*conn{}, dialing: map[string]*dial{}}
+
+func (p *connPool) get(cfg *Config) (*sftp.Client, error) {
+	key := cfg.key()
+
+	p.mu.Lock()
+	if c, ok := p.conns[key]; ok {
+		p.mu.Unlock()
+		return c.sftp, nil
+	}
+
+	if pending, ok := p.dialing[key]; ok {
+		p.mu.Unlock()
+		<-pending.done
+		return pending.client, pending.err
+	}
+
+	pending := &dial{done: make(chan struct{})}
+	p.dialing[key] = pending
+	p.mu.Unlock()
+
+	c, err := connect(cfg)
+
+	p.mu.Lock()
+	delete(p.dialing, key)
+	if err == nil {
+		p.conns[key] = c
+		pending.client = c.sftp
+	}
+	pending.err = err
+	p.mu.Unlock()
+	close(pending.done)
+
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		// Forget the connection as soon as it drops so the next
+		// operation dials a new one.
+		c.sftp.Wait()
+		p.forget(cfg, c.sftp)
+	}()
+
+	return c.sftp, nil
+}
+
+// connect dials the server of cfg and starts an SFTP session on it.
+func connect(cfg *Config) (*conn, error) {
+	clientCfg, err := cfg.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	sshClient, err := ssh.Dial("tcp", cfg.Host, clientCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sftpClient, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, err
+	}
+
+	return &conn{ssh: sshClient, sftp: sftpClient}, nil
+}
+
+func (p *connPool) forget(cfg *Config, client *sftp.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.conns[cfg.key()]; ok && c.sftp == client {
+		delete(p.conns, cfg.key())
+		c.close()
+	}
+}
+
+// dead checks if client is no longer usable, dropping it from
+// the pool if so.
+func (p *connPool) dead(cfg *Config, client *sftp.Client) bool {
+	if _, err := client.Getwd(); err != nil {
+		if _, ok := err.(*sftp.StatusError); !ok {
+			p.forget(cfg, client)
+			return true
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.conns[cfg.key()]
+	return !ok || c.sftp != client
+}
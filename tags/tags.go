@@ -0,0 +1,274 @@
+// Package tags keeps lists of tags on the files, such as "approved", so
+// they survive outside of File Browser. They are stored in an extended
+// attribute of the files when the filesystem of the scope supports them,
+// and in a sidecar file in each directory otherwise.
+package tags
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	// Attr is the extended attribute with the tags of a file, separated
+	// by commas.
+	Attr = "user.filemanager.tags"
+	// Sidecar is the file with the tags of the entries of a directory,
+	// by name, when the extended attributes aren't supported.
+	Sidecar = ".filemanager-tags.json"
+	// MaxTags is the number of tags a file can have.
+	MaxTags = 32
+	// MaxLength is the length in bytes of the longest tag.
+	MaxLength = 64
+)
+
+// ErrInvalid is returned for the tags that are too long or have other
+// characters than letters, digits, dashes, underscores, dots and colons,
+// and for the lists with too many of them.
+var ErrInvalid = errors.New("invalid tag")
+
+// errUnsupported is returned when the extended attributes aren't
+// supported on a path.
+var errUnsupported = errors.New("extended attributes aren't supported")
+
+// Valid checks that tag can be stored.
+func Valid(tag string) bool {
+	if tag == "" || len(tag) > MaxLength {
+		return false
+	}
+
+	for _, c := range tag {
+		switch {
+		case unicode.IsLetter(c), unicode.IsDigit(c):
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Clean returns the tags trimmed, sorted and without repetitions, or
+// ErrInvalid if one of them can't be stored.
+func Clean(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	cleaned := []string{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if !Valid(tag) {
+			return nil, ErrInvalid
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			cleaned = append(cleaned, tag)
+		}
+	}
+
+	if len(cleaned) > MaxTags {
+		return nil, ErrInvalid
+	}
+
+	sort.Strings(cleaned)
+	return cleaned, nil
+}
+
+// Store reads and writes the tags of the files of a scope.
+type Store struct {
+	fs    afero.Fs
+	local func(name string) (string, bool)
+	xattr bool
+}
+
+// probes has whether the extended attributes are supported by the root
+// of each local scope, which is only probed once.
+var probes = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+// New returns the store of the tags of the files of fs. local returns the
+// path on the local disk of a path of fs, if it has one. The extended
+// attributes are used if the root of the scope supports them.
+func New(fs afero.Fs, local func(name string) (string, bool)) *Store {
+	s := &Store{fs: fs, local: local}
+
+	root, ok := local("/")
+	if !ok {
+		return s
+	}
+
+	probes.Lock()
+	defer probes.Unlock()
+
+	supported, ok := probes.m[root]
+	if !ok {
+		supported = attrSupported(root)
+		probes.m[root] = supported
+	}
+
+	s.xattr = supported
+	return s
+}
+
+// Mode returns where the tags are stored: "xattr" or "sidecar".
+func (s *Store) Mode() string {
+	if s.xattr {
+		return "xattr"
+	}
+
+	return "sidecar"
+}
+
+// Get returns the tags of the file at name, which may be empty.
+func (s *Store) Get(name string) ([]string, error) {
+	if p, ok := s.attrPath(name); ok {
+		value, err := getAttr(p)
+		if err != errUnsupported {
+			if err != nil || len(value) == 0 {
+				return []string{}, err
+			}
+			return strings.Split(string(value), ","), nil
+		}
+	}
+
+	sidecar, key := sidecarOf(name)
+	entries, err := s.readSidecar(sidecar)
+	if err != nil {
+		return nil, err
+	}
+
+	if tags, ok := entries[key]; ok {
+		return tags, nil
+	}
+
+	return []string{}, nil
+}
+
+// GetAll returns the tags of the files at paths by path, reading each
+// sidecar file once. The files without tags are left out.
+func (s *Store) GetAll(paths []string) (map[string][]string, error) {
+	all := map[string][]string{}
+	sidecars := map[string]map[string][]string{}
+
+	for _, name := range paths {
+		var (
+			list []string
+			err  error
+		)
+
+		if s.xattr {
+			list, err = s.Get(name)
+		} else {
+			sidecar, key := sidecarOf(name)
+			entries, ok := sidecars[sidecar]
+			if !ok {
+				entries, err = s.readSidecar(sidecar)
+				sidecars[sidecar] = entries
+			}
+			list = entries[key]
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(list) > 0 {
+			all[name] = list
+		}
+	}
+
+	return all, nil
+}
+
+// Set replaces the tags of the file at name with tags, which must have
+// been cleaned. Setting no tags removes them.
+func (s *Store) Set(name string, tags []string) error {
+	if _, err := s.fs.Stat(name); err != nil {
+		return err
+	}
+
+	if p, ok := s.attrPath(name); ok {
+		if err := setAttr(p, []byte(strings.Join(tags, ","))); err != errUnsupported {
+			return err
+		}
+	}
+
+	sidecarMu.Lock()
+	defer sidecarMu.Unlock()
+
+	sidecar, key := sidecarOf(name)
+	entries, err := s.readSidecar(sidecar)
+	if err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		delete(entries, key)
+	} else {
+		entries[key] = tags
+	}
+
+	if len(entries) == 0 {
+		err := s.fs.Remove(sidecar)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(s.fs, sidecar, data, 0644)
+}
+
+func (s *Store) attrPath(name string) (string, bool) {
+	if !s.xattr {
+		return "", false
+	}
+
+	return s.local(name)
+}
+
+// sidecarMu serializes the changes to the sidecar files.
+var sidecarMu sync.Mutex
+
+// sidecarOf returns the sidecar file with the tags of the file at name,
+// and its key in it. The root's own tags have the key ".".
+func sidecarOf(name string) (string, string) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return "/" + Sidecar, "."
+	}
+
+	return path.Join(path.Dir(name), Sidecar), path.Base(name)
+}
+
+func (s *Store) readSidecar(sidecar string) (map[string][]string, error) {
+	entries := map[string][]string{}
+
+	data, err := afero.ReadFile(s.fs, sidecar)
+	if os.IsNotExist(err) {
+		return entries, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,49 @@
+//go:build linux
+// +build linux
+
+package tags
+
+import "syscall"
+
+// maxAttr is the size of the longest value of the attribute.
+const maxAttr = MaxTags * (MaxLength + 1)
+
+func getAttr(path string) ([]byte, error) {
+	buf := make([]byte, maxAttr)
+	n, err := syscall.Getxattr(path, Attr, buf)
+	switch err {
+	case nil:
+		return buf[:n], nil
+	case syscall.ENODATA:
+		return nil, nil
+	case syscall.ENOTSUP:
+		return nil, errUnsupported
+	default:
+		return nil, err
+	}
+}
+
+func setAttr(path string, value []byte) error {
+	var err error
+	if len(value) == 0 {
+		err = syscall.Removexattr(path, Attr)
+	} else {
+		err = syscall.Setxattr(path, Attr, value, 0)
+	}
+
+	switch err {
+	case syscall.ENODATA:
+		return nil
+	case syscall.ENOTSUP:
+		return errUnsupported
+	default:
+		return err
+	}
+}
+
+// attrSupported checks if the filesystem of path supports the extended
+// attributes of the users.
+func attrSupported(path string) bool {
+	_, err := getAttr(path)
+	return err == nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package tags
+
+func getAttr(path string) ([]byte, error) {
+	return nil, errUnsupported
+}
+
+func setAttr(path string, value []byte) error {
+	return errUnsupported
+}
+
+func attrSupported(path string) bool {
+	return false
+}
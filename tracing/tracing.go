@@ -0,0 +1,69 @@
+// Package tracing defines the interface File Browser traces its requests
+// through, so the programs that embed it can send the spans of the
+// listings to their own tracer, such as OpenTelemetry. The requests are
+// only traced when they carry a trace context, and the code that starts
+// the spans neither allocates nor calls the tracer when they don't.
+//
+// An OpenTelemetry tracer is adapted by extracting the context with a
+// propagation.TraceContext in Extract and telling if the span context it
+// got is valid, by calling Start on a trace.Tracer in Start, and by
+// setting the attributes with attribute.String, attribute.Int64 and
+// attribute.Bool on the span.
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer creates the spans.
+type Tracer interface {
+	// Extract returns ctx with the trace context of the headers of a
+	// request, and whether they had one.
+	Extract(ctx context.Context, header http.Header) (context.Context, bool)
+	// Start starts a span named name, child of the span of ctx, and
+	// returns ctx with the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an operation being traced. The attributes have a method by
+// type, so that setting them on the Nop span doesn't allocate.
+type Span interface {
+	SetString(key, value string)
+	SetInt(key string, value int64)
+	SetBool(key string, value bool)
+	End()
+}
+
+// Nop is the span of the requests that aren't traced.
+var Nop Span = nopSpan{}
+
+type nopSpan struct{}
+
+func (nopSpan) SetString(string, string) {}
+func (nopSpan) SetInt(string, int64)     {}
+func (nopSpan) SetBool(string, bool)     {}
+func (nopSpan) End()                     {}
+
+type tracerKey struct{}
+
+// WithTracer returns ctx with the tracer that Start uses. The spans of a
+// request are only created if its context has one.
+func WithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+// Start starts a span named name with the tracer of ctx, and returns ctx
+// with the new span. If ctx has no tracer, it returns ctx and Nop.
+func Start(ctx context.Context, name string) (context.Context, Span) {
+	if ctx == nil {
+		return ctx, Nop
+	}
+
+	tracer, ok := ctx.Value(tracerKey{}).(Tracer)
+	if !ok {
+		return ctx, Nop
+	}
+
+	return tracer.Start(ctx, name)
+}
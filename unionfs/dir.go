@@ -0,0 +1,165 @@
+package unionfs
+
+import (
+	"io"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/spf13/afero"
+)
+
+// dir is a directory whose entries are merged from every layer, or, if
+// layer isn't -1, the directory real of that layer only.
+type dir struct {
+	name    string
+	info    os.FileInfo
+	entries []os.FileInfo
+	read    bool
+	fs      *Fs
+	layer   int
+	real    string
+}
+
+// merge reads the directory from every layer. Layers where the
+// directory is missing or unreadable are skipped.
+func (d *dir) merge() error {
+	if d.layer != -1 {
+		entries, err := afero.ReadDir(d.fs.layers[d.layer], d.real)
+		if err != nil {
+			return err
+		}
+
+		d.entries = entries
+		d.read = true
+		return nil
+	}
+
+	seen := map[string]os.FileInfo{}
+	var entries []os.FileInfo
+	var firstErr error
+	found := false
+
+	for i, layer := range d.fs.layers {
+		infos, err := afero.ReadDir(layer, d.name)
+		if err != nil {
+			if i == 0 && !os.IsNotExist(err) {
+				firstErr = err
+			}
+			continue
+		}
+
+		found = true
+		for _, info := range infos {
+			first, ok := seen[info.Name()]
+			if !ok {
+				seen[info.Name()] = info
+				entries = append(entries, info)
+				continue
+			}
+
+			// Directories with the same name are merged so only
+			// the colliding files and directories get a marker.
+			if d.fs.policy != Suffix || info.IsDir() && first.IsDir() {
+				continue
+			}
+
+			entries = append(entries, renamed{info, markName(info.Name(), i)})
+		}
+	}
+
+	if !found && firstErr != nil {
+		return firstErr
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	d.entries = entries
+	d.read = true
+	return nil
+}
+
+func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.read {
+		if err := d.merge(); err != nil {
+			return nil, err
+		}
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > len(d.entries) {
+		count = len(d.entries)
+	}
+
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+func (d *dir) Readdirnames(n int) ([]string, error) {
+	infos, err := d.Readdir(n)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+func (d *dir) Name() string {
+	return d.name
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) Sync() error {
+	return nil
+}
+
+func (d *dir) err(op string) error {
+	return &os.PathError{Op: op, Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *dir) Read(b []byte) (int, error) {
+	return 0, d.err("read")
+}
+
+func (d *dir) ReadAt(b []byte, off int64) (int, error) {
+	return 0, d.err("read")
+}
+
+func (d *dir) Seek(offset int64, whence int) (int64, error) {
+	return 0, d.err("seek")
+}
+
+func (d *dir) Write(b []byte) (int, error) {
+	return 0, d.err("write")
+}
+
+func (d *dir) WriteAt(b []byte, off int64) (int, error) {
+	return 0, d.err("write")
+}
+
+func (d *dir) WriteString(s string) (int, error) {
+	return 0, d.err("write")
+}
+
+func (d *dir) Truncate(size int64) error {
+	return d.err("truncate")
+}
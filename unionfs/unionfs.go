@@ -0,0 +1,385 @@
+package unionfs
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// Policy describes how entries with the same name in different
+// layers are merged.
+type Policy string
+
+const (
+	// FirstWins only shows the entry from the first layer that has it.
+	FirstWins Policy = "first"
+	// Suffix shows every entry, adding a "~N" marker to the name of the
+	// ones coming from the Nth layer. Directories are merged with the
+	// directories of the same name, but not with the files, so a file and
+	// a directory of the same name are both shown too.
+	Suffix Policy = "suffix"
+)
+
+var markerRegexp = regexp.MustCompile(`^(.*)~([0-9]+)(\.[^.~]*)?$`)
+
+// Fs merges multiple filesystems into a single tree. The first layer is
+// the primary one: every write goes there and only the entries it holds
+// can be removed or renamed. The other layers are read-only, except that
+// removing an entry of the primary layer removes the copies the union
+// shows as that entry, so it doesn't show up again.
+type Fs struct {
+	layers  []afero.Fs
+	sources []afero.Fs
+	policy  Policy
+}
+
+// New creates a new union of the given layers.
+func New(policy Policy, primary afero.Fs, secondary ...afero.Fs) *Fs {
+	if policy != Suffix {
+		policy = FirstWins
+	}
+
+	layers := []afero.Fs{primary}
+	for _, fs := range secondary {
+		layers = append(layers, afero.NewReadOnlyFs(fs))
+	}
+
+	sources := append([]afero.Fs{primary}, secondary...)
+	return &Fs{layers: layers, sources: sources, policy: policy}
+}
+
+// Primary returns the layer which receives the writes.
+func (fs *Fs) Primary() afero.Fs {
+	return fs.layers[0]
+}
+
+func clean(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+// marked splits a name with a layer marker in the name without it
+// and the index of the layer. It returns -1 if there's no marker.
+func (fs *Fs) marked(name string) (string, int) {
+	if fs.policy != Suffix {
+		return name, -1
+	}
+
+	m := markerRegexp.FindStringSubmatch(name)
+	if m == nil {
+		return name, -1
+	}
+
+	n, err := strconv.Atoi(m[2])
+	if err != nil || n < 2 || n > len(fs.layers) {
+		return name, -1
+	}
+
+	return m[1] + m[3], n - 1
+}
+
+func markName(name string, layer int) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "~" + strconv.Itoa(layer+1) + ext
+}
+
+// find returns the index of the layer that serves name, the name in
+// that layer and its info. The secondary layers only serve the entries
+// of the directories they are merged in, so a directory hidden by a file
+// of an earlier layer hides its entries too.
+func (fs *Fs) find(name string) (int, string, os.FileInfo, error) {
+	name = clean(name)
+	var firstErr error
+
+	for i, layer := range fs.layers {
+		if i == 1 && !fs.mergedDir(path.Dir(name)) {
+			break
+		}
+
+		info, err := layer.Stat(name)
+		if err == nil {
+			return i, name, info, nil
+		}
+
+		if firstErr == nil && !os.IsNotExist(err) {
+			firstErr = err
+		}
+	}
+
+	if i, real, info, ok := fs.findMarked(name); ok {
+		return i, real, info, nil
+	}
+
+	if firstErr == nil {
+		firstErr = os.ErrNotExist
+	}
+
+	return -1, "", nil, &os.PathError{Op: "stat", Path: name, Err: firstErr}
+}
+
+// mergedDir checks if the union shows dir as a directory merged from
+// every layer that has it.
+func (fs *Fs) mergedDir(dir string) bool {
+	if dir == "/" {
+		return true
+	}
+
+	_, real, info, err := fs.find(dir)
+	return err == nil && real == dir && info.IsDir()
+}
+
+// findMarked finds name in the layer of the marker of one of its
+// segments, such as "/photos/a~2.jpg" for "/photos/a.jpg" of the second
+// layer, or "/photos~2/a.jpg" for the one under its "/photos". The marker
+// must be one the listings show: the entry it names collides with an
+// entry of an earlier layer, and they aren't both directories.
+func (fs *Fs) findMarked(name string) (int, string, os.FileInfo, bool) {
+	segments := strings.Split(strings.TrimPrefix(name, "/"), "/")
+	for k, segment := range segments {
+		unmarked, i := fs.marked(segment)
+		if i == -1 {
+			continue
+		}
+
+		prefix := path.Join(append([]string{"/"}, append(segments[:k:k], unmarked)...)...)
+		shown, err := fs.layers[i].Stat(prefix)
+		if err != nil {
+			continue
+		}
+
+		first, _, hidden, err := fs.find(prefix)
+		if err != nil || first >= i || shown.IsDir() && hidden.IsDir() {
+			continue
+		}
+
+		real := path.Join(append([]string{prefix}, segments[k+1:]...)...)
+		info, err := fs.layers[i].Stat(real)
+		if err != nil {
+			return -1, "", nil, false
+		}
+
+		if k == len(segments)-1 {
+			info = renamed{info, segment}
+		}
+		return i, real, info, true
+	}
+
+	return -1, "", nil, false
+}
+
+// Name implements afero.Fs.
+func (fs *Fs) Name() string {
+	return "unionfs"
+}
+
+// Stat implements afero.Fs.
+func (fs *Fs) Stat(name string) (os.FileInfo, error) {
+	_, _, info, err := fs.find(name)
+	return info, err
+}
+
+// Open implements afero.Fs.
+func (fs *Fs) Open(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile implements afero.Fs.
+func (fs *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	name = clean(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		if err := fs.ensureParent(name); err != nil {
+			return nil, err
+		}
+
+		return fs.layers[0].OpenFile(name, flag, perm)
+	}
+
+	i, real, info, err := fs.find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return fs.layers[i].OpenFile(real, flag, perm)
+	}
+
+	// The directories with a marker are only in their layer.
+	if real != name {
+		return &dir{name: name, info: info, fs: fs, layer: i, real: real}, nil
+	}
+
+	return &dir{name: name, info: info, fs: fs, layer: -1}, nil
+}
+
+// ensureParent makes sure the parent directory of name exists on
+// the primary layer if it exists anywhere on the union. The directories
+// with a marker are only in their secondary layer, so nothing can be
+// written under them.
+func (fs *Fs) ensureParent(name string) error {
+	dir := path.Dir(name)
+	_, real, info, err := fs.find(dir)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrInvalid}
+	}
+
+	if real != dir {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+
+	if _, err := fs.layers[0].Stat(dir); err == nil {
+		return nil
+	}
+
+	return fs.layers[0].MkdirAll(dir, info.Mode().Perm())
+}
+
+// primaryOnly returns an error if name does not exist on the primary
+// layer, using os.ErrPermission when it only exists in a secondary one.
+func (fs *Fs) primaryOnly(op, name string) error {
+	name = clean(name)
+
+	_, err := fs.layers[0].Stat(name)
+	if err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	if _, err := fs.Stat(name); err != nil {
+		return err
+	}
+
+	return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+}
+
+// Create implements afero.Fs.
+func (fs *Fs) Create(name string) (afero.File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir implements afero.Fs.
+func (fs *Fs) Mkdir(name string, perm os.FileMode) error {
+	name = clean(name)
+
+	if _, err := fs.Stat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+
+	if err := fs.ensureParent(name); err != nil {
+		return err
+	}
+
+	return fs.layers[0].Mkdir(name, perm)
+}
+
+// MkdirAll implements afero.Fs.
+func (fs *Fs) MkdirAll(name string, perm os.FileMode) error {
+	name = clean(name)
+
+	// Like the files, the directories can't be made under the ones with
+	// a marker.
+	for dir := name; dir != "/"; dir = path.Dir(dir) {
+		if _, real, _, err := fs.find(dir); err == nil {
+			if real != dir {
+				return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrPermission}
+			}
+			break
+		}
+	}
+
+	return fs.layers[0].MkdirAll(name, perm)
+}
+
+// Remove implements afero.Fs.
+func (fs *Fs) Remove(name string) error {
+	return fs.remove(name, afero.Fs.Remove)
+}
+
+// RemoveAll implements afero.Fs.
+func (fs *Fs) RemoveAll(name string) error {
+	err := fs.remove(name, afero.Fs.RemoveAll)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// remove removes name from the primary layer with fn, after the copies
+// of the secondary layers the union shows as the same entry: all of them
+// with FirstWins, and the directories merged with a directory with
+// Suffix. The copies go first, so a secondary layer that can't be changed
+// leaves the entry as it was.
+func (fs *Fs) remove(name string, fn func(afero.Fs, string) error) error {
+	if err := fs.primaryOnly("remove", name); err != nil {
+		return err
+	}
+
+	name = clean(name)
+	info, err := fs.layers[0].Stat(name)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range fs.sources[1:] {
+		copyInfo, err := source.Stat(name)
+		if err != nil || fs.policy == Suffix && !(info.IsDir() && copyInfo.IsDir()) {
+			continue
+		}
+
+		if err := fn(source, name); err != nil {
+			return err
+		}
+	}
+
+	return fn(fs.layers[0], name)
+}
+
+// Rename implements afero.Fs.
+func (fs *Fs) Rename(oldname, newname string) error {
+	if err := fs.primaryOnly("rename", oldname); err != nil {
+		return err
+	}
+
+	if err := fs.ensureParent(clean(newname)); err != nil {
+		return err
+	}
+
+	return fs.layers[0].Rename(clean(oldname), clean(newname))
+}
+
+// Chmod implements afero.Fs.
+func (fs *Fs) Chmod(name string, mode os.FileMode) error {
+	if err := fs.primaryOnly("chmod", name); err != nil {
+		return err
+	}
+
+	return fs.layers[0].Chmod(clean(name), mode)
+}
+
+// Chtimes implements afero.Fs.
+func (fs *Fs) Chtimes(name string, atime, mtime time.Time) error {
+	if err := fs.primaryOnly("chtimes", name); err != nil {
+		return err
+	}
+
+	return fs.layers[0].Chtimes(clean(name), atime, mtime)
+}
+
+// renamed is an os.FileInfo shown under a different name.
+type renamed struct {
+	os.FileInfo
+	name string
+}
+
+func (r renamed) Name() string {
+	return r.name
+}
@@ -0,0 +1,226 @@
+package unionfs
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// layers returns the primary and the secondary layers of the tests. The
+// names ending with a slash are directories.
+func layers(t *testing.T) (afero.Fs, afero.Fs) {
+	t.Helper()
+
+	newFs := func(names ...string) afero.Fs {
+		fs := afero.NewMemMapFs()
+		for _, name := range names {
+			var err error
+			if strings.HasSuffix(name, "/") {
+				err = fs.MkdirAll(name, 0755)
+			} else {
+				err = afero.WriteFile(fs, name, []byte(name), 0644)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return fs
+	}
+
+	primary := newFs("/a.txt", "/both/p.txt", "/x", "/y/", "/only-primary/")
+	secondary := newFs("/a.txt", "/both/s.txt", "/x/in.txt", "/y", "/only.txt")
+	return primary, secondary
+}
+
+func newUnion(t *testing.T, policy Policy) *Fs {
+	t.Helper()
+
+	primary, secondary := layers(t)
+	return New(policy, primary, secondary)
+}
+
+func names(t *testing.T, fs afero.Fs, dir string) string {
+	t.Helper()
+
+	infos, err := afero.ReadDir(fs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+
+	return strings.Join(names, " ")
+}
+
+func TestListing(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		dir    string
+		want   string
+	}{
+		{FirstWins, "/", "a.txt both/ only-primary/ only.txt x y/"},
+		{FirstWins, "/both", "p.txt s.txt"},
+		{Suffix, "/", "a.txt a~2.txt both/ only-primary/ only.txt x x~2/ y/ y~2"},
+		{Suffix, "/both", "p.txt s.txt"},
+		{Suffix, "/x~2", "in.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+" "+tt.dir, func(t *testing.T) {
+			if got := names(t, newUnion(t, tt.policy), tt.dir); got != tt.want {
+				t.Errorf("the listing is %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		policy Policy
+		name   string
+		want   string // the content, or "" if it doesn't exist
+	}{
+		{FirstWins, "/a.txt", "/a.txt"},
+		{FirstWins, "/only.txt", "/only.txt"},
+		{FirstWins, "/a~2.txt", ""},
+		{Suffix, "/a~2.txt", "/a.txt"},
+		{Suffix, "/y~2", "/y"},
+		{Suffix, "/x~2/in.txt", "/x/in.txt"},
+		{Suffix, "/x/in.txt", ""},
+		{Suffix, "/both~2/s.txt", ""},
+		{Suffix, "/only~2.txt", ""},
+		{Suffix, "/a~3.txt", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+" "+tt.name, func(t *testing.T) {
+			content, err := afero.ReadFile(newUnion(t, tt.policy), tt.name)
+			if tt.want == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("ReadFile() = %q, %v, want it missing", content, err)
+				}
+				return
+			}
+
+			if err != nil || string(content) != tt.want {
+				t.Errorf("ReadFile() = %q, %v, want %q", content, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingSecondary(t *testing.T) {
+	primary, _ := layers(t)
+	fs := New(Suffix, primary, afero.NewBasePathFs(afero.NewMemMapFs(), "/missing"))
+
+	if got, want := names(t, fs, "/"), "a.txt both/ only-primary/ x y/"; got != want {
+		t.Errorf("the listing is %q, want %q", got, want)
+	}
+
+	if err := afero.WriteFile(fs, "/new.txt", []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := primary.Stat("/new.txt"); err != nil {
+		t.Errorf("the file wasn't written to the primary layer: %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name    string
+		written bool // it's written to the primary layer, or it fails
+	}{
+		{"/new.txt", true},
+		{"/only-primary/new.txt", true},
+		{"/both/new.txt", true},
+		{"/x~2/new.txt", false}, // the directories with a marker are read-only
+		{"/x/new.txt", false},   // /x is a file
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, secondary := layers(t)
+			err := afero.WriteFile(New(Suffix, primary, secondary), tt.name, []byte("new"), 0644)
+			if !tt.written {
+				if err == nil {
+					t.Error("WriteFile() succeeded")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := primary.Stat(tt.name); err != nil {
+				t.Errorf("the file wasn't written to the primary layer: %v", err)
+			}
+		})
+	}
+}
+
+func TestMkdirAll(t *testing.T) {
+	fs := newUnion(t, Suffix)
+	if err := fs.MkdirAll("/both/new/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fs.MkdirAll("/x~2/new/sub", 0755); !os.IsPermission(err) {
+		t.Errorf("MkdirAll() under a directory with a marker = %v, want a permission error", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		policy    Policy
+		name      string
+		readOnly  bool   // the secondary layer can't be changed
+		denied    bool   // the removal fails with a permission error
+		remaining string // the listing of / after the removal
+		secondary string // the listing of / of the secondary layer after it
+	}{
+		{FirstWins, "/both", false, false, "a.txt only-primary/ only.txt x y/", "a.txt only.txt x/ y"},
+		{Suffix, "/both", false, false, "a.txt a~2.txt only-primary/ only.txt x x~2/ y/ y~2", "a.txt only.txt x/ y"},
+		{Suffix, "/both", true, true, "a.txt a~2.txt both/ only-primary/ only.txt x x~2/ y/ y~2", "a.txt both/ only.txt x/ y"},
+		{FirstWins, "/a.txt", false, false, "both/ only-primary/ only.txt x y/", "both/ only.txt x/ y"},
+		{Suffix, "/a.txt", false, false, "a.txt both/ only-primary/ only.txt x x~2/ y/ y~2", "a.txt both/ only.txt x/ y"},
+		{Suffix, "/y", false, false, "a.txt a~2.txt both/ only-primary/ only.txt x x~2/ y", "a.txt both/ only.txt x/ y"},
+		{Suffix, "/only.txt", false, true, "a.txt a~2.txt both/ only-primary/ only.txt x x~2/ y/ y~2", "a.txt both/ only.txt x/ y"},
+		{Suffix, "/x~2", false, true, "a.txt a~2.txt both/ only-primary/ only.txt x x~2/ y/ y~2", "a.txt both/ only.txt x/ y"},
+		{Suffix, "/missing", false, false, "a.txt a~2.txt both/ only-primary/ only.txt x x~2/ y/ y~2", "a.txt both/ only.txt x/ y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+" "+tt.name, func(t *testing.T) {
+			primary, secondary := layers(t)
+			layer := secondary
+			if tt.readOnly {
+				layer = afero.NewReadOnlyFs(secondary)
+			}
+			fs := New(tt.policy, primary, layer)
+
+			err := fs.RemoveAll(tt.name)
+			if denied := err != nil; denied != tt.denied {
+				t.Fatalf("RemoveAll() = %v, want denied: %v", err, tt.denied)
+			}
+
+			if got := names(t, fs, "/"); got != tt.remaining {
+				t.Errorf("the listing is %q, want %q", got, tt.remaining)
+			}
+
+			if got := names(t, secondary, "/"); got != tt.secondary {
+				t.Errorf("the secondary layer has %q, want %q", got, tt.secondary)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package users
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/filebrowser/filebrowser/v2/sftpfs"
+)
+
+// AliasError describes why an alias of a user is invalid.
+type AliasError struct {
+	Path   string
+	Reason string
+}
+
+func (e *AliasError) Error() string {
+	return fmt.Sprintf("invalid alias %s: %s", e.Path, e.Reason)
+}
+
+// CheckAliases checks that the aliases are below the root of the scope,
+// are not inside one another and have targets that are existing
+// directories. The targets on SFTP servers are only checked when they
+// are opened.
+func CheckAliases(aliases []Alias) error {
+	for i, alias := range aliases {
+		p := path.Clean("/" + filepath.ToSlash(alias.Path))
+		if p == "/" {
+			return &AliasError{Path: alias.Path, Reason: "it can't be the root of the scope"}
+		}
+
+		for _, other := range aliases[:i] {
+			o := path.Clean("/" + filepath.ToSlash(other.Path))
+			if p == o || strings.HasPrefix(p, o+"/") || strings.HasPrefix(o, p+"/") {
+				return &AliasError{Path: alias.Path, Reason: "it overlaps the alias " + other.Path}
+			}
+		}
+
+		if sftpfs.IsURL(alias.Target) {
+			continue
+		}
+
+		if !filepath.IsAbs(alias.Target) {
+			return &AliasError{Path: alias.Path, Reason: "the target must be an absolute path"}
+		}
+
+		info, err := os.Stat(alias.Target)
+		if err != nil {
+			return &AliasError{Path: alias.Path, Reason: err.Error()}
+		}
+
+		if !info.IsDir() {
+			return &AliasError{Path: alias.Path, Reason: "the target is not a directory"}
+		}
+	}
+
+	return nil
+}
+
+// CheckAliasRoots checks that the targets of the aliases are in one of
+// the roots, once their symbolic links are resolved, so the aliases can
+// only serve the directories the administrators approved. The targets on
+// SFTP servers aren't on the disk and are left to CheckAliases.
+func CheckAliasRoots(aliases []Alias, roots []string) error {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if root, err := filepath.EvalSymlinks(root); err == nil {
+			resolved = append(resolved, root)
+		}
+	}
+
+	for _, alias := range aliases {
+		if sftpfs.IsURL(alias.Target) {
+			continue
+		}
+
+		target, err := filepath.EvalSymlinks(alias.Target)
+		if err != nil {
+			return &AliasError{Path: alias.Path, Reason: err.Error()}
+		}
+
+		if !inRoots(target, resolved) {
+			return &AliasError{Path: alias.Path, Reason: "the target is outside of the roots of the aliases"}
+		}
+	}
+
+	return nil
+}
+
+// inRoots checks if p is one of the roots or is under one of them.
+func inRoots(p string, roots []string) bool {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, p)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+
+	return false
+}
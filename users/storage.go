@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/filebrowser/filebrowser/v2/errors"
+	"github.com/filebrowser/filebrowser/v2/excludefs"
+	"github.com/filebrowser/filebrowser/v2/rules"
 )
 
 // StorageBackend is the interface to implement for a users storage.
@@ -60,11 +62,35 @@ func (s *Storage) Gets(baseScope string) ([]*User, error) {
 
 // Update updates a user in the database.
 func (s *Storage) Update(user *User, fields ...string) error {
+	if len(fields) == 0 || contains(fields, "Ignore") {
+		if err := excludefs.CheckPatterns(user.Ignore); err != nil {
+			return err
+		}
+	}
+
+	if len(fields) == 0 || contains(fields, "Rules") {
+		if err := rules.Check(user.Rules); err != nil {
+			return err
+		}
+	}
+
 	err := user.Clean("", fields...)
 	if err != nil {
 		return err
 	}
 
+	if len(fields) == 0 || contains(fields, "Aliases") {
+		if err := CheckAliases(user.Aliases); err != nil {
+			return err
+		}
+	}
+
+	if len(fields) == 0 || contains(fields, "Aliases") || contains(fields, "Transfers") {
+		if err := CheckTransfers(user.Aliases, user.Transfers); err != nil {
+			return err
+		}
+	}
+
 	err = s.back.Update(user, fields...)
 	if err != nil {
 		return err
@@ -78,10 +104,26 @@ func (s *Storage) Update(user *User, fields ...string) error {
 
 // Save saves the user in a storage.
 func (s *Storage) Save(user *User) error {
+	if err := excludefs.CheckPatterns(user.Ignore); err != nil {
+		return err
+	}
+
+	if err := rules.Check(user.Rules); err != nil {
+		return err
+	}
+
 	if err := user.Clean(""); err != nil {
 		return err
 	}
 
+	if err := CheckAliases(user.Aliases); err != nil {
+		return err
+	}
+
+	if err := CheckTransfers(user.Aliases, user.Transfers); err != nil {
+		return err
+	}
+
 	return s.back.Save(user)
 }
 
@@ -110,3 +152,13 @@ func (s *Storage) LastUpdate(id uint) int64 {
 	}
 	return 0
 }
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
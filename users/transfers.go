@@ -0,0 +1,85 @@
+package users
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Transfer allows a user to move files from the area of the scope at From
+// to the one at To. The areas are the aliases, by their paths, and the
+// rest of the scope, which is "/". The moves between the areas copy the
+// files, since their roots differ, and are only made when asked for.
+type Transfer struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Area returns the area of the scope of the user p is in: the path of
+// its alias, or "/" if it isn't in one, and whether it's read-only.
+func (u *User) Area(p string) (string, bool) {
+	return u.area(p, false)
+}
+
+// AreaFold is like Area, but finds the aliases regardless of case, for
+// the scopes that find their files regardless of case.
+func (u *User) AreaFold(p string) (string, bool) {
+	return u.area(p, true)
+}
+
+func (u *User) area(p string, fold bool) (string, bool) {
+	p = path.Clean("/" + p)
+	if fold {
+		p = strings.ToLower(p)
+	}
+
+	for _, alias := range u.Aliases {
+		a := path.Clean("/" + filepath.ToSlash(alias.Path))
+		name := a
+		if fold {
+			name = strings.ToLower(a)
+		}
+
+		if p == name || strings.HasPrefix(p, name+"/") {
+			return a, alias.ReadOnly
+		}
+	}
+
+	return "/", false
+}
+
+// AllowsTransfer checks if the user can move files from the area from to
+// the area to.
+func (u *User) AllowsTransfer(from, to string) bool {
+	for _, transfer := range u.Transfers {
+		if path.Clean("/"+transfer.From) == from && path.Clean("/"+transfer.To) == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckTransfers checks that the transfers are between different areas
+// of the scope, which are "/" or the paths of the aliases.
+func CheckTransfers(aliases []Alias, transfers []Transfer) error {
+	areas := map[string]bool{"/": true}
+	for _, alias := range aliases {
+		areas[path.Clean("/"+filepath.ToSlash(alias.Path))] = true
+	}
+
+	for _, transfer := range transfers {
+		from, to := path.Clean("/"+transfer.From), path.Clean("/"+transfer.To)
+		for _, p := range []string{from, to} {
+			if !areas[p] {
+				return &AliasError{Path: p, Reason: "transfers must be between aliases or the rest of the scope"}
+			}
+		}
+
+		if from == to {
+			return &AliasError{Path: from, Reason: "it can't be transferred to itself"}
+		}
+	}
+
+	return nil
+}
@@ -1,13 +1,20 @@
 package users
 
 import (
+	"log"
 	"path/filepath"
 	"regexp"
 
 	"github.com/filebrowser/filebrowser/v2/errors"
 
+	"github.com/filebrowser/filebrowser/v2/aliasfs"
+	"github.com/filebrowser/filebrowser/v2/excludefs"
 	"github.com/filebrowser/filebrowser/v2/files"
+	"github.com/filebrowser/filebrowser/v2/linkfs"
+	"github.com/filebrowser/filebrowser/v2/normfs"
 	"github.com/filebrowser/filebrowser/v2/rules"
+	"github.com/filebrowser/filebrowser/v2/sftpfs"
+	"github.com/filebrowser/filebrowser/v2/unionfs"
 	"github.com/spf13/afero"
 )
 
@@ -19,20 +26,92 @@ const (
 	MosaicViewMode ViewMode = "mosaic"
 )
 
+// Ways the requests of a user for machine readable formats, such as the
+// JSON listings, are handled when the user can't get them: with the HTML
+// listing instead, or rejected with 406 Not Acceptable.
+const (
+	MachineFormatsHTML   = "html"
+	MachineFormatsReject = "reject"
+)
+
 // User describes a user.
 type User struct {
-	ID           uint          `storm:"id,increment" json:"id"`
-	Username     string        `storm:"unique" json:"username"`
-	Password     string        `json:"password"`
-	Scope        string        `json:"scope"`
-	Locale       string        `json:"locale"`
-	LockPassword bool          `json:"lockPassword"`
-	ViewMode     ViewMode      `json:"viewMode"`
-	Perm         Permissions   `json:"perm"`
-	Commands     []string      `json:"commands"`
-	Sorting      files.Sorting `json:"sorting"`
-	Fs           afero.Fs      `json:"-" yaml:"-"`
-	Rules        []rules.Rule  `json:"rules"`
+	ID           uint           `storm:"id,increment" json:"id"`
+	Username     string         `storm:"unique" json:"username"`
+	Password     string         `json:"password"`
+	Scope        string         `json:"scope"`
+	UnionScopes  []string       `json:"unionScopes"`
+	UnionPolicy  unionfs.Policy `json:"unionPolicy"`
+	Locale       string         `json:"locale"`
+	LockPassword bool           `json:"lockPassword"`
+	ViewMode     ViewMode       `json:"viewMode"`
+	Perm         Permissions    `json:"perm"`
+	Commands     []string       `json:"commands"`
+	Sorting      files.Sorting  `json:"sorting"`
+	Fs           afero.Fs       `json:"-" yaml:"-"`
+	Rules        []rules.Rule   `json:"rules"`
+	// Title and Favicon replace the ones of the branding settings in the
+	// HTML listings of the scope of the user.
+	Title   string `json:"title"`
+	Favicon string `json:"favicon"`
+	// Branding brands the HTML listings of the scope of the user.
+	Branding Branding `json:"branding"`
+	// MachineFormats, when set, keeps the user from getting the listings
+	// in any format but HTML, plain text included, and the routes that
+	// only serve machine readable formats, such as the search, the tree
+	// and WebDAV.
+	MachineFormats string `json:"machineFormats"`
+	// Exclude has the paths of the scope that are hidden from the user as
+	// if they didn't exist, with everything under them.
+	Exclude []string `json:"exclude"`
+	// Ignore has the glob patterns of the entries of the scope hidden from
+	// the user like the excluded paths: *.bak matches the names of the
+	// entries, /build/*.log their paths from the root of the scope, and
+	// node_modules/ only the directories.
+	Ignore []string `json:"ignore"`
+	// ListingIndex, when set, replaces the mode of the index files of the
+	// HTML listings of the settings for the scope of the user.
+	ListingIndex string `json:"listingIndex"`
+	// Variables are available to the templates of the HTML listings of the
+	// scope of the user through their Var method.
+	Variables map[string]string `json:"variables"`
+	// Aliases serve some directories of the scope of the user from other
+	// roots.
+	Aliases []Alias `json:"aliases"`
+	// Favorites are the paths of the scope the user pinned to the top of
+	// the listings.
+	Favorites []string `json:"favorites"`
+	// Transfers are the areas of the scope the user can move files
+	// between, when the moves ask for it.
+	Transfers []Transfer `json:"transfers"`
+}
+
+// Alias serves the directory at Path of the scope of a user, and
+// everything under it, from Target, which is an absolute path or an SFTP
+// URL. The alias shows up in the directory above it as a normal
+// directory. ReadOnly aliases can't be written, whatever the
+// permissions of the user, and Private ones are left out of the landing
+// page.
+type Alias struct {
+	Path     string `json:"path"`
+	Target   string `json:"target,omitempty"`
+	ReadOnly bool   `json:"readOnly"`
+	Private  bool   `json:"private"`
+}
+
+// Branding is the branding of the HTML listings of the scope of a user.
+// Name and Logo, the URL of an image, head the listings, and Color is the
+// color of their links. CSSFile and JSFile are a stylesheet and a script
+// written in the listings: their paths are in the scope of the user, or
+// on the disk of the server when they are absolute. HideCredits leaves
+// out the link to the project from the footer.
+type Branding struct {
+	Name        string `json:"name"`
+	Logo        string `json:"logo"`
+	Color       string `json:"color"`
+	CSSFile     string `json:"cssFile"`
+	JSFile      string `json:"jsFile"`
+	HideCredits bool   `json:"hideCredits"`
 }
 
 // GetRules implements rules.Provider.
@@ -86,22 +165,127 @@ func (u *User) Clean(baseScope string, fields ...string) error {
 		}
 	}
 
-	if u.Fs == nil {
-		scope := u.Scope
+	if u.Fs != nil {
+		return nil
+	}
+
+	fs, err := scopeFs(baseScope, u.Scope)
+	if err != nil {
+		return err
+	}
+
+	if len(u.UnionScopes) == 0 {
+		u.Fs = excluding(aliasing(fs, u.Aliases), u.Exclude, u.Ignore)
+		return nil
+	}
 
-		if !filepath.IsAbs(scope) {
-			scope = filepath.Join(baseScope, scope)
+	secondary := make([]afero.Fs, 0, len(u.UnionScopes))
+	for _, scope := range u.UnionScopes {
+		layer, err := scopeFs(baseScope, scope)
+		if err != nil {
+			return err
 		}
 
-		u.Fs = afero.NewBasePathFs(afero.NewOsFs(), scope)
+		secondary = append(secondary, layer)
 	}
 
+	u.Fs = excluding(aliasing(unionfs.New(u.UnionPolicy, fs, secondary...), u.Aliases), u.Exclude, u.Ignore)
 	return nil
 }
 
-// FullPath gets the full path for a user's relative path.
+func aliasing(fs afero.Fs, aliases []Alias) afero.Fs {
+	if len(aliases) == 0 {
+		return fs
+	}
+
+	layers := make([]aliasfs.Alias, 0, len(aliases))
+	for _, alias := range aliases {
+		target, err := scopeFs("", alias.Target)
+		if err != nil {
+			log.Printf("couldn't open the alias %s: %v", alias.Path, err)
+			continue
+		}
+
+		layers = append(layers, aliasfs.Alias{Path: alias.Path, Fs: target, ReadOnly: alias.ReadOnly})
+	}
+
+	return aliasfs.New(fs, layers)
+}
+
+func excluding(fs afero.Fs, excluded, ignored []string) afero.Fs {
+	if len(excluded) == 0 && len(ignored) == 0 {
+		return fs
+	}
+
+	if err := excludefs.CheckPatterns(ignored); err != nil {
+		log.Printf("left an ignore pattern out: %v", err)
+	}
+
+	return excludefs.New(fs, excluded, ignored)
+}
+
+func scopeFs(baseScope, scope string) (afero.Fs, error) {
+	if sftpfs.IsURL(scope) {
+		return sftpfs.New(scope)
+	}
+
+	if !filepath.IsAbs(scope) {
+		scope = filepath.Join(baseScope, scope)
+	}
+
+	return afero.NewBasePathFs(afero.NewOsFs(), scope), nil
+}
+
+// FullPath gets the full path for a user's relative path. On merged
+// scopes, it is the path on the primary scope.
 func (u *User) FullPath(path string) string {
-	return afero.FullBaseFsPath(u.Fs.(*afero.BasePathFs), path)
+	return fullPath(u.Fs, path)
+}
+
+// LocalPath returns the path on the local disk of a user's relative
+// path. It returns false if the scope isn't on the local disk.
+func (u *User) LocalPath(path string) (string, bool) {
+	return localPath(u.Fs, path)
+}
+
+func localPath(fs afero.Fs, path string) (string, bool) {
+	switch fs := fs.(type) {
+	case *afero.BasePathFs:
+		return afero.FullBaseFsPath(fs, path), true
+	case *unionfs.Fs:
+		return localPath(fs.Primary(), path)
+	case *normfs.Fs:
+		return localPath(fs.Source(), path)
+	case *excludefs.Fs:
+		return localPath(fs.Source(), path)
+	case *aliasfs.Fs:
+		return localPath(fs.Resolve(path))
+	case *linkfs.Fs:
+		return localPath(fs.Source(), path)
+	default:
+		return "", false
+	}
+}
+
+func fullPath(fs afero.Fs, path string) string {
+	switch fs := fs.(type) {
+	case *afero.BasePathFs:
+		return afero.FullBaseFsPath(fs, path)
+	case *sftpfs.Fs:
+		return fs.RealPath(path)
+	case *unionfs.Fs:
+		return fullPath(fs.Primary(), path)
+	case *normfs.Fs:
+		return fullPath(fs.Source(), path)
+	case *excludefs.Fs:
+		return fullPath(fs.Source(), path)
+	case *aliasfs.Fs:
+		return fullPath(fs.Resolve(path))
+	case *linkfs.Fs:
+		return fullPath(fs.Source(), path)
+	default:
+		return path
+	}
 }
 
 // CanExecute checks if an user can execute a specific command.
@@ -0,0 +1,248 @@
+// Package webhooks notifies URLs of the operations on the files. It is a
+// subscriber of the events, whose notifications are sent from a queue in
+// the background, so slow or failing endpoints never hold the requests
+// that made them.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/filebrowser/filebrowser/v2/events"
+	"github.com/filebrowser/filebrowser/v2/logging"
+	"github.com/filebrowser/filebrowser/v2/settings"
+)
+
+// SignatureHeader is the header of the signature of the payloads of the
+// webhooks with a secret. It is "sha256=" followed by the hexadecimal
+// HMAC-SHA256 of the body with the secret as the key.
+const SignatureHeader = "X-Filebrowser-Signature"
+
+const (
+	// queueSize is the number of notifications that can wait to be
+	// sent. The ones over it are dropped.
+	queueSize = 1024
+	workers   = 4
+	// maxAttempts is the number of times a notification is sent before
+	// it is given up. The waits between them start at firstBackoff and
+	// double each time.
+	maxAttempts  = 5
+	firstBackoff = time.Second
+	timeout      = 10 * time.Second
+)
+
+type job struct {
+	hook  settings.Webhook
+	event events.Event
+	body  []byte
+}
+
+// Queue sends the notifications in the background.
+type Queue struct {
+	logger logging.Logger
+	client *http.Client
+	jobs   chan job
+	wg     sync.WaitGroup
+
+	// abort is closed when the queue is closed and the context given to
+	// Close is done, so the notifications waiting to be retried give up.
+	abort     chan struct{}
+	abortOnce sync.Once
+
+	mu     sync.RWMutex
+	closed bool
+
+	// unsubscribe stops the events from the bus the queue listens to, and
+	// listening waits for the ones received to be queued.
+	unsubscribe func()
+	listening   sync.WaitGroup
+}
+
+// NewQueue starts a Queue that logs the notifications it gives up with
+// logger.
+func NewQueue(logger logging.Logger) *Queue {
+	q := &Queue{
+		logger: logger,
+		client: &http.Client{Timeout: timeout},
+		jobs:   make(chan job, queueSize),
+		abort:  make(chan struct{}),
+	}
+
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q
+}
+
+// Listen subscribes the queue to the events of bus, which are sent to the
+// hooks returned by hooks when they are received. It can only be called
+// once, and the events stop when the queue is closed.
+func (q *Queue) Listen(bus *events.Bus, hooks func() []settings.Webhook) {
+	ch, unsubscribe := bus.Subscribe()
+	q.unsubscribe = unsubscribe
+
+	q.listening.Add(1)
+	go func() {
+		defer q.listening.Done()
+
+		for event := range ch {
+			if targets := hooks(); len(targets) > 0 {
+				q.Send(targets, event)
+			}
+		}
+	}()
+}
+
+// Send queues event for the hooks that want it. It never blocks: if the
+// queue is full or closed, the notification is dropped and logged.
+func (q *Queue) Send(hooks []settings.Webhook, event events.Event) {
+	var body []byte
+	for _, hook := range hooks {
+		if !hook.Wants(event.Type) {
+			continue
+		}
+
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(event); err != nil {
+				q.giveUp(hook, event, 0, err)
+				return
+			}
+		}
+
+		q.mu.RLock()
+		if q.closed {
+			q.mu.RUnlock()
+			q.giveUp(hook, event, 0, fmt.Errorf("the queue is closed"))
+			continue
+		}
+
+		select {
+		case q.jobs <- job{hook: hook, event: event, body: body}:
+		default:
+			q.giveUp(hook, event, 0, fmt.Errorf("the queue is full"))
+		}
+		q.mu.RUnlock()
+	}
+}
+
+// Close unsubscribes from the events, stops taking notifications and
+// waits for the queued ones to be sent. If ctx is done first, the ones left are given up and its error
+// is returned.
+func (q *Queue) Close(ctx context.Context) error {
+	if q.unsubscribe != nil {
+		q.unsubscribe()
+		q.listening.Wait()
+	}
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.abortOnce.Do(func() { close(q.abort) })
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Len returns the number of notifications waiting to be sent and the
+// number that can wait.
+func (q *Queue) Len() (int, int) {
+	return len(q.jobs), cap(q.jobs)
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for j := range q.jobs {
+		q.deliver(j)
+	}
+}
+
+func (q *Queue) deliver(j job) {
+	backoff := firstBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := q.post(j)
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt == maxAttempts {
+			q.giveUp(j.hook, j.event, attempt, err)
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-q.abort:
+			q.giveUp(j.hook, j.event, attempt, fmt.Errorf("shutting down after: %v", err))
+			return
+		}
+	}
+}
+
+// post sends the notification once. It says if a failure is worth
+// retrying: the requests the endpoint rejected are not.
+func (q *Queue) post(j job) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, j.hook.URL, bytes.NewReader(j.body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if j.hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(j.hook.Secret, j.body))
+	}
+
+	res, err := q.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return true, fmt.Errorf("the endpoint answered %s", res.Status)
+	default:
+		return false, fmt.Errorf("the endpoint answered %s", res.Status)
+	}
+}
+
+func (q *Queue) giveUp(hook settings.Webhook, event events.Event, attempts int, err error) {
+	q.logger.Error("couldn't notify the webhook", "url", hook.URL, "event", event.Type,
+		"path", event.Path, "user", event.User, "attempts", attempts, "error", err)
+}
+
+// Sign returns the value of the SignatureHeader of body signed with
+// secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}